@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var accountTypes = []string{"taxable", "traditional-ira", "roth-ira", "401k", "roth-401k", "403b", "457b", "529", "hsa"}
+
+// default529Exchanges is how many times a year the IRS lets a 529 plan's
+// existing investments be changed
+const default529Exchanges = 2
+
+func validateAccounts(config *Config) error {
+	for _, account := range config.Accounts {
+		if account.Type != "" && !slices.Contains(accountTypes, account.Type) {
+			return fmt.Errorf("unknown type %q for account %s (expected one of %s)", account.Type, account.Name, strings.Join(accountTypes, ", "))
+		}
+		if account.ExchangesLeft != nil && (account.Type != "529" || *account.ExchangesLeft < 0) {
+			return fmt.Errorf("exchanges_left for account %s needs type 529 and must not be negative", account.Name)
+		}
+		if account.CashMinimum != 0 && (account.Type != "hsa" || account.CashMinimum < 0) {
+			return fmt.Errorf("cash_minimum for account %s needs type hsa and must not be negative", account.Name)
+		}
+		for _, blackout := range account.Blackouts {
+			if blackout.Start.IsZero() || blackout.End.Before(blackout.Start) {
+				return fmt.Errorf("blackout of account %s needs a start and an end no earlier than it", account.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// exchangesLeft is how many more times this year a 529 account's holdings
+// can be sold to rebalance
+func (a *Account) exchangesLeft() int {
+	if a.ExchangesLeft == nil {
+		return default529Exchanges
+	}
+	return *a.ExchangesLeft
+}
+
+// Blackout is a span of dates, both included, when an account can't be traded
+type Blackout struct {
+	Start  time.Time `yaml:"start"`
+	End    time.Time `yaml:"end"`
+	Reason string    `yaml:"reason,omitempty"`
+}
+
+// blackout is the blackout the account is in on now's date, or nil
+func (a *Account) blackout(now time.Time) *Blackout {
+	if a == nil {
+		return nil
+	}
+	date := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	for i, b := range a.Blackouts {
+		if !date.Before(b.Start) && !date.After(b.End) {
+			return &a.Blackouts[i]
+		}
+	}
+	return nil
+}
+
+// sellLocked reports whether rebalancing sales are off-limits in an account
+func (a *Account) sellLocked() bool {
+	return a != nil && a.Type == "529" && a.exchangesLeft() == 0
+}
+
+// applyAccountRules moves trades that break an account's rules to another
+// account holding the symbol. A trade that can only happen in an account in a
+// blackout on now's date is deferred, a sale that can only happen in a 529
+// with no exchanges left is dropped, a sale of a locked stock or locked lots
+// is cut back to what isn't locked, and the other symbols are rebalanced
+// around the held positions; a purchase in an HSA beyond the cash above its
+// minimum is flagged.
+func applyAccountRules(config *Config, holdings *Holdings, symbolData map[string]SymbolData, total int, now time.Time) {
+	held := make(map[string]bool)
+	for {
+		changed := false
+		for _, stock := range config.Stocks {
+			data := symbolData[stock.Symbol]
+			if floor := lockedAmount(stock, holdings); !held[stock.Symbol] && data.AmountNeeded < 0 && data.Amount+data.AmountNeeded < floor {
+				held[stock.Symbol] = true
+				data.AmountNeeded = min(floor-data.Amount, 0)
+				data.Locked = true
+				symbolData[stock.Symbol] = data
+				changed = true
+				continue
+			}
+			if blackout := config.account(data.Account, "").blackout(now); !held[stock.Symbol] && data.AmountNeeded != 0 && blackout != nil {
+				selling := max(-data.AmountNeeded, 0)
+				excluded := func(a *Account) bool { return a.blackout(now) != nil || selling > 0 && a.sellLocked() }
+				if other := ruleAccount(config, holdings.AmountsByAccount[stock.Symbol], selling, excluded); other != "" {
+					data.Account = other
+				} else {
+					held[stock.Symbol] = true
+					data.AmountNeeded = 0
+					data.Note = fmt.Sprintf("deferred, as %s can't be traded until after %s", data.Account, blackout.End.Format(time.DateOnly))
+					if blackout.Reason != "" {
+						data.Note += " (" + blackout.Reason + ")"
+					}
+					changed = true
+				}
+				symbolData[stock.Symbol] = data
+				continue
+			}
+			if held[stock.Symbol] || data.AmountNeeded >= 0 || !config.account(data.Account, "").sellLocked() {
+				continue
+			}
+			if other := ruleAccount(config, holdings.AmountsByAccount[stock.Symbol], -data.AmountNeeded, (*Account).sellLocked); other != "" {
+				data.Account = other
+			} else {
+				held[stock.Symbol] = true
+				data.AmountNeeded = 0
+				data.Note = fmt.Sprintf("held in %s, which has no 529 exchanges left this year", data.Account)
+				changed = true
+			}
+			symbolData[stock.Symbol] = data
+		}
+		if !changed {
+			break
+		}
+		// Spread what isn't held over the remaining symbols by their targets,
+		// as the same fraction of the way there as the first pass
+		heldAmount, heldPercentage, invested := 0, 0.0, 0
+		for _, stock := range config.Stocks {
+			if data := symbolData[stock.Symbol]; held[stock.Symbol] {
+				heldAmount += data.Amount + data.AmountNeeded
+				heldPercentage += data.TargetPercentage
+			} else {
+				invested += data.Amount
+			}
+		}
+		for _, stock := range config.Stocks {
+			if held[stock.Symbol] || heldPercentage >= 100 {
+				continue
+			}
+			data := symbolData[stock.Symbol]
+			target := stock.TargetPercentage * 100 / (100 - heldPercentage)
+			data.AmountNeeded = config.round(neededAmount(total-heldAmount, invested, data.Amount, target, config.rebalanceFraction()), "half-up")
+			data.Account = tradeAccount(holdings.AmountsByAccount[stock.Symbol])
+			symbolData[stock.Symbol] = data
+		}
+	}
+
+	for _, stock := range config.Stocks {
+		if data := symbolData[stock.Symbol]; data.Locked {
+			data.UnavoidableDrift = float64(data.Amount+data.AmountNeeded)/float64(total)*100 - stock.TargetPercentage
+			symbolData[stock.Symbol] = data
+		}
+	}
+
+	for _, stock := range config.Stocks {
+		data := symbolData[stock.Symbol]
+		account := config.account(data.Account, "")
+		if data.AmountNeeded <= 0 || account == nil || account.Type != "hsa" {
+			continue
+		}
+		cash := holdings.availableCash(config, account)
+		if data.AmountNeeded <= cash {
+			continue
+		}
+		overdrawn := func(a *Account) bool {
+			return a.blackout(now) != nil || a.Type == "hsa" && data.AmountNeeded > holdings.availableCash(config, a)
+		}
+		if other := ruleAccount(config, holdings.AmountsByAccount[stock.Symbol], 0, overdrawn); other != "" {
+			data.Account = other
+		} else {
+			data.Note = fmt.Sprintf("%s has only %s of cash above its %s minimum", account.Name, formatAmount(cash, true), formatAmount(int(account.CashMinimum), true))
+		}
+		symbolData[stock.Symbol] = data
+	}
+}
+
+// lockedAmount is how much of a stock can't be sold: all of it when the stock
+// is locked, or else its locked lots
+func lockedAmount(stock Stock, holdings *Holdings) int {
+	if stock.Locked {
+		return holdings.Amounts[stock.Symbol]
+	}
+	return holdings.Locked[stock.Symbol]
+}
+
+// ruleAccount picks the account holding the most of a symbol, at least
+// minAmount of it, that isn't excluded; "" if there is none
+func ruleAccount(config *Config, amounts map[string]int, minAmount int, excluded func(*Account) bool) string {
+	allowed := make(map[string]int)
+	for name, amount := range amounts {
+		if account := config.account(name, ""); amount >= minAmount && (account == nil || !excluded(account)) {
+			allowed[name] = amount
+		}
+	}
+	return tradeAccount(allowed)
+}
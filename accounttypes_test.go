@@ -0,0 +1,175 @@
+package main
+
+import (
+	"flag"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccountRules(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "account_types.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	holdings := loadHoldings(t, config, "account_types.csv")
+
+	type trade struct {
+		needed  int
+		account string
+		noted   bool
+	}
+	check := func(result *RebalanceResult, expected map[string]trade) {
+		t.Helper()
+		for symbol, e := range expected {
+			data := result.Symbols[symbol]
+			if data.AmountNeeded != e.needed || data.Account != e.account || (data.Note != "") != e.noted {
+				t.Errorf("%s mismatch: got %d in %s (note %q), expected %d in %s", symbol, data.AmountNeeded, data.Account, data.Note, e.needed, e.account)
+			}
+		}
+	}
+
+	// With no exchanges left, VTI can only be sold in the 529, so it is held
+	// and the rest of the portfolio is rebalanced around it
+	result, err := allocationCalc(config, holdings, 0)
+	if err != nil {
+		t.Fatalf("allocationCalc failed: %v", err)
+	}
+	check(result, map[string]trade{
+		"VTI":  {0, "College 529", true},
+		"VXUS": {160000, "Brokerage", false},
+		"BND":  {-160000, "HSA", false},
+	})
+
+	// With an exchange left the 529 sells, and the BND purchase moves out of
+	// the HSA, which has only $500 above its cash minimum
+	config.Accounts[0].ExchangesLeft = nil
+	result, err = allocationCalc(config, holdings, 0)
+	if err != nil {
+		t.Fatalf("allocationCalc failed: %v", err)
+	}
+	check(result, map[string]trade{
+		"VTI":  {-900000, "College 529", false},
+		"VXUS": {520000, "Brokerage", false},
+		"BND":  {380000, "Brokerage", false},
+	})
+
+	// While the brokerage is in a blackout, the VXUS purchase is deferred and
+	// the BND purchase stays in the HSA, short of cash as it is
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	config.Accounts[1].Blackouts = []Blackout{{Start: today.AddDate(0, 0, -3), End: today.AddDate(0, 0, 10), Reason: "trading window"}}
+	result, err = allocationCalc(config, holdings, 0)
+	if err != nil {
+		t.Fatalf("allocationCalc failed: %v", err)
+	}
+	check(result, map[string]trade{
+		"VTI":  {-575000, "College 529", false},
+		"VXUS": {0, "Brokerage", true},
+		"BND":  {575000, "HSA", true},
+	})
+	if note := result.Symbols["VXUS"].Note; !strings.Contains(note, "until after "+today.AddDate(0, 0, 10).Format(time.DateOnly)+" (trading window)") {
+		t.Errorf("Expected the note to give the blackout's end and reason, got %q", note)
+	}
+
+	// A blackout that has ended has no effect
+	config.Accounts[1].Blackouts[0].End = today.AddDate(0, 0, -1)
+	if result, err = allocationCalc(config, holdings, 0); err != nil {
+		t.Fatalf("allocationCalc failed: %v", err)
+	}
+	check(result, map[string]trade{"VXUS": {520000, "Brokerage", false}})
+
+	config.Accounts[1].Blackouts[0].End = today.AddDate(0, 0, -5)
+	if err := config.validate(); err == nil {
+		t.Error("Expected an error for a blackout ending before it starts")
+	}
+}
+
+func TestHSACashMinimumIsNotDrag(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "account_types.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	holdings := loadHoldings(t, config, "account_types.csv")
+
+	drags := cashDragCalc(config, holdings, nil, time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC))
+	if len(drags) != 1 || drags[0].Account != "HSA" || drags[0].Amount != 50000 {
+		t.Errorf("Expected $500 of HSA cash above its minimum, got %+v", drags)
+	}
+}
+
+func TestLockedStocks(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	holdings := loadHoldings(t, config, "cash.csv")
+	config.Stocks[0].TargetPercentage = 66
+	config.Stocks[2].TargetPercentage = 16
+
+	// VTI is locked, so it isn't sold and the others split what's left by
+	// their targets, leaving VTI 5 points over
+	config.Stocks[0].Locked = true
+	result, err := allocationCalc(config, holdings, 0)
+	if err != nil {
+		t.Fatalf("allocationCalc failed: %v", err)
+	}
+	expected := map[string]int{"VTI": 0, "VXUS": -264706, "BND": 264706}
+	for symbol, needed := range expected {
+		if got := result.Symbols[symbol].AmountNeeded; got != needed {
+			t.Errorf("Expected %s to need %d, got %d", symbol, needed, got)
+		}
+	}
+	if vti := result.Symbols["VTI"]; !vti.Locked || math.Abs(vti.UnavoidableDrift-5) > 1e-9 {
+		t.Errorf("Expected VTI locked with 5 points of drift left, got %+v", vti)
+	}
+
+	// With $69,000 of VTI in locked lots, only $2,000 of it is sold
+	config.Stocks[0].Locked = false
+	holdings.Locked = map[string]int{"VTI": 6900000}
+	if result, err = allocationCalc(config, holdings, 0); err != nil {
+		t.Fatalf("allocationCalc failed: %v", err)
+	}
+	expected = map[string]int{"VTI": -200000, "VXUS": -158824, "BND": 358824}
+	for symbol, needed := range expected {
+		if got := result.Symbols[symbol].AmountNeeded; got != needed {
+			t.Errorf("Expected %s to need %d, got %d", symbol, needed, got)
+		}
+	}
+	if vti := result.Symbols["VTI"]; !vti.Locked || math.Abs(vti.UnavoidableDrift-3) > 1e-9 {
+		t.Errorf("Expected VTI locked with 3 points of drift left, got %+v", vti)
+	}
+
+	// With -halfway, VTI still stops at its locked lots, and VXUS and BND go
+	// halfway to their share of the rest
+	flagSet := flag.NewFlagSet("rebalance", flag.ContinueOnError)
+	apply := fractionFlags(flagSet, config)
+	if err := flagSet.Parse([]string{"-halfway"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := apply(); err != nil {
+		t.Fatal(err)
+	}
+	if result, err = allocationCalc(config, holdings, 0); err != nil {
+		t.Fatalf("allocationCalc failed: %v", err)
+	}
+	expected = map[string]int{"VTI": -200000, "VXUS": -17343, "BND": 217343}
+	for symbol, needed := range expected {
+		if got := result.Symbols[symbol].AmountNeeded; got != needed {
+			t.Errorf("Expected %s to need %d halfway, got %d", symbol, needed, got)
+		}
+	}
+	config.RebalanceFraction = nil
+
+	// Locks don't hold back purchases
+	config.Stocks[0].TargetPercentage = 76
+	config.Stocks[2].TargetPercentage = 6
+	if result, err = allocationCalc(config, holdings, 0); err != nil {
+		t.Fatalf("allocationCalc failed: %v", err)
+	}
+	if vti := result.Symbols["VTI"]; vti.Locked || vti.AmountNeeded != 500000 {
+		t.Errorf("Expected VTI bought as usual, got %+v", vti)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// adviseLikely is the chance of a band breach before the next scheduled
+// check above which advise recommends acting now
+const adviseLikely = 0.5
+
+// SymbolAdvice is how close one symbol is to its band and how likely it is
+// to cross it before the next check
+type SymbolAdvice struct {
+	Symbol            string  `json:"symbol"`
+	CurrentPercentage float64 `json:"current_percentage"`
+	TargetPercentage  float64 `json:"target_percentage"`
+	Drift             float64 `json:"drift"`
+	// Band is the drift, in percentage points, the band policy allows, and
+	// Remaining how much of it is left
+	Band      float64 `json:"band"`
+	Remaining float64 `json:"remaining"`
+	// BreachDays is the typical time until the drift reaches the band; it's
+	// unset for symbols whose weight can't drift (a 0% or 100% target)
+	BreachDays *int `json:"breach_days,omitempty"`
+	// ExpectedDrift is the typical drift at the next check
+	ExpectedDrift float64 `json:"expected_drift"`
+	// BreachChance is the chance of reaching the band by the next check
+	BreachChance float64 `json:"breach_chance"`
+}
+
+type Advice struct {
+	AsOf      time.Time      `json:"as_of"`
+	Band      string         `json:"band"`
+	NextCheck time.Time      `json:"next_check"`
+	Schedule  string         `json:"schedule"`
+	Symbols   []SymbolAdvice `json:"symbols"`
+	// Outside lists the symbols already past their band
+	Outside []string `json:"outside"`
+	// BreachChance is the likeliest of any symbol's breach by the next check
+	BreachChance float64 `json:"breach_chance"`
+	// ActNow is set when a symbol is outside its band or likely to be by the
+	// next check, so waiting for it matters
+	ActNow bool `json:"act_now"`
+}
+
+func advise(config *Config, args []string) {
+	var nextStr, format string
+	flagSet := flag.NewFlagSet("advise", flag.ExitOnError)
+	flagSet.StringVar(&nextStr, "next", "", "Date of the next scheduled check (YYYY-MM-DD); defaults to the remind cadence's")
+	flagSet.StringVar(&format, "format", "text", "Output format: text or json")
+	if len(args) < 1 {
+		flag.Usage()
+		return
+	}
+	portfolioCsv := args[0]
+	flagSet.Parse(args[1:])
+	if format != "text" && format != "json" {
+		printError(codedErrorf(CodeUsage, "unknown format %q", format))
+		return
+	}
+
+	holdings, err := loadPortfolio(config, portfolioCsv)
+	if err != nil {
+		printError(err)
+		return
+	}
+	now := time.Now().Truncate(24 * time.Hour)
+	next := portfolioReminders(config, holdings, now, 1)[0]
+	if nextStr != "" {
+		date, err := time.Parse(time.DateOnly, nextStr)
+		if err != nil {
+			printError(codedErrorf(CodeUsage, "parsing next date: %w", err))
+			return
+		}
+		next = Reminder{Date: date, Reason: "scheduled check"}
+	}
+	if !next.Date.After(now) {
+		printError(codedErrorf(CodeUsage, "next check %s is not after today", next.Date.Format(time.DateOnly)))
+		return
+	}
+	advice := adviseCalc(config, holdings, now, next)
+
+	if format == "json" {
+		if err := writeJSON(os.Stdout, advice); err != nil {
+			printError(err)
+		}
+		return
+	}
+	fmt.Printf("Drift against the %s; next check %s (%s)\n", advice.Band, advice.NextCheck.Format(time.DateOnly), advice.Schedule)
+	fmt.Println(rule())
+	fmt.Printf("%-6s %8s %8s %8s %12s %12s %9s\n", "Symbol", "Drift", "Band", "Left", "Breach in", "At check", "Chance")
+	for _, s := range advice.Symbols {
+		breach := "never"
+		switch {
+		case s.Remaining <= 0:
+			breach = "now"
+		case s.BreachDays != nil:
+			breach = fmt.Sprintf("~%d days", *s.BreachDays)
+		}
+		fmt.Printf("%-6s %+8.2f %8.2f %8.2f %12s %12s %8.0f%%\n", s.Symbol, s.Drift, s.Band, s.Remaining, breach, fmt.Sprintf("±%.2f", s.ExpectedDrift), s.BreachChance*100)
+	}
+	fmt.Println()
+	days := int(math.Round(advice.NextCheck.Sub(advice.AsOf).Hours() / 24))
+	switch {
+	case len(advice.Outside) > 0:
+		fmt.Println(red(fmt.Sprintf("Already outside the band: %s; rebalance now rather than in %d days", strings.Join(advice.Outside, ", "), days)))
+	case advice.ActNow:
+		fmt.Println(red(fmt.Sprintf("A breach before the next check is likely (%.0f%%); rebalancing now keeps drift from running past the band for part of the next %d days", advice.BreachChance*100, days)))
+	default:
+		fmt.Println(green(fmt.Sprintf("A breach before the next check is unlikely (%.0f%%); waiting the %d days costs little", advice.BreachChance*100, days)))
+	}
+	fmt.Println("Chances assume drift grows as a random walk at remind's volatility, ignoring deposits and withdrawals.")
+}
+
+// adviseBand is the drift, in percentage points, a symbol with target percent
+// may reach before the band policy calls for a trade: the 5/25 rule's or
+// remind's band
+func adviseBand(config *Config, target float64) float64 {
+	if config.BandPolicy == "5/25" && target > 0 {
+		return min(swedroeAbsoluteBand, target*swedroeRelativeBand/100)
+	}
+	return config.Remind.band()
+}
+
+// adviseBandLabel names the band adviseBand measures drift against
+func adviseBandLabel(config *Config) string {
+	if config.BandPolicy == "5/25" {
+		return config.bandLabel()
+	}
+	return fmt.Sprintf("%g-point band", config.Remind.band())
+}
+
+// adviseCalc estimates each symbol's chance of drifting to its band by the
+// next check. Drift is taken as a random walk with a yearly standard
+// deviation of driftSpread, so by the reflection principle the chance it
+// reaches a distance d within t years is erfc(d / (spread·√(2t))).
+func adviseCalc(config *Config, holdings *Holdings, now time.Time, next Reminder) *Advice {
+	advice := &Advice{AsOf: now, Band: adviseBandLabel(config), NextCheck: next.Date, Schedule: next.Reason, Symbols: []SymbolAdvice{}, Outside: []string{}}
+	total := 0
+	for _, stock := range config.Stocks {
+		total += holdings.Amounts[stock.Symbol]
+	}
+	years := next.Date.Sub(now).Hours() / 24 / 365
+	for _, stock := range config.Stocks {
+		s := SymbolAdvice{Symbol: stock.Symbol, TargetPercentage: stock.TargetPercentage}
+		if total > 0 {
+			s.CurrentPercentage = float64(holdings.Amounts[stock.Symbol]) / float64(total) * 100
+		}
+		s.Drift = s.CurrentPercentage - s.TargetPercentage
+		s.Band = adviseBand(config, s.TargetPercentage)
+		s.Remaining = max(s.Band-math.Abs(s.Drift), 0)
+		spread := config.Remind.driftSpread(s.TargetPercentage)
+		s.ExpectedDrift = math.Sqrt(s.Drift*s.Drift + spread*spread*years)
+		switch {
+		case s.Remaining <= 0:
+			days := 0
+			s.BreachDays, s.BreachChance = &days, 1
+			if math.Abs(s.Drift) > s.Band {
+				advice.Outside = append(advice.Outside, s.Symbol)
+			}
+		case spread > 0:
+			days := int(math.Round(math.Pow(s.Remaining/spread, 2) * 365))
+			s.BreachDays = &days
+			s.BreachChance = math.Erfc(s.Remaining / (spread * math.Sqrt(2*years)))
+		}
+		advice.BreachChance = max(advice.BreachChance, s.BreachChance)
+		advice.Symbols = append(advice.Symbols, s)
+	}
+	advice.ActNow = len(advice.Outside) > 0 || advice.BreachChance >= adviseLikely
+	return advice
+}
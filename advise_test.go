@@ -0,0 +1,51 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAdviseCalc(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	holdings := loadHoldings(t, config, "cash.csv")
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	quarter := Reminder{Date: now.AddDate(0, 3, 0), Reason: "quarterly rebalance check"}
+
+	advice := adviseCalc(config, holdings, now, quarter)
+	if advice.ActNow || len(advice.Outside) != 0 {
+		t.Errorf("Expected a portfolio on target to wait, got %+v", advice)
+	}
+
+	// VTI and BND are 3 points off, with 2 left before the 5-point band
+	config.Stocks[0].TargetPercentage = 68
+	config.Stocks[2].TargetPercentage = 14
+	advice = adviseCalc(config, holdings, now, quarter)
+	vti := advice.Symbols[0]
+	if vti.Remaining != 2 || vti.BreachDays == nil || *vti.BreachDays != 137 {
+		t.Errorf("Expected VTI to have 2 points left, breached in about 137 days, got %+v", vti)
+	}
+	if advice.ActNow || advice.BreachChance < 0.15 || advice.BreachChance > 0.3 {
+		t.Errorf("Expected a breach within a quarter to be unlikely but possible, got %v", advice.BreachChance)
+	}
+	advice = adviseCalc(config, holdings, now, Reminder{Date: now.AddDate(1, 0, 0)})
+	if !advice.ActNow {
+		t.Errorf("Expected a breach within a year to be likely, got %v", advice.BreachChance)
+	}
+
+	// With the 5/25 rule, BND's band is a quarter of its 14% target
+	config.BandPolicy = "5/25"
+	advice = adviseCalc(config, holdings, now, quarter)
+	if bnd := advice.Symbols[2]; bnd.Band != 3.5 || !advice.ActNow || len(advice.Outside) != 0 {
+		t.Errorf("Expected BND's 3.5-point band to be nearly reached, got %+v", bnd)
+	}
+	config.Stocks[2].TargetPercentage = 16
+	config.Stocks[0].TargetPercentage = 66
+	advice = adviseCalc(config, holdings, now, quarter)
+	if len(advice.Outside) != 1 || advice.Outside[0] != "BND" {
+		t.Errorf("Expected BND outside its band, got %v", advice.Outside)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// HeldAs is the part of a configured symbol's value held in one symbol, the
+// primary itself or an alternative such as a tax-loss harvesting partner
+type HeldAs struct {
+	Symbol string `json:"symbol"`
+	Amount int    `json:"amount"`
+	// Percentage is the share of the portfolio, as with CurrentPercentage
+	Percentage float64 `json:"percentage"`
+}
+
+// heldAsCalc splits each configured symbol held through more than one symbol
+// into the symbols held, the primary first and then its alternatives in
+// config order. Values counted without a row of their own, such as a
+// target-date fund's underlying holdings, aren't in the split.
+func heldAsCalc(config *Config, holdings *Holdings, total int) map[string][]HeldAs {
+	amounts := make(map[string]map[string]int)
+	for _, position := range holdings.Positions {
+		if amounts[position.Primary] == nil {
+			amounts[position.Primary] = make(map[string]int)
+		}
+		amounts[position.Primary][position.Symbol] += position.Value
+	}
+	splits := make(map[string][]HeldAs)
+	for _, stock := range config.Stocks {
+		held := amounts[stock.Symbol]
+		if _, primary := held[stock.Symbol]; len(held) == 0 || len(held) == 1 && primary {
+			continue
+		}
+		order := func(symbol string) int {
+			if symbol == stock.Symbol {
+				return -1
+			}
+			if i := slices.Index(stock.Alternatives, symbol); i >= 0 {
+				return i
+			}
+			return len(stock.Alternatives)
+		}
+		var split []HeldAs
+		for symbol, amount := range held {
+			split = append(split, HeldAs{Symbol: symbol, Amount: amount, Percentage: float64(amount) / float64(total) * 100})
+		}
+		slices.SortFunc(split, func(a, b HeldAs) int {
+			return cmp.Or(cmp.Compare(order(a.Symbol), order(b.Symbol)), cmp.Compare(a.Symbol, b.Symbol))
+		})
+		splits[stock.Symbol] = split
+	}
+	return splits
+}
+
+func printHeldAs(stock Stock, split []HeldAs) {
+	if len(split) == 0 {
+		return
+	}
+	fmt.Println("Held as:")
+	for _, held := range split {
+		line := fmt.Sprintf("  %-8s %14s %7.2f%%", held.Symbol, formatAmount(held.Amount, true), held.Percentage)
+		if slices.Contains(stock.Alternatives, held.Symbol) {
+			line += " (alternative)"
+		}
+		fmt.Println(line)
+	}
+}
+
+// TradeAs is the part of a configured symbol's trade placed in one symbol
+type TradeAs struct {
+	Symbol string `json:"symbol"`
+	Amount int    `json:"amount"`
+}
+
+// tradeAsCalc places a stock's trade of needed cents when it has
+// prefer_for_buys. Purchases all go to the preferred symbol. Sales come from
+// the other symbols first, the stock's own and then its alternatives in config
+// order, up to what each holds, and from the preferred symbol last.
+func tradeAsCalc(stock Stock, positions []Position, needed int) []TradeAs {
+	if stock.PreferForBuys == "" || needed == 0 {
+		return nil
+	}
+	if needed > 0 {
+		return []TradeAs{{Symbol: stock.PreferForBuys, Amount: needed}}
+	}
+
+	held := make(map[string]int)
+	for _, position := range positions {
+		if position.Primary == stock.Symbol {
+			held[position.Symbol] += position.Value
+		}
+	}
+	order := []string{}
+	for _, symbol := range append([]string{stock.Symbol}, stock.Alternatives...) {
+		if symbol != stock.PreferForBuys {
+			order = append(order, symbol)
+		}
+	}
+	order = append(order, stock.PreferForBuys)
+
+	var trades []TradeAs
+	remaining := -needed
+	for _, symbol := range order {
+		sale := min(held[symbol], remaining)
+		if sale <= 0 {
+			continue
+		}
+		trades = append(trades, TradeAs{Symbol: symbol, Amount: -sale})
+		remaining -= sale
+	}
+	// Whatever the rows don't account for, such as a target-date fund's
+	// underlying holdings, is left to the preferred symbol
+	if remaining > 0 {
+		if n := len(trades); n > 0 && trades[n-1].Symbol == stock.PreferForBuys {
+			trades[n-1].Amount -= remaining
+		} else {
+			trades = append(trades, TradeAs{Symbol: stock.PreferForBuys, Amount: -remaining})
+		}
+	}
+	return trades
+}
+
+func printTradeAs(trades []TradeAs) {
+	if len(trades) == 0 {
+		return
+	}
+	fmt.Println("Trade as:")
+	for _, trade := range trades {
+		fmt.Printf("  %-8s %s\n", trade.Symbol, tradeText(trade.Amount, formatAmount(trade.Amount, true)))
+	}
+}
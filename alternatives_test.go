@@ -0,0 +1,75 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestHeldAs(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	config.Stocks[0].Alternatives = []string{"ITOT"}
+	holdings := loadHoldings(t, config, "tlh.csv")
+	result, err := allocationCalc(config, holdings, 0)
+	if err != nil {
+		t.Fatalf("allocationCalc failed: %v", err)
+	}
+
+	// VTI and its harvesting partner are on target together
+	vti := result.Symbols["VTI"]
+	if vti.CurrentPercentage != 71 || vti.AmountNeeded != 0 {
+		t.Errorf("Expected VTI on target combined, got %+v", vti)
+	}
+	expected := []HeldAs{{Symbol: "VTI", Amount: 5000000, Percentage: 50}, {Symbol: "ITOT", Amount: 2100000, Percentage: 21}}
+	if !reflect.DeepEqual(vti.HeldAs, expected) {
+		t.Errorf("Split mismatch: got %+v, expected %+v", vti.HeldAs, expected)
+	}
+	// Symbols held only as themselves have no split
+	if split := result.Symbols["BND"].HeldAs; split != nil {
+		t.Errorf("Expected no split for BND, got %+v", split)
+	}
+}
+
+func TestTradeAs(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	config.Stocks[0].Alternatives = []string{"ITOT"}
+	config.Stocks[0].PreferForBuys = "ITOT"
+	holdings := loadHoldings(t, config, "tlh.csv")
+
+	tests := []struct {
+		vti, bnd float64
+		expected []TradeAs
+	}{
+		// Buys go to the partner fund, leaving VTI held
+		{76, 6, []TradeAs{{Symbol: "ITOT", Amount: 500000}}},
+		// Sales come from VTI before the preferred ITOT
+		{66, 16, []TradeAs{{Symbol: "VTI", Amount: -500000}}},
+		{16, 66, []TradeAs{{Symbol: "VTI", Amount: -5000000}, {Symbol: "ITOT", Amount: -500000}}},
+		{71, 11, nil},
+	}
+	for _, test := range tests {
+		config.Stocks[0].TargetPercentage = test.vti
+		config.Stocks[2].TargetPercentage = test.bnd
+		result, err := allocationCalc(config, holdings, 0)
+		if err != nil {
+			t.Fatalf("allocationCalc failed: %v", err)
+		}
+		if got := result.Symbols["VTI"].TradeAs; !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("VTI at %g%%: expected %+v, got %+v", test.vti, test.expected, got)
+		}
+		if got := result.Symbols["BND"].TradeAs; got != nil {
+			t.Errorf("Expected no split for BND without prefer_for_buys, got %+v", got)
+		}
+	}
+
+	config.Stocks[0].PreferForBuys = "SCHB"
+	if err := config.validate(); err == nil {
+		t.Error("Expected an error for prefer_for_buys outside the alternatives")
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// archiveDir is where every command's report is saved, from -archiveDir or
+// the config's archive_dir
+var archiveDir string
+
+// archiveTimeLayout starts the name of an archived report
+const archiveTimeLayout = "20060102-150405"
+
+// unarchivedCommands don't produce reports worth keeping
+var unarchivedCommands = []string{"history", "serve", "audit"}
+
+// archiveExtensions maps a -format value to the extension of its archived
+// report; anything else is archived as .txt
+var archiveExtensions = map[string]string{"json": "json", "html": "html", "csv": "csv", "ics": "ics", "svg": "svg", "png": "png"}
+
+// ArchivedReport is a report saved to the archive directory
+type ArchivedReport struct {
+	Time    time.Time `json:"time"`
+	Command string    `json:"command"`
+	Format  string    `json:"format"`
+	Path    string    `json:"path"`
+	// seq numbers reports archived within the same second
+	seq int
+}
+
+// archiveReport copies the report captured at path into dir as
+// <time>-<command>.<ext>, returning the archived path. An empty report, such
+// as that of a command writing to an -o file, isn't archived.
+func archiveReport(dir, command string, args []string, path string, now time.Time) (string, error) {
+	report, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer report.Close()
+	if info, err := report.Stat(); err != nil || info.Size() == 0 {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	ext, ok := archiveExtensions[formatRequested(args)]
+	if !ok {
+		ext = "txt"
+	}
+	base := now.Format(archiveTimeLayout) + "-" + command
+	// Runs within the same second are numbered rather than overwritten
+	for n := 1; ; n++ {
+		name := base + "." + ext
+		if n > 1 {
+			name = base + "." + strconv.Itoa(n) + "." + ext
+		}
+		archived := filepath.Join(dir, name)
+		file, err := os.OpenFile(archived, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		if _, err := io.Copy(file, report); err != nil {
+			file.Close()
+			return "", err
+		}
+		return archived, file.Close()
+	}
+}
+
+// listArchive returns the reports in dir, newest first. Files that weren't
+// named by archiveReport are skipped.
+func listArchive(dir string) ([]ArchivedReport, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var reports []ArchivedReport
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || len(name) <= len(archiveTimeLayout)+1 {
+			continue
+		}
+		t, err := time.ParseInLocation(archiveTimeLayout, name[:len(archiveTimeLayout)], time.Local)
+		if err != nil || name[len(archiveTimeLayout)] != '-' {
+			continue
+		}
+		rest := name[len(archiveTimeLayout)+1:]
+		ext := filepath.Ext(rest)
+		command, seq := strings.TrimSuffix(rest, ext), 1
+		if n := filepath.Ext(command); n != "" {
+			if number, err := strconv.Atoi(n[1:]); err == nil {
+				command, seq = strings.TrimSuffix(command, n), number
+			}
+		}
+		reports = append(reports, ArchivedReport{Time: t, Command: command, Format: strings.TrimPrefix(ext, "."), Path: filepath.Join(dir, name), seq: seq})
+	}
+	sort.SliceStable(reports, func(i, j int) bool {
+		if !reports[i].Time.Equal(reports[j].Time) {
+			return reports[i].Time.After(reports[j].Time)
+		}
+		return reports[i].seq > reports[j].seq
+	})
+	return reports, nil
+}
+
+func historyList(args []string) {
+	var command string
+	var limit int
+	flagSet := flag.NewFlagSet("history list", flag.ExitOnError)
+	flagSet.StringVar(&command, "command", "", "Only list reports of this command")
+	flagSet.IntVar(&limit, "n", 20, "Number of most recent reports to list (0 for all)")
+	flagSet.Parse(args)
+	if archiveDir == "" {
+		printError(codedErrorf(CodeConfigInvalid, "no archive directory; pass -archiveDir or set archive_dir in the config"))
+		return
+	}
+
+	reports, err := listArchive(archiveDir)
+	if err != nil {
+		printError(err)
+		return
+	}
+	shown := 0
+	for _, report := range reports {
+		if command != "" && report.Command != command {
+			continue
+		}
+		if limit > 0 && shown == limit {
+			break
+		}
+		fmt.Printf("%s  %-16s %-5s %s\n", report.Time.Format(time.DateTime), report.Command, report.Format, report.Path)
+		shown++
+	}
+	if shown == 0 {
+		fmt.Println("No archived reports")
+	}
+}
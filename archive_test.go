@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestArchiveReport(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "reports")
+	report := filepath.Join(t.TempDir(), "report.txt")
+	if err := os.WriteFile(report, []byte(`{"total": 1}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, time.October, 17, 9, 30, 0, 0, time.Local)
+
+	var paths []string
+	for _, args := range [][]string{{"portfolio.csv", "-format", "json"}, {"portfolio.csv", "-format=json"}, {"portfolio.csv"}} {
+		path, err := archiveReport(dir, "rebalance", args, report, now)
+		if err != nil {
+			t.Fatalf("archiveReport failed: %v", err)
+		}
+		paths = append(paths, filepath.Base(path))
+	}
+	expected := []string{"20261017-093000-rebalance.json", "20261017-093000-rebalance.2.json", "20261017-093000-rebalance.txt"}
+	for i := range expected {
+		if paths[i] != expected[i] {
+			t.Errorf("Archive %d mismatch: got %s, expected %s", i, paths[i], expected[i])
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, expected[0])); string(data) != `{"total": 1}` {
+		t.Errorf("Archived report mismatch: got %q", data)
+	}
+
+	// Output written to an -o file leaves nothing to archive
+	empty := filepath.Join(t.TempDir(), "empty.txt")
+	os.WriteFile(empty, nil, 0o600)
+	if path, err := archiveReport(dir, "withdrawal-plan", nil, empty, now.Add(time.Hour)); err != nil || path != "" {
+		t.Errorf("Expected an empty report to be skipped, got %q (%v)", path, err)
+	}
+
+	if _, err := archiveReport(dir, "withdrawal-plan", nil, report, now.Add(time.Hour)); err != nil {
+		t.Fatalf("archiveReport failed: %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0o600)
+	reports, err := listArchive(dir)
+	if err != nil {
+		t.Fatalf("listArchive failed: %v", err)
+	}
+	if len(reports) != 4 {
+		t.Fatalf("Expected 4 archived reports, got %+v", reports)
+	}
+	if reports[0].Command != "withdrawal-plan" || !reports[0].Time.Equal(now.Add(time.Hour)) {
+		t.Errorf("Newest report mismatch: %+v", reports[0])
+	}
+	if filepath.Base(reports[1].Path) != expected[1] || reports[1].Command != "rebalance" || reports[1].Format != "json" {
+		t.Errorf("Numbered report mismatch: %+v", reports[1])
+	}
+
+	if reports, err := listArchive(filepath.Join(t.TempDir(), "missing")); err != nil || len(reports) != 0 {
+		t.Errorf("Expected no reports in a missing directory, got %v (%v)", reports, err)
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unauditedCommands aren't recorded in the audit log, as they only read it
+var unauditedCommands = []string{"audit"}
+
+// AuditOutputs are the key results of a command, recorded in the audit log
+type AuditOutputs struct {
+	Total *int `json:"total,omitempty"`
+	// MaxDrift is the largest drift of any symbol, in percentage points
+	MaxDrift *float64 `json:"max_drift,omitempty"`
+	// PlanExported is where the trade plan was written: a saved plan file,
+	// an export with trades, or a Google Sheet
+	PlanExported string `json:"plan_exported,omitempty"`
+	// Report is where the report was archived
+	Report string `json:"report,omitempty"`
+	// Error is the code of the first error the command reported
+	Error string `json:"error,omitempty"`
+}
+
+// auditOutputs are the results the command found, set as it runs
+var auditOutputs AuditOutputs
+
+// rebalanced records the total and largest drift of a rebalance result
+func (o *AuditOutputs) rebalanced(result *RebalanceResult) {
+	total, drift := result.Total, 0.0
+	for _, data := range result.Symbols {
+		if abs := max(data.Drift, -data.Drift); abs > drift {
+			drift = abs
+		}
+	}
+	o.Total, o.MaxDrift = &total, &drift
+}
+
+// AuditEntry is one command run in the audit log: its manifest and outputs
+type AuditEntry struct {
+	Manifest
+	Outputs AuditOutputs `json:"outputs"`
+	// Prev is a checksum of the line before, chaining the log so that an
+	// edited or deleted entry shows
+	Prev string `json:"prev,omitempty"`
+}
+
+type AuditLog struct {
+	Entries []AuditEntry `json:"entries"`
+	// Broken are the numbers, from 1, of the entries that don't follow the
+	// one before them
+	Broken []int `json:"broken"`
+}
+
+// auditHash is the checksum of one line of the audit log
+func auditHash(line []byte) string {
+	hash := sha256.Sum256(line)
+	return hex.EncodeToString(hash[:8])
+}
+
+// auditLines splits the log at path into its lines, dropping blank ones. A
+// missing log has none.
+func auditLines(path string) ([][]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var lines [][]byte
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// appendAudit adds entry to the end of the log at path, chained to the
+// last entry. Nothing rewrites the log, and it's kept read-only between
+// runs so that other programs don't either.
+func appendAudit(path string, entry *AuditEntry) error {
+	guard, err := guardStore(path)
+	if err != nil {
+		return err
+	}
+	return guard.write(func() error {
+		lines, err := auditLines(path)
+		if err != nil {
+			return err
+		}
+		if len(lines) > 0 {
+			entry.Prev = auditHash(lines[len(lines)-1])
+		}
+		if err := os.Chmod(path, 0o600); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if err := appendJSONLines(path, []*AuditEntry{entry}); err != nil {
+			return err
+		}
+		return os.Chmod(path, 0o400)
+	})
+}
+
+// readAudit reads the log at path, oldest first, with the number of each
+// entry (from 1) that doesn't follow the one before it
+func readAudit(path string) ([]AuditEntry, []int, error) {
+	lines, err := auditLines(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	entries := make([]AuditEntry, len(lines))
+	var broken []int
+	for i, line := range lines {
+		if err := json.Unmarshal(line, &entries[i]); err != nil {
+			return nil, nil, fmt.Errorf("%s line %d: %w", path, i+1, err)
+		}
+		prev := ""
+		if i > 0 {
+			prev = auditHash(lines[i-1])
+		}
+		if entries[i].Prev != prev {
+			broken = append(broken, i+1)
+		}
+	}
+	return entries, broken, nil
+}
+
+// recordAudit appends the run to the config's audit log, if it has one
+func recordAudit(config *Config, command string) {
+	if config.AuditLog == "" || runManifest == nil || slices.Contains(unauditedCommands, command) {
+		return
+	}
+	if skipDryRun("Record the run in "+config.AuditLog, "") {
+		return
+	}
+	entry := &AuditEntry{Manifest: *runManifest, Outputs: auditOutputs}
+	if err := appendAudit(config.AuditLog, entry); err != nil {
+		printError(fmt.Errorf("recording the run in the audit log: %w", err))
+	}
+}
+
+func audit(config *Config, args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: fin-tilt audit show [-n <count>] [-command <command>] [-format json]")
+		return
+	}
+	switch args[0] {
+	case "show":
+		auditShow(config, args[1:])
+	default:
+		printError(codedErrorf(CodeUsage, "unknown audit command %q", args[0]))
+	}
+}
+
+func auditShow(config *Config, args []string) {
+	var command, format string
+	var limit int
+	flagSet := flag.NewFlagSet("audit show", flag.ExitOnError)
+	flagSet.StringVar(&command, "command", "", "Only show runs of this command")
+	flagSet.IntVar(&limit, "n", 20, "Number of most recent runs to show (0 for all)")
+	flagSet.StringVar(&format, "format", "text", "Output format: text or json")
+	flagSet.Parse(args)
+	if format != "text" && format != "json" {
+		printError(codedErrorf(CodeUsage, "unknown format %q", format))
+		return
+	}
+	if config.AuditLog == "" {
+		printError(codedErrorf(CodeConfigInvalid, "no audit log; set audit_log in the config"))
+		return
+	}
+
+	entries, broken, err := readAudit(config.AuditLog)
+	if err != nil {
+		printError(err)
+		return
+	}
+	shown := []AuditEntry{}
+	for _, entry := range slices.Backward(entries) {
+		if command != "" && entry.Command != command {
+			continue
+		}
+		if limit > 0 && len(shown) == limit {
+			break
+		}
+		shown = append(shown, entry)
+	}
+	slices.Reverse(shown)
+
+	if format == "json" {
+		if err := writeJSON(os.Stdout, AuditLog{Entries: shown, Broken: append([]int{}, broken...)}); err != nil {
+			printError(err)
+		}
+		return
+	}
+	if len(shown) == 0 {
+		fmt.Println("No runs recorded")
+	}
+	for _, entry := range shown {
+		fmt.Printf("%s  %s\n", entry.Time.Local().Format(time.DateTime), strings.Join(entry.Args, " "))
+		if outputs := auditOutputsText(entry.Outputs); outputs != "" {
+			fmt.Println(wrapText("    "+outputs, terminalWidth))
+		}
+	}
+	if len(broken) > 0 {
+		numbers := make([]string, len(broken))
+		for i, number := range broken {
+			numbers[i] = strconv.Itoa(number)
+		}
+		label := "entry"
+		if len(broken) > 1 {
+			label = "entries"
+		}
+		fmt.Println(red(fmt.Sprintf("Warning: the log was edited; the chain breaks at %s %s", label, strings.Join(numbers, ", "))))
+	} else if len(entries) > 0 {
+		fmt.Printf("All %d entries are intact\n", len(entries))
+	}
+}
+
+func auditOutputsText(o AuditOutputs) string {
+	var parts []string
+	if o.Total != nil {
+		parts = append(parts, "total "+formatAmount(*o.Total, true))
+	}
+	if o.MaxDrift != nil {
+		parts = append(parts, fmt.Sprintf("max drift %.2f%%", *o.MaxDrift))
+	}
+	if o.PlanExported != "" {
+		parts = append(parts, "plan exported to "+o.PlanExported)
+	}
+	if o.Report != "" {
+		parts = append(parts, "archived as "+o.Report)
+	}
+	if o.Error != "" {
+		parts = append(parts, red("failed with "+o.Error))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	saved, savedOutputs := runManifest, auditOutputs
+	t.Cleanup(func() { runManifest, auditOutputs = saved, savedOutputs })
+
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	holdings := loadHoldings(t, config, "cash.csv")
+	config.Stocks[0].TargetPercentage = 66
+	config.Stocks[2].TargetPercentage = 16
+	result, err := allocationCalc(config, holdings, 0)
+	if err != nil {
+		t.Fatalf("allocationCalc failed: %v", err)
+	}
+
+	config.AuditLog = path
+	runManifest = &Manifest{Tool: "fin-tilt", Command: "rebalance", Args: []string{"rebalance", "cash.csv"}, Time: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}
+	auditOutputs = AuditOutputs{}
+	auditOutputs.rebalanced(result)
+	recordAudit(config, "rebalance")
+	runManifest = &Manifest{Tool: "fin-tilt", Command: "plan", Args: []string{"plan", "save", "cash.csv"}}
+	auditOutputs = AuditOutputs{PlanExported: "plan.json", Error: CodeUsage}
+	recordAudit(config, "plan")
+	runManifest.Command = "audit"
+	recordAudit(config, "audit")
+
+	entries, broken, err := readAudit(path)
+	if err != nil {
+		t.Fatalf("readAudit failed: %v", err)
+	}
+	if len(entries) != 2 || len(broken) != 0 {
+		t.Fatalf("Expected two intact entries, audit itself unrecorded; got %d, broken %v", len(entries), broken)
+	}
+	first := entries[0].Outputs
+	if first.Total == nil || *first.Total != result.Total || first.MaxDrift == nil || *first.MaxDrift != 5 {
+		t.Errorf("Expected the total and a max drift of 5, got %+v", first)
+	}
+	if entries[0].Command != "rebalance" || entries[0].Prev != "" || entries[1].Prev == "" {
+		t.Errorf("Expected the second entry chained to the first, got %+v", entries)
+	}
+	if entries[1].Outputs.PlanExported != "plan.json" || entries[1].Outputs.Error != CodeUsage {
+		t.Errorf("Expected the exported plan and error, got %+v", entries[1].Outputs)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o400 {
+		t.Errorf("Expected the log to be read-only between runs, got %v", info.Mode())
+	}
+
+	// Editing an entry breaks the chain at the next one
+	data, _ := os.ReadFile(path)
+	os.Chmod(path, 0o600)
+	if err := os.WriteFile(path, []byte(strings.Replace(string(data), `"rebalance"`, `"deposit"`, 1)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, broken, err := readAudit(path); err != nil || len(broken) != 1 || broken[0] != 2 {
+		t.Errorf("Expected the chain to break at entry 2, got %v, %v", broken, err)
+	}
+}
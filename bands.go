@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+const (
+	// swedroeAbsoluteBand and swedroeRelativeBand are the 5/25 rule: a
+	// holding needs rebalancing once it drifts more than 5 percentage points
+	// from its target, or more than 25% of its target
+	swedroeAbsoluteBand = 5.0
+	swedroeRelativeBand = 25.0
+)
+
+// bandPolicies decide which symbols rebalance flags as needing a trade:
+// absolute flags drift beyond remind's band, and 5/25 follows the 5/25 rule.
+// Without a policy every symbol with a trade is flagged.
+var bandPolicies = []string{"absolute", "5/25"}
+
+func validateBandPolicy(config *Config) error {
+	if config.BandPolicy != "" && !slices.Contains(bandPolicies, config.BandPolicy) {
+		return fmt.Errorf("unknown band_policy %q (expected one of %s)", config.BandPolicy, strings.Join(bandPolicies, ", "))
+	}
+	return nil
+}
+
+// outsideSwedroeBands reports whether a holding at current percent of the
+// portfolio breaks the 5/25 rule for its target percent
+func outsideSwedroeBands(target, current float64) bool {
+	drift := math.Abs(current - target)
+	return drift > swedroeAbsoluteBand || (target > 0 && drift > target*swedroeRelativeBand/100)
+}
+
+// actionable reports whether a symbol's drift calls for a trade under the
+// band policy
+func (c *Config) actionable(data SymbolData) bool {
+	switch c.BandPolicy {
+	case "absolute":
+		return math.Abs(data.Drift) > c.Remind.band()
+	case "5/25":
+		return outsideSwedroeBands(data.TargetPercentage, data.CurrentPercentage)
+	}
+	return data.AmountNeeded != 0
+}
+
+// bandLabel names the band policy's band for reports
+func (c *Config) bandLabel() string {
+	if c.BandPolicy == "absolute" {
+		return fmt.Sprintf("%g-point band", c.Remind.band())
+	}
+	return c.BandPolicy + " band"
+}
+
+// printBands lists the symbols outside the band policy's band
+func printBands(config *Config, result *RebalanceResult) {
+	if config.BandPolicy == "" {
+		return
+	}
+	var outside []string
+	for _, stock := range config.Stocks {
+		if result.Symbols[stock.Symbol].Actionable {
+			outside = append(outside, stock.Symbol)
+		}
+	}
+	if len(outside) == 0 {
+		fmt.Println(green(fmt.Sprintf("Every symbol is within the %s; no trades needed", config.bandLabel())))
+		return
+	}
+	fmt.Println(red(fmt.Sprintf("Outside the %s: %s", config.bandLabel(), strings.Join(outside, ", "))))
+}
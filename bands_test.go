@@ -0,0 +1,70 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestOutsideSwedroeBands(t *testing.T) {
+	tests := []struct {
+		target, current float64
+		expected        bool
+	}{
+		{60, 64, false},
+		{60, 65.5, true}, // more than 5 points
+		{10, 12, false},
+		{10, 12.6, true}, // more than 25% of the target
+		{10, 7.4, true},
+		{0, 1, false},
+	}
+	for _, tt := range tests {
+		if got := outsideSwedroeBands(tt.target, tt.current); got != tt.expected {
+			t.Errorf("outsideSwedroeBands(%v, %v) = %v, expected %v", tt.target, tt.current, got, tt.expected)
+		}
+	}
+}
+
+func TestBandPolicy(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	// VTI is 5 points over its target, 7.6% of it, and BND 5 points under,
+	// 31% of it
+	config.Stocks[0].TargetPercentage = 66
+	config.Stocks[2].TargetPercentage = 16
+	holdings := loadHoldings(t, config, "cash.csv")
+	tests := []struct {
+		policy   string
+		expected []string
+	}{
+		{"", []string{"VTI", "BND"}},
+		{"absolute", nil},
+		{"5/25", []string{"BND"}},
+	}
+	for _, tt := range tests {
+		config.BandPolicy = tt.policy
+		if err := config.validate(); err != nil {
+			t.Fatalf("validate failed for %q: %v", tt.policy, err)
+		}
+		result, err := allocationCalc(config, holdings, 0)
+		if err != nil {
+			t.Fatalf("allocationCalc failed: %v", err)
+		}
+		var flagged []string
+		for _, stock := range config.Stocks {
+			if result.Symbols[stock.Symbol].Actionable {
+				flagged = append(flagged, stock.Symbol)
+			}
+		}
+		if !slices.Equal(flagged, tt.expected) {
+			t.Errorf("Policy %q flagged %v, expected %v", tt.policy, flagged, tt.expected)
+		}
+	}
+
+	config.BandPolicy = "10/20"
+	if err := config.validate(); err == nil {
+		t.Error("Expected an error for an unknown band policy")
+	}
+}
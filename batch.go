@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BatchManifest lists the portfolios batch rebalances, each under its own
+// config. Paths are relative to the manifest.
+type BatchManifest struct {
+	Runs []BatchEntry `yaml:"runs"`
+}
+
+type BatchEntry struct {
+	// Name labels the run in the summary; it defaults to the portfolio's
+	// file name
+	Name      string `yaml:"name,omitempty"`
+	Config    string `yaml:"config"`
+	Portfolio string `yaml:"portfolio"`
+	// Output is a file to write the run's rebalance report to, as JSON when
+	// it ends in .json or Format is json
+	Output string `yaml:"output,omitempty"`
+	Format string `yaml:"format,omitempty"`
+}
+
+// BatchRun is the summary of one manifest entry
+type BatchRun struct {
+	Name      string `json:"name"`
+	Config    string `json:"config"`
+	Portfolio string `json:"portfolio"`
+	Output    string `json:"output,omitempty"`
+	Total     int    `json:"total"`
+	// Symbol is the one furthest from its target, by Drift
+	Symbol  string  `json:"symbol,omitempty"`
+	Drift   float64 `json:"drift"`
+	Buys    int     `json:"buys"`
+	Sells   int     `json:"sells"`
+	Urgency Urgency `json:"urgency"`
+	// Status is ok or failed, with Error saying why; Seconds is how long the
+	// run took
+	Status  string  `json:"status"`
+	Error   string  `json:"error,omitempty"`
+	Seconds float64 `json:"seconds"`
+	// Warnings are what reading the portfolio found
+	Warnings []Warning `json:"warnings,omitempty"`
+
+	config   *Config
+	holdings *Holdings
+	result   *RebalanceResult
+	format   string
+}
+
+// batch rebalances every portfolio in a manifest, writing each report to its
+// output, and prints a summary of them all. Up to parallel portfolios are
+// read and rebalanced at once, since quotes and plugins may be slow; their
+// reports are then written one at a time in manifest order, as writing may
+// ask for confirmation. A run that fails is reported in the summary and the
+// rest still run.
+func batch(args []string) int {
+	var format string
+	var parallel int
+	flagSet := flag.NewFlagSet("batch", flag.ExitOnError)
+	flagSet.StringVar(&format, "format", "text", "Summary format: text or json")
+	flagSet.IntVar(&parallel, "parallel", fetchWorkers, "How many portfolios to rebalance at once")
+	if len(args) < 1 {
+		flag.Usage()
+		return 1
+	}
+	manifestPath := args[0]
+	flagSet.Parse(args[1:])
+	if format != "text" && format != "json" {
+		printError(codedErrorf(CodeUsage, "unknown format %q", format))
+		return 1
+	}
+	if parallel < 1 {
+		printError(codedErrorf(CodeUsage, "-parallel must be at least 1"))
+		return 1
+	}
+	manifest, err := readBatchManifest(manifestPath)
+	if err != nil {
+		printError(err)
+		return 1
+	}
+
+	runs := batchRuns(filepath.Dir(manifestPath), manifest.Runs, parallel, isTerminal(os.Stderr))
+	failed := false
+	for i := range runs {
+		writeBatchReport(&runs[i])
+		if runs[i].Error != "" {
+			failed = true
+		}
+	}
+
+	if format == "json" {
+		if err := writeJSON(os.Stdout, runs); err != nil {
+			printError(err)
+			return 1
+		}
+	} else {
+		printBatchSummary(runs)
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+func readBatchManifest(path string) (*BatchManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var manifest BatchManifest
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&manifest); err != nil {
+		return nil, codedErrorf(CodeConfigInvalid, "parsing manifest %s: %w", path, err)
+	}
+	if len(manifest.Runs) == 0 {
+		return nil, codedErrorf(CodeConfigInvalid, "manifest %s has no runs", path)
+	}
+	for i, entry := range manifest.Runs {
+		if entry.Config == "" || entry.Portfolio == "" {
+			return nil, codedErrorf(CodeConfigInvalid, "run %d of manifest %s needs a config and a portfolio", i+1, path)
+		}
+		if entry.Format != "" && entry.Format != "text" && entry.Format != "json" {
+			return nil, codedErrorf(CodeConfigInvalid, "run %d of manifest %s has unknown format %q", i+1, path, entry.Format)
+		}
+	}
+	return &manifest, nil
+}
+
+// batchRuns rebalances the entries with up to parallel at a time, in the
+// manner of fetchAll, listing each on stderr as it finishes when progress is
+// set. Every run stands alone, so one that fails leaves the others be.
+func batchRuns(dir string, entries []BatchEntry, parallel int, progress bool) []BatchRun {
+	runs := make([]BatchRun, len(entries))
+	var mu sync.Mutex
+	finished := 0
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for range min(parallel, len(entries)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				runs[i] = batchRun(dir, entries[i])
+				if progress {
+					mu.Lock()
+					finished++
+					fmt.Fprintf(os.Stderr, "[%d/%d] %s: %s (%.1fs)\n", finished, len(entries), runs[i].Name, runs[i].Status, runs[i].Seconds)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range entries {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+	return runs
+}
+
+// batchRun rebalances one entry's portfolio, relative paths taken from dir.
+// Anything that goes wrong, even a panic, fails only this run.
+func batchRun(dir string, entry BatchEntry) (run BatchRun) {
+	resolve := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(dir, path)
+	}
+	run = BatchRun{Name: entry.Name, Config: resolve(entry.Config), Portfolio: resolve(entry.Portfolio), Output: resolve(entry.Output), format: entry.Format}
+	if run.Name == "" {
+		run.Name = strings.TrimSuffix(filepath.Base(entry.Portfolio), filepath.Ext(entry.Portfolio))
+	}
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			run.Error = fmt.Sprintf("internal error: %v", r)
+		}
+		run.Status = "ok"
+		if run.Error != "" {
+			run.Status = "failed"
+		}
+		run.Seconds = time.Since(start).Seconds()
+	}()
+
+	config, err := parseConfig(run.Config)
+	if err != nil {
+		run.Error = fmt.Sprintf("parsing config: %v", err)
+		return run
+	}
+	holdings, err := readPortfolio(config, run.Portfolio)
+	if err != nil {
+		run.Error = err.Error()
+		return run
+	}
+	result, err := allocationCalc(config, holdings, 0)
+	if err != nil {
+		run.Error = err.Error()
+		return run
+	}
+	if err := addTradeWarnings(config, result); err != nil {
+		run.Error = err.Error()
+		return run
+	}
+	run.config, run.holdings, run.result = config, holdings, result
+	run.Total, run.Urgency, run.Warnings = result.Total, result.Urgency, result.Warnings
+	for _, stock := range config.Stocks {
+		data := result.Symbols[stock.Symbol]
+		if run.Symbol == "" || math.Abs(data.Drift) > math.Abs(run.Drift) {
+			run.Symbol, run.Drift = stock.Symbol, data.Drift
+		}
+		if data.AmountNeeded > 0 {
+			run.Buys += data.AmountNeeded
+		} else {
+			run.Sells -= data.AmountNeeded
+		}
+	}
+	return run
+}
+
+// writeBatchReport writes a successful run's rebalance report to its output,
+// failing the run if it can't
+func writeBatchReport(run *BatchRun) {
+	if run.Output == "" || run.result == nil {
+		return
+	}
+	format := run.format
+	if format == "" {
+		format = "text"
+		if strings.EqualFold(filepath.Ext(run.Output), ".json") {
+			format = "json"
+		}
+	}
+	write := func(w io.Writer) error { return batchReport(run.config, run.holdings, run.result, format, w) }
+	if err := writeOutput(run.Output, write); err != nil {
+		run.Error = fmt.Sprintf("writing %s: %v", run.Output, err)
+		run.Status = "failed"
+	}
+}
+
+// batchReport writes the report rebalance prints for result to w
+func batchReport(config *Config, holdings *Holdings, result *RebalanceResult, format string, w io.Writer) error {
+	report, err := os.CreateTemp("", "fin-tilt-batch-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(report.Name())
+	defer report.Close()
+	stdout := os.Stdout
+	os.Stdout = report
+	printRebalance(config, holdings, result, format)
+	os.Stdout = stdout
+	if _, err := report.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err = io.Copy(w, report)
+	return err
+}
+
+func printBatchSummary(runs []BatchRun) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Name\tStatus\tTotal\tLargest drift\tBuys\tSells\tUrgency\tReport")
+	for _, run := range runs {
+		if run.Error != "" {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", run.Name, red(run.Status), red("Error: "+run.Error))
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s %s\t%s\t%s\t%s\t%s\n", run.Name, run.Status, formatAmount(run.Total, true), run.Symbol, driftText(run.Drift), formatAmount(run.Buys, true), formatAmount(run.Sells, true), run.Urgency.Level, run.Output)
+	}
+	w.Flush()
+	for _, run := range runs {
+		for _, warning := range run.Warnings {
+			fmt.Println(red(fmt.Sprintf("%s: Warning: %s", run.Name, warning.Message)))
+		}
+	}
+}
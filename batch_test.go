@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	dir := t.TempDir()
+	configPath, _ := filepath.Abs(filepath.Join("tests", "configs", "simple.yaml"))
+	portfolios, _ := filepath.Abs(filepath.Join("tests", "portfolios"))
+	manifest := `runs:
+  - name: Alex
+    config: ` + configPath + `
+    portfolio: ` + filepath.Join(portfolios, "unbalanced.csv") + `
+    output: reports/alex.json
+  - config: ` + configPath + `
+    portfolio: ` + filepath.Join(portfolios, "cash.csv") + `
+  - config: missing.yaml
+    portfolio: cash.csv
+`
+	manifestPath := filepath.Join(dir, "manifest.yaml")
+	if err := os.WriteFile(manifestPath, []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "reports"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	m, err := readBatchManifest(manifestPath)
+	if err != nil {
+		t.Fatalf("readBatchManifest failed: %v", err)
+	}
+
+	// The runs go two at a time but come back in manifest order, the broken
+	// one failing alone
+	runs := batchRuns(dir, m.Runs, 2, false)
+	for i := range runs {
+		writeBatchReport(&runs[i])
+	}
+	if len(runs) != 3 {
+		t.Fatalf("Expected a run per entry, got %+v", runs)
+	}
+	alex := runs[0]
+	if alex.Status != "ok" || alex.Name != "Alex" || alex.Symbol != "VTI" || alex.Drift != 9 || alex.Buys != 900000 || alex.Sells != 900000 {
+		t.Errorf("Alex's run mismatch: %+v", alex)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "reports", "alex.json"))
+	if err != nil {
+		t.Fatalf("Expected Alex's report written: %v", err)
+	}
+	var result RebalanceResult
+	if err := json.Unmarshal(data, &result); err != nil || result.Symbols["VTI"].AmountNeeded != -900000 {
+		t.Errorf("Expected Alex's rebalance as JSON, got %s (%v)", data, err)
+	}
+
+	// The name defaults to the portfolio's, and without an output nothing
+	// is written
+	if cash := runs[1]; cash.Status != "ok" || cash.Name != "cash" || cash.Buys != 0 || cash.Output != "" {
+		t.Errorf("Cash run mismatch: %+v", cash)
+	}
+	// A run that fails reports why, with paths relative to the manifest
+	if broken := runs[2]; broken.Status != "failed" || !strings.Contains(broken.Error, filepath.Join(dir, "missing.yaml")) {
+		t.Errorf("Expected the missing config in the error, got %+v", broken)
+	}
+
+	// A report that can't be written fails its run
+	alex.Output = filepath.Join(dir, "missing", "alex.json")
+	if writeBatchReport(&alex); alex.Status != "failed" || !strings.Contains(alex.Error, "writing") {
+		t.Errorf("Expected the unwritable report to fail the run, got %+v", alex)
+	}
+
+	if err := os.WriteFile(manifestPath, []byte("runs:\n  - config: simple.yaml\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readBatchManifest(manifestPath); err == nil {
+		t.Error("Expected an error for a run without a portfolio")
+	}
+}
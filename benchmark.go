@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// BenchmarkConfig is a portfolio to compare against, such as 60/40 through
+// VBIAX or a blend of index funds
+type BenchmarkConfig struct {
+	// Name labels the benchmark in reports (default: its symbols)
+	Name string `yaml:"name,omitempty"`
+	// Composition is the percentage held in each symbol, adding up to 100
+	Composition map[string]float64 `yaml:"composition"`
+}
+
+// BenchmarkWeight compares the portfolio's weight in a configured symbol to
+// the benchmark's. Benchmark symbols that aren't configured are grouped under
+// "Other".
+type BenchmarkWeight struct {
+	Symbol    string  `json:"symbol"`
+	Current   float64 `json:"current"`
+	Benchmark float64 `json:"benchmark"`
+}
+
+type BenchmarkReturn struct {
+	Name  string `json:"name"`
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Return is the cumulative return of the blend, rebalanced monthly
+	Return float64 `json:"return"`
+}
+
+func validateBenchmark(config *Config) error {
+	if config.Benchmark == nil {
+		return nil
+	}
+	if len(config.Benchmark.Composition) == 0 {
+		return fmt.Errorf("benchmark needs a composition")
+	}
+	total := 0.0
+	for symbol, percentage := range config.Benchmark.Composition {
+		if percentage <= 0 {
+			return fmt.Errorf("benchmark percentage for %s must be positive", symbol)
+		}
+		total += percentage
+	}
+	if math.Abs(total-100) > 1e-9 {
+		return codedErrorf(CodeConfigSum, "benchmark composition adds up to %g, not 100", total)
+	}
+	return nil
+}
+
+func (b *BenchmarkConfig) symbols() []string {
+	return slices.Sorted(maps.Keys(b.Composition))
+}
+
+func (b *BenchmarkConfig) label() string {
+	if b.Name != "" {
+		return b.Name
+	}
+	return strings.Join(b.symbols(), "/")
+}
+
+// benchmarkWeights compares current weights to the benchmark's, counting a
+// benchmark symbol toward the configured stock it is a primary or alternative
+// symbol of
+func benchmarkWeights(config *Config, holdings *Holdings) []BenchmarkWeight {
+	total := 0
+	for _, stock := range config.Stocks {
+		total += holdings.Amounts[stock.Symbol]
+	}
+	other := 0.0
+	benchmark := make(map[string]float64)
+	for symbol, percentage := range config.Benchmark.Composition {
+		index := slices.IndexFunc(config.Stocks, func(stock Stock) bool {
+			return stock.Symbol == symbol || slices.Contains(stock.Alternatives, symbol)
+		})
+		if index < 0 {
+			other += percentage
+			continue
+		}
+		benchmark[config.Stocks[index].Symbol] += percentage
+	}
+
+	var weights []BenchmarkWeight
+	for _, stock := range config.Stocks {
+		weight := BenchmarkWeight{Symbol: stock.Symbol, Benchmark: benchmark[stock.Symbol]}
+		if total > 0 {
+			weight.Current = float64(holdings.Amounts[stock.Symbol]) / float64(total) * 100
+		}
+		weights = append(weights, weight)
+	}
+	if other > 0 {
+		weights = append(weights, BenchmarkWeight{Symbol: "Other", Benchmark: other})
+	}
+	return weights
+}
+
+func printBenchmarkWeights(config *Config, holdings *Holdings) {
+	if config.Benchmark == nil {
+		return
+	}
+	fmt.Println("\n" + rule())
+	fmt.Printf("Versus benchmark %s\n", config.Benchmark.label())
+	fmt.Println(rule())
+	for _, weight := range benchmarkWeights(config, holdings) {
+		fmt.Printf("%s - %.2f%% vs %.2f%% (%s)\n", weight.Symbol, weight.Current, weight.Benchmark, driftText(weight.Current-weight.Benchmark))
+	}
+}
+
+// benchmarkReturnCalc measures the benchmark, rebalanced monthly, from the
+// month-end close of start's month to that of end's month
+func benchmarkReturnCalc(benchmark *BenchmarkConfig, history PriceHistory, start, end time.Time) (*BenchmarkReturn, error) {
+	result := &BenchmarkReturn{Name: benchmark.label(), Start: start.Format("2006-01"), End: end.Format("2006-01")}
+	if result.Start >= result.End {
+		return nil, fmt.Errorf("benchmark returns need valuations in different months")
+	}
+	returns := make(map[string]map[string]float64)
+	for _, symbol := range benchmark.symbols() {
+		closes, ok := history[symbol]
+		if !ok {
+			return nil, fmt.Errorf("no price history for %s", symbol)
+		}
+		returns[symbol] = monthlyReturns(closes)
+	}
+
+	growth := 1.0
+	for month := time.Date(start.Year(), start.Month()+1, 1, 0, 0, 0, 0, time.UTC); month.Format("2006-01") <= result.End; month = month.AddDate(0, 1, 0) {
+		key := month.Format("2006-01")
+		monthly := 0.0
+		for symbol, percentage := range benchmark.Composition {
+			r, ok := returns[symbol][key]
+			if !ok {
+				return nil, fmt.Errorf("no price history for %s in %s", symbol, key)
+			}
+			monthly += percentage / 100 * r
+		}
+		growth *= 1 + monthly
+	}
+	result.Return = growth - 1
+	return result, nil
+}
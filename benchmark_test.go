@@ -0,0 +1,69 @@
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBenchmarkWeights(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	config.Benchmark = &BenchmarkConfig{Composition: map[string]float64{"VTI": 60, "VBIAX": 40}}
+	if err := config.validate(); err != nil {
+		t.Fatalf("validate failed: %v", err)
+	}
+	holdings := loadHoldings(t, config, "balanced.csv")
+
+	expected := []BenchmarkWeight{
+		{Symbol: "VTI", Current: 71, Benchmark: 60},
+		{Symbol: "VXUS", Current: 18, Benchmark: 0},
+		{Symbol: "BND", Current: 11, Benchmark: 0},
+		{Symbol: "Other", Current: 0, Benchmark: 40},
+	}
+	weights := benchmarkWeights(config, holdings)
+	if len(weights) != len(expected) {
+		t.Fatalf("Weight count mismatch: got %+v", weights)
+	}
+	for i, e := range expected {
+		if weights[i].Symbol != e.Symbol || math.Abs(weights[i].Current-e.Current) > 1e-9 || weights[i].Benchmark != e.Benchmark {
+			t.Errorf("Weight %d mismatch: got %+v, expected %+v", i, weights[i], e)
+		}
+	}
+	if label := config.Benchmark.label(); label != "VBIAX/VTI" {
+		t.Errorf("Label mismatch: got %q", label)
+	}
+
+	config.Benchmark.Composition["VTI"] = 50
+	if err := config.validate(); errorCode(err) != CodeConfigSum {
+		t.Errorf("Expected %s for a composition adding up to 90, got %v", CodeConfigSum, err)
+	}
+}
+
+func TestBenchmarkReturnCalc(t *testing.T) {
+	benchmark := &BenchmarkConfig{Name: "60/40", Composition: map[string]float64{"VTI": 60, "BND": 40}}
+	history := PriceHistory{
+		"VTI": {"2026-01": 100, "2026-02": 110, "2026-03": 99},
+		"BND": {"2026-01": 50, "2026-02": 50, "2026-03": 51},
+	}
+	start := time.Date(2026, time.January, 31, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2026, time.March, 15, 0, 0, 0, 0, time.UTC)
+
+	result, err := benchmarkReturnCalc(benchmark, history, start, end)
+	if err != nil {
+		t.Fatalf("benchmarkReturnCalc failed: %v", err)
+	}
+	// February: 0.6*10% = 6%; March: 0.6*-10% + 0.4*2% = -5.2%
+	expected := 1.06*0.948 - 1
+	if result.Start != "2026-01" || result.End != "2026-03" || math.Abs(result.Return-expected) > 1e-9 {
+		t.Errorf("Result mismatch: got %+v, expected a return of %v", result, expected)
+	}
+
+	delete(history["BND"], "2026-03")
+	if _, err := benchmarkReturnCalc(benchmark, history, start, end); err == nil {
+		t.Error("Expected an error for a missing month")
+	}
+}
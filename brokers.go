@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// holdingsColumns are the names brokers give each column readHoldings reads,
+// in order of preference. Headers are matched after normalizeHoldingsHeader,
+// so "Value $" (E*TRADE) matches "Value".
+var holdingsColumns = map[string][]string{
+	// Ticker is M1 Finance's and Fund is Betterment's
+	"Symbol": {"Symbol", "Ticker", "Fund"},
+	// Equity is Robinhood's
+	"Current Value":    {"Current Value", "Value", "Market Value", "Equity"},
+	"Last Price":       {"Last Price", "Price", "Current Price"},
+	"Quantity":         {"Quantity", "Shares"},
+	"Cost Basis Total": {"Cost Basis Total", "Cost Basis"},
+	// Price Paid is the cost basis per share, used when there is no total
+	"Price Paid":     {"Price Paid", "Avg. Price", "Average Cost"},
+	"Account Number": {"Account Number"},
+	// Account is Empower's, which names the institution and account together
+	"Account Name": {"Account Name", "Account"},
+	// Asset Class is the basket a robo-advisor holds a fund for
+	"Asset Class": {"Asset Class"},
+	"Description": {"Description", "Security Description"},
+	// Available Cash is what an account summary says can be spent on
+	// purchases; Cash Purchasing Power is E*TRADE's
+	"Available Cash": {"Available Cash", "Cash Available to Trade", "Cash Purchasing Power"},
+}
+
+// holdingsColumn finds the index of a column in a normalized header, or -1.
+// Case is ignored, since aggregators such as Empower export "account".
+func holdingsColumn(header []string, column string) int {
+	for _, name := range holdingsColumns[column] {
+		if i := slices.IndexFunc(header, func(h string) bool { return strings.EqualFold(h, name) }); i >= 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// normalizeHoldingsHeader trims headers and drops the unit suffixes some
+// brokers add, as in "Last Price $" or "Value ($)"
+func normalizeHoldingsHeader(record []string) []string {
+	header := normalizeHeader(record)
+	for i, name := range header {
+		name = strings.TrimSuffix(name, "($)")
+		header[i] = strings.TrimSpace(strings.TrimSuffix(name, " $"))
+	}
+	return header
+}
+
+// summaryAccount returns the account that record names when previous is the
+// header of an account summary. E*TRADE's Portfolio Download puts one above
+// the positions of each account, which have no account column of their own.
+func summaryAccount(previous, record []string) (string, bool) {
+	header := normalizeHoldingsHeader(previous)
+	if len(header) < 2 || header[0] != "Account" || !slices.Contains(header, "Net Account Value") {
+		return "", false
+	}
+	account := strings.TrimSpace(field(record, 0))
+	return account, account != ""
+}
+
+// summaryAvailableCash returns the cash that record, the row of an account
+// summary, says can be spent on purchases without selling
+func summaryAvailableCash(previous, record []string) (int, bool, error) {
+	if _, found := summaryAccount(previous, record); !found {
+		return 0, false, nil
+	}
+	value := field(record, holdingsColumn(normalizeHoldingsHeader(previous), "Available Cash"))
+	if value == "" {
+		return 0, false, nil
+	}
+	cash, err := amountToInt(value)
+	if err != nil {
+		return 0, false, codedErrorf(CodeCSVValue, "error parsing available cash: %w", err)
+	}
+	return cash, true, nil
+}
+
+func validateAssetClasses(config *Config) error {
+	owners := make(map[string]string)
+	for _, stock := range config.Stocks {
+		for _, class := range stock.AssetClasses {
+			key := strings.ToLower(strings.TrimSpace(class))
+			if key == "" {
+				return fmt.Errorf("asset class of %s must not be empty", stock.Symbol)
+			}
+			if owner, exists := owners[key]; exists {
+				return fmt.Errorf("asset class %q appears multiple times (for %s and %s)", class, owner, stock.Symbol)
+			}
+			owners[key] = stock.Symbol
+		}
+	}
+	return nil
+}
+
+// assetClassStocks maps each configured asset class, lowercased, to the
+// stock its funds count as. Robo-advisors swap the funds in a basket (for tax
+// loss harvesting, say), so the class is a steadier key than the symbol.
+func assetClassStocks(config *Config) map[string]string {
+	stocks := make(map[string]string)
+	for _, stock := range config.Stocks {
+		for _, class := range stock.AssetClasses {
+			stocks[strings.ToLower(strings.TrimSpace(class))] = stock.Symbol
+		}
+	}
+	return stocks
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadHoldingsETrade(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	config.Accounts = []Account{{Name: "Roth IRA -5678"}}
+	holdings := loadHoldings(t, config, "etrade.csv")
+
+	expected := map[string]int{"VTI": 7100000, "VXUS": 1800000, "BND": 1100000}
+	for symbol, amount := range expected {
+		if holdings.Amounts[symbol] != amount {
+			t.Errorf("%s: got %d, expected %d", symbol, holdings.Amounts[symbol], amount)
+		}
+	}
+	// Positions belong to the account summary above them; the summary and
+	// TOTAL rows aren't holdings
+	if got := holdings.AccountTotals; len(got) != 2 || got["Individual Brokerage -1234"] != 7600000 || got["Roth IRA -5678"] != 2900000 {
+		t.Errorf("Account totals mismatch: %v", got)
+	}
+	if got := holdings.Cash["Individual Brokerage -1234"]; got != 500000 {
+		t.Errorf("Cash mismatch: got %d, expected 500000", got)
+	}
+	if got := holdings.AmountsByAccount["BND"]["Roth IRA -5678"]; got != 1100000 {
+		t.Errorf("Configured account mismatch: got %d, expected 1100000", got)
+	}
+	// Price Paid is per share: 284 shares at $211.27
+	if got := holdings.CostBasis["VTI"]; got != 6000068 {
+		t.Errorf("Cost basis mismatch: got %d, expected 6000068", got)
+	}
+	if holdings.Prices["VXUS"] != 6000 {
+		t.Errorf("Price mismatch: got %d, expected 6000", holdings.Prices["VXUS"])
+	}
+	if expected := time.Date(2026, time.October, 1, 0, 0, 0, 0, time.Local); !holdings.AsOf.Equal(expected) || holdings.AsOfSource != asOfExport {
+		t.Errorf("As of mismatch: got %s (%s)", holdings.AsOf, holdings.AsOfSource)
+	}
+}
+
+func TestNormalizeHoldingsHeader(t *testing.T) {
+	header := normalizeHoldingsHeader([]string{"\ufeffSymbol", "Last Price $", "Value ($)", "Change %"})
+	for i, expected := range []string{"Symbol", "Last Price", "Value", "Change %"} {
+		if header[i] != expected {
+			t.Errorf("Column %d: got %q, expected %q", i, header[i], expected)
+		}
+	}
+	if holdingsColumn(header, "Current Value") != 2 {
+		t.Errorf("Expected Value to be read as the current value")
+	}
+	if holdingsColumn([]string{"ticker", "account", "value"}, "Account Name") != 1 {
+		t.Errorf("Expected columns to match regardless of case")
+	}
+}
+
+func TestReadHoldingsNonstandardColumns(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	config.Stocks = append(config.Stocks, Stock{Symbol: "BTC"})
+	tests := []struct {
+		file      string
+		vti       int
+		costBasis int
+		price     int
+	}{
+		// M1 Finance: Ticker, Shares, Cost Basis, and Value, with no price
+		{"m1.csv", 7103086, 6002650, 0},
+		// Robinhood: Equity and a per-share Average Cost
+		{"robinhood.csv", 7100000, 6000068, 25000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			holdings := loadHoldings(t, config, tt.file)
+			if got := holdings.Amounts["VTI"]; got != tt.vti {
+				t.Errorf("VTI amount: got %d, expected %d", got, tt.vti)
+			}
+			if got := holdings.CostBasis["VTI"]; got != tt.costBasis {
+				t.Errorf("VTI cost basis: got %d, expected %d", got, tt.costBasis)
+			}
+			if got := holdings.Prices["VTI"]; got != tt.price {
+				t.Errorf("VTI price: got %d, expected %d", got, tt.price)
+			}
+			// Crypto rows hold fractions of a coin and read like any other
+			if got := holdings.Amounts["BTC"]; got != 74074 {
+				t.Errorf("BTC amount: got %d, expected 74074", got)
+			}
+		})
+	}
+}
+
+func TestReadHoldingsEmpower(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	config.Accounts = []Account{{Name: "Vanguard - Roth IRA - 5678"}}
+	holdings := loadHoldings(t, config, "empower.csv")
+
+	// Holdings of one symbol at several institutions add up
+	if got := holdings.Amounts["VTI"]; got != 7100000 {
+		t.Errorf("VTI amount: got %d, expected 7100000", got)
+	}
+	if got := holdings.AccountTotals; len(got) != 2 || got["Fidelity - Individual - 1234"] != 5000000 || got["Vanguard - Roth IRA - 5678"] != 5050000 {
+		t.Errorf("Account totals mismatch: %v", got)
+	}
+	if got := holdings.AmountsByAccount["VTI"]["Vanguard - Roth IRA - 5678"]; got != 2100000 {
+		t.Errorf("Configured account mismatch: got %d, expected 2100000", got)
+	}
+	if got := holdings.Cash["Vanguard - Roth IRA - 5678"]; got != 50000 {
+		t.Errorf("Cash mismatch: got %d, expected 50000", got)
+	}
+}
+
+func TestReadHoldingsRoboAssetClasses(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "robo.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	tests := []struct {
+		file string
+		want map[string]int
+		cash int
+	}{
+		// Betterment's SCHB is VTI's tax loss harvesting partner in the same class
+		{"betterment.csv", map[string]int{"VTI": 3000000, "VXUS": 725000, "BND": 300000}, 75000},
+		// Wealthfront's Dividend Stocks class isn't configured and is ignored
+		{"wealthfront.csv", map[string]int{"VTI": 1000000, "VXUS": 400000, "BND": 150000}, 10000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			holdings := loadHoldings(t, config, tt.file)
+			for symbol, amount := range tt.want {
+				if got := holdings.Amounts[symbol]; got != amount {
+					t.Errorf("%s: got %d, expected %d", symbol, got, amount)
+				}
+			}
+			cash := 0
+			for _, amount := range holdings.Cash {
+				cash += amount
+			}
+			if cash != tt.cash {
+				t.Errorf("Cash mismatch: got %d, expected %d", cash, tt.cash)
+			}
+		})
+	}
+}
+
+func TestValidateAssetClasses(t *testing.T) {
+	config := &Config{Stocks: []Stock{
+		{Symbol: "VTI", AssetClasses: []string{"US Stocks"}},
+		{Symbol: "VXUS", AssetClasses: []string{"us stocks"}},
+	}}
+	if err := validateAssetClasses(config); err == nil {
+		t.Errorf("Expected an error for an asset class of two stocks")
+	}
+	config.Stocks[1].AssetClasses = []string{"Foreign Stocks"}
+	if err := validateAssetClasses(config); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
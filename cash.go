@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// defaultCashSymbols are the rows brokers use for sweep funds, core
+// positions, and pending deposits. Fidelity also marks its core position by
+// appending "**" to the fund symbol (e.g. SPAXX**).
+var defaultCashSymbols = []string{"Pending Activity", "CASH", "Cash", "Cash & Cash Investments", "FCASH", "CORE"}
+
+// cashDescriptions mark the cash rows of exports that give the core position
+// or sweep fund a description but no symbol, or a symbol of its own
+var cashDescriptions = []string{"Core Position", "Held in Money Market", "Cash", "Sweep"}
+
+const defaultExpectedReturn = 6.0
+
+type CashConfig struct {
+	// Symbols are CSV symbols counted as cash in addition to the defaults
+	Symbols []string `yaml:"symbols,omitempty"`
+	// ExpectedReturn is the annual return, in percent, expected from the
+	// target allocation (default 6)
+	ExpectedReturn *float64 `yaml:"expected_return,omitempty"`
+	// CashYield is the annual yield, in percent, earned on cash (default 0)
+	CashYield float64 `yaml:"cash_yield,omitempty"`
+}
+
+// CashDrag describes the uninvested cash in one account
+type CashDrag struct {
+	Account string `json:"account"`
+	Amount  int    `json:"amount"`
+	// Idle is the least cash held in the account across the consecutive
+	// snapshots leading up to now, and IdleSince the first of them
+	Idle      int       `json:"idle"`
+	IdleSince time.Time `json:"idle_since"`
+	// AnnualDrag is the return given up per year by the current cash, and
+	// AccruedDrag the return already given up by the idle cash
+	AnnualDrag  int `json:"annual_drag"`
+	AccruedDrag int `json:"accrued_drag"`
+}
+
+// isCash reports whether a CSV symbol that isn't a configured stock holds cash
+func (c *Config) isCash(symbol string) bool {
+	if strings.HasSuffix(symbol, "**") || slices.Contains(defaultCashSymbols, symbol) {
+		return true
+	}
+	return c.Cash != nil && slices.Contains(c.Cash.Symbols, symbol)
+}
+
+// isCashDescription reports whether a row's description marks it as cash
+func isCashDescription(description string) bool {
+	return slices.ContainsFunc(cashDescriptions, func(d string) bool { return strings.EqualFold(d, strings.TrimSpace(description)) })
+}
+
+// expectedReturn is the annual return, in percent, expected from the target
+// allocation
+func (c *CashConfig) expectedReturn() float64 {
+	if c != nil && c.ExpectedReturn != nil {
+		return *c.ExpectedReturn
+	}
+	return defaultExpectedReturn
+}
+
+// dragRate is the annual return, in percent, that cash gives up by not
+// following the target allocation
+func (c *CashConfig) dragRate() float64 {
+	if c == nil {
+		return c.expectedReturn()
+	}
+	return c.expectedReturn() - c.CashYield
+}
+
+// cashDragCalc estimates the drag of each account's cash as of asOf. The
+// snapshots, oldest first, show how long the cash has been sitting.
+func cashDragCalc(config *Config, holdings *Holdings, snapshots []Snapshot, asOf time.Time) []CashDrag {
+	rate := config.Cash.dragRate() / 100
+	var drags []CashDrag
+	for account, amount := range holdings.Cash {
+		// An HSA's required minimum can't be invested, so it isn't drag
+		if configured := config.account(account, account); configured != nil && configured.Type == "hsa" {
+			amount -= int(configured.CashMinimum)
+		}
+		if amount <= 0 {
+			continue
+		}
+		drag := CashDrag{Account: account, Amount: amount, Idle: amount, IdleSince: asOf}
+		for i := len(snapshots) - 1; i >= 0; i-- {
+			if !snapshots[i].AsOf.Before(asOf) {
+				continue
+			}
+			cash := snapshots[i].Cash[account]
+			if cash <= 0 {
+				break
+			}
+			drag.Idle = min(drag.Idle, cash)
+			drag.IdleSince = snapshots[i].AsOf
+		}
+		days := asOf.Sub(drag.IdleSince).Hours() / 24
+		drag.AnnualDrag = int(float64(amount) * rate)
+		drag.AccruedDrag = int(float64(drag.Idle) * rate * days / 365)
+		drags = append(drags, drag)
+	}
+	slices.SortFunc(drags, func(a, b CashDrag) int { return strings.Compare(a.Account, b.Account) })
+	return drags
+}
+
+// printCashDrag reports uninvested cash, loading snapshots when configured
+func printCashDrag(config *Config, holdings *Holdings) {
+	if len(holdings.Cash) == 0 {
+		return
+	}
+	var snapshots []Snapshot
+	if config.Snapshots != "" {
+		var err error
+		if snapshots, err = readSnapshots(config.Snapshots); err != nil {
+			printError(fmt.Errorf("reading snapshots: %w", err))
+		}
+	}
+	asOf := holdings.AsOf
+	if asOf.IsZero() {
+		asOf = time.Now()
+	}
+	drags := cashDragCalc(config, holdings, snapshots, asOf)
+	if len(drags) == 0 {
+		return
+	}
+
+	fmt.Println("\n" + rule())
+	fmt.Printf("Uninvested cash (drag at %.2f%%/year versus target)\n", config.Cash.dragRate())
+	fmt.Println(rule())
+	for _, drag := range drags {
+		account := drag.Account
+		if account == "" {
+			account = "Portfolio"
+		}
+		fmt.Printf("%s: %s, costing about %s/year\n", account, formatAmount(drag.Amount, true), formatAmount(drag.AnnualDrag, true))
+		if drag.IdleSince.Before(asOf) {
+			days := int(asOf.Sub(drag.IdleSince).Hours() / 24)
+			fmt.Printf("  At least %s idle since %s (%d days), about %s given up so far\n", formatAmount(drag.Idle, true), drag.IdleSince.Format(time.DateOnly), days, formatAmount(drag.AccruedDrag, true))
+		}
+	}
+}
+
+// CashCheck compares the purchases a plan makes in one account with the cash
+// there to pay for them: what the account can spend and its sales' proceeds
+type CashCheck struct {
+	Account   string `json:"account"`
+	Available int    `json:"available"`
+	Purchases int    `json:"purchases"`
+	Sales     int    `json:"sales"`
+	// Shortfall is how much more the purchases need than there is
+	Shortfall int `json:"shortfall,omitempty"`
+}
+
+// availableCash is what a configured account can spend on purchases: its
+// summary's figure when the export gives one, or else its cash rows, less an
+// HSA's minimum
+func (h *Holdings) availableCash(config *Config, account *Account) int {
+	sum := func(amounts map[string]int) (int, bool) {
+		total, found := 0, false
+		for key, amount := range amounts {
+			if config.account(key, key) == account {
+				total, found = total+amount, true
+			}
+		}
+		return total, found
+	}
+	cash, found := sum(h.AvailableCash)
+	if !found {
+		cash, _ = sum(h.Cash)
+	}
+	return max(cash-int(account.CashMinimum), 0)
+}
+
+// cashCheckCalc checks each configured account that the plan buys in. A
+// deposit isn't counted, since the plan doesn't say which account it goes to.
+func cashCheckCalc(config *Config, holdings *Holdings, symbols map[string]SymbolData) []CashCheck {
+	checks := make(map[string]*CashCheck)
+	for _, stock := range config.Stocks {
+		data := symbols[stock.Symbol]
+		account := config.account(data.Account, "")
+		if account == nil || data.AmountNeeded == 0 {
+			continue
+		}
+		if checks[account.Name] == nil {
+			checks[account.Name] = &CashCheck{Account: account.Name, Available: holdings.availableCash(config, account)}
+		}
+		amount := data.AmountNeeded
+		if data.WholeShares {
+			amount -= data.ResidualCash
+		}
+		if amount > 0 {
+			checks[account.Name].Purchases += amount
+		} else {
+			checks[account.Name].Sales -= amount
+		}
+	}
+	var result []CashCheck
+	for _, check := range checks {
+		if check.Purchases == 0 {
+			continue
+		}
+		check.Shortfall = max(check.Purchases-check.Available-check.Sales, 0)
+		result = append(result, *check)
+	}
+	slices.SortFunc(result, func(a, b CashCheck) int { return strings.Compare(a.Account, b.Account) })
+	return result
+}
+
+// printCashChecks warns about accounts whose purchases need more cash than
+// they have
+func printCashChecks(result *RebalanceResult) {
+	short := false
+	for _, check := range result.CashChecks {
+		if check.Shortfall == 0 {
+			continue
+		}
+		funds := formatAmount(check.Available, true) + " of cash"
+		if check.Sales > 0 {
+			funds += " and " + formatAmount(check.Sales, true) + " of sales"
+		}
+		fmt.Println(red(fmt.Sprintf("Warning: purchases of %s in %s need %s more than its %s", formatAmount(check.Purchases, true), check.Account, formatAmount(check.Shortfall, true), funds)))
+		short = true
+	}
+	if short && result.DepositAmount > 0 {
+		fmt.Printf("The %s deposit can make up the shortfall of the account it goes to\n", formatAmount(result.DepositAmount, true))
+	}
+}
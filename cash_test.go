@@ -0,0 +1,115 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCashDrag(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	holdings := loadHoldings(t, config, "cash.csv")
+	if holdings.Cash["Individual"] != 500000 || holdings.Cash["Roth IRA"] != 50000 {
+		t.Fatalf("Cash mismatch: got %v", holdings.Cash)
+	}
+	if holdings.Amounts["VTI"] != 7100000 {
+		t.Errorf("Cash rows should not count toward stocks: got VTI %d", holdings.Amounts["VTI"])
+	}
+
+	asOf := time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)
+	snapshots := []Snapshot{
+		{AsOf: asOf.AddDate(0, 0, -146), Cash: map[string]int{"Roth IRA": 50000}},
+		{AsOf: asOf.AddDate(0, 0, -73), Cash: map[string]int{"Individual": 300000, "Roth IRA": 50000}},
+		{AsOf: asOf.AddDate(0, 0, -30), Cash: map[string]int{"Individual": 400000}},
+	}
+	expectedReturn := 7.0
+	config.Cash = &CashConfig{ExpectedReturn: &expectedReturn, CashYield: 2}
+	drags := cashDragCalc(config, holdings, snapshots, asOf)
+	if len(drags) != 2 {
+		t.Fatalf("Expected 2 accounts with cash, got %d", len(drags))
+	}
+
+	individual := drags[0]
+	if individual.Account != "Individual" || individual.Idle != 300000 || !individual.IdleSince.Equal(asOf.AddDate(0, 0, -73)) {
+		t.Errorf("Individual mismatch: %+v", individual)
+	}
+	// $5,000 at 5% a year, and $3,000 idle for a fifth of a year
+	if individual.AnnualDrag != 25000 || individual.AccruedDrag != 3000 {
+		t.Errorf("Individual drag mismatch: annual %d, accrued %d", individual.AnnualDrag, individual.AccruedDrag)
+	}
+
+	// The Roth IRA had no cash in the latest snapshot, so it only just arrived
+	roth := drags[1]
+	if !roth.IdleSince.Equal(asOf) || roth.AccruedDrag != 0 {
+		t.Errorf("Roth IRA mismatch: %+v", roth)
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshots.jsonl")
+	if snapshots, err := readSnapshots(path); err != nil || len(snapshots) != 0 {
+		t.Fatalf("Missing file should have no snapshots: %v, %v", snapshots, err)
+	}
+
+	later := time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)
+	earlier := later.AddDate(0, -1, 0)
+	for _, asOf := range []time.Time{later, earlier, later} {
+		holdings := &Holdings{AsOf: asOf, Amounts: map[string]int{"VTI": 100000}, Cash: map[string]int{"": 5000}}
+		if _, err := appendSnapshot(path, newSnapshot(holdings)); err != nil {
+			t.Fatalf("appendSnapshot failed: %v", err)
+		}
+	}
+
+	snapshots, err := readSnapshots(path)
+	if err != nil {
+		t.Fatalf("readSnapshots failed: %v", err)
+	}
+	if len(snapshots) != 2 || !snapshots[0].AsOf.Equal(earlier) {
+		t.Fatalf("Expected 2 snapshots oldest first, got %+v", snapshots)
+	}
+	if snapshots[1].Total != 105000 || snapshots[1].Cash[""] != 5000 {
+		t.Errorf("Snapshot mismatch: %+v", snapshots[1])
+	}
+}
+
+func TestCashChecks(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	config.Accounts = []Account{{Name: "Individual"}, {Name: "Roth IRA"}}
+	for i, target := range []float64{50, 30, 20} {
+		config.Stocks[i].TargetPercentage = target
+	}
+	holdings := loadHoldings(t, config, "cash.csv")
+	result, err := allocationCalc(config, holdings, 0)
+	if err != nil {
+		t.Fatalf("allocationCalc failed: %v", err)
+	}
+	// VTI is sold in Individual, but VXUS and BND are bought in the Roth IRA
+	// with only its $500 of cash
+	expected := []CashCheck{{Account: "Roth IRA", Available: 50000, Purchases: 2100000, Shortfall: 2050000}}
+	if !reflect.DeepEqual(result.CashChecks, expected) {
+		t.Errorf("Cash checks mismatch: got %+v, expected %+v", result.CashChecks, expected)
+	}
+
+	// E*TRADE's summary gives each account's cash purchasing power
+	holdings = loadHoldings(t, config, "etrade.csv")
+	if holdings.AvailableCash["Individual Brokerage -1234"] != 500000 || holdings.AvailableCash["Roth IRA -5678"] != 0 {
+		t.Errorf("Available cash mismatch: got %v", holdings.AvailableCash)
+	}
+
+	// Core positions may have a description but no symbol
+	holdings, err = readHoldings(config, strings.NewReader("Symbol,Description,Current Value\n,Core Position,$250.00\nVTI,Vanguard Total Stock Market ETF,$1000.00\n"))
+	if err != nil {
+		t.Fatalf("readHoldings failed: %v", err)
+	}
+	if holdings.Cash[""] != 25000 || holdings.Amounts["VTI"] != 100000 {
+		t.Errorf("Core position mismatch: cash %v, amounts %v", holdings.Cash, holdings.Amounts)
+	}
+}
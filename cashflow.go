@@ -0,0 +1,267 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// defaultDividendMonths are the months a stock with a dividend yield pays in
+// when the config doesn't say: quarterly, as most funds do
+var defaultDividendMonths = []int{3, 6, 9, 12}
+
+// couponFrequencies are the coupon payments per year a ladder bond may make
+var couponFrequencies = []int{1, 2, 4, 12}
+
+// LadderBond is a bond or CD in a ladder, held to maturity, whose coupons
+// and principal the cashflow command projects
+type LadderBond struct {
+	Name    string `yaml:"name"`
+	Account string `yaml:"account,omitempty"`
+	// Face is the principal repaid at Maturity
+	Face Money `yaml:"face"`
+	// Coupon is the annual coupon rate, in percent of Face
+	Coupon   float64   `yaml:"coupon,omitempty"`
+	Maturity time.Time `yaml:"maturity"`
+	// CouponsPerYear is how often the coupon is paid, counting back from
+	// Maturity (default 2)
+	CouponsPerYear int `yaml:"coupons_per_year,omitempty"`
+}
+
+func (b *LadderBond) couponsPerYear() int {
+	if b.CouponsPerYear == 0 {
+		return 2
+	}
+	return b.CouponsPerYear
+}
+
+// Income is one expected payment: a dividend, a coupon, or a bond's
+// principal at maturity
+type Income struct {
+	Account string `json:"account"`
+	// Kind is dividend, coupon, or maturity
+	Kind string `json:"kind"`
+	// Source is the stock or bond paying it
+	Source string `json:"source"`
+	Amount int    `json:"amount"`
+}
+
+// IncomeMonth is the income expected in one month
+type IncomeMonth struct {
+	// Month is the first day of the month
+	Month    time.Time      `json:"month"`
+	Total    int            `json:"total"`
+	Accounts map[string]int `json:"accounts"`
+	Flows    []Income       `json:"flows"`
+}
+
+type IncomeProjection struct {
+	Months []IncomeMonth `json:"months"`
+	// Accounts lists every account with income, in the order first paid
+	Accounts []string `json:"accounts"`
+	Total    int      `json:"total"`
+}
+
+func validateCashFlow(config *Config) error {
+	for _, stock := range config.Stocks {
+		if stock.DividendYield < 0 {
+			return fmt.Errorf("dividend_yield of %s must not be negative", stock.Symbol)
+		}
+		for _, month := range stock.DividendMonths {
+			if month < 1 || month > 12 {
+				return fmt.Errorf("dividend_months of %s must be between 1 and 12", stock.Symbol)
+			}
+		}
+	}
+	seen := make(map[string]bool)
+	for _, bond := range config.Ladder {
+		if bond.Name == "" {
+			return fmt.Errorf("ladder bonds need a name")
+		}
+		if seen[bond.Name] {
+			return fmt.Errorf("ladder bond %q appears multiple times", bond.Name)
+		}
+		seen[bond.Name] = true
+		if bond.Face <= 0 {
+			return fmt.Errorf("ladder bond %q must have a positive face value", bond.Name)
+		}
+		if bond.Coupon < 0 {
+			return fmt.Errorf("ladder bond %q must not have a negative coupon", bond.Name)
+		}
+		if bond.Maturity.IsZero() {
+			return fmt.Errorf("ladder bond %q must have a maturity", bond.Name)
+		}
+		if bond.CouponsPerYear != 0 && !slices.Contains(couponFrequencies, bond.CouponsPerYear) {
+			return fmt.Errorf("ladder bond %q coupons_per_year must be 1, 2, 4, or 12", bond.Name)
+		}
+	}
+	return nil
+}
+
+func cashflow(config *Config, args []string) {
+	var format, from string
+	var months int
+	flagSet := flag.NewFlagSet("cashflow", flag.ExitOnError)
+	flagSet.IntVar(&months, "months", 12, "How many months to project")
+	flagSet.StringVar(&from, "from", "", "First month to project, as YYYY-MM (default this month)")
+	flagSet.StringVar(&format, "format", "text", "Output format: text or json")
+	if len(args) < 1 {
+		flag.Usage()
+		return
+	}
+	portfolioCsv := args[0]
+	flagSet.Parse(args[1:])
+	if format != "text" && format != "json" {
+		printError(codedErrorf(CodeUsage, "unknown format %q", format))
+		return
+	}
+	if months < 1 {
+		printError(codedErrorf(CodeUsage, "-months must be at least 1"))
+		return
+	}
+	start := time.Now()
+	if from != "" {
+		var err error
+		if start, err = time.Parse("2006-01", from); err != nil {
+			printError(codedErrorf(CodeUsage, "invalid -from month %q (expected YYYY-MM)", from))
+			return
+		}
+	}
+
+	holdings, err := loadPortfolio(config, portfolioCsv)
+	if err != nil {
+		printError(err)
+		return
+	}
+	projection := cashFlowCalc(config, holdings, start, months)
+
+	if format == "json" {
+		if err := writeJSON(os.Stdout, projection); err != nil {
+			printError(err)
+		}
+		return
+	}
+	printCashFlow(projection)
+}
+
+// cashFlowCalc projects the income of each month from start's on: dividends
+// at each stock's dividend_yield on its current value, split evenly across
+// the months it pays in, and the coupons and principal of the ladder. Values
+// are held where they are, so nothing is reinvested.
+func cashFlowCalc(config *Config, holdings *Holdings, start time.Time, months int) *IncomeProjection {
+	first := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
+	projection := &IncomeProjection{Months: make([]IncomeMonth, months)}
+	for i := range projection.Months {
+		projection.Months[i] = IncomeMonth{Month: first.AddDate(0, i, 0), Accounts: make(map[string]int)}
+	}
+	add := func(month time.Time, flow Income) {
+		i := (month.Year()-first.Year())*12 + int(month.Month()-first.Month())
+		if i < 0 || i >= months || flow.Amount == 0 {
+			return
+		}
+		m := &projection.Months[i]
+		m.Flows = append(m.Flows, flow)
+		m.Accounts[flow.Account] += flow.Amount
+		m.Total += flow.Amount
+		projection.Total += flow.Amount
+		if !slices.Contains(projection.Accounts, flow.Account) {
+			projection.Accounts = append(projection.Accounts, flow.Account)
+		}
+	}
+
+	// Sum each stock's value by account, in the order the rows were read
+	yields := make(map[string]*Stock)
+	for i := range config.Stocks {
+		if config.Stocks[i].DividendYield > 0 {
+			yields[config.Stocks[i].Symbol] = &config.Stocks[i]
+		}
+	}
+	type holding struct{ symbol, account string }
+	var order []holding
+	values := make(map[holding]int)
+	for _, position := range holdings.Positions {
+		key := holding{position.Primary, position.Account}
+		if yields[key.symbol] == nil {
+			continue
+		}
+		if _, ok := values[key]; !ok {
+			order = append(order, key)
+		}
+		values[key] += position.Value
+	}
+	for _, month := range projection.Months {
+		for _, key := range order {
+			stock := yields[key.symbol]
+			paid := stock.DividendMonths
+			if len(paid) == 0 {
+				paid = defaultDividendMonths
+			}
+			if !slices.Contains(paid, int(month.Month.Month())) {
+				continue
+			}
+			amount := config.round(float64(values[key])*stock.DividendYield/100/float64(len(paid)), "half-up")
+			add(month.Month, Income{Account: key.account, Kind: "dividend", Source: key.symbol, Amount: amount})
+		}
+	}
+
+	for _, bond := range config.Ladder {
+		maturity := time.Date(bond.Maturity.Year(), bond.Maturity.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if maturity.Before(first) {
+			continue
+		}
+		perYear := bond.couponsPerYear()
+		coupon := config.round(float64(bond.Face)*bond.Coupon/100/float64(perYear), "half-up")
+		var payments []time.Time
+		for month := maturity; !month.Before(first); month = month.AddDate(0, -12/perYear, 0) {
+			payments = append(payments, month)
+		}
+		for _, month := range slices.Backward(payments) {
+			add(month, Income{Account: bond.Account, Kind: "coupon", Source: bond.Name, Amount: coupon})
+		}
+		add(maturity, Income{Account: bond.Account, Kind: "maturity", Source: bond.Name, Amount: int(bond.Face)})
+	}
+	return projection
+}
+
+// accountLabel names an account in reports, including the rows of exports
+// without one
+func accountLabel(account string) string {
+	if account == "" {
+		return "Other"
+	}
+	return account
+}
+
+func printCashFlow(projection *IncomeProjection) {
+	if projection.Total == 0 {
+		fmt.Println("No income expected; set dividend_yield on stocks or list bonds in the ladder")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	header := []string{"Month"}
+	for _, account := range projection.Accounts {
+		header = append(header, accountLabel(account))
+	}
+	fmt.Fprintln(w, strings.Join(append(header, "Total"), "\t")+"\t")
+	for _, month := range projection.Months {
+		row := []string{month.Month.Format("2006-01")}
+		for _, account := range projection.Accounts {
+			row = append(row, formatAmount(month.Accounts[account], true))
+		}
+		fmt.Fprintln(w, strings.Join(append(row, formatAmount(month.Total, true)), "\t")+"\t")
+	}
+	w.Flush()
+	fmt.Println(rule())
+	fmt.Printf("Total over %d months: %s\n", len(projection.Months), formatAmount(projection.Total, true))
+	for _, month := range projection.Months {
+		for _, flow := range month.Flows {
+			if flow.Kind == "maturity" {
+				fmt.Printf("%s matures in %s, repaying %s to %s\n", flow.Source, month.Month.Format("January 2006"), formatAmount(flow.Amount, true), accountLabel(flow.Account))
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCashFlowCalc(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	config.Stocks[0].DividendYield = 1.2
+	config.Stocks[2].DividendYield = 3.6
+	config.Stocks[2].DividendMonths = []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+	config.Ladder = []LadderBond{
+		{Name: "T 4 2027", Account: "IRA", Face: 1000000, Coupon: 4, Maturity: time.Date(2027, 2, 15, 0, 0, 0, 0, time.UTC)},
+		{Name: "CD 2025", Face: 500000, Coupon: 5, Maturity: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	if err := config.validate(); err != nil {
+		t.Fatalf("validate failed: %v", err)
+	}
+	csv := `Account Name,Symbol,Current Value
+Brokerage,VTI,"$10,000.00"
+IRA,VTI,"$20,000.00"
+IRA,BND,"$10,000.00"
+`
+	holdings, err := readHoldings(config, strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("readHoldings failed: %v", err)
+	}
+
+	projection := cashFlowCalc(config, holdings, time.Date(2026, 7, 20, 0, 0, 0, 0, time.UTC), 12)
+	if len(projection.Months) != 12 || projection.Months[0].Month.Format("2006-01") != "2026-07" {
+		t.Fatalf("Expected 12 months from July 2026, got %+v", projection.Months)
+	}
+	months := make(map[string]IncomeMonth)
+	for _, month := range projection.Months {
+		months[month.Month.Format("2006-01")] = month
+	}
+	// BND pays 3.6% of $10,000 a year monthly, $30 a month
+	if july := months["2026-07"]; july.Total != 3000 || july.Accounts["IRA"] != 3000 {
+		t.Errorf("Expected $30 of BND income in July, got %+v", july)
+	}
+	// VTI pays 1.2% a year quarterly: $30 in the brokerage, $60 in the IRA,
+	// and the bond's coupon falls in August
+	if sept := months["2026-09"]; sept.Accounts["Brokerage"] != 3000 || sept.Accounts["IRA"] != 9000 {
+		t.Errorf("Expected VTI dividends in September, got %+v", sept)
+	}
+	if aug := months["2026-08"]; aug.Accounts["IRA"] != 3000+20000 {
+		t.Errorf("Expected BND income and a $200 coupon in August, got %+v", aug)
+	}
+	feb := months["2027-02"]
+	if feb.Accounts["IRA"] != 3000+20000+1000000 || feb.Flows[len(feb.Flows)-1].Kind != "maturity" {
+		t.Errorf("Expected the last coupon and principal in February, got %+v", feb)
+	}
+	// The matured CD pays nothing
+	if total := 12*3000 + 4*3000 + 4*6000 + 2*20000 + 1000000; projection.Total != total {
+		t.Errorf("Expected total income of %d, got %d", total, projection.Total)
+	}
+	if strings.Join(projection.Accounts, ",") != "IRA,Brokerage" {
+		t.Errorf("Expected accounts in the order first paid, got %v", projection.Accounts)
+	}
+
+	config.Ladder[0].CouponsPerYear = 3
+	if err := config.validate(); err == nil {
+		t.Error("Expected an error for 3 coupons a year")
+	}
+}
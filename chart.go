@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+// chartColors are the slice and bar colors, repeated for longer configs
+var chartColors = []color.RGBA{
+	{0x1f, 0x77, 0xb4, 0xff}, {0xff, 0x7f, 0x0e, 0xff}, {0x2c, 0xa0, 0x2c, 0xff}, {0xd6, 0x27, 0x28, 0xff},
+	{0x94, 0x67, 0xbd, 0xff}, {0x8c, 0x56, 0x4b, 0xff}, {0xe3, 0x77, 0xc2, 0xff}, {0x7f, 0x7f, 0x7f, 0xff},
+	{0xbc, 0xbd, 0x22, 0xff}, {0x17, 0xbe, 0xcf, 0xff},
+}
+
+// The chart has the current and target donuts side by side, a legend under
+// them, and a bar of each symbol's drift below that
+const (
+	chartWidth       = 800
+	chartDonutY      = 190
+	chartOuterRadius = 120
+	chartInnerRadius = 70
+	chartLegendY     = 350
+	chartBarsY       = 400
+	chartBarHeight   = 20
+	chartBarGap      = 8
+	// chartBarReach is how far the largest drift's bar reaches from the axis
+	chartBarReach = 280
+)
+
+// chartDonutX are the centers of the current and target donuts
+var chartDonutX = [2]int{200, 600}
+
+// ChartSlice is one symbol's part of the chart
+type ChartSlice struct {
+	Symbol  string
+	Current float64
+	Target  float64
+	Drift   float64
+	Color   color.RGBA
+}
+
+func chart(config *Config, args []string) {
+	var format, outputPath string
+	flagSet := flag.NewFlagSet("chart", flag.ExitOnError)
+	flagSet.StringVar(&format, "format", "svg", "Image format: svg or png")
+	flagSet.StringVar(&outputPath, "o", "", "Write the chart to a file instead of stdout")
+	if len(args) < 1 {
+		flag.Usage()
+		return
+	}
+	portfolioCsv := args[0]
+	flagSet.Parse(args[1:])
+	if format != "svg" && format != "png" {
+		printError(codedErrorf(CodeUsage, "unknown format %q", format))
+		return
+	}
+	if format == "png" && outputPath == "" && isTerminal(os.Stdout) {
+		printError(codedErrorf(CodeUsage, "a PNG chart can't be shown on a terminal; pass -o <file>"))
+		return
+	}
+
+	holdings, err := loadPortfolio(config, portfolioCsv)
+	if err != nil {
+		printError(err)
+		return
+	}
+	result, err := allocationCalc(config, holdings, 0)
+	if err != nil {
+		printError(err)
+		return
+	}
+	slices := chartSlices(config, result)
+
+	err = writeOutput(outputPath, func(w io.Writer) error {
+		if format == "png" {
+			return writeChartPNG(w, slices)
+		}
+		return writeChartSVG(w, slices, result.AsOf)
+	})
+	if err != nil {
+		printError(err)
+	}
+}
+
+// chartSlices gives each stock a color in config order
+func chartSlices(config *Config, result *RebalanceResult) []ChartSlice {
+	slices := make([]ChartSlice, 0, len(config.Stocks))
+	for i, stock := range config.Stocks {
+		data := result.Symbols[stock.Symbol]
+		slices = append(slices, ChartSlice{
+			Symbol:  stock.Symbol,
+			Current: max(data.CurrentPercentage, 0),
+			Target:  stock.TargetPercentage,
+			Drift:   data.Drift,
+			Color:   chartColors[i%len(chartColors)],
+		})
+	}
+	return slices
+}
+
+func chartHeight(slices []ChartSlice) int {
+	return chartBarsY + len(slices)*(chartBarHeight+chartBarGap) + 20
+}
+
+// chartBar is the rectangle of the ith symbol's drift bar, which runs right
+// of the axis when overweight and left when underweight
+func chartBar(i int, drift, maxDrift float64) image.Rectangle {
+	axis := chartWidth / 2
+	length := 0
+	if maxDrift > 0 {
+		length = int(math.Round(drift / maxDrift * chartBarReach))
+	}
+	y := chartBarsY + i*(chartBarHeight+chartBarGap)
+	return image.Rect(axis, y, axis+length, y+chartBarHeight).Canon()
+}
+
+func maxDrift(slices []ChartSlice) float64 {
+	largest := 0.0
+	for _, s := range slices {
+		largest = max(largest, math.Abs(s.Drift))
+	}
+	return largest
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func writeChartSVG(w io.Writer, slices []ChartSlice, asOf time.Time) error {
+	height := chartHeight(slices)
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="14">`+"\n", chartWidth, height, chartWidth, height)
+	writeSVGManifest(w)
+	fmt.Fprintf(w, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	title := "Allocation"
+	if !asOf.IsZero() {
+		title += " as of " + asOf.Format(time.DateOnly)
+	}
+	fmt.Fprintf(w, `<text x="%d" y="30" text-anchor="middle" font-size="18">%s</text>`+"\n", chartWidth/2, html.EscapeString(title))
+
+	// Each donut is a ring of dashes, one per symbol, drawn clockwise from
+	// the top
+	radius := float64(chartOuterRadius+chartInnerRadius) / 2
+	circumference := 2 * math.Pi * radius
+	for d, name := range []string{"Current", "Target"} {
+		cx := chartDonutX[d]
+		fmt.Fprintf(w, `<text x="%d" y="%d" text-anchor="middle" font-size="16">%s</text>`+"\n", cx, chartDonutY+6, name)
+		offset := 0.0
+		for _, s := range slices {
+			percentage := s.Current
+			if d == 1 {
+				percentage = s.Target
+			}
+			if percentage <= 0 {
+				continue
+			}
+			length := percentage / 100 * circumference
+			fmt.Fprintf(w, `<circle cx="%d" cy="%d" r="%.2f" fill="none" stroke="%s" stroke-width="%d" stroke-dasharray="%.2f %.2f" stroke-dashoffset="%.2f" transform="rotate(-90 %d %d)"><title>%s %.2f%%</title></circle>`+"\n",
+				cx, chartDonutY, radius, hexColor(s.Color), chartOuterRadius-chartInnerRadius, length, circumference, 0-offset, cx, chartDonutY, html.EscapeString(s.Symbol), percentage)
+			offset += length
+		}
+	}
+
+	spacing := chartWidth / max(len(slices), 1)
+	for i, s := range slices {
+		x := i*spacing + spacing/2 - 50
+		fmt.Fprintf(w, `<rect x="%d" y="%d" width="12" height="12" fill="%s"/>`+"\n", x, chartLegendY-11, hexColor(s.Color))
+		fmt.Fprintf(w, `<text x="%d" y="%d">%s %.1f%% / %.1f%%</text>`+"\n", x+18, chartLegendY, html.EscapeString(s.Symbol), s.Current, s.Target)
+	}
+
+	largest := maxDrift(slices)
+	axis := chartWidth / 2
+	fmt.Fprintf(w, `<text x="%d" y="%d" text-anchor="middle">Drift from target (percentage points)</text>`+"\n", axis, chartBarsY-12)
+	for i, s := range slices {
+		bar := chartBar(i, s.Drift, largest)
+		fmt.Fprintf(w, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`+"\n", bar.Min.X, bar.Min.Y, bar.Dx(), bar.Dy(), hexColor(s.Color))
+		fmt.Fprintf(w, `<text x="%d" y="%d" text-anchor="end">%s</text>`+"\n", axis-chartBarReach-10, bar.Max.Y-5, html.EscapeString(s.Symbol))
+		label, anchor, x := fmt.Sprintf("%+.2f", s.Drift), "start", bar.Max.X+6
+		if s.Drift < 0 {
+			anchor, x = "end", bar.Min.X-6
+		}
+		fmt.Fprintf(w, `<text x="%d" y="%d" text-anchor="%s">%s</text>`+"\n", x, bar.Max.Y-5, anchor, label)
+	}
+	bottom := chartBarsY + len(slices)*(chartBarHeight+chartBarGap)
+	fmt.Fprintf(w, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#333"/>`+"\n", axis, chartBarsY-4, axis, bottom)
+	_, err := fmt.Fprintln(w, "</svg>")
+	return err
+}
+
+// writeChartPNG draws the same chart as writeChartSVG without its text, as
+// the standard library has no fonts; the legend is a swatch per symbol in
+// config order
+func writeChartPNG(w io.Writer, slices []ChartSlice) error {
+	img := image.NewRGBA(image.Rect(0, 0, chartWidth, chartHeight(slices)))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+
+	for d := range chartDonutX {
+		cx := chartDonutX[d]
+		for y := chartDonutY - chartOuterRadius; y <= chartDonutY+chartOuterRadius; y++ {
+			for x := cx - chartOuterRadius; x <= cx+chartOuterRadius; x++ {
+				dx, dy := float64(x-cx)+0.5, float64(y-chartDonutY)+0.5
+				r := math.Hypot(dx, dy)
+				if r > chartOuterRadius || r < chartInnerRadius {
+					continue
+				}
+				// Percent of the way clockwise from the top
+				angle := math.Atan2(dy, dx) + math.Pi/2
+				if angle < 0 {
+					angle += 2 * math.Pi
+				}
+				position := angle / (2 * math.Pi) * 100
+				cumulative := 0.0
+				for _, s := range slices {
+					percentage := s.Current
+					if d == 1 {
+						percentage = s.Target
+					}
+					cumulative += percentage
+					if position < cumulative {
+						img.SetRGBA(x, y, s.Color)
+						break
+					}
+				}
+			}
+		}
+	}
+
+	spacing := chartWidth / max(len(slices), 1)
+	for i, s := range slices {
+		x := i*spacing + spacing/2 - 6
+		fillRect(img, image.Rect(x, chartLegendY-11, x+12, chartLegendY+1), s.Color)
+	}
+
+	largest := maxDrift(slices)
+	for i, s := range slices {
+		fillRect(img, chartBar(i, s.Drift, largest), s.Color)
+	}
+	bottom := chartBarsY + len(slices)*(chartBarHeight+chartBarGap)
+	fillRect(img, image.Rect(chartWidth/2, chartBarsY-4, chartWidth/2+1, bottom), color.RGBA{0x33, 0x33, 0x33, 0xff})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	_, err := w.Write(pngManifest(buf.Bytes()))
+	return err
+}
+
+func fillRect(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	r = r.Intersect(img.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
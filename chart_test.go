@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChart(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	holdings := loadHoldings(t, config, "cash.csv")
+	config.Stocks[0].TargetPercentage = 66
+	config.Stocks[2].TargetPercentage = 16
+	result, err := allocationCalc(config, holdings, 0)
+	if err != nil {
+		t.Fatalf("allocationCalc failed: %v", err)
+	}
+	slices := chartSlices(config, result)
+	if len(slices) != 3 || slices[0].Symbol != "VTI" || slices[0].Color != chartColors[0] {
+		t.Fatalf("Expected a slice per stock in config order, got %+v", slices)
+	}
+
+	var svg bytes.Buffer
+	if err := writeChartSVG(&svg, slices, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("writeChartSVG failed: %v", err)
+	}
+	if count := strings.Count(svg.String(), "<circle"); count != 6 {
+		t.Errorf("Expected a donut slice per symbol for current and target, got %d", count)
+	}
+	for _, text := range []string{"as of 2024-03-01", "VXUS 18.0% / 18.0%", "+5.00", "-5.00"} {
+		if !strings.Contains(svg.String(), text) {
+			t.Errorf("Expected the SVG to contain %q", text)
+		}
+	}
+	if strings.Contains(svg.String(), "-0.00") {
+		t.Error("Expected no negative zero in the SVG")
+	}
+
+	// VTI is overweight and BND underweight by the same amount, so their
+	// bars reach equally far on either side of the axis
+	vti, bnd := chartBar(0, slices[0].Drift, maxDrift(slices)), chartBar(2, slices[2].Drift, maxDrift(slices))
+	if vti.Min.X != chartWidth/2 || vti.Dx() != chartBarReach {
+		t.Errorf("Expected VTI's bar right of the axis, got %v", vti)
+	}
+	if bnd.Max.X != chartWidth/2 || bnd.Dx() != chartBarReach {
+		t.Errorf("Expected BND's bar left of the axis, got %v", bnd)
+	}
+
+	var buf bytes.Buffer
+	if err := writeChartPNG(&buf, slices); err != nil {
+		t.Fatalf("writeChartPNG failed: %v", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("Failed to decode PNG: %v", err)
+	}
+	if img.Bounds().Dx() != chartWidth || img.Bounds().Dy() != chartHeight(slices) {
+		t.Errorf("Expected a %dx%d image, got %v", chartWidth, chartHeight(slices), img.Bounds())
+	}
+	// Just right of the top of each donut is the first slice, and just left
+	// is the last
+	top := chartDonutY - (chartOuterRadius+chartInnerRadius)/2
+	for _, cx := range chartDonutX {
+		if got := img.At(cx+2, top); got != slices[0].Color {
+			t.Errorf("Expected VTI's color right of the top of the donut at %d, got %v", cx, got)
+		}
+		if got := img.At(cx-3, top); got != slices[2].Color {
+			t.Errorf("Expected BND's color left of the top of the donut at %d, got %v", cx, got)
+		}
+	}
+	if got := img.At(vti.Min.X+10, vti.Min.Y+5); got != slices[0].Color {
+		t.Errorf("Expected VTI's drift bar, got %v", got)
+	}
+}
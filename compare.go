@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// Scenario is one config's rebalance of a shared portfolio
+type Scenario struct {
+	Name   string
+	Config *Config
+	Result *RebalanceResult
+}
+
+func compare(config *Config, args []string) {
+	var toDeposit int
+	flagSet := flag.NewFlagSet("compare", flag.ExitOnError)
+	flagSet.Func("toDeposit", "Amount to deposit (negative to withdraw) in every scenario", func(value string) error {
+		amount, err := amountToInt(value)
+		toDeposit = amount
+		return err
+	})
+	if len(args) < 1 {
+		flag.Usage()
+		return
+	}
+	portfolioCsv := args[0]
+	flagSet.Parse(args[1:])
+	if flagSet.NArg() < 1 {
+		printError(codedErrorf(CodeUsage, "at least one config to compare against is required"))
+		return
+	}
+
+	scenarios := []*Scenario{{Name: "current", Config: config}}
+	for _, path := range flagSet.Args() {
+		proposed, err := parseConfig(path)
+		if err != nil {
+			printError(fmt.Errorf("parsing config %s: %w", path, err))
+			return
+		}
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		scenarios = append(scenarios, &Scenario{Name: name, Config: proposed})
+	}
+
+	if err := compareCalc(scenarios, portfolioCsv, toDeposit); err != nil {
+		printError(err)
+		return
+	}
+	printWarnings(os.Stderr, scenarios[0].Result.Warnings)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(w, "\t")
+	for _, scenario := range scenarios {
+		fmt.Fprintf(w, "%s\t\t\t", scenario.Name)
+	}
+	fmt.Fprint(w, "\nSymbol\t")
+	for range scenarios {
+		fmt.Fprint(w, "Target\tDrift\tTrade\t")
+	}
+	fmt.Fprintln(w)
+	for _, symbol := range compareSymbols(scenarios) {
+		fmt.Fprintf(w, "%s\t", symbol)
+		for _, scenario := range scenarios {
+			data, ok := scenario.Result.Symbols[symbol]
+			if !ok {
+				fmt.Fprint(w, "-\t-\t-\t")
+				continue
+			}
+			needed := formatAmount(data.AmountNeeded, true)
+			if plain {
+				needed = tradeText(data.AmountNeeded, needed)
+			}
+			fmt.Fprintf(w, "%.2f%%\t%s\t%s\t", data.TargetPercentage, driftText(data.Drift), needed)
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+
+	fmt.Println("\n" + rule())
+	for _, scenario := range scenarios {
+		fmt.Printf("%s: total trades %s\n", scenario.Name, formatAmount(tradeVolume(scenario.Result), true))
+	}
+	fmt.Printf("As of: %s\n", formatAsOf(scenarios[0].Result.AsOf, scenarios[0].Result.AsOfSource))
+}
+
+// compareCalc rebalances the same portfolio under each scenario's config.
+// The portfolio is read once per config because symbol alternatives, account
+// settings, and static positions change its holdings.
+func compareCalc(scenarios []*Scenario, path string, depositCents int) error {
+	for _, scenario := range scenarios {
+		holdings, err := readPortfolio(scenario.Config, path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", scenario.Name, err)
+		}
+		scenario.Result, err = allocationCalc(scenario.Config, holdings, depositCents)
+		if err != nil {
+			return fmt.Errorf("%s: %w", scenario.Name, err)
+		}
+	}
+	return nil
+}
+
+// compareSymbols lists every symbol targeted by any scenario, in config order
+func compareSymbols(scenarios []*Scenario) []string {
+	var symbols []string
+	seen := make(map[string]bool)
+	for _, scenario := range scenarios {
+		for _, stock := range scenario.Config.Stocks {
+			if !seen[stock.Symbol] {
+				seen[stock.Symbol] = true
+				symbols = append(symbols, stock.Symbol)
+			}
+		}
+	}
+	return symbols
+}
+
+// tradeVolume is the total dollar amount bought and sold
+func tradeVolume(result *RebalanceResult) int {
+	volume := 0
+	for _, data := range result.Symbols {
+		if data.AmountNeeded < 0 {
+			volume -= data.AmountNeeded
+		} else {
+			volume += data.AmountNeeded
+		}
+	}
+	return volume
+}
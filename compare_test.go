@@ -0,0 +1,58 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestCompareCalc(t *testing.T) {
+	var scenarios []*Scenario
+	for _, name := range []string{"simple", "proposed"} {
+		config, err := parseConfig(filepath.Join("tests", "configs", name+".yaml"))
+		if err != nil {
+			t.Fatalf("Failed to parse config: %v", err)
+		}
+		scenarios = append(scenarios, &Scenario{Name: name, Config: config})
+	}
+	portfolio := filepath.Join("tests", "portfolios", "unbalanced.csv")
+
+	if err := compareCalc(scenarios, portfolio, 100000); err != nil {
+		t.Fatalf("compareCalc failed: %v", err)
+	}
+	if symbols := compareSymbols(scenarios); !slices.Equal(symbols, []string{"VTI", "VXUS", "BND", "VNQ"}) {
+		t.Errorf("compareSymbols mismatch: got %v", symbols)
+	}
+
+	expected := map[string]map[string]int{
+		"simple":   {"VTI": -829000, "VXUS": 618000, "BND": 311000},
+		"proposed": {"VTI": -1940000, "VXUS": 1325000, "BND": 210000, "VNQ": 505000},
+	}
+	for _, scenario := range scenarios {
+		if len(scenario.Result.Symbols) != len(expected[scenario.Name]) {
+			t.Errorf("%s: got %d symbols, expected %d", scenario.Name, len(scenario.Result.Symbols), len(expected[scenario.Name]))
+		}
+		for symbol, amount := range expected[scenario.Name] {
+			if actual := scenario.Result.Symbols[symbol].AmountNeeded; actual != amount {
+				t.Errorf("%s %s: got %d, expected %d", scenario.Name, symbol, actual, amount)
+			}
+		}
+	}
+	if volume := tradeVolume(scenarios[1].Result); volume != 3980000 {
+		t.Errorf("tradeVolume mismatch: got %d, expected 3980000", volume)
+	}
+
+	// Static positions are merged into each scenario's portfolio
+	for _, scenario := range scenarios {
+		scenario.Config.StaticPositions = []StaticPosition{{Symbol: "BND", Value: 1000000, Account: "Credit Union"}}
+	}
+	if err := compareCalc(scenarios, portfolio, 100000); err != nil {
+		t.Fatalf("compareCalc failed: %v", err)
+	}
+	expectedBND := map[string]int{"simple": -579000, "proposed": -690000}
+	for _, scenario := range scenarios {
+		if bnd := scenario.Result.Symbols["BND"]; bnd.Amount != 1800000 || bnd.AmountNeeded != expectedBND[scenario.Name] {
+			t.Errorf("%s BND: got %d held and %d to trade, expected 1800000 and %d", scenario.Name, bnd.Amount, bnd.AmountNeeded, expectedBND[scenario.Name])
+		}
+	}
+}
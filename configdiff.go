@@ -0,0 +1,198 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// TargetChange is one symbol's target under two configs and, with a
+// portfolio, the trades each would make
+type TargetChange struct {
+	Symbol string `json:"symbol"`
+	// Status is added, removed, changed, or unchanged
+	Status    string  `json:"status"`
+	OldTarget float64 `json:"old_target"`
+	NewTarget float64 `json:"new_target"`
+	Amount    int     `json:"amount"`
+	OldTrade  int     `json:"old_trade"`
+	NewTrade  int     `json:"new_trade"`
+	// Change is what the new targets trade beyond the old ones
+	Change int `json:"change"`
+}
+
+type ConfigDiff struct {
+	Old     string         `json:"old"`
+	New     string         `json:"new"`
+	Symbols []TargetChange `json:"symbols"`
+	// Portfolio is empty when no portfolio was given, leaving the trades 0
+	Portfolio string `json:"portfolio,omitempty"`
+	// Proceeds is the value of symbols the new config drops, which the new
+	// trades sell and reinvest
+	Proceeds  int `json:"proceeds"`
+	OldVolume int `json:"old_volume"`
+	NewVolume int `json:"new_volume"`
+	// Warnings are those of the portfolio read under the new config
+	Warnings []Warning `json:"warnings,omitempty"`
+}
+
+func configDiff(args []string) int {
+	var portfolioCsv, format string
+	flagSet := flag.NewFlagSet("config diff", flag.ExitOnError)
+	flagSet.StringVar(&portfolioCsv, "portfolio", "", "Portfolio CSV to show the trades of each config on")
+	flagSet.StringVar(&format, "format", "text", "Output format: text or json")
+	if len(args) < 2 {
+		fmt.Println("Usage: fin-tilt config diff <old.yaml> <new.yaml> [-portfolio <portfolio.csv>] [-format json]")
+		return 1
+	}
+	oldPath, newPath := args[0], args[1]
+	flagSet.Parse(args[2:])
+	if format != "text" && format != "json" {
+		printError(codedErrorf(CodeUsage, "unknown format %q", format))
+		return 1
+	}
+
+	oldConfig, err := parseConfig(oldPath)
+	if err != nil {
+		printError(fmt.Errorf("parsing config %s: %w", oldPath, err))
+		return 1
+	}
+	newConfig, err := parseConfig(newPath)
+	if err != nil {
+		printError(fmt.Errorf("parsing config %s: %w", newPath, err))
+		return 1
+	}
+	diff, err := configDiffCalc(oldConfig, newConfig, portfolioCsv)
+	if err != nil {
+		printError(err)
+		return 1
+	}
+	diff.Old, diff.New, diff.Portfolio = oldPath, newPath, portfolioCsv
+	printWarnings(os.Stderr, diff.Warnings)
+
+	if format == "json" {
+		if err := writeJSON(os.Stdout, diff); err != nil {
+			printError(err)
+			return 1
+		}
+		return 0
+	}
+	oldName := strings.TrimSuffix(filepath.Base(oldPath), filepath.Ext(oldPath))
+	newName := strings.TrimSuffix(filepath.Base(newPath), filepath.Ext(newPath))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "Symbol\t%s\t%s\t", oldName, newName)
+	if portfolioCsv != "" {
+		fmt.Fprintf(w, "Trade (%s)\tTrade (%s)\tChange\t", oldName, newName)
+	}
+	fmt.Fprintln(w, "\t")
+	for _, c := range diff.Symbols {
+		fmt.Fprintf(w, "%s\t%.2f%%\t%.2f%%\t", c.Symbol, c.OldTarget, c.NewTarget)
+		if portfolioCsv != "" {
+			change := formatAmount(c.Change, true)
+			if plain {
+				change = tradeText(c.Change, change)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t", formatAmount(c.OldTrade, true), formatAmount(c.NewTrade, true), change)
+		}
+		status := ""
+		if c.Status == "added" || c.Status == "removed" {
+			status = c.Status
+		}
+		fmt.Fprintf(w, "%s\t\n", status)
+	}
+	w.Flush()
+	if portfolioCsv == "" {
+		return 0
+	}
+	fmt.Println("\n" + rule())
+	if diff.Proceeds > 0 {
+		fmt.Printf("Symbols dropped by %s are sold, freeing %s to reinvest\n", newName, formatAmount(diff.Proceeds, true))
+	}
+	fmt.Printf("Total trades: %s under %s, %s under %s\n", formatAmount(diff.OldVolume, true), oldName, formatAmount(diff.NewVolume, true), newName)
+	return 0
+}
+
+// configDiffCalc compares the targets of two configs and, given a portfolio,
+// the trades each makes on it. The portfolio is read under each config as
+// compare does, static positions and all. Symbols the new config drops, as a
+// stock or an alternative, are sold, and the proceeds deposited under the new
+// targets.
+func configDiffCalc(oldConfig, newConfig *Config, path string) (*ConfigDiff, error) {
+	diff := &ConfigDiff{Symbols: []TargetChange{}}
+	scenarios := []*Scenario{{Name: "old", Config: oldConfig}, {Name: "new", Config: newConfig}}
+	kept := make(map[string]bool)
+	for _, stock := range newConfig.Stocks {
+		kept[stock.Symbol] = true
+		for _, alt := range stock.Alternatives {
+			kept[alt] = true
+		}
+	}
+
+	var oldResult, newResult *RebalanceResult
+	if path != "" {
+		oldHoldings, err := readPortfolio(oldConfig, path)
+		if err != nil {
+			return nil, fmt.Errorf("old: %w", err)
+		}
+		newHoldings, err := readPortfolio(newConfig, path)
+		if err != nil {
+			return nil, fmt.Errorf("new: %w", err)
+		}
+		for _, stock := range oldConfig.Stocks {
+			if !kept[stock.Symbol] {
+				diff.Proceeds += oldHoldings.Amounts[stock.Symbol]
+			}
+		}
+		if oldResult, err = allocationCalc(oldConfig, oldHoldings, 0); err != nil {
+			return nil, fmt.Errorf("old: %w", err)
+		}
+		if newResult, err = allocationCalc(newConfig, newHoldings, diff.Proceeds); err != nil {
+			return nil, fmt.Errorf("new: %w", err)
+		}
+		diff.Warnings = newResult.Warnings
+	}
+
+	for _, symbol := range compareSymbols(scenarios) {
+		c := TargetChange{Symbol: symbol}
+		oldIndex := slices.IndexFunc(oldConfig.Stocks, func(s Stock) bool { return s.Symbol == symbol })
+		newIndex := slices.IndexFunc(newConfig.Stocks, func(s Stock) bool { return s.Symbol == symbol })
+		if oldIndex >= 0 {
+			c.OldTarget = oldConfig.Stocks[oldIndex].TargetPercentage
+		}
+		if newIndex >= 0 {
+			c.NewTarget = newConfig.Stocks[newIndex].TargetPercentage
+		}
+		switch {
+		case oldIndex < 0:
+			c.Status = "added"
+		case newIndex < 0:
+			c.Status = "removed"
+		case c.OldTarget != c.NewTarget:
+			c.Status = "changed"
+		default:
+			c.Status = "unchanged"
+		}
+		if path != "" {
+			if oldIndex >= 0 {
+				c.Amount = oldResult.Symbols[symbol].Amount
+				c.OldTrade = oldResult.Symbols[symbol].AmountNeeded
+			} else {
+				c.Amount = newResult.Symbols[symbol].Amount
+			}
+			if newIndex >= 0 {
+				c.NewTrade = newResult.Symbols[symbol].AmountNeeded
+			} else if !kept[symbol] {
+				c.NewTrade = -c.Amount
+			}
+			c.Change = c.NewTrade - c.OldTrade
+			diff.OldVolume += abs(c.OldTrade)
+			diff.NewVolume += abs(c.NewTrade)
+		}
+		diff.Symbols = append(diff.Symbols, c)
+	}
+	return diff, nil
+}
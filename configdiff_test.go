@@ -0,0 +1,78 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigDiffCalc(t *testing.T) {
+	oldConfig, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	newConfig, err := parseConfig(filepath.Join("tests", "configs", "proposed.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	portfolio := filepath.Join("tests", "portfolios", "cash.csv")
+
+	// Without a portfolio only the targets are compared
+	diff, err := configDiffCalc(oldConfig, newConfig, "")
+	if err != nil {
+		t.Fatalf("configDiffCalc failed: %v", err)
+	}
+	if len(diff.Symbols) != 4 || diff.Symbols[3].Status != "added" || diff.Symbols[0].Status != "changed" || diff.Symbols[0].NewTrade != 0 {
+		t.Errorf("Expected VNQ added and no trades, got %+v", diff.Symbols)
+	}
+
+	// The old targets match the portfolio, so every trade is the change's
+	diff, err = configDiffCalc(oldConfig, newConfig, portfolio)
+	if err != nil {
+		t.Fatalf("configDiffCalc failed: %v", err)
+	}
+	expected := map[string]int{"VTI": -1100000, "VXUS": 700000, "BND": -100000, "VNQ": 500000}
+	for _, c := range diff.Symbols {
+		if c.OldTrade != 0 || c.Change != expected[c.Symbol] {
+			t.Errorf("Expected %s to change by %d from no trade, got %+v", c.Symbol, expected[c.Symbol], c)
+		}
+	}
+	if diff.OldVolume != 0 || diff.NewVolume != 2400000 {
+		t.Errorf("Expected volumes 0 and 2400000, got %d and %d", diff.OldVolume, diff.NewVolume)
+	}
+
+	// Dropping BND sells all of it into the remaining targets
+	newConfig.Stocks = []Stock{{Symbol: "VTI", TargetPercentage: 80}, {Symbol: "VXUS", TargetPercentage: 20}}
+	diff, err = configDiffCalc(oldConfig, newConfig, portfolio)
+	if err != nil {
+		t.Fatalf("configDiffCalc failed: %v", err)
+	}
+	if diff.Proceeds != 1100000 {
+		t.Errorf("Expected proceeds of 1100000, got %d", diff.Proceeds)
+	}
+	expected = map[string]int{"VTI": 900000, "VXUS": 200000, "BND": -1100000}
+	for _, c := range diff.Symbols {
+		if c.NewTrade != expected[c.Symbol] {
+			t.Errorf("Expected %s to trade %d, got %+v", c.Symbol, expected[c.Symbol], c)
+		}
+	}
+	if diff.Symbols[2].Status != "removed" {
+		t.Errorf("Expected BND removed, got %q", diff.Symbols[2].Status)
+	}
+
+	// Static positions are part of the portfolio under each config, as they
+	// are when rebalancing
+	newConfig.Stocks = []Stock{{Symbol: "VTI", TargetPercentage: 70}, {Symbol: "VXUS", TargetPercentage: 20}, {Symbol: "BND", TargetPercentage: 10}}
+	static := []StaticPosition{{Symbol: "BND", Value: 1000000, Account: "Credit Union"}}
+	oldConfig.StaticPositions, newConfig.StaticPositions = static, static
+	diff, err = configDiffCalc(oldConfig, newConfig, portfolio)
+	if err != nil {
+		t.Fatalf("configDiffCalc failed: %v", err)
+	}
+	expectedOld := map[string]int{"VTI": 710000, "VXUS": 180000, "BND": -890000}
+	expectedNew := map[string]int{"VTI": 600000, "VXUS": 400000, "BND": -1000000}
+	for _, c := range diff.Symbols {
+		if c.OldTrade != expectedOld[c.Symbol] || c.NewTrade != expectedNew[c.Symbol] {
+			t.Errorf("Expected %s to trade %d then %d, got %+v", c.Symbol, expectedOld[c.Symbol], expectedNew[c.Symbol], c)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configDocument is a config file parsed into a yaml.Node tree for editing.
+// Commands that change the config edit the tree and render it, so that the
+// user's comments, anchors, key order, and quoting survive the edit.
+type configDocument struct {
+	path   string
+	source []byte
+	doc    yaml.Node
+}
+
+func readConfigDocument(path string) (*configDocument, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &CodedError{Code: CodeConfigInvalid, Err: err}
+	}
+	// Editing would write the config back in plaintext
+	if tool := encryption(path, source); tool != "" {
+		return nil, codedErrorf(CodeConfigInvalid, "%s is encrypted with %s; decrypt it to edit it, then encrypt it again", path, tool)
+	}
+	d := &configDocument{path: path, source: source}
+	if err := yaml.Unmarshal(source, &d.doc); err != nil {
+		return nil, &CodedError{Code: CodeConfigInvalid, Err: err}
+	}
+	// An empty file is edited as an empty mapping
+	if d.doc.Kind == 0 {
+		d.doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	return d, nil
+}
+
+// root is the config's top-level mapping
+func (d *configDocument) root() (*yaml.Node, error) {
+	if len(d.doc.Content) == 0 || d.doc.Content[0].Kind != yaml.MappingNode {
+		return nil, codedErrorf(CodeConfigInvalid, "config is not a mapping")
+	}
+	return d.doc.Content[0], nil
+}
+
+// render encodes the edited tree in the source's indentation, puts back the
+// blank lines and document marker the encoder drops, and validates the
+// result, which is returned only if it is a valid config
+func (d *configDocument) render() ([]byte, error) {
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(sourceIndent(d.source))
+	if err := encoder.Encode(&d.doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	rendered := restoreBlankLines(d.source, out.Bytes())
+	if bytes.HasPrefix(d.source, []byte("---\n")) || bytes.HasPrefix(d.source, []byte("---\r\n")) {
+		rendered = append([]byte("---\n"), rendered...)
+	}
+	if _, err := parseConfigData(rendered); err != nil {
+		return nil, fmt.Errorf("edited config is invalid: %w", err)
+	}
+	return rendered, nil
+}
+
+// sourceIndent is the narrowest indentation in source, which the encoder
+// uses for every level. Sequences are always indented under their key.
+func sourceIndent(source []byte) int {
+	indent := 0
+	for _, line := range strings.Split(string(source), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if n := len(line) - len(trimmed); n > 0 && (indent == 0 || n < indent) {
+			indent = n
+		}
+	}
+	if indent < 2 || indent > 9 {
+		return 2
+	}
+	return indent
+}
+
+// restoreBlankLines puts the blank lines of source back into rendered, which
+// the encoder writes without any. The lines of the two are aligned, ignoring
+// whitespace, and each blank run goes back before the line that followed it
+// in source. Lines with the same key align even if their values were edited,
+// though less readily than identical lines.
+func restoreBlankLines(source, rendered []byte) []byte {
+	normalize := func(lines []string) []string {
+		normalized := make([]string, len(lines))
+		for i, line := range lines {
+			normalized[i] = strings.Join(strings.Fields(line), " ")
+		}
+		return normalized
+	}
+	sourceLines := strings.Split(strings.ReplaceAll(string(source), "\r\n", "\n"), "\n")
+	renderedLines := strings.Split(strings.TrimSuffix(string(rendered), "\n"), "\n")
+	a, b := normalize(sourceLines), normalize(renderedLines)
+	similarity := func(i, j int) int {
+		switch {
+		case a[i] == "" || b[j] == "":
+			return 0
+		case a[i] == b[j]:
+			return 2
+		}
+		key, _, found := strings.Cut(a[i], ": ")
+		if found && strings.HasPrefix(b[j], key+": ") {
+			return 1
+		}
+		return 0
+	}
+
+	// score[i][j] is the best total similarity of an alignment of a[i:] with
+	// b[j:], as in a longest common subsequence
+	score := make([][]int, len(a)+1)
+	for i := range score {
+		score[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			score[i][j] = max(score[i+1][j], score[i][j+1])
+			if s := similarity(i, j); s > 0 {
+				score[i][j] = max(score[i][j], score[i+1][j+1]+s)
+			}
+		}
+	}
+
+	blanks := make(map[int]int) // rendered line -> blank lines before it
+	for i, j, run := 0, 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == "" && b[j] == "":
+			// A blank line the encoder kept, as in a block scalar
+			run = 0
+			i++
+			j++
+		case b[j] == "":
+			run = 0
+			j++
+		case a[i] == "":
+			run++
+			i++
+		case similarity(i, j) > 0 && score[i][j] == score[i+1][j+1]+similarity(i, j):
+			if run > 0 && j > 0 {
+				blanks[j] = run
+			}
+			run = 0
+			i++
+			j++
+		case score[i+1][j] >= score[i][j+1]:
+			// A line only in source, such as one of a removed item,
+			// doesn't carry the blank lines before it to the next match
+			run = 0
+			i++
+		default:
+			j++
+		}
+	}
+
+	var out strings.Builder
+	for j, line := range renderedLines {
+		out.WriteString(strings.Repeat("\n", blanks[j]))
+		out.WriteString(line + "\n")
+	}
+	return []byte(out.String())
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue replaces the value of key in a mapping node, keeping the
+// comments of the value it replaces, or adds key at the end of the mapping
+func setMappingValue(node *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			old := node.Content[i+1]
+			value.HeadComment, value.LineComment, value.FootComment = old.HeadComment, old.LineComment, old.FootComment
+			node.Content[i+1] = value
+			return
+		}
+	}
+	node.Content = append(node.Content, stringNode(key), value)
+}
+
+func stringNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+// replaceFile writes data next to path and renames it over path, keeping the
+// file's permissions, so an interrupted write leaves the old file intact
+func replaceFile(path string, data []byte) error {
+	mode := fs.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	temp := path + ".tmp"
+	if err := os.WriteFile(temp, data, mode); err != nil {
+		os.Remove(temp)
+		return err
+	}
+	return os.Rename(temp, path)
+}
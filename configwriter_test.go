@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigDocumentRoundTrip(t *testing.T) {
+	config := `---
+# Household allocation
+
+stocks:
+    # Core holding, kept simple
+    - symbol: VTI
+      target_percentage: 60   # US total market
+      description: &total "Total market"
+      notes: |
+        Held in every account.
+
+        Never sold for rebalancing.
+
+    - symbol: BND
+      target_percentage: 40
+      description: *total
+      alternatives: [AGG, SCHZ]
+
+
+rounding: half-even
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := readConfigDocument(path)
+	if err != nil {
+		t.Fatalf("readConfigDocument failed: %v", err)
+	}
+	rendered, err := doc.render()
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	// Only the spacing before the line comment changes
+	want := `---
+# Household allocation
+
+stocks:
+    # Core holding, kept simple
+    - symbol: VTI
+      target_percentage: 60 # US total market
+      description: &total "Total market"
+      notes: |
+        Held in every account.
+
+        Never sold for rebalancing.
+
+    - symbol: BND
+      target_percentage: 40
+      description: *total
+      alternatives: [AGG, SCHZ]
+
+
+rounding: half-even
+`
+	if string(rendered) != want {
+		t.Errorf("Round trip mismatch:\n%s\nwant:\n%s", rendered, want)
+	}
+
+	// Edits keep the comments on what they replace, and a removed item
+	// takes the blank line before it along
+	root, err := doc.root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stocks := mappingValue(root, "stocks")
+	setMappingValue(stocks.Content[0], "target_percentage", percentageNode(100))
+	stocks.Content = stocks.Content[:1]
+	setMappingValue(root, "rounding", stringNode("floor"))
+	setMappingValue(root, "snapshots", stringNode("snapshots.jsonl"))
+	if rendered, err = doc.render(); err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	want = `---
+# Household allocation
+
+stocks:
+    # Core holding, kept simple
+    - symbol: VTI
+      target_percentage: 100 # US total market
+      description: &total "Total market"
+      notes: |
+        Held in every account.
+
+        Never sold for rebalancing.
+
+
+rounding: floor
+snapshots: snapshots.jsonl
+`
+	if string(rendered) != want {
+		t.Errorf("Edit mismatch:\n%s\nwant:\n%s", rendered, want)
+	}
+
+	// An edit that breaks the config isn't rendered
+	setMappingValue(stocks.Content[0], "target_percentage", percentageNode(90))
+	if _, err := doc.render(); errorCode(err) != CodeConfigSum {
+		t.Errorf("Expected %s, got %v", CodeConfigSum, err)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// assumeYes goes ahead with writes and submissions without asking. Set by
+// the -yes flag.
+var assumeYes bool
+
+// dryRun prints what writes and submissions would do instead of doing them.
+// Set by the -dryRun flag.
+var dryRun bool
+
+// confirmInput is read for answers instead of stdin, in tests
+var confirmInput io.Reader
+
+// previewLines is how much of a file's new contents an overwrite shows
+const previewLines = 10
+
+// confirm shows preview, exactly what action will write or submit, on stderr
+// and asks whether to go ahead. With -yes it goes ahead without asking.
+// Without a terminal to ask on, it refuses, so scripts must pass -yes.
+func confirm(action, preview string) error {
+	if assumeYes {
+		return nil
+	}
+	input := confirmInput
+	if input == nil {
+		if !isTerminal(os.Stdin) {
+			return codedErrorf(CodeUsage, "%s needs confirmation; pass -yes to go ahead without asking", action)
+		}
+		input = os.Stdin
+	}
+	fmt.Fprint(os.Stderr, strings.TrimRight(preview, "\n")+"\n")
+	fmt.Fprintf(os.Stderr, "%s? [y/N] ", action)
+	answer, err := bufio.NewReader(input).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return codedErrorf(CodeCanceled, "%s: not confirmed", action)
+}
+
+// skipDryRun reports whether action, which writes a file or sends to another
+// service, must be skipped because of -dryRun. It then prints preview and the
+// action on stderr instead. Anything with a side effect checks it first,
+// before asking for confirmation.
+func skipDryRun(action, preview string) bool {
+	if !dryRun {
+		return false
+	}
+	if preview != "" {
+		fmt.Fprint(os.Stderr, strings.TrimRight(preview, "\n")+"\n")
+	}
+	fmt.Fprintf(os.Stderr, "Dry run, skipped: %s\n", action)
+	return true
+}
+
+// mayConfirm reports whether a command may ask for confirmation, in which
+// case its output isn't paged so that the prompt stays visible
+func mayConfirm(command string, args []string) bool {
+	if assumeYes || dryRun {
+		return false
+	}
+	switch command {
+	case "sheets", "import", "target", "plan":
+		return true
+	case "snapshot":
+		return replacesSnapshots(args)
+	case "history":
+		if len(args) > 0 && args[0] == "import" {
+			return replacesSnapshots(args[1:])
+		}
+		return len(args) > 0 && args[0] == "prune"
+	case "holdings":
+		return len(args) > 0 && args[0] != "list"
+	}
+	_, ok := flagValue(args, "o")
+	return ok
+}
+
+// replacesSnapshots reports whether snapshot or history import args pass
+// -force, which asks before replacing a snapshot that's already recorded
+func replacesSnapshots(args []string) bool {
+	force, ok := flagValue(args, "force")
+	return ok && force != "false"
+}
+
+// writeOutput writes what write produces to path, or to stdout when path is
+// empty. Replacing an existing file needs confirmation, which previews the
+// start of the new contents; with -dryRun, only the preview is shown.
+func writeOutput(path string, write func(io.Writer) error) error {
+	if path == "" {
+		return write(os.Stdout)
+	}
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	lines := strings.SplitAfter(buf.String(), "\n")
+	preview := strings.Join(lines[:min(len(lines), previewLines)], "")
+	if len(lines) > previewLines {
+		preview += fmt.Sprintf("... (%d more lines)\n", len(lines)-previewLines)
+	}
+	info, err := os.Stat(path)
+	if skipDryRun("Write "+path, fmt.Sprintf("%s will get %d bytes:\n", path, buf.Len())+preview) {
+		return nil
+	}
+	if err == nil {
+		header := fmt.Sprintf("%s (%d bytes) will be replaced with %d bytes:\n", path, info.Size(), buf.Len())
+		if err := confirm("Overwrite "+path, header+preview); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func answer(t *testing.T, text string) {
+	t.Helper()
+	defer func(input io.Reader) { t.Cleanup(func() { confirmInput = input }) }(confirmInput)
+	confirmInput = strings.NewReader(text)
+}
+
+func TestConfirm(t *testing.T) {
+	answer(t, "Y\n")
+	if err := confirm("Prune snapshots.jsonl", "1 snapshot will be removed"); err != nil {
+		t.Errorf("Expected yes to confirm, got %v", err)
+	}
+	answer(t, "\n")
+	if err := confirm("Prune snapshots.jsonl", "1 snapshot will be removed"); errorCode(err) != CodeCanceled {
+		t.Errorf("Expected no answer to cancel, got %v", err)
+	}
+
+	// Without a terminal or -yes, nothing is written
+	confirmInput = nil
+	stdin := os.Stdin
+	defer func() { os.Stdin = stdin }()
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	os.Stdin = devNull
+	if err := confirm("Import activity.csv", ""); errorCode(err) != CodeUsage || !strings.Contains(err.Error(), "-yes") {
+		t.Errorf("Expected a request for -yes, got %v", err)
+	}
+	defer func(yes bool) { assumeYes = yes }(assumeYes)
+	assumeYes = true
+	if err := confirm("Import activity.csv", ""); err != nil {
+		t.Errorf("Expected -yes to confirm, got %v", err)
+	}
+}
+
+func TestWriteOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedule.csv")
+	write := func(contents string) func(io.Writer) error {
+		return func(w io.Writer) error {
+			_, err := io.WriteString(w, contents)
+			return err
+		}
+	}
+
+	// A new file is written without asking
+	answer(t, "")
+	if err := writeOutput(path, write("first\n")); err != nil {
+		t.Fatalf("writeOutput failed: %v", err)
+	}
+	answer(t, "n\n")
+	if err := writeOutput(path, write("second\n")); errorCode(err) != CodeCanceled {
+		t.Errorf("Expected the overwrite to be canceled, got %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "first\n" {
+		t.Errorf("Expected the file to be kept, got %q", data)
+	}
+	answer(t, "yes\n")
+	if err := writeOutput(path, write("second\n")); err != nil {
+		t.Fatalf("writeOutput failed: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "second\n" {
+		t.Errorf("Expected the file to be replaced, got %q", data)
+	}
+}
+
+func TestMayConfirm(t *testing.T) {
+	tests := []struct {
+		command  string
+		args     []string
+		expected bool
+	}{
+		{"sheets", []string{"push", "portfolio.csv"}, true},
+		{"history", []string{"prune", "-before", "2025-01-01"}, true},
+		{"history", []string{"show"}, false},
+		{"snapshot", []string{"portfolio.csv", "-force"}, true},
+		{"snapshot", []string{"portfolio.csv"}, false},
+		{"history", []string{"import", "portfolio.csv", "--force"}, true},
+		{"history", []string{"import", "portfolio.csv", "-force=false"}, false},
+		{"dca", []string{"12000", "-o", "schedule.ics"}, true},
+		{"rebalance", []string{"portfolio.csv"}, false},
+	}
+	for _, tt := range tests {
+		if actual := mayConfirm(tt.command, tt.args); actual != tt.expected {
+			t.Errorf("mayConfirm(%s, %v) = %v, expected %v", tt.command, tt.args, actual, tt.expected)
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	defer func(run bool) { dryRun = run }(dryRun)
+	dryRun = true
+	path := filepath.Join(t.TempDir(), "plan.json")
+
+	// Nothing is written, and nothing is asked
+	answer(t, "")
+	err := writeOutput(path, func(w io.Writer) error {
+		_, err := io.WriteString(w, "{}\n")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("writeOutput failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected %s not to be written, got %v", path, err)
+	}
+	if mayConfirm("plan", []string{"save"}) {
+		t.Error("Expected no confirmation in a dry run")
+	}
+
+	marker := filepath.Join(t.TempDir(), "ran")
+	config := &Config{Hooks: map[string]string{"post_rebalance": "touch " + marker}}
+	if err := runHook(context.Background(), config, "post_rebalance", &HookData{}); err != nil {
+		t.Fatalf("runHook failed: %v", err)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Errorf("Expected the hook not to run, got %v", err)
+	}
+}
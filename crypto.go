@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Transaction types of a Coinbase transaction history that add to or take
+// from an asset's balance. Others, such as moves between staking and the
+// main wallet, leave it alone.
+var (
+	coinbaseInflows  = []string{"Buy", "Advanced Trade Buy", "Receive", "Deposit", "Rewards Income", "Staking Income", "Inflation Reward", "Learning Reward", "Coinbase Earn"}
+	coinbaseOutflows = []string{"Sell", "Advanced Trade Sell", "Send", "Withdrawal"}
+)
+
+// coinbaseConvertPattern finds what a Convert row bought in its notes, e.g.
+// "Converted 0.5 ETH to 0.0213 BTC"
+var coinbaseConvertPattern = regexp.MustCompile(`Converted [0-9.,]+ \S+ to ([0-9.,]+) (\S+)`)
+
+// krakenAssets maps Kraken's legacy asset codes to the usual symbols
+var krakenAssets = map[string]string{
+	"XXBT": "BTC", "XBT": "BTC", "XETH": "ETH", "ETH2": "ETH", "XXDG": "DOGE", "XDG": "DOGE",
+	"XLTC": "LTC", "XXRP": "XRP", "XXLM": "XLM", "XXMR": "XMR", "XETC": "ETC", "XZEC": "ZEC",
+	"ZUSD": "USD", "ZEUR": "EUR", "ZGBP": "GBP", "ZCAD": "CAD", "ZJPY": "JPY",
+}
+
+// fiatCurrencies are the exchange balances that count as cash
+var fiatCurrencies = []string{"USD", "EUR", "GBP", "CAD", "JPY", "CHF", "AUD"}
+
+// cryptoBalance is how much of an asset an exchange holds
+type cryptoBalance struct {
+	Asset    string
+	Quantity float64
+}
+
+// cryptoReader returns the reader for a crypto exchange export, recognized
+// by its header, or nil if br holds something else
+func cryptoReader(br *bufio.Reader) func(*Config, io.Reader) (*Holdings, error) {
+	start, _ := br.Peek(4096)
+	start = bytes.ToLower(start)
+	switch {
+	case bytes.Contains(start, []byte("transaction type")) && bytes.Contains(start, []byte("quantity transacted")):
+		return readCoinbase
+	case bytes.Contains(start, []byte("txid")) && bytes.Contains(start, []byte("refid")) && bytes.Contains(start, []byte("balance")):
+		return readKraken
+	}
+	return nil
+}
+
+// readCoinbase totals the quantities of a Coinbase transaction history. Rows
+// in the price currency, such as USD deposits, are left out: the history
+// doesn't say enough to follow the cash balance.
+func readCoinbase(config *Config, r io.Reader) (*Holdings, error) {
+	reader := newCSVReader(r)
+	var header []string
+	for line := 0; !slices.Contains(header, "Quantity Transacted"); line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) || line == maxPreambleLines {
+			return nil, codedErrorf(CodeCSVHeader, "Coinbase history must have 'Transaction Type', 'Asset', and 'Quantity Transacted' columns")
+		} else if err != nil {
+			return nil, fmt.Errorf("error reading header: %w", err)
+		}
+		header = normalizeHeader(record)
+	}
+	typeIndex := slices.Index(header, "Transaction Type")
+	assetIndex := slices.Index(header, "Asset")
+	quantityIndex := slices.Index(header, "Quantity Transacted")
+	currencyIndex := indexOfAny(header, "Price Currency", "Spot Price Currency")
+	notesIndex := slices.Index(header, "Notes")
+	if typeIndex == -1 || assetIndex == -1 {
+		return nil, codedErrorf(CodeCSVHeader, "Coinbase history must have 'Transaction Type', 'Asset', and 'Quantity Transacted' columns")
+	}
+
+	quantities := make(map[string]float64)
+	var assets []string
+	add := func(asset string, quantity float64) {
+		if _, seen := quantities[asset]; !seen {
+			assets = append(assets, asset)
+		}
+		quantities[asset] += quantity
+	}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		asset, kind := field(record, assetIndex), field(record, typeIndex)
+		if asset == "" || asset == field(record, currencyIndex) {
+			continue
+		}
+		quantity, err := parseCryptoQuantity(field(record, quantityIndex))
+		if err != nil {
+			return nil, codedErrorf(CodeCSVValue, "error parsing quantity of %s: %w", asset, err)
+		}
+		switch {
+		case slices.Contains(coinbaseInflows, kind):
+			add(asset, quantity)
+		case slices.Contains(coinbaseOutflows, kind):
+			add(asset, -quantity)
+		case kind == "Convert":
+			add(asset, -quantity)
+			match := coinbaseConvertPattern.FindStringSubmatch(field(record, notesIndex))
+			if match == nil {
+				return nil, codedErrorf(CodeCSVValue, "can't tell what a conversion of %s bought from its notes %q", asset, field(record, notesIndex))
+			}
+			bought, err := parseCryptoQuantity(match[1])
+			if err != nil {
+				return nil, codedErrorf(CodeCSVValue, "error parsing conversion of %s: %w", asset, err)
+			}
+			add(match[2], bought)
+		}
+	}
+
+	var balances []cryptoBalance
+	for _, asset := range assets {
+		balances = append(balances, cryptoBalance{Asset: asset, Quantity: quantities[asset]})
+	}
+	return cryptoHoldings(config, "Coinbase", balances)
+}
+
+// readKraken takes the balance after the latest entry of each asset in each
+// wallet of a Kraken ledger
+func readKraken(config *Config, r io.Reader) (*Holdings, error) {
+	reader := newCSVReader(r)
+	record, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading header: %w", err)
+	}
+	header := normalizeHeader(record)
+	timeIndex := slices.Index(header, "time")
+	assetIndex := slices.Index(header, "asset")
+	balanceIndex := slices.Index(header, "balance")
+	walletIndex := slices.Index(header, "wallet")
+	if timeIndex == -1 || assetIndex == -1 || balanceIndex == -1 {
+		return nil, codedErrorf(CodeCSVHeader, "Kraken ledger must have 'time', 'asset', and 'balance' columns")
+	}
+
+	type entry struct {
+		balance float64
+		time    time.Time
+	}
+	latest := make(map[[2]string]entry)
+	var keys [][2]string
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		// Entries still being confirmed have no balance yet
+		if field(record, balanceIndex) == "" {
+			continue
+		}
+		at, err := parseCryptoTime(field(record, timeIndex))
+		if err != nil {
+			return nil, codedErrorf(CodeCSVValue, "error parsing time: %w", err)
+		}
+		balance, err := strconv.ParseFloat(strings.ReplaceAll(field(record, balanceIndex), ",", ""), 64)
+		if err != nil {
+			return nil, codedErrorf(CodeCSVValue, "error parsing balance: %w", err)
+		}
+		key := [2]string{krakenAsset(field(record, assetIndex)), field(record, walletIndex)}
+		previous, seen := latest[key]
+		if !seen {
+			keys = append(keys, key)
+		}
+		if !seen || !at.Before(previous.time) {
+			latest[key] = entry{balance, at}
+		}
+	}
+
+	quantities := make(map[string]float64)
+	var balances []cryptoBalance
+	for _, key := range keys {
+		if _, seen := quantities[key[0]]; !seen {
+			balances = append(balances, cryptoBalance{Asset: key[0]})
+		}
+		quantities[key[0]] += latest[key].balance
+	}
+	for i := range balances {
+		balances[i].Quantity = quantities[balances[i].Asset]
+	}
+	return cryptoHoldings(config, "Kraken", balances)
+}
+
+// krakenAsset turns a Kraken asset code into the usual symbol, dropping the
+// suffix of staked balances such as "DOT.S"
+func krakenAsset(asset string) string {
+	if i := strings.LastIndex(asset, "."); i > 0 {
+		asset = asset[:i]
+	}
+	if symbol, ok := krakenAssets[asset]; ok {
+		return symbol
+	}
+	return asset
+}
+
+func parseCryptoQuantity(value string) (float64, error) {
+	quantity, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(value), ",", ""), 64)
+	return math.Abs(quantity), err
+}
+
+// parseCryptoTime parses the times of Kraken's ledger, which are in UTC
+func parseCryptoTime(value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, time.DateTime} {
+		if at, err := time.Parse(layout, value); err == nil {
+			return at, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+// cryptoHoldings values the balances of configured assets with prices from
+// the quotes plugin, as exchange exports hold quantities without current
+// prices. Fiat balances count as cash, converted to USD.
+func cryptoHoldings(config *Config, account string, balances []cryptoBalance) (*Holdings, error) {
+	var positions []PluginPosition
+	var symbols []string
+	for _, balance := range balances {
+		if balance.Quantity == 0 {
+			continue
+		}
+		if slices.Contains(fiatCurrencies, balance.Asset) {
+			positions = append(positions, PluginPosition{Account: account, Symbol: "CASH", Value: Money(math.Round(balance.Quantity * 100)), Currency: balance.Asset})
+			continue
+		}
+		if _, found := config.primarySymbol(balance.Asset); found {
+			symbols = append(symbols, balance.Asset)
+		}
+	}
+
+	ctx := context.Background()
+	if len(symbols) > 0 {
+		name := config.pluginFor("quotes")
+		if name == "" {
+			return nil, codedErrorf(CodePlugin, "%s holdings of %s need a plugin that provides quotes to be valued", account, strings.Join(symbols, ", "))
+		}
+		response, err := runPlugin(ctx, config, name, PluginRequest{Type: "quotes", Symbols: symbols})
+		if err != nil {
+			return nil, err
+		}
+		for _, balance := range balances {
+			if !slices.Contains(symbols, balance.Asset) {
+				continue
+			}
+			price, ok := response.Quotes[balance.Asset]
+			if !ok || price <= 0 {
+				return nil, codedErrorf(CodePlugin, "plugin %s has no quote for %s", name, balance.Asset)
+			}
+			positions = append(positions, PluginPosition{
+				Account:  account,
+				Symbol:   balance.Asset,
+				Quantity: balance.Quantity,
+				Price:    Money(price.cents()),
+				Value:    Money(math.Round(balance.Quantity * float64(price) * 100)),
+			})
+		}
+	}
+
+	positions, err := convertCurrencies(ctx, config, positions)
+	if err != nil {
+		return nil, err
+	}
+	return readPositions(config, positions)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadCryptoExports(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "crypto.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	config.Plugins = map[string]PluginConfig{
+		"quotes": {Command: `cat > /dev/null; printf '{"quotes": {"BTC": "$60,000.00", "ETH": 3000.125}}'`, Provides: []string{"quotes"}},
+	}
+	tests := []struct {
+		file     string
+		btc, eth int
+		cash     map[string]int
+	}{
+		// 0.05 bought and 0.025 converted from ETH, less 0.005 sent; 0.5 ETH
+		// left after staking income, the conversion, and a sale. USD deposits
+		// and withdrawals aren't followed.
+		{"coinbase.csv", 420000, 150006, map[string]int{}},
+		// The latest balance of each wallet, staked ETH.S included; the
+		// unconfirmed deposit has no balance yet
+		{"kraken.csv", 300000, 300613, map[string]int{"Kraken": 58240}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			file, err := os.Open(filepath.Join("tests", "portfolios", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			br := bufio.NewReader(file)
+			read := cryptoReader(br)
+			if read == nil {
+				t.Fatalf("Expected %s to be recognized as a crypto export", tt.file)
+			}
+			holdings, err := read(config, br)
+			if err != nil {
+				t.Fatalf("Reading failed: %v", err)
+			}
+			if holdings.Amounts["BTC"] != tt.btc || holdings.Amounts["ETH"] != tt.eth {
+				t.Errorf("Amounts mismatch: %v", holdings.Amounts)
+			}
+			if len(holdings.Cash) != len(tt.cash) || holdings.Cash["Kraken"] != tt.cash["Kraken"] {
+				t.Errorf("Cash mismatch: %v", holdings.Cash)
+			}
+		})
+	}
+
+	// Without a quotes plugin there is nothing to value the coins at
+	config.Plugins = nil
+	file, err := os.Open(filepath.Join("tests", "portfolios", "kraken.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := readKraken(config, file); errorCode(err) != CodePlugin {
+		t.Errorf("Expected a plugin error, got %v", err)
+	}
+}
+
+func TestLoadPortfolioList(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "crypto.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	config.Plugins = map[string]PluginConfig{
+		"quotes": {Command: `cat > /dev/null; printf '{"quotes": {"BTC": 60000, "ETH": 3000, "VTI": 250}}'`, Provides: []string{"quotes"}},
+	}
+	portfolios := filepath.Join("tests", "portfolios", "balanced.csv") + "," + filepath.Join("tests", "portfolios", "kraken.csv")
+	holdings, err := loadPortfolio(config, portfolios)
+	if err != nil {
+		t.Fatalf("loadPortfolio failed: %v", err)
+	}
+	if holdings.Amounts["VTI"] != 7100000 || holdings.Amounts["BTC"] != 300000 || holdings.Cash["Kraken"] != 58240 {
+		t.Errorf("Merged holdings mismatch: %v, cash %v", holdings.Amounts, holdings.Cash)
+	}
+	if holdings.Prices["BTC"] != 6000000 || holdings.Prices["VTI"] != 25000 {
+		t.Errorf("Prices mismatch: %v", holdings.Prices)
+	}
+}
+
+func TestKrakenAsset(t *testing.T) {
+	for asset, expected := range map[string]string{"XXBT": "BTC", "XETH": "ETH", "ETH2.S": "ETH", "DOT.S": "DOT", "ZUSD": "USD", "SOL": "SOL"} {
+		if got := krakenAsset(asset); got != expected {
+			t.Errorf("krakenAsset(%q) = %q, expected %q", asset, got, expected)
+		}
+	}
+}
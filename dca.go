@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type DCATranche struct {
+	Date        time.Time      `json:"date"`
+	Amount      int            `json:"amount"`
+	Allocations map[string]int `json:"allocations"`
+}
+
+// dcaIntervals maps schedule names to the (months, days) between tranches
+var dcaIntervals = map[string][2]int{
+	"weekly":    {0, 7},
+	"biweekly":  {0, 14},
+	"monthly":   {1, 0},
+	"quarterly": {3, 0},
+}
+
+func dca(config *Config, args []string) {
+	var periods int
+	var portfolioCsv, startStr, interval, format, outputPath string
+	flagSet := flag.NewFlagSet("dca", flag.ExitOnError)
+	flagSet.IntVar(&periods, "periods", 6, "Number of tranches to split the amount into")
+	flagSet.StringVar(&portfolioCsv, "portfolio", "", "Portfolio CSV used to direct purchases toward underweight symbols")
+	flagSet.StringVar(&startStr, "start", time.Now().Format(time.DateOnly), "Date of the first tranche (YYYY-MM-DD)")
+	flagSet.StringVar(&interval, "interval", "monthly", "Time between tranches: weekly, biweekly, monthly, or quarterly")
+	flagSet.StringVar(&format, "format", "text", "Output format: text, csv, or ics")
+	flagSet.StringVar(&outputPath, "o", "", "Write the schedule to a file instead of stdout")
+	if len(args) < 1 {
+		flag.Usage()
+		return
+	}
+	amount, err := amountToInt(args[0])
+	if err != nil {
+		printError(codedErrorf(CodeUsage, "parsing amount: %w", err))
+		return
+	}
+	flagSet.Parse(args[1:])
+
+	start, err := time.Parse(time.DateOnly, startStr)
+	if err != nil {
+		printError(codedErrorf(CodeUsage, "parsing start date: %w", err))
+		return
+	}
+
+	holdings := make(map[string]int)
+	if portfolioCsv != "" {
+		portfolio, err := loadPortfolio(config, portfolioCsv)
+		if err != nil {
+			printError(err)
+			return
+		}
+		holdings = portfolio.Amounts
+	}
+
+	schedule, err := dcaCalc(config, holdings, amount, periods, start, interval)
+	if err != nil {
+		printError(err)
+		return
+	}
+
+	err = writeOutput(outputPath, func(out io.Writer) error {
+		switch format {
+		case "text":
+			writeDCAText(out, config, schedule)
+			return nil
+		case "csv":
+			return writeDCACSV(out, config, schedule)
+		case "ics":
+			return writeICS(out, dcaEvents(config, schedule))
+		}
+		return fmt.Errorf("unknown format %q", format)
+	})
+	if err != nil {
+		printError(err)
+	}
+}
+
+// dcaCalc splits amountCents into equal tranches (the last one absorbing any
+// remainder) and allocates each tranche with buy-only purchases, carrying the
+// simulated holdings forward so early tranches go to the most underweight symbols.
+func dcaCalc(config *Config, holdings map[string]int, amountCents, periods int, start time.Time, interval string) ([]DCATranche, error) {
+	if periods < 1 {
+		return nil, fmt.Errorf("periods must be at least 1, got %d", periods)
+	}
+	if amountCents <= 0 {
+		return nil, fmt.Errorf("amount must be positive, got %s", formatAmount(amountCents, true))
+	}
+	step, ok := dcaIntervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("unknown interval %q", interval)
+	}
+
+	current := make(map[string]int)
+	for symbol, amount := range holdings {
+		current[symbol] = amount
+	}
+
+	trancheAmount := amountCents / periods
+	schedule := make([]DCATranche, 0, periods)
+	for i := range periods {
+		amount := trancheAmount
+		if i == periods-1 {
+			amount = amountCents - trancheAmount*(periods-1)
+		}
+		allocations := buyOnlyCalc(config, current, amount)
+		for symbol, allocation := range allocations {
+			current[symbol] += allocation
+		}
+		schedule = append(schedule, DCATranche{
+			Date:        addMonths(start, step[0]*i).AddDate(0, 0, step[1]*i),
+			Amount:      amount,
+			Allocations: allocations,
+		})
+	}
+	return schedule, nil
+}
+
+// addMonths adds months to t, keeping to the last day of the month when t's
+// day is past its end, so that a schedule starting on the 31st stays at the
+// end of each month instead of spilling into the next (Jan 31 plus a month
+// is Feb 28, not Mar 3)
+func addMonths(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(t.Day(), last)-1)
+}
+
+func writeDCAText(w io.Writer, config *Config, schedule []DCATranche) {
+	for i, tranche := range schedule {
+		fmt.Fprintf(w, "\n%s - Tranche %d of %d: %s\n", tranche.Date.Format(time.DateOnly), i+1, len(schedule), formatAmount(tranche.Amount, true))
+		fmt.Fprintln(w, rule())
+		for _, stock := range config.Stocks {
+			fmt.Fprintf(w, "%s: %s\n", stock.Symbol, formatAmount(tranche.Allocations[stock.Symbol], true))
+		}
+	}
+}
+
+func writeDCACSV(w io.Writer, config *Config, schedule []DCATranche) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"Date", "Symbol", "Amount"})
+	for _, tranche := range schedule {
+		for _, stock := range config.Stocks {
+			cents := tranche.Allocations[stock.Symbol]
+			writer.Write([]string{tranche.Date.Format(time.DateOnly), stock.Symbol, strconv.FormatFloat(float64(cents)/100, 'f', 2, 64)})
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func dcaEvents(config *Config, schedule []DCATranche) []calendarEvent {
+	events := make([]calendarEvent, 0, len(schedule))
+	for i, tranche := range schedule {
+		var description []string
+		for _, stock := range config.Stocks {
+			description = append(description, fmt.Sprintf("%s: %s", stock.Symbol, formatAmount(tranche.Allocations[stock.Symbol], true)))
+		}
+		events = append(events, calendarEvent{
+			UID:         fmt.Sprintf("dca-%s-%d@fin-tilt", tranche.Date.Format("20060102"), i+1),
+			Date:        tranche.Date,
+			Summary:     fmt.Sprintf("Invest %s (DCA %d of %d)", formatAmount(tranche.Amount, true), i+1, len(schedule)),
+			Description: strings.Join(description, "\n"),
+		})
+	}
+	return events
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDCACalc(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	start := time.Date(2026, time.January, 15, 0, 0, 0, 0, time.UTC)
+
+	t.Run("no holdings splits by target", func(t *testing.T) {
+		schedule, err := dcaCalc(config, map[string]int{}, 1000001, 4, start, "quarterly")
+		if err != nil {
+			t.Fatalf("dcaCalc failed: %v", err)
+		}
+		if len(schedule) != 4 {
+			t.Fatalf("Tranche count mismatch: got %d, expected 4", len(schedule))
+		}
+		if schedule[0].Amount != 250000 || schedule[3].Amount != 250001 {
+			t.Errorf("Tranche amounts mismatch: got %d and %d", schedule[0].Amount, schedule[3].Amount)
+		}
+		if expected := time.Date(2026, time.October, 15, 0, 0, 0, 0, time.UTC); !schedule[3].Date.Equal(expected) {
+			t.Errorf("Last tranche date mismatch: got %s, expected %s", schedule[3].Date, expected)
+		}
+		if got := schedule[1].Allocations["VTI"]; got != 177500 {
+			t.Errorf("VTI allocation mismatch: got %d, expected 177500", got)
+		}
+	})
+
+	t.Run("underweight symbols are bought first", func(t *testing.T) {
+		holdings := map[string]int{"VTI": 8000000, "VXUS": 1200000, "BND": 800000}
+		schedule, err := dcaCalc(config, holdings, 1200000, 3, start, "monthly")
+		if err != nil {
+			t.Fatalf("dcaCalc failed: %v", err)
+		}
+		for i, tranche := range schedule {
+			if tranche.Allocations["VTI"] != 0 {
+				t.Errorf("Tranche %d: overweight VTI should not be bought, got %d", i+1, tranche.Allocations["VTI"])
+			}
+			if tranche.Allocations["VXUS"] <= tranche.Allocations["BND"] {
+				t.Errorf("Tranche %d: expected VXUS (most underweight) to receive more than BND", i+1)
+			}
+		}
+	})
+
+	t.Run("month-end start stays at month end", func(t *testing.T) {
+		start := time.Date(2026, time.January, 31, 0, 0, 0, 0, time.UTC)
+		schedule, err := dcaCalc(config, map[string]int{}, 400000, 4, start, "monthly")
+		if err != nil {
+			t.Fatalf("dcaCalc failed: %v", err)
+		}
+		expected := []string{"2026-01-31", "2026-02-28", "2026-03-31", "2026-04-30"}
+		for i, tranche := range schedule {
+			if got := tranche.Date.Format(time.DateOnly); got != expected[i] {
+				t.Errorf("Tranche %d date mismatch: got %s, expected %s", i+1, got, expected[i])
+			}
+		}
+	})
+
+	t.Run("invalid interval", func(t *testing.T) {
+		if _, err := dcaCalc(config, nil, 100000, 2, start, "daily"); err == nil {
+			t.Error("Expected error for unknown interval")
+		}
+	})
+}
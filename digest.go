@@ -0,0 +1,295 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// digestNotableDrift is how far, in percentage points, a symbol's drift
+	// must move in a week for the digest to call it out
+	digestNotableDrift = 0.5
+	// digestReminderDays is how far ahead the digest lists reminders
+	digestReminderDays = 28
+)
+
+// Digest is a summary of the portfolio to send weekly by email or Slack
+type Digest struct {
+	AsOf  time.Time `json:"as_of"`
+	Total int       `json:"total"`
+	// Status is each symbol's drift and the chance of a breach by the next
+	// check, as advise gives them
+	Status *Advice `json:"status"`
+	// Trend is the drift at each snapshot of the last weeks, ending now
+	Trend *DriftHistory `json:"trend"`
+	// Since is the date of the snapshot the changes are measured from: the
+	// latest a week or more before AsOf. Without one there are no changes.
+	Since       *time.Time     `json:"since,omitempty"`
+	TotalBefore int            `json:"total_before,omitempty"`
+	Changes     []DigestChange `json:"changes"`
+	// Reminders are the checks of the next four weeks, or the next one
+	Reminders []Reminder `json:"reminders"`
+}
+
+// DigestChange is a notable move in a symbol's drift since the week before
+type DigestChange struct {
+	Symbol      string  `json:"symbol"`
+	DriftBefore float64 `json:"drift_before"`
+	DriftAfter  float64 `json:"drift_after"`
+	// Crossed is outside when the drift moved past the band and inside when
+	// it came back
+	Crossed string `json:"crossed,omitempty"`
+}
+
+func digest(config *Config, args []string) {
+	var format, outputPath string
+	var weeks int
+	flagSet := flag.NewFlagSet("digest", flag.ExitOnError)
+	flagSet.IntVar(&weeks, "weeks", 8, "Weeks of snapshots to show the drift trend over")
+	flagSet.StringVar(&format, "format", "text", "Output format: text, html, or json")
+	flagSet.StringVar(&outputPath, "o", "", "Write the digest to a file instead of stdout")
+	if len(args) < 1 {
+		flag.Usage()
+		return
+	}
+	portfolioCsv := args[0]
+	flagSet.Parse(args[1:])
+	if format != "text" && format != "html" && format != "json" {
+		printError(codedErrorf(CodeUsage, "unknown format %q", format))
+		return
+	}
+	if weeks < 1 {
+		printError(codedErrorf(CodeUsage, "-weeks must be at least 1"))
+		return
+	}
+
+	holdings, err := loadPortfolio(config, portfolioCsv)
+	if err != nil {
+		printError(err)
+		return
+	}
+	var snapshots []Snapshot
+	if config.Snapshots != "" {
+		if snapshots, err = readSnapshots(config.Snapshots); err != nil {
+			printError(err)
+			return
+		}
+	}
+	d, err := digestCalc(config, holdings, snapshots, time.Now().Truncate(24*time.Hour), weeks)
+	if err != nil {
+		printError(err)
+		return
+	}
+
+	if format == "json" {
+		if err := writeJSON(os.Stdout, d); err != nil {
+			printError(err)
+		}
+		return
+	}
+	err = writeOutput(outputPath, func(w io.Writer) error {
+		if format == "html" {
+			return writeDigestHTML(w, d)
+		}
+		return writeDigestText(w, d)
+	})
+	if err != nil {
+		printError(err)
+	}
+}
+
+// digestCalc gathers the digest of holdings as of now: the status advise
+// gives, the drift at each snapshot of the last weeks and at the holdings,
+// the moves in drift since a week before, and the coming reminders
+func digestCalc(config *Config, holdings *Holdings, snapshots []Snapshot, now time.Time, weeks int) (*Digest, error) {
+	current := newSnapshot(holdings)
+	if current.AsOf.IsZero() {
+		current.AsOf = now
+	}
+	d := &Digest{AsOf: current.AsOf, Total: current.Total, Changes: []DigestChange{}}
+	d.Status = adviseCalc(config, holdings, now, portfolioReminders(config, holdings, now, 1)[0])
+
+	// The trend ends at the holdings, which replace a snapshot of the same
+	// export
+	start := current.AsOf.AddDate(0, 0, -7*weeks)
+	weekAgo := current.AsOf.AddDate(0, 0, -7)
+	var window []Snapshot
+	var base *Snapshot
+	for _, s := range snapshots {
+		if current.sameExport(s) || s.AsOf.After(current.AsOf) {
+			continue
+		}
+		if !s.AsOf.Before(start) {
+			window = append(window, s)
+		}
+		if !s.AsOf.After(weekAgo) && (base == nil || s.AsOf.After(base.AsOf)) {
+			base = &s
+		}
+	}
+	d.Trend = driftHistoryCalc(config, append(window, *current))
+
+	if base != nil {
+		compared := driftHistoryCalc(config, []Snapshot{*base, *current})
+		if len(compared.Dates) == 2 {
+			d.Since, d.TotalBefore = &base.AsOf, base.Total
+			for _, s := range compared.Symbols {
+				change := DigestChange{Symbol: s.Symbol, DriftBefore: s.Drift[0], DriftAfter: s.Drift[1]}
+				wasOutside, isOutside := math.Abs(change.DriftBefore) > s.Band, math.Abs(change.DriftAfter) > s.Band
+				switch {
+				case isOutside && !wasOutside:
+					change.Crossed = "outside"
+				case wasOutside && !isOutside:
+					change.Crossed = "inside"
+				}
+				if change.Crossed != "" || math.Abs(change.DriftAfter-change.DriftBefore) >= digestNotableDrift {
+					d.Changes = append(d.Changes, change)
+				}
+			}
+		}
+	}
+
+	reminders, err := remindCalc(config, holdings, now, 4)
+	if err != nil {
+		return nil, err
+	}
+	horizon := now.AddDate(0, 0, digestReminderDays)
+	for i, reminder := range reminders {
+		if i > 0 && reminder.Date.After(horizon) {
+			break
+		}
+		d.Reminders = append(d.Reminders, reminder)
+	}
+	return d, nil
+}
+
+// totalChange describes the change in the total since the week before
+func (d *Digest) totalChange() string {
+	change := d.Total - d.TotalBefore
+	text := formatAmount(change, true)
+	if change >= 0 {
+		text = "+" + text
+	}
+	if d.TotalBefore != 0 {
+		text += fmt.Sprintf(", %+.2f%%", float64(change)/float64(d.TotalBefore)*100)
+	}
+	return fmt.Sprintf("%s -> %s (%s)", formatAmount(d.TotalBefore, true), formatAmount(d.Total, true), text)
+}
+
+// statusSummary says whether the portfolio needs a trade before the next check
+func (d *Digest) statusSummary() string {
+	advice := d.Status
+	switch {
+	case len(advice.Outside) > 0:
+		return fmt.Sprintf("Outside the %s: %s; rebalance now", advice.Band, strings.Join(advice.Outside, ", "))
+	case advice.ActNow:
+		return fmt.Sprintf("A breach of the %s before the next check on %s is likely (%.0f%%)", advice.Band, advice.NextCheck.Format(time.DateOnly), advice.BreachChance*100)
+	}
+	return fmt.Sprintf("Every symbol is within the %s; a breach before the next check on %s is unlikely (%.0f%%)", advice.Band, advice.NextCheck.Format(time.DateOnly), advice.BreachChance*100)
+}
+
+// text describes the change, as the digest lists it
+func (c DigestChange) text() string {
+	text := fmt.Sprintf("%s drift %+.2f -> %+.2f", c.Symbol, c.DriftBefore, c.DriftAfter)
+	switch c.Crossed {
+	case "outside":
+		text += ", now outside its band"
+	case "inside":
+		text += ", back inside its band"
+	}
+	return text
+}
+
+// writeDigestText writes the digest as plain text, without color, so that it
+// reads the same in an email or a Slack message
+func writeDigestText(w io.Writer, d *Digest) error {
+	fmt.Fprintf(w, "Portfolio digest, %s\n\n", d.AsOf.Format(time.DateOnly))
+	fmt.Fprintf(w, "Status: %s\n", formatAmount(d.Total, true))
+	for _, s := range d.Status.Symbols {
+		fmt.Fprintf(w, "  %-6s %6.2f%% of %6.2f%%  drift %+6.2f  band %.2f\n", s.Symbol, s.CurrentPercentage, s.TargetPercentage, s.Drift, s.Band)
+	}
+	fmt.Fprintln(w, d.statusSummary())
+
+	if count := len(d.Trend.Dates); count > 0 {
+		fmt.Fprintf(w, "\nDrift trend since %s:\n", d.Trend.Dates[0].Format(time.DateOnly))
+		for _, s := range d.Trend.Symbols {
+			limit := max(s.Band, s.Largest)
+			fmt.Fprintf(w, "  %-6s %s %+6.2f\n", s.Symbol, sparkline(s.Drift, -limit, limit), s.Drift[count-1])
+		}
+	}
+
+	if d.Since == nil {
+		fmt.Fprintln(w, "\nNo snapshot from a week or more ago to compare with")
+	} else {
+		fmt.Fprintf(w, "\nSince %s: %s\n", d.Since.Format(time.DateOnly), d.totalChange())
+		for _, change := range d.Changes {
+			fmt.Fprintf(w, "  %s\n", change.text())
+		}
+		if len(d.Changes) == 0 {
+			fmt.Fprintln(w, "  No notable changes in drift")
+		}
+	}
+
+	fmt.Fprintln(w, "\nComing up:")
+	for _, reminder := range d.Reminders {
+		fmt.Fprintf(w, "  %s  %s\n", reminder.Date.Format(time.DateOnly), reminder.Reason)
+	}
+	return nil
+}
+
+// writeDigestHTML writes the digest as an HTML message with inline styles,
+// which email clients keep
+func writeDigestHTML(w io.Writer, d *Digest) error {
+	esc := html.EscapeString
+	title := "Portfolio digest, " + d.AsOf.Format(time.DateOnly)
+	fmt.Fprintln(w, `<!DOCTYPE html>`)
+	fmt.Fprintln(w, `<html><head><meta charset="utf-8">`)
+	writeHTMLManifest(w)
+	fmt.Fprintf(w, "<title>%s</title></head>\n", esc(title))
+	fmt.Fprintf(w, "<body style=\"font-family: sans-serif\">\n<h1>%s</h1>\n", esc(title))
+
+	fmt.Fprintf(w, "<h2>Status: %s</h2>\n<table>\n", esc(formatAmount(d.Total, true)))
+	fmt.Fprintln(w, `<tr><th align="left">Symbol</th><th align="right">Current</th><th align="right">Target</th><th align="right">Drift</th><th align="right">Band</th></tr>`)
+	for _, s := range d.Status.Symbols {
+		style := ""
+		if math.Abs(s.Drift) > s.Band {
+			style = ` style="color: #c00"`
+		}
+		fmt.Fprintf(w, "<tr%s><td>%s</td><td align=\"right\">%.2f%%</td><td align=\"right\">%.2f%%</td><td align=\"right\">%+.2f</td><td align=\"right\">%.2f</td></tr>\n", style, esc(s.Symbol), s.CurrentPercentage, s.TargetPercentage, s.Drift, s.Band)
+	}
+	fmt.Fprintf(w, "</table>\n<p>%s</p>\n", esc(d.statusSummary()))
+
+	if count := len(d.Trend.Dates); count > 0 {
+		fmt.Fprintf(w, "<h2>Drift trend since %s</h2>\n<table style=\"font-family: monospace\">\n", d.Trend.Dates[0].Format(time.DateOnly))
+		for _, s := range d.Trend.Symbols {
+			limit := max(s.Band, s.Largest)
+			fmt.Fprintf(w, "<tr><td>%s</td><td>%s</td><td align=\"right\">%+.2f</td></tr>\n", esc(s.Symbol), esc(sparkline(s.Drift, -limit, limit)), s.Drift[count-1])
+		}
+		fmt.Fprintln(w, "</table>")
+	}
+
+	if d.Since == nil {
+		fmt.Fprintln(w, "<h2>This week</h2>\n<p>No snapshot from a week or more ago to compare with</p>")
+	} else {
+		fmt.Fprintf(w, "<h2>Since %s</h2>\n<p>%s</p>\n<ul>\n", d.Since.Format(time.DateOnly), esc(d.totalChange()))
+		for _, change := range d.Changes {
+			fmt.Fprintf(w, "<li>%s</li>\n", esc(change.text()))
+		}
+		if len(d.Changes) == 0 {
+			fmt.Fprintln(w, "<li>No notable changes in drift</li>")
+		}
+		fmt.Fprintln(w, "</ul>")
+	}
+
+	fmt.Fprintln(w, "<h2>Coming up</h2>\n<ul>")
+	for _, reminder := range d.Reminders {
+		fmt.Fprintf(w, "<li>%s: %s</li>\n", reminder.Date.Format(time.DateOnly), esc(reminder.Reason))
+	}
+	_, err := fmt.Fprintln(w, "</ul>\n</body></html>")
+	return err
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDigest(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	holdings := loadHoldings(t, config, "cash.csv")
+	holdings.AsOf = time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC)
+	// VTI 71% against 65%, and BND 11% against 17%
+	config.Stocks[0].TargetPercentage = 65
+	config.Stocks[2].TargetPercentage = 17
+
+	onTarget := map[string]int{"VTI": 6500000, "VXUS": 1800000, "BND": 1700000}
+	snapshots := []Snapshot{
+		{AsOf: time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC), Total: 9000000, Amounts: onTarget},
+		{AsOf: time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC), Total: 10000000, Amounts: onTarget},
+		{AsOf: time.Date(2026, 3, 12, 0, 0, 0, 0, time.UTC), Total: 10100000, Amounts: map[string]int{"VTI": 6900000, "VXUS": 1800000, "BND": 1300000}},
+		{AsOf: time.Date(2026, 3, 17, 0, 0, 0, 0, time.UTC), Total: 10400000, Amounts: map[string]int{"VTI": 7000000, "VXUS": 1800000, "BND": 1200000}},
+	}
+	now := time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC)
+	d, err := digestCalc(config, holdings, snapshots, now, 8)
+	if err != nil {
+		t.Fatalf("digestCalc failed: %v", err)
+	}
+
+	if d.Total != 10550000 || len(d.Status.Outside) != 2 {
+		t.Errorf("Expected VTI and BND outside the band, got %+v", d.Status)
+	}
+	if len(d.Trend.Dates) != 4 || !d.Trend.Dates[3].Equal(holdings.AsOf) {
+		t.Errorf("Expected the trend over the last 8 weeks' snapshots and the holdings, got %v", d.Trend.Dates)
+	}
+	if d.Since == nil || !d.Since.Equal(snapshots[2].AsOf) || d.TotalBefore != 10100000 {
+		t.Fatalf("Expected the changes since the latest snapshot a week old, got %v", d.Since)
+	}
+	if len(d.Changes) != 2 || d.Changes[0].Symbol != "VTI" || d.Changes[0].Crossed != "outside" || d.Changes[1].Symbol != "BND" {
+		t.Errorf("Expected VTI and BND to move outside the band and VXUS not to be notable, got %+v", d.Changes)
+	}
+	if len(d.Reminders) != 1 || !d.Reminders[0].Date.Equal(now.AddDate(0, 3, 0)) {
+		t.Errorf("Expected the next quarterly check, beyond four weeks, got %+v", d.Reminders)
+	}
+
+	var text bytes.Buffer
+	if err := writeDigestText(&text, d); err != nil {
+		t.Fatalf("writeDigestText failed: %v", err)
+	}
+	for _, want := range []string{
+		"Status: $105,500.00",
+		"Outside the 5-point band: VTI, BND; rebalance now",
+		"Since 2026-03-12: $101,000.00 -> $105,500.00 (+$4,500.00, +4.46%)",
+		"VTI drift +4.00 -> +6.00, now outside its band",
+		"2026-06-20  quarterly rebalance check",
+	} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("Expected the text digest to contain %q:\n%s", want, text.String())
+		}
+	}
+	if strings.Contains(text.String(), "\033[") {
+		t.Error("Expected the text digest without color")
+	}
+
+	var page bytes.Buffer
+	if err := writeDigestHTML(&page, d); err != nil {
+		t.Fatalf("writeDigestHTML failed: %v", err)
+	}
+	for _, want := range []string{"<h2>Status: $105,500.00</h2>", `<tr style="color: #c00"><td>VTI</td>`, "<li>VTI drift +4.00 -&gt; +6.00, now outside its band</li>"} {
+		if !strings.Contains(page.String(), want) {
+			t.Errorf("Expected the HTML digest to contain %q:\n%s", want, page.String())
+		}
+	}
+
+	// Without a snapshot a week old, there's nothing to compare
+	d, err = digestCalc(config, holdings, snapshots[3:], now, 8)
+	if err != nil {
+		t.Fatalf("digestCalc failed: %v", err)
+	}
+	if d.Since != nil || len(d.Changes) != 0 {
+		t.Errorf("Expected no changes without a snapshot a week old, got %+v", d.Changes)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// windows1252 maps the bytes 0x80-0x9F to their Unicode code points. The rest
+// of the upper half of Windows-1252 matches Latin-1 and maps to itself.
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// csvDelimiter is the field delimiter set with the -delimiter flag. Zero
+// detects the delimiter from the first line of each file.
+var csvDelimiter rune
+
+// Delimiters considered when detecting the delimiter of a file
+var csvDelimiters = []rune{',', '\t', ';', '|'}
+
+// newCSVReader returns a CSV reader that accepts the encodings brokers export:
+// UTF-8 with or without a BOM, UTF-16 (detected by BOM or NUL bytes), and
+// Windows-1252, detected line by line when a line isn't valid UTF-8. Fields
+// are split on csvDelimiter, or on the delimiter detected from the first line.
+func newCSVReader(r io.Reader) *csv.Reader {
+	br := bufio.NewReader(decodeText(r))
+	reader := csv.NewReader(br)
+	reader.FieldsPerRecord = -1 // Allow variable number of fields per record
+	reader.Comma = csvDelimiter
+	if reader.Comma == 0 {
+		reader.Comma = detectDelimiter(br)
+	}
+	return reader
+}
+
+// detectDelimiter picks the candidate delimiter that appears most often
+// outside quotes in the first line, defaulting to a comma
+func detectDelimiter(br *bufio.Reader) rune {
+	peeked, _ := br.Peek(4096)
+	if i := bytes.IndexByte(peeked, '\n'); i != -1 {
+		peeked = peeked[:i]
+	}
+	counts := make(map[rune]int)
+	quoted := false
+	for _, r := range string(peeked) {
+		if r == '"' {
+			quoted = !quoted
+		} else if !quoted {
+			counts[r]++
+		}
+	}
+	best := ','
+	for _, delimiter := range csvDelimiters {
+		if counts[delimiter] > counts[best] {
+			best = delimiter
+		}
+	}
+	return best
+}
+
+// parseDelimiter parses the -delimiter flag value
+func parseDelimiter(value string) (rune, error) {
+	switch value {
+	case "auto":
+		return 0, nil
+	case "tab", `\t`, "\t":
+		return '\t', nil
+	case "comma":
+		return ',', nil
+	case "semicolon":
+		return ';', nil
+	case "pipe":
+		return '|', nil
+	}
+	if r := []rune(value); len(r) == 1 && r[0] != '"' && r[0] != '\n' && r[0] != '\r' {
+		return r[0], nil
+	}
+	return 0, fmt.Errorf("invalid delimiter %q (expected a single character, tab, or auto)", value)
+}
+
+func decodeText(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	prefix, _ := br.Peek(4)
+	switch {
+	case bytes.HasPrefix(prefix, []byte{0xEF, 0xBB, 0xBF}):
+		br.Discard(3)
+	case bytes.HasPrefix(prefix, []byte{0xFF, 0xFE}):
+		br.Discard(2)
+		return &utf16Reader{r: br, bigEndian: false}
+	case bytes.HasPrefix(prefix, []byte{0xFE, 0xFF}):
+		br.Discard(2)
+		return &utf16Reader{r: br, bigEndian: true}
+	case len(prefix) == 4 && prefix[1] == 0 && prefix[3] == 0 && prefix[0] != 0:
+		return &utf16Reader{r: br, bigEndian: false}
+	case len(prefix) == 4 && prefix[0] == 0 && prefix[2] == 0 && prefix[1] != 0:
+		return &utf16Reader{r: br, bigEndian: true}
+	}
+	return &windows1252Reader{r: br}
+}
+
+// utf16Reader transcodes UTF-16 input to UTF-8
+type utf16Reader struct {
+	r         *bufio.Reader
+	bigEndian bool
+	buf       []byte
+	err       error
+}
+
+func (u *utf16Reader) Read(p []byte) (int, error) {
+	for len(u.buf) < len(p) && u.err == nil {
+		unit, err := u.readUnit()
+		if err != nil {
+			u.err = err
+			break
+		}
+		r := rune(unit)
+		if utf16.IsSurrogate(r) {
+			low, err := u.readUnit()
+			if err != nil {
+				u.err = err
+				r = utf8.RuneError
+			} else {
+				r = utf16.DecodeRune(r, rune(low))
+			}
+		}
+		u.buf = utf8.AppendRune(u.buf, r)
+	}
+	n := copy(p, u.buf)
+	u.buf = u.buf[n:]
+	if n == 0 && u.err != nil {
+		return 0, u.err
+	}
+	return n, nil
+}
+
+func (u *utf16Reader) readUnit() (uint16, error) {
+	var b [2]byte
+	if _, err := io.ReadFull(u.r, b[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, io.EOF
+		}
+		return 0, err
+	}
+	if u.bigEndian {
+		return uint16(b[0])<<8 | uint16(b[1]), nil
+	}
+	return uint16(b[1])<<8 | uint16(b[0]), nil
+}
+
+// windows1252Reader passes valid UTF-8 lines through unchanged and decodes any
+// other line as Windows-1252
+type windows1252Reader struct {
+	r   *bufio.Reader
+	buf []byte
+	err error
+}
+
+func (w *windows1252Reader) Read(p []byte) (int, error) {
+	for len(w.buf) == 0 && w.err == nil {
+		line, err := w.r.ReadBytes('\n')
+		w.err = err
+		if utf8.Valid(line) {
+			w.buf = line
+			continue
+		}
+		decoded := make([]byte, 0, len(line)+8)
+		for _, b := range line {
+			switch {
+			case b < 0x80:
+				decoded = append(decoded, b)
+			case b < 0xA0:
+				decoded = utf8.AppendRune(decoded, windows1252[b-0x80])
+			default:
+				decoded = utf8.AppendRune(decoded, rune(b))
+			}
+		}
+		w.buf = decoded
+	}
+	n := copy(p, w.buf)
+	w.buf = w.buf[n:]
+	if n == 0 {
+		return 0, w.err
+	}
+	return n, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"unicode/utf16"
+)
+
+func encodeUTF16(str string, bigEndian, bom bool) []byte {
+	var buf bytes.Buffer
+	units := utf16.Encode([]rune(str))
+	if bom {
+		units = append([]uint16{0xFEFF}, units...)
+	}
+	for _, unit := range units {
+		if bigEndian {
+			buf.Write([]byte{byte(unit >> 8), byte(unit)})
+		} else {
+			buf.Write([]byte{byte(unit), byte(unit >> 8)})
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeText(t *testing.T) {
+	const csvText = "Symbol,Description,Current Value\nVTI,Vanguard Total Stock Market – ETF 📈,$71000.00\n"
+
+	tests := []struct {
+		name     string
+		input    []byte
+		expected string
+	}{
+		{"utf-8", []byte(csvText), csvText},
+		{"utf-8 bom", append([]byte{0xEF, 0xBB, 0xBF}, csvText...), csvText},
+		{"utf-16le bom", encodeUTF16(csvText, false, true), csvText},
+		{"utf-16be bom", encodeUTF16(csvText, true, true), csvText},
+		{"utf-16le without bom", encodeUTF16(csvText, false, false), csvText},
+		{
+			"windows-1252",
+			[]byte("Symbol,Description,Current Value\nVTI,Investor\x92s Caf\xe9 \x96 ETF,$1.00\n"),
+			"Symbol,Description,Current Value\nVTI,Investor’s Café – ETF,$1.00\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, err := io.ReadAll(decodeText(bytes.NewReader(tt.input)))
+			if err != nil {
+				t.Fatalf("decodeText failed: %v", err)
+			}
+			if string(decoded) != tt.expected {
+				t.Errorf("Decoded text mismatch:\ngot:      %q\nexpected: %q", decoded, tt.expected)
+			}
+		})
+	}
+}
+
+func TestReadHoldingsEncodings(t *testing.T) {
+	config := &Config{Stocks: []Stock{{Symbol: "VTI", TargetPercentage: 100}}}
+	input := encodeUTF16("\ufeffSymbol , Current Value\r\nVTI,$71000.00\r\n", false, false)
+	holdings, err := readHoldings(config, bytes.NewReader(input))
+	if err != nil {
+		t.Fatalf("readHoldings failed: %v", err)
+	}
+	if holdings.Amounts["VTI"] != 7100000 {
+		t.Errorf("VTI amount mismatch: got %d, expected 7100000", holdings.Amounts["VTI"])
+	}
+}
+
+func TestDelimiters(t *testing.T) {
+	detect := []struct {
+		header   string
+		expected rune
+	}{
+		{"Symbol,Current Value\n", ','},
+		{"Symbol\tCurrent Value\tDescription\n", '\t'},
+		{"Symbol;Current Value\n", ';'},
+		{"Symbol|\"Value, Current\"\n", '|'},
+		{"Symbol\n", ','},
+	}
+	for _, tt := range detect {
+		reader := newCSVReader(bytes.NewReader([]byte(tt.header)))
+		if reader.Comma != tt.expected {
+			t.Errorf("Detected delimiter for %q: got %q, expected %q", tt.header, reader.Comma, tt.expected)
+		}
+	}
+
+	parse := map[string]rune{"auto": 0, "tab": '\t', `\t`: '\t', ";": ';', "pipe": '|', "|": '|'}
+	for value, expected := range parse {
+		if actual, err := parseDelimiter(value); err != nil || actual != expected {
+			t.Errorf("parseDelimiter(%q): got %q (%v), expected %q", value, actual, err, expected)
+		}
+	}
+	if _, err := parseDelimiter(";;"); err == nil {
+		t.Error("Expected error for a multi-character delimiter")
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Encrypted configs are told apart by their headers: age's binary and
+// ASCII-armored ones, and GPG's armored one
+const (
+	ageHeader      = "age-encryption.org/v1\n"
+	ageArmorHeader = "-----BEGIN AGE ENCRYPTED FILE-----"
+	pgpArmorHeader = "-----BEGIN PGP MESSAGE-----"
+)
+
+// ageIdentityEnv names the age identity file to decrypt configs with
+const ageIdentityEnv = "FIN_TILT_AGE_IDENTITY"
+
+// stdinConfigPath is the -config value that reads the config from stdin
+const stdinConfigPath = "-"
+
+// ageCommand and gpgCommand are the programs that decrypt a config; tests
+// replace them
+var (
+	ageCommand = "age"
+	gpgCommand = "gpg"
+)
+
+// readStdin reads standard input once, so a config piped in can be parsed
+// again, as by household and lint
+var readStdin = sync.OnceValues(func() ([]byte, error) { return io.ReadAll(os.Stdin) })
+
+// readConfigFile reads the config at path, decrypting it if it is encrypted
+// with age or GPG. A path of - reads the config from standard input, for
+// one decrypted by another program and piped in.
+func readConfigFile(path string) ([]byte, error) {
+	var data []byte
+	var err error
+	if path == stdinConfigPath {
+		data, err = readStdin()
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, &CodedError{Code: CodeConfigInvalid, Err: err}
+	}
+	switch encryption(path, data) {
+	case "age":
+		var args []string
+		if identity := ageIdentity(); identity != "" {
+			args = append(args, "-i", identity)
+		}
+		return decryptConfig(ageCommand, append([]string{"--decrypt"}, args...), data)
+	case "gpg":
+		return decryptConfig(gpgCommand, []string{"--quiet", "--decrypt"}, data)
+	}
+	return data, nil
+}
+
+// encryption names the tool data was encrypted with, from its header or,
+// for a binary GPG file, its extension and the high bit every OpenPGP
+// packet tag sets. A plain config has none.
+func encryption(path string, data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte(ageHeader)), bytes.HasPrefix(data, []byte(ageArmorHeader)):
+		return "age"
+	case bytes.HasPrefix(data, []byte(pgpArmorHeader)):
+		return "gpg"
+	case len(data) > 0 && data[0]&0x80 != 0 && (strings.HasSuffix(path, ".gpg") || strings.HasSuffix(path, ".pgp")):
+		return "gpg"
+	}
+	return ""
+}
+
+// ageIdentity is the age identity file to decrypt with: the one named by
+// FIN_TILT_AGE_IDENTITY, or else age-identity.txt in the fin-tilt config
+// directory if it exists. Without one, age asks for the passphrase
+// of a passphrase-encrypted config.
+func ageIdentity() string {
+	if path := os.Getenv(ageIdentityEnv); path != "" {
+		return path
+	}
+	dirs, err := homeDirs()
+	if err != nil {
+		return ""
+	}
+	path := filepath.Join(dirs.Config, "age-identity.txt")
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// decryptConfig pipes data through the decrypting program. Its prompts and
+// errors go to stderr.
+func decryptConfig(command string, args []string, data []byte) ([]byte, error) {
+	var stdout bytes.Buffer
+	cmd := exec.Command(command, args...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, codedErrorf(CodeConfigInvalid, "config is encrypted; install %s to decrypt it, or decrypt it yourself and pipe it to -config -", command)
+		}
+		return nil, codedErrorf(CodeConfigInvalid, "decrypting config with %s: %w", command, err)
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	plain, err := os.ReadFile(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, content string, mode os.FileMode) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	// The fake age drops its header and records its arguments; the fake gpg
+	// drops the armor lines
+	argsPath := filepath.Join(dir, "args")
+	savedAge, savedGPG, savedStdin := ageCommand, gpgCommand, readStdin
+	t.Cleanup(func() { ageCommand, gpgCommand, readStdin = savedAge, savedGPG, savedStdin })
+	ageCommand = write("age", "#!/bin/sh\necho \"$@\" > "+argsPath+"\ntail -n +2\n", 0o755)
+	gpgCommand = write("gpg", "#!/bin/sh\nsed '1d;$d'\n", 0o755)
+	t.Setenv(ageIdentityEnv, "key.txt")
+
+	ageConfig := write("config.yaml.age", ageHeader+string(plain), 0o644)
+	config, err := parseConfig(ageConfig)
+	if err != nil {
+		t.Fatalf("Expected the age config to decrypt, got %v", err)
+	}
+	if len(config.Stocks) != 3 || config.Stocks[0].Symbol != "VTI" {
+		t.Errorf("Expected the decrypted stocks, got %+v", config.Stocks)
+	}
+	if args, _ := os.ReadFile(argsPath); string(args) != "--decrypt -i key.txt\n" {
+		t.Errorf("Expected age to decrypt with the identity, got %q", args)
+	}
+
+	gpgConfig := write("config.yaml.asc", pgpArmorHeader+"\n"+string(plain)+"-----END PGP MESSAGE-----\n", 0o644)
+	if _, err := decodeConfig(gpgConfig, true); err != nil {
+		t.Errorf("Expected the GPG config to decrypt, got %v", err)
+	}
+	if got := encryption("config.yaml.gpg", []byte{0x85, 0x01}); got != "gpg" {
+		t.Errorf("Expected a binary .gpg file to be GPG, got %q", got)
+	}
+	if got := encryption("config.yaml", plain); got != "" {
+		t.Errorf("Expected a plain config to need no decryption, got %q", got)
+	}
+
+	readStdin = func() ([]byte, error) { return plain, nil }
+	if _, err := parseConfig(stdinConfigPath); err != nil {
+		t.Errorf("Expected the config from stdin, got %v", err)
+	}
+
+	ageCommand = "fin-tilt-test-no-such-age"
+	_, err = parseConfig(ageConfig)
+	if errorCode(err) != CodeConfigInvalid || !strings.Contains(err.Error(), "install fin-tilt-test-no-such-age") {
+		t.Errorf("Expected a missing age to be reported, got %v", err)
+	}
+
+	if _, err := readConfigDocument(ageConfig); err == nil || !strings.Contains(err.Error(), "encrypted with age") {
+		t.Errorf("Expected editing an encrypted config to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Error codes are stable identifiers for failure modes, so scripts can branch
+// on them instead of matching messages
+const (
+	// CodeConfigSum means percentages in the config don't add up to 100
+	CodeConfigSum = "E_CONFIG_SUM"
+	// CodeConfigInvalid covers every other problem with the config file
+	CodeConfigInvalid = "E_CONFIG_INVALID"
+	// CodeCSVHeader means a CSV is missing columns it needs
+	CodeCSVHeader = "E_CSV_HEADER"
+	// CodeCSVValue means a CSV cell couldn't be parsed
+	CodeCSVValue = "E_CSV_VALUE"
+	// CodeInputFormat means a portfolio file that isn't a CSV, such as a
+	// GnuCash book, couldn't be read
+	CodeInputFormat = "E_INPUT_FORMAT"
+	// CodeUnknownSymbol means a symbol isn't in the config or the portfolio
+	CodeUnknownSymbol = "E_UNKNOWN_SYMBOL"
+	// CodeUsage means the command line is missing or has invalid arguments
+	CodeUsage = "E_USAGE"
+	// CodeTimeout means a network provider didn't answer within -timeout
+	CodeTimeout = "E_TIMEOUT"
+	// CodeOffline means the command needs data that -offline can't provide
+	CodeOffline = "E_OFFLINE"
+	// CodePlugin means a provider plugin failed or answered invalidly
+	CodePlugin = "E_PLUGIN"
+	// CodeHook means a pre or post hook failed
+	CodeHook = "E_HOOK"
+	// CodeLocked means another run held a local store's lock for too long
+	CodeLocked = "E_LOCKED"
+	// CodeStoreConflict means a local store was changed by another run or a
+	// sync, or has conflicting copies, so it wasn't written
+	CodeStoreConflict = "E_STORE_CONFLICT"
+	// CodeCanceled means the command was interrupted, e.g. by Ctrl-C
+	CodeCanceled = "E_CANCELED"
+	// CodeOther is reported for errors without a more specific code
+	CodeOther = "E_OTHER"
+)
+
+// CodedError is an error with a stable code
+type CodedError struct {
+	Code string
+	Err  error
+}
+
+func (e *CodedError) Error() string { return e.Err.Error() }
+
+func (e *CodedError) Unwrap() error { return e.Err }
+
+func codedErrorf(code, format string, args ...any) error {
+	return &CodedError{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+// errorCode returns the code of the outermost CodedError in err's chain, or
+// of a canceled or timed-out network call
+func errorCode(err error) string {
+	if coded := (*CodedError)(nil); errors.As(err, &coded) {
+		return coded.Code
+	}
+	if code, ok := networkErrorCode(err); ok {
+		return code
+	}
+	return CodeOther
+}
+
+// errorFormat is "json" when the command was asked for JSON output, so that
+// errors are JSON too
+var errorFormat = "text"
+
+// jsonRequested reports whether args ask for JSON output with -format
+func jsonRequested(args []string) bool {
+	return formatRequested(args) == "json"
+}
+
+// formatRequested returns the value of a -format flag in args, or "" if
+// there is none
+func formatRequested(args []string) string {
+	value, _ := flagValue(args, "format")
+	return value
+}
+
+// flagValue finds the flag called name in args ahead of flag parsing,
+// returning its value and whether it was given
+func flagValue(args []string, name string) (string, bool) {
+	for i, arg := range args {
+		flagName, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || flagName != name {
+			continue
+		}
+		if !hasValue && i+1 < len(args) {
+			value = args[i+1]
+		}
+		return value, true
+	}
+	return "", false
+}
+
+type ErrorOutput struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// printError reports a failed command on stdout, with its code
+func printError(err error) {
+	if auditOutputs.Error == "" {
+		auditOutputs.Error = errorCode(err)
+	}
+	if errorFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(map[string]ErrorOutput{"error": {Code: errorCode(err), Message: err.Error()}})
+		return
+	}
+	fmt.Printf("Error [%s]: %v\n", errorCode(err), err)
+}
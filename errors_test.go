@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestErrorCodes(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	writeConfig := func(yaml string) string {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name     string
+		err      func() error
+		expected string
+	}{
+		{
+			name: "percentages off",
+			err: func() error {
+				_, err := parseConfig(writeConfig("stocks:\n  - symbol: VTI\n    target_percentage: 90\n"))
+				return err
+			},
+			expected: CodeConfigSum,
+		},
+		{
+			name: "schema mismatch",
+			err: func() error {
+				_, err := parseConfig(writeConfig("stocks:\n  - symbol: VTI\n    target_percentage: all\n"))
+				return err
+			},
+			expected: CodeConfigInvalid,
+		},
+		{
+			name: "unvested symbol not in stocks",
+			err: func() error {
+				_, err := parseConfig(writeConfig("stocks:\n  - symbol: VTI\n    target_percentage: 100\nunvested:\n  - symbol: ACME\n    vesting:\n      - date: 2025-01-01\n        shares: 10\n"))
+				return err
+			},
+			expected: CodeUnknownSymbol,
+		},
+		{
+			name: "missing columns",
+			err: func() error {
+				_, err := readHoldings(config, strings.NewReader("Fund,Balance\nVTI,100\n"))
+				return err
+			},
+			expected: CodeCSVHeader,
+		},
+		{
+			name: "bad amount, wrapped",
+			err: func() error {
+				_, err := readHoldings(config, strings.NewReader("Symbol,Current Value\nVTI,lots\n"))
+				return fmt.Errorf("loading portfolio: %w", err)
+			},
+			expected: CodeCSVValue,
+		},
+		{
+			name:     "uncoded",
+			err:      func() error { return fmt.Errorf("something else") },
+			expected: CodeOther,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.err()
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			if code := errorCode(err); code != tt.expected {
+				t.Errorf("Expected %s, got %s (%v)", tt.expected, code, err)
+			}
+		})
+	}
+}
+
+func TestJSONRequested(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected bool
+	}{
+		{[]string{"portfolio.csv", "-format", "json"}, true},
+		{[]string{"portfolio.csv", "--format=json"}, true},
+		{[]string{"portfolio.csv", "-format", "text"}, false},
+		{[]string{"portfolio.csv", "-toDeposit", "json"}, false},
+		{[]string{"format", "json"}, false},
+	}
+	for _, tt := range tests {
+		if actual := jsonRequested(tt.args); actual != tt.expected {
+			t.Errorf("jsonRequested(%q) = %v, expected %v", tt.args, actual, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// Explanation is the arithmetic behind a symbol's recommended trade, for
+// rebalance -explain
+type Explanation struct {
+	// Target is the symbol's target percentage of the total, in cents, and
+	// Unrounded the trade that reaches it, or the fraction of the way there
+	Target    float64 `json:"target"`
+	Unrounded float64 `json:"unrounded"`
+	// Rounding is the mode the trade was rounded with, and Rounded the result
+	// before any constraint changed it
+	Rounding string `json:"rounding"`
+	Rounded  int    `json:"rounded"`
+	// BandLow and BandHigh are the edges of the band policy's band, in
+	// percent of the total
+	BandLow  *float64 `json:"band_low,omitempty"`
+	BandHigh *float64 `json:"band_high,omitempty"`
+	// Constraints are what changed the trade after rounding: locked shares,
+	// account rules, and the symbols those held back
+	Constraints []string `json:"constraints,omitempty"`
+	// Steps spell out the arithmetic, one line each
+	Steps []string `json:"steps"`
+}
+
+// bandEdges are the percentages of the total a symbol may range between
+// under the band policy; without one there is no band
+func (c *Config) bandEdges(target float64) (low, high float64, ok bool) {
+	var width float64
+	switch c.BandPolicy {
+	case "absolute":
+		width = c.Remind.band()
+	case "5/25":
+		width = min(swedroeAbsoluteBand, target*swedroeRelativeBand/100)
+	default:
+		return 0, 0, false
+	}
+	return max(target-width, 0), target + width, true
+}
+
+// explainRebalance works out how allocationCalc arrived at each symbol's
+// trade in result and adds it to the symbol as its Explanation
+func explainRebalance(config *Config, holdings *Holdings, result *RebalanceResult) {
+	invested := result.Total - result.DepositAmount
+	rounding := config.Rounding
+	if rounding == "" {
+		rounding = "half-up"
+	}
+	for _, stock := range config.Stocks {
+		data := result.Symbols[stock.Symbol]
+		e := &Explanation{
+			Target:    float64(result.Total) * stock.TargetPercentage / 100,
+			Unrounded: neededAmount(result.Total, invested, data.Amount, stock.TargetPercentage, result.Fraction),
+			Rounding:  rounding,
+		}
+		e.Rounded = config.round(e.Unrounded, "half-up")
+		step := func(format string, args ...any) { e.Steps = append(e.Steps, fmt.Sprintf(format, args...)) }
+
+		step("Target value: %.2f%% of %s = %s", stock.TargetPercentage, formatAmount(result.Total, true), formatAmount(int(math.Round(e.Target)), true))
+		step("Current value: %s (%.2f%%, %s)", formatAmount(data.Amount, true), data.CurrentPercentage, driftText(data.Drift))
+		if result.Fraction < 1 {
+			step("Fraction %g: moves %g%% of the way back to target", result.Fraction, result.Fraction*100)
+		}
+		step("Trade before rounding: %s; rounded %s to %s", formatUnrounded(e.Unrounded), rounding, formatAmount(e.Rounded, true))
+		if low, high, ok := config.bandEdges(stock.TargetPercentage); ok {
+			e.BandLow, e.BandHigh = &low, &high
+			inside := "inside it, so the trade is optional"
+			if data.Actionable {
+				inside = "outside it, so trade"
+			}
+			step("Band: %.2f%% to %.2f%% (%s); %.2f%% is %s", low, high, config.bandLabel(), data.CurrentPercentage, inside)
+		}
+
+		switch {
+		case data.Locked:
+			e.Constraints = append(e.Constraints, fmt.Sprintf("locked shares worth %s can't be sold, so the sale stops at %s", formatAmount(lockedAmount(stock, holdings), true), formatAmount(data.AmountNeeded, true)))
+		case data.Note != "":
+			e.Constraints = append(e.Constraints, "account rule: "+data.Note)
+		case data.AmountNeeded != e.Rounded:
+			e.Constraints = append(e.Constraints, fmt.Sprintf("other symbols were held back, so the rest of the portfolio was spread by target: %s", formatAmount(data.AmountNeeded, true)))
+		}
+		for _, constraint := range e.Constraints {
+			step("Constraint: %s", constraint)
+		}
+		if data.WholeShares {
+			shares := float64(data.AmountNeeded) / float64(data.Price)
+			step("Whole shares in %s: %s / %s = %.2f shares, rounded %s to %d (%s), leaving %s of cash", data.Account, formatAmount(data.AmountNeeded, true), formatAmount(data.Price, true), shares, wholeShareRounding(config), data.SharesNeeded, formatAmount(data.SharesNeeded*data.Price, true), formatAmount(data.ResidualCash, true))
+		} else if data.Account != "" && data.AmountNeeded != 0 {
+			step("Traded in %s", data.Account)
+		}
+		data.Explanation = e
+		result.Symbols[stock.Symbol] = data
+	}
+}
+
+// formatUnrounded formats cents to the hundredth of a cent, showing what
+// rounding dropped
+func formatUnrounded(cents float64) string {
+	whole := math.Trunc(cents)
+	hundredths := int(math.Abs(cents-whole) * 100)
+	text := formatAmount(int(whole), true)
+	if cents < 0 && whole == 0 {
+		text = "-" + text
+	}
+	return fmt.Sprintf("%s%02d", text, hundredths)
+}
+
+// wholeShareRounding is the mode share counts are rounded with
+func wholeShareRounding(config *Config) string {
+	if config.Rounding != "" {
+		return config.Rounding
+	}
+	return "toward zero"
+}
+
+func printExplanation(e *Explanation) {
+	if e == nil {
+		return
+	}
+	fmt.Println("Explanation:")
+	for _, step := range e.Steps {
+		fmt.Println(wrapText("  "+step, terminalWidth))
+	}
+}
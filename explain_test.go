@@ -0,0 +1,52 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExplainRebalance(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "accounts.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	config.BandPolicy = "5/25"
+	holdings := loadHoldings(t, config, "whole_shares.csv")
+	result, err := allocationCalc(config, holdings, 0)
+	if err != nil {
+		t.Fatalf("allocationCalc failed: %v", err)
+	}
+	explainRebalance(config, holdings, result)
+
+	// VXUS's 18% target of $99,964.49 is $17,993.61, $5,586.25 more than it
+	// holds, bought as 91 whole shares in the brokerage
+	vxus := result.Symbols["VXUS"].Explanation
+	if vxus == nil || vxus.Rounded != 558625 || vxus.Rounding != "half-up" {
+		t.Fatalf("VXUS explanation mismatch: %+v", vxus)
+	}
+	// The 5/25 band of an 18% target is 25% of it, 4.5 points
+	if *vxus.BandLow != 13.5 || *vxus.BandHigh != 22.5 {
+		t.Errorf("Expected VXUS's band to be 13.5%% to 22.5%%, got %g to %g", *vxus.BandLow, *vxus.BandHigh)
+	}
+	steps := strings.Join(vxus.Steps, "\n")
+	for _, want := range []string{"Target value: 18.00% of $99,964.49 = $17,993.61", "rounded half-up to $5,586.25", "outside it, so trade", "rounded toward zero to 91 ($5,561.92)", "$5,586.2482", "Whole shares in Brokerage"} {
+		if !strings.Contains(steps, want) {
+			t.Errorf("Expected VXUS's steps to mention %q, got:\n%s", want, steps)
+		}
+	}
+
+	// Locking VTI holds its sale back and spreads the rest by target
+	config.Stocks[0].Locked = true
+	result, err = allocationCalc(config, holdings, 0)
+	if err != nil {
+		t.Fatalf("allocationCalc failed: %v", err)
+	}
+	explainRebalance(config, holdings, result)
+	if vti := result.Symbols["VTI"].Explanation; len(vti.Constraints) != 1 || !strings.Contains(vti.Constraints[0], "locked shares worth $74,656.89") {
+		t.Errorf("Expected VTI's lock as its constraint, got %+v", vti.Constraints)
+	}
+	if bnd := result.Symbols["BND"].Explanation; len(bnd.Constraints) != 1 || !strings.Contains(bnd.Constraints[0], "held back") {
+		t.Errorf("Expected BND's trade to be spread around VTI, got %+v", bnd.Constraints)
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// exportFormats maps each export format to its writer
+var exportFormats = map[string]func(w io.Writer, plan *ExportPlan) error{
+	"ledger":    writeLedger,
+	"beancount": writeBeancount,
+	"qif":       writeQIF,
+	"ofx":       writeOFX,
+}
+
+// tradeFormats only hold trades, so they always include them
+var tradeFormats = []string{"qif", "ofx"}
+
+// ExportPlan is everything an export writes: the holdings as of the export
+// date and, optionally, the recommended trades
+type ExportPlan struct {
+	AsOf      time.Time
+	TradeDate time.Time
+	// Root is the plain-text-accounting account under which each brokerage
+	// account and symbol gets a subaccount
+	Root      string
+	Positions []Position
+	Trades    []ExportTrade
+}
+
+// ExportTrade is one recommended trade. Quantity is zero when the CSV has no
+// price to convert the dollar amount into shares.
+type ExportTrade struct {
+	Account  string
+	Symbol   string
+	Quantity float64
+	Price    int
+	Amount   int
+}
+
+func export(config *Config, args []string) {
+	var root, outputPath string
+	var trades bool
+	var toDeposit int
+	flagSet := flag.NewFlagSet("export", flag.ExitOnError)
+	flagSet.StringVar(&root, "root", "Assets:Investments", "Account that brokerage accounts are nested under")
+	flagSet.BoolVar(&trades, "trades", false, "Include the recommended trades as pending transactions")
+	flagSet.Func("toDeposit", "Amount to deposit (negative to withdraw) when planning trades", func(value string) error {
+		amount, err := amountToInt(value)
+		toDeposit = amount
+		return err
+	})
+	flagSet.StringVar(&outputPath, "o", "", "Write the export to a file instead of stdout")
+	if len(args) < 2 {
+		flag.Usage()
+		return
+	}
+	format, portfolioCsv := args[0], args[1]
+	write, ok := exportFormats[format]
+	if !ok {
+		printError(codedErrorf(CodeUsage, "unknown export format %q", format))
+		return
+	}
+	flagSet.Parse(args[2:])
+
+	holdings, err := loadPortfolio(config, portfolioCsv)
+	if err != nil {
+		printError(err)
+		return
+	}
+	result, err := allocationCalc(config, holdings, toDeposit)
+	if err != nil {
+		printError(err)
+		return
+	}
+	auditOutputs.rebalanced(result)
+	withTrades := trades || slices.Contains(tradeFormats, format)
+	plan := exportPlan(config, holdings, result, root, withTrades, time.Now())
+
+	err = writeOutput(outputPath, func(out io.Writer) error {
+		w := bufio.NewWriter(out)
+		if err := write(w, plan); err != nil {
+			return err
+		}
+		return w.Flush()
+	})
+	if err != nil {
+		printError(err)
+		return
+	}
+	if withTrades && !dryRun {
+		auditOutputs.PlanExported = cmp.Or(outputPath, "stdout")
+	}
+}
+
+// exportPlan gathers the positions and, when withTrades is set, the trades
+// from result in config order
+func exportPlan(config *Config, holdings *Holdings, result *RebalanceResult, root string, withTrades bool, tradeDate time.Time) *ExportPlan {
+	plan := &ExportPlan{AsOf: result.AsOf, TradeDate: tradeDate, Root: root, Positions: holdings.Positions}
+	if plan.AsOf.IsZero() {
+		plan.AsOf = tradeDate
+	}
+	if !withTrades {
+		return plan
+	}
+
+	byAccount := make(map[string]map[string]int)
+	for _, position := range holdings.Positions {
+		if byAccount[position.Primary] == nil {
+			byAccount[position.Primary] = make(map[string]int)
+		}
+		byAccount[position.Primary][position.Account] += position.Value
+	}
+	for _, stock := range config.Stocks {
+		data := result.Symbols[stock.Symbol]
+		if data.AmountNeeded == 0 {
+			continue
+		}
+		// Name the account as the positions do, whether or not it is configured
+		trade := ExportTrade{Account: tradeAccount(byAccount[stock.Symbol]), Symbol: stock.Symbol, Price: data.Price, Amount: data.AmountNeeded}
+		if data.WholeShares {
+			if data.SharesNeeded == 0 {
+				continue
+			}
+			trade.Quantity = float64(data.SharesNeeded)
+			trade.Amount = data.SharesNeeded * data.Price
+		} else if data.Price > 0 {
+			trade.Quantity = roundQuantity(float64(data.AmountNeeded) / float64(data.Price))
+		}
+		plan.Trades = append(plan.Trades, trade)
+	}
+	return plan
+}
+
+// quantity returns the share count of a position, deriving it from the value
+// and price when the CSV has no Quantity column
+func (p *Position) quantity() float64 {
+	if p.Quantity != 0 || p.Price == 0 {
+		return p.Quantity
+	}
+	return roundQuantity(float64(p.Value) / float64(p.Price))
+}
+
+func roundQuantity(quantity float64) float64 {
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(quantity, 'f', 4, 64), 64)
+	return rounded
+}
+
+func formatQuantity(quantity float64) string {
+	return strconv.FormatFloat(quantity, 'f', -1, 64)
+}
+
+// formatDecimal formats cents as a plain decimal number, e.g. "-1234.50"
+func formatDecimal(cents int) string {
+	sign := ""
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// ledgerAccount joins the root, brokerage account, and leaf into an account
+// name, keeping only the letters, digits, and dashes both ledger and
+// beancount accept in a component
+func ledgerAccount(root, account, leaf string) string {
+	parts := []string{root}
+	for _, part := range []string{account, leaf} {
+		var b strings.Builder
+		for _, r := range part {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' {
+				b.WriteRune(r)
+			}
+		}
+		if b.Len() > 0 {
+			component := []rune(b.String())
+			component[0] = unicode.ToUpper(component[0])
+			parts = append(parts, string(component))
+		}
+	}
+	return strings.Join(parts, ":")
+}
+
+func writeLedger(w io.Writer, plan *ExportPlan) error {
+	date := plan.AsOf.Format("2006/01/02")
+	fmt.Fprintf(w, "; Holdings exported by fin-tilt as of %s\n", plan.AsOf.Format(time.DateOnly))
+	prices := make(map[string]bool)
+	for _, position := range plan.Positions {
+		if position.Price > 0 && !prices[position.Symbol] {
+			prices[position.Symbol] = true
+			fmt.Fprintf(w, "P %s %s $%s\n", date, position.Symbol, formatDecimal(position.Price))
+		}
+	}
+
+	fmt.Fprintf(w, "\n%s * fin-tilt holdings\n", date)
+	for _, position := range plan.Positions {
+		account := ledgerAccount(plan.Root, position.Account, position.Symbol)
+		if quantity := position.quantity(); quantity != 0 {
+			fmt.Fprintf(w, "    %-50s 0 %s = %s %s\n", account, position.Symbol, formatQuantity(quantity), position.Symbol)
+		} else {
+			fmt.Fprintf(w, "    %-50s $0 = $%s\n", account, formatDecimal(position.Value))
+		}
+	}
+	fmt.Fprintf(w, "    %s\n", ledgerAccount("Equity", "Unreconciled", ""))
+
+	for _, trade := range plan.Trades {
+		action := "buy"
+		if trade.Amount < 0 {
+			action = "sell"
+		}
+		fmt.Fprintf(w, "\n%s ! Rebalance: %s %s\n", plan.TradeDate.Format("2006/01/02"), action, trade.Symbol)
+		if trade.Quantity != 0 {
+			fmt.Fprintf(w, "    %-50s %s %s @ $%s\n", ledgerAccount(plan.Root, trade.Account, trade.Symbol), formatQuantity(trade.Quantity), trade.Symbol, formatDecimal(trade.Price))
+		} else {
+			fmt.Fprintf(w, "    %-50s $%s\n", ledgerAccount(plan.Root, trade.Account, trade.Symbol), formatDecimal(trade.Amount))
+		}
+		fmt.Fprintf(w, "    %s\n", ledgerAccount(plan.Root, trade.Account, "Cash"))
+	}
+	return nil
+}
+
+func writeBeancount(w io.Writer, plan *ExportPlan) error {
+	date := plan.AsOf.Format(time.DateOnly)
+	fmt.Fprintf(w, "; Holdings exported by fin-tilt as of %s\n", date)
+	prices := make(map[string]bool)
+	for _, position := range plan.Positions {
+		if position.Price > 0 && !prices[position.Symbol] {
+			prices[position.Symbol] = true
+			fmt.Fprintf(w, "%s price %s %s USD\n", date, position.Symbol, formatDecimal(position.Price))
+		}
+	}
+
+	fmt.Fprintln(w)
+	for _, position := range plan.Positions {
+		account := ledgerAccount(plan.Root, position.Account, position.Symbol)
+		if quantity := position.quantity(); quantity != 0 {
+			fmt.Fprintf(w, "%s balance %s %s %s\n", date, account, formatQuantity(quantity), position.Symbol)
+		} else {
+			fmt.Fprintf(w, "%s balance %s %s USD\n", date, account, formatDecimal(position.Value))
+		}
+	}
+
+	for _, trade := range plan.Trades {
+		action := "buy"
+		if trade.Amount < 0 {
+			action = "sell"
+		}
+		fmt.Fprintf(w, "\n%s ! \"fin-tilt\" \"Rebalance: %s %s\"\n", plan.TradeDate.Format(time.DateOnly), action, trade.Symbol)
+		cash := ledgerAccount(plan.Root, trade.Account, "Cash")
+		switch {
+		case trade.Quantity > 0:
+			fmt.Fprintf(w, "  %s %s %s {%s USD}\n", ledgerAccount(plan.Root, trade.Account, trade.Symbol), formatQuantity(trade.Quantity), trade.Symbol, formatDecimal(trade.Price))
+			fmt.Fprintf(w, "  %s\n", cash)
+		case trade.Quantity < 0:
+			fmt.Fprintf(w, "  %s %s %s {} @ %s USD\n", ledgerAccount(plan.Root, trade.Account, trade.Symbol), formatQuantity(trade.Quantity), trade.Symbol, formatDecimal(trade.Price))
+			fmt.Fprintf(w, "  %s %s USD\n", cash, formatDecimal(-trade.Amount))
+			fmt.Fprintln(w, "  Income:CapitalGains")
+		default:
+			fmt.Fprintf(w, "  %s %s USD\n", ledgerAccount(plan.Root, trade.Account, trade.Symbol), formatDecimal(trade.Amount))
+			fmt.Fprintf(w, "  %s\n", cash)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportLedger(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "accounts.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	holdings := loadHoldings(t, config, "whole_shares.csv")
+	holdings.AsOf = time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)
+	result, err := allocationCalc(config, holdings, 0)
+	if err != nil {
+		t.Fatalf("allocationCalc failed: %v", err)
+	}
+	plan := exportPlan(config, holdings, result, "Assets:Investments", true, time.Date(2026, time.October, 2, 0, 0, 0, 0, time.UTC))
+
+	var b strings.Builder
+	if err := writeBeancount(&b, plan); err != nil {
+		t.Fatalf("writeBeancount failed: %v", err)
+	}
+	expected := `; Holdings exported by fin-tilt as of 2026-10-01
+2026-10-01 price VTI 251.37 USD
+2026-10-01 price VXUS 61.12 USD
+2026-10-01 price BND 75.44 USD
+
+2026-10-01 balance Assets:Investments:Brokerage:VTI 297 VTI
+2026-10-01 balance Assets:Investments:Brokerage:VXUS 203 VXUS
+2026-10-01 balance Assets:Investments:RothIRA:BND 171 BND
+
+2026-10-02 ! "fin-tilt" "Rebalance: sell VTI"
+  Assets:Investments:Brokerage:VTI -14 VTI {} @ 251.37 USD
+  Assets:Investments:Brokerage:Cash 3519.18 USD
+  Income:CapitalGains
+
+2026-10-02 ! "fin-tilt" "Rebalance: buy VXUS"
+  Assets:Investments:Brokerage:VXUS 91 VXUS {61.12 USD}
+  Assets:Investments:Brokerage:Cash
+
+2026-10-02 ! "fin-tilt" "Rebalance: sell BND"
+  Assets:Investments:RothIRA:BND -25.2406 BND {} @ 75.44 USD
+  Assets:Investments:RothIRA:Cash 1904.15 USD
+  Income:CapitalGains
+`
+	if b.String() != expected {
+		t.Errorf("Beancount mismatch:\n%s", b.String())
+	}
+
+	b.Reset()
+	plan.Trades = nil
+	if err := writeLedger(&b, plan); err != nil {
+		t.Fatalf("writeLedger failed: %v", err)
+	}
+	for _, line := range []string{
+		"P 2026/10/01 VTI $251.37",
+		"2026/10/01 * fin-tilt holdings",
+		"    Assets:Investments:RothIRA:BND                     0 BND = 171 BND",
+	} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("Ledger output missing %q:\n%s", line, b.String())
+		}
+	}
+}
+
+func TestExportQIFAndOFX(t *testing.T) {
+	plan := &ExportPlan{
+		TradeDate: time.Date(2026, time.October, 2, 0, 0, 0, 0, time.UTC),
+		Trades: []ExportTrade{
+			{Account: "Brokerage", Symbol: "VTI", Quantity: -14, Price: 25137, Amount: -351918},
+			{Account: "Roth IRA", Symbol: "BND", Quantity: 10.5, Price: 7544, Amount: 79212},
+			{Account: "Roth IRA", Symbol: "FXAIX", Amount: 50000},
+		},
+	}
+
+	var b strings.Builder
+	if err := writeQIF(&b, plan); err != nil {
+		t.Fatalf("writeQIF failed: %v", err)
+	}
+	for _, entry := range []string{
+		"!Account\nNBrokerage\nTInvst\n^\n!Type:Invst\nD10/02/2026\nNSell\nYVTI\nI251.37\nQ14\nT3519.18\n",
+		"!Account\nNRoth IRA\nTInvst\n^\n!Type:Invst\nD10/02/2026\nNBuy\nYBND\nI75.44\nQ10.5\nT792.12\n",
+		"NBuy\nYFXAIX\nT500.00\n",
+	} {
+		if !strings.Contains(b.String(), entry) {
+			t.Errorf("QIF output missing %q:\n%s", entry, b.String())
+		}
+	}
+
+	b.Reset()
+	if err := writeOFX(&b, plan); err != nil {
+		t.Fatalf("writeOFX failed: %v", err)
+	}
+	ofx := b.String()
+	for _, entry := range []string{
+		"<ACCTID>Brokerage</INVACCTFROM>",
+		"<UNITS>-14<UNITPRICE>251.37<TOTAL>3519.18<SUBACCTSEC>CASH<SUBACCTFUND>CASH</INVSELL>",
+		"<ACCTID>Roth IRA</INVACCTFROM>",
+		"<UNITS>10.5<UNITPRICE>75.44<TOTAL>-792.12<SUBACCTSEC>CASH<SUBACCTFUND>CASH</INVBUY>",
+	} {
+		if !strings.Contains(ofx, entry) {
+			t.Errorf("OFX output missing %q:\n%s", entry, ofx)
+		}
+	}
+	if strings.Contains(ofx, "FXAIX") {
+		t.Error("OFX should leave out trades without a share count")
+	}
+
+	// A failed write, such as to a full disk, is reported
+	for name, write := range map[string]func(io.Writer, *ExportPlan) error{"qif": writeQIF, "ofx": writeOFX} {
+		if err := write(failingWriter{}, plan); !errors.Is(err, errFailingWrite) {
+			t.Errorf("%s: expected the write error, got %v", name, err)
+		}
+	}
+}
+
+var errFailingWrite = errors.New("no space left on device")
+
+// failingWriter fails every write
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errFailingWrite }
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ecbAPI serves the European Central Bank's reference rates as XML,
+// overridden in tests
+var ecbAPI = "https://www.ecb.europa.eu/stats/eurofxref/"
+
+// exchangeRateHostAPI serves daily rates as JSON, overridden in tests
+var exchangeRateHostAPI = "https://api.exchangerate.host/"
+
+var fxSources = []string{"ecb", "exchangerate.host"}
+
+// fxLookback is how far before a date a rate may be published and still be
+// the rate of that date, covering weekends and bank holidays
+const fxLookback = 7 * 24 * time.Hour
+
+// currencyPattern matches an ISO 4217 currency code
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// fxDate, when set, converts other currencies at that day's exchange rates
+// instead of the latest. Set by the -fxDate flag.
+var fxDate time.Time
+
+// FXConfig chooses where exchange rates come from
+type FXConfig struct {
+	// Source is ecb (default) or exchangerate.host
+	Source string `yaml:"source,omitempty"`
+	// TokenEnv names the environment variable holding the exchangerate.host
+	// access key (default EXCHANGERATE_HOST_KEY)
+	TokenEnv string `yaml:"token_env,omitempty"`
+}
+
+// FXHistory maps date ("2006-01-02") -> currency -> USD value of one unit
+type FXHistory map[string]map[string]float64
+
+// FXSource provides the daily rates of currencies from start to end
+type FXSource interface {
+	Rates(ctx context.Context, currencies []string, start, end time.Time) (FXHistory, error)
+}
+
+func validateFX(config *Config) error {
+	if fx := config.FX; fx != nil && fx.Source != "" && !slices.Contains(fxSources, fx.Source) {
+		return fmt.Errorf("unknown fx source %q (expected one of %s)", fx.Source, strings.Join(fxSources, ", "))
+	}
+	for _, stock := range config.Stocks {
+		if stock.Currency != "" && !currencyPattern.MatchString(stock.Currency) {
+			return fmt.Errorf("currency of %s must be a three-letter code such as EUR (got %q)", stock.Symbol, stock.Currency)
+		}
+	}
+	return nil
+}
+
+func (f *FXConfig) source() string {
+	if f == nil || f.Source == "" {
+		return "ecb"
+	}
+	return f.Source
+}
+
+// fxSource returns the configured source of exchange rates
+func (c *Config) fxSource() FXSource {
+	if c.FX.source() == "exchangerate.host" {
+		env := c.FX.TokenEnv
+		if env == "" {
+			env = "EXCHANGERATE_HOST_KEY"
+		}
+		return exchangeRateHostSource{key: os.Getenv(env), keyEnv: env}
+	}
+	return ecbSource{}
+}
+
+// ecbSource downloads the European Central Bank's reference rates, which
+// need no key. They are quoted against the euro and published on business
+// days.
+type ecbSource struct{}
+
+func (ecbSource) Rates(ctx context.Context, currencies []string, start, end time.Time) (FXHistory, error) {
+	// The 90-day file is far smaller than the full history since 1999
+	file := "eurofxref-hist.xml"
+	if time.Since(start) < 85*24*time.Hour {
+		file = "eurofxref-hist-90d.xml"
+	}
+	body, err := getPrices(ctx, ecbAPI+file, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	var envelope struct {
+		Days []struct {
+			Time  string `xml:"time,attr"`
+			Rates []struct {
+				Currency string  `xml:"currency,attr"`
+				Rate     float64 `xml:"rate,attr"`
+			} `xml:"Cube"`
+		} `xml:"Cube>Cube"`
+	}
+	if err := xml.NewDecoder(body).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("invalid ECB response: %w", err)
+	}
+
+	history := make(FXHistory)
+	from, to := start.Format(time.DateOnly), end.Format(time.DateOnly)
+	for _, day := range envelope.Days {
+		if day.Time < from || day.Time > to {
+			continue
+		}
+		// Each rate is units per euro, so a unit is worth USD/rate dollars
+		perEuro := map[string]float64{"EUR": 1}
+		for _, rate := range day.Rates {
+			perEuro[rate.Currency] = rate.Rate
+		}
+		usd := perEuro["USD"]
+		if usd <= 0 {
+			continue
+		}
+		rates := make(map[string]float64)
+		for _, currency := range currencies {
+			if rate := perEuro[currency]; rate > 0 {
+				rates[currency] = usd / rate
+			}
+		}
+		history[day.Time] = rates
+	}
+	if len(history) == 0 {
+		return nil, errors.New("no exchange rates")
+	}
+	return history, nil
+}
+
+// exchangeRateHostSource downloads from exchangerate.host, which needs an
+// access key and serves at most a year per request
+type exchangeRateHostSource struct {
+	key, keyEnv string
+}
+
+func (s exchangeRateHostSource) Rates(ctx context.Context, currencies []string, start, end time.Time) (FXHistory, error) {
+	if s.key == "" {
+		return nil, fmt.Errorf("set %s to an exchangerate.host access key", s.keyEnv)
+	}
+	history := make(FXHistory)
+	for from := start; !from.After(end); from = from.AddDate(1, 0, 0) {
+		to := from.AddDate(1, 0, -1)
+		if to.After(end) {
+			to = end
+		}
+		query := url.Values{
+			"access_key": {s.key},
+			"source":     {"USD"},
+			"currencies": {strings.Join(currencies, ",")},
+			"start_date": {from.Format(time.DateOnly)},
+			"end_date":   {to.Format(time.DateOnly)},
+		}
+		body, err := getPrices(ctx, exchangeRateHostAPI+"timeframe?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		var response struct {
+			Success bool                          `json:"success"`
+			Quotes  map[string]map[string]float64 `json:"quotes"`
+			Error   struct {
+				Info string `json:"info"`
+			} `json:"error"`
+		}
+		err = json.NewDecoder(body).Decode(&response)
+		body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid exchangerate.host response: %w", err)
+		}
+		if !response.Success {
+			return nil, fmt.Errorf("exchangerate.host: %s", response.Error.Info)
+		}
+		// Quotes such as USDEUR are units per dollar
+		for date, quotes := range response.Quotes {
+			rates := make(map[string]float64)
+			for pair, quote := range quotes {
+				if quote > 0 {
+					rates[strings.TrimPrefix(pair, "USD")] = 1 / quote
+				}
+			}
+			history[date] = rates
+		}
+	}
+	if len(history) == 0 {
+		return nil, errors.New("no exchange rates")
+	}
+	return history, nil
+}
+
+// fxHistory returns daily rates of currencies since start from the
+// configured source, caching what it downloads. Under -offline they are read
+// from the cache instead.
+func fxHistory(ctx context.Context, config *Config, currencies []string, start time.Time) (FXHistory, error) {
+	cached, err := readFXCache(config)
+	if err != nil {
+		return nil, err
+	}
+	if offline {
+		if cached == nil {
+			return nil, codedErrorf(CodeOffline, "no cached exchange rates for %s; run once without -offline", strings.Join(currencies, ", "))
+		}
+		return cached.Rates, nil
+	}
+	source := config.fxSource()
+	provider := config.FX.source()
+	fetched, err := fetchAll(ctx, config.fetchPolicy(provider), []string{provider}, func(ctx context.Context, _ string) (FXHistory, error) {
+		return source.Rates(ctx, currencies, start, time.Now())
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loading exchange rates from %w", err)
+	}
+	history := fetched[provider]
+	// Failing to write the cache only costs a download later
+	if err := writeFXCache(config, cached, history, time.Now()); err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: caching exchange rates:", err)
+	}
+	return history, nil
+}
+
+// fxRates returns the USD value of one unit of each currency on date, or
+// today if date is zero. Rates of past dates are taken from the cache when
+// it has them, since they never change.
+func fxRates(ctx context.Context, config *Config, currencies []string, date time.Time) (map[string]float64, error) {
+	end := date
+	if end.IsZero() {
+		end = time.Now()
+	}
+	day := end.Format(time.DateOnly)
+	cached, err := readFXCache(config)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil && !date.IsZero() && cached.FetchedAt.After(end) {
+		if rates, err := cached.Rates.on(currencies, day); err == nil {
+			return rates, nil
+		}
+	}
+	if offline {
+		if cached == nil {
+			return nil, codedErrorf(CodeOffline, "no cached exchange rates for %s; run once without -offline", strings.Join(currencies, ", "))
+		}
+		rates, err := cached.Rates.on(currencies, day)
+		if err != nil {
+			return nil, codedErrorf(CodeOffline, "%w in the cache; run once without -offline", err)
+		}
+		return rates, nil
+	}
+	history, err := fxHistory(ctx, config, currencies, end.Add(-fxLookback))
+	if err != nil {
+		return nil, err
+	}
+	return history.on(currencies, day)
+}
+
+// on returns the rate of each currency on date: the latest published on or
+// before it, within fxLookback
+func (h FXHistory) on(currencies []string, date string) (map[string]float64, error) {
+	rates := make(map[string]float64)
+	for _, currency := range currencies {
+		rate, ok := h.series(currency).on(date)
+		if !ok {
+			return nil, fmt.Errorf("no exchange rate for %s on %s", currency, date)
+		}
+		rates[currency] = rate
+	}
+	return rates, nil
+}
+
+// fxSeries is one currency's rates in date order
+type fxSeries struct {
+	dates []string
+	rates []float64
+}
+
+func (h FXHistory) series(currency string) fxSeries {
+	var s fxSeries
+	for _, date := range slices.Sorted(maps.Keys(h)) {
+		if rate, ok := h[date][currency]; ok && rate > 0 {
+			s.dates = append(s.dates, date)
+			s.rates = append(s.rates, rate)
+		}
+	}
+	return s
+}
+
+func (s fxSeries) on(date string) (float64, bool) {
+	i := sort.SearchStrings(s.dates, date)
+	if i < len(s.dates) && s.dates[i] == date {
+		return s.rates[i], true
+	}
+	if i == 0 {
+		return 0, false
+	}
+	published, err := time.Parse(time.DateOnly, s.dates[i-1])
+	at, atErr := time.Parse(time.DateOnly, date)
+	if err != nil || atErr != nil || at.Sub(published) > fxLookback {
+		return 0, false
+	}
+	return s.rates[i-1], true
+}
+
+// convertHistory converts the monthly closes of stocks quoted in other
+// currencies to USD at the rate of each month's end, so that backtests of
+// non-USD assets include their currency's moves
+func convertHistory(ctx context.Context, config *Config, history PriceHistory, start time.Time) error {
+	currencyOf := make(map[string]string)
+	var currencies []string
+	for _, stock := range config.Stocks {
+		if _, ok := history[stock.Symbol]; !ok || stock.Currency == "" || stock.Currency == "USD" {
+			continue
+		}
+		currencyOf[stock.Symbol] = stock.Currency
+		if !slices.Contains(currencies, stock.Currency) {
+			currencies = append(currencies, stock.Currency)
+		}
+	}
+	if len(currencies) == 0 {
+		return nil
+	}
+	fx, err := fxHistory(ctx, config, currencies, start)
+	if err != nil {
+		return err
+	}
+	today := time.Now().Format(time.DateOnly)
+	for symbol, currency := range currencyOf {
+		series := fx.series(currency)
+		for month, price := range history[symbol] {
+			first, err := time.Parse("2006-01", month)
+			if err != nil {
+				return fmt.Errorf("invalid month %q in the history of %s", month, symbol)
+			}
+			// The month's close is on or before its last day, or today for
+			// the month under way
+			rate, ok := series.on(min(first.AddDate(0, 1, -1).Format(time.DateOnly), today))
+			if !ok {
+				return fmt.Errorf("no exchange rate for %s in %s to convert %s", currency, month, symbol)
+			}
+			history[symbol][month] = price * rate
+		}
+	}
+	return nil
+}
+
+// CachedFX is every exchange rate downloaded from a source
+type CachedFX struct {
+	Source    string    `json:"source"`
+	FetchedAt time.Time `json:"fetched_at"`
+	Rates     FXHistory `json:"rates"`
+}
+
+func fxCachePath(config *Config) (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "fx", config.FX.source()+".json"), nil
+}
+
+// readFXCache returns the cached rates of the configured source, or nil if
+// there are none
+func readFXCache(config *Config) (*CachedFX, error) {
+	path, err := fxCachePath(config)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var cached CachedFX
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &cached, nil
+}
+
+// writeFXCache adds history to the rates already cached
+func writeFXCache(config *Config, cached *CachedFX, history FXHistory, now time.Time) error {
+	path, err := fxCachePath(config)
+	if err != nil {
+		return err
+	}
+	merged := make(FXHistory)
+	if cached != nil {
+		maps.Copy(merged, cached.Rates)
+	}
+	for date, rates := range history {
+		if merged[date] == nil {
+			merged[date] = make(map[string]float64)
+		}
+		maps.Copy(merged[date], rates)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(CachedFX{Source: config.FX.source(), FetchedAt: now, Rates: merged})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const ecbHistory = `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<gesmes:subject>Reference rates</gesmes:subject>
+	<Cube>
+		<Cube time="2026-09-30"><Cube currency="USD" rate="1.2"/><Cube currency="GBP" rate="0.8"/></Cube>
+		<Cube time="2026-08-31"><Cube currency="USD" rate="1.1"/><Cube currency="GBP" rate="0.88"/></Cube>
+	</Cube>
+</gesmes:Envelope>`
+
+// serveECB points ecbAPI and the cache at test doubles, counting requests
+func serveECB(t *testing.T) *int {
+	requests := new(int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		w.Write([]byte(ecbHistory))
+	}))
+	t.Cleanup(server.Close)
+	api := ecbAPI
+	t.Cleanup(func() { ecbAPI = api })
+	ecbAPI = server.URL + "/"
+	dir := t.TempDir()
+	cache := cacheDir
+	t.Cleanup(func() { cacheDir = cache })
+	cacheDir = func() (string, error) { return dir, nil }
+	return requests
+}
+
+func assertRate(t *testing.T, name string, got, expected float64) {
+	t.Helper()
+	if math.Abs(got-expected) > 1e-9 {
+		t.Errorf("%s: got %v, expected %v", name, got, expected)
+	}
+}
+
+func TestECBSource(t *testing.T) {
+	serveECB(t)
+	start := time.Date(2026, time.September, 1, 0, 0, 0, 0, time.UTC)
+	history, err := ecbSource{}.Rates(context.Background(), []string{"EUR", "GBP"}, start, start.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatalf("Rates failed: %v", err)
+	}
+	if len(history) != 1 {
+		t.Fatalf("Expected only the day since start, got %v", history)
+	}
+	assertRate(t, "EUR", history["2026-09-30"]["EUR"], 1.2)
+	assertRate(t, "GBP", history["2026-09-30"]["GBP"], 1.5)
+}
+
+func TestExchangeRateHostSource(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query().Get("start_date")+".."+r.URL.Query().Get("end_date"))
+		if r.URL.Query().Get("access_key") != "secret" {
+			w.Write([]byte(`{"success": false, "error": {"info": "invalid access key"}}`))
+			return
+		}
+		w.Write([]byte(`{"success": true, "quotes": {"2026-09-30": {"USDEUR": 0.8}}}`))
+	}))
+	defer server.Close()
+	defer func(api string) { exchangeRateHostAPI = api }(exchangeRateHostAPI)
+	exchangeRateHostAPI = server.URL + "/"
+	start, end := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, time.September, 30, 0, 0, 0, 0, time.UTC)
+
+	history, err := exchangeRateHostSource{key: "secret"}.Rates(context.Background(), []string{"EUR"}, start, end)
+	if err != nil {
+		t.Fatalf("Rates failed: %v", err)
+	}
+	assertRate(t, "EUR", history["2026-09-30"]["EUR"], 1.25)
+	// Requests are split into years
+	if strings.Join(queries, " ") != "2025-01-01..2025-12-31 2026-01-01..2026-09-30" {
+		t.Errorf("Ranges mismatch: got %v", queries)
+	}
+
+	if _, err := (exchangeRateHostSource{key: "wrong"}).Rates(context.Background(), []string{"EUR"}, start, end); err == nil || !strings.Contains(err.Error(), "invalid access key") {
+		t.Errorf("Expected the service's error, got %v", err)
+	}
+	if _, err := (exchangeRateHostSource{keyEnv: "EXCHANGERATE_HOST_KEY"}).Rates(context.Background(), []string{"EUR"}, start, end); err == nil {
+		t.Error("Expected an error without a key")
+	}
+}
+
+func TestFXRatesOnDate(t *testing.T) {
+	requests := serveECB(t)
+	config := &Config{}
+	ctx := context.Background()
+
+	// A Saturday takes the Friday's rate
+	rates, err := fxRates(ctx, config, []string{"EUR"}, time.Date(2026, time.October, 3, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("fxRates failed: %v", err)
+	}
+	assertRate(t, "EUR", rates["EUR"], 1.2)
+
+	// Past rates come from the cache, even offline
+	defer func(o bool) { offline = o }(offline)
+	offline = true
+	rates, err = fxRates(ctx, config, []string{"EUR"}, time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("fxRates failed offline: %v", err)
+	}
+	assertRate(t, "EUR", rates["EUR"], 1.2)
+	if *requests != 1 {
+		t.Errorf("Expected one download, got %d", *requests)
+	}
+
+	if _, err := fxRates(ctx, config, []string{"EUR"}, time.Date(2026, time.July, 1, 0, 0, 0, 0, time.UTC)); errorCode(err) != CodeOffline {
+		t.Errorf("Expected %s for a date that isn't cached, got %v", CodeOffline, err)
+	}
+}
+
+func TestConvertCurrenciesWithoutPlugin(t *testing.T) {
+	serveECB(t)
+	defer func(date time.Time) { fxDate = date }(fxDate)
+	fxDate = time.Date(2026, time.September, 30, 0, 0, 0, 0, time.UTC)
+
+	positions, err := convertCurrencies(context.Background(), &Config{}, []PluginPosition{{Symbol: "CASH", Value: 100000, Currency: "EUR"}})
+	if err != nil {
+		t.Fatalf("convertCurrencies failed: %v", err)
+	}
+	if positions[0].Value != 120000 || positions[0].Currency != "USD" {
+		t.Errorf("Conversion mismatch: got %+v", positions[0])
+	}
+}
+
+func TestConvertHistory(t *testing.T) {
+	serveECB(t)
+	config := &Config{Stocks: []Stock{{Symbol: "VWCE.DE", Currency: "EUR"}, {Symbol: "VTI"}}}
+	if err := validateFX(config); err != nil {
+		t.Fatalf("validateFX failed: %v", err)
+	}
+	history := PriceHistory{
+		"VWCE.DE": {"2026-08": 100, "2026-09": 110},
+		"VTI":     {"2026-08": 300, "2026-09": 310},
+	}
+	if err := convertHistory(context.Background(), config, history, time.Date(2026, time.August, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("convertHistory failed: %v", err)
+	}
+	// The euro's rise adds to the return in dollars
+	assertRate(t, "August", history["VWCE.DE"]["2026-08"], 110)
+	assertRate(t, "September", history["VWCE.DE"]["2026-09"], 132)
+	if history["VTI"]["2026-09"] != 310 {
+		t.Errorf("Expected USD history unchanged, got %v", history["VTI"])
+	}
+
+	history["VWCE.DE"]["2026-06"] = 90
+	if err := convertHistory(context.Background(), config, history, time.Date(2026, time.June, 1, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Error("Expected an error for a month without rates")
+	}
+}
+
+func TestValidateFX(t *testing.T) {
+	if err := validateFX(&Config{FX: &FXConfig{Source: "yahoo"}}); err == nil {
+		t.Error("Expected an error for an unknown source")
+	}
+	if err := validateFX(&Config{Stocks: []Stock{{Symbol: "VWCE.DE", Currency: "euro"}}}); err == nil {
+		t.Error("Expected an error for an invalid currency")
+	}
+}
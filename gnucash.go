@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// gnuCashTimeLayout is how GnuCash's XML writes dates
+const gnuCashTimeLayout = "2006-01-02 15:04:05 -0700"
+
+// gnuCashSecurityTypes are the account types that hold shares of a commodity
+var gnuCashSecurityTypes = []string{"STOCK", "MUTUAL"}
+
+type gnuCashFile struct {
+	Book gnuCashBook `xml:"book"`
+}
+
+type gnuCashBook struct {
+	Accounts     []gnuCashAccount     `xml:"account"`
+	Transactions []gnuCashTransaction `xml:"transaction"`
+	Prices       []gnuCashPrice       `xml:"pricedb>price"`
+}
+
+type gnuCashCommodity struct {
+	Space string `xml:"space"`
+	ID    string `xml:"id"`
+}
+
+// isCurrency reports whether the commodity is a currency rather than a security
+func (c gnuCashCommodity) isCurrency() bool {
+	return c.Space == "CURRENCY" || c.Space == "ISO4217"
+}
+
+type gnuCashAccount struct {
+	Name      string           `xml:"name"`
+	ID        string           `xml:"id"`
+	Type      string           `xml:"type"`
+	Commodity gnuCashCommodity `xml:"commodity"`
+	Parent    string           `xml:"parent"`
+}
+
+type gnuCashTransaction struct {
+	Currency gnuCashCommodity `xml:"currency"`
+	Posted   string           `xml:"date-posted>date"`
+	Splits   []gnuCashSplit   `xml:"splits>split"`
+}
+
+// gnuCashSplit is one account's side of a transaction. Value is in the
+// transaction's currency and Quantity in the account's commodity.
+type gnuCashSplit struct {
+	Value    string `xml:"value"`
+	Quantity string `xml:"quantity"`
+	Account  string `xml:"account"`
+}
+
+type gnuCashPrice struct {
+	Commodity gnuCashCommodity `xml:"commodity"`
+	Currency  gnuCashCommodity `xml:"currency"`
+	Time      string           `xml:"time>date"`
+	Value     string           `xml:"value"`
+}
+
+// isGnuCashBook reports whether path names a GnuCash book rather than a CSV
+func isGnuCashBook(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".gnucash")
+}
+
+// parseGnuCashNumber parses GnuCash's rational numbers, such as "2500000/100"
+func parseGnuCashNumber(value string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok {
+		return nil, fmt.Errorf("invalid number %q", value)
+	}
+	return r, nil
+}
+
+// gnuCashQuote is a price per share in a currency
+type gnuCashQuote struct {
+	Price    *big.Rat
+	Currency string
+	Time     time.Time
+}
+
+// readGnuCash reads the investment accounts of a GnuCash book, saved in XML,
+// which may be gzipped as GnuCash saves it, or in sqlite format. Each
+// security account is valued at the latest price in the book's price
+// database, or at its latest trade price if there is none, and belongs to its
+// parent account. A parent kept in a currency, as a brokerage account usually
+// is, is its cash.
+func readGnuCash(config *Config, r io.Reader) (*Holdings, error) {
+	br := bufio.NewReader(r)
+	var book *gnuCashBook
+	var err error
+	if magic, _ := br.Peek(16); bytes.HasPrefix(magic, []byte(sqliteMagic)) {
+		book, err = readGnuCashSQLite(br)
+	} else {
+		book, err = readGnuCashXML(br)
+	}
+	if err != nil {
+		return nil, codedErrorf(CodeInputFormat, "reading GnuCash book: %w", err)
+	}
+	positions, err := gnuCashPositions(book)
+	if err != nil {
+		return nil, codedErrorf(CodeInputFormat, "reading GnuCash book: %w", err)
+	}
+	if positions, err = convertCurrencies(context.Background(), config, positions); err != nil {
+		return nil, err
+	}
+	return readPositions(config, positions)
+}
+
+func readGnuCashXML(br *bufio.Reader) (*gnuCashBook, error) {
+	var r io.Reader = br
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	var file gnuCashFile
+	if err := xml.NewDecoder(r).Decode(&file); err != nil {
+		return nil, err
+	}
+	return &file.Book, nil
+}
+
+// readGnuCashSQLite reads the tables of a book saved in sqlite format into
+// the shape of an XML book
+func readGnuCashSQLite(r io.Reader) (*gnuCashBook, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	db, err := openSQLite(data)
+	if err != nil {
+		return nil, err
+	}
+	tables := make(map[string][]sqliteRow)
+	for _, name := range []string{"commodities", "accounts", "transactions", "splits", "prices"} {
+		if tables[name], err = db.table(name); err != nil {
+			return nil, err
+		}
+	}
+	text := func(row sqliteRow, column string) string {
+		value, _ := row[column].(string)
+		return value
+	}
+	number := func(row sqliteRow, prefix string) string {
+		num, _ := row[prefix+"_num"].(int64)
+		denom, _ := row[prefix+"_denom"].(int64)
+		return fmt.Sprintf("%d/%d", num, denom)
+	}
+	commodities := make(map[string]gnuCashCommodity)
+	for _, row := range tables["commodities"] {
+		commodities[text(row, "guid")] = gnuCashCommodity{Space: text(row, "namespace"), ID: text(row, "mnemonic")}
+	}
+
+	book := &gnuCashBook{}
+	for _, row := range tables["accounts"] {
+		book.Accounts = append(book.Accounts, gnuCashAccount{
+			Name:      text(row, "name"),
+			ID:        text(row, "guid"),
+			Type:      text(row, "account_type"),
+			Commodity: commodities[text(row, "commodity_guid")],
+			Parent:    text(row, "parent_guid"),
+		})
+	}
+	splits := make(map[string][]gnuCashSplit)
+	for _, row := range tables["splits"] {
+		transaction := text(row, "tx_guid")
+		splits[transaction] = append(splits[transaction], gnuCashSplit{
+			Value:    number(row, "value"),
+			Quantity: number(row, "quantity"),
+			Account:  text(row, "account_guid"),
+		})
+	}
+	for _, row := range tables["transactions"] {
+		posted, err := gnuCashSQLiteTime(text(row, "post_date"))
+		if err != nil {
+			return nil, fmt.Errorf("transaction date: %w", err)
+		}
+		book.Transactions = append(book.Transactions, gnuCashTransaction{
+			Currency: commodities[text(row, "currency_guid")],
+			Posted:   posted,
+			Splits:   splits[text(row, "guid")],
+		})
+	}
+	for _, row := range tables["prices"] {
+		at, err := gnuCashSQLiteTime(text(row, "date"))
+		if err != nil {
+			return nil, fmt.Errorf("price date: %w", err)
+		}
+		book.Prices = append(book.Prices, gnuCashPrice{
+			Commodity: commodities[text(row, "commodity_guid")],
+			Currency:  commodities[text(row, "currency_guid")],
+			Time:      at,
+			Value:     number(row, "value"),
+		})
+	}
+	return book, nil
+}
+
+// gnuCashSQLiteTime converts a sqlite book's UTC date, "2006-01-02 15:04:05"
+// or "20060102150405" before GnuCash 3, to the XML book's layout
+func gnuCashSQLiteTime(value string) (string, error) {
+	for _, layout := range []string{time.DateTime, "20060102150405"} {
+		if at, err := time.Parse(layout, value); err == nil {
+			return at.Format(gnuCashTimeLayout), nil
+		}
+	}
+	return "", fmt.Errorf("invalid date %q", value)
+}
+
+func gnuCashPositions(book *gnuCashBook) ([]PluginPosition, error) {
+	accounts := make(map[string]*gnuCashAccount)
+	for i := range book.Accounts {
+		accounts[book.Accounts[i].ID] = &book.Accounts[i]
+	}
+	// fullName joins the names from below the root down to the account
+	fullName := func(account *gnuCashAccount) string {
+		var names []string
+		for ; account != nil && account.Type != "ROOT"; account = accounts[account.Parent] {
+			names = append(names, account.Name)
+		}
+		slices.Reverse(names)
+		return strings.Join(names, ":")
+	}
+
+	quotes := make(map[gnuCashCommodity]gnuCashQuote)
+	for _, price := range book.Prices {
+		value, err := parseGnuCashNumber(price.Value)
+		if err != nil {
+			return nil, fmt.Errorf("price of %s: %w", price.Commodity.ID, err)
+		}
+		at, err := time.Parse(gnuCashTimeLayout, price.Time)
+		if err != nil {
+			return nil, fmt.Errorf("price of %s: %w", price.Commodity.ID, err)
+		}
+		if latest, ok := quotes[price.Commodity]; !ok || at.After(latest.Time) {
+			quotes[price.Commodity] = gnuCashQuote{Price: value, Currency: price.Currency.ID, Time: at}
+		}
+	}
+
+	// Sum each account's shares, keeping its latest trade price as a fallback
+	balances := make(map[string]*big.Rat)
+	trades := make(map[string]gnuCashQuote)
+	for _, transaction := range book.Transactions {
+		posted, err := time.Parse(gnuCashTimeLayout, transaction.Posted)
+		if err != nil {
+			return nil, fmt.Errorf("transaction date: %w", err)
+		}
+		for _, split := range transaction.Splits {
+			quantity, err := parseGnuCashNumber(split.Quantity)
+			if err != nil {
+				return nil, err
+			}
+			if balances[split.Account] == nil {
+				balances[split.Account] = new(big.Rat)
+			}
+			balances[split.Account].Add(balances[split.Account], quantity)
+			if quantity.Sign() == 0 {
+				continue
+			}
+			value, err := parseGnuCashNumber(split.Value)
+			if err != nil {
+				return nil, err
+			}
+			if latest, ok := trades[split.Account]; !ok || !posted.Before(latest.Time) {
+				trades[split.Account] = gnuCashQuote{Price: new(big.Rat).Quo(value, quantity), Currency: transaction.Currency.ID, Time: posted}
+			}
+		}
+	}
+
+	cents := func(r *big.Rat) Money {
+		f, _ := new(big.Rat).Mul(r, big.NewRat(100, 1)).Float64()
+		return Money(math.Round(f))
+	}
+	var positions []PluginPosition
+	brokerages := make(map[string]bool)
+	for _, account := range book.Accounts {
+		balance := balances[account.ID]
+		if !slices.Contains(gnuCashSecurityTypes, account.Type) || balance == nil || balance.Sign() == 0 {
+			continue
+		}
+		quote, ok := quotes[account.Commodity]
+		if !ok {
+			if quote, ok = trades[account.ID]; !ok {
+				return nil, fmt.Errorf("no price for %s", account.Commodity.ID)
+			}
+		}
+		shares, _ := balance.Float64()
+		positions = append(positions, PluginPosition{
+			Account:  fullName(accounts[account.Parent]),
+			Symbol:   account.Commodity.ID,
+			Quantity: shares,
+			Price:    cents(quote.Price),
+			Value:    cents(new(big.Rat).Mul(balance, quote.Price)),
+			Currency: quote.Currency,
+		})
+		brokerages[account.Parent] = true
+	}
+	for _, account := range book.Accounts {
+		balance := balances[account.ID]
+		if !brokerages[account.ID] || !account.Commodity.isCurrency() || balance == nil || balance.Sign() == 0 {
+			continue
+		}
+		positions = append(positions, PluginPosition{Account: fullName(&account), Symbol: "CASH", Value: cents(balance), Currency: account.Commodity.ID})
+	}
+	if len(positions) == 0 {
+		return nil, errors.New("no investment accounts with shares")
+	}
+	return positions, nil
+}
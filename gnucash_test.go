@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadGnuCash(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join("tests", "portfolios", "book.gnucash"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write(data)
+	gz.Close()
+	// The same book saved in sqlite format, with older prices enough to
+	// need interior pages and a description long enough to overflow its page
+	sqlite, err := os.ReadFile(filepath.Join("tests", "portfolios", "book-sqlite.gnucash"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, book := range map[string][]byte{"xml": data, "gzip": gzipped.Bytes(), "sqlite": sqlite} {
+		t.Run(name, func(t *testing.T) {
+			holdings, err := readGnuCash(config, bytes.NewReader(book))
+			if err != nil {
+				t.Fatalf("readGnuCash failed: %v", err)
+			}
+			// VTI is at its latest price, and BND, with no price, at what it was bought for
+			expected := map[string]int{"VTI": 7100000, "VXUS": 1800000, "BND": 1140000}
+			for symbol, amount := range expected {
+				if holdings.Amounts[symbol] != amount {
+					t.Errorf("%s: got %d, expected %d", symbol, holdings.Amounts[symbol], amount)
+				}
+			}
+			if holdings.Prices["VTI"] != 25000 || holdings.Prices["BND"] != 7600 {
+				t.Errorf("Prices mismatch: %v", holdings.Prices)
+			}
+			// Only the parents of security accounts hold cash; Checking doesn't count
+			if got := holdings.Cash; len(got) != 2 || got["Assets:Brokerage"] != 50000 || got["Assets:Roth IRA"] != 100000 {
+				t.Errorf("Cash mismatch: %v", got)
+			}
+		})
+	}
+}
+
+func TestReadGnuCashErrors(t *testing.T) {
+	config := &Config{}
+	for name, book := range map[string]string{
+		"sqlite": "SQLite format 3\x00...",
+		"xml":    "<gnc-v2><gnc:book>",
+	} {
+		if _, err := readGnuCash(config, strings.NewReader(book)); errorCode(err) != CodeInputFormat {
+			t.Errorf("%s: expected a damaged book to be %s, got %v", name, CodeInputFormat, err)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// daysPerMonth is the average month length used to count fractional months
+const daysPerMonth = 365.25 / 12
+
+// GoalConfig is a savings target the goal command tracks progress toward
+type GoalConfig struct {
+	Name   string    `yaml:"name"`
+	Target Money     `yaml:"target"`
+	Date   time.Time `yaml:"date"`
+	// Accounts limit the goal to these accounts; without them it counts the
+	// whole portfolio
+	Accounts []string `yaml:"accounts,omitempty"`
+	// ExpectedReturn is the annual return, in percent, assumed until Date
+	// (defaults to cash.expected_return)
+	ExpectedReturn *float64 `yaml:"expected_return,omitempty"`
+}
+
+// GoalStatus is where a goal stands as of the latest snapshot or portfolio
+type GoalStatus struct {
+	Name           string    `json:"name"`
+	Target         int       `json:"target"`
+	Date           time.Time `json:"date"`
+	AsOf           time.Time `json:"as_of"`
+	Current        int       `json:"current"`
+	ExpectedReturn float64   `json:"expected_return"`
+	Months         float64   `json:"months"`
+	// Needed is the monthly contribution that reaches Target by Date
+	Needed int `json:"needed"`
+	// Pace is the monthly contribution that, at the expected return,
+	// explains the growth since PaceSince; it's unknown without a snapshot at
+	// least a month older than AsOf
+	Pace      *int      `json:"pace,omitempty"`
+	PaceSince time.Time `json:"pace_since,omitzero"`
+	// Projected is the value at Date, contributing at Pace if it's known
+	Projected int `json:"projected"`
+	// Status is reached, on track, behind, or missed
+	Status string `json:"status"`
+}
+
+func validateGoals(config *Config) error {
+	seen := make(map[string]bool)
+	for _, goal := range config.Goals {
+		if goal.Name == "" {
+			return fmt.Errorf("goals need a name")
+		}
+		if seen[goal.Name] {
+			return fmt.Errorf("goal %q appears multiple times", goal.Name)
+		}
+		seen[goal.Name] = true
+		if goal.Target <= 0 {
+			return fmt.Errorf("goal %q must have a positive target", goal.Name)
+		}
+		if goal.Date.IsZero() {
+			return fmt.Errorf("goal %q must have a date", goal.Name)
+		}
+		if goal.ExpectedReturn != nil && *goal.ExpectedReturn <= -100 {
+			return fmt.Errorf("goal %q expected_return must be above -100", goal.Name)
+		}
+	}
+	return nil
+}
+
+func (g *GoalConfig) expectedReturn(config *Config) float64 {
+	if g.ExpectedReturn != nil {
+		return *g.ExpectedReturn
+	}
+	return config.Cash.expectedReturn()
+}
+
+// value is what the goal's accounts, or the whole portfolio, held at snapshot
+func (g *GoalConfig) value(snapshot *Snapshot) int {
+	if len(g.Accounts) == 0 {
+		return snapshot.Total
+	}
+	var total int
+	for _, account := range g.Accounts {
+		total += snapshot.Accounts[account]
+	}
+	return total
+}
+
+func goal(config *Config, args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: fin-tilt goal status [<name>] [-portfolio <portfolio.csv>] [-format json]")
+		return
+	}
+	switch args[0] {
+	case "status":
+		goalStatus(config, args[1:])
+	default:
+		printError(codedErrorf(CodeUsage, "unknown goal command %q", args[0]))
+	}
+}
+
+func goalStatus(config *Config, args []string) {
+	var portfolioCsv, format string
+	flagSet := flag.NewFlagSet("goal status", flag.ExitOnError)
+	flagSet.StringVar(&portfolioCsv, "portfolio", "", "Portfolio CSV to value the goals at instead of the latest snapshot")
+	flagSet.StringVar(&format, "format", "text", "Output format: text or json")
+	var name string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	flagSet.Parse(args)
+	if format != "text" && format != "json" {
+		printError(codedErrorf(CodeUsage, "unknown format %q", format))
+		return
+	}
+	goals := config.Goals
+	if name != "" {
+		goals = nil
+		for _, g := range config.Goals {
+			if g.Name == name {
+				goals = append(goals, g)
+			}
+		}
+		if goals == nil {
+			printError(codedErrorf(CodeUsage, "no goal named %q in the config", name))
+			return
+		}
+	}
+	if len(goals) == 0 {
+		printError(codedErrorf(CodeConfigInvalid, "config has no goals"))
+		return
+	}
+
+	var snapshots []Snapshot
+	if config.Snapshots != "" {
+		var err error
+		if snapshots, err = readSnapshots(config.Snapshots); err != nil {
+			printError(fmt.Errorf("reading snapshots: %w", err))
+			return
+		}
+	}
+	if portfolioCsv != "" {
+		holdings, err := loadPortfolio(config, portfolioCsv)
+		if err != nil {
+			printError(err)
+			return
+		}
+		if len(snapshots) == 0 || holdings.AsOf.After(snapshots[len(snapshots)-1].AsOf) {
+			snapshots = append(snapshots, *newSnapshot(holdings))
+		}
+	}
+	if len(snapshots) == 0 {
+		printError(codedErrorf(CodeUsage, "no snapshots to measure progress from; record one with snapshot or pass -portfolio"))
+		return
+	}
+
+	statuses := make([]GoalStatus, 0, len(goals))
+	for _, g := range goals {
+		statuses = append(statuses, goalCalc(config, &g, snapshots))
+	}
+
+	if format == "json" {
+		if err := writeJSON(os.Stdout, statuses); err != nil {
+			printError(err)
+		}
+		return
+	}
+	for i, status := range statuses {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println(rule())
+		fmt.Printf("%s: %s by %s\n", status.Name, formatAmount(status.Target, true), status.Date.Format(time.DateOnly))
+		fmt.Println(rule())
+		fmt.Printf("Current: %s as of %s (%.0f%%)\n", formatAmount(status.Current, true), status.AsOf.Format(time.DateOnly), 100*float64(status.Current)/float64(status.Target))
+		if status.Months <= 0 {
+			fmt.Printf("Status: %s\n", status.Status)
+			continue
+		}
+		fmt.Printf("Expected return: %.1f%% a year over %.0f months\n", status.ExpectedReturn, status.Months)
+		if status.Pace != nil {
+			fmt.Printf("Saving pace: %s a month since %s\n", formatAmount(*status.Pace, true), status.PaceSince.Format(time.DateOnly))
+			fmt.Printf("Projected: %s at that pace\n", formatAmount(status.Projected, true))
+		} else {
+			fmt.Printf("Projected: %s without contributions\n", formatAmount(status.Projected, true))
+		}
+		line := "Status: " + status.Status
+		if status.Needed > 0 {
+			line += fmt.Sprintf("; %s a month reaches the target", formatAmount(status.Needed, true))
+		}
+		switch status.Status {
+		case "reached", "on track":
+			line = green(line)
+		default:
+			line = red(line)
+		}
+		fmt.Println(line)
+	}
+}
+
+// goalCalc measures a goal against the last of snapshots, oldest first. The
+// saving pace comes from the oldest snapshot within the year before it.
+func goalCalc(config *Config, goal *GoalConfig, snapshots []Snapshot) GoalStatus {
+	latest := &snapshots[len(snapshots)-1]
+	status := GoalStatus{
+		Name:           goal.Name,
+		Target:         int(goal.Target),
+		Date:           goal.Date,
+		AsOf:           latest.AsOf,
+		Current:        goal.value(latest),
+		ExpectedReturn: goal.expectedReturn(config),
+		Months:         goal.Date.Sub(latest.AsOf).Hours() / 24 / daysPerMonth,
+	}
+	if status.Months <= 0 {
+		status.Projected = status.Current
+		status.Status = "missed"
+		if status.Current >= status.Target {
+			status.Status = "reached"
+		}
+		return status
+	}
+	monthly := math.Pow(1+status.ExpectedReturn/100, 1.0/12)
+	status.Needed = max(0, int(math.Ceil(contribution(float64(status.Current), float64(status.Target), monthly, status.Months))))
+
+	yearAgo := latest.AsOf.AddDate(-1, 0, 0)
+	for i := range snapshots {
+		since := &snapshots[i]
+		if since.AsOf.Before(yearAgo) {
+			continue
+		}
+		if months := latest.AsOf.Sub(since.AsOf).Hours() / 24 / daysPerMonth; months >= 1 {
+			pace := int(math.Round(contribution(float64(goal.value(since)), float64(status.Current), monthly, months)))
+			status.Pace, status.PaceSince = &pace, since.AsOf
+		}
+		break
+	}
+
+	growth := math.Pow(monthly, status.Months)
+	projected := float64(status.Current) * growth
+	if status.Pace != nil {
+		projected += float64(*status.Pace) * annuity(monthly, status.Months)
+	}
+	status.Projected = int(math.Round(projected))
+	switch {
+	case status.Current >= status.Target:
+		status.Status = "reached"
+	case status.Projected >= status.Target:
+		status.Status = "on track"
+	default:
+		status.Status = "behind"
+	}
+	return status
+}
+
+// annuity is what a contribution of 1 at the end of each month grows to over
+// months at a monthly growth factor
+func annuity(monthly, months float64) float64 {
+	if monthly == 1 {
+		return months
+	}
+	return (math.Pow(monthly, months) - 1) / (monthly - 1)
+}
+
+// contribution is the monthly contribution that takes start to end over
+// months at a monthly growth factor
+func contribution(start, end, monthly, months float64) float64 {
+	return (end - start*math.Pow(monthly, months)) / annuity(monthly, months)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestGoalCalc(t *testing.T) {
+	zero := 0.0
+	config := &Config{}
+	day := func(year int, month time.Month) time.Time { return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC) }
+	snapshots := []Snapshot{
+		{AsOf: day(2025, time.April), Total: 1000000},
+		{AsOf: day(2025, time.October), Total: 3000000, Accounts: map[string]int{"Roth IRA": 1000000}},
+		{AsOf: day(2026, time.April), Total: 4500000, Accounts: map[string]int{"Roth IRA": 1500000}},
+		{AsOf: day(2026, time.October), Total: 6000000, Accounts: map[string]int{"Roth IRA": 2000000}},
+	}
+
+	// Saving $2,500 a month since a year ago falls short of the $5,000 a
+	// month needed over the next year; the older snapshot isn't counted
+	house := &GoalConfig{Name: "House", Target: 12000000, Date: day(2027, time.October), ExpectedReturn: &zero}
+	status := goalCalc(config, house, snapshots)
+	if status.Status != "behind" || status.Pace == nil || !status.PaceSince.Equal(day(2025, time.October)) {
+		t.Fatalf("Status mismatch: got %+v", status)
+	}
+	if pace := *status.Pace; pace < 249000 || pace > 251000 {
+		t.Errorf("Pace mismatch: got %d, expected about 250000", pace)
+	}
+	if status.Needed < 499000 || status.Needed > 501000 {
+		t.Errorf("Needed mismatch: got %d, expected about 500000", status.Needed)
+	}
+	if status.Projected < 8990000 || status.Projected > 9010000 {
+		t.Errorf("Projected mismatch: got %d, expected about 9000000", status.Projected)
+	}
+
+	// Growth alone gets the Roth IRA there, so nothing more is needed
+	retirement := &GoalConfig{Name: "Retirement", Target: 2100000, Date: day(2027, time.October), Accounts: []string{"Roth IRA"}}
+	status = goalCalc(config, retirement, snapshots)
+	if status.Status != "on track" || status.Needed != 0 || status.Current != 2000000 || status.ExpectedReturn != defaultExpectedReturn {
+		t.Errorf("Status mismatch: got %+v", status)
+	}
+
+	// Contributing the needed amount lands on the target
+	growth := 6.0
+	retirement.Target, retirement.ExpectedReturn = 5000000, &growth
+	status = goalCalc(config, retirement, snapshots)
+	monthly := math.Pow(1+growth/100, 1.0/12)
+	projected := float64(status.Current)*math.Pow(monthly, status.Months) + float64(status.Needed)*annuity(monthly, status.Months)
+	if projected < 5000000 || projected > 5000100 {
+		t.Errorf("Expected the needed contribution to reach the target, got %v", projected)
+	}
+
+	// Without an older snapshot there is no pace to project from
+	status = goalCalc(config, house, snapshots[3:])
+	if status.Pace != nil || status.Projected != 6000000 {
+		t.Errorf("Expected no pace, got %+v", status)
+	}
+
+	house.Date = day(2026, time.June)
+	if status = goalCalc(config, house, snapshots); status.Status != "missed" {
+		t.Errorf("Expected a past goal short of its target to be missed, got %s", status.Status)
+	}
+}
+
+func TestValidateGoals(t *testing.T) {
+	date := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	tests := []GoalConfig{
+		{Target: 100, Date: date},
+		{Name: "House", Date: date},
+		{Name: "House", Target: 100},
+	}
+	for _, goal := range tests {
+		if err := validateGoals(&Config{Goals: []GoalConfig{goal}}); err == nil {
+			t.Errorf("Expected an error for %+v", goal)
+		}
+	}
+	goal := GoalConfig{Name: "House", Target: 100, Date: date}
+	if err := validateGoals(&Config{Goals: []GoalConfig{goal, goal}}); err == nil {
+		t.Error("Expected an error for a duplicate goal")
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// defaultCurrencyBand is how far, in percentage points, foreign-currency
+// exposure may stray from its target before rebalance warns
+const defaultCurrencyBand = 5.0
+
+// CurrencyExposureConfig is the share of the portfolio meant to be exposed
+// to currencies other than the dollar
+type CurrencyExposureConfig struct {
+	// Target is the percent of the portfolio's value in unhedged foreign
+	// currencies
+	Target float64 `yaml:"target"`
+	// Band is how many percentage points the exposure may stray from Target
+	// before a warning (default 5)
+	Band *float64 `yaml:"band,omitempty"`
+}
+
+func (c *CurrencyExposureConfig) band() float64 {
+	if c.Band != nil {
+		return *c.Band
+	}
+	return defaultCurrencyBand
+}
+
+// CurrencyWeight is a symbol's share of the portfolio held in foreign
+// currencies, hedged or not
+type CurrencyWeight struct {
+	Symbol  string  `json:"symbol"`
+	Dollar  float64 `json:"dollar_percentage"`
+	Foreign float64 `json:"foreign_percentage"`
+	Hedged  bool    `json:"hedged"`
+	// Exposure is the symbol's unhedged foreign currency as a percent of the
+	// portfolio; a hedged fund has none
+	Exposure       float64 `json:"exposure_percentage"`
+	TargetExposure float64 `json:"target_exposure_percentage"`
+}
+
+// CurrencyExposure is the portfolio's foreign-currency exposure, as a
+// percentage of its value
+type CurrencyExposure struct {
+	Symbols []CurrencyWeight `json:"symbols"`
+	// Total is the unhedged exposure, and Hedged the foreign holdings whose
+	// currency is hedged back to the dollar
+	Total  float64 `json:"total_percentage"`
+	Hedged float64 `json:"hedged_percentage"`
+	// Allocation is the exposure of the target allocation, and Target the
+	// configured currency_exposure target, when there is one
+	Allocation float64  `json:"allocation_percentage"`
+	Target     *float64 `json:"target_percentage,omitempty"`
+	Band       float64  `json:"band,omitempty"`
+}
+
+func validateCurrencyExposure(config *Config) error {
+	for _, stock := range config.Stocks {
+		if stock.ForeignCurrency != nil && (*stock.ForeignCurrency < 0 || *stock.ForeignCurrency > 100) {
+			return fmt.Errorf("foreign_currency of %s must be between 0 and 100", stock.Symbol)
+		}
+	}
+	if exposure := config.CurrencyExposure; exposure != nil {
+		if exposure.Target < 0 || exposure.Target > 100 {
+			return fmt.Errorf("currency_exposure target must be between 0 and 100")
+		}
+		if exposure.Band != nil && *exposure.Band < 0 {
+			return fmt.Errorf("currency_exposure band must not be negative")
+		}
+	}
+	return nil
+}
+
+// foreignCurrency is the percent of the stock's value held in currencies
+// other than the dollar: foreign_currency if set, or else all of it for a
+// stock quoted in another currency
+func (s Stock) foreignCurrency() float64 {
+	if s.ForeignCurrency != nil {
+		return *s.ForeignCurrency
+	}
+	if s.Currency != "" && s.Currency != "USD" {
+		return 100
+	}
+	return 0
+}
+
+// currencyExposed reports whether the rebalance report should include
+// foreign-currency exposure: when a target is set or some stock is foreign
+func (c *Config) currencyExposed() bool {
+	if c.CurrencyExposure != nil {
+		return true
+	}
+	for _, stock := range c.Stocks {
+		if stock.foreignCurrency() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// currencyExposureCalc sums the foreign currency in each stock's current
+// and target weights, counting hedged funds apart
+func currencyExposureCalc(config *Config, symbols map[string]SymbolData) *CurrencyExposure {
+	exposure := &CurrencyExposure{}
+	for _, stock := range config.Stocks {
+		foreign := stock.foreignCurrency()
+		if foreign == 0 {
+			continue
+		}
+		data := symbols[stock.Symbol]
+		weight := CurrencyWeight{Symbol: stock.Symbol, Dollar: data.CurrentPercentage, Foreign: foreign, Hedged: stock.Hedged}
+		if stock.Hedged {
+			exposure.Hedged += data.CurrentPercentage * foreign / 100
+		} else {
+			weight.Exposure = data.CurrentPercentage * foreign / 100
+			weight.TargetExposure = stock.TargetPercentage * foreign / 100
+		}
+		exposure.Symbols = append(exposure.Symbols, weight)
+		exposure.Total += weight.Exposure
+		exposure.Allocation += weight.TargetExposure
+	}
+	if config.CurrencyExposure != nil {
+		exposure.Target = &config.CurrencyExposure.Target
+		exposure.Band = config.CurrencyExposure.band()
+	}
+	return exposure
+}
+
+// outOfBand reports whether the exposure is further from its target than
+// the band allows
+func (e *CurrencyExposure) outOfBand() bool {
+	return e.Target != nil && math.Abs(e.Total-*e.Target) > e.Band
+}
+
+func printCurrencyExposure(config *Config, result *RebalanceResult) {
+	if !config.currencyExposed() {
+		return
+	}
+	exposure := currencyExposureCalc(config, result.Symbols)
+	fmt.Println("\n" + rule())
+	fmt.Println("Foreign currency exposure")
+	fmt.Println(rule())
+	for _, weight := range exposure.Symbols {
+		if weight.Hedged {
+			fmt.Printf("%s - %.2f%% of the portfolio, %g%% foreign, hedged\n", weight.Symbol, weight.Dollar, weight.Foreign)
+			continue
+		}
+		fmt.Printf("%s - %.2f%% of the portfolio, %g%% foreign: %.2f%% exposure\n", weight.Symbol, weight.Dollar, weight.Foreign, weight.Exposure)
+	}
+	line := fmt.Sprintf("Total exposure: %.2f%% of the portfolio (%.2f%% at the target allocation", exposure.Total, exposure.Allocation)
+	if exposure.Target != nil {
+		line += fmt.Sprintf("; target %.2f%%, %s", *exposure.Target, driftText(exposure.Total-*exposure.Target))
+	}
+	fmt.Println(line + ")")
+	if exposure.Hedged > 0 {
+		fmt.Printf("Hedged: %.2f%% of the portfolio\n", exposure.Hedged)
+	}
+	if exposure.outOfBand() {
+		fmt.Println(red(fmt.Sprintf("Warning: foreign currency exposure of %.2f%% is more than %g points from its %.2f%% target", exposure.Total, exposure.Band, *exposure.Target)))
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestCurrencyExposureCalc(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	if config.currencyExposed() {
+		t.Fatal("Expected no currency exposure without foreign stocks")
+	}
+	// VXUS is all foreign currency, and BND stands in for a hedged fund
+	// that's half foreign
+	all, half := 100.0, 50.0
+	config.Stocks[1].ForeignCurrency = &all
+	config.Stocks[2].ForeignCurrency = &half
+	config.Stocks[2].Hedged = true
+	config.CurrencyExposure = &CurrencyExposureConfig{Target: 25}
+	if err := config.validate(); err != nil {
+		t.Fatalf("validate failed: %v", err)
+	}
+	symbols := map[string]SymbolData{
+		"VTI":  {CurrentPercentage: 70},
+		"VXUS": {CurrentPercentage: 12},
+		"BND":  {CurrentPercentage: 18},
+	}
+
+	exposure := currencyExposureCalc(config, symbols)
+	if len(exposure.Symbols) != 2 || exposure.Symbols[0].Exposure != 12 || exposure.Symbols[1].Exposure != 0 {
+		t.Errorf("Symbol exposure mismatch: %+v", exposure.Symbols)
+	}
+	if exposure.Total != 12 || exposure.Hedged != 9 || exposure.Allocation != 18 || *exposure.Target != 25 {
+		t.Errorf("Total exposure mismatch: %+v", exposure)
+	}
+	if !exposure.outOfBand() {
+		t.Error("Expected 12% exposure to be out of the 25% target's band")
+	}
+	band := 15.0
+	config.CurrencyExposure.Band = &band
+	if currencyExposureCalc(config, symbols).outOfBand() {
+		t.Error("Expected 12% exposure within a 15 point band")
+	}
+
+	// A stock quoted in another currency is foreign unless configured
+	for _, tt := range []struct {
+		stock    Stock
+		expected float64
+	}{
+		{Stock{}, 0},
+		{Stock{Currency: "USD"}, 0},
+		{Stock{Currency: "EUR"}, 100},
+		{Stock{Currency: "EUR", ForeignCurrency: &half}, 50},
+	} {
+		if got := tt.stock.foreignCurrency(); math.Abs(got-tt.expected) > 1e-9 {
+			t.Errorf("foreignCurrency(%+v) = %g, expected %g", tt.stock, got, tt.expected)
+		}
+	}
+
+	over := 120.0
+	config.Stocks[1].ForeignCurrency = &over
+	if err := config.validate(); err == nil {
+		t.Error("Expected an error for foreign_currency above 100")
+	}
+}
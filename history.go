@@ -0,0 +1,318 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotCSVHeader heads a snapshots file ending in .csv, which has a row for
+// each symbol amount, cash balance, account total, and liability of every
+// snapshot, and for the content hash of its export, so that recording one
+// only ever adds lines
+var snapshotCSVHeader = []string{"as_of", "kind", "name", "amount"}
+
+// isCSVLedger reports whether a snapshots file is kept as CSV rather than
+// JSON Lines
+func isCSVLedger(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".csv")
+}
+
+func history(config *Config, args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: fin-tilt history show|import|prune|chart|list [<args>]")
+		return
+	}
+	if args[0] == "list" {
+		historyList(args[1:])
+		return
+	}
+	if config.Snapshots == "" {
+		printError(codedErrorf(CodeConfigInvalid, "config has no snapshots file"))
+		return
+	}
+	switch args[0] {
+	case "show":
+		historyShow(config, args[1:])
+	case "import":
+		recordSnapshot(config, "history import", args[1:])
+	case "prune":
+		historyPrune(config, args[1:])
+	case "chart":
+		historyChart(config, args[1:])
+	default:
+		printError(codedErrorf(CodeUsage, "unknown history command %q", args[0]))
+	}
+}
+
+func historyShow(config *Config, args []string) {
+	var sinceStr, format string
+	flagSet := flag.NewFlagSet("history show", flag.ExitOnError)
+	flagSet.StringVar(&sinceStr, "since", "", "Only show snapshots on or after this date (YYYY-MM-DD)")
+	flagSet.StringVar(&format, "format", "text", "Output format: text or json")
+	flagSet.Parse(args)
+
+	var since time.Time
+	if sinceStr != "" {
+		var err error
+		if since, err = time.Parse(time.DateOnly, sinceStr); err != nil {
+			printError(codedErrorf(CodeUsage, "parsing since date: %w", err))
+			return
+		}
+	}
+	snapshots, err := readSnapshots(config.Snapshots)
+	if err != nil {
+		printError(err)
+		return
+	}
+	previous := 0
+	shown := []Snapshot{}
+	for i, s := range snapshots {
+		if s.AsOf.Before(since) {
+			previous = s.Total
+			continue
+		}
+		shown = append(shown, snapshots[i])
+	}
+
+	switch format {
+	case "text":
+		if len(shown) == 0 {
+			fmt.Println("No snapshots recorded")
+			return
+		}
+		for _, s := range shown {
+			line := fmt.Sprintf("%s  %15s", s.AsOf.Format(time.DateOnly), formatAmount(s.Total, true))
+			if previous != 0 {
+				line += "  " + changeText(s.Total-previous)
+			}
+			fmt.Println(line)
+			previous = s.Total
+		}
+	case "json":
+		if err := writeJSON(os.Stdout, shown); err != nil {
+			printError(err)
+		}
+	default:
+		printError(codedErrorf(CodeUsage, "unknown format %q", format))
+	}
+}
+
+// changeText formats a change in value, green with a "+" when up and red
+// when down, or spelled out under -plain
+func changeText(change int) string {
+	switch {
+	case plain && change >= 0:
+		return "up " + formatAmount(change, true)
+	case plain:
+		return "down " + formatAmount(-change, true)
+	case change >= 0:
+		return green("+" + formatAmount(change, true))
+	}
+	return red(formatAmount(change, true))
+}
+
+func historyPrune(config *Config, args []string) {
+	var beforeStr string
+	var monthly bool
+	flagSet := flag.NewFlagSet("history prune", flag.ExitOnError)
+	flagSet.StringVar(&beforeStr, "before", "", "Prune snapshots before this date (YYYY-MM-DD)")
+	flagSet.BoolVar(&monthly, "monthly", false, "Keep the last snapshot of each month before the date")
+	flagSet.Parse(args)
+	if beforeStr == "" {
+		printError(codedErrorf(CodeUsage, "history prune needs -before"))
+		return
+	}
+	before, err := time.Parse(time.DateOnly, beforeStr)
+	if err != nil {
+		printError(codedErrorf(CodeUsage, "parsing before date: %w", err))
+		return
+	}
+
+	guard, err := guardStore(config.Snapshots)
+	if err != nil {
+		printError(err)
+		return
+	}
+	snapshots, err := readSnapshots(config.Snapshots)
+	if err != nil {
+		printError(err)
+		return
+	}
+	kept := pruneSnapshots(snapshots, before, monthly)
+	if len(kept) == len(snapshots) {
+		fmt.Println("No snapshots to prune")
+		return
+	}
+	var preview strings.Builder
+	fmt.Fprintf(&preview, "%d snapshots will be removed from %s:\n", len(snapshots)-len(kept), config.Snapshots)
+	for _, s := range snapshots {
+		if !slices.ContainsFunc(kept, func(k Snapshot) bool { return k.AsOf.Equal(s.AsOf) }) {
+			fmt.Fprintf(&preview, "%s  %s\n", s.AsOf.Format(time.DateTime), formatAmount(s.Total, true))
+		}
+	}
+	if skipDryRun("Prune "+config.Snapshots, preview.String()) {
+		return
+	}
+	if err := confirm("Prune "+config.Snapshots, preview.String()); err != nil {
+		printError(err)
+		return
+	}
+	if err := guard.write(func() error { return writeSnapshots(config.Snapshots, kept) }); err != nil {
+		printError(fmt.Errorf("pruning snapshots: %w", err))
+		return
+	}
+	fmt.Printf("Pruned %d snapshots from %s, %d left\n", len(snapshots)-len(kept), config.Snapshots, len(kept))
+}
+
+// pruneSnapshots drops snapshots, sorted oldest first, from before the given
+// date. With monthly, the last snapshot of each earlier month is kept.
+func pruneSnapshots(snapshots []Snapshot, before time.Time, monthly bool) []Snapshot {
+	var kept []Snapshot
+	for i, s := range snapshots {
+		lastOfMonth := i == len(snapshots)-1 || snapshots[i+1].AsOf.Format("2006-01") != s.AsOf.Format("2006-01")
+		if !s.AsOf.Before(before) || (monthly && lastOfMonth) {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+// writeSnapshots replaces the contents of path with snapshots. The file is
+// written next to path and renamed over it, so an interrupted prune leaves
+// the old file intact.
+func writeSnapshots(path string, snapshots []Snapshot) error {
+	temp := path + ".tmp"
+	if err := os.Remove(temp); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	var err error
+	if isCSVLedger(path) {
+		err = appendSnapshotsCSV(temp, snapshots)
+	} else {
+		err = appendJSONLines(temp, snapshots)
+	}
+	if err != nil {
+		os.Remove(temp)
+		return err
+	}
+	return os.Rename(temp, path)
+}
+
+// readSnapshotsCSV loads the snapshots in a CSV ledger. A missing file has
+// no snapshots.
+func readSnapshotsCSV(path string) ([]Snapshot, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = len(snapshotCSVHeader)
+	if _, err := reader.Read(); err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var snapshots []Snapshot
+	index := make(map[string]int)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		line, _ := reader.FieldPos(0)
+		asOf, err := time.Parse(time.RFC3339, record[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		amount, err := amountToInt(record[3])
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		i, ok := index[record[0]]
+		if !ok {
+			i = len(snapshots)
+			index[record[0]] = i
+			snapshots = append(snapshots, Snapshot{AsOf: asOf, Amounts: map[string]int{}, Cash: map[string]int{}, Accounts: map[string]int{}})
+		}
+		s := &snapshots[i]
+		switch record[1] {
+		case "symbol":
+			s.Amounts[record[2]] = amount
+			s.Total += amount
+		case "cash":
+			s.Cash[record[2]] = amount
+			s.Total += amount
+		case "account":
+			s.Accounts[record[2]] = amount
+		case "liability":
+			if s.Liabilities == nil {
+				s.Liabilities = make(map[string]int)
+			}
+			s.Liabilities[record[2]] = amount
+		case "source":
+			s.Source = record[2]
+		default:
+			return nil, fmt.Errorf("%s line %d: unknown kind %q", path, line, record[1])
+		}
+	}
+	return snapshots, nil
+}
+
+// appendSnapshotsCSV adds rows for snapshots to the end of a CSV ledger,
+// writing the header first if the file is new or empty
+func appendSnapshotsCSV(path string, snapshots []Snapshot) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	writer := csv.NewWriter(file)
+	if info.Size() == 0 {
+		writer.Write(snapshotCSVHeader)
+	}
+	for _, s := range snapshots {
+		asOf := s.AsOf.Format(time.RFC3339Nano)
+		if s.Source != "" {
+			writer.Write([]string{asOf, "source", s.Source, formatDecimal(0)})
+		}
+		for _, group := range []struct {
+			kind    string
+			amounts map[string]int
+		}{{"symbol", s.Amounts}, {"cash", s.Cash}, {"account", s.Accounts}, {"liability", s.Liabilities}} {
+			names := make([]string, 0, len(group.amounts))
+			for name := range group.amounts {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				writer.Write([]string{asOf, group.kind, name, formatDecimal(group.amounts[name])})
+			}
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
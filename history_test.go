@@ -0,0 +1,142 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotsCSVLedger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshots.csv")
+	if snapshots, err := readSnapshots(path); err != nil || len(snapshots) != 0 {
+		t.Fatalf("Missing file should have no snapshots: %v, %v", snapshots, err)
+	}
+
+	later := time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)
+	earlier := later.AddDate(0, -1, 0)
+	for _, asOf := range []time.Time{later, earlier, later} {
+		holdings := &Holdings{
+			AsOf:          asOf,
+			Amounts:       map[string]int{"VTI": 100050, "BND": 40000},
+			Cash:          map[string]int{"Roth IRA": 5000},
+			AccountTotals: map[string]int{"Roth IRA": 145050},
+		}
+		if _, err := appendSnapshot(path, newSnapshot(holdings)); err != nil {
+			t.Fatalf("appendSnapshot failed: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 9 || lines[0] != "as_of,kind,name,amount" || lines[1] != "2026-10-01T00:00:00Z,symbol,BND,400.00" {
+		t.Fatalf("Ledger mismatch: got\n%s", data)
+	}
+
+	snapshots, err := readSnapshots(path)
+	if err != nil {
+		t.Fatalf("readSnapshots failed: %v", err)
+	}
+	if len(snapshots) != 2 || !snapshots[0].AsOf.Equal(earlier) {
+		t.Fatalf("Expected 2 snapshots oldest first, got %+v", snapshots)
+	}
+	s := snapshots[1]
+	if s.Total != 145050 || s.Amounts["VTI"] != 100050 || s.Cash["Roth IRA"] != 5000 || s.Accounts["Roth IRA"] != 145050 {
+		t.Errorf("Snapshot mismatch: %+v", s)
+	}
+}
+
+func TestPruneSnapshots(t *testing.T) {
+	var snapshots []Snapshot
+	for _, date := range []string{"2025-01-10", "2025-01-31", "2025-02-15", "2025-03-01", "2025-03-20"} {
+		asOf, _ := time.Parse(time.DateOnly, date)
+		snapshots = append(snapshots, Snapshot{AsOf: asOf, Total: 100, Amounts: map[string]int{"VTI": 100}})
+	}
+	before := time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC)
+
+	dates := func(snapshots []Snapshot) string {
+		var out []string
+		for _, s := range snapshots {
+			out = append(out, s.AsOf.Format(time.DateOnly))
+		}
+		return strings.Join(out, " ")
+	}
+	if got := dates(pruneSnapshots(snapshots, before, false)); got != "2025-03-20" {
+		t.Errorf("Prune mismatch: got %s", got)
+	}
+	// The March 1 snapshot isn't the last of its month, which continues past the cutoff
+	if got := dates(pruneSnapshots(snapshots, before, true)); got != "2025-01-31 2025-02-15 2025-03-20" {
+		t.Errorf("Monthly prune mismatch: got %s", got)
+	}
+
+	for _, name := range []string{"snapshots.jsonl", "snapshots.csv"} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := writeSnapshots(path, snapshots[:2]); err != nil {
+			t.Fatalf("writeSnapshots failed: %v", err)
+		}
+		if err := writeSnapshots(path, snapshots[1:2]); err != nil {
+			t.Fatalf("writeSnapshots failed: %v", err)
+		}
+		if got, err := readSnapshots(path); err != nil || dates(got) != "2025-01-31" {
+			t.Errorf("%s: expected only the rewritten snapshot, got %v (%v)", name, got, err)
+		}
+	}
+}
+
+func TestSnapshotImportDedupe(t *testing.T) {
+	dir := t.TempDir()
+	data, err := os.ReadFile(filepath.Join("tests", "portfolios", "cash.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	export := filepath.Join(dir, "export.csv")
+	renamed := filepath.Join(dir, "export (1).csv")
+	for _, path := range []string{export, renamed} {
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The same content hashes alike under any name, and differently once changed
+	source, err := exportHash(export)
+	if err != nil || len(source) != 16 {
+		t.Fatalf("exportHash failed: %q (%v)", source, err)
+	}
+	if again, _ := exportHash(renamed); again != source {
+		t.Errorf("Expected the renamed export to hash alike, got %q and %q", source, again)
+	}
+	if both, _ := exportHash(export + "," + renamed); both == source {
+		t.Error("Expected two exports to hash unlike one")
+	}
+
+	for _, path := range []string{filepath.Join(dir, "snapshots.jsonl"), filepath.Join(dir, "snapshots.csv")} {
+		first := &Snapshot{AsOf: time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC), Total: 100, Amounts: map[string]int{"VTI": 100}, Source: source}
+		if added, err := appendSnapshot(path, first); !added || err != nil {
+			t.Fatalf("%s: first import failed: %v", path, err)
+		}
+		// Downloading the same export again changes its modification time,
+		// but it's still a no-op
+		again := *first
+		again.AsOf = first.AsOf.Add(time.Hour)
+		again.Total = 200
+		again.Amounts = map[string]int{"VTI": 200}
+		if added, err := appendSnapshot(path, &again); added || err != nil {
+			t.Errorf("%s: expected the same export skipped, got %v (%v)", path, added, err)
+		}
+		existing, err := readSnapshots(path)
+		if err != nil || len(existing) != 1 || existing[0].Source != source {
+			t.Fatalf("%s: expected one snapshot with its source, got %+v (%v)", path, existing, err)
+		}
+
+		// Forcing it replaces the earlier snapshot
+		if err := replaceSnapshots(path, existing, &again); err != nil {
+			t.Fatalf("%s: replaceSnapshots failed: %v", path, err)
+		}
+		if snapshots, err := readSnapshots(path); err != nil || len(snapshots) != 1 || snapshots[0].Total != 200 || !snapshots[0].AsOf.Equal(again.AsOf) {
+			t.Errorf("%s: expected the snapshot replaced, got %+v (%v)", path, snapshots, err)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// sparkLevels are the bars of a sparkline from lowest to highest, and
+// plainSparkLevels their stand-ins under -plain
+var (
+	sparkLevels      = []rune("▁▂▃▄▅▆▇█")
+	plainSparkLevels = []rune(".:-=+*#@")
+)
+
+// The history chart has a panel of each symbol's drift over time above a
+// panel of the total value
+const (
+	historyChartHeight = 560
+	historyChartLeft   = 80
+	historyChartRight  = 720
+	historyDriftTop    = 60
+	historyDriftBottom = 300
+	historyValueTop    = 360
+	historyValueBottom = 500
+)
+
+// DriftSeries is one symbol's drift at each snapshot of a DriftHistory
+type DriftSeries struct {
+	Symbol           string    `json:"symbol"`
+	TargetPercentage float64   `json:"target_percentage"`
+	Band             float64   `json:"band"`
+	Drift            []float64 `json:"drift"`
+	// Outside counts the snapshots at which the drift was past the band
+	Outside int `json:"outside"`
+	// Largest is the furthest the drift got from target either way
+	Largest float64 `json:"largest"`
+}
+
+// DriftHistory is each symbol's drift and the total value at every snapshot
+type DriftHistory struct {
+	Band    string        `json:"band"`
+	Dates   []time.Time   `json:"dates"`
+	Totals  []int         `json:"totals"`
+	Symbols []DriftSeries `json:"symbols"`
+}
+
+func historyChart(config *Config, args []string) {
+	var sinceStr, format, outputPath string
+	flagSet := flag.NewFlagSet("history chart", flag.ExitOnError)
+	flagSet.StringVar(&sinceStr, "since", "", "Only chart snapshots on or after this date (YYYY-MM-DD)")
+	flagSet.StringVar(&format, "format", "text", "Output format: text, svg, or json")
+	flagSet.StringVar(&outputPath, "o", "", "Write the SVG chart to a file instead of stdout")
+	flagSet.Parse(args)
+	if format != "text" && format != "svg" && format != "json" {
+		printError(codedErrorf(CodeUsage, "unknown format %q", format))
+		return
+	}
+
+	var since time.Time
+	if sinceStr != "" {
+		var err error
+		if since, err = time.Parse(time.DateOnly, sinceStr); err != nil {
+			printError(codedErrorf(CodeUsage, "parsing since date: %w", err))
+			return
+		}
+	}
+	snapshots, err := readSnapshots(config.Snapshots)
+	if err != nil {
+		printError(err)
+		return
+	}
+	var shown []Snapshot
+	for _, s := range snapshots {
+		if !s.AsOf.Before(since) {
+			shown = append(shown, s)
+		}
+	}
+	history := driftHistoryCalc(config, shown)
+
+	switch format {
+	case "json":
+		if err := writeJSON(os.Stdout, history); err != nil {
+			printError(err)
+		}
+		return
+	case "svg":
+		if len(history.Dates) == 0 {
+			printError(fmt.Errorf("no snapshots with value in a configured symbol to chart"))
+			return
+		}
+		err := writeOutput(outputPath, func(w io.Writer) error {
+			return writeDriftHistorySVG(w, history)
+		})
+		if err != nil {
+			printError(err)
+		}
+		return
+	}
+
+	if len(history.Dates) == 0 {
+		fmt.Println("No snapshots recorded")
+		return
+	}
+	count := len(history.Dates)
+	fmt.Printf("Drift against the %s, %s to %s (%d snapshots)\n", history.Band, history.Dates[0].Format(time.DateOnly), history.Dates[count-1].Format(time.DateOnly), count)
+	fmt.Println(rule())
+	for _, s := range history.Symbols {
+		limit := max(s.Band, s.Largest)
+		fmt.Printf("%-6s %s %8s  band %5.2f  largest %5.2f  outside %d of %d\n", s.Symbol, sparkline(s.Drift, -limit, limit), driftText(s.Drift[count-1]), s.Band, s.Largest, s.Outside, count)
+	}
+	totals := make([]float64, count)
+	low, high := math.Inf(1), math.Inf(-1)
+	for i, total := range history.Totals {
+		totals[i] = float64(total)
+		low, high = min(low, totals[i]), max(high, totals[i])
+	}
+	fmt.Printf("%-6s %s %s\n", "Total", sparkline(totals, low, high), formatAmount(history.Totals[count-1], true))
+	fmt.Println()
+	fmt.Println("Each drift line runs from the band's lower edge to its upper edge, or the largest drift if wider; a symbol often outside its band may need a wider one.")
+}
+
+// driftHistoryCalc gives each configured symbol's drift from target at every
+// snapshot with value in a configured symbol, weighing each symbol against
+// the configured symbols' total as adviseCalc does
+func driftHistoryCalc(config *Config, snapshots []Snapshot) *DriftHistory {
+	history := &DriftHistory{Band: adviseBandLabel(config), Dates: []time.Time{}, Totals: []int{}, Symbols: []DriftSeries{}}
+	for _, stock := range config.Stocks {
+		history.Symbols = append(history.Symbols, DriftSeries{
+			Symbol:           stock.Symbol,
+			TargetPercentage: stock.TargetPercentage,
+			Band:             adviseBand(config, stock.TargetPercentage),
+			Drift:            []float64{},
+		})
+	}
+	for _, snapshot := range snapshots {
+		total := 0
+		for _, stock := range config.Stocks {
+			total += snapshot.Amounts[stock.Symbol]
+		}
+		if total <= 0 {
+			continue
+		}
+		history.Dates = append(history.Dates, snapshot.AsOf)
+		history.Totals = append(history.Totals, snapshot.Total)
+		for i := range history.Symbols {
+			s := &history.Symbols[i]
+			drift := float64(snapshot.Amounts[s.Symbol])/float64(total)*100 - s.TargetPercentage
+			s.Drift = append(s.Drift, drift)
+			s.Largest = max(s.Largest, math.Abs(drift))
+			if math.Abs(drift) > s.Band {
+				s.Outside++
+			}
+		}
+	}
+	return history
+}
+
+// sparkline draws values as a row of bars scaled from low to high, clamping
+// those outside the range
+func sparkline(values []float64, low, high float64) string {
+	levels := sparkLevels
+	if plain {
+		levels = plainSparkLevels
+	}
+	var line strings.Builder
+	for _, value := range values {
+		level := len(levels) / 2
+		if high > low {
+			level = int(math.Round((value - low) / (high - low) * float64(len(levels)-1)))
+			level = min(max(level, 0), len(levels)-1)
+		}
+		line.WriteRune(levels[level])
+	}
+	return line.String()
+}
+
+func writeDriftHistorySVG(w io.Writer, history *DriftHistory) error {
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n", chartWidth, historyChartHeight, chartWidth, historyChartHeight)
+	writeSVGManifest(w)
+	fmt.Fprintf(w, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	first, last := history.Dates[0], history.Dates[len(history.Dates)-1]
+	fmt.Fprintf(w, `<text x="%d" y="30" text-anchor="middle" font-size="18">Drift against the %s, %s to %s</text>`+"\n", chartWidth/2, html.EscapeString(history.Band), first.Format(time.DateOnly), last.Format(time.DateOnly))
+
+	// Snapshots are placed by date, or centered when there's only one
+	span := last.Sub(first).Seconds()
+	x := func(date time.Time) float64 {
+		if span == 0 {
+			return (historyChartLeft + historyChartRight) / 2
+		}
+		return historyChartLeft + date.Sub(first).Seconds()/span*(historyChartRight-historyChartLeft)
+	}
+	y := func(value, low, high float64, top, bottom int) float64 {
+		if high == low {
+			return float64(top+bottom) / 2
+		}
+		return float64(bottom) - (value-low)/(high-low)*float64(bottom-top)
+	}
+
+	limit := 0.0
+	for _, s := range history.Symbols {
+		limit = max(limit, s.Band, s.Largest)
+	}
+	limit = math.Ceil(limit)
+	fmt.Fprintf(w, `<text x="%d" y="%d" text-anchor="end">%+.0f</text>`+"\n", historyChartLeft-8, historyDriftTop+4, limit)
+	fmt.Fprintf(w, `<text x="%d" y="%d" text-anchor="end">0</text>`+"\n", historyChartLeft-8, (historyDriftTop+historyDriftBottom)/2+4)
+	fmt.Fprintf(w, `<text x="%d" y="%d" text-anchor="end">%+.0f</text>`+"\n", historyChartLeft-8, historyDriftBottom+4, -limit)
+	fmt.Fprintf(w, `<line x1="%d" y1="%.2f" x2="%d" y2="%.2f" stroke="#333"/>`+"\n", historyChartLeft, y(0, -limit, limit, historyDriftTop, historyDriftBottom), historyChartRight, y(0, -limit, limit, historyDriftTop, historyDriftBottom))
+	for i, s := range history.Symbols {
+		color := hexColor(chartColors[i%len(chartColors)])
+		// The band's edges are dashed in the symbol's color
+		for _, edge := range []float64{s.Band, -s.Band} {
+			fmt.Fprintf(w, `<line x1="%d" y1="%.2f" x2="%d" y2="%.2f" stroke="%s" stroke-dasharray="4 4" opacity="0.6"/>`+"\n", historyChartLeft, y(edge, -limit, limit, historyDriftTop, historyDriftBottom), historyChartRight, y(edge, -limit, limit, historyDriftTop, historyDriftBottom), color)
+		}
+		points := make([]string, len(s.Drift))
+		for j, drift := range s.Drift {
+			points[j] = fmt.Sprintf("%.2f,%.2f", x(history.Dates[j]), y(drift, -limit, limit, historyDriftTop, historyDriftBottom))
+		}
+		fmt.Fprintf(w, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"><title>%s</title></polyline>`+"\n", strings.Join(points, " "), color, html.EscapeString(s.Symbol))
+		fmt.Fprintf(w, `<text x="%d" y="%d" fill="%s">%s</text>`+"\n", historyChartRight+10, historyDriftTop+4+i*16, color, html.EscapeString(s.Symbol))
+	}
+
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, total := range history.Totals {
+		low, high = min(low, float64(total)), max(high, float64(total))
+	}
+	fmt.Fprintf(w, `<text x="%d" y="%d" text-anchor="middle">Total value</text>`+"\n", chartWidth/2, historyValueTop-12)
+	fmt.Fprintf(w, `<text x="%d" y="%d" text-anchor="end">%s</text>`+"\n", historyChartLeft-8, historyValueTop+4, formatAmount(int(high), true))
+	fmt.Fprintf(w, `<text x="%d" y="%d" text-anchor="end">%s</text>`+"\n", historyChartLeft-8, historyValueBottom+4, formatAmount(int(low), true))
+	points := make([]string, len(history.Totals))
+	for i, total := range history.Totals {
+		points[i] = fmt.Sprintf("%.2f,%.2f", x(history.Dates[i]), y(float64(total), low, high, historyValueTop, historyValueBottom))
+	}
+	fmt.Fprintf(w, `<polyline points="%s" fill="none" stroke="#333" stroke-width="2"/>`+"\n", strings.Join(points, " "))
+
+	fmt.Fprintf(w, `<text x="%d" y="%d" text-anchor="start">%s</text>`+"\n", historyChartLeft, historyValueBottom+24, first.Format(time.DateOnly))
+	fmt.Fprintf(w, `<text x="%d" y="%d" text-anchor="end">%s</text>`+"\n", historyChartRight, historyValueBottom+24, last.Format(time.DateOnly))
+	_, err := fmt.Fprintln(w, "</svg>")
+	return err
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDriftHistoryCalc(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	config.BandPolicy = "5/25"
+	start := time.Date(2026, time.January, 31, 0, 0, 0, 0, time.UTC)
+	snapshots := []Snapshot{
+		{AsOf: start, Total: 100000, Amounts: map[string]int{"VTI": 71000, "VXUS": 18000, "BND": 11000}},
+		// Only cash, so there's no drift to chart
+		{AsOf: start.AddDate(0, 1, 0), Total: 5000, Cash: map[string]int{"Individual": 5000}},
+		{AsOf: start.AddDate(0, 2, 0), Total: 110000, Amounts: map[string]int{"VTI": 80000, "VXUS": 18000, "BND": 7000}, Cash: map[string]int{"Individual": 5000}},
+	}
+
+	history := driftHistoryCalc(config, snapshots)
+	if len(history.Dates) != 2 || len(history.Totals) != 2 || history.Totals[1] != 110000 {
+		t.Fatalf("Expected the two snapshots with configured symbols, got %+v", history)
+	}
+	if history.Band != "5/25 band" {
+		t.Errorf("Expected the 5/25 band, got %q", history.Band)
+	}
+	expected := map[string][]float64{"VTI": {0, 80000.0/1050 - 71}, "VXUS": {0, 18000.0/1050 - 18}, "BND": {0, 7000.0/1050 - 11}}
+	for _, s := range history.Symbols {
+		for i, drift := range s.Drift {
+			if math.Abs(drift-expected[s.Symbol][i]) > 1e-9 {
+				t.Errorf("Expected %s drift %f at snapshot %d, got %f", s.Symbol, expected[s.Symbol][i], i, drift)
+			}
+		}
+	}
+	// VTI's +5.19 is past its 5-point band and BND's -4.33 past its 2.75
+	outside := map[string]int{"VTI": 1, "VXUS": 0, "BND": 1}
+	for _, s := range history.Symbols {
+		if s.Outside != outside[s.Symbol] {
+			t.Errorf("Expected %s outside its band %d times, got %d", s.Symbol, outside[s.Symbol], s.Outside)
+		}
+	}
+
+	var svg bytes.Buffer
+	if err := writeDriftHistorySVG(&svg, history); err != nil {
+		t.Fatalf("writeDriftHistorySVG failed: %v", err)
+	}
+	if count := strings.Count(svg.String(), "<polyline"); count != 4 {
+		t.Errorf("Expected a line per symbol and one for the total, got %d", count)
+	}
+	if !strings.Contains(svg.String(), "2026-01-31 to 2026-03-31") {
+		t.Error("Expected the SVG title to give the date range")
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	if got := sparkline([]float64{-5, 0, 5, 9}, -5, 5); got != "▁▅██" {
+		t.Errorf("Expected the range scaled onto the bars and clamped, got %q", got)
+	}
+	if got := sparkline([]float64{3, 3}, 3, 3); got != "▅▅" {
+		t.Errorf("Expected a flat line in the middle, got %q", got)
+	}
+	plain = true
+	defer func() { plain = false }()
+	if got := sparkline([]float64{-5, 5}, -5, 5); got != ".@" {
+		t.Errorf("Expected ASCII bars under -plain, got %q", got)
+	}
+}
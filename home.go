@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// homeEnv names the fin-tilt home directory when -home isn't passed
+const homeEnv = "FIN_TILT_HOME"
+
+// homeDir is the -home directory, which keeps the config, data, and cache
+// together in one place instead of the user's config, data, and cache
+// directories
+var homeDir string
+
+// HomeDirs are where fin-tilt looks for its config and keeps its data
+type HomeDirs struct {
+	// Config holds config.yaml and age-identity.txt
+	Config string `json:"config"`
+	// Data is where relative store paths in a config from Config point:
+	// snapshots, transactions, static positions, the audit log, and the
+	// report archive
+	Data string `json:"data"`
+	// Cache holds downloaded price history and exchange rates
+	Cache string `json:"cache"`
+}
+
+// homeDirs are the -home or FIN_TILT_HOME directory and its data and cache
+// subdirectories, or else ~/.config/fin-tilt, ~/.local/share/fin-tilt, and
+// the user's cache directory, following XDG_CONFIG_HOME and XDG_DATA_HOME
+func homeDirs() (HomeDirs, error) {
+	home := homeDir
+	if home == "" {
+		home = os.Getenv(homeEnv)
+	}
+	if home != "" {
+		return HomeDirs{Config: home, Data: filepath.Join(home, "data"), Cache: filepath.Join(home, "cache")}, nil
+	}
+	userHome, err := os.UserHomeDir()
+	if err != nil {
+		return HomeDirs{}, err
+	}
+	configDir, dataDir := os.Getenv("XDG_CONFIG_HOME"), os.Getenv("XDG_DATA_HOME")
+	if configDir == "" {
+		configDir = filepath.Join(userHome, ".config")
+	}
+	if dataDir == "" {
+		dataDir = filepath.Join(userHome, ".local", "share")
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return HomeDirs{}, err
+	}
+	return HomeDirs{
+		Config: filepath.Join(configDir, "fin-tilt"),
+		Data:   filepath.Join(dataDir, "fin-tilt"),
+		Cache:  filepath.Join(cacheDir, "fin-tilt"),
+	}, nil
+}
+
+// findConfig is the config to read when -config isn't passed: config.yaml in
+// the -home directory, or else in the working directory if there is one
+// there, or else in the user's fin-tilt config directory
+func findConfig() string {
+	dirs, err := homeDirs()
+	if err != nil {
+		return "config.yaml"
+	}
+	homeConfig := filepath.Join(dirs.Config, "config.yaml")
+	if homeDir != "" || os.Getenv(homeEnv) != "" {
+		return homeConfig
+	}
+	if _, err := os.Stat("config.yaml"); err == nil {
+		return "config.yaml"
+	}
+	if _, err := os.Stat(homeConfig); err == nil {
+		return homeConfig
+	}
+	return "config.yaml"
+}
+
+// resolveDataPaths points the relative store paths of a config read from the
+// fin-tilt config directory into the data directory, so that they don't
+// depend on where fin-tilt is run. Other configs' paths stay relative to the
+// working directory.
+func (config *Config) resolveDataPaths(configPath string) {
+	if configPath == stdinConfigPath {
+		return
+	}
+	dirs, err := homeDirs()
+	if err != nil {
+		return
+	}
+	configDir, err := filepath.Abs(filepath.Dir(configPath))
+	if err != nil {
+		return
+	}
+	if homeConfig, err := filepath.Abs(dirs.Config); err != nil || homeConfig != configDir {
+		return
+	}
+	for _, path := range []*string{&config.Snapshots, &config.Transactions, &config.StaticPositionsFile, &config.AuditLog, &config.ArchiveDir} {
+		if *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Join(dirs.Data, *path)
+		}
+	}
+}
+
+// homeCommand prints the fin-tilt directories and the config that's used
+// without -config
+func homeCommand(args []string) int {
+	var format string
+	flagSet := flag.NewFlagSet("home", flag.ExitOnError)
+	flagSet.StringVar(&format, "format", "text", "Output format: text or json")
+	flagSet.Parse(args)
+	if format != "text" && format != "json" {
+		printError(codedErrorf(CodeUsage, "unknown format %q", format))
+		return 1
+	}
+	dirs, err := homeDirs()
+	if err != nil {
+		printError(err)
+		return 1
+	}
+	configPath := findConfig()
+	if format == "json" {
+		output := struct {
+			HomeDirs
+			ConfigFile string `json:"config_file"`
+		}{dirs, configPath}
+		if err := writeJSON(os.Stdout, output); err != nil {
+			printError(err)
+			return 1
+		}
+		return 0
+	}
+	fmt.Printf("Config: %s\n", dirs.Config)
+	fmt.Printf("Data:   %s\n", dirs.Data)
+	fmt.Printf("Cache:  %s\n", dirs.Cache)
+	if _, err := os.Stat(configPath); errors.Is(err, fs.ErrNotExist) {
+		fmt.Printf("No config found; create %s or pass -config\n", filepath.Join(dirs.Config, "config.yaml"))
+	} else {
+		fmt.Printf("Using %s\n", configPath)
+	}
+	return 0
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHomeDirs(t *testing.T) {
+	t.Setenv(homeEnv, "")
+	t.Setenv("XDG_CONFIG_HOME", "/xdg/config")
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv("HOME", "/home/user")
+	dirs, err := homeDirs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dirs.Config != "/xdg/config/fin-tilt" || dirs.Data != "/home/user/.local/share/fin-tilt" {
+		t.Errorf("Expected the XDG config and default data directories, got %+v", dirs)
+	}
+
+	t.Setenv(homeEnv, "/env/home")
+	if dirs, _ := homeDirs(); dirs.Config != "/env/home" || dirs.Data != "/env/home/data" || dirs.Cache != "/env/home/cache" {
+		t.Errorf("Expected FIN_TILT_HOME to hold everything, got %+v", dirs)
+	}
+	saved := homeDir
+	t.Cleanup(func() { homeDir = saved })
+	homeDir = "/flag/home"
+	if dirs, _ := homeDirs(); dirs.Config != "/flag/home" {
+		t.Errorf("Expected -home to override FIN_TILT_HOME, got %+v", dirs)
+	}
+	if got := findConfig(); got != "/flag/home/config.yaml" {
+		t.Errorf("Expected the config in -home, got %q", got)
+	}
+}
+
+func TestResolveDataPaths(t *testing.T) {
+	home := t.TempDir()
+	saved := homeDir
+	t.Cleanup(func() { homeDir = saved })
+	homeDir = home
+	data, err := os.ReadFile(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = append(data, "snapshots: snapshots.jsonl\naudit_log: /var/log/fin-tilt.jsonl\n"...)
+	configPath := filepath.Join(home, "config.yaml")
+	if err := os.WriteFile(configPath, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config, err := parseConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(home, "data", "snapshots.jsonl"); config.Snapshots != want {
+		t.Errorf("Expected the snapshots in the data directory, %q, got %q", want, config.Snapshots)
+	}
+	if config.AuditLog != "/var/log/fin-tilt.jsonl" {
+		t.Errorf("Expected an absolute path to be kept, got %q", config.AuditLog)
+	}
+
+	// A config elsewhere keeps its paths relative to the working directory
+	otherPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(otherPath, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if config, err = parseConfig(otherPath); err != nil {
+		t.Fatal(err)
+	}
+	if config.Snapshots != "snapshots.jsonl" {
+		t.Errorf("Expected the snapshots path to be kept, got %q", config.Snapshots)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"text/template"
+)
+
+// HookData is what hook commands can refer to as template fields, e.g.
+// {{.ReportPath}}
+type HookData struct {
+	Command    string
+	Args       []string
+	ConfigPath string
+	// ReportPath is a file holding everything the command printed. It is only
+	// set for post hooks, and the file is left for the hook to archive.
+	ReportPath string
+	// Urgency is the drift score and level found by rebalance, for post
+	// hooks that alert on it; its Level is empty for other commands
+	Urgency Urgency
+}
+
+func validateHooks(config *Config) error {
+	for name, command := range config.Hooks {
+		if !strings.HasPrefix(name, "pre_") && !strings.HasPrefix(name, "post_") {
+			return fmt.Errorf("hook %s must be named pre_<command> or post_<command>", name)
+		}
+		if _, err := template.New(name).Parse(command); err != nil {
+			return fmt.Errorf("hook %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// runHook runs the named hook, if the config has one, through the shell. Its
+// output goes to stderr so that it can't corrupt the command's output.
+func runHook(ctx context.Context, config *Config, name string, data *HookData) error {
+	command, ok := config.Hooks[name]
+	if !ok {
+		return nil
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(command)
+	if err != nil {
+		return codedErrorf(CodeHook, "hook %s: %w", name, err)
+	}
+	var script strings.Builder
+	if err := tmpl.Execute(&script, data); err != nil {
+		return codedErrorf(CodeHook, "hook %s: %w", name, err)
+	}
+	if skipDryRun("Run hook "+name, script.String()) {
+		return nil
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", script.String())
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return codedErrorf(CodeHook, "hook %s failed: %w", name, err)
+	}
+	return nil
+}
+
+// captureOutput copies everything the command prints to stdout into a report
+// file as well. finish stops copying and restores stdout.
+func captureOutput(command string) (path string, finish func() error, err error) {
+	report, err := os.CreateTemp("", "fin-tilt-"+command+"-*.txt")
+	if err != nil {
+		return "", nil, err
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		report.Close()
+		return "", nil, err
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(io.MultiWriter(stdout, report), r)
+		done <- err
+	}()
+	finish = func() error {
+		os.Stdout = stdout
+		w.Close()
+		copyErr := <-done
+		r.Close()
+		if err := report.Close(); err != nil {
+			return err
+		}
+		return copyErr
+	}
+	return report.Name(), finish, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "archive.txt")
+	config := &Config{Hooks: map[string]string{
+		"pre_rebalance":  "echo {{.Command}} {{index .Args 0}} > " + filepath.Join(dir, "pre.txt"),
+		"post_rebalance": "cp {{.ReportPath}} " + archive,
+		"pre_deposit":    "exit 3",
+	}}
+	if err := validateHooks(config); err != nil {
+		t.Fatalf("validateHooks failed: %v", err)
+	}
+	data := &HookData{Command: "rebalance", Args: []string{"portfolio.csv"}}
+	ctx := context.Background()
+
+	if err := runHook(ctx, config, "pre_rebalance", data); err != nil {
+		t.Fatalf("pre hook failed: %v", err)
+	}
+	if pre, _ := os.ReadFile(filepath.Join(dir, "pre.txt")); string(pre) != "rebalance portfolio.csv\n" {
+		t.Errorf("Pre hook output mismatch: got %q", pre)
+	}
+
+	path, finish, err := captureOutput("rebalance")
+	if err != nil {
+		t.Fatalf("captureOutput failed: %v", err)
+	}
+	defer os.Remove(path)
+	fmt.Println("Total: $1,000.00")
+	if err := finish(); err != nil {
+		t.Fatalf("finish failed: %v", err)
+	}
+	data.ReportPath = path
+	if err := runHook(ctx, config, "post_rebalance", data); err != nil {
+		t.Fatalf("post hook failed: %v", err)
+	}
+	if report, _ := os.ReadFile(archive); string(report) != "Total: $1,000.00\n" {
+		t.Errorf("Archived report mismatch: got %q", report)
+	}
+
+	if err := runHook(ctx, config, "post_deposit", data); err != nil {
+		t.Errorf("Expected a missing hook to be skipped, got %v", err)
+	}
+	if err := runHook(ctx, config, "pre_deposit", data); errorCode(err) != CodeHook || !strings.Contains(err.Error(), "exit status 3") {
+		t.Errorf("Expected the hook's exit status, got %v", err)
+	}
+
+	for _, hooks := range []map[string]string{{"before_rebalance": "true"}, {"pre_rebalance": "{{.Missing"}} {
+		if err := validateHooks(&Config{Hooks: hooks}); err == nil {
+			t.Errorf("Expected %v to be invalid", hooks)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HouseholdMember is one of several configs, whose allocation applies only
+// to the accounts it lists
+type HouseholdMember struct {
+	Name     string           `json:"name"`
+	Accounts []string         `json:"accounts"`
+	Result   *RebalanceResult `json:"result"`
+	Config   *Config          `json:"-"`
+	Holdings *Holdings        `json:"-"`
+}
+
+// HouseholdSymbol is a symbol summed over every member. Its target is the
+// sum of each member's target applied to that member's accounts.
+type HouseholdSymbol struct {
+	Symbol            string  `json:"symbol"`
+	Amount            int     `json:"amount"`
+	CurrentPercentage float64 `json:"current_percentage"`
+	TargetPercentage  float64 `json:"target_percentage"`
+	Drift             float64 `json:"drift"`
+	AmountNeeded      int     `json:"amount_needed"`
+}
+
+type Household struct {
+	Total   int                `json:"total"`
+	Members []*HouseholdMember `json:"members"`
+	Symbols []HouseholdSymbol  `json:"symbols"`
+}
+
+// household rebalances a portfolio under several configs at once, each over
+// its own accounts, and reports them together. Only rebalance supports it.
+func household(paths []string, subCmd string, args []string) int {
+	if subCmd != "rebalance" {
+		printError(codedErrorf(CodeUsage, "only rebalance accepts more than one -config, not %s", subCmd))
+		return 1
+	}
+	var format string
+	flagSet := flag.NewFlagSet("rebalance", flag.ExitOnError)
+	flagSet.StringVar(&format, "format", "text", "Output format: text or json")
+	if len(args) < 1 {
+		flag.Usage()
+		return 1
+	}
+	portfolioCsv := args[0]
+	flagSet.Parse(args[1:])
+	if format != "text" && format != "json" {
+		printError(codedErrorf(CodeUsage, "unknown format %q", format))
+		return 1
+	}
+
+	var members []*HouseholdMember
+	for _, path := range paths {
+		config, err := parseConfig(path)
+		if err != nil {
+			printError(fmt.Errorf("parsing config %s: %w", path, err))
+			return 1
+		}
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		members = append(members, &HouseholdMember{Name: name, Config: config})
+	}
+	if err := validateHousehold(members); err != nil {
+		printError(err)
+		return 1
+	}
+	for _, member := range members {
+		holdings, err := loadPortfolio(member.Config, portfolioCsv)
+		if err != nil {
+			printError(fmt.Errorf("%s: %w", member.Name, err))
+			return 1
+		}
+		member.Holdings = holdings
+	}
+	result, err := householdCalc(members)
+	if err != nil {
+		printError(err)
+		return 1
+	}
+
+	if format == "json" {
+		if err := writeJSON(os.Stdout, result); err != nil {
+			printError(err)
+			return 1
+		}
+		return 0
+	}
+	for _, member := range result.Members {
+		fmt.Println(rule())
+		fmt.Printf("%s (%s): %s\n", member.Name, strings.Join(member.Accounts, ", "), formatAmount(member.Result.Total, true))
+		fmt.Println(rule())
+		for _, stock := range member.Config.Stocks {
+			data := member.Result.Symbols[stock.Symbol]
+			printHouseholdLine(stock.Symbol, data.CurrentPercentage, data.TargetPercentage, data.Drift, data.AmountNeeded)
+		}
+		fmt.Println()
+	}
+	fmt.Println(rule())
+	fmt.Printf("Household: %s\n", formatAmount(result.Total, true))
+	fmt.Println(rule())
+	for _, s := range result.Symbols {
+		printHouseholdLine(s.Symbol, s.CurrentPercentage, s.TargetPercentage, s.Drift, s.AmountNeeded)
+	}
+	return 0
+}
+
+func printHouseholdLine(symbol string, current, target, drift float64, needed int) {
+	fmt.Printf("%-6s %6.2f%% of %6.2f%% target (%s): %s\n", symbol, current, target, driftText(drift), tradeText(needed, formatAmount(needed, false)))
+}
+
+// validateHousehold checks that every member lists the accounts its
+// allocation applies to and that no account is in two members. Each config's
+// targets already sum to 100, which makes them add up within its accounts.
+func validateHousehold(members []*HouseholdMember) error {
+	owners := make(map[string]string)
+	for _, member := range members {
+		if len(member.Config.Accounts) == 0 {
+			return codedErrorf(CodeConfigInvalid, "%s has no accounts; with more than one config, each must list the accounts it applies to", member.Name)
+		}
+		for _, account := range member.Config.Accounts {
+			if owner, ok := owners[account.Name]; ok {
+				return codedErrorf(CodeConfigInvalid, "account %s is in both %s and %s; each account may be in only one config", account.Name, owner, member.Name)
+			}
+			owners[account.Name] = member.Name
+		}
+	}
+	return nil
+}
+
+// scopeHoldings is a copy of holdings that counts only what is held in the
+// config's accounts, which are all AmountsByAccount tracks
+func scopeHoldings(holdings *Holdings) *Holdings {
+	scoped := holdings.clone()
+	scoped.Amounts = make(map[string]int, len(holdings.Amounts))
+	for symbol, accounts := range holdings.AmountsByAccount {
+		for _, amount := range accounts {
+			scoped.Amounts[symbol] += amount
+		}
+	}
+	return scoped
+}
+
+// householdCalc rebalances each member within its accounts and sums the
+// results by symbol, in the order the configs first list them
+func householdCalc(members []*HouseholdMember) (*Household, error) {
+	result := &Household{Members: members, Symbols: []HouseholdSymbol{}}
+	targets := make(map[string]float64)
+	index := make(map[string]int)
+	for _, member := range members {
+		member.Accounts = nil
+		for _, account := range member.Config.Accounts {
+			member.Accounts = append(member.Accounts, account.Name)
+		}
+		var err error
+		if member.Result, err = allocationCalc(member.Config, scopeHoldings(member.Holdings), 0); err != nil {
+			return nil, fmt.Errorf("%s: %w", member.Name, err)
+		}
+		result.Total += member.Result.Total
+		for _, stock := range member.Config.Stocks {
+			data := member.Result.Symbols[stock.Symbol]
+			i, ok := index[stock.Symbol]
+			if !ok {
+				i = len(result.Symbols)
+				index[stock.Symbol] = i
+				result.Symbols = append(result.Symbols, HouseholdSymbol{Symbol: stock.Symbol})
+			}
+			result.Symbols[i].Amount += data.Amount
+			result.Symbols[i].AmountNeeded += data.AmountNeeded
+			targets[stock.Symbol] += float64(member.Result.Total) * stock.TargetPercentage / 100
+		}
+	}
+	for i := range result.Symbols {
+		s := &result.Symbols[i]
+		s.CurrentPercentage = float64(s.Amount) / float64(result.Total) * 100
+		s.TargetPercentage = targets[s.Symbol] / float64(result.Total) * 100
+		s.Drift = s.CurrentPercentage - s.TargetPercentage
+	}
+	return result, nil
+}
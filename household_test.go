@@ -0,0 +1,65 @@
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func householdMembers(t *testing.T, names ...string) []*HouseholdMember {
+	t.Helper()
+	var members []*HouseholdMember
+	for _, name := range names {
+		config, err := parseConfig(filepath.Join("tests", "configs", name+".yaml"))
+		if err != nil {
+			t.Fatalf("Failed to parse config %s: %v", name, err)
+		}
+		members = append(members, &HouseholdMember{Name: name, Config: config, Holdings: loadHoldings(t, config, "cash.csv")})
+	}
+	return members
+}
+
+func TestHouseholdCalc(t *testing.T) {
+	members := householdMembers(t, "household_taxable", "household_roth")
+	if err := validateHousehold(members); err != nil {
+		t.Fatalf("validateHousehold failed: %v", err)
+	}
+	result, err := householdCalc(members)
+	if err != nil {
+		t.Fatalf("householdCalc failed: %v", err)
+	}
+
+	// The taxable config sees only the Individual account's $71,000 of VTI,
+	// and the Roth config only the IRA's VXUS and BND
+	if members[0].Result.Total != 7100000 || members[1].Result.Total != 2900000 {
+		t.Errorf("Expected member totals 7100000 and 2900000, got %d and %d", members[0].Result.Total, members[1].Result.Total)
+	}
+	if result.Total != 10000000 {
+		t.Errorf("Expected a household total of 10000000, got %d", result.Total)
+	}
+	expected := []HouseholdSymbol{
+		{Symbol: "VTI", Amount: 7100000, TargetPercentage: 42.6, AmountNeeded: -2840000},
+		{Symbol: "VXUS", Amount: 1800000, TargetPercentage: 42.9, AmountNeeded: 2490000},
+		{Symbol: "BND", Amount: 1100000, TargetPercentage: 14.5, AmountNeeded: 350000},
+	}
+	if len(result.Symbols) != len(expected) {
+		t.Fatalf("Expected %d symbols, got %+v", len(expected), result.Symbols)
+	}
+	for i, want := range expected {
+		got := result.Symbols[i]
+		if got.Symbol != want.Symbol || got.Amount != want.Amount || got.AmountNeeded != want.AmountNeeded || math.Abs(got.TargetPercentage-want.TargetPercentage) > 1e-9 {
+			t.Errorf("Expected %+v, got %+v", want, got)
+		}
+	}
+}
+
+func TestValidateHousehold(t *testing.T) {
+	if err := validateHousehold(householdMembers(t, "household_taxable", "simple")); err == nil {
+		t.Error("Expected an error for a config without accounts")
+	}
+	members := householdMembers(t, "household_taxable", "household_roth")
+	members[1].Config.Accounts = append(members[1].Config.Accounts, Account{Name: "Individual"})
+	if err := validateHousehold(members); err == nil {
+		t.Error("Expected an error for an account in two configs")
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestWriteICSFoldsLongLines(t *testing.T) {
+	description := strings.Repeat("Buy VTI, VXUS, and BND — ", 12)
+	var buf bytes.Buffer
+	err := writeICS(&buf, []calendarEvent{{
+		UID:         "dca-1@fin-tilt",
+		Date:        time.Date(2026, time.January, 31, 0, 0, 0, 0, time.UTC),
+		Summary:     "Invest $1,000.00",
+		Description: description,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ics := buf.String()
+	if !strings.HasSuffix(ics, "END:VCALENDAR\r\n") {
+		t.Errorf("Expected CRLF line endings, got %q", ics)
+	}
+	folded := 0
+	for line := range strings.SplitSeq(strings.TrimSuffix(ics, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("Line of %d octets: %q", len(line), line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("Fold split a character: %q", line)
+		}
+		if strings.HasPrefix(line, " ") {
+			folded++
+		}
+	}
+	if folded == 0 {
+		t.Error("Expected the long description to be folded")
+	}
+	// Unfolding restores the escaped description
+	unfolded := strings.ReplaceAll(ics, "\r\n ", "")
+	if !strings.Contains(unfolded, "DESCRIPTION:"+icsEscape(description)+"\r\n") {
+		t.Errorf("Expected the description back after unfolding, got %q", unfolded)
+	}
+}
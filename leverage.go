@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// exposureWarningPoints is how far, in percentage points, notional exposure
+// may stray from the portfolio's dollar value before rebalance warns
+const exposureWarningPoints = 5.0
+
+// ExposureWeight compares a symbol's share of the portfolio's dollars with
+// its share of notional exposure, which leveraged and inverse funds multiply
+type ExposureWeight struct {
+	Symbol   string  `json:"symbol"`
+	Leverage float64 `json:"leverage"`
+	Dollar   float64 `json:"dollar_percentage"`
+	Exposure float64 `json:"exposure_percentage"`
+	// TargetExposure is the exposure of the symbol's target percentage
+	TargetExposure float64 `json:"target_exposure_percentage"`
+}
+
+// Exposure is the portfolio's notional exposure per symbol and in total,
+// as a percentage of its dollar value
+type Exposure struct {
+	Symbols []ExposureWeight `json:"symbols"`
+	Total   float64          `json:"total_percentage"`
+	Target  float64          `json:"target_percentage"`
+}
+
+func validateLeverage(config *Config) error {
+	for _, stock := range config.Stocks {
+		if math.IsNaN(stock.Leverage) || math.IsInf(stock.Leverage, 0) {
+			return fmt.Errorf("leverage of %s must be a number", stock.Symbol)
+		}
+	}
+	return nil
+}
+
+// leverage is how many dollars of exposure each dollar of the stock buys:
+// 2 for a 2x fund, -1 for an inverse fund, and 1 unless configured
+func (s Stock) leverage() float64 {
+	if s.Leverage == 0 {
+		return 1
+	}
+	return s.Leverage
+}
+
+// leveraged reports whether any stock has a leverage other than 1
+func (c *Config) leveraged() bool {
+	for _, stock := range c.Stocks {
+		if stock.leverage() != 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// exposureCalc multiplies the dollar weights of the allocation by each
+// stock's leverage
+func exposureCalc(config *Config, symbols map[string]SymbolData) *Exposure {
+	exposure := &Exposure{}
+	for _, stock := range config.Stocks {
+		data := symbols[stock.Symbol]
+		weight := ExposureWeight{
+			Symbol:         stock.Symbol,
+			Leverage:       stock.leverage(),
+			Dollar:         data.CurrentPercentage,
+			Exposure:       data.CurrentPercentage * stock.leverage(),
+			TargetExposure: stock.TargetPercentage * stock.leverage(),
+		}
+		exposure.Symbols = append(exposure.Symbols, weight)
+		exposure.Total += weight.Exposure
+		exposure.Target += weight.TargetExposure
+	}
+	return exposure
+}
+
+// diverges reports whether the notional exposure differs from the dollar
+// value by enough to warn about
+func (e *Exposure) diverges() bool {
+	return math.Abs(e.Total-100) > exposureWarningPoints
+}
+
+func printExposure(config *Config, result *RebalanceResult) {
+	if !config.leveraged() {
+		return
+	}
+	exposure := exposureCalc(config, result.Symbols)
+	fmt.Println("\n" + rule())
+	fmt.Println("Notional exposure")
+	fmt.Println(rule())
+	for _, weight := range exposure.Symbols {
+		fmt.Printf("%s - %.2f%% of dollars, %.2f%% exposure at %gx (target %.2f%%, %s)\n", weight.Symbol, weight.Dollar, weight.Exposure, weight.Leverage, weight.TargetExposure, driftText(weight.Exposure-weight.TargetExposure))
+	}
+	fmt.Printf("Total exposure: %.2f%% of the portfolio's value (target %.2f%%)\n", exposure.Total, exposure.Target)
+	if exposure.diverges() {
+		fmt.Println(red(fmt.Sprintf("Warning: leveraged and inverse funds put notional exposure at %.2f%% of the portfolio's dollar value", exposure.Total)))
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestExposureCalc(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "leveraged.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	if !config.leveraged() {
+		t.Fatal("Expected the config to be leveraged")
+	}
+	result, err := allocationCalc(config, loadHoldings(t, config, "leveraged.csv"), 0)
+	if err != nil {
+		t.Fatalf("allocationCalc failed: %v", err)
+	}
+
+	// SSO's 14% of dollars at 2x is 28% of exposure against a 20% target
+	exposure := exposureCalc(config, result.Symbols)
+	sso := exposure.Symbols[1]
+	if sso.Symbol != "SSO" || sso.Leverage != 2 || math.Abs(sso.Exposure-28) > 1e-9 || sso.TargetExposure != 20 {
+		t.Errorf("SSO exposure mismatch: %+v", sso)
+	}
+	if math.Abs(exposure.Total-114) > 1e-9 || exposure.Target != 110 || !exposure.diverges() {
+		t.Errorf("Total exposure mismatch: %+v", exposure)
+	}
+
+	// SSO's 4 dollar points of drift count as 8 in the drift score:
+	// 0.7 * 4 + 0.1 * 8 + 0.2 * 0
+	if math.Abs(result.Urgency.Score-3.6) > 1e-9 {
+		t.Errorf("Drift score mismatch: got %g, expected 3.6", result.Urgency.Score)
+	}
+	// Trades stay in dollars
+	if needed := result.Symbols["SSO"].AmountNeeded; needed != -400000 {
+		t.Errorf("SSO trade mismatch: got %d, expected -400000", needed)
+	}
+}
+
+func TestStockLeverage(t *testing.T) {
+	for _, tt := range []struct {
+		leverage, expected float64
+	}{{0, 1}, {2, 2}, {-1, -1}, {0.5, 0.5}} {
+		if got := (Stock{Leverage: tt.leverage}).leverage(); got != tt.expected {
+			t.Errorf("leverage(%g) = %g, expected %g", tt.leverage, got, tt.expected)
+		}
+	}
+	if (&Config{Stocks: []Stock{{Symbol: "VTI", Leverage: 1}}}).leveraged() {
+		t.Error("Expected a leverage of 1 not to count as leveraged")
+	}
+}
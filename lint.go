@@ -0,0 +1,174 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var lintSeverities = []string{"error", "warning", "info"}
+
+type LintFinding struct {
+	Severity string `json:"severity"`
+	// Subject is the symbol, account, or config key the finding is about
+	Subject     string `json:"subject"`
+	Message     string `json:"message"`
+	Explanation string `json:"explanation"`
+}
+
+// lint prints findings for the config and returns the process exit code
+func lint(configPath string, args []string) int {
+	var strict bool
+	flagSet := flag.NewFlagSet("lint", flag.ExitOnError)
+	flagSet.BoolVar(&strict, "strict", false, "Exit with an error status on warnings as well as errors")
+	flagSet.Parse(args)
+
+	var findings []LintFinding
+	config, err := decodeConfig(configPath, true)
+	if typeErr := (*yaml.TypeError)(nil); errors.As(err, &typeErr) {
+		// Unknown keys are usually typos; report them and lint the rest
+		for _, msg := range typeErr.Errors {
+			findings = append(findings, LintFinding{
+				Severity:    "warning",
+				Subject:     "config",
+				Message:     msg,
+				Explanation: "Unknown keys are ignored, so a misspelled setting silently has no effect.",
+			})
+		}
+		config, err = decodeConfig(configPath, false)
+	}
+	if err != nil {
+		printError(fmt.Errorf("parsing config: %w", err))
+		return 1
+	}
+
+	var holdings *Holdings
+	if flagSet.NArg() > 0 {
+		if holdings, err = loadPortfolio(config, flagSet.Arg(0)); err != nil {
+			printError(err)
+			return 1
+		}
+	}
+
+	findings = append(findings, lintConfig(config, holdings)...)
+	slices.SortStableFunc(findings, func(a, b LintFinding) int {
+		return cmp.Compare(slices.Index(lintSeverities, a.Severity), slices.Index(lintSeverities, b.Severity))
+	})
+
+	status := 0
+	for _, finding := range findings {
+		fmt.Printf("%-7s %s: %s\n", finding.Severity, finding.Subject, finding.Message)
+		fmt.Printf("        %s\n", finding.Explanation)
+		if finding.Severity == "error" || (strict && finding.Severity == "warning") {
+			status = 1
+		}
+	}
+	if len(findings) == 0 {
+		fmt.Println("No problems found")
+	}
+	return status
+}
+
+// lintConfig flags suspicious settings beyond the hard errors from validate.
+// holdings is optional and enables checks against actual dollar amounts.
+func lintConfig(config *Config, holdings *Holdings) []LintFinding {
+	var findings []LintFinding
+	add := func(severity, subject, explanation, format string, args ...any) {
+		findings = append(findings, LintFinding{severity, subject, fmt.Sprintf(format, args...), explanation})
+	}
+
+	if err := config.validate(); err != nil {
+		add("error", "config", "Commands refuse to run until this is fixed.", "%s", err)
+	}
+
+	descriptions := make(map[string]string)
+	for _, stock := range config.Stocks {
+		scaled := stock.TargetPercentage * 100
+		if math.Abs(scaled-math.Round(scaled)) > 1e-9 {
+			add("warning", stock.Symbol, "Targets finer than 0.01% can't be represented in reports and usually come from a calculator rounding error.",
+				"target_percentage %g has more than 2 decimal places", stock.TargetPercentage)
+		}
+		if stock.TargetPercentage == 0 && !slices.ContainsFunc(config.Unvested, func(g UnvestedGrant) bool { return g.Symbol == stock.Symbol }) {
+			add("info", stock.Symbol, "A 0% target recommends selling every share; remove the symbol if it is no longer held.",
+				"target_percentage is 0")
+		}
+
+		description := strings.ToLower(strings.TrimSpace(stock.Description))
+		if description == "" {
+			add("info", stock.Symbol, "Descriptions are shown in every report and document why the position exists.", "missing description")
+		} else if other, found := descriptions[description]; found {
+			add("warning", stock.Symbol, "Two entries for the same fund split one target across symbols; list one as an alternative of the other instead.",
+				"has the same description as %s", other)
+		} else {
+			descriptions[description] = stock.Symbol
+		}
+
+		for _, alt := range stock.Alternatives {
+			for _, other := range config.Stocks {
+				if other.Symbol != stock.Symbol && slices.Contains(strings.Fields(strings.ToUpper(other.Description)), strings.ToUpper(alt)) {
+					add("warning", stock.Symbol, "The alternative is probably attached to the wrong entry.",
+						"alternative %s is mentioned in the description of %s", alt, other.Symbol)
+				}
+			}
+		}
+	}
+
+	// Classes are only rebalanced once they leave a band when a band policy
+	// sets one
+	if config.BandPolicy == "" {
+		var classes []string
+		for _, stock := range config.Stocks {
+			if stock.Class != "" && !slices.Contains(classes, stock.Class) {
+				classes = append(classes, stock.Class)
+			}
+		}
+		for _, class := range classes {
+			add("warning", class, "Without a band, every drift in the class is flagged as needing a trade, however small; set band_policy to absolute or 5/25.",
+				"class has no band")
+		}
+	}
+
+	if holdings == nil {
+		return findings
+	}
+
+	total := 0
+	for _, amount := range holdings.Amounts {
+		total += amount
+	}
+	for _, stock := range config.Stocks {
+		if stock.TargetPercentage == 0 {
+			continue
+		}
+		targetAmount := int(math.Round(float64(total) * stock.TargetPercentage / 100))
+		if targetAmount < 100 {
+			add("warning", stock.Symbol, "The target can never be reached at this portfolio size; consider removing it or raising its percentage.",
+				"target of %.2f%% is only %s of this portfolio", stock.TargetPercentage, formatAmount(targetAmount, true))
+			continue
+		}
+		account := config.account(tradeAccount(holdings.AmountsByAccount[stock.Symbol]), "")
+		if price := holdings.Prices[stock.Symbol]; account != nil && !account.fractional() && price > targetAmount {
+			add("warning", stock.Symbol, "The account trades whole shares only, so the position can only be 0 or at least one share.",
+				"target of %s is less than one share (%s) in %s", formatAmount(targetAmount, true), formatAmount(price, true), account.Name)
+		}
+	}
+	for _, account := range config.Accounts {
+		found := false
+		for _, amounts := range holdings.AmountsByAccount {
+			if _, ok := amounts[account.Name]; ok {
+				found = true
+			}
+		}
+		if !found {
+			add("info", account.Name, "Account names must match the CSV's Account Number or Account Name column exactly.",
+				"account holds no configured symbols in this portfolio")
+		}
+	}
+	return findings
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLintConfig(t *testing.T) {
+	fractional := false
+	config := &Config{
+		Stocks: []Stock{
+			{Symbol: "VTI", TargetPercentage: 70.005, Description: "Total Market", Alternatives: []string{"FSKAX"}},
+			{Symbol: "ITOT", TargetPercentage: 29.945, Description: "total market"},
+			{Symbol: "BND", TargetPercentage: 0.05, Description: "Bonds, formerly FSKAX"},
+		},
+		Accounts: []Account{{Name: "Brokerage", FractionalShares: &fractional}},
+	}
+	holdings := &Holdings{
+		Amounts:          map[string]int{"VTI": 50000, "ITOT": 50000},
+		AmountsByAccount: map[string]map[string]int{"ITOT": {"Brokerage": 50000}},
+		Prices:           map[string]int{"ITOT": 40000},
+	}
+
+	findings := lintConfig(config, holdings)
+	expected := []string{
+		"warning VTI: target_percentage 70.005 has more than 2 decimal places",
+		"warning VTI: alternative FSKAX is mentioned in the description of BND",
+		"warning ITOT: target_percentage 29.945 has more than 2 decimal places",
+		"warning ITOT: has the same description as VTI",
+		"warning ITOT: target of $299.45 is less than one share ($400.00) in Brokerage",
+		"warning BND: target of 0.05% is only $0.50 of this portfolio",
+	}
+	var actual []string
+	for _, finding := range findings {
+		actual = append(actual, finding.Severity+" "+finding.Subject+": "+finding.Message)
+	}
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Findings mismatch:\ngot:\n%s\nexpected:\n%s", strings.Join(actual, "\n"), strings.Join(expected, "\n"))
+	}
+
+	clean, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	if findings := lintConfig(clean, nil); len(findings) != 0 {
+		t.Errorf("Expected no findings for simple.yaml, got %v", findings)
+	}
+
+	// Each class is flagged until a band policy gives it a band
+	clean.Stocks[0].Class, clean.Stocks[1].Class, clean.Stocks[2].Class = "Equity", "Equity", "Bonds"
+	actual = nil
+	for _, finding := range lintConfig(clean, nil) {
+		actual = append(actual, finding.Severity+" "+finding.Subject+": "+finding.Message)
+	}
+	expected = []string{"warning Equity: class has no band", "warning Bonds: class has no band"}
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Findings mismatch:\ngot:\n%s\nexpected:\n%s", strings.Join(actual, "\n"), strings.Join(expected, "\n"))
+	}
+	clean.BandPolicy = "5/25"
+	if findings := lintConfig(clean, nil); len(findings) != 0 {
+		t.Errorf("Expected no findings with a band policy, got %v", findings)
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Lot methods supported by the lot_method config setting
+var lotMethods = []string{"hifo", "lifo", "fifo", "min-tax"}
+
+type Lot struct {
+	Symbol   string    `json:"symbol"`
+	Acquired time.Time `json:"acquired"`
+	Quantity float64   `json:"quantity"`
+	// CostBasis is the total basis of the lot in cents
+	CostBasis int `json:"cost_basis"`
+	// Value is the current value of the lot in cents
+	Value int `json:"value"`
+	// Locked lots are never sold, such as low-basis legacy shares
+	Locked bool `json:"locked,omitempty"`
+}
+
+type LotSale struct {
+	Lot      Lot     `json:"lot"`
+	Quantity float64 `json:"quantity"`
+	Proceeds int     `json:"proceeds"`
+	Basis    int     `json:"basis"`
+	Gain     int     `json:"gain"`
+	LongTerm bool    `json:"long_term"`
+}
+
+// longTerm reports whether selling the lot on date qualifies for long-term
+// treatment, i.e. it has been held for more than one year
+func (l *Lot) longTerm(date time.Time) bool {
+	return date.After(l.Acquired.AddDate(1, 0, 0))
+}
+
+func (l *Lot) basisPerShare() float64 {
+	if l.Quantity == 0 {
+		return 0
+	}
+	return float64(l.CostBasis) / l.Quantity
+}
+
+// gainRatio is the gain (or loss) realized per dollar of the lot sold
+func (l *Lot) gainRatio() float64 {
+	if l.Value == 0 {
+		return 0
+	}
+	return float64(l.Value-l.CostBasis) / float64(l.Value)
+}
+
+// readLots parses a lot-level CSV with Symbol, Date Acquired, Quantity, and
+// Cost Basis Total columns. Each lot is valued from a Current Value column,
+// a Last Price column, or the portfolio's last price for the symbol. An
+// optional Locked column marks lots never to sell.
+func readLots(config *Config, holdings *Holdings, csvReader io.Reader) (map[string][]Lot, error) {
+	symbolToPrimary := make(map[string]string)
+	for _, stock := range config.Stocks {
+		symbolToPrimary[stock.Symbol] = stock.Symbol
+		for _, alt := range stock.Alternatives {
+			symbolToPrimary[alt] = stock.Symbol
+		}
+	}
+
+	reader := newCSVReader(csvReader)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading lots header: %w", err)
+	}
+	header = normalizeHeader(header)
+	symbolIndex := slices.Index(header, "Symbol")
+	dateIndex := slices.Index(header, "Date Acquired")
+	quantityIndex := slices.Index(header, "Quantity")
+	basisIndex := slices.Index(header, "Cost Basis Total")
+	if symbolIndex == -1 || dateIndex == -1 || quantityIndex == -1 || basisIndex == -1 {
+		return nil, codedErrorf(CodeCSVHeader, "lots CSV must have 'Symbol', 'Date Acquired', 'Quantity', and 'Cost Basis Total' columns")
+	}
+	valueIndex := slices.Index(header, "Current Value")
+	priceIndex := slices.Index(header, "Last Price")
+	lockedIndex := slices.Index(header, "Locked")
+
+	lots := make(map[string][]Lot)
+	for {
+		record, err := reader.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		primarySymbol, found := symbolToPrimary[field(record, symbolIndex)]
+		if !found {
+			continue
+		}
+
+		lot := Lot{Symbol: field(record, symbolIndex)}
+		if lot.Acquired, err = parseLotDate(field(record, dateIndex)); err != nil {
+			return nil, err
+		}
+		if lot.Quantity, err = strconv.ParseFloat(strings.ReplaceAll(field(record, quantityIndex), ",", ""), 64); err != nil {
+			return nil, codedErrorf(CodeCSVValue, "error parsing lot quantity: %w", err)
+		}
+		if lot.CostBasis, err = amountToInt(field(record, basisIndex)); err != nil {
+			return nil, codedErrorf(CodeCSVValue, "error parsing lot cost basis: %w", err)
+		}
+
+		switch {
+		case field(record, valueIndex) != "":
+			if lot.Value, err = amountToInt(field(record, valueIndex)); err != nil {
+				return nil, codedErrorf(CodeCSVValue, "error parsing lot value: %w", err)
+			}
+		case field(record, priceIndex) != "":
+			price, err := amountToInt(field(record, priceIndex))
+			if err != nil {
+				return nil, codedErrorf(CodeCSVValue, "error parsing lot price: %w", err)
+			}
+			lot.Value = int(math.Round(lot.Quantity * float64(price)))
+		case lot.Symbol == primarySymbol && holdings.Prices[primarySymbol] > 0:
+			lot.Value = int(math.Round(lot.Quantity * float64(holdings.Prices[primarySymbol])))
+		default:
+			return nil, fmt.Errorf("no value for %s lot acquired %s: add a Current Value or Last Price column", lot.Symbol, lot.Acquired.Format(time.DateOnly))
+		}
+		if locked := field(record, lockedIndex); locked != "" {
+			if lot.Locked, err = strconv.ParseBool(locked); err != nil {
+				return nil, codedErrorf(CodeCSVValue, "error parsing locked of %s lot acquired %s: %w", lot.Symbol, lot.Acquired.Format(time.DateOnly), err)
+			}
+		}
+		lots[primarySymbol] = append(lots[primarySymbol], lot)
+	}
+	return lots, nil
+}
+
+func parseLotDate(str string) (time.Time, error) {
+	for _, layout := range []string{time.DateOnly, "01/02/2006", "Jan-02-2006"} {
+		if date, err := time.Parse(layout, strings.TrimSpace(str)); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, codedErrorf(CodeCSVValue, "invalid lot acquisition date %q", str)
+}
+
+// sortLots orders lots by the sequence in which method sells them. min-tax
+// sells short-term losses, then long-term losses, then long-term gains, then
+// short-term gains, taking the smallest gain per dollar first in each group.
+func sortLots(lots []Lot, method string, date time.Time) {
+	taxGroup := func(lot *Lot) int {
+		gain, long := lot.gainRatio() > 0, lot.longTerm(date)
+		switch {
+		case !gain && !long:
+			return 0
+		case !gain:
+			return 1
+		case long:
+			return 2
+		default:
+			return 3
+		}
+	}
+	slices.SortStableFunc(lots, func(a, b Lot) int {
+		switch method {
+		case "lifo":
+			return b.Acquired.Compare(a.Acquired)
+		case "fifo":
+			return a.Acquired.Compare(b.Acquired)
+		case "min-tax":
+			if ga, gb := taxGroup(&a), taxGroup(&b); ga != gb {
+				return ga - gb
+			}
+			return cmp.Compare(a.gainRatio(), b.gainRatio())
+		default: // hifo
+			return cmp.Compare(b.basisPerShare(), a.basisPerShare())
+		}
+	})
+}
+
+// selectLots picks the lots to sell to raise amountCents, in method order,
+// passing over locked lots. The last lot may be sold partially.
+func selectLots(lots []Lot, amountCents int, method string, date time.Time) []LotSale {
+	ordered := slices.Clone(lots)
+	sortLots(ordered, method, date)
+
+	var sales []LotSale
+	remaining := amountCents
+	for _, lot := range ordered {
+		if remaining <= 0 {
+			break
+		}
+		if lot.Value <= 0 || lot.Quantity <= 0 || lot.Locked {
+			continue
+		}
+		sale := LotSale{Lot: lot, Quantity: lot.Quantity, Proceeds: lot.Value, Basis: lot.CostBasis, LongTerm: lot.longTerm(date)}
+		if lot.Value > remaining {
+			fraction := float64(remaining) / float64(lot.Value)
+			sale.Quantity = math.Round(lot.Quantity*fraction*10000) / 10000
+			sale.Proceeds = remaining
+			sale.Basis = int(math.Round(float64(lot.CostBasis) * fraction))
+		}
+		sale.Gain = sale.Proceeds - sale.Basis
+		remaining -= sale.Proceeds
+		sales = append(sales, sale)
+	}
+	return sales
+}
+
+// lockedLots sums the value of each primary symbol's locked lots, for
+// Holdings.Locked
+func lockedLots(lots map[string][]Lot) map[string]int {
+	locked := make(map[string]int)
+	for symbol, symbolLots := range lots {
+		for _, lot := range symbolLots {
+			if lot.Locked {
+				locked[symbol] += lot.Value
+			}
+		}
+	}
+	return locked
+}
+
+// assignLotSales attaches the lots to sell to every symbol with a recommended sale
+func assignLotSales(config *Config, result *RebalanceResult, lots map[string][]Lot, date time.Time) {
+	for _, stock := range config.Stocks {
+		data := result.Symbols[stock.Symbol]
+		sell := -(data.AmountNeeded - data.ResidualCash)
+		if sell <= 0 || len(lots[stock.Symbol]) == 0 {
+			continue
+		}
+		data.LotSales = selectLots(lots[stock.Symbol], sell, config.lotMethod(), date)
+		result.Symbols[stock.Symbol] = data
+	}
+}
+
+func printLotSales(method string, sales []LotSale) {
+	fmt.Printf("Lots to sell (%s):\n", method)
+	for _, sale := range sales {
+		character := "short-term"
+		if sale.LongTerm {
+			character = "long-term"
+		}
+		kind := "gain"
+		if sale.Gain < 0 {
+			kind = "loss"
+		}
+		fmt.Printf("  %s %s: %g shares, basis %s, %s %s %s\n", sale.Lot.Acquired.Format(time.DateOnly), sale.Lot.Symbol, sale.Quantity, formatAmount(sale.Basis, true), character, kind, formatAmount(sale.Gain, true))
+	}
+}
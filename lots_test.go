@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSelectLots(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	holdings := loadHoldings(t, config, "unbalanced.csv")
+	file, err := os.Open(filepath.Join("tests", "portfolios", "lots.csv"))
+	if err != nil {
+		t.Fatalf("Failed to open lots CSV: %v", err)
+	}
+	defer file.Close()
+	lots, err := readLots(config, holdings, file)
+	if err != nil {
+		t.Fatalf("readLots failed: %v", err)
+	}
+	if len(lots["VTI"]) != 3 || len(lots["BND"]) != 1 {
+		t.Fatalf("Lot count mismatch: got %d VTI and %d BND lots", len(lots["VTI"]), len(lots["BND"]))
+	}
+
+	date := time.Date(2026, time.October, 17, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		method   string
+		acquired string
+		basis    int
+		longTerm bool
+	}{
+		{"hifo", "2022-06-01", 990000, true},
+		{"lifo", "2026-08-01", 936000, false},
+		{"fifo", "2020-01-15", 540000, true},
+		{"min-tax", "2026-08-01", 936000, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			sales := selectLots(lots["VTI"], 900000, tt.method, date)
+			if len(sales) != 1 {
+				t.Fatalf("Sale count mismatch: got %d, expected 1", len(sales))
+			}
+			sale := sales[0]
+			if got := sale.Lot.Acquired.Format(time.DateOnly); got != tt.acquired {
+				t.Errorf("Lot mismatch: got %s, expected %s", got, tt.acquired)
+			}
+			if sale.Quantity != 36 || sale.Proceeds != 900000 {
+				t.Errorf("Sale mismatch: got %g shares for %d", sale.Quantity, sale.Proceeds)
+			}
+			if sale.Basis != tt.basis || sale.Gain != 900000-tt.basis {
+				t.Errorf("Basis mismatch: got %d (gain %d), expected %d", sale.Basis, sale.Gain, tt.basis)
+			}
+			if sale.LongTerm != tt.longTerm {
+				t.Errorf("LongTerm mismatch: got %t, expected %t", sale.LongTerm, tt.longTerm)
+			}
+		})
+	}
+
+	t.Run("spans multiple lots", func(t *testing.T) {
+		sales := selectLots(lots["VTI"], 5000000, "fifo", date)
+		if len(sales) != 3 {
+			t.Fatalf("Sale count mismatch: got %d, expected 3", len(sales))
+		}
+		if sales[0].Quantity != 100 || sales[1].Quantity != 80 || sales[2].Proceeds != 500000 {
+			t.Errorf("Unexpected sales: %+v", sales)
+		}
+	})
+}
+
+func TestLockedLots(t *testing.T) {
+	date := time.Date(2026, time.October, 17, 0, 0, 0, 0, time.UTC)
+	lots := map[string][]Lot{"VTI": {
+		{Symbol: "VTI", Acquired: time.Date(2020, time.January, 15, 0, 0, 0, 0, time.UTC), Quantity: 100, CostBasis: 1500000, Value: 2500000, Locked: true},
+		{Symbol: "VTI", Acquired: time.Date(2022, time.June, 1, 0, 0, 0, 0, time.UTC), Quantity: 80, CostBasis: 2200000, Value: 2000000},
+	}}
+	if locked := lockedLots(lots); locked["VTI"] != 2500000 {
+		t.Errorf("Expected 2500000 of VTI locked, got %d", locked["VTI"])
+	}
+	// Only the unlocked lot is sold, however much is asked for
+	sales := selectLots(lots["VTI"], 3000000, "fifo", date)
+	if len(sales) != 1 || sales[0].Lot.Locked || sales[0].Proceeds != 2000000 {
+		t.Errorf("Expected only the unlocked lot sold, got %+v", sales)
+	}
+}
@@ -1,32 +1,90 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/csv"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"math"
+	"net/url"
 	"os"
+	"os/signal"
 	"slices"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type SymbolData struct {
-	Amount            int     `json:"amount"`
-	AmountNeeded      int     `json:"amount_needed"`
-	CurrentPercentage float64 `json:"current_percentage"`
-	TargetPercentage  float64 `json:"target_percentage"`
-	Drift             float64 `json:"drift"`
+	Amount            int       `json:"amount"`
+	AmountNeeded      int       `json:"amount_needed"`
+	CurrentPercentage float64   `json:"current_percentage"`
+	TargetPercentage  float64   `json:"target_percentage"`
+	Drift             float64   `json:"drift"`
+	Account           string    `json:"account,omitempty"`
+	Price             int       `json:"price,omitempty"`
+	WholeShares       bool      `json:"whole_shares,omitempty"`
+	SharesNeeded      int       `json:"shares_needed,omitempty"`
+	ResidualCash      int       `json:"residual_cash,omitempty"`
+	LotSales          []LotSale `json:"lot_sales,omitempty"`
+	// Note explains a trade changed or held back by an account rule
+	Note string `json:"note,omitempty"`
+	// HeldAs splits the symbol's value among the symbols it is held as, when
+	// an alternative is held
+	HeldAs []HeldAs `json:"held_as,omitempty"`
+	// TradeAs splits the trade among the symbols it is placed in, when the
+	// stock has prefer_for_buys
+	TradeAs []TradeAs `json:"trade_as,omitempty"`
+	// Locked is set when selling the symbol down to target would sell locked
+	// shares, so it's held back; UnavoidableDrift is the drift that leaves
+	Locked           bool    `json:"locked,omitempty"`
+	UnavoidableDrift float64 `json:"unavoidable_drift,omitempty"`
+	// Actionable is set when the band policy calls for trading the symbol,
+	// or, without one, when it has a trade
+	Actionable bool `json:"actionable"`
+	// Explanation is set under rebalance -explain
+	Explanation *Explanation `json:"explanation,omitempty"`
 }
 
 type RebalanceResult struct {
 	Symbols       map[string]SymbolData `json:"symbols"`
 	Total         int                   `json:"total"`
 	DepositAmount int                   `json:"deposit_amount"`
+	// Fraction is how far the trades move each symbol back to its target
+	Fraction     float64   `json:"fraction"`
+	ResidualCash int       `json:"residual_cash,omitempty"`
+	AsOf         time.Time `json:"as_of,omitzero"`
+	AsOfSource   string    `json:"as_of_source,omitempty"`
+	// Benchmark compares current weights to the configured benchmark's
+	Benchmark []BenchmarkWeight `json:"benchmark,omitempty"`
+	// Urgency scores the drift of the whole portfolio
+	Urgency Urgency `json:"urgency"`
+	// Sleeves rebalance each configured sleeve on its own
+	Sleeves []SleeveResult `json:"sleeves,omitempty"`
+	// Classes sum the symbols by class, when any stock has one
+	Classes []ClassWeight `json:"classes,omitempty"`
+	// Exposure is set when some stock is leveraged
+	Exposure *Exposure `json:"exposure,omitempty"`
+	// CurrencyExposure is set when some stock holds foreign currency or a
+	// currency_exposure target is configured
+	CurrencyExposure *CurrencyExposure `json:"currency_exposure,omitempty"`
+	// CashChecks compare each configured account's purchases with its cash
+	CashChecks []CashCheck `json:"cash_checks,omitempty"`
+	// Sequence orders the trades of accounts that both sell and buy
+	Sequence []TradeStep `json:"sequence,omitempty"`
+	// TradeWarnings are trades that recent transactions make costly or
+	// against a fund's frequent-trading policy
+	TradeWarnings []TradeWarning `json:"trade_warnings,omitempty"`
+	// Warnings are what reading the holdings found, such as symbols missing
+	// from the config or a stale export
+	Warnings []Warning `json:"warnings,omitempty"`
 }
 
 type DepositResult struct {
@@ -35,25 +93,287 @@ type DepositResult struct {
 }
 
 type Config struct {
-	Stocks []Stock `yaml:"stocks"`
+	Stocks   []Stock         `yaml:"stocks"`
+	Accounts []Account       `yaml:"accounts,omitempty"`
+	Paycheck *PaycheckConfig `yaml:"paycheck,omitempty"`
+	Unvested []UnvestedGrant `yaml:"unvested,omitempty"`
+	// LotMethod chooses which lots are sold first: hifo (default), lifo, fifo, or min-tax
+	LotMethod string `yaml:"lot_method,omitempty"`
+	// Rounding is how fractional cents and shares are rounded in needed
+	// amounts, deposit splits, and share counts: floor, ceil, half-even, or
+	// half-up. Unset keeps each calculation's own rounding.
+	Rounding string        `yaml:"rounding,omitempty"`
+	Sheets   *SheetsConfig `yaml:"sheets,omitempty"`
+	Serve    *ServeConfig  `yaml:"serve,omitempty"`
+	// StaleAfter is the export age that triggers a staleness warning (default 3d)
+	StaleAfter *Age `yaml:"stale_after,omitempty"`
+	// DuplicateRows is the policy for a symbol appearing in several CSV rows:
+	// sum (default), warn, or error
+	DuplicateRows string `yaml:"duplicate_rows,omitempty"`
+	// ValueTolerance is how far, in percent, a row's Current Value may be from
+	// its Quantity times Last Price before it's flagged (default 1)
+	ValueTolerance *float64 `yaml:"value_tolerance,omitempty"`
+	// SharePrices are manual prices by symbol, which value rows that give
+	// shares alone in place of the quotes plugin, such as an ESOP's private
+	// company stock
+	SharePrices map[string]Money `yaml:"share_prices,omitempty"`
+	// TargetDateFunds are split into configured stocks by their glide path
+	TargetDateFunds []TargetDateFund `yaml:"target_date_funds,omitempty"`
+	// Cash adjusts which rows count as uninvested cash and how its drag is estimated
+	Cash *CashConfig `yaml:"cash,omitempty"`
+	// Snapshots is a JSON Lines file, or a CSV ledger if it ends in .csv, of
+	// portfolio snapshots recorded by the snapshot command
+	Snapshots string `yaml:"snapshots,omitempty"`
+	// Transactions is a JSON Lines file of broker activity added by the
+	// import command
+	Transactions string `yaml:"transactions,omitempty"`
+	// Tilt is the desired factor tilt reported on by the tilt command
+	Tilt *TiltConfig `yaml:"tilt,omitempty"`
+	// Hooks are shell commands run around commands, keyed pre_<command> or
+	// post_<command>
+	Hooks map[string]string `yaml:"hooks,omitempty"`
+	// Plugins are external holdings, quotes, and fx providers by name
+	Plugins map[string]PluginConfig `yaml:"plugins,omitempty"`
+	// Withdrawal sets up required minimum distributions and a safe
+	// withdrawal rate for the withdrawal-plan command
+	Withdrawal *WithdrawalConfig `yaml:"withdrawal,omitempty"`
+	// Benchmark is compared against in the rebalance and returns reports, and
+	// is what risk measures beta against by default
+	Benchmark *BenchmarkConfig `yaml:"benchmark,omitempty"`
+	// Prices chooses the source of daily price history
+	Prices *PricesConfig `yaml:"prices,omitempty"`
+	// FX chooses the source of exchange rates
+	FX *FXConfig `yaml:"fx,omitempty"`
+	// Network paces and retries calls to each network provider
+	Network map[string]NetworkPolicy `yaml:"network,omitempty"`
+	// ArchiveDir is where every report is saved, unless -archiveDir overrides it
+	ArchiveDir string `yaml:"archive_dir,omitempty"`
+	// AuditLog is a JSON Lines file that every command run is appended to,
+	// with its inputs and key outputs
+	AuditLog string `yaml:"audit_log,omitempty"`
+	// Remind sets the cadence of the remind command's rebalance checks
+	Remind *RemindConfig `yaml:"remind,omitempty"`
+	// Urgency sets the drift scores that call for watching or rebalancing
+	Urgency *UrgencyConfig `yaml:"urgency,omitempty"`
+	// RebalanceFraction is how far trades move each symbol back to its
+	// target, e.g. 0.5 for halfway, to trade and realize gains less (default 1)
+	RebalanceFraction *float64 `yaml:"rebalance_fraction,omitempty"`
+	// BandPolicy decides which symbols are flagged as needing a trade:
+	// absolute (drift beyond remind's band) or 5/25. Unset flags every trade.
+	BandPolicy string `yaml:"band_policy,omitempty"`
+	// Sleeves split the portfolio into parts rebalanced on their own
+	Sleeves []SleeveConfig `yaml:"sleeves,omitempty"`
+	// StaticPositions are holdings valued by hand, added to every portfolio
+	StaticPositions []StaticPosition `yaml:"static_positions,omitempty"`
+	// StaticPositionsFile is a YAML list, or a CSV if it ends in .csv, of
+	// more static positions
+	StaticPositionsFile string `yaml:"static_positions_file,omitempty"`
+	// Goals are savings targets tracked by the goal command
+	Goals []GoalConfig `yaml:"goals,omitempty"`
+	// Ladder lists the bonds and CDs held to maturity whose coupons and
+	// principal the cashflow command projects
+	Ladder []LadderBond `yaml:"ladder,omitempty"`
+	// CurrencyExposure is the target share of the portfolio in unhedged
+	// foreign currencies
+	CurrencyExposure *CurrencyExposureConfig `yaml:"currency_exposure,omitempty"`
+	// StressScenarios are the shocks the stress command applies, instead of
+	// its defaults
+	StressScenarios []StressScenario `yaml:"stress_scenarios,omitempty"`
+}
+
+var duplicateRowPolicies = []string{"sum", "warn", "error"}
+
+var roundingModes = []string{"floor", "ceil", "half-even", "half-up"}
+
+// round rounds x to an integer with the configured rounding mode, or with
+// fallback when none is configured. Besides the configurable modes, fallback
+// may be "truncate" (toward zero).
+func (c *Config) round(x float64, fallback string) int {
+	mode := fallback
+	if c.Rounding != "" {
+		mode = c.Rounding
+	}
+	switch mode {
+	case "floor":
+		return int(math.Floor(x))
+	case "ceil":
+		return int(math.Ceil(x))
+	case "half-even":
+		return int(math.RoundToEven(x))
+	case "truncate":
+		return int(math.Trunc(x))
+	}
+	// half-up rounds halves away from zero, so -2.5 is -3 as 2.5 is 3
+	return int(math.Round(x))
+}
+
+func (c *Config) lotMethod() string {
+	if c.LotMethod == "" {
+		return "hifo"
+	}
+	return c.LotMethod
+}
+
+type Account struct {
+	// Name matches either the "Account Number" or "Account Name" column of the CSV
+	Name             string `yaml:"name"`
+	FractionalShares *bool  `yaml:"fractional_shares,omitempty"`
+	// Type is the account's tax treatment, e.g. taxable, traditional-ira, or
+	// roth-ira, which decides whether it has required minimum distributions
+	// and the trading rules of 529 and hsa accounts
+	Type string `yaml:"type,omitempty"`
+	// ExchangesLeft is how many more times this year a 529 account's holdings
+	// may be sold to rebalance (default 2, the IRS limit)
+	ExchangesLeft *int `yaml:"exchanges_left,omitempty"`
+	// CashMinimum is the cash an HSA must keep uninvested
+	CashMinimum Money `yaml:"cash_minimum,omitempty"`
+	// CashAccount marks an account without margin, where a purchase can't be
+	// paid for with sale proceeds before they settle
+	CashAccount bool `yaml:"cash_account,omitempty"`
+	// Blackouts are the dates the account can't be traded, such as a 401k's
+	// exchange restrictions or an employer's trading window
+	Blackouts []Blackout `yaml:"blackouts,omitempty"`
+}
+
+// fractional reports whether the account supports fractional share trading.
+// Accounts default to fractional trading when the setting is omitted.
+func (a *Account) fractional() bool {
+	return a.FractionalShares == nil || *a.FractionalShares
+}
+
+// account finds the configured account matching either an account number or name
+func (c *Config) account(number, name string) *Account {
+	for i := range c.Accounts {
+		if (number != "" && c.Accounts[i].Name == number) || (name != "" && c.Accounts[i].Name == name) {
+			return &c.Accounts[i]
+		}
+	}
+	return nil
 }
 
 type Stock struct {
 	Symbol           string   `yaml:"symbol"`
 	TargetPercentage float64  `yaml:"target_percentage"`
 	Description      string   `yaml:"description"`
+	Notes            string   `yaml:"notes,omitempty"`
+	URL              string   `yaml:"url,omitempty"`
 	Alternatives     []string `yaml:"alternatives,omitempty"`
+	// PreferForBuys is the symbol, the stock's own or one of its
+	// alternatives, that purchases are placed in; the others are only held
+	// and sold from
+	PreferForBuys string `yaml:"prefer_for_buys,omitempty"`
+	// Locked means never recommend selling the stock, such as employer stock
+	// in a blackout window; the other stocks are rebalanced around it
+	Locked bool `yaml:"locked,omitempty"`
+	// RedemptionFee and FrequentTradingDays are the fund's short-term
+	// trading rules: a fee on selling recently bought shares, and the days
+	// after a purchase or sale in which trading back is a round trip
+	RedemptionFee       *RedemptionFee `yaml:"redemption_fee,omitempty"`
+	FrequentTradingDays int            `yaml:"frequent_trading_days,omitempty"`
+	// AssetClasses are the asset classes of robo-advisor exports (Betterment,
+	// Wealthfront) whose funds count as this stock, whatever their symbol
+	AssetClasses []string `yaml:"asset_classes,omitempty"`
+	// Category is a style-box category (e.g. small-value) used to estimate
+	// factor loadings; Factors sets or overrides individual loadings
+	Category string             `yaml:"category,omitempty"`
+	Factors  map[string]float64 `yaml:"factors,omitempty"`
+	// Class is the asset class the stock is grouped under in reports, such
+	// as US Equity; the metadata plugin fills it in when it's unset
+	Class string `yaml:"class,omitempty"`
+	// Name is the security's name from the metadata plugin
+	Name string `yaml:"-"`
+	// Sleeve names the sleeve the stock is rebalanced within
+	Sleeve string `yaml:"sleeve,omitempty"`
+	// Leverage multiplies the stock's dollars into notional exposure, such
+	// as 2 for a 2x fund or -1 for an inverse fund (default 1)
+	Leverage float64 `yaml:"leverage,omitempty"`
+	// Currency is what the stock's price history is quoted in, converted to
+	// USD for backtests (default USD)
+	Currency string `yaml:"currency,omitempty"`
+	// DividendYield is the annual dividend, in percent of the stock's value,
+	// paid in DividendMonths (default March, June, September, and December)
+	DividendYield  float64 `yaml:"dividend_yield,omitempty"`
+	DividendMonths []int   `yaml:"dividend_months,omitempty"`
+	// ForeignCurrency is the percent of the stock's value held in currencies
+	// other than the dollar (default 100 for a non-USD currency, else 0), and
+	// Hedged means the fund hedges it back to the dollar
+	ForeignCurrency *float64 `yaml:"foreign_currency,omitempty"`
+	Hedged          bool     `yaml:"hedged,omitempty"`
+	// Duration is a bond fund's duration in years, which stress scales rate
+	// shocks by; stocks without one take the equity shock
+	Duration float64 `yaml:"duration,omitempty"`
 }
 
 func main() {
-	var configPath string
-	flag.StringVar(&configPath, "config", "config.yaml", "Config file that specifies a desired asset allocation")
+	var configPaths []string
+	flag.Func("config", "Config file that specifies a desired asset allocation (default config.yaml here or in the fin-tilt config directory), age- or GPG-encrypted or - for stdin; repeat it for configs over disjoint accounts of one household", func(value string) error {
+		configPaths = append(configPaths, value)
+		return nil
+	})
+	flag.Func("maxAge", "Fail when the portfolio export is older than this (e.g. 7d, 36h)", func(value string) error {
+		age, err := parseAge(value)
+		maxAge = age
+		return err
+	})
+	flag.BoolVar(&offline, "offline", false, "Make no network calls; use cached price history and fail if it isn't cached")
+	flag.Func("fxDate", "Convert other currencies at the exchange rates of this date (YYYY-MM-DD) instead of the latest", func(value string) error {
+		date, err := time.Parse(time.DateOnly, value)
+		fxDate = date
+		return err
+	})
+	flag.BoolVar(&plain, "plain", false, "Print without color, spelling out signs in words (e.g. overweight by 2.10%)")
+	flag.BoolVar(&assumeYes, "yes", false, "Write files and submit changes without asking for confirmation")
+	flag.BoolVar(&dryRun, "dryRun", false, "Print the files that would be written and changes that would be submitted, without writing or submitting them")
+	flag.BoolVar(&noPager, "noPager", false, "Print reports directly instead of through $PAGER")
+	flag.StringVar(&homeDir, "home", "", "Directory holding the config, data, and cache, in place of ~/.config/fin-tilt, ~/.local/share/fin-tilt, and the user cache directory (default $FIN_TILT_HOME)")
+	flag.StringVar(&archiveDir, "archiveDir", "", "Save a timestamped copy of every report in this directory")
+	flag.DurationVar(&httpClient.Timeout, "timeout", httpClient.Timeout, "Timeout for each call to a network provider")
+	flag.Func("delimiter", "CSV field delimiter: a single character, tab, or auto (default auto)", func(value string) error {
+		delimiter, err := parseDelimiter(value)
+		csvDelimiter = delimiter
+		return err
+	})
 
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(), "Usage: fin-tilt -config <config.yaml> <command> [<args>]\n")
 		fmt.Println("Commands:")
-		fmt.Println("  rebalance <portfolio.csv> [-toDeposit <amount>]  Rebalance portfolio based on current values in CSV file")
+		fmt.Println("  rebalance <portfolio.csv> [-toDeposit <amount>] [-lots <lots.csv>] [-fraction <f> | -halfway] [-explain] [-format json]  Rebalance portfolio based on current values in CSV file")
+		fmt.Println("  batch <manifest.yaml> [-format json]  Rebalance each portfolio in a manifest under its own config and summarize them")
 		fmt.Println("  deposit <amount>           Deposit the specified amount")
+		fmt.Println("  topup <portfolio.csv>      Smallest deposit that restores every target without selling, and its split")
+		fmt.Println("  dca <amount> -periods <n> [-portfolio <portfolio.csv>]  Split a lump sum into a dated purchase schedule")
+		fmt.Println("  paycheck <gross>           Split a paycheck across accounts and allocate each slice")
+		fmt.Println("  vest <portfolio.csv> [-date <YYYY-MM-DD>]  Plan the sale and diversification of newly vested shares")
+		fmt.Println("  unwind <portfolio.csv> -symbol <symbol> -gainsBudget <amount>  Plan a multi-quarter sale of a concentrated position")
+		fmt.Println("  compare <portfolio.csv> <proposed.yaml>...  Compare drift and trades under other configs side by side")
+		fmt.Println("  risk <portfolio.csv> [-benchmark <symbol>] [-history <prices.csv>]  Report volatility, drawdown, and beta of current and target weights")
+		fmt.Println("  trigger-analysis [-years <n>] [-history <prices.csv>] [-paths <n> -seed <n>] [-replay <run.json>]  Backtest calendar and 5/25 band rebalancing: turnover and drift of each")
+		fmt.Println("  chart <portfolio.csv> [-format svg|png] [-o <file>]  Draw current and target donuts and a drift bar chart")
+		fmt.Println("  tilt <portfolio.csv>       Report size, value, and quality factor tilts against the config's tilt target")
+		fmt.Println("  snapshot <portfolio.csv>   Record the portfolio in the config's snapshots file")
+		fmt.Println("  history show|prune|chart|list  Show, prune, or chart recorded snapshots, or list archived reports")
+		fmt.Println("  returns [<portfolio.csv>] [-flows <flows.csv>]  Money- and time-weighted returns from snapshots and cash flows")
+		fmt.Println("  import <activity.csv>      Add a broker activity export to the config's transactions file")
+		fmt.Println("  export ledger|beancount|qif|ofx <portfolio.csv> [-trades]  Export holdings and trades for accounting software")
+		fmt.Println("  sheets push <portfolio.csv>  Write the allocation, drift, and trade plan to a Google Sheet")
+		fmt.Println("  serve [-addr <addr>] [-portfolio <portfolio.csv>]  Serve the /fintilt Slack slash command")
+		fmt.Println("  withdrawal-plan <portfolio.csv> [-rate <percent>] [-format json]  Plan this year's required minimum distributions or safe withdrawal")
+		fmt.Println("  cashflow <portfolio.csv> [-months <n>] [-from <YYYY-MM>] [-format json]  Project monthly dividend, coupon, and maturity income by account")
+		fmt.Println("  stress <portfolio.csv> [-equities <percent>] [-rates <points>] [-usd <percent>] [-format json]  Apply market shocks and report values, drift, and breached bands")
+		fmt.Println("  digest <portfolio.csv> [-weeks <n>] [-format text|html|json] [-o <file>]  Summarize status, drift trend, the week's changes, and coming reminders for email or Slack")
+		fmt.Println("  advise <portfolio.csv> [-next <YYYY-MM-DD>]  Estimate when drift will breach the bands and whether to act before the next check")
+		fmt.Println("  remind [-portfolio <portfolio.csv>] [-format ics] [-o <file>]  Schedule rebalance checks for a calendar")
+		fmt.Println("  holdings set|remove|list [<symbol> <amount>] [-account <name>]  Record hand-valued positions in the static positions file")
+		fmt.Println("  plan save <portfolio.csv> [-toDeposit <amount>] [-o <plan.json>]  Save the rebalance trades to check after placing them")
+		fmt.Println("  reconcile <portfolio.csv> [-plan <plan.json>] [-format json]  Report which saved trades a new export shows executed, partial, or skipped")
+		fmt.Println("  goal status [<name>] [-portfolio <portfolio.csv>]  Report whether savings goals are on track and the monthly contribution they need")
+		fmt.Println("  networth [<portfolio.csv>] [-since <YYYY-MM-DD>]  Net worth over time: snapshot totals less recorded liabilities")
+		fmt.Println("  audit show [-n <count>] [-command <command>] [-format json]  Show the runs recorded in the audit log")
+		fmt.Println("  home [-format json]        Show the config, data, and cache directories and the config in use")
+		fmt.Println("  lint [<portfolio.csv>]     Check the config for suspicious settings")
+		fmt.Println("  target set|add|remove <symbol> [<percent>] [-balance <symbol>] [-scale]  Change the config's targets, keeping its comments")
+		fmt.Println("  config schema              Print the JSON Schema for the config file")
+		fmt.Println("  config diff <old.yaml> <new.yaml> [-portfolio <portfolio.csv>] [-format json]  Show target changes and the trades they imply")
 		flag.PrintDefaults()
 	}
 
@@ -63,87 +383,628 @@ func main() {
 		flag.Usage()
 		os.Exit(1)
 	}
+	configPath := findConfig()
+	if len(configPaths) > 0 {
+		configPath = configPaths[0]
+	}
+
+	if offline {
+		httpClient.Transport = offlineTransport{}
+	}
 
 	subCmd := flag.Arg(0)
 	subCmdArgs := flag.Args()[1:]
+	// Reports record the configs and inputs that produced them
+	if len(configPaths) == 0 {
+		runManifest = newManifest(subCmd, []string{configPath}, subCmdArgs)
+	} else {
+		runManifest = newManifest(subCmd, configPaths, subCmdArgs)
+	}
 
+	if subCmd == "home" {
+		os.Exit(homeCommand(subCmdArgs))
+	}
+	// lint reports config problems itself, and target can fix them, so both
+	// must run before validation
+	if subCmd == "lint" {
+		os.Exit(lint(configPath, subCmdArgs))
+	}
+	if subCmd == "target" {
+		os.Exit(target(configPath, subCmdArgs))
+	}
+
+	if jsonRequested(subCmdArgs) {
+		errorFormat = "json"
+	}
+	// config and batch read the configs they name themselves
+	if subCmd == "config" {
+		os.Exit(configCommand(subCmdArgs))
+	}
+	if subCmd == "batch" {
+		os.Exit(batch(subCmdArgs))
+	}
+	if len(configPaths) > 1 {
+		os.Exit(household(configPaths, subCmd, subCmdArgs))
+	}
 	config, err := parseConfig(configPath)
 	if err != nil {
-		fmt.Println("Error parsing config:", err)
+		printError(fmt.Errorf("parsing config: %w", err))
+		os.Exit(1)
+	}
+
+	// Ctrl-C cancels network calls and stops serve gracefully
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := enrichStocks(ctx, config); err != nil {
+		printError(err)
 		os.Exit(1)
 	}
 
+	hook := &HookData{Command: subCmd, Args: subCmdArgs, ConfigPath: configPath}
+	if err := runHook(ctx, config, "pre_"+subCmd, hook); err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+	// Reports on a terminal wrap to its width and go through a pager
+	var finishPager func() error
+	if isTerminal(os.Stdout) {
+		terminalWidth = detectTerminalWidth(os.Stdout)
+		if !noPager && !slices.Contains(unpagedCommands, subCmd) && !mayConfirm(subCmd, subCmdArgs) {
+			if finishPager, err = startPager(); err != nil {
+				printError(err)
+				os.Exit(1)
+			}
+		}
+	}
+	// A post hook gets a copy of the command's output to archive or forward
+	var finishCapture func() error
+	var reportPath string
+	_, postHook := config.Hooks["post_"+subCmd]
+	if archiveDir == "" {
+		archiveDir = config.ArchiveDir
+	}
+	archiving := archiveDir != "" && !slices.Contains(unarchivedCommands, subCmd)
+	if postHook || archiving {
+		if reportPath, finishCapture, err = captureOutput(subCmd); err != nil {
+			printError(err)
+			os.Exit(1)
+		}
+	}
+	if postHook {
+		hook.ReportPath = reportPath
+	}
+
 	switch subCmd {
 	case "rebalance":
 		rebalance(config, subCmdArgs)
 	case "deposit":
 		deposit(config, subCmdArgs)
+	case "topup":
+		topup(config, subCmdArgs)
+	case "dca":
+		dca(config, subCmdArgs)
+	case "paycheck":
+		paycheck(config, subCmdArgs)
+	case "vest":
+		vest(config, subCmdArgs)
+	case "unwind":
+		unwind(config, subCmdArgs)
+	case "compare":
+		compare(config, subCmdArgs)
+	case "risk":
+		risk(ctx, config, subCmdArgs)
+	case "chart":
+		chart(config, subCmdArgs)
+	case "tilt":
+		tilt(config, subCmdArgs)
+	case "snapshot":
+		snapshot(config, subCmdArgs)
+	case "history":
+		history(config, subCmdArgs)
+	case "returns":
+		returns(ctx, config, subCmdArgs)
+	case "import":
+		importTransactions(config, subCmdArgs)
+	case "export":
+		export(config, subCmdArgs)
+	case "sheets":
+		sheets(ctx, config, subCmdArgs)
+	case "serve":
+		serve(ctx, config, subCmdArgs)
+	case "withdrawal-plan":
+		withdrawalPlan(config, subCmdArgs)
+	case "advise":
+		advise(config, subCmdArgs)
+	case "remind":
+		remind(config, subCmdArgs)
+	case "holdings":
+		holdings(config, subCmdArgs)
+	case "plan":
+		plan(config, subCmdArgs)
+	case "reconcile":
+		reconcile(config, subCmdArgs)
+	case "goal":
+		goal(config, subCmdArgs)
+	case "networth":
+		networth(config, subCmdArgs)
+	case "trigger-analysis":
+		triggerAnalysis(ctx, config, subCmdArgs)
+	case "cashflow":
+		cashflow(config, subCmdArgs)
+	case "stress":
+		stress(config, subCmdArgs)
+	case "audit":
+		audit(config, subCmdArgs)
+	case "digest":
+		digest(config, subCmdArgs)
 	default:
 		fmt.Println("Unknown command:", subCmd)
 		flag.Usage()
+		if finishPager != nil {
+			finishPager()
+		}
 		os.Exit(1)
 	}
+
+	if finishCapture != nil {
+		if err := finishCapture(); err != nil {
+			printError(err)
+			os.Exit(1)
+		}
+	}
+	if finishPager != nil {
+		// Quitting the pager early isn't an error
+		finishPager()
+	}
+	if archiving {
+		if !skipDryRun("Archive the report in "+archiveDir, "") {
+			if path, err := archiveReport(archiveDir, subCmd, subCmdArgs, reportPath, time.Now()); err != nil {
+				printError(fmt.Errorf("archiving report: %w", err))
+			} else {
+				auditOutputs.Report = path
+			}
+		}
+		// The post hook owns the report file; otherwise it was only kept for the archive
+		if !postHook {
+			os.Remove(reportPath)
+		}
+	}
+	recordAudit(config, subCmd)
+	if postHook {
+		hook.Urgency = commandUrgency
+		if err := runHook(ctx, config, "post_"+subCmd, hook); err != nil {
+			printError(err)
+			os.Exit(1)
+		}
+		if dryRun {
+			// The hook that would have owned the report didn't run
+			os.Remove(reportPath)
+		}
+	}
 }
 
 func rebalance(config *Config, args []string) {
-	var portfolioCsv string
+	var portfolioCsv, lotsCsv, format string
 	var toDeposit int
+	var explain bool
 	flagSet := flag.NewFlagSet("rebalance", flag.ExitOnError)
-	flagSet.IntVar(&toDeposit, "toDeposit", 0, "Additional amount to deposit, in dollars")
+	flagSet.StringVar(&format, "format", "text", "Output format: text or json")
+	flagSet.BoolVar(&explain, "explain", false, "Show the arithmetic behind each recommended trade")
+	flagSet.StringVar(&lotsCsv, "lots", "", "Lot-level CSV used to name the specific lots to sell")
+	applyFraction := fractionFlags(flagSet, config)
+	flagSet.Func("toDeposit", "Additional amount to deposit, in dollars (e.g. 1500, 1,500.25, $1500); negative for a withdrawal", func(value string) error {
+		amount, err := amountToInt(value)
+		toDeposit = amount
+		return err
+	})
 	if len(args) < 1 {
 		flag.Usage()
 		return
 	}
 	portfolioCsv = args[0]
 	flagSet.Parse(args[1:])
+	if format != "text" && format != "json" {
+		printError(codedErrorf(CodeUsage, "unknown format %q", format))
+		return
+	}
+	if err := applyFraction(); err != nil {
+		printError(err)
+		return
+	}
 
-	// Convert to cents
-	toDeposit *= 100
-
-	file, err := os.Open(portfolioCsv)
+	holdings, err := loadPortfolio(config, portfolioCsv)
 	if err != nil {
-		fmt.Println("Error:", err)
+		printError(err)
 		return
 	}
-	defer file.Close()
-
-	result, err := rebalanceCalc(config, file, toDeposit)
+	var lots map[string][]Lot
+	if lotsCsv != "" {
+		lotsFile, err := os.Open(lotsCsv)
+		if err != nil {
+			printError(err)
+			return
+		}
+		defer lotsFile.Close()
+		lotsReader, done := withProgress(lotsFile, "Reading "+lotsCsv)
+		lots, err = readLots(config, holdings, lotsReader)
+		done()
+		if err != nil {
+			printError(err)
+			return
+		}
+		holdings.Locked = lockedLots(lots)
+	}
+	result, err := allocationCalc(config, holdings, toDeposit)
 	if err != nil {
-		fmt.Println("Error:", err)
+		printError(err)
+		return
+	}
+	if lots != nil {
+		assignLotSales(config, result, lots, time.Now())
+	}
+	if err := addTradeWarnings(config, result); err != nil {
+		printError(err)
+		return
+	}
+	if explain {
+		explainRebalance(config, holdings, result)
+	}
+	commandUrgency = result.Urgency
+	auditOutputs.rebalanced(result)
+	printRebalance(config, holdings, result, format)
+}
+
+// printRebalance prints the rebalance report of result as text or json
+func printRebalance(config *Config, holdings *Holdings, result *RebalanceResult, format string) {
+	if format == "json" {
+		if config.Benchmark != nil {
+			result.Benchmark = benchmarkWeights(config, holdings)
+		}
+		if len(config.Sleeves) > 0 {
+			result.Sleeves = sleeveCalc(config, holdings)
+		}
+		result.Classes = classCalc(config, result)
+		if config.leveraged() {
+			result.Exposure = exposureCalc(config, result.Symbols)
+		}
+		if config.currencyExposed() {
+			result.CurrencyExposure = currencyExposureCalc(config, result.Symbols)
+		}
+		if err := writeJSON(os.Stdout, result); err != nil {
+			printError(err)
+		}
 		return
 	}
 
 	for _, stock := range config.Stocks {
 		data := result.Symbols[stock.Symbol]
 		needed := formatAmount(data.AmountNeeded, false)
-		if data.AmountNeeded > 0 {
-			needed = green("+" + needed)
-		} else {
-			needed = red(needed)
+		if data.WholeShares {
+			needed = fmt.Sprintf("%d shares (%s)", data.SharesNeeded, formatAmount(data.AmountNeeded-data.ResidualCash, false))
 		}
-		driftStr := fmt.Sprintf("%.2f%%", data.Drift)
+		needed = tradeText(data.AmountNeeded, needed)
+		driftStr := driftText(data.Drift)
 		if data.Drift > 0 {
-			driftStr = green("+" + driftStr)
+			driftStr = green(driftStr)
 		} else {
 			driftStr = red(driftStr)
 		}
-		fmt.Println("\n" + strings.Repeat("-", 60))
+		fmt.Println("\n" + rule())
 		fmt.Printf("%s - %.2f%% (%s)\n", stock.Symbol, data.CurrentPercentage, driftStr)
-		fmt.Println(strings.Repeat("-", 60))
-		fmt.Printf("%s\n", stock.Description)
+		fmt.Println(rule())
+		fmt.Println(wrapText(stock.label(), terminalWidth))
+		if stock.Notes != "" {
+			fmt.Println(wrapText("Notes: "+stock.Notes, terminalWidth))
+		}
+		if stock.URL != "" {
+			fmt.Printf("Link: %s\n", stock.URL)
+		}
 		fmt.Printf("Needed: %s\n", needed)
+		if data.Note != "" {
+			fmt.Printf("Account rule: %s\n", data.Note)
+		}
+		if data.Locked {
+			fmt.Printf("Locked: keeps %s, leaving %s drift after the trades\n", formatAmount(data.Amount+data.AmountNeeded, true), driftText(data.UnavoidableDrift))
+		}
+		if config.BandPolicy != "" {
+			if data.Actionable {
+				fmt.Printf("Outside the %s; trade\n", config.bandLabel())
+			} else {
+				fmt.Printf("Within the %s; trade optional\n", config.bandLabel())
+			}
+		}
+		if data.WholeShares {
+			fmt.Printf("Share Price: %s (%s, whole shares only)\n", formatAmount(data.Price, true), data.Account)
+		}
 		fmt.Printf("Current Total: %s\n", formatAmount(data.Amount, true))
+		printHeldAs(stock, data.HeldAs)
+		printTradeAs(data.TradeAs)
+		if len(data.LotSales) > 0 {
+			printLotSales(config.lotMethod(), data.LotSales)
+		}
+		printExplanation(data.Explanation)
 	}
 
-	fmt.Println("\n" + strings.Repeat("-", 60))
+	fmt.Println("\n" + rule())
 	if result.DepositAmount > 0 {
 		fmt.Printf("Total: %s (includes %s deposit)\n", formatAmount(result.Total, true), formatAmount(result.DepositAmount, true))
+	} else if result.DepositAmount < 0 {
+		fmt.Printf("Total: %s (after %s withdrawal)\n", formatAmount(result.Total, true), formatAmount(-result.DepositAmount, true))
 	} else {
 		fmt.Printf("Total: %s\n", formatAmount(result.Total, true))
 	}
+	if result.Fraction < 1 {
+		fmt.Printf("Trades move each symbol %g%% of the way back to target\n", result.Fraction*100)
+	}
+	if result.ResidualCash != 0 {
+		fmt.Printf("Residual cash from whole-share rounding: %s\n", formatAmount(result.ResidualCash, true))
+	}
+	fmt.Printf("As of: %s\n", formatAsOf(result.AsOf, result.AsOfSource))
+	fmt.Println(urgencyText(result.Urgency))
+	printBands(config, result)
+	printClasses(config, result)
+	printMergedRows(config, holdings)
+	printTargetDateFunds(holdings)
+	printStaticPositions(holdings)
+	printCashDrag(config, holdings)
+	printCashChecks(result)
+	printTradeWarnings(result.TradeWarnings)
+	printTradeSequence(result)
+	printBenchmarkWeights(config, holdings)
+	printExposure(config, result)
+	printCurrencyExposure(config, result)
+	printSleeves(config, holdings)
+
+	if len(config.Unvested) > 0 {
+		if err := printUnvestedAllocation(config, holdings, result.DepositAmount); err != nil {
+			printError(err)
+		}
+	}
+}
+
+func printMergedRows(config *Config, holdings *Holdings) {
+	merged := holdings.mergedRows()
+	if len(merged) == 0 {
+		return
+	}
+	if config.DuplicateRows == "warn" {
+		for _, symbol := range merged {
+			fmt.Println(red(fmt.Sprintf("Warning: %s appears in %d rows; their values were summed", symbol, holdings.RowCounts[symbol])))
+		}
+		return
+	}
+	counts := make([]string, 0, len(merged))
+	for _, symbol := range merged {
+		counts = append(counts, fmt.Sprintf("%s (%d)", symbol, holdings.RowCounts[symbol]))
+	}
+	fmt.Printf("Merged rows: %s\n", strings.Join(counts, ", "))
+}
+
+// Holdings are the configured positions read from a portfolio CSV, keyed by
+// primary symbol with alternatives folded in
+type Holdings struct {
+	Amounts map[string]int
+	// AmountsByAccount tracks how much of each symbol is held in each configured account
+	AmountsByAccount map[string]map[string]int
+	// Prices holds the last known share price of each primary symbol
+	Prices map[string]int
+	// CostBasis holds the total cost basis of each primary symbol, excluding alternatives
+	CostBasis map[string]int
+	// RowCounts holds the number of CSV rows read for each symbol, as it appears in the CSV
+	RowCounts map[string]int
+	// FundAmounts holds the value of each target-date fund, which is also
+	// spread across its underlying symbols in Amounts
+	FundAmounts map[string]int
+	// Cash holds uninvested cash (sweep funds, pending deposits) by the
+	// account name or number of its row, or "" when the CSV has neither
+	Cash map[string]int
+	// AvailableCash holds the cash each account's summary says can be spent
+	// on purchases, keyed the same way as Cash, for exports that give one
+	AvailableCash map[string]int
+	// Positions holds each configured-symbol row as read, for exports that
+	// need share counts by account
+	Positions []Position
+	// AccountTotals holds the value of every row read, cash included, keyed
+	// the same way as Cash
+	AccountTotals map[string]int
+	// Static holds the static positions added to the export
+	Static []StaticPosition
+	// Liabilities holds the balance owed on each static liability, keyed by
+	// its symbol and account
+	Liabilities map[string]int
+	// Locked holds the value of each primary symbol's locked lots, which
+	// aren't sold
+	Locked map[string]int
+	// Warnings are what reading the holdings found that didn't stop it, such
+	// as symbols missing from the config or a stale export
+	Warnings []Warning
+	// AsOf is when the holdings were valued: the export date from the CSV's
+	// "Date downloaded" or "as of" line, or else the file's modification time
+	AsOf       time.Time
+	AsOfSource string
+}
+
+// Position is one CSV row of a configured symbol
+type Position struct {
+	// Account is the row's account name or number, configured or not
+	Account string
+	// Symbol is the symbol as it appears in the CSV, which may be an
+	// alternative to Primary
+	Symbol   string
+	Primary  string
+	Quantity float64
+	Price    int
+	Value    int
+	// SharesOnly is set for a row that gave a quantity without a value or
+	// price, until valueShares prices it
+	SharesOnly bool
+}
+
+// loadPortfolio reads a portfolio with readPortfolio, printing its warnings
+// on stderr
+func loadPortfolio(config *Config, path string) (*Holdings, error) {
+	holdings, err := readPortfolio(config, path)
+	if err != nil {
+		return nil, err
+	}
+	printWarnings(os.Stderr, holdings.Warnings)
+	return holdings, nil
+}
+
+// readPortfolio reads the holdings in a portfolio CSV or GnuCash book,
+// failing if they are older than the -maxAge flag allows and adding a
+// warning if they are stale. A comma-separated list of portfolios, such as a
+// brokerage CSV and a crypto exchange export, is read as one.
+func readPortfolio(config *Config, path string) (*Holdings, error) {
+	var holdings *Holdings
+	for _, path := range portfolioPaths(path) {
+		source, err := loadPortfolioSource(config, path)
+		if err != nil {
+			return nil, err
+		}
+		if holdings == nil {
+			holdings = source
+		} else {
+			holdings.merge(source)
+		}
+	}
+	if err := addStaticPositions(config, holdings); err != nil {
+		return nil, err
+	}
+	warning, err := checkStaleness(config, holdings.AsOf, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if warning != nil {
+		holdings.Warnings = append(holdings.Warnings, *warning)
+	}
+	if err := valueShares(context.Background(), config, holdings); err != nil {
+		return nil, err
+	}
+	if err := fillQuotes(context.Background(), config, holdings); err != nil {
+		return nil, err
+	}
+	return holdings, nil
+}
+
+// portfolioPaths splits a comma-separated list of portfolios, unless a file
+// is named by the whole path
+func portfolioPaths(path string) []string {
+	if _, err := os.Stat(path); err != nil && strings.Contains(path, ",") {
+		paths := strings.Split(path, ",")
+		for i := range paths {
+			paths[i] = strings.TrimSpace(paths[i])
+		}
+		return paths
+	}
+	return []string{path}
+}
+
+// loadPortfolioSource reads the holdings of a single plugin or file, telling
+// a GnuCash book by its name and a crypto exchange export by its header
+func loadPortfolioSource(config *Config, path string) (*Holdings, error) {
+	if name, ok := strings.CutPrefix(path, pluginPrefix); ok {
+		holdings, err := loadPluginHoldings(context.Background(), config, name)
+		if err != nil {
+			return nil, err
+		}
+		if holdings.AsOf.IsZero() {
+			holdings.AsOf, holdings.AsOfSource = time.Now(), asOfExport
+		}
+		return holdings, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	br := bufio.NewReader(file)
+	read := readHoldings
+	if isGnuCashBook(path) {
+		read = readGnuCash
+	} else if crypto := cryptoReader(br); crypto != nil {
+		read = crypto
+	} else if preset := presetReader(br); preset != nil {
+		read = preset
+	}
+	holdings, err := read(config, br)
+	if err != nil {
+		return nil, err
+	}
+	if holdings.AsOf.IsZero() {
+		if info, err := file.Stat(); err == nil {
+			holdings.AsOf = info.ModTime()
+			holdings.AsOfSource = asOfFileModified
+		}
+	}
+	return holdings, nil
+}
+
+// merge adds the holdings of other, which came from another source. They are
+// as old as the older of the two.
+func (h *Holdings) merge(other *Holdings) {
+	for _, pair := range []struct{ into, from map[string]int }{
+		{h.Amounts, other.Amounts}, {h.CostBasis, other.CostBasis}, {h.RowCounts, other.RowCounts},
+		{h.FundAmounts, other.FundAmounts}, {h.Cash, other.Cash}, {h.AvailableCash, other.AvailableCash},
+		{h.AccountTotals, other.AccountTotals},
+	} {
+		for key, value := range pair.from {
+			pair.into[key] += value
+		}
+	}
+	for symbol, accounts := range other.AmountsByAccount {
+		if h.AmountsByAccount[symbol] == nil {
+			h.AmountsByAccount[symbol] = make(map[string]int)
+		}
+		for account, amount := range accounts {
+			h.AmountsByAccount[symbol][account] += amount
+		}
+	}
+	for symbol, price := range other.Prices {
+		if h.Prices[symbol] == 0 {
+			h.Prices[symbol] = price
+		}
+	}
+	h.Positions = append(h.Positions, other.Positions...)
+	h.Warnings = append(h.Warnings, other.Warnings...)
+	if other.AsOf.Before(h.AsOf) {
+		h.AsOf, h.AsOfSource = other.AsOf, other.AsOfSource
+	}
+}
+
+// mergedRows returns the symbols that appeared in more than one CSV row, sorted
+func (h *Holdings) mergedRows() []string {
+	var symbols []string
+	for symbol, count := range h.RowCounts {
+		if count > 1 {
+			symbols = append(symbols, symbol)
+		}
+	}
+	slices.Sort(symbols)
+	return symbols
+}
+
+// clone returns a copy of the holdings whose amounts can be adjusted without
+// affecting the original
+func (h *Holdings) clone() *Holdings {
+	c := *h
+	c.Amounts = make(map[string]int, len(h.Amounts))
+	for symbol, amount := range h.Amounts {
+		c.Amounts[symbol] = amount
+	}
+	return &c
 }
 
 func rebalanceCalc(config *Config, csvReader io.Reader, depositCents int) (*RebalanceResult, error) {
+	holdings, err := readHoldings(config, csvReader)
+	if err != nil {
+		return nil, err
+	}
+	return allocationCalc(config, holdings, depositCents)
+}
+
+func readHoldings(config *Config, csvReader io.Reader) (*Holdings, error) {
 	// Build a map from any symbol (primary or alternative) to its primary symbol
 	symbolToPrimary := make(map[string]string)
 	for _, stock := range config.Stocks {
@@ -152,18 +1013,81 @@ func rebalanceCalc(config *Config, csvReader io.Reader, depositCents int) (*Reba
 			symbolToPrimary[alt] = stock.Symbol
 		}
 	}
-	reader := csv.NewReader(csvReader)
-	reader.FieldsPerRecord = -1 // Allow variable number of fields per record
-	amountsBySymbol := make(map[string]int)
-	total := depositCents
-	header, err := reader.Read()
-	if err != nil {
-		return nil, fmt.Errorf("error reading header: %w", err)
+	reader := newCSVReader(csvReader)
+	holdings := &Holdings{
+		Amounts:          make(map[string]int),
+		AmountsByAccount: make(map[string]map[string]int),
+		Prices:           make(map[string]int),
+		CostBasis:        make(map[string]int),
+		RowCounts:        make(map[string]int),
+		FundAmounts:      make(map[string]int),
+		Cash:             make(map[string]int),
+		AvailableCash:    make(map[string]int),
+		AccountTotals:    make(map[string]int),
+	}
+	fundAmounts := make(map[string]map[string]int)
+	// unmatched sums the value of each symbol missing from the config, in the
+	// order they're read
+	unmatched := make(map[string]int)
+	var unmatchedSymbols []string
+	// Some exports put a title or "as of" line above the header, so look for
+	// the header within the first few lines
+	var header, previous []string
+	// sectionAccount is the account of the section being read, for exports
+	// that group positions under account summaries instead of having an
+	// account column
+	sectionAccount := ""
+	// Plan statements, such as DSPP and ESOP ones, may give a Quantity
+	// without a Current Value
+	symbolIndex, amountIndex, quantityIndex := -1, -1, -1
+	for line := 0; symbolIndex == -1 || (amountIndex == -1 && quantityIndex == -1); line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) || line == maxPreambleLines {
+			return nil, codedErrorf(CodeCSVHeader, "CSV file must have 'Symbol' and 'Current Value' or 'Quantity' columns")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading header: %w", err)
+		}
+		if asOf, found := parseAsOf(record); found {
+			holdings.AsOf, holdings.AsOfSource = asOf, asOfExport
+		}
+		if account, found := summaryAccount(previous, record); found {
+			sectionAccount = account
+			cash, found, err := summaryAvailableCash(previous, record)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				holdings.AvailableCash[account] = cash
+			}
+		}
+		previous = record
+		header = normalizeHoldingsHeader(record)
+		symbolIndex = holdingsColumn(header, "Symbol")
+		amountIndex = holdingsColumn(header, "Current Value")
+		quantityIndex = holdingsColumn(header, "Quantity")
+	}
+	// Optional columns used for account attribution and whole-share trades
+	accountNumberIndex := holdingsColumn(header, "Account Number")
+	accountNameIndex := holdingsColumn(header, "Account Name")
+	priceIndex := holdingsColumn(header, "Last Price")
+	costBasisIndex := holdingsColumn(header, "Cost Basis Total")
+	pricePaidIndex := holdingsColumn(header, "Price Paid")
+	assetClassIndex := holdingsColumn(header, "Asset Class")
+	descriptionIndex := holdingsColumn(header, "Description")
+	classToPrimary := assetClassStocks(config)
+	rowAccountName := func(record []string) string {
+		if accountNameIndex < 0 {
+			return sectionAccount
+		}
+		return field(record, accountNameIndex)
 	}
-	symbolIndex := slices.Index(header, "Symbol")
-	amountIndex := slices.Index(header, "Current Value")
-	if symbolIndex == -1 || amountIndex == -1 {
-		return nil, errors.New("CSV file must have 'Symbol' and 'Current Value' columns")
+	// rowAccount identifies a row's account whether or not it is configured
+	rowAccount := func(record []string) string {
+		if name := rowAccountName(record); name != "" {
+			return name
+		}
+		return field(record, accountNumberIndex)
 	}
 	for {
 		record, err := reader.Read()
@@ -177,54 +1101,272 @@ func rebalanceCalc(config *Config, csvReader io.Reader, depositCents int) (*Reba
 			}
 			return nil, err
 		}
-		// Skip rows that don't have enough fields
+		if account, found := summaryAccount(previous, record); found {
+			sectionAccount = account
+			cash, found, err := summaryAvailableCash(previous, record)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				holdings.AvailableCash[account] = cash
+			}
+		}
+		previous = record
+		// Skip rows that don't have enough fields, such as the footer of
+		// Fidelity exports, after checking them for the download date
 		if len(record) <= symbolIndex || len(record) <= amountIndex {
+			if asOf, found := parseAsOf(record); found {
+				holdings.AsOf, holdings.AsOfSource = asOf, asOfExport
+			}
+			if symbol := field(record, symbolIndex); symbolToPrimary[symbol] != "" {
+				line, _ := reader.FieldPos(0)
+				holdings.Warnings = append(holdings.Warnings, Warning{Code: WarningSkippedRow, Symbol: symbol, Line: line,
+					Message: fmt.Sprintf("the %s row on line %d has no value and was skipped", symbol, line)})
+			}
 			continue
 		}
 		symbol := record[symbolIndex]
 
-		// Look up the primary symbol (handles both primary and alternative symbols)
+		// Target-date funds are split into their holdings once the as-of
+		// date, which selects the glide path step, is known
+		if config.targetDateFund(symbol) != nil {
+			amount, err := amountToInt(field(record, amountIndex))
+			if err != nil {
+				return nil, codedErrorf(CodeCSVValue, "error parsing amount: %w", err)
+			}
+			accountName := ""
+			if account := config.account(field(record, accountNumberIndex), rowAccountName(record)); account != nil {
+				accountName = account.Name
+			}
+			if fundAmounts[symbol] == nil {
+				fundAmounts[symbol] = make(map[string]int)
+			}
+			fundAmounts[symbol][accountName] += amount
+			holdings.AccountTotals[rowAccount(record)] += amount
+			continue
+		}
+
+		// Look up the primary symbol (handles both primary and alternative
+		// symbols), then the stock a robo-advisor's asset class counts as
 		primarySymbol, found := symbolToPrimary[symbol]
+		assetClass := strings.TrimSpace(field(record, assetClassIndex))
+		if !found && assetClass != "" {
+			if primarySymbol, found = classToPrimary[strings.ToLower(assetClass)]; found && symbol == "" {
+				// Some robo statements give the class alone
+				symbol = assetClass
+			}
+		}
+		description := field(record, descriptionIndex)
+		if !found && (config.isCash(symbol) || symbol == "" && config.isCash(assetClass) || isCashDescription(description)) {
+			amount, err := amountToInt(field(record, amountIndex))
+			if err != nil {
+				return nil, codedErrorf(CodeCSVValue, "error parsing amount: %w", err)
+			}
+			holdings.Cash[rowAccount(record)] += amount
+			holdings.AccountTotals[rowAccount(record)] += amount
+			continue
+		}
 		if !found {
-			// Ignore any symbols that are not in the config
+			// Symbols that are not in the config are left out, with a warning
+			if amount, err := amountToInt(field(record, amountIndex)); err == nil && amount != 0 && unmatchedSymbol(symbol) {
+				if _, seen := unmatched[symbol]; !seen {
+					unmatchedSymbols = append(unmatchedSymbols, symbol)
+				}
+				unmatched[symbol] += amount
+			}
 			continue
 		}
 
-		amount, err := amountToInt(record[amountIndex])
-		if err != nil {
-			return nil, fmt.Errorf("error parsing amount: %w", err)
+		position := Position{Account: rowAccount(record), Symbol: symbol, Primary: primarySymbol}
+		if quantity := strings.ReplaceAll(field(record, quantityIndex), ",", ""); quantity != "" {
+			if position.Quantity, err = strconv.ParseFloat(quantity, 64); err != nil {
+				return nil, codedErrorf(CodeCSVValue, "error parsing quantity for %s: %w", symbol, err)
+			}
+		}
+		if field(record, priceIndex) != "" {
+			if position.Price, err = amountToInt(field(record, priceIndex)); err != nil {
+				return nil, codedErrorf(CodeCSVValue, "error parsing price: %w", err)
+			}
+		}
+		// Shares without a value are valued at the row's price, or else
+		// later by valueShares
+		switch value := field(record, amountIndex); {
+		case value != "" || position.Quantity == 0:
+			if position.Value, err = amountToInt(value); err != nil {
+				return nil, codedErrorf(CodeCSVValue, "error parsing amount: %w", err)
+			}
+		case position.Price > 0:
+			position.Value = int(math.Round(position.Quantity * float64(position.Price)))
+		default:
+			position.SharesOnly = true
+		}
+		amount := position.Value
+		holdings.Amounts[primarySymbol] += amount
+		holdings.AccountTotals[rowAccount(record)] += amount
+		line, _ := reader.FieldPos(0)
+		if warning := reconcileValue(config, position, line); warning != nil {
+			holdings.Warnings = append(holdings.Warnings, *warning)
+		}
+		holdings.Positions = append(holdings.Positions, position)
+		holdings.RowCounts[symbol]++
+		if holdings.RowCounts[symbol] > 1 && config.DuplicateRows == "error" {
+			return nil, fmt.Errorf("%s appears in more than one row (set duplicate_rows to sum or warn to allow this)", symbol)
+		}
+
+		if account := config.account(field(record, accountNumberIndex), rowAccountName(record)); account != nil {
+			if holdings.AmountsByAccount[primarySymbol] == nil {
+				holdings.AmountsByAccount[primarySymbol] = make(map[string]int)
+			}
+			holdings.AmountsByAccount[primarySymbol][account.Name] += amount
+		}
+		if symbol == primarySymbol && field(record, priceIndex) != "" {
+			price, err := amountToInt(field(record, priceIndex))
+			if err != nil {
+				return nil, codedErrorf(CodeCSVValue, "error parsing price: %w", err)
+			}
+			holdings.Prices[primarySymbol] = price
+		}
+		if symbol == primarySymbol && field(record, costBasisIndex) != "" {
+			basis, err := amountToInt(field(record, costBasisIndex))
+			if err != nil {
+				return nil, codedErrorf(CodeCSVValue, "error parsing cost basis: %w", err)
+			}
+			holdings.CostBasis[primarySymbol] += basis
+		} else if symbol == primarySymbol && field(record, pricePaidIndex) != "" {
+			pricePaid, err := amountToInt(field(record, pricePaidIndex))
+			if err != nil {
+				return nil, codedErrorf(CodeCSVValue, "error parsing price paid: %w", err)
+			}
+			holdings.CostBasis[primarySymbol] += int(math.Round(float64(pricePaid) * position.Quantity))
 		}
+	}
+	for _, symbol := range unmatchedSymbols {
+		holdings.Warnings = append(holdings.Warnings, Warning{Code: WarningUnmatchedSymbol, Symbol: symbol,
+			Message: fmt.Sprintf("%s isn't in the config, so its %s was left out", symbol, formatAmount(unmatched[symbol], true))})
+	}
+	addTargetDateFunds(config, holdings, fundAmounts)
+	return holdings, nil
+}
+
+// allocationCalc compares holdings against the target allocation and works out
+// the trades needed to get back to target after depositing depositCents
+func allocationCalc(config *Config, holdings *Holdings, depositCents int) (*RebalanceResult, error) {
+	total := depositCents
+	for _, amount := range holdings.Amounts {
 		total += amount
-		amountsBySymbol[primarySymbol] += amount
+	}
+	if total <= 0 {
+		if depositCents < 0 {
+			return nil, fmt.Errorf("withdrawal of %s exceeds portfolio value of %s", formatAmount(-depositCents, true), formatAmount(total-depositCents, true))
+		}
+		return nil, errors.New("portfolio has no value in any configured symbol")
 	}
 
 	symbolData := make(map[string]SymbolData)
+	heldAs := heldAsCalc(config, holdings, total)
+	residualCash := 0
+	invested := total - depositCents
+	fraction := config.rebalanceFraction()
 	for _, stock := range config.Stocks {
-		currentAmount := amountsBySymbol[stock.Symbol]
+		currentAmount := holdings.Amounts[stock.Symbol]
 		currentPercentage := (float64(currentAmount) / float64(total)) * 100
-		drift := currentPercentage - stock.TargetPercentage
-		data := SymbolData{
+		symbolData[stock.Symbol] = SymbolData{
 			Amount:            currentAmount,
 			CurrentPercentage: currentPercentage,
 			TargetPercentage:  stock.TargetPercentage,
-			Drift:             drift,
-			AmountNeeded:      int(math.Round(float64(total) * (-drift / 100))),
+			Drift:             currentPercentage - stock.TargetPercentage,
+			AmountNeeded:      config.round(neededAmount(total, invested, currentAmount, stock.TargetPercentage, fraction), "half-up"),
+			Account:           tradeAccount(holdings.AmountsByAccount[stock.Symbol]),
+			Price:             holdings.Prices[stock.Symbol],
+			HeldAs:            heldAs[stock.Symbol],
+		}
+	}
+	now := time.Now()
+	applyAccountRules(config, holdings, symbolData, total, now)
+
+	for _, stock := range config.Stocks {
+		data := symbolData[stock.Symbol]
+		if account := config.account(data.Account, ""); account != nil && !account.fractional() && data.Price > 0 {
+			// By default round toward zero so a whole-share trade never exceeds
+			// the dollar recommendation
+			data.WholeShares = true
+			data.SharesNeeded = config.round(float64(data.AmountNeeded)/float64(data.Price), "truncate")
+			data.ResidualCash = data.AmountNeeded - data.SharesNeeded*data.Price
+			residualCash += data.ResidualCash
 		}
+		data.Actionable = config.actionable(data)
+		data.TradeAs = tradeAsCalc(stock, holdings.Positions, data.AmountNeeded)
 		symbolData[stock.Symbol] = data
 	}
 
 	return &RebalanceResult{
+		Fraction:      fraction,
 		Symbols:       symbolData,
 		Total:         total,
 		DepositAmount: depositCents,
+		ResidualCash:  residualCash,
+		AsOf:          holdings.AsOf,
+		AsOfSource:    holdings.AsOfSource,
+		Urgency:       urgencyCalc(config, symbolData),
+		CashChecks:    cashCheckCalc(config, holdings, symbolData),
+		Sequence:      tradeSequence(config, holdings, symbolData, now),
+		Warnings:      holdings.Warnings,
 	}, nil
 }
 
+// neededAmount is the trade, before rounding, that moves currentAmount of a
+// portfolio worth total after a deposit (invested before it) to its target
+// percentage, or fraction of the way there
+func neededAmount(total, invested, currentAmount int, target, fraction float64) float64 {
+	if fraction < 1 && invested > 0 {
+		// Part of the way from the weight before the deposit, so that the
+		// trades still add up to the deposit
+		held := float64(currentAmount) / float64(invested) * 100
+		return float64(total)*(held+fraction*(target-held))/100 - float64(currentAmount)
+	}
+	return float64(total)*target/100 - float64(currentAmount)
+}
+
+// tradeAccount picks the account holding the largest value of a symbol, which
+// is where trades for that symbol are recommended
+func tradeAccount(amounts map[string]int) string {
+	best := ""
+	for account, amount := range amounts {
+		if best == "" || amount > amounts[best] || (amount == amounts[best] && account < best) {
+			best = account
+		}
+	}
+	return best
+}
+
+// normalizeHeader trims stray whitespace and byte order marks from column names
+func normalizeHeader(header []string) []string {
+	normalized := make([]string, len(header))
+	for i, name := range header {
+		normalized[i] = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+	}
+	return normalized
+}
+
+// field returns the value at index, or an empty string if the column is missing
+func field(record []string, index int) string {
+	if index < 0 || index >= len(record) {
+		return ""
+	}
+	return record[index]
+}
+
 func green(str string) string {
+	if plain {
+		return str
+	}
 	return "\033[32m" + str + "\033[0m"
 }
 
 func red(str string) string {
+	if plain {
+		return str
+	}
 	return "\033[31m" + str + "\033[0m"
 }
 
@@ -235,16 +1377,13 @@ func deposit(config *Config, args []string) {
 		flag.Usage()
 		return
 	}
-	amount, err := strconv.Atoi(args[0])
+	amount, err := amountToInt(args[0])
 	if err != nil {
-		fmt.Println("Error parsing amount:", err)
+		printError(codedErrorf(CodeUsage, "parsing amount: %w", err))
 		return
 	}
 	flagSet.Parse(args[1:])
 
-	// Convert amount to cents
-	amount *= 100
-
 	result := depositCalc(config, amount)
 
 	for _, stock := range config.Stocks {
@@ -257,7 +1396,7 @@ func depositCalc(config *Config, amountCents int) *DepositResult {
 	total := 0
 
 	for _, stock := range config.Stocks {
-		amountToDeposit := int(math.Floor(float64(amountCents) * (stock.TargetPercentage / 100)))
+		amountToDeposit := config.round(float64(amountCents)*(stock.TargetPercentage/100), "floor")
 		allocations[stock.Symbol] = amountToDeposit
 		total += amountToDeposit
 	}
@@ -268,60 +1407,338 @@ func depositCalc(config *Config, amountCents int) *DepositResult {
 	}
 }
 
+// buyOnlyCalc splits a deposit across symbols without selling anything. Each
+// underweight symbol receives a share of the deposit proportional to how far
+// it is below its target after the deposit; any money left once every symbol
+// is at target is split by target percentage.
+func buyOnlyCalc(config *Config, holdings map[string]int, amountCents int) map[string]int {
+	total := amountCents
+	for _, stock := range config.Stocks {
+		total += holdings[stock.Symbol]
+	}
+
+	shortfalls := make(map[string]int)
+	totalShortfall := 0
+	for _, stock := range config.Stocks {
+		target := config.round(float64(total)*(stock.TargetPercentage/100), "floor")
+		if shortfall := target - holdings[stock.Symbol]; shortfall > 0 {
+			shortfalls[stock.Symbol] = shortfall
+			totalShortfall += shortfall
+		}
+	}
+
+	allocations := make(map[string]int)
+	if totalShortfall <= amountCents {
+		leftover := amountCents - totalShortfall
+		for _, stock := range config.Stocks {
+			allocations[stock.Symbol] = shortfalls[stock.Symbol] + config.round(float64(leftover)*(stock.TargetPercentage/100), "floor")
+		}
+		return allocations
+	}
+	for _, stock := range config.Stocks {
+		allocations[stock.Symbol] = config.round(float64(amountCents)*float64(shortfalls[stock.Symbol])/float64(totalShortfall), "floor")
+	}
+	return allocations
+}
+
+// sellOnlyCalc splits a withdrawal across symbols without buying anything,
+// mirroring buyOnlyCalc: each overweight symbol gives up a share of the
+// withdrawal proportional to how far it is above its target after the
+// withdrawal; anything still needed once every symbol is at target is taken
+// by target percentage.
+func sellOnlyCalc(config *Config, holdings map[string]int, amountCents int) map[string]int {
+	total := -amountCents
+	for _, stock := range config.Stocks {
+		total += holdings[stock.Symbol]
+	}
+
+	excesses := make(map[string]int)
+	totalExcess := 0
+	for _, stock := range config.Stocks {
+		target := config.round(float64(total)*(stock.TargetPercentage/100), "floor")
+		if excess := holdings[stock.Symbol] - target; excess > 0 {
+			excesses[stock.Symbol] = excess
+			totalExcess += excess
+		}
+	}
+
+	sales := make(map[string]int)
+	if totalExcess <= amountCents {
+		leftover := amountCents - totalExcess
+		for _, stock := range config.Stocks {
+			sales[stock.Symbol] = excesses[stock.Symbol] + config.round(float64(leftover)*(stock.TargetPercentage/100), "floor")
+		}
+		return sales
+	}
+	for _, stock := range config.Stocks {
+		sales[stock.Symbol] = config.round(float64(amountCents)*float64(excesses[stock.Symbol])/float64(totalExcess), "floor")
+	}
+	return sales
+}
+
 func parseConfig(filePath string) (*Config, error) {
-	file, err := os.Open(filePath)
+	data, err := readConfigFile(filePath)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
+	config, err := parseConfigData(data)
+	if err != nil {
+		return nil, err
+	}
+	config.resolveDataPaths(filePath)
+	return config, nil
+}
+
+// parseConfigData decodes and validates a config that isn't read from a file,
+// such as one about to be written
+func parseConfigData(data []byte) (*Config, error) {
+	config, err := decodeConfigData(data, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.validate(); err != nil {
+		if errorCode(err) == CodeOther {
+			err = &CodedError{Code: CodeConfigInvalid, Err: err}
+		}
+		return nil, err
+	}
+	return config, nil
+}
+
+// decodeConfig reads the YAML config and checks it against the config schema,
+// leaving the rules the schema can't express to validate. With knownFields,
+// keys that don't match a config field are reported as errors.
+func decodeConfig(filePath string, knownFields bool) (*Config, error) {
+	data, err := readConfigFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return decodeConfigData(data, knownFields)
+}
+
+func decodeConfigData(data []byte, knownFields bool) (*Config, error) {
+	// The schema pins errors to a line and column, so check it before the
+	// decoder reports the same mistakes less precisely
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, &CodedError{Code: CodeConfigInvalid, Err: err}
+	}
+	if err := validateSchema(&doc); err != nil {
+		return nil, err
+	}
 
 	var config Config
-	decoder := yaml.NewDecoder(file)
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(knownFields)
 	if err := decoder.Decode(&config); err != nil {
-		return nil, err
+		return nil, &CodedError{Code: CodeConfigInvalid, Err: err}
 	}
+	return &config, nil
+}
 
+func (c *Config) validate() error {
 	totalPercentage := 0.0
-	for _, stock := range config.Stocks {
+	for _, stock := range c.Stocks {
 		totalPercentage += stock.TargetPercentage
 	}
 
 	if math.Abs(totalPercentage-100.0) > 1e-9 {
-		return nil, errors.New("target percentages do not add up to 100")
+		return codedErrorf(CodeConfigSum, "target percentages do not add up to 100")
 	}
 
 	// Validate that no symbol appears multiple times (as primary or alternative)
 	symbolOwner := make(map[string]string) // maps symbol to the primary stock that owns it
-	for _, stock := range config.Stocks {
+	for _, stock := range c.Stocks {
 		// Check primary symbol
 		if owner, exists := symbolOwner[stock.Symbol]; exists {
-			return nil, fmt.Errorf("symbol %s appears multiple times (primary for both %s and %s)", stock.Symbol, owner, stock.Symbol)
+			return fmt.Errorf("symbol %s appears multiple times (primary for both %s and %s)", stock.Symbol, owner, stock.Symbol)
 		}
 		symbolOwner[stock.Symbol] = stock.Symbol
 
+		if stock.URL != "" {
+			if u, err := url.Parse(stock.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("url %q for %s is not an http or https link", stock.URL, stock.Symbol)
+			}
+		}
+
 		// Check alternative symbols
 		for _, alt := range stock.Alternatives {
 			if owner, exists := symbolOwner[alt]; exists {
-				return nil, fmt.Errorf("symbol %s appears multiple times (primary/alternative for %s, alternative for %s)", alt, owner, stock.Symbol)
+				return fmt.Errorf("symbol %s appears multiple times (primary/alternative for %s, alternative for %s)", alt, owner, stock.Symbol)
 			}
 			symbolOwner[alt] = stock.Symbol
 		}
+		if stock.PreferForBuys != "" && stock.PreferForBuys != stock.Symbol && !slices.Contains(stock.Alternatives, stock.PreferForBuys) {
+			return fmt.Errorf("prefer_for_buys %s of %s is not the stock or one of its alternatives", stock.PreferForBuys, stock.Symbol)
+		}
 	}
 
-	return &config, nil
+	if c.DuplicateRows != "" && !slices.Contains(duplicateRowPolicies, c.DuplicateRows) {
+		return fmt.Errorf("unknown duplicate_rows policy %q (expected one of %s)", c.DuplicateRows, strings.Join(duplicateRowPolicies, ", "))
+	}
+	if c.ValueTolerance != nil && *c.ValueTolerance < 0 {
+		return fmt.Errorf("value_tolerance must not be negative")
+	}
+	if err := validateSharePrices(c); err != nil {
+		return err
+	}
+
+	if c.Rounding != "" && !slices.Contains(roundingModes, c.Rounding) {
+		return fmt.Errorf("unknown rounding %q (expected one of %s)", c.Rounding, strings.Join(roundingModes, ", "))
+	}
+
+	if c.LotMethod != "" && !slices.Contains(lotMethods, c.LotMethod) {
+		return fmt.Errorf("unknown lot_method %q (expected one of %s)", c.LotMethod, strings.Join(lotMethods, ", "))
+	}
+
+	if err := c.Paycheck.validate(); err != nil {
+		return err
+	}
+	if err := validateUnvested(c); err != nil {
+		return err
+	}
+	if err := validateTargetDateFunds(c); err != nil {
+		return err
+	}
+	if err := validateTilt(c); err != nil {
+		return err
+	}
+	if err := validateHooks(c); err != nil {
+		return err
+	}
+	if err := validatePlugins(c); err != nil {
+		return err
+	}
+	if err := validateAccounts(c); err != nil {
+		return err
+	}
+	if err := validateTradingRules(c); err != nil {
+		return err
+	}
+	if err := validateWithdrawal(c); err != nil {
+		return err
+	}
+	if err := validateBenchmark(c); err != nil {
+		return err
+	}
+	if err := validatePrices(c); err != nil {
+		return err
+	}
+	if err := validateRemind(c); err != nil {
+		return err
+	}
+	if err := validateFX(c); err != nil {
+		return err
+	}
+	if err := validateNetwork(c); err != nil {
+		return err
+	}
+	if err := validateUrgency(c); err != nil {
+		return err
+	}
+	if err := validateBandPolicy(c); err != nil {
+		return err
+	}
+	if err := validateRebalanceFraction(c); err != nil {
+		return err
+	}
+	if err := validateSleeves(c); err != nil {
+		return err
+	}
+	if err := validateLeverage(c); err != nil {
+		return err
+	}
+	if err := validateStaticPositions(c); err != nil {
+		return err
+	}
+	if err := validateCashFlow(c); err != nil {
+		return err
+	}
+	if err := validateCurrencyExposure(c); err != nil {
+		return err
+	}
+	if err := validateStress(c); err != nil {
+		return err
+	}
+	if err := validateAssetClasses(c); err != nil {
+		return err
+	}
+	if err := validateGoals(c); err != nil {
+		return err
+	}
+
+	return nil
 }
 
+// amountToInt parses a dollar amount into cents. It accepts an optional sign,
+// a leading "$", thousands separators, and any number of decimal places, which
+// are rounded to the nearest cent (e.g. "-$1,234.567" is -123457).
 func amountToInt(amount string) (int, error) {
-	amount = strings.TrimPrefix(amount, "$")
-	amount = strings.ReplaceAll(amount, ".", "")
-	amountInt, err := strconv.Atoi(amount)
+	str := strings.TrimSpace(amount)
+	negative := false
+	if strings.HasPrefix(str, "-") || strings.HasPrefix(str, "+") {
+		negative = str[0] == '-'
+		str = str[1:]
+	}
+	str = strings.TrimPrefix(str, "$")
+	str = strings.ReplaceAll(str, ",", "")
+	dollars, cents, _ := strings.Cut(str, ".")
+	if dollars == "" && cents == "" {
+		return 0, fmt.Errorf("invalid amount %q", amount)
+	}
+	for _, part := range []string{dollars, cents} {
+		if strings.IndexFunc(part, func(r rune) bool { return r < '0' || r > '9' }) != -1 {
+			return 0, fmt.Errorf("invalid amount %q", amount)
+		}
+	}
+	// Pad to at least three fractional digits so the third can be used for rounding
+	cents += "000"
+	amountInt, err := strconv.Atoi(dollars + cents[:2])
 	if err != nil {
-		return 0, err
+		return 0, fmt.Errorf("invalid amount %q: %w", amount, err)
+	}
+	if cents[2] >= '5' {
+		amountInt++
+	}
+	if negative {
+		amountInt = -amountInt
 	}
 	return amountInt, nil
 }
 
+// Money is an amount in cents read from the config. It accepts the same
+// formats as amountToInt, so both 1500.25 and "$1,500.25" work in YAML.
+type Money int
+
+func (m *Money) UnmarshalYAML(node *yaml.Node) error {
+	cents, err := amountToInt(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	*m = Money(cents)
+	return nil
+}
+
+// UnmarshalJSON accepts a JSON number or string, as plugins may send either
+func (m *Money) UnmarshalJSON(data []byte) error {
+	value := string(data)
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = unquoted
+	}
+	cents, err := amountToInt(value)
+	if err != nil {
+		return err
+	}
+	*m = Money(cents)
+	return nil
+}
+
 func formatAmount(amount int, includeCommas bool) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
 	amountStr := strconv.Itoa(amount)
 	if len(amountStr) < 3 {
 		// Ensure at least 3 characters for slicing (e.g., "001" for 1 cent)
@@ -334,9 +1751,5 @@ func formatAmount(amount int, includeCommas bool) string {
 			dollars = dollars[:i] + "," + dollars[i:]
 		}
 	}
-	amountStr = dollars + "." + cents
-	if amount < 0 {
-		return amountStr[:1] + "$" + amountStr[1:]
-	}
-	return "$" + amountStr
+	return sign + "$" + dollars + "." + cents
 }
@@ -24,8 +24,9 @@ type TestInput struct {
 }
 
 type ExpectedResult struct {
-	Total   int                       `json:"total"`
-	Symbols map[string]ExpectedSymbol `json:"symbols"`
+	Total        int                       `json:"total"`
+	ResidualCash int                       `json:"residual_cash"`
+	Symbols      map[string]ExpectedSymbol `json:"symbols"`
 }
 
 type ExpectedSymbol struct {
@@ -33,6 +34,8 @@ type ExpectedSymbol struct {
 	CurrentPercentage float64 `json:"current_percentage"`
 	Drift             float64 `json:"drift"`
 	AmountNeeded      int     `json:"amount_needed"`
+	SharesNeeded      int     `json:"shares_needed"`
+	ResidualCash      int     `json:"residual_cash"`
 }
 
 func TestRebalanceFromDefinitions(t *testing.T) {
@@ -87,6 +90,10 @@ func TestRebalanceFromDefinitions(t *testing.T) {
 				t.Errorf("Total mismatch: got %d, expected %d", result.Total, def.Expected.Total)
 			}
 
+			if result.ResidualCash != def.Expected.ResidualCash {
+				t.Errorf("ResidualCash mismatch: got %d, expected %d", result.ResidualCash, def.Expected.ResidualCash)
+			}
+
 			for symbol, expected := range def.Expected.Symbols {
 				actual, ok := result.Symbols[symbol]
 				if !ok {
@@ -109,6 +116,14 @@ func TestRebalanceFromDefinitions(t *testing.T) {
 				if actual.AmountNeeded != expected.AmountNeeded {
 					t.Errorf("Symbol %s: AmountNeeded mismatch: got %d, expected %d", symbol, actual.AmountNeeded, expected.AmountNeeded)
 				}
+
+				if actual.SharesNeeded != expected.SharesNeeded {
+					t.Errorf("Symbol %s: SharesNeeded mismatch: got %d, expected %d", symbol, actual.SharesNeeded, expected.SharesNeeded)
+				}
+
+				if actual.ResidualCash != expected.ResidualCash {
+					t.Errorf("Symbol %s: ResidualCash mismatch: got %d, expected %d", symbol, actual.ResidualCash, expected.ResidualCash)
+				}
 			}
 		})
 	}
@@ -117,3 +132,109 @@ func TestRebalanceFromDefinitions(t *testing.T) {
 func floatEqual(a, b, tolerance float64) bool {
 	return math.Abs(a-b) <= tolerance
 }
+
+func TestAmountToInt(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+		wantErr  bool
+	}{
+		{"5000", 500000, false},
+		{"1234.56", 123456, false},
+		{"1,500", 150000, false},
+		{"$1,500.25", 150025, false},
+		{"$71000.00", 7100000, false},
+		{"10.5", 1050, false},
+		{".99", 99, false},
+		{"12.345", 1235, false},
+		{"-$3,519.18", -351918, false},
+		{"", 0, true},
+		{"$", 0, true},
+		{"12a", 0, true},
+		{"1.2.3", 0, true},
+	}
+
+	for _, tt := range tests {
+		actual, err := amountToInt(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("amountToInt(%q): unexpected error state: %v", tt.input, err)
+			continue
+		}
+		if actual != tt.expected {
+			t.Errorf("amountToInt(%q): got %d, expected %d", tt.input, actual, tt.expected)
+		}
+	}
+}
+
+func TestDuplicateRowPolicy(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+
+	holdings := loadHoldings(t, config, "duplicate_rows.csv")
+	if merged := holdings.mergedRows(); len(merged) != 2 || merged[0] != "BND" || merged[1] != "VTI" {
+		t.Errorf("Merged rows mismatch: got %v, expected [BND VTI]", merged)
+	}
+
+	config.DuplicateRows = "error"
+	file, err := os.Open(filepath.Join("tests", "portfolios", "duplicate_rows.csv"))
+	if err != nil {
+		t.Fatalf("Failed to open CSV file: %v", err)
+	}
+	defer file.Close()
+	if _, err := readHoldings(config, file); err == nil {
+		t.Error("Expected error for duplicate rows with the error policy")
+	}
+}
+
+func TestStockURL(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+
+	config.Stocks[0].Notes = "Held for the 401k match"
+	config.Stocks[0].URL = "https://investor.vanguard.com/investment-products/etfs/profile/vti"
+	if err := config.validate(); err != nil {
+		t.Errorf("Expected valid url: %v", err)
+	}
+	for _, link := range []string{"investor.vanguard.com/vti", "ftp://example.com/vti", "https://"} {
+		config.Stocks[0].URL = link
+		if err := config.validate(); err == nil {
+			t.Errorf("Expected error for url %q", link)
+		}
+	}
+}
+
+func TestRounding(t *testing.T) {
+	config := &Config{Stocks: []Stock{{Symbol: "VTI", TargetPercentage: 50}, {Symbol: "BND", TargetPercentage: 50}}}
+	tests := []struct {
+		mode string
+		// deposit is the split of $0.05 and shares the rounding of -2.5 shares
+		deposit, shares int
+	}{
+		{"", 2, -2},
+		{"floor", 2, -3},
+		{"ceil", 3, -2},
+		{"half-even", 2, -2},
+		{"half-up", 3, -3},
+	}
+	for _, tt := range tests {
+		config.Rounding = tt.mode
+		if err := config.validate(); err != nil {
+			t.Fatalf("%q: validate failed: %v", tt.mode, err)
+		}
+		if actual := depositCalc(config, 5).Allocations["VTI"]; actual != tt.deposit {
+			t.Errorf("%q: deposit split got %d, expected %d", tt.mode, actual, tt.deposit)
+		}
+		if actual := config.round(-2.5, "truncate"); actual != tt.shares {
+			t.Errorf("%q: share count got %d, expected %d", tt.mode, actual, tt.shares)
+		}
+	}
+
+	config.Rounding = "bankers"
+	if err := config.validate(); err == nil {
+		t.Error("Expected error for unknown rounding mode")
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"html"
+	"io"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// version is set when building a release, with
+// -ldflags "-X main.version=v1.2.3"; otherwise it comes from the build info
+var version string
+
+// Manifest records what produced a report, so that an archived copy can be
+// traced back to the exact tool, config, and inputs
+type Manifest struct {
+	Tool    string `json:"tool"`
+	Version string `json:"version"`
+	Command string `json:"command"`
+	// Args is the whole command line after the program name, global flags
+	// included
+	Args []string  `json:"args"`
+	Time time.Time `json:"time"`
+	// Configs and Inputs map each config and each file named on the command
+	// line to a checksum of its contents
+	Configs map[string]string `json:"configs"`
+	Inputs  map[string]string `json:"inputs,omitempty"`
+}
+
+// runManifest is the manifest of this run, set by main() before the command
+// runs. Tests leave it nil, so their outputs have none.
+var runManifest *Manifest
+
+// toolVersion is the release version, or else the module version or VCS
+// revision the binary was built from
+func toolVersion() string {
+	if version != "" {
+		return version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	var revision, dirty string
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value[:min(len(setting.Value), 12)]
+		case "vcs.modified":
+			if setting.Value == "true" {
+				dirty = "-dirty"
+			}
+		}
+	}
+	if revision == "" {
+		return "devel"
+	}
+	return "devel-" + revision + dirty
+}
+
+func newManifest(command string, configPaths, args []string) *Manifest {
+	manifest := &Manifest{
+		Tool:    "fin-tilt",
+		Version: toolVersion(),
+		Command: command,
+		Args:    os.Args[1:],
+		Time:    time.Now(),
+		Configs: make(map[string]string),
+		Inputs:  manifestInputs(args),
+	}
+	for _, path := range configPaths {
+		if hash, err := exportHash(path); err == nil {
+			manifest.Configs[path] = hash
+		}
+	}
+	return manifest
+}
+
+// manifestInputs checksums the files named in a command's args, as
+// arguments or flag values. Files are hashed before the command runs, so
+// the file written by -o is skipped, and paths that aren't files are too.
+func manifestInputs(args []string) map[string]string {
+	inputs := make(map[string]string)
+	for i, arg := range args {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if strings.HasPrefix(arg, "-") {
+			if !hasValue || name == "o" {
+				continue
+			}
+			arg = value
+		} else if i > 0 && strings.TrimLeft(args[i-1], "-") == "o" {
+			continue
+		}
+		if info, err := os.Stat(portfolioPaths(arg)[0]); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if hash, err := exportHash(arg); err == nil && hash != "" {
+			inputs[arg] = hash
+		}
+	}
+	return inputs
+}
+
+// writeJSON writes value to w as indented JSON. An object gets the run's
+// manifest as its first field; a list keeps its shape, so it has none.
+func writeJSON(w io.Writer, value any) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	if runManifest != nil && len(data) > 1 && data[0] == '{' {
+		manifest, err := json.MarshalIndent(runManifest, "  ", "  ")
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		buf.WriteString("{\n  \"manifest\": ")
+		buf.Write(manifest)
+		if string(data) == "{}" {
+			buf.WriteString("\n}")
+		} else {
+			buf.WriteString(",")
+			buf.Write(data[1:])
+		}
+		data = buf.Bytes()
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// writeSVGManifest records the run's manifest in an SVG's metadata element
+func writeSVGManifest(w io.Writer) {
+	if runManifest == nil {
+		return
+	}
+	data, err := json.Marshal(runManifest)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "<metadata>%s</metadata>\n", html.EscapeString(string(data)))
+}
+
+// writeHTMLManifest records the run's manifest in a meta element of an HTML
+// document's head
+func writeHTMLManifest(w io.Writer) {
+	if runManifest == nil {
+		return
+	}
+	data, err := json.Marshal(runManifest)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "<meta name=\"fin-tilt-manifest\" content=\"%s\">\n", html.EscapeString(string(data)))
+}
+
+// pngManifest adds the run's manifest to an encoded PNG as an iTXt chunk
+// keyed fin-tilt, right after the image header
+func pngManifest(image []byte) []byte {
+	// The 8-byte signature, then the 25-byte IHDR chunk
+	const headerEnd = 8 + 25
+	if runManifest == nil || len(image) < headerEnd {
+		return image
+	}
+	text, err := json.Marshal(runManifest)
+	if err != nil {
+		return image
+	}
+	// Keyword, then no compression and empty language and translated keyword
+	data := append([]byte("fin-tilt\x00\x00\x00\x00\x00"), text...)
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, "iTXt"...)
+	chunk = append(chunk, data...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+	return append(append(image[:headerEnd:headerEnd], chunk...), image[headerEnd:]...)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/png"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManifestInputs(t *testing.T) {
+	portfolio := filepath.Join("tests", "portfolios", "cash.csv")
+	config := filepath.Join("tests", "configs", "simple.yaml")
+	inputs := manifestInputs([]string{portfolio, "-o", config, "-plan=" + portfolio, "-format", "json", "missing.csv"})
+	if len(inputs) != 1 || len(inputs[portfolio]) != 16 {
+		t.Errorf("Expected only the portfolio hashed, the -o file and other values skipped, got %v", inputs)
+	}
+	hash, err := exportHash(portfolio)
+	if err != nil {
+		t.Fatalf("exportHash failed: %v", err)
+	}
+	if inputs[portfolio] != hash {
+		t.Errorf("Expected the export's hash %s, got %s", hash, inputs[portfolio])
+	}
+
+	manifest := newManifest("rebalance", []string{config, "missing.yaml"}, []string{portfolio})
+	if manifest.Tool != "fin-tilt" || manifest.Version == "" || manifest.Command != "rebalance" {
+		t.Errorf("Unexpected manifest %+v", manifest)
+	}
+	if len(manifest.Configs) != 1 || manifest.Configs[config] == "" {
+		t.Errorf("Expected the config that exists to be hashed, got %v", manifest.Configs)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	saved := runManifest
+	t.Cleanup(func() { runManifest = saved })
+	runManifest = nil
+
+	var buf bytes.Buffer
+	value := map[string]int{"total": 100}
+	if err := writeJSON(&buf, value); err != nil {
+		t.Fatalf("writeJSON failed: %v", err)
+	}
+	if buf.String() != "{\n  \"total\": 100\n}\n" {
+		t.Errorf("Expected plain indented JSON without a manifest, got %q", buf.String())
+	}
+
+	runManifest = &Manifest{Tool: "fin-tilt", Version: "v1.0.0", Command: "rebalance", Args: []string{"rebalance", "p.csv"}, Time: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), Configs: map[string]string{"config.yaml": "abc"}}
+	for _, value := range []any{value, struct{}{}} {
+		buf.Reset()
+		if err := writeJSON(&buf, value); err != nil {
+			t.Fatalf("writeJSON failed: %v", err)
+		}
+		var decoded struct {
+			Manifest Manifest `json:"manifest"`
+			Total    int      `json:"total"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+			t.Fatalf("Expected valid JSON, got %v:\n%s", err, buf.String())
+		}
+		if decoded.Manifest.Version != "v1.0.0" || decoded.Manifest.Configs["config.yaml"] != "abc" {
+			t.Errorf("Expected the manifest in the output, got %+v", decoded.Manifest)
+		}
+		if !strings.HasPrefix(buf.String(), "{\n  \"manifest\": {\n    \"tool\": \"fin-tilt\",") {
+			t.Errorf("Expected the manifest first and indented, got:\n%s", buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := writeJSON(&buf, []int{1, 2}); err != nil {
+		t.Fatalf("writeJSON failed: %v", err)
+	}
+	if strings.Contains(buf.String(), "manifest") {
+		t.Errorf("Expected a list to keep its shape, got %s", buf.String())
+	}
+
+	var svg bytes.Buffer
+	writeSVGManifest(&svg)
+	if !strings.HasPrefix(svg.String(), "<metadata>{&#34;tool&#34;:&#34;fin-tilt&#34;") {
+		t.Errorf("Expected the manifest in an SVG metadata element, got %s", svg.String())
+	}
+
+	var image bytes.Buffer
+	if err := writeChartPNG(&image, []ChartSlice{{Symbol: "VTI", Current: 100, Target: 100, Color: chartColors[0]}}); err != nil {
+		t.Fatalf("writeChartPNG failed: %v", err)
+	}
+	if !bytes.Contains(image.Bytes(), []byte("iTXtfin-tilt\x00")) || !bytes.Contains(image.Bytes(), []byte(`"version":"v1.0.0"`)) {
+		t.Error("Expected the manifest in an iTXt chunk")
+	}
+	if _, err := png.Decode(&image); err != nil {
+		t.Errorf("Expected the PNG with a manifest to decode, got %v", err)
+	}
+}
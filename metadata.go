@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SymbolMetadata is what the metadata plugin knows about a security
+type SymbolMetadata struct {
+	Name string `json:"name,omitempty"`
+	// Category is a style-box category such as "Large Blend", which fills in
+	// the stock's category for tilt when it's one tilt knows
+	Category string `json:"category,omitempty"`
+	// AssetClass fills in the stock's class when the config has none
+	AssetClass string `json:"asset_class,omitempty"`
+}
+
+// enrichStocks asks the metadata plugin, if there is one, about every
+// configured stock and fills in the name, class, and category the config
+// leaves out. Settings in the config always win.
+func enrichStocks(ctx context.Context, config *Config) error {
+	name := config.pluginFor("metadata")
+	if name == "" {
+		return nil
+	}
+	var symbols []string
+	for _, stock := range config.Stocks {
+		symbols = append(symbols, stock.Symbol)
+	}
+	response, err := runPlugin(ctx, config, name, PluginRequest{Type: "metadata", Symbols: symbols})
+	if err != nil {
+		return err
+	}
+	for i := range config.Stocks {
+		stock := &config.Stocks[i]
+		metadata, ok := response.Metadata[stock.Symbol]
+		if !ok {
+			continue
+		}
+		stock.Name = metadata.Name
+		if stock.Class == "" {
+			stock.Class = metadata.AssetClass
+		}
+		category := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(metadata.Category)), " ", "-")
+		if _, known := categoryLoadings[category]; stock.Category == "" && len(stock.Factors) == 0 && known {
+			stock.Category = category
+		}
+	}
+	return nil
+}
+
+// label describes the stock for reports: its security name from the
+// metadata plugin, or else its description, followed by its class
+func (s *Stock) label() string {
+	label := s.Description
+	if s.Name != "" {
+		label = s.Name
+	}
+	if s.Class != "" {
+		label += " (" + s.Class + ")"
+	}
+	return label
+}
+
+// ClassWeight is the share of the portfolio in one asset class
+type ClassWeight struct {
+	Class             string   `json:"class"`
+	Symbols           []string `json:"symbols"`
+	Amount            int      `json:"amount"`
+	CurrentPercentage float64  `json:"current_percentage"`
+	TargetPercentage  float64  `json:"target_percentage"`
+	Drift             float64  `json:"drift"`
+}
+
+// unclassified groups the stocks without a class when some others have one
+const unclassified = "Unclassified"
+
+// classCalc sums the rebalance result by class, in the order the config
+// first lists each. It's empty when no stock has a class.
+func classCalc(config *Config, result *RebalanceResult) []ClassWeight {
+	var classes []ClassWeight
+	index := make(map[string]int)
+	classified := false
+	for _, stock := range config.Stocks {
+		class := stock.Class
+		if class == "" {
+			class = unclassified
+		} else {
+			classified = true
+		}
+		i, ok := index[class]
+		if !ok {
+			i = len(classes)
+			index[class] = i
+			classes = append(classes, ClassWeight{Class: class})
+		}
+		data := result.Symbols[stock.Symbol]
+		classes[i].Symbols = append(classes[i].Symbols, stock.Symbol)
+		classes[i].Amount += data.Amount
+		classes[i].CurrentPercentage += data.CurrentPercentage
+		classes[i].TargetPercentage += data.TargetPercentage
+	}
+	if !classified {
+		return nil
+	}
+	for i := range classes {
+		classes[i].Drift = classes[i].CurrentPercentage - classes[i].TargetPercentage
+	}
+	return classes
+}
+
+func printClasses(config *Config, result *RebalanceResult) {
+	classes := classCalc(config, result)
+	if len(classes) == 0 {
+		return
+	}
+	fmt.Println("\nBy class:")
+	for _, c := range classes {
+		fmt.Printf("  %s: %.2f%% of %.2f%% target (%s), %s\n", c.Class, c.CurrentPercentage, c.TargetPercentage, driftText(c.Drift), strings.Join(c.Symbols, ", "))
+	}
+}
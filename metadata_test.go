@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnrichStocks(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	config.Stocks[1].Class = "International"
+	config.Plugins = map[string]PluginConfig{
+		"morningstar": {Command: `cat > /dev/null && printf '%s' '{"metadata": {
+			"VTI": {"name": "Vanguard Total Stock Market ETF", "category": "Large Blend", "asset_class": "US Equity"},
+			"VXUS": {"name": "Vanguard Total International Stock ETF", "category": "Foreign Large Blend", "asset_class": "International Equity"},
+			"BND": {"asset_class": "Bonds"}}}'`, Provides: []string{"metadata"}},
+	}
+	if err := validatePlugins(config); err != nil {
+		t.Fatalf("validatePlugins failed: %v", err)
+	}
+	if err := enrichStocks(context.Background(), config); err != nil {
+		t.Fatalf("enrichStocks failed: %v", err)
+	}
+
+	vti, vxus, bnd := config.Stocks[0], config.Stocks[1], config.Stocks[2]
+	if label := vti.label(); label != "Vanguard Total Stock Market ETF (US Equity)" {
+		t.Errorf("Expected VTI labeled with its name and class, got %q", label)
+	}
+	if vti.Category != "large-blend" {
+		t.Errorf("Expected VTI's category filled in for tilt, got %q", vti.Category)
+	}
+	// The config's class wins, and a category tilt doesn't know is left out
+	if vxus.Class != "International" || vxus.Category != "" {
+		t.Errorf("Expected VXUS to keep its class without a category, got %q and %q", vxus.Class, vxus.Category)
+	}
+	if label := bnd.label(); label != "Vanguard Total Bond Market ETF (Bonds)" {
+		t.Errorf("Expected BND labeled with its description and class, got %q", label)
+	}
+}
+
+func TestClassCalc(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	holdings := loadHoldings(t, config, "cash.csv")
+	result, err := allocationCalc(config, holdings, 0)
+	if err != nil {
+		t.Fatalf("allocationCalc failed: %v", err)
+	}
+	if classes := classCalc(config, result); classes != nil {
+		t.Errorf("Expected no classes without any configured, got %+v", classes)
+	}
+
+	config.Stocks[0].Class = "Equity"
+	config.Stocks[1].Class = "Equity"
+	classes := classCalc(config, result)
+	if len(classes) != 2 || classes[0].Class != "Equity" || classes[1].Class != unclassified {
+		t.Fatalf("Expected Equity and unclassified, got %+v", classes)
+	}
+	if classes[0].Amount != 8900000 || classes[0].TargetPercentage != 89 || len(classes[0].Symbols) != 2 {
+		t.Errorf("Expected Equity to hold VTI and VXUS, 8900000 against an 89%% target, got %+v", classes[0])
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// httpClient makes every call to a network provider (price history, Google
+// Sheets). Its timeout bounds each call, including reading the response, and
+// is set by the -timeout flag.
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// offline forbids network calls: commands use cached data instead, or fail
+// if it isn't cached. Set by the -offline flag.
+var offline bool
+
+var errOffline = &CodedError{Code: CodeOffline, Err: errors.New("network access is disabled by -offline")}
+
+// offlineTransport refuses every request, so that -offline holds even for a
+// call that forgets to check it
+type offlineTransport struct{}
+
+func (offlineTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errOffline
+}
+
+// shutdownTimeout is how long serve waits for in-flight requests on SIGINT
+const shutdownTimeout = 5 * time.Second
+
+// networkErrorCode classifies an interrupted or timed-out network call
+func networkErrorCode(err error) (string, bool) {
+	if errors.Is(err, context.Canceled) {
+		return CodeCanceled, true
+	}
+	if netErr := net.Error(nil); errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return CodeTimeout, true
+	}
+	return "", false
+}
+
+// Limits for fetching one resource per symbol. They are variables so tests
+// can run without waiting.
+var (
+	// fetchWorkers is how many requests are in flight at once
+	fetchWorkers = 4
+	// fetchInterval is the minimum time between starting two requests, which
+	// keeps free price APIs from throttling a long config
+	fetchInterval = 200 * time.Millisecond
+	// fetchAttempts is how many times a transient failure is tried in all
+	fetchAttempts = 3
+	// fetchBackoff is the wait before the first retry, doubling after each
+	fetchBackoff = 500 * time.Millisecond
+)
+
+// networkProviders are the services the network config can set limits for
+var networkProviders = []string{"stooq", "tiingo", "sheets", "ecb", "exchangerate.host"}
+
+// NetworkPolicy overrides how calls to one provider are paced and retried,
+// e.g. to stay under a free tier's quota
+type NetworkPolicy struct {
+	// RequestsPerMinute caps how often calls start (default 300)
+	RequestsPerMinute float64 `yaml:"requests_per_minute,omitempty"`
+	// MaxRetries is how many times a transient failure is retried (default 2)
+	MaxRetries *int `yaml:"max_retries,omitempty"`
+	// Backoff is the wait before the first retry, doubling after each (default 500ms)
+	Backoff *Age `yaml:"backoff,omitempty"`
+}
+
+func validateNetwork(config *Config) error {
+	for provider, policy := range config.Network {
+		if !slices.Contains(networkProviders, provider) {
+			return fmt.Errorf("unknown network provider %q (expected one of %s)", provider, strings.Join(networkProviders, ", "))
+		}
+		if policy.RequestsPerMinute < 0 || (policy.MaxRetries != nil && *policy.MaxRetries < 0) {
+			return fmt.Errorf("network.%s limits must not be negative", provider)
+		}
+	}
+	return nil
+}
+
+// fetchPolicy is how calls to one provider are paced and retried
+type fetchPolicy struct {
+	interval time.Duration
+	attempts int
+	backoff  time.Duration
+}
+
+// fetchPolicy returns the limits for provider: the defaults, overridden by
+// its network config
+func (c *Config) fetchPolicy(provider string) fetchPolicy {
+	policy := fetchPolicy{interval: fetchInterval, attempts: fetchAttempts, backoff: fetchBackoff}
+	override := c.Network[provider]
+	if override.RequestsPerMinute > 0 {
+		policy.interval = time.Duration(float64(time.Minute) / override.RequestsPerMinute)
+	}
+	if override.MaxRetries != nil {
+		policy.attempts = *override.MaxRetries + 1
+	}
+	if override.Backoff != nil {
+		policy.backoff = time.Duration(*override.Backoff)
+	}
+	return policy
+}
+
+// pacer returns a wait function that returns at most once per interval,
+// the first time immediately, and a function that releases its ticker
+func (p fetchPolicy) pacer(ctx context.Context) (wait func() error, stop func()) {
+	ticker := time.NewTicker(max(p.interval, time.Nanosecond))
+	start := make(chan struct{}, 1)
+	start <- struct{}{}
+	wait = func() error {
+		select {
+		case <-start:
+		case <-ticker.C:
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+		return nil
+	}
+	return wait, ticker.Stop
+}
+
+// httpStatusError is a response with an unsuccessful status code
+type httpStatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *httpStatusError) Error() string { return e.Status }
+
+// retryable reports whether a failed call may succeed if tried again: the
+// server was overloaded or the call timed out without being canceled
+func retryable(err error) bool {
+	if statusErr := (*httpStatusError)(nil); errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	code, ok := networkErrorCode(err)
+	return ok && code == CodeTimeout
+}
+
+// fetchAll calls fetch for every key with at most fetchWorkers at a time,
+// starting at most one call per policy interval and retrying transient
+// failures with exponential backoff. The first error cancels the calls still
+// pending.
+func fetchAll[T any](ctx context.Context, policy fetchPolicy, keys []string, fetch func(ctx context.Context, key string) (T, error)) (map[string]T, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	wait, stop := policy.pacer(ctx)
+	defer stop()
+
+	var mu sync.Mutex
+	results := make(map[string]T, len(keys))
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	for range min(fetchWorkers, len(keys)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range queue {
+				value, err := fetchWithRetry(ctx, policy, key, wait, fetch)
+				if err != nil {
+					cancel(fmt.Errorf("%s: %w", key, err))
+					continue
+				}
+				mu.Lock()
+				results[key] = value
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, key := range keys {
+		select {
+		case queue <- key:
+		case <-ctx.Done():
+		}
+	}
+	close(queue)
+	wg.Wait()
+	if err := context.Cause(ctx); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func fetchWithRetry[T any](ctx context.Context, policy fetchPolicy, key string, wait func() error, fetch func(ctx context.Context, key string) (T, error)) (T, error) {
+	var zero T
+	backoff := policy.backoff
+	for attempt := 1; ; attempt++ {
+		if err := wait(); err != nil {
+			return zero, err
+		}
+		value, err := fetch(ctx, key)
+		if err == nil || attempt >= policy.attempts || !retryable(err) {
+			return value, err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return zero, context.Cause(ctx)
+		}
+		backoff *= 2
+	}
+}
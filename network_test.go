@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func fastFetchLimits(t *testing.T) {
+	t.Helper()
+	workers, interval, backoff := fetchWorkers, fetchInterval, fetchBackoff
+	t.Cleanup(func() { fetchWorkers, fetchInterval, fetchBackoff = workers, interval, backoff })
+	fetchWorkers, fetchInterval, fetchBackoff = 3, time.Millisecond, time.Millisecond
+}
+
+func TestFetchAll(t *testing.T) {
+	fastFetchLimits(t)
+	var symbols []string
+	for i := range 12 {
+		symbols = append(symbols, fmt.Sprintf("SYM%d", i))
+	}
+
+	var mu sync.Mutex
+	var inFlight, maxInFlight int
+	attempts := make(map[string]int)
+	results, err := fetchAll(context.Background(), (&Config{}).fetchPolicy("stooq"), symbols, func(ctx context.Context, symbol string) (string, error) {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		attempts[symbol]++
+		attempt := attempts[symbol]
+		mu.Unlock()
+		time.Sleep(2 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		// Every other symbol is throttled once before succeeding
+		if symbol[len(symbol)-1]%2 == 0 && attempt == 1 {
+			return "", &httpStatusError{StatusCode: http.StatusTooManyRequests, Status: "429 Too Many Requests"}
+		}
+		return "price of " + symbol, nil
+	})
+	if err != nil {
+		t.Fatalf("fetchAll failed: %v", err)
+	}
+	if len(results) != len(symbols) || results["SYM4"] != "price of SYM4" {
+		t.Errorf("Results mismatch: got %v", results)
+	}
+	if maxInFlight > fetchWorkers {
+		t.Errorf("Expected at most %d requests in flight, got %d", fetchWorkers, maxInFlight)
+	}
+	if attempts["SYM4"] != 2 || attempts["SYM5"] != 1 {
+		t.Errorf("Attempts mismatch: got %v", attempts)
+	}
+}
+
+func TestFetchAllStopsOnPermanentError(t *testing.T) {
+	fastFetchLimits(t)
+	var calls atomic.Int32
+	_, err := fetchAll(context.Background(), (&Config{}).fetchPolicy("stooq"), []string{"VTI", "NOPE", "BND"}, func(ctx context.Context, symbol string) (int, error) {
+		calls.Add(1)
+		if symbol == "NOPE" {
+			return 0, &httpStatusError{StatusCode: http.StatusNotFound, Status: "404 Not Found"}
+		}
+		return 1, nil
+	})
+	if err == nil || err.Error() != "NOPE: 404 Not Found" {
+		t.Errorf("Expected NOPE's 404, got %v", err)
+	}
+	if calls.Load() > 3 {
+		t.Errorf("Expected no retries of a 404, got %d calls", calls.Load())
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{&httpStatusError{StatusCode: 503}, true},
+		{&httpStatusError{StatusCode: 429}, true},
+		{&httpStatusError{StatusCode: 404}, false},
+		{context.DeadlineExceeded, true},
+		{context.Canceled, false},
+		{errors.New("no price data"), false},
+	}
+	for _, tt := range tests {
+		if actual := retryable(tt.err); actual != tt.expected {
+			t.Errorf("retryable(%v) = %v, expected %v", tt.err, actual, tt.expected)
+		}
+	}
+}
+
+func TestFetchPolicy(t *testing.T) {
+	retries := 0
+	backoff := Age(2 * time.Second)
+	config := &Config{Network: map[string]NetworkPolicy{
+		"tiingo": {RequestsPerMinute: 30, MaxRetries: &retries, Backoff: &backoff},
+	}}
+	if err := validateNetwork(config); err != nil {
+		t.Fatalf("validateNetwork failed: %v", err)
+	}
+
+	policy := config.fetchPolicy("tiingo")
+	if policy.interval != 2*time.Second || policy.attempts != 1 || policy.backoff != 2*time.Second {
+		t.Errorf("Tiingo policy mismatch: %+v", policy)
+	}
+	if policy := config.fetchPolicy("stooq"); policy.interval != fetchInterval || policy.attempts != fetchAttempts || policy.backoff != fetchBackoff {
+		t.Errorf("Expected the defaults for stooq, got %+v", policy)
+	}
+
+	// Without retries, a throttled call fails on its first attempt
+	fastFetchLimits(t)
+	var calls atomic.Int32
+	_, err := fetchAll(context.Background(), fetchPolicy{interval: time.Millisecond, attempts: 1}, []string{"VTI"}, func(ctx context.Context, symbol string) (int, error) {
+		calls.Add(1)
+		return 0, &httpStatusError{StatusCode: http.StatusTooManyRequests, Status: "429 Too Many Requests"}
+	})
+	if err == nil || calls.Load() != 1 {
+		t.Errorf("Expected one failed call, got %d (%v)", calls.Load(), err)
+	}
+
+	for _, network := range []map[string]NetworkPolicy{{"yahoo": {}}, {"stooq": {RequestsPerMinute: -1}}} {
+		if err := validateNetwork(&Config{Network: network}); err == nil {
+			t.Errorf("Expected %v to be invalid", network)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// NetWorth is the net worth at one snapshot
+type NetWorth struct {
+	AsOf        time.Time      `json:"as_of"`
+	Assets      int            `json:"assets"`
+	Liabilities int            `json:"liabilities"`
+	NetWorth    int            `json:"net_worth"`
+	Owed        map[string]int `json:"owed,omitempty"`
+}
+
+// netWorthCalc subtracts each snapshot's liabilities from its total. Snapshots
+// recorded before any liability was declared have none.
+func netWorthCalc(snapshots []Snapshot) []NetWorth {
+	points := make([]NetWorth, 0, len(snapshots))
+	for _, s := range snapshots {
+		point := NetWorth{AsOf: s.AsOf, Assets: s.Total, Owed: s.Liabilities}
+		for _, amount := range s.Liabilities {
+			point.Liabilities += amount
+		}
+		point.NetWorth = point.Assets - point.Liabilities
+		points = append(points, point)
+	}
+	return points
+}
+
+func networth(config *Config, args []string) {
+	var sinceStr, format string
+	flagSet := flag.NewFlagSet("networth", flag.ExitOnError)
+	flagSet.StringVar(&sinceStr, "since", "", "Only show snapshots on or after this date (YYYY-MM-DD)")
+	flagSet.StringVar(&format, "format", "text", "Output format: text or json")
+	var portfolioCsv string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		portfolioCsv = args[0]
+		args = args[1:]
+	}
+	flagSet.Parse(args)
+	if format != "text" && format != "json" {
+		printError(codedErrorf(CodeUsage, "unknown format %q", format))
+		return
+	}
+	var since time.Time
+	if sinceStr != "" {
+		var err error
+		if since, err = time.Parse(time.DateOnly, sinceStr); err != nil {
+			printError(codedErrorf(CodeUsage, "parsing since date: %w", err))
+			return
+		}
+	}
+
+	var snapshots []Snapshot
+	if config.Snapshots != "" {
+		var err error
+		if snapshots, err = readSnapshots(config.Snapshots); err != nil {
+			printError(fmt.Errorf("reading snapshots: %w", err))
+			return
+		}
+	}
+	if portfolioCsv != "" {
+		holdings, err := loadPortfolio(config, portfolioCsv)
+		if err != nil {
+			printError(err)
+			return
+		}
+		if len(snapshots) == 0 || holdings.AsOf.After(snapshots[len(snapshots)-1].AsOf) {
+			snapshots = append(snapshots, *newSnapshot(holdings))
+		}
+	}
+	if len(snapshots) == 0 {
+		printError(codedErrorf(CodeUsage, "no snapshots to report on; record exports with the snapshot command or pass the current portfolio CSV"))
+		return
+	}
+	points := []NetWorth{}
+	for _, point := range netWorthCalc(snapshots) {
+		if !point.AsOf.Before(since) {
+			points = append(points, point)
+		}
+	}
+
+	if format == "json" {
+		if err := writeJSON(os.Stdout, points); err != nil {
+			printError(err)
+		}
+		return
+	}
+	if len(points) == 0 {
+		fmt.Println("No snapshots recorded")
+		return
+	}
+	fmt.Printf("%-10s  %15s  %15s  %15s  %s\n", "Date", "Assets", "Liabilities", "Net worth", "Change")
+	fmt.Println(rule())
+	for i, point := range points {
+		line := fmt.Sprintf("%-10s  %15s  %15s  %15s", point.AsOf.Format(time.DateOnly), formatAmount(point.Assets, true), formatAmount(point.Liabilities, true), formatAmount(point.NetWorth, true))
+		if i > 0 {
+			line += "  " + changeText(point.NetWorth-points[i-1].NetWorth)
+		}
+		fmt.Println(line)
+	}
+
+	last := points[len(points)-1]
+	if len(last.Owed) > 0 {
+		names := make([]string, 0, len(last.Owed))
+		for name := range last.Owed {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Printf("\nOwed as of %s:\n", last.AsOf.Format(time.DateOnly))
+		for _, name := range names {
+			fmt.Printf("  %s: %s\n", name, formatAmount(last.Owed[name], true))
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLiabilities(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	config.StaticPositions = []StaticPosition{{Symbol: "MORTGAGE", Value: 30000000, Account: "Chase", Liability: true}}
+	config.StaticPositionsFile = filepath.Join(t.TempDir(), "static.csv")
+	if err := os.WriteFile(config.StaticPositionsFile, []byte("Symbol,Value,Account,Updated,Liability\nCAR,\"$12,000.00\",,,true\nBND,$500.00,,,\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := validateStaticPositions(config); err != nil {
+		t.Fatalf("Expected a liability not to need a configured symbol, got %v", err)
+	}
+	holdings := loadHoldings(t, config, "cash.csv")
+	total := newSnapshot(holdings).Total
+	if err := addStaticPositions(config, holdings); err != nil {
+		t.Fatalf("addStaticPositions failed: %v", err)
+	}
+
+	// Liabilities stay out of the portfolio
+	snapshot := newSnapshot(holdings)
+	if snapshot.Total != total+50000 {
+		t.Errorf("Expected only BND added to the total, got %d from %d", snapshot.Total, total)
+	}
+	if snapshot.Liabilities["MORTGAGE (Chase)"] != 30000000 || snapshot.Liabilities["CAR"] != 1200000 {
+		t.Errorf("Liabilities mismatch: got %v", snapshot.Liabilities)
+	}
+
+	// They're kept with each snapshot, in either format
+	for _, name := range []string{"snapshots.jsonl", "snapshots.csv"} {
+		path := filepath.Join(t.TempDir(), name)
+		snapshot.AsOf = time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)
+		if _, err := appendSnapshot(path, snapshot); err != nil {
+			t.Fatalf("appendSnapshot failed: %v", err)
+		}
+		read, err := readSnapshots(path)
+		if err != nil || len(read) != 1 || read[0].Liabilities["CAR"] != 1200000 || read[0].Total != snapshot.Total {
+			t.Errorf("%s: snapshot mismatch: got %+v (%v)", name, read, err)
+		}
+	}
+}
+
+func TestNetWorthCalc(t *testing.T) {
+	snapshots := []Snapshot{
+		{AsOf: time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC), Total: 50000000},
+		{AsOf: time.Date(2026, time.July, 1, 0, 0, 0, 0, time.UTC), Total: 55000000, Liabilities: map[string]int{"MORTGAGE": 30000000, "CAR": 1000000}},
+	}
+	points := netWorthCalc(snapshots)
+	if len(points) != 2 || points[0].NetWorth != 50000000 || points[0].Liabilities != 0 {
+		t.Fatalf("Expected a snapshot without liabilities to owe nothing, got %+v", points)
+	}
+	if points[1].Liabilities != 31000000 || points[1].NetWorth != 24000000 {
+		t.Errorf("Net worth mismatch: got %+v", points[1])
+	}
+}
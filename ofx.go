@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+)
+
+// writeQIF writes the trades as QIF investment transactions, under an
+// account header for each brokerage account. Quicken and GnuCash import each
+// one as a Buy or Sell against the account's cash.
+func writeQIF(out io.Writer, plan *ExportPlan) error {
+	w := bufio.NewWriter(out)
+	for _, account := range tradeAccounts(plan.Trades) {
+		if account != "" {
+			fmt.Fprintf(w, "!Account\nN%s\nTInvst\n^\n", account)
+		}
+		fmt.Fprintln(w, "!Type:Invst")
+		for _, trade := range plan.Trades {
+			if trade.Account != account {
+				continue
+			}
+			action := "Buy"
+			if trade.Amount < 0 {
+				action = "Sell"
+			}
+			fmt.Fprintf(w, "D%s\n", plan.TradeDate.Format("01/02/2006"))
+			fmt.Fprintf(w, "N%s\n", action)
+			fmt.Fprintf(w, "Y%s\n", trade.Symbol)
+			if trade.Quantity != 0 {
+				fmt.Fprintf(w, "I%s\n", formatDecimal(trade.Price))
+				fmt.Fprintf(w, "Q%s\n", formatQuantity(math.Abs(trade.Quantity)))
+			}
+			fmt.Fprintf(w, "T%s\n", formatDecimal(abs(trade.Amount)))
+			fmt.Fprintln(w, "MRebalance (fin-tilt)")
+			fmt.Fprintln(w, "^")
+		}
+	}
+	return w.Flush()
+}
+
+// writeOFX writes the trades as an OFX 1.0.2 investment statement for each
+// brokerage account, with one BUYSTOCK or SELLSTOCK per trade. OFX requires
+// share counts, so trades without a price are left out.
+func writeOFX(out io.Writer, plan *ExportPlan) error {
+	w := bufio.NewWriter(out)
+	now := plan.TradeDate.UTC().Format("20060102150405")
+	fmt.Fprint(w, "OFXHEADER:100\nDATA:OFXSGML\nVERSION:102\nSECURITY:NONE\nENCODING:USASCII\nCHARSET:1252\nCOMPRESSION:NONE\nOLDFILEUID:NONE\nNEWFILEUID:NONE\n\n")
+	fmt.Fprintln(w, "<OFX>")
+	fmt.Fprintf(w, "<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0<SEVERITY>INFO</STATUS><DTSERVER>%s<LANGUAGE>ENG</SONRS></SIGNONMSGSRSV1>\n", now)
+	fmt.Fprintln(w, "<INVSTMTMSGSRSV1>")
+
+	var securities []string
+	seen := make(map[string]bool)
+	for n, account := range tradeAccounts(plan.Trades) {
+		acctID := account
+		if acctID == "" {
+			acctID = "fin-tilt"
+		}
+		fmt.Fprintf(w, "<INVSTMTTRNRS><TRNUID>%d<STATUS><CODE>0<SEVERITY>INFO</STATUS>\n", n)
+		fmt.Fprintf(w, "<INVSTMTRS><DTASOF>%s<CURDEF>USD<INVACCTFROM><BROKERID>fin-tilt<ACCTID>%s</INVACCTFROM>\n", now, ofxEscape(acctID))
+		fmt.Fprintf(w, "<INVTRANLIST><DTSTART>%s<DTEND>%s\n", now, now)
+		for i, trade := range plan.Trades {
+			if trade.Account != account || trade.Quantity == 0 {
+				continue
+			}
+			if !seen[trade.Symbol] {
+				seen[trade.Symbol] = true
+				securities = append(securities, trade.Symbol)
+			}
+			buy := trade.Amount > 0
+			tag, inner := "SELLSTOCK", "INVSELL"
+			if buy {
+				tag, inner = "BUYSTOCK", "INVBUY"
+			}
+			fmt.Fprintf(w, "<%s><%s><INVTRAN><FITID>fin-tilt-%s-%d<DTTRADE>%s<MEMO>Rebalance</INVTRAN>\n", tag, inner, plan.TradeDate.Format("20060102"), i+1, now)
+			fmt.Fprintf(w, "<SECID><UNIQUEID>%s<UNIQUEIDTYPE>TICKER</SECID>\n", ofxEscape(trade.Symbol))
+			// Purchases have positive units and a negative total; sales the reverse
+			fmt.Fprintf(w, "<UNITS>%s<UNITPRICE>%s<TOTAL>%s<SUBACCTSEC>CASH<SUBACCTFUND>CASH</%s>\n", formatQuantity(trade.Quantity), formatDecimal(trade.Price), formatDecimal(-trade.Amount), inner)
+			if buy {
+				fmt.Fprintln(w, "<BUYTYPE>BUY</BUYSTOCK>")
+			} else {
+				fmt.Fprintln(w, "<SELLTYPE>SELL</SELLSTOCK>")
+			}
+		}
+		fmt.Fprintln(w, "</INVTRANLIST></INVSTMTRS></INVSTMTTRNRS>")
+	}
+	fmt.Fprintln(w, "</INVSTMTMSGSRSV1>")
+
+	fmt.Fprintln(w, "<SECLISTMSGSRSV1><SECLIST>")
+	for _, symbol := range securities {
+		fmt.Fprintf(w, "<STOCKINFO><SECINFO><SECID><UNIQUEID>%s<UNIQUEIDTYPE>TICKER</SECID><SECNAME>%s<TICKER>%s</SECINFO></STOCKINFO>\n", ofxEscape(symbol), ofxEscape(symbol), ofxEscape(symbol))
+	}
+	fmt.Fprintln(w, "</SECLIST></SECLISTMSGSRSV1>")
+	fmt.Fprintln(w, "</OFX>")
+	return w.Flush()
+}
+
+// tradeAccounts lists the accounts trades are placed in, in order of first trade
+func tradeAccounts(trades []ExportTrade) []string {
+	var accounts []string
+	for _, trade := range trades {
+		if !slices.Contains(accounts, trade.Account) {
+			accounts = append(accounts, trade.Account)
+		}
+	}
+	return accounts
+}
+
+func ofxEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
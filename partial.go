@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// rebalanceFraction is how far trades move each symbol from its current
+// weight back to its target: 1 (the default) all the way
+func (c *Config) rebalanceFraction() float64 {
+	if c.RebalanceFraction == nil {
+		return 1
+	}
+	return *c.RebalanceFraction
+}
+
+func validateRebalanceFraction(config *Config) error {
+	if f := config.RebalanceFraction; f != nil && (*f <= 0 || *f > 1) {
+		return fmt.Errorf("rebalance_fraction must be above 0 and at most 1")
+	}
+	return nil
+}
+
+// fractionFlags adds -fraction and -halfway to a command's flags. The
+// returned function, called once they are parsed, overrides the config's
+// rebalance_fraction with them.
+func fractionFlags(flagSet *flag.FlagSet, config *Config) func() error {
+	var fraction float64
+	var halfway bool
+	flagSet.Float64Var(&fraction, "fraction", 0, "Move each symbol this fraction of the way back to target, e.g. 0.5 (default rebalance_fraction, or 1)")
+	flagSet.BoolVar(&halfway, "halfway", false, "Move each symbol half of the way back to target, like -fraction 0.5")
+	return func() error {
+		if halfway {
+			if fraction != 0 && fraction != 0.5 {
+				return codedErrorf(CodeUsage, "-halfway and -fraction %g disagree", fraction)
+			}
+			fraction = 0.5
+		}
+		if fraction == 0 {
+			return nil
+		}
+		if fraction < 0 || fraction > 1 {
+			return codedErrorf(CodeUsage, "-fraction must be above 0 and at most 1")
+		}
+		config.RebalanceFraction = &fraction
+		return nil
+	}
+}
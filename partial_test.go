@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"path/filepath"
+	"testing"
+)
+
+func TestRebalanceFraction(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	config.Stocks[0].TargetPercentage = 66
+	config.Stocks[2].TargetPercentage = 16
+	holdings := loadHoldings(t, config, "cash.csv")
+	full, err := allocationCalc(config, holdings, 0)
+	if err != nil {
+		t.Fatalf("allocationCalc failed: %v", err)
+	}
+
+	half := 0.5
+	config.RebalanceFraction = &half
+	result, err := allocationCalc(config, holdings, 0)
+	if err != nil {
+		t.Fatalf("allocationCalc failed: %v", err)
+	}
+	for symbol, data := range result.Symbols {
+		if expected := full.Symbols[symbol].AmountNeeded / 2; data.AmountNeeded != expected {
+			t.Errorf("%s needed mismatch: got %d, expected %d", symbol, data.AmountNeeded, expected)
+		}
+		// Drift is still measured from the target
+		if data.Drift != full.Symbols[symbol].Drift {
+			t.Errorf("%s drift changed: got %v, expected %v", symbol, data.Drift, full.Symbols[symbol].Drift)
+		}
+	}
+
+	// A deposit is still invested in full, and VTI moves halfway from 71%
+	// to 68.5% of the new total
+	result, err = allocationCalc(config, holdings, 1000000)
+	if err != nil {
+		t.Fatalf("allocationCalc failed: %v", err)
+	}
+	sum := 0
+	for _, data := range result.Symbols {
+		sum += data.AmountNeeded
+	}
+	if sum != 1000000 {
+		t.Errorf("Expected trades to add up to the deposit, got %d", sum)
+	}
+	if got := result.Symbols["VTI"].AmountNeeded; got != 435000 {
+		t.Errorf("VTI needed mismatch: got %d, expected 435000", got)
+	}
+}
+
+func TestRebalanceFractionWithLock(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	config.Stocks[0].TargetPercentage = 66
+	config.Stocks[2].TargetPercentage = 16
+	config.Stocks[0].Locked = true
+	holdings := loadHoldings(t, config, "cash.csv")
+
+	// VTI is held back, and VXUS and BND still move only halfway to their
+	// share of what's left: half of the full -264706 and 264706
+	half := 0.5
+	config.RebalanceFraction = &half
+	result, err := allocationCalc(config, holdings, 0)
+	if err != nil {
+		t.Fatalf("allocationCalc failed: %v", err)
+	}
+	expected := map[string]int{"VTI": 0, "VXUS": -132353, "BND": 132353}
+	for symbol, needed := range expected {
+		if got := result.Symbols[symbol].AmountNeeded; got != needed {
+			t.Errorf("Expected %s to need %d, got %d", symbol, needed, got)
+		}
+	}
+}
+
+func TestFractionFlags(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected float64
+		err      bool
+	}{
+		{nil, 1, false},
+		{[]string{"-halfway"}, 0.5, false},
+		{[]string{"-fraction", "0.25"}, 0.25, false},
+		{[]string{"-fraction", "1.5"}, 0, true},
+		{[]string{"-halfway", "-fraction", "0.25"}, 0, true},
+	}
+	for _, tt := range tests {
+		config := &Config{}
+		flagSet := flag.NewFlagSet("rebalance", flag.ContinueOnError)
+		apply := fractionFlags(flagSet, config)
+		if err := flagSet.Parse(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		err := apply()
+		if tt.err {
+			if errorCode(err) != CodeUsage {
+				t.Errorf("%v: expected a usage error, got %v", tt.args, err)
+			}
+			continue
+		}
+		if err != nil || config.rebalanceFraction() != tt.expected {
+			t.Errorf("%v: got %v (%v), expected %v", tt.args, config.rebalanceFraction(), err, tt.expected)
+		}
+	}
+
+	if err := validateRebalanceFraction(&Config{RebalanceFraction: new(float64)}); err == nil {
+		t.Error("Expected an error for a fraction of 0")
+	}
+}
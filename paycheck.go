@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+)
+
+type PaycheckConfig struct {
+	Contributions []Contribution `yaml:"contributions"`
+}
+
+type Contribution struct {
+	Account string `yaml:"account"`
+	// Percentage of gross pay contributed to the account
+	Percentage float64 `yaml:"percentage"`
+	// Allocate splits the contribution by target percentages. Set it to false for
+	// contributions that buy a fixed investment, such as ESPP purchases.
+	Allocate *bool `yaml:"allocate,omitempty"`
+	// Match is the employer match formula, e.g. 100% up to 4% of pay and 50%
+	// up to 6%. The match is deposited to the same account.
+	Match []MatchTier `yaml:"match,omitempty"`
+}
+
+// MatchTier matches Rate percent of the employee contribution between the
+// previous tier's UpTo and this one's, both as percentages of gross pay
+type MatchTier struct {
+	Rate float64 `yaml:"rate"`
+	UpTo float64 `yaml:"up_to"`
+}
+
+type PaycheckResult struct {
+	Gross         int                  `json:"gross"`
+	Contributions []ContributionResult `json:"contributions"`
+	// Match is the employer match across all contributions, which doesn't
+	// come out of gross pay
+	Match     int `json:"match,omitempty"`
+	Remaining int `json:"remaining"`
+}
+
+type ContributionResult struct {
+	Account string `json:"account"`
+	Amount  int    `json:"amount"`
+	Match   int    `json:"match,omitempty"`
+	// Allocations split the contribution and its match across symbols
+	Allocations map[string]int `json:"allocations,omitempty"`
+}
+
+func (c *Contribution) allocate() bool {
+	return c.Allocate == nil || *c.Allocate
+}
+
+// matchPercentage is the employer match as a percentage of gross pay
+func (c *Contribution) matchPercentage() float64 {
+	match, previous := 0.0, 0.0
+	for _, tier := range c.Match {
+		if matched := min(c.Percentage, tier.UpTo) - previous; matched > 0 {
+			match += matched * tier.Rate / 100
+		}
+		previous = tier.UpTo
+	}
+	return match
+}
+
+func (p *PaycheckConfig) validate() error {
+	if p == nil {
+		return nil
+	}
+	totalPercentage := 0.0
+	for _, contribution := range p.Contributions {
+		if contribution.Account == "" {
+			return errors.New("paycheck contribution is missing an account")
+		}
+		if contribution.Percentage <= 0 {
+			return fmt.Errorf("paycheck contribution to %s must have a positive percentage", contribution.Account)
+		}
+		totalPercentage += contribution.Percentage
+		previous := 0.0
+		for _, tier := range contribution.Match {
+			if tier.Rate <= 0 || tier.UpTo <= previous {
+				return fmt.Errorf("paycheck match for %s needs a positive rate and up_to above the previous tier's", contribution.Account)
+			}
+			previous = tier.UpTo
+		}
+	}
+	if totalPercentage > 100.0+1e-9 {
+		return codedErrorf(CodeConfigSum, "paycheck contributions add up to %.2f%% of gross pay", totalPercentage)
+	}
+	return nil
+}
+
+func paycheck(config *Config, args []string) {
+	if len(args) < 1 {
+		flag.Usage()
+		return
+	}
+	gross, err := amountToInt(args[0])
+	if err != nil {
+		printError(codedErrorf(CodeUsage, "parsing amount: %w", err))
+		return
+	}
+
+	result, err := paycheckCalc(config, gross)
+	if err != nil {
+		printError(err)
+		return
+	}
+
+	for _, contribution := range result.Contributions {
+		fmt.Println("\n" + rule())
+		if contribution.Match > 0 {
+			fmt.Printf("%s: %s (+ %s employer match)\n", contribution.Account, formatAmount(contribution.Amount, true), formatAmount(contribution.Match, true))
+		} else {
+			fmt.Printf("%s: %s\n", contribution.Account, formatAmount(contribution.Amount, true))
+		}
+		fmt.Println(rule())
+		if contribution.Allocations == nil {
+			fmt.Println("Not allocated by target")
+			continue
+		}
+		for _, stock := range config.Stocks {
+			fmt.Printf("%s: %s\n", stock.Symbol, formatAmount(contribution.Allocations[stock.Symbol], true))
+		}
+	}
+
+	fmt.Println("\n" + rule())
+	fmt.Printf("Gross: %s\n", formatAmount(result.Gross, true))
+	if result.Match > 0 {
+		fmt.Printf("Employer match: %s\n", formatAmount(result.Match, true))
+	}
+	fmt.Printf("Remaining after contributions: %s\n", formatAmount(result.Remaining, true))
+}
+
+// paycheckCalc splits gross pay into the configured account contributions and
+// allocates each contribution across symbols by target percentage
+func paycheckCalc(config *Config, grossCents int) (*PaycheckResult, error) {
+	if config.Paycheck == nil || len(config.Paycheck.Contributions) == 0 {
+		return nil, errors.New("config has no paycheck contributions")
+	}
+	if grossCents <= 0 {
+		return nil, fmt.Errorf("gross pay must be positive, got %s", formatAmount(grossCents, true))
+	}
+
+	result := &PaycheckResult{Gross: grossCents, Remaining: grossCents}
+	for _, contribution := range config.Paycheck.Contributions {
+		amount := config.round(float64(grossCents)*(contribution.Percentage/100), "floor")
+		contributionResult := ContributionResult{
+			Account: contribution.Account,
+			Amount:  amount,
+			Match:   config.round(float64(grossCents)*contribution.matchPercentage()/100, "floor"),
+		}
+		if contribution.allocate() {
+			contributionResult.Allocations = depositCalc(config, amount+contributionResult.Match).Allocations
+		}
+		result.Contributions = append(result.Contributions, contributionResult)
+		result.Match += contributionResult.Match
+		result.Remaining -= amount
+	}
+	return result, nil
+}
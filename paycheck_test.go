@@ -0,0 +1,95 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPaycheckCalc(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "paycheck.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+
+	result, err := paycheckCalc(config, 400000)
+	if err != nil {
+		t.Fatalf("paycheckCalc failed: %v", err)
+	}
+
+	expected := []struct {
+		account  string
+		amount   int
+		vti      int
+		allocate bool
+	}{
+		{"401k", 40000, 28400, true},
+		{"ESPP", 20000, 0, false},
+		{"Brokerage", 10000, 7100, true},
+	}
+	if len(result.Contributions) != len(expected) {
+		t.Fatalf("Contribution count mismatch: got %d, expected %d", len(result.Contributions), len(expected))
+	}
+	for i, e := range expected {
+		actual := result.Contributions[i]
+		if actual.Account != e.account || actual.Amount != e.amount {
+			t.Errorf("Contribution %d mismatch: got %s %d, expected %s %d", i, actual.Account, actual.Amount, e.account, e.amount)
+		}
+		if (actual.Allocations != nil) != e.allocate {
+			t.Errorf("%s: allocation presence mismatch", e.account)
+		}
+		if actual.Allocations["VTI"] != e.vti {
+			t.Errorf("%s: VTI allocation mismatch: got %d, expected %d", e.account, actual.Allocations["VTI"], e.vti)
+		}
+	}
+	if result.Remaining != 330000 {
+		t.Errorf("Remaining mismatch: got %d, expected 330000", result.Remaining)
+	}
+}
+
+func TestPaycheckCalcMatch(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "paycheck.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	// 100% of the first 4% and 50% of the next 2%
+	config.Paycheck.Contributions[0].Match = []MatchTier{{Rate: 100, UpTo: 4}, {Rate: 50, UpTo: 6}}
+
+	result, err := paycheckCalc(config, 400000)
+	if err != nil {
+		t.Fatalf("paycheckCalc failed: %v", err)
+	}
+	contribution := result.Contributions[0]
+	if contribution.Amount != 40000 || contribution.Match != 20000 {
+		t.Errorf("401k mismatch: got %d + %d match, expected 40000 + 20000", contribution.Amount, contribution.Match)
+	}
+	// The match is allocated along with the contribution
+	if contribution.Allocations["VTI"] != 42600 {
+		t.Errorf("VTI allocation mismatch: got %d, expected 42600", contribution.Allocations["VTI"])
+	}
+	if result.Match != 20000 {
+		t.Errorf("Total match mismatch: got %d, expected 20000", result.Match)
+	}
+	// The match doesn't come out of take-home pay
+	if result.Remaining != 330000 {
+		t.Errorf("Remaining mismatch: got %d, expected 330000", result.Remaining)
+	}
+}
+
+func TestMatchPercentage(t *testing.T) {
+	tiers := []MatchTier{{Rate: 100, UpTo: 4}, {Rate: 50, UpTo: 6}}
+	tests := []struct {
+		percentage float64
+		expected   float64
+	}{
+		{3, 3},
+		{4, 4},
+		{5, 4.5},
+		{10, 5},
+	}
+	for _, tt := range tests {
+		contribution := Contribution{Percentage: tt.percentage, Match: tiers}
+		if actual := contribution.matchPercentage(); actual != tt.expected {
+			t.Errorf("matchPercentage at %v%% = %v, expected %v", tt.percentage, actual, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+// defaultPlanFile is where plan save writes and reconcile reads
+const defaultPlanFile = "plan.json"
+
+// reconcileTolerance is the share of a planned trade that may be missing, or
+// come on top, and still count as executed; less than it counts as skipped
+const reconcileTolerance = 0.05
+
+// SavedPlan is a rebalance plan kept to check against a later export
+type SavedPlan struct {
+	CreatedAt time.Time `json:"created_at"`
+	// Portfolio is the export the plan was made from, as of AsOf
+	Portfolio string         `json:"portfolio"`
+	AsOf      time.Time      `json:"as_of,omitzero"`
+	Trades    []PlannedTrade `json:"trades"`
+}
+
+// PlannedTrade is one symbol's trade in a saved plan
+type PlannedTrade struct {
+	Symbol  string `json:"symbol"`
+	Account string `json:"account,omitempty"`
+	// Amount is positive for a purchase and negative for a sale
+	Amount int `json:"amount"`
+	// Before and Price are the symbol's value and share price when planned
+	Before int `json:"before"`
+	Price  int `json:"price,omitempty"`
+}
+
+// TradeReconciliation says how much of a planned trade the new export shows
+type TradeReconciliation struct {
+	PlannedTrade
+	// Traded is the change in value not explained by the change in price
+	Traded int `json:"traded"`
+	// Status is executed, partial, or skipped
+	Status string `json:"status"`
+}
+
+func plan(config *Config, args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: fin-tilt plan save <portfolio.csv> [-toDeposit <amount>] [-o <plan.json>]")
+		return
+	}
+	switch args[0] {
+	case "save":
+		planSave(config, args[1:])
+	default:
+		printError(codedErrorf(CodeUsage, "unknown plan command %q", args[0]))
+	}
+}
+
+func planSave(config *Config, args []string) {
+	var output string
+	var toDeposit int
+	flagSet := flag.NewFlagSet("plan save", flag.ExitOnError)
+	flagSet.StringVar(&output, "o", defaultPlanFile, "File to save the plan in")
+	flagSet.Func("toDeposit", "Additional amount to deposit, in dollars; negative for a withdrawal", func(value string) error {
+		amount, err := amountToInt(value)
+		toDeposit = amount
+		return err
+	})
+	applyFraction := fractionFlags(flagSet, config)
+	if len(args) < 1 {
+		flag.Usage()
+		return
+	}
+	portfolioCsv := args[0]
+	flagSet.Parse(args[1:])
+	if err := applyFraction(); err != nil {
+		printError(err)
+		return
+	}
+
+	holdings, err := loadPortfolio(config, portfolioCsv)
+	if err != nil {
+		printError(err)
+		return
+	}
+	result, err := allocationCalc(config, holdings, toDeposit)
+	if err != nil {
+		printError(err)
+		return
+	}
+	auditOutputs.rebalanced(result)
+	saved := savePlan(config, portfolioCsv, holdings, result, time.Now())
+	err = writeOutput(output, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(saved)
+	})
+	if err != nil {
+		printError(err)
+		return
+	}
+	if dryRun {
+		return
+	}
+	auditOutputs.PlanExported = output
+	fmt.Printf("Saved %d trades to %s; run reconcile with the next export to check them\n", len(saved.Trades), output)
+}
+
+// savePlan keeps the trades of a rebalance result with what each symbol was
+// worth, in config order
+func savePlan(config *Config, portfolio string, holdings *Holdings, result *RebalanceResult, now time.Time) *SavedPlan {
+	saved := &SavedPlan{CreatedAt: now, Portfolio: portfolio, AsOf: holdings.AsOf, Trades: []PlannedTrade{}}
+	for _, stock := range config.Stocks {
+		data := result.Symbols[stock.Symbol]
+		amount := data.AmountNeeded
+		if data.WholeShares {
+			amount -= data.ResidualCash
+		}
+		if amount == 0 {
+			continue
+		}
+		saved.Trades = append(saved.Trades, PlannedTrade{
+			Symbol:  stock.Symbol,
+			Account: data.Account,
+			Amount:  amount,
+			Before:  data.Amount,
+			Price:   holdings.Prices[stock.Symbol],
+		})
+	}
+	return saved
+}
+
+func readPlan(path string) (*SavedPlan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var saved SavedPlan
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, codedErrorf(CodeConfigInvalid, "%s: %w", path, err)
+	}
+	return &saved, nil
+}
+
+func reconcile(config *Config, args []string) {
+	var planPath, format string
+	flagSet := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	flagSet.StringVar(&planPath, "plan", defaultPlanFile, "Plan saved by plan save")
+	flagSet.StringVar(&format, "format", "text", "Output format: text or json")
+	if len(args) < 1 {
+		flag.Usage()
+		return
+	}
+	portfolioCsv := args[0]
+	flagSet.Parse(args[1:])
+	if format != "text" && format != "json" {
+		printError(codedErrorf(CodeUsage, "unknown format %q", format))
+		return
+	}
+
+	saved, err := readPlan(planPath)
+	if err != nil {
+		printError(fmt.Errorf("reading plan: %w", err))
+		return
+	}
+	holdings, err := loadPortfolio(config, portfolioCsv)
+	if err != nil {
+		printError(err)
+		return
+	}
+	results := reconcileCalc(saved, holdings)
+
+	if format == "json" {
+		if err := writeJSON(os.Stdout, results); err != nil {
+			printError(err)
+		}
+		return
+	}
+	fmt.Printf("Plan saved %s from %s\n", saved.CreatedAt.Format(time.DateOnly), saved.Portfolio)
+	fmt.Println(rule())
+	for _, r := range results {
+		planned := tradeText(r.Amount, formatAmount(r.Amount, false))
+		status := r.Status
+		switch status {
+		case "executed":
+			status = green(status)
+		case "skipped":
+			status = red(status)
+		}
+		line := fmt.Sprintf("%-6s %s: %s", r.Symbol, planned, status)
+		if r.Status == "partial" {
+			line += fmt.Sprintf(" (%s, %.0f%%)", formatAmount(abs(r.Traded), true), 100*float64(r.Traded)/float64(r.Amount))
+		}
+		if r.Account != "" {
+			line += " in " + r.Account
+		}
+		fmt.Println(line)
+	}
+}
+
+// reconcileCalc compares each planned trade with the change in its symbol's
+// value since the plan, less the change that the price alone explains
+func reconcileCalc(saved *SavedPlan, holdings *Holdings) []TradeReconciliation {
+	results := make([]TradeReconciliation, 0, len(saved.Trades))
+	for _, trade := range saved.Trades {
+		untraded := float64(trade.Before)
+		if price := holdings.Prices[trade.Symbol]; trade.Price > 0 && price > 0 {
+			untraded *= float64(price) / float64(trade.Price)
+		}
+		traded := holdings.Amounts[trade.Symbol] - int(math.Round(untraded))
+		status := "partial"
+		switch fraction := float64(traded) / float64(trade.Amount); {
+		case fraction >= 1-reconcileTolerance:
+			status = "executed"
+		case fraction <= reconcileTolerance:
+			status = "skipped"
+		}
+		results = append(results, TradeReconciliation{PlannedTrade: trade, Traded: traded, Status: status})
+	}
+	return results
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSavePlan(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	config.Stocks[1].TargetPercentage = 10
+	config.Stocks[2].TargetPercentage = 19
+	holdings := loadHoldings(t, config, "cash.csv")
+	result, err := allocationCalc(config, holdings, 0)
+	if err != nil {
+		t.Fatalf("allocationCalc failed: %v", err)
+	}
+	saved := savePlan(config, "cash.csv", holdings, result, time.Now())
+	if len(saved.Trades) == 0 {
+		t.Fatal("Expected trades in the plan")
+	}
+	for _, trade := range saved.Trades {
+		data := result.Symbols[trade.Symbol]
+		if trade.Amount != data.AmountNeeded || trade.Before != data.Amount {
+			t.Errorf("Trade of %s mismatch: got %+v, expected %+v", trade.Symbol, trade, data)
+		}
+	}
+
+	// The plan reads back as it was saved
+	path := filepath.Join(t.TempDir(), "plan.json")
+	data, err := json.Marshal(saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	read, err := readPlan(path)
+	if err != nil {
+		t.Fatalf("readPlan failed: %v", err)
+	}
+	if len(read.Trades) != len(saved.Trades) || read.Trades[0] != saved.Trades[0] {
+		t.Errorf("Plan mismatch: got %+v, expected %+v", read.Trades, saved.Trades)
+	}
+}
+
+func TestReconcileCalc(t *testing.T) {
+	saved := &SavedPlan{Trades: []PlannedTrade{
+		{Symbol: "VTI", Amount: 100000, Before: 500000, Price: 25000},
+		{Symbol: "VXUS", Amount: -50000, Before: 200000, Price: 6000},
+		{Symbol: "BND", Amount: 40000, Before: 100000, Price: 7000},
+		{Symbol: "VNQ", Amount: 20000, Before: 30000},
+	}}
+	holdings := &Holdings{
+		// VTI rose 10% and was bought in full, VXUS half sold, BND sold
+		// instead of bought, and VNQ untouched with no price
+		Amounts: map[string]int{"VTI": 650000, "VXUS": 175000, "BND": 90000, "VNQ": 30000},
+		Prices:  map[string]int{"VTI": 27500, "VXUS": 6000, "BND": 7000},
+	}
+	expected := map[string]string{"VTI": "executed", "VXUS": "partial", "BND": "skipped", "VNQ": "skipped"}
+	results := reconcileCalc(saved, holdings)
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got %+v", len(expected), results)
+	}
+	for _, r := range results {
+		if r.Status != expected[r.Symbol] {
+			t.Errorf("Status of %s mismatch: got %s (traded %d), expected %s", r.Symbol, r.Status, r.Traded, expected[r.Symbol])
+		}
+	}
+	if results[0].Traded != 100000 {
+		t.Errorf("Expected the price change left out of VTI's trade, got %d", results[0].Traded)
+	}
+}
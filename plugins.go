@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pluginProtocol is the version of the request sent to plugins. It changes
+// only when a field is removed or changes meaning.
+const pluginProtocol = 1
+
+// pluginPrefix marks a portfolio argument as the name of a holdings plugin,
+// e.g. "plugin:mybroker", instead of a CSV file
+const pluginPrefix = "plugin:"
+
+var pluginTypes = []string{"holdings", "quotes", "fx", "metadata"}
+
+// PluginConfig is an external program that provides holdings, quotes,
+// exchange rates, or symbol metadata. It reads a PluginRequest as JSON on stdin and writes a
+// PluginResponse as JSON on stdout.
+type PluginConfig struct {
+	// Command is run through the shell
+	Command  string   `yaml:"command"`
+	Provides []string `yaml:"provides"`
+}
+
+type PluginRequest struct {
+	Protocol int    `json:"protocol"`
+	Type     string `json:"type"`
+	// Symbols are the configured symbols for holdings and metadata, and
+	// those to quote
+	Symbols []string `json:"symbols,omitempty"`
+	// Currencies are the currencies to convert to USD for fx
+	Currencies []string `json:"currencies,omitempty"`
+	// Date asks for the fx rates of a past day (YYYY-MM-DD), set by -fxDate
+	Date string `json:"date,omitempty"`
+	// Offline is set with -offline; plugins should answer from a cache
+	Offline bool `json:"offline"`
+}
+
+type PluginResponse struct {
+	// AsOf is when the holdings were valued, as RFC 3339 or YYYY-MM-DD
+	AsOf      string           `json:"as_of,omitempty"`
+	Positions []PluginPosition `json:"positions,omitempty"`
+	// Quotes are prices by symbol
+	Quotes map[string]Quote `json:"quotes,omitempty"`
+	// Rates are the USD value of one unit of each currency
+	Rates map[string]float64 `json:"rates,omitempty"`
+	// Metadata describes each symbol asked about
+	Metadata map[string]SymbolMetadata `json:"metadata,omitempty"`
+	// Error explains a failure; the plugin should also exit non-zero
+	Error string `json:"error,omitempty"`
+}
+
+type PluginPosition struct {
+	Account   string  `json:"account,omitempty"`
+	Symbol    string  `json:"symbol"`
+	Quantity  float64 `json:"quantity,omitempty"`
+	Price     Money   `json:"price,omitempty"`
+	Value     Money   `json:"value"`
+	CostBasis Money   `json:"cost_basis,omitempty"`
+	// Currency of Price, Value, and CostBasis (default USD)
+	Currency string `json:"currency,omitempty"`
+}
+
+// Quote is a price in dollars, kept to a fraction of a cent for coins worth
+// less than a dollar. Like Money, it may be sent as a number or a string.
+type Quote float64
+
+func (q *Quote) UnmarshalJSON(data []byte) error {
+	value := string(data)
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = unquoted
+	}
+	value = strings.ReplaceAll(strings.TrimPrefix(strings.TrimSpace(value), "$"), ",", "")
+	price, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("invalid quote %s", data)
+	}
+	*q = Quote(price)
+	return nil
+}
+
+// cents rounds the quote to a whole cent, as Holdings keeps prices
+func (q Quote) cents() int {
+	return int(math.Round(float64(q) * 100))
+}
+
+func validatePlugins(config *Config) error {
+	for name, plugin := range config.Plugins {
+		if plugin.Command == "" {
+			return fmt.Errorf("plugin %s needs a command", name)
+		}
+		if len(plugin.Provides) == 0 {
+			return fmt.Errorf("plugin %s must provide at least one of %s", name, strings.Join(pluginTypes, ", "))
+		}
+		for _, kind := range plugin.Provides {
+			if !slices.Contains(pluginTypes, kind) {
+				return fmt.Errorf("plugin %s provides unknown type %q (expected one of %s)", name, kind, strings.Join(pluginTypes, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// pluginFor returns the name of the plugin that provides kind, preferring the
+// first in name order so the choice is stable
+func (c *Config) pluginFor(kind string) string {
+	var names []string
+	for name, plugin := range c.Plugins {
+		if slices.Contains(plugin.Provides, kind) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	if len(names) == 0 {
+		return ""
+	}
+	return names[0]
+}
+
+// runPlugin sends request to the named plugin and returns its response. The
+// plugin's stderr is passed through so it can report progress.
+func runPlugin(ctx context.Context, config *Config, name string, request PluginRequest) (*PluginResponse, error) {
+	plugin, ok := config.Plugins[name]
+	if !ok {
+		return nil, codedErrorf(CodePlugin, "no plugin named %s in the config", name)
+	}
+	if !slices.Contains(plugin.Provides, request.Type) {
+		return nil, codedErrorf(CodePlugin, "plugin %s doesn't provide %s", name, request.Type)
+	}
+	request.Protocol = pluginProtocol
+	request.Offline = offline
+	input, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, httpClient.Timeout)
+	defer cancel()
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", plugin.Command)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	runErr := cmd.Run()
+
+	var response PluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil && runErr == nil {
+		return nil, codedErrorf(CodePlugin, "plugin %s wrote an invalid response: %w", name, err)
+	}
+	if response.Error != "" {
+		return nil, codedErrorf(CodePlugin, "plugin %s: %s", name, response.Error)
+	}
+	if runErr != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("plugin %s: %w", name, ctx.Err())
+		}
+		return nil, codedErrorf(CodePlugin, "plugin %s failed: %w", name, runErr)
+	}
+	return &response, nil
+}
+
+// loadPluginHoldings reads holdings from a plugin
+func loadPluginHoldings(ctx context.Context, config *Config, name string) (*Holdings, error) {
+	var symbols []string
+	for _, stock := range config.Stocks {
+		symbols = append(symbols, stock.Symbol)
+		symbols = append(symbols, stock.Alternatives...)
+	}
+	response, err := runPlugin(ctx, config, name, PluginRequest{Type: "holdings", Symbols: symbols})
+	if err != nil {
+		return nil, err
+	}
+	positions, err := convertCurrencies(ctx, config, response.Positions)
+	if err != nil {
+		return nil, err
+	}
+
+	holdings, err := readPositions(config, positions)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", name, err)
+	}
+	if response.AsOf != "" {
+		asOf, err := time.Parse(time.RFC3339, response.AsOf)
+		if err != nil {
+			if asOf, err = time.ParseInLocation(time.DateOnly, response.AsOf, time.Local); err != nil {
+				return nil, codedErrorf(CodePlugin, "plugin %s: invalid as_of %q", name, response.AsOf)
+			}
+		}
+		holdings.AsOf, holdings.AsOfSource = asOf, asOfExport
+	}
+	return holdings, nil
+}
+
+// readPositions lays positions out as a CSV export so that alternatives,
+// cash, and target-date funds are handled exactly as they are for files
+func readPositions(config *Config, positions []PluginPosition) (*Holdings, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"Account Name", "Symbol", "Quantity", "Last Price", "Current Value", "Cost Basis Total"})
+	for _, p := range positions {
+		row := []string{p.Account, p.Symbol, "", "", formatDecimal(int(p.Value)), ""}
+		if p.Quantity != 0 {
+			row[2] = strconv.FormatFloat(p.Quantity, 'f', -1, 64)
+		}
+		if p.Price != 0 {
+			row[3] = formatDecimal(int(p.Price))
+		}
+		if p.CostBasis != 0 {
+			row[5] = formatDecimal(int(p.CostBasis))
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	return readHoldings(config, &buf)
+}
+
+// convertCurrencies converts positions valued in other currencies to USD with
+// rates from the fx plugin, or from the fx source if there is none
+func convertCurrencies(ctx context.Context, config *Config, positions []PluginPosition) ([]PluginPosition, error) {
+	var currencies []string
+	for _, p := range positions {
+		if p.Currency != "" && p.Currency != "USD" && !slices.Contains(currencies, p.Currency) {
+			currencies = append(currencies, p.Currency)
+		}
+	}
+	if len(currencies) == 0 {
+		return positions, nil
+	}
+	var rates map[string]float64
+	var err error
+	if name := config.pluginFor("fx"); name != "" {
+		request := PluginRequest{Type: "fx", Currencies: currencies}
+		if !fxDate.IsZero() {
+			request.Date = fxDate.Format(time.DateOnly)
+		}
+		response, err := runPlugin(ctx, config, name, request)
+		if err != nil {
+			return nil, err
+		}
+		for _, currency := range currencies {
+			if rate := response.Rates[currency]; rate <= 0 {
+				return nil, codedErrorf(CodePlugin, "plugin %s has no rate for %s", name, currency)
+			}
+		}
+		rates = response.Rates
+	} else if rates, err = fxRates(ctx, config, currencies, fxDate); err != nil {
+		return nil, fmt.Errorf("converting holdings in %s: %w", strings.Join(currencies, ", "), err)
+	}
+	convert := func(amount Money, rate float64) Money { return Money(math.Round(float64(amount) * rate)) }
+	converted := slices.Clone(positions)
+	for i, p := range converted {
+		if p.Currency == "" || p.Currency == "USD" {
+			continue
+		}
+		rate := rates[p.Currency]
+		converted[i].Price, converted[i].Value, converted[i].CostBasis = convert(p.Price, rate), convert(p.Value, rate), convert(p.CostBasis, rate)
+		converted[i].Currency = "USD"
+	}
+	return converted, nil
+}
+
+// fillQuotes asks the quotes plugin, if there is one, for the price of each
+// configured symbol the holdings have no price for
+func fillQuotes(ctx context.Context, config *Config, holdings *Holdings) error {
+	name := config.pluginFor("quotes")
+	if name == "" {
+		return nil
+	}
+	var missing []string
+	for _, stock := range config.Stocks {
+		if holdings.Prices[stock.Symbol] == 0 {
+			missing = append(missing, stock.Symbol)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	response, err := runPlugin(ctx, config, name, PluginRequest{Type: "quotes", Symbols: missing})
+	if err != nil {
+		return err
+	}
+	for _, symbol := range missing {
+		if price := response.Quotes[symbol]; price > 0 {
+			holdings.Prices[symbol] = price.cents()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPluginHoldings(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "accounts.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	requests := filepath.Join(t.TempDir(), "requests.jsonl")
+	respond := func(response string) string {
+		return "cat >> " + requests + " && echo >> " + requests + " && printf '%s' '" + response + "'"
+	}
+	config.Plugins = map[string]PluginConfig{
+		"broker": {Command: respond(`{"as_of": "2026-10-01", "positions": [
+			{"account": "Brokerage", "symbol": "VTI", "quantity": 10, "price": 250, "value": "$2,500.00"},
+			{"account": "Brokerage", "symbol": "VXUS", "value": 500},
+			{"account": "Depot", "symbol": "BND", "value": 900, "currency": "EUR"}]}`), Provides: []string{"holdings"}},
+		"rates":  {Command: respond(`{"rates": {"EUR": 1.1}}`), Provides: []string{"fx"}},
+		"quotes": {Command: respond(`{"quotes": {"VXUS": "61.12", "BND": 72.5}}`), Provides: []string{"quotes"}},
+	}
+	if err := validatePlugins(config); err != nil {
+		t.Fatalf("validatePlugins failed: %v", err)
+	}
+
+	holdings, err := loadPortfolio(config, "plugin:broker")
+	if err != nil {
+		t.Fatalf("loadPortfolio failed: %v", err)
+	}
+	if holdings.Amounts["VTI"] != 250000 || holdings.Amounts["VXUS"] != 50000 || holdings.Amounts["BND"] != 99000 {
+		t.Errorf("Amounts mismatch: got %v", holdings.Amounts)
+	}
+	if holdings.AmountsByAccount["VTI"]["Brokerage"] != 250000 {
+		t.Errorf("Account attribution mismatch: got %v", holdings.AmountsByAccount)
+	}
+	if holdings.Prices["VTI"] != 25000 || holdings.Prices["VXUS"] != 6112 || holdings.Prices["BND"] != 7250 {
+		t.Errorf("Prices mismatch: got %v", holdings.Prices)
+	}
+	if holdings.AsOf.Format("2006-01-02") != "2026-10-01" {
+		t.Errorf("AsOf mismatch: got %v", holdings.AsOf)
+	}
+
+	data, err := os.ReadFile(requests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent []PluginRequest
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var request PluginRequest
+		if err := json.Unmarshal([]byte(line), &request); err != nil {
+			t.Fatalf("Invalid request %q: %v", line, err)
+		}
+		sent = append(sent, request)
+	}
+	if len(sent) != 3 || sent[0].Type != "holdings" || sent[1].Type != "fx" || sent[2].Type != "quotes" {
+		t.Fatalf("Requests mismatch: got %+v", sent)
+	}
+	if sent[0].Protocol != pluginProtocol || strings.Join(sent[1].Currencies, ",") != "EUR" || strings.Join(sent[2].Symbols, ",") != "VXUS,BND" {
+		t.Errorf("Requests mismatch: got %+v", sent)
+	}
+}
+
+func TestPluginErrors(t *testing.T) {
+	config := &Config{Plugins: map[string]PluginConfig{
+		"failing":  {Command: `printf '{"error": "session expired"}'; exit 1`, Provides: []string{"holdings"}},
+		"crashing": {Command: "exit 2", Provides: []string{"holdings"}},
+		"garbled":  {Command: "echo not json", Provides: []string{"holdings"}},
+	}}
+	for name, expected := range map[string]string{
+		"failing":  "plugin failing: session expired",
+		"crashing": "plugin crashing failed: exit status 2",
+		"garbled":  "plugin garbled wrote an invalid response",
+		"missing":  "no plugin named missing in the config",
+	} {
+		_, err := runPlugin(context.Background(), config, name, PluginRequest{Type: "holdings"})
+		if errorCode(err) != CodePlugin || !strings.HasPrefix(err.Error(), expected) {
+			t.Errorf("%s: expected %q, got %v", name, expected, err)
+		}
+	}
+
+	if err := validatePlugins(&Config{Plugins: map[string]PluginConfig{"x": {Command: "true", Provides: []string{"trades"}}}}); err == nil {
+		t.Error("Expected an unknown provider type to be invalid")
+	}
+}
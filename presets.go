@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// presetReader returns the reader for a Canadian or European broker's
+// export, recognized by its header, or nil if br holds something else. Their
+// values are in the account's currency, so each is converted to USD.
+func presetReader(br *bufio.Reader) func(*Config, io.Reader) (*Holdings, error) {
+	start, _ := br.Peek(4096)
+	start = bytes.ToLower(start)
+	switch {
+	case bytes.Contains(start, []byte("open qty")) && bytes.Contains(start, []byte("book cost")):
+		return readQuestrade
+	case bytes.Contains(start, []byte("market value currency")):
+		return readWealthsimple
+	case bytes.Contains(start, []byte("symbol/isin")) || bytes.Contains(start, []byte("symbool/isin")):
+		return readDegiro
+	case bytes.HasPrefix(bytes.TrimPrefix(start, []byte("\xef\xbb\xbf")), []byte("statement,header,")):
+		return readIBKRStatement
+	}
+	return nil
+}
+
+// parseDecimal parses a number written with either decimal separator. When
+// both appear, the last is the decimal separator; a lone comma is one when
+// comma is set, as in European exports, and a thousands separator otherwise.
+func parseDecimal(value string, comma bool) (float64, error) {
+	str := strings.NewReplacer(" ", "", "\u00a0", "", "'", "").Replace(strings.TrimSpace(value))
+	switch dot, lastComma := strings.LastIndex(str, "."), strings.LastIndex(str, ","); {
+	case dot >= 0 && lastComma >= 0 && lastComma > dot:
+		str = strings.ReplaceAll(strings.ReplaceAll(str, ".", ""), ",", ".")
+	case dot >= 0 && lastComma >= 0:
+		str = strings.ReplaceAll(str, ",", "")
+	case lastComma >= 0 && comma && strings.Count(str, ",") == 1:
+		str = strings.ReplaceAll(str, ",", ".")
+	default:
+		str = strings.ReplaceAll(str, ",", "")
+	}
+	number, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", value)
+	}
+	return number, nil
+}
+
+// parseDecimalCents parses an amount with parseDecimal, in cents
+func parseDecimalCents(value string, comma bool) (Money, error) {
+	number, err := parseDecimal(value, comma)
+	return Money(math.Round(number * 100)), err
+}
+
+// readPreset converts positions read from a preset's export to USD and
+// reads them as holdings
+func readPreset(config *Config, positions []PluginPosition, asOf time.Time) (*Holdings, error) {
+	positions, err := convertCurrencies(context.Background(), config, positions)
+	if err != nil {
+		return nil, err
+	}
+	holdings, err := readPositions(config, positions)
+	if err != nil {
+		return nil, err
+	}
+	if !asOf.IsZero() {
+		holdings.AsOf, holdings.AsOfSource = asOf, asOfExport
+	}
+	return holdings, nil
+}
+
+// presetRows reads a preset's header and calls row for each record after it
+// with a function that returns a named column's field
+func presetRows(r io.Reader, broker string, required []string, row func(column func(string) string) error) error {
+	reader := newCSVReader(r)
+	record, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("error reading header: %w", err)
+	}
+	header := normalizeHeader(record)
+	index := func(name string) int {
+		return slices.IndexFunc(header, func(h string) bool { return strings.EqualFold(h, name) })
+	}
+	for _, name := range required {
+		if index(name) < 0 {
+			return codedErrorf(CodeCSVHeader, "%s export must have a '%s' column", broker, name)
+		}
+	}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := row(func(name string) string { return strings.TrimSpace(field(record, index(name))) }); err != nil {
+			return err
+		}
+	}
+}
+
+// readQuestrade reads Questrade's positions export, whose rows are in the
+// currency each security trades in
+func readQuestrade(config *Config, r io.Reader) (*Holdings, error) {
+	var positions []PluginPosition
+	err := presetRows(r, "Questrade", []string{"Symbol", "Open Qty", "Market Value", "Currency"}, func(column func(string) string) error {
+		symbol := column("Symbol")
+		if symbol == "" {
+			return nil
+		}
+		position := PluginPosition{Account: column("Account"), Symbol: symbol, Currency: column("Currency")}
+		var err error
+		if position.Quantity, err = parseDecimal(column("Open Qty"), false); err != nil {
+			return codedErrorf(CodeCSVValue, "error parsing quantity of %s: %w", symbol, err)
+		}
+		if position.Value, err = parseDecimalCents(column("Market Value"), false); err != nil {
+			return codedErrorf(CodeCSVValue, "error parsing market value of %s: %w", symbol, err)
+		}
+		if price := column("Current Price"); price != "" {
+			if position.Price, err = parseDecimalCents(price, false); err != nil {
+				return codedErrorf(CodeCSVValue, "error parsing price of %s: %w", symbol, err)
+			}
+		}
+		if cost := column("Book Cost"); cost != "" {
+			if position.CostBasis, err = parseDecimalCents(cost, false); err != nil {
+				return codedErrorf(CodeCSVValue, "error parsing book cost of %s: %w", symbol, err)
+			}
+		}
+		positions = append(positions, position)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return readPreset(config, positions, time.Time{})
+}
+
+// readWealthsimple reads Wealthsimple's holdings report, which ends with an
+// "As of" line. Book values are in CAD, so they are only kept for holdings
+// valued in CAD.
+func readWealthsimple(config *Config, r io.Reader) (*Holdings, error) {
+	var positions []PluginPosition
+	var asOf time.Time
+	err := presetRows(r, "Wealthsimple", []string{"Symbol", "Quantity", "Market Value", "Market Value Currency"}, func(column func(string) string) error {
+		if date, found := parseAsOf([]string{column("Account Name")}); found {
+			asOf = date
+			return nil
+		}
+		symbol := column("Symbol")
+		if symbol == "" && strings.EqualFold(column("Security Type"), "Cash") {
+			symbol = "CASH"
+		}
+		if symbol == "" || column("Market Value") == "" {
+			return nil
+		}
+		account := column("Account Name")
+		if account == "" {
+			account = column("Account Number")
+		}
+		position := PluginPosition{Account: account, Symbol: symbol, Currency: column("Market Value Currency")}
+		var err error
+		if quantity := column("Quantity"); quantity != "" {
+			if position.Quantity, err = parseDecimal(quantity, false); err != nil {
+				return codedErrorf(CodeCSVValue, "error parsing quantity of %s: %w", symbol, err)
+			}
+		}
+		if position.Value, err = parseDecimalCents(column("Market Value"), false); err != nil {
+			return codedErrorf(CodeCSVValue, "error parsing market value of %s: %w", symbol, err)
+		}
+		if price := column("Market Price"); price != "" && column("Market Price Currency") == position.Currency {
+			if position.Price, err = parseDecimalCents(price, false); err != nil {
+				return codedErrorf(CodeCSVValue, "error parsing price of %s: %w", symbol, err)
+			}
+		}
+		if cost := column("Book Value (CAD)"); cost != "" && position.Currency == "CAD" {
+			if position.CostBasis, err = parseDecimalCents(cost, false); err != nil {
+				return codedErrorf(CodeCSVValue, "error parsing book value of %s: %w", symbol, err)
+			}
+		}
+		positions = append(positions, position)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return readPreset(config, positions, asOf)
+}
+
+// readDegiro reads DEGIRO's portfolio export, in English or Dutch. Holdings
+// are named by ISIN and valued in the account's base currency, given by the
+// header of the last column (e.g. "Value in EUR"). Numbers may use decimal
+// commas.
+func readDegiro(config *Config, r io.Reader) (*Holdings, error) {
+	reader := newCSVReader(r)
+	record, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading header: %w", err)
+	}
+	header := normalizeHeader(record)
+	column := func(names ...string) int {
+		return slices.IndexFunc(header, func(h string) bool {
+			return slices.ContainsFunc(names, func(name string) bool { return strings.EqualFold(h, name) })
+		})
+	}
+	productIndex := column("Product")
+	symbolIndex := column("Symbol/ISIN", "Symbool/ISIN")
+	quantityIndex := column("Quantity", "Aantal")
+	valueIndex, currency := -1, ""
+	for i, name := range header {
+		for _, prefix := range []string{"Value in ", "Waarde in "} {
+			if code, ok := strings.CutPrefix(name, prefix); ok && currencyPattern.MatchString(code) {
+				valueIndex, currency = i, code
+			}
+		}
+	}
+	if productIndex == -1 || symbolIndex == -1 || valueIndex == -1 {
+		return nil, codedErrorf(CodeCSVHeader, "DEGIRO export must have 'Product', 'Symbol/ISIN', and 'Value in <currency>' columns")
+	}
+
+	var positions []PluginPosition
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		symbol, product := strings.TrimSpace(field(record, symbolIndex)), strings.TrimSpace(field(record, productIndex))
+		if symbol == "" && strings.HasPrefix(strings.ToUpper(product), "CASH") {
+			symbol = "CASH"
+		}
+		if symbol == "" || field(record, valueIndex) == "" {
+			continue
+		}
+		position := PluginPosition{Account: "DEGIRO", Symbol: symbol, Currency: currency}
+		if position.Value, err = parseDecimalCents(field(record, valueIndex), true); err != nil {
+			return nil, codedErrorf(CodeCSVValue, "error parsing value of %s: %w", product, err)
+		}
+		if quantity := strings.TrimSpace(field(record, quantityIndex)); quantity != "" && symbol != "CASH" {
+			if position.Quantity, err = parseDecimal(quantity, true); err != nil {
+				return nil, codedErrorf(CodeCSVValue, "error parsing quantity of %s: %w", product, err)
+			}
+			// The closing price is in the listing's currency, which may not
+			// be the base currency
+			if position.Quantity != 0 {
+				position.Price = Money(math.Round(float64(position.Value) / position.Quantity))
+			}
+		}
+		positions = append(positions, position)
+	}
+	return readPreset(config, positions, time.Time{})
+}
+
+// readIBKRStatement reads the Open Positions and Cash Report sections of an
+// Interactive Brokers activity statement. Every row starts with its
+// section's name and whether it is a Header, Data, or Total row, and each
+// section has its own header.
+func readIBKRStatement(config *Config, r io.Reader) (*Holdings, error) {
+	reader := newCSVReader(r)
+	headers := make(map[string][]string)
+	account := "Interactive Brokers"
+	var positions []PluginPosition
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if len(record) < 3 {
+			continue
+		}
+		section, kind, fields := record[0], record[1], record[2:]
+		if kind == "Header" {
+			headers[section] = normalizeHeader(fields)
+			continue
+		}
+		if kind != "Data" {
+			continue
+		}
+		column := func(name string) string {
+			return strings.TrimSpace(field(fields, slices.Index(headers[section], name)))
+		}
+		switch section {
+		case "Account Information":
+			if column("Field Name") == "Account" {
+				account = column("Field Value")
+			}
+		case "Open Positions":
+			// Lots are listed under their summary row when the statement
+			// shows them
+			if column("DataDiscriminator") != "Summary" {
+				continue
+			}
+			symbol := column("Symbol")
+			position := PluginPosition{Account: account, Symbol: symbol, Currency: column("Currency")}
+			if position.Quantity, err = parseDecimal(column("Quantity"), false); err != nil {
+				return nil, codedErrorf(CodeCSVValue, "error parsing quantity of %s: %w", symbol, err)
+			}
+			if position.Value, err = parseDecimalCents(column("Value"), false); err != nil {
+				return nil, codedErrorf(CodeCSVValue, "error parsing value of %s: %w", symbol, err)
+			}
+			if position.Price, err = parseDecimalCents(column("Close Price"), false); err != nil {
+				return nil, codedErrorf(CodeCSVValue, "error parsing close price of %s: %w", symbol, err)
+			}
+			if cost := column("Cost Basis"); cost != "" {
+				if position.CostBasis, err = parseDecimalCents(cost, false); err != nil {
+					return nil, codedErrorf(CodeCSVValue, "error parsing cost basis of %s: %w", symbol, err)
+				}
+			}
+			positions = append(positions, position)
+		case "Cash Report":
+			// Each currency's ending cash, not the base currency summary
+			// that totals them
+			currency := column("Currency")
+			if column("Currency Summary") != "Ending Cash" || !currencyPattern.MatchString(currency) {
+				continue
+			}
+			value, err := parseDecimalCents(column("Total"), false)
+			if err != nil {
+				return nil, codedErrorf(CodeCSVValue, "error parsing ending cash in %s: %w", currency, err)
+			}
+			positions = append(positions, PluginPosition{Account: account, Symbol: "CASH", Value: value, Currency: currency})
+		}
+	}
+	if headers["Open Positions"] == nil && headers["Cash Report"] == nil {
+		return nil, codedErrorf(CodeCSVHeader, "Interactive Brokers statement has no Open Positions or Cash Report section")
+	}
+	return readPreset(config, positions, time.Time{})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadPresetExports(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "international.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	config.Plugins = map[string]PluginConfig{
+		"rates": {Command: `cat > /dev/null; printf '{"rates": {"EUR": 1.2, "CAD": 0.75}}'`, Provides: []string{"fx"}},
+	}
+	tests := []struct {
+		file    string
+		amounts map[string]int
+		cash    map[string]int
+		asOf    string
+	}{
+		// CAD rows are converted, USD rows kept as they are
+		{"questrade.csv", map[string]int{"XEQT.TO": 240000, "VTI": 280000}, map[string]int{}, ""},
+		// Cash rows have a security type but no symbol
+		{"wealthsimple.csv", map[string]int{"XEQT.TO": 121200, "VTI": 56000}, map[string]int{"TFSA": 15000}, "2026-10-01"},
+		// Values with decimal commas, in the EUR base currency
+		{"degiro.csv", map[string]int{"VWCE": 504960, "VTI": 140000}, map[string]int{"DEGIRO": 30000}, ""},
+		// Each currency's ending cash, but not the base currency total
+		{"ibkr.csv", map[string]int{"VWCE": 378720, "VTI": 112000}, map[string]int{"U1234567": 130000}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			file, err := os.Open(filepath.Join("tests", "portfolios", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			br := bufio.NewReader(file)
+			read := presetReader(br)
+			if read == nil {
+				t.Fatalf("Expected %s to be recognized as a broker preset", tt.file)
+			}
+			holdings, err := read(config, br)
+			if err != nil {
+				t.Fatalf("Reading failed: %v", err)
+			}
+			for symbol, amount := range tt.amounts {
+				if holdings.Amounts[symbol] != amount {
+					t.Errorf("Amount of %s mismatch: got %d, expected %d", symbol, holdings.Amounts[symbol], amount)
+				}
+			}
+			if len(holdings.Cash) != len(tt.cash) {
+				t.Errorf("Cash mismatch: got %v, expected %v", holdings.Cash, tt.cash)
+			}
+			for account, amount := range tt.cash {
+				if holdings.Cash[account] != amount {
+					t.Errorf("Cash in %s mismatch: got %d, expected %d", account, holdings.Cash[account], amount)
+				}
+			}
+			if asOf := holdings.AsOf; tt.asOf != "" && asOf.Format(time.DateOnly) != tt.asOf {
+				t.Errorf("As-of mismatch: got %v, expected %s", asOf, tt.asOf)
+			}
+		})
+	}
+
+	// A Fidelity export isn't mistaken for one
+	file, err := os.Open(filepath.Join("tests", "portfolios", "fidelity_export.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if presetReader(bufio.NewReader(file)) != nil {
+		t.Error("Expected fidelity_export.csv not to be recognized as a broker preset")
+	}
+}
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		value    string
+		comma    bool
+		expected float64
+	}{
+		{"1,234.56", false, 1234.56},
+		{"1.234,56", false, 1234.56},
+		{"1.234,56", true, 1234.56},
+		{"105,20", true, 105.20},
+		{"1,234", false, 1234},
+		{"1 234,5", true, 1234.5},
+		{"-12.5", true, -12.5},
+		{"1,234,567", true, 1234567},
+	}
+	for _, tt := range tests {
+		got, err := parseDecimal(tt.value, tt.comma)
+		if err != nil || got != tt.expected {
+			t.Errorf("parseDecimal(%q, %v) = %v, %v; expected %v", tt.value, tt.comma, got, err, tt.expected)
+		}
+	}
+	if _, err := parseDecimal("n/a", true); err == nil {
+		t.Error("Expected an error for a value that isn't a number")
+	}
+}
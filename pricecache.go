@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// cacheDir is where downloaded data is kept for -offline, overridden in tests
+var cacheDir = func() (string, error) {
+	dirs, err := homeDirs()
+	return dirs.Cache, err
+}
+
+// CachedHistory is the daily closes last downloaded for a symbol
+type CachedHistory struct {
+	Symbol    string      `json:"symbol"`
+	FetchedAt time.Time   `json:"fetched_at"`
+	Closes    DailyCloses `json:"closes"`
+}
+
+// historyCachePath is kept apart for each price source, so switching sources
+// never mixes their closes
+func historyCachePath(config *Config, symbol string) (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "history", config.Prices.cacheKey(), url.PathEscape(symbol)+".json"), nil
+}
+
+func writeHistoryCache(config *Config, symbol string, closes DailyCloses, now time.Time) error {
+	path, err := historyCachePath(config, symbol)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(CachedHistory{Symbol: symbol, FetchedAt: now, Closes: closes})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// readHistoryCache returns the cached history for symbol, or nil if there is none
+func readHistoryCache(config *Config, symbol string) (*CachedHistory, error) {
+	path, err := historyCachePath(config, symbol)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var cached CachedHistory
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &cached, nil
+}
+
+// cachedHistory loads the monthly history of every symbol from the cache, warning on
+// stderr about history older than stale_after. It fails listing every symbol
+// that was never downloaded.
+func cachedHistory(config *Config, symbols []string, now time.Time) (PriceHistory, error) {
+	history := make(PriceHistory)
+	var missing []string
+	for _, symbol := range symbols {
+		cached, err := readHistoryCache(config, symbol)
+		if err != nil {
+			return nil, err
+		}
+		if cached == nil {
+			missing = append(missing, symbol)
+			continue
+		}
+		if age := now.Sub(cached.FetchedAt); age > config.staleAfter() {
+			fmt.Fprintln(os.Stderr, red(fmt.Sprintf("Warning: cached prices for %s are from %s (%d days old)", symbol, cached.FetchedAt.Format(time.DateOnly), int(age.Hours()/24))))
+		}
+		history[symbol] = monthlyCloses(cached.Closes)
+	}
+	if len(missing) > 0 {
+		return nil, codedErrorf(CodeOffline, "no cached price history for %s; run risk once without -offline or pass -history", strings.Join(missing, ", "))
+	}
+	return history, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCachedHistory(t *testing.T) {
+	dir := t.TempDir()
+	defer func(f func() (string, error)) { cacheDir = f }(cacheDir)
+	cacheDir = func() (string, error) { return dir, nil }
+
+	now := time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)
+	config := &Config{}
+	if err := writeHistoryCache(config, "VTI", DailyCloses{"2026-08-31": 104.5, "2026-09-29": 107, "2026-09-30": 108.25}, now); err != nil {
+		t.Fatalf("writeHistoryCache failed: %v", err)
+	}
+	if err := writeHistoryCache(config, "BRK/B", DailyCloses{"2026-09-30": 450}, now); err != nil {
+		t.Fatalf("writeHistoryCache failed: %v", err)
+	}
+
+	history, err := cachedHistory(config, []string{"VTI", "BRK/B"}, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("cachedHistory failed: %v", err)
+	}
+	if history["VTI"]["2026-08"] != 104.5 || history["VTI"]["2026-09"] != 108.25 || history["BRK/B"]["2026-09"] != 450 {
+		t.Errorf("History mismatch: got %v", history)
+	}
+
+	// Another source has its own cache
+	unadjusted := false
+	other := &Config{Prices: &PricesConfig{Source: "tiingo", Adjusted: &unadjusted}}
+	if _, err := cachedHistory(other, []string{"VTI"}, now); errorCode(err) != CodeOffline {
+		t.Errorf("Expected %s for another source's cache, got %v", CodeOffline, err)
+	}
+
+	_, err = cachedHistory(config, []string{"SPY", "VTI", "BND"}, now)
+	if errorCode(err) != CodeOffline || err.Error() != "no cached price history for SPY, BND; run risk once without -offline or pass -history" {
+		t.Errorf("Expected the uncached symbols, got %v", err)
+	}
+}
+
+func TestOfflineTransport(t *testing.T) {
+	defer func(transport http.RoundTripper) { httpClient.Transport = transport }(httpClient.Transport)
+	httpClient.Transport = offlineTransport{}
+	_, err := stooqSource{}.Daily(context.Background(), "VTI", time.Now())
+	if errorCode(err) != CodeOffline {
+		t.Errorf("Expected %s, got %v", CodeOffline, err)
+	}
+}
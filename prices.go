@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// historyAPI serves daily closing prices as CSV, overridden in tests
+var historyAPI = "https://stooq.com/q/d/l/"
+
+// tiingoAPI serves daily prices as JSON, overridden in tests
+var tiingoAPI = "https://api.tiingo.com/tiingo/daily/"
+
+var priceSources = []string{"stooq", "tiingo", "csv"}
+
+// PricesConfig chooses where daily price history comes from
+type PricesConfig struct {
+	// Source is stooq (default), tiingo, or csv
+	Source string `yaml:"source,omitempty"`
+	// Adjusted uses closes adjusted for splits and dividends (default true)
+	Adjusted *bool `yaml:"adjusted,omitempty"`
+	// Dir holds a <symbol>.csv of daily prices per symbol for the csv source
+	Dir string `yaml:"dir,omitempty"`
+	// TokenEnv names the environment variable holding the Tiingo API token
+	// (default TIINGO_TOKEN)
+	TokenEnv string `yaml:"token_env,omitempty"`
+}
+
+// DailyCloses maps date ("2006-01-02") -> closing price
+type DailyCloses map[string]float64
+
+// PriceSource provides daily closes for a symbol since start
+type PriceSource interface {
+	Daily(ctx context.Context, symbol string, start time.Time) (DailyCloses, error)
+}
+
+func validatePrices(config *Config) error {
+	p := config.Prices
+	if p == nil {
+		return nil
+	}
+	if p.Source != "" && !slices.Contains(priceSources, p.Source) {
+		return fmt.Errorf("unknown prices source %q (expected one of %s)", p.Source, strings.Join(priceSources, ", "))
+	}
+	if p.source() == "csv" && p.Dir == "" {
+		return fmt.Errorf("prices source csv needs a dir")
+	}
+	return nil
+}
+
+func (p *PricesConfig) source() string {
+	if p == nil || p.Source == "" {
+		return "stooq"
+	}
+	return p.Source
+}
+
+func (p *PricesConfig) adjusted() bool {
+	return p == nil || p.Adjusted == nil || *p.Adjusted
+}
+
+// cacheKey separates cached history by source and adjustment, since their
+// closes differ
+func (p *PricesConfig) cacheKey() string {
+	if !p.adjusted() {
+		return p.source() + "-unadjusted"
+	}
+	return p.source()
+}
+
+// priceSource returns the configured source of daily prices
+func (c *Config) priceSource() PriceSource {
+	switch c.Prices.source() {
+	case "tiingo":
+		env := c.Prices.TokenEnv
+		if env == "" {
+			env = "TIINGO_TOKEN"
+		}
+		return tiingoSource{token: os.Getenv(env), tokenEnv: env, adjusted: c.Prices.adjusted()}
+	case "csv":
+		return csvSource{dir: c.Prices.Dir, adjusted: c.Prices.adjusted()}
+	}
+	return stooqSource{}
+}
+
+// priceHistory returns monthly closes for symbols since start from the
+// configured source, caching what it downloads. Under -offline, downloaded
+// sources are read from the cache instead. Closes of stocks quoted in other
+// currencies are converted to USD.
+func priceHistory(ctx context.Context, config *Config, symbols []string, start time.Time) (PriceHistory, error) {
+	history, err := sourceHistory(ctx, config, symbols, start)
+	if err != nil {
+		return nil, err
+	}
+	if err := convertHistory(ctx, config, history, start); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+func sourceHistory(ctx context.Context, config *Config, symbols []string, start time.Time) (PriceHistory, error) {
+	if offline && config.Prices.source() != "csv" {
+		return cachedHistory(config, symbols, time.Now())
+	}
+	source := config.priceSource()
+	daily, err := fetchAll(ctx, config.fetchPolicy(config.Prices.source()), symbols, func(ctx context.Context, symbol string) (DailyCloses, error) {
+		closes, err := source.Daily(ctx, symbol, start)
+		if err == nil && config.Prices.source() != "csv" {
+			// The cache only serves -offline, so failing to write it isn't fatal
+			if err := writeHistoryCache(config, symbol, closes, time.Now()); err != nil {
+				fmt.Fprintln(os.Stderr, "Warning: caching prices:", err)
+			}
+		}
+		return closes, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loading prices for %w (pass -history to supply them)", err)
+	}
+	history := make(PriceHistory)
+	for symbol, closes := range daily {
+		history[symbol] = monthlyCloses(closes)
+	}
+	return history, nil
+}
+
+// monthlyCloses keeps the last close of each month, keyed "2006-01"
+func monthlyCloses(daily DailyCloses) map[string]float64 {
+	monthly := make(map[string]float64)
+	latest := make(map[string]string)
+	for date, price := range daily {
+		month := date[:min(len(date), 7)]
+		if date >= latest[month] {
+			latest[month] = date
+			monthly[month] = price
+		}
+	}
+	return monthly
+}
+
+// stooqSource downloads from Stooq, whose closes are already adjusted for
+// splits and dividends. Symbols without an exchange suffix are looked up as
+// US listings.
+type stooqSource struct{}
+
+func (stooqSource) Daily(ctx context.Context, symbol string, start time.Time) (DailyCloses, error) {
+	ticker := strings.ToLower(symbol)
+	if !strings.Contains(ticker, ".") {
+		ticker += ".us"
+	}
+	query := url.Values{"s": {ticker}, "i": {"d"}, "d1": {start.Format("20060102")}}
+	body, err := getPrices(ctx, historyAPI+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return readDailyCloses(body, true)
+}
+
+// tiingoSource downloads from Tiingo, which needs an API token
+type tiingoSource struct {
+	token, tokenEnv string
+	adjusted        bool
+}
+
+func (s tiingoSource) Daily(ctx context.Context, symbol string, start time.Time) (DailyCloses, error) {
+	if s.token == "" {
+		return nil, fmt.Errorf("set %s to a Tiingo API token", s.tokenEnv)
+	}
+	query := url.Values{"startDate": {start.Format(time.DateOnly)}}
+	body, err := getPrices(ctx, tiingoAPI+url.PathEscape(strings.ToLower(symbol))+"/prices?"+query.Encode(), http.Header{"Authorization": {"Token " + s.token}})
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	var rows []struct {
+		Date     string  `json:"date"`
+		Close    float64 `json:"close"`
+		AdjClose float64 `json:"adjClose"`
+	}
+	if err := json.NewDecoder(body).Decode(&rows); err != nil {
+		return nil, fmt.Errorf("invalid Tiingo response: %w", err)
+	}
+	closes := make(DailyCloses)
+	for _, row := range rows {
+		price := row.Close
+		if s.adjusted {
+			price = row.AdjClose
+		}
+		closes[row.Date[:min(len(row.Date), 10)]] = price
+	}
+	if len(closes) == 0 {
+		return nil, errors.New("no price data")
+	}
+	return closes, nil
+}
+
+// csvSource reads <dir>/<symbol>.csv files with a Date column and a Close or
+// Adj Close column, such as those exported by Yahoo Finance
+type csvSource struct {
+	dir      string
+	adjusted bool
+}
+
+func (s csvSource) Daily(ctx context.Context, symbol string, start time.Time) (DailyCloses, error) {
+	file, err := os.Open(filepath.Join(s.dir, symbol+".csv"))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	closes, err := readDailyCloses(file, s.adjusted)
+	if err != nil {
+		return nil, err
+	}
+	for date := range closes {
+		if date < start.Format(time.DateOnly) {
+			delete(closes, date)
+		}
+	}
+	return closes, nil
+}
+
+// getPrices makes a GET request through httpClient and returns the body of a
+// successful response
+func getPrices(ctx context.Context, url string, header http.Header) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, &httpStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return resp.Body, nil
+}
+
+// readDailyCloses parses a CSV with a Date column and a Close column, using
+// Adj Close instead when adjusted and the file has one
+func readDailyCloses(r io.Reader, adjusted bool) (DailyCloses, error) {
+	reader := newCSVReader(r)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("no price data")
+	} else if err != nil {
+		return nil, err
+	}
+	header = normalizeHeader(header)
+	dateIdx := slices.Index(header, "Date")
+	closeIdx := slices.Index(header, "Close")
+	if adjIdx := slices.Index(header, "Adj Close"); adjusted && adjIdx >= 0 {
+		closeIdx = adjIdx
+	}
+	if dateIdx < 0 || closeIdx < 0 {
+		return nil, codedErrorf(CodeCSVHeader, "price CSV must have 'Date' and 'Close' columns (got %q)", strings.Join(header, ","))
+	}
+
+	closes := make(DailyCloses)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		dateStr, value := field(record, dateIdx), strings.TrimPrefix(field(record, closeIdx), "$")
+		if dateStr == "" || value == "" || value == "null" {
+			continue
+		}
+		date, err := parseCSVDate(dateStr)
+		if err != nil {
+			return nil, err
+		}
+		price, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", ""), 64)
+		if err != nil {
+			return nil, codedErrorf(CodeCSVValue, "invalid price %q on %s", value, dateStr)
+		}
+		closes[date.Format(time.DateOnly)] = price
+	}
+	if len(closes) == 0 {
+		return nil, errors.New("no price data")
+	}
+	return closes, nil
+}
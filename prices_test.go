@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStooqSource(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Write([]byte("Date,Open,High,Low,Close,Volume\n2026-09-29,100,105,99,104.50,1000\n2026-09-30,104,110,103,108.25,1200\n"))
+	}))
+	defer server.Close()
+	defer func(api string) { historyAPI = api }(historyAPI)
+	historyAPI = server.URL
+
+	closes, err := stooqSource{}.Daily(context.Background(), "VTI", time.Date(2026, time.September, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Daily failed: %v", err)
+	}
+	if query != "d1=20260901&i=d&s=vti.us" {
+		t.Errorf("Query mismatch: got %s", query)
+	}
+	if closes["2026-09-29"] != 104.50 || closes["2026-09-30"] != 108.25 {
+		t.Errorf("Closes mismatch: got %v", closes)
+	}
+}
+
+func TestStooqSourceInterrupted(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+	defer func(api string) { historyAPI = api }(historyAPI)
+	historyAPI = server.URL
+	start := time.Date(2026, time.August, 1, 0, 0, 0, 0, time.UTC)
+	source := stooqSource{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := source.Daily(ctx, "VTI", start); errorCode(err) != CodeCanceled {
+		t.Errorf("Expected %s, got %v", CodeCanceled, err)
+	}
+
+	defer func(timeout time.Duration) { httpClient.Timeout = timeout }(httpClient.Timeout)
+	httpClient.Timeout = 10 * time.Millisecond
+	if _, err := source.Daily(context.Background(), "VTI", start); errorCode(err) != CodeTimeout {
+		t.Errorf("Expected %s, got %v", CodeTimeout, err)
+	}
+}
+
+func TestTiingoSource(t *testing.T) {
+	var path, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.RequestURI(), r.Header.Get("Authorization")
+		w.Write([]byte(`[{"date":"2026-09-29T00:00:00.000Z","close":210,"adjClose":105},{"date":"2026-09-30T00:00:00.000Z","close":216,"adjClose":108}]`))
+	}))
+	defer server.Close()
+	defer func(api string) { tiingoAPI = api }(tiingoAPI)
+	tiingoAPI = server.URL + "/"
+	start := time.Date(2026, time.September, 1, 0, 0, 0, 0, time.UTC)
+
+	// Adjusted closes account for the 2-for-1 split
+	closes, err := tiingoSource{token: "secret", tokenEnv: "TIINGO_TOKEN", adjusted: true}.Daily(context.Background(), "VTI", start)
+	if err != nil {
+		t.Fatalf("Daily failed: %v", err)
+	}
+	if path != "/vti/prices?startDate=2026-09-01" || auth != "Token secret" {
+		t.Errorf("Request mismatch: got %s with %q", path, auth)
+	}
+	if closes["2026-09-29"] != 105 || closes["2026-09-30"] != 108 {
+		t.Errorf("Closes mismatch: got %v", closes)
+	}
+
+	closes, err = tiingoSource{token: "secret", tokenEnv: "TIINGO_TOKEN"}.Daily(context.Background(), "VTI", start)
+	if err != nil || closes["2026-09-30"] != 216 {
+		t.Errorf("Expected unadjusted closes, got %v (%v)", closes, err)
+	}
+
+	if _, err := (tiingoSource{tokenEnv: "TIINGO_TOKEN"}).Daily(context.Background(), "VTI", start); err == nil {
+		t.Error("Expected an error without a token")
+	}
+}
+
+func TestCSVSourcePriceHistory(t *testing.T) {
+	dir := t.TempDir()
+	csv := "Date,Open,High,Low,Close,Adj Close,Volume\n2026-07-31,1,1,1,100,95,1\n2026-08-28,1,1,1,102,97,1\n2026-08-31,1,1,1,104,99,1\n2026-09-30,1,1,1,null,null,1\n"
+	if err := os.WriteFile(filepath.Join(dir, "VTI.csv"), []byte(csv), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{Prices: &PricesConfig{Source: "csv", Dir: dir}}
+	if err := validatePrices(config); err != nil {
+		t.Fatalf("validatePrices failed: %v", err)
+	}
+
+	// The csv source is local, so it is read even with -offline
+	defer func(o bool) { offline = o }(offline)
+	offline = true
+	history, err := priceHistory(context.Background(), config, []string{"VTI"}, time.Date(2026, time.August, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("priceHistory failed: %v", err)
+	}
+	monthly := history["VTI"]
+	if len(monthly) != 1 || monthly["2026-08"] != 99 {
+		t.Errorf("Monthly closes mismatch: got %v, expected the adjusted August month-end only", monthly)
+	}
+
+	if _, err := priceHistory(context.Background(), config, []string{"BND"}, time.Time{}); err == nil {
+		t.Error("Expected an error for a symbol without a file")
+	}
+}
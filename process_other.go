@@ -0,0 +1,9 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package main
+
+// processExists can't check for a process on this platform, so a lock is
+// only taken over once it's stale
+func processExists(pid int) bool {
+	return true
+}
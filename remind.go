@@ -0,0 +1,232 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+var remindCadences = []string{"monthly", "quarterly", "annually", "band"}
+
+// remindMonths is the months between reminders of each calendar cadence
+var remindMonths = map[string]int{"monthly": 1, "quarterly": 3, "annually": 12}
+
+const (
+	defaultRemindBand = 5.0
+	// defaultRemindVolatility is the annual volatility, in percent, of one
+	// holding's return relative to the rest of the portfolio
+	defaultRemindVolatility = 15.0
+)
+
+// RemindConfig is how often to check the portfolio for drift
+type RemindConfig struct {
+	// Cadence is monthly, quarterly (default), annually, or band, which
+	// estimates when drift will first reach Band from Volatility
+	Cadence string `yaml:"cadence,omitempty"`
+	// Band is the drift, in percentage points, that calls for a rebalance (default 5)
+	Band float64 `yaml:"band,omitempty"`
+	// Volatility is the annual volatility, in percent, of a holding's return
+	// relative to the rest of the portfolio (default 15)
+	Volatility float64 `yaml:"volatility,omitempty"`
+}
+
+type Reminder struct {
+	Date time.Time `json:"date"`
+	// Sleeve is set for the check of a sleeve with its own cadence
+	Sleeve string `json:"sleeve,omitempty"`
+	// Reason explains how the date was chosen
+	Reason string `json:"reason"`
+}
+
+func validateRemind(config *Config) error {
+	r := config.Remind
+	if r == nil {
+		return nil
+	}
+	if r.Cadence != "" && !slices.Contains(remindCadences, r.Cadence) {
+		return fmt.Errorf("unknown remind cadence %q (expected one of %s)", r.Cadence, strings.Join(remindCadences, ", "))
+	}
+	if r.Band < 0 || r.Volatility < 0 {
+		return fmt.Errorf("remind band and volatility must not be negative")
+	}
+	return nil
+}
+
+func (r *RemindConfig) cadence() string {
+	if r == nil || r.Cadence == "" {
+		return "quarterly"
+	}
+	return r.Cadence
+}
+
+func (r *RemindConfig) band() float64 {
+	if r == nil || r.Band == 0 {
+		return defaultRemindBand
+	}
+	return r.Band
+}
+
+func (r *RemindConfig) volatility() float64 {
+	if r == nil || r.Volatility == 0 {
+		return defaultRemindVolatility
+	}
+	return r.Volatility
+}
+
+// driftSpread is the standard deviation, in percentage points, of a year's
+// drift of a holding at target percent: its weight times one minus it times
+// the volatility of its return relative to the rest of the portfolio
+func (r *RemindConfig) driftSpread(target float64) float64 {
+	weight := target / 100
+	return weight * (1 - weight) * r.volatility()
+}
+
+func remind(config *Config, args []string) {
+	var count int
+	var portfolioCsv, startStr, format, outputPath string
+	flagSet := flag.NewFlagSet("remind", flag.ExitOnError)
+	flagSet.IntVar(&count, "count", 4, "Number of reminders")
+	flagSet.StringVar(&portfolioCsv, "portfolio", "", "Portfolio CSV whose current drift moves the first band check earlier")
+	flagSet.StringVar(&startStr, "start", time.Now().Format(time.DateOnly), "Date to schedule reminders after (YYYY-MM-DD)")
+	flagSet.StringVar(&format, "format", "text", "Output format: text or ics")
+	flagSet.StringVar(&outputPath, "o", "", "Write the reminders to a file instead of stdout")
+	flagSet.Parse(args)
+
+	start, err := time.Parse(time.DateOnly, startStr)
+	if err != nil {
+		printError(codedErrorf(CodeUsage, "parsing start date: %w", err))
+		return
+	}
+	var holdings *Holdings
+	if portfolioCsv != "" {
+		if holdings, err = loadPortfolio(config, portfolioCsv); err != nil {
+			printError(err)
+			return
+		}
+	}
+
+	reminders, err := remindCalc(config, holdings, start, count)
+	if err != nil {
+		printError(err)
+		return
+	}
+
+	err = writeOutput(outputPath, func(out io.Writer) error {
+		switch format {
+		case "text":
+			for _, reminder := range reminders {
+				fmt.Fprintf(out, "%s  %s\n", reminder.Date.Format(time.DateOnly), reminder.Reason)
+			}
+			return nil
+		case "ics":
+			return writeICS(out, remindEvents(config, reminders))
+		}
+		return codedErrorf(CodeUsage, "unknown format %q", format)
+	})
+	if err != nil {
+		printError(err)
+	}
+}
+
+// remindCalc schedules count reminders after start. Calendar cadences repeat
+// at a fixed interval. The band cadence repeats at the time drift from
+// target is expected to take to reach the band; with holdings, the first
+// reminder counts only the drift still left before the band. Sleeves with a
+// cadence of their own get count reminders each as well.
+func remindCalc(config *Config, holdings *Holdings, start time.Time, count int) ([]Reminder, error) {
+	if count < 1 {
+		return nil, codedErrorf(CodeUsage, "count must be at least 1, got %d", count)
+	}
+	reminders := portfolioReminders(config, holdings, start, count)
+	for _, sleeve := range config.Sleeves {
+		if sleeve.Cadence == "" {
+			continue
+		}
+		for i := 1; i <= count; i++ {
+			reminders = append(reminders, Reminder{
+				Date:   start.AddDate(0, remindMonths[sleeve.Cadence]*i, 0),
+				Sleeve: sleeve.Name,
+				Reason: fmt.Sprintf("%s rebalance check of the %s sleeve", sleeve.Cadence, sleeve.Name),
+			})
+		}
+	}
+	slices.SortStableFunc(reminders, func(a, b Reminder) int { return a.Date.Compare(b.Date) })
+	return reminders, nil
+}
+
+func portfolioReminders(config *Config, holdings *Holdings, start time.Time, count int) []Reminder {
+	cadence := config.Remind.cadence()
+	var reminders []Reminder
+	if months, ok := remindMonths[cadence]; ok {
+		for i := 1; i <= count; i++ {
+			reminders = append(reminders, Reminder{Date: start.AddDate(0, months*i, 0), Reason: cadence + " rebalance check"})
+		}
+		return reminders
+	}
+
+	band := config.Remind.band()
+	interval := bandBreachTime(config, nil, band)
+	first := interval
+	if holdings != nil {
+		first = bandBreachTime(config, holdings, band)
+	}
+	date := start.Add(first)
+	reason := fmt.Sprintf("expected %g-point band breach", band)
+	for range count {
+		reminders = append(reminders, Reminder{Date: date, Reason: reason})
+		date = date.Add(interval)
+	}
+	return reminders
+}
+
+// bandBreachTime estimates how long until some symbol drifts by band
+// percentage points. A weight w moves by about w(1-w) times the holding's
+// return relative to the rest of the portfolio, so drift grows with the
+// square root of time at w(1-w) times the configured volatility per year.
+// The estimate is clamped to between a week and a year.
+func bandBreachTime(config *Config, holdings *Holdings, band float64) time.Duration {
+	total := 0
+	if holdings != nil {
+		for _, stock := range config.Stocks {
+			total += holdings.Amounts[stock.Symbol]
+		}
+	}
+	years := 1.0
+	for _, stock := range config.Stocks {
+		remaining := band
+		if total > 0 {
+			current := float64(holdings.Amounts[stock.Symbol]) / float64(total) * 100
+			remaining = max(band-math.Abs(current-stock.TargetPercentage), 0)
+		}
+		spread := config.Remind.driftSpread(stock.TargetPercentage)
+		if spread <= 0 {
+			continue
+		}
+		years = min(years, math.Pow(remaining/spread, 2))
+	}
+	return max(time.Duration(years*365*24)*time.Hour, 7*24*time.Hour).Round(24 * time.Hour)
+}
+
+func remindEvents(config *Config, reminders []Reminder) []calendarEvent {
+	events := make([]calendarEvent, 0, len(reminders))
+	for _, reminder := range reminders {
+		event := calendarEvent{
+			UID:         fmt.Sprintf("remind-%s@fin-tilt", reminder.Date.Format("20060102")),
+			Date:        reminder.Date,
+			Summary:     "Check portfolio drift",
+			Description: fmt.Sprintf("Run fin-tilt rebalance (%s) and trade if any symbol is more than %g points from target.", reminder.Reason, config.Remind.band()),
+		}
+		if reminder.Sleeve != "" {
+			i := slices.IndexFunc(config.Sleeves, func(s SleeveConfig) bool { return s.Name == reminder.Sleeve })
+			event.UID = fmt.Sprintf("remind-%s-%s@fin-tilt", reminder.Sleeve, reminder.Date.Format("20060102"))
+			event.Summary = "Check " + reminder.Sleeve + " sleeve drift"
+			event.Description = fmt.Sprintf("Run fin-tilt rebalance (%s) and trade the %s sleeve if it is outside its %g-point band.", reminder.Reason, reminder.Sleeve, config.Sleeves[i].band())
+		}
+		events = append(events, event)
+	}
+	return events
+}
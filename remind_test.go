@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRemindCalc(t *testing.T) {
+	start := time.Date(2026, time.January, 31, 0, 0, 0, 0, time.UTC)
+	stocks := []Stock{{Symbol: "VTI", TargetPercentage: 50}, {Symbol: "BND", TargetPercentage: 50}}
+
+	t.Run("quarterly by default", func(t *testing.T) {
+		reminders, err := remindCalc(&Config{Stocks: stocks}, nil, start, 4)
+		if err != nil {
+			t.Fatalf("remindCalc failed: %v", err)
+		}
+		if len(reminders) != 4 {
+			t.Fatalf("Reminder count mismatch: got %d, expected 4", len(reminders))
+		}
+		if expected := time.Date(2027, time.January, 31, 0, 0, 0, 0, time.UTC); !reminders[3].Date.Equal(expected) {
+			t.Errorf("Last reminder mismatch: got %s, expected %s", reminders[3].Date, expected)
+		}
+	})
+
+	t.Run("band breach estimated from volatility", func(t *testing.T) {
+		// 0.5 * 0.5 * 15% = 3.75% a year, so 2 points takes (2/3.75)^2 years
+		config := &Config{Stocks: stocks, Remind: &RemindConfig{Cadence: "band", Band: 2}}
+		reminders, err := remindCalc(config, nil, start, 2)
+		if err != nil {
+			t.Fatalf("remindCalc failed: %v", err)
+		}
+		if days := reminders[0].Date.Sub(start).Hours() / 24; days != 104 {
+			t.Errorf("First reminder mismatch: got %v days, expected 104", days)
+		}
+		if days := reminders[1].Date.Sub(reminders[0].Date).Hours() / 24; days != 104 {
+			t.Errorf("Interval mismatch: got %v days, expected 104", days)
+		}
+
+		// One point of drift already leaves (1/3.75)^2 years until the band
+		holdings := &Holdings{Amounts: map[string]int{"VTI": 5100, "BND": 4900}}
+		reminders, err = remindCalc(config, holdings, start, 2)
+		if err != nil {
+			t.Fatalf("remindCalc failed: %v", err)
+		}
+		if days := reminders[0].Date.Sub(start).Hours() / 24; days != 26 {
+			t.Errorf("First reminder with drift mismatch: got %v days, expected 26", days)
+		}
+		if days := reminders[1].Date.Sub(reminders[0].Date).Hours() / 24; days != 104 {
+			t.Errorf("Interval after drift mismatch: got %v days, expected 104", days)
+		}
+	})
+
+	t.Run("band breach clamped to a year", func(t *testing.T) {
+		config := &Config{Stocks: stocks, Remind: &RemindConfig{Cadence: "band"}}
+		reminders, err := remindCalc(config, nil, start, 1)
+		if err != nil {
+			t.Fatalf("remindCalc failed: %v", err)
+		}
+		if days := reminders[0].Date.Sub(start).Hours() / 24; days != 365 {
+			t.Errorf("Reminder mismatch: got %v days, expected 365", days)
+		}
+	})
+
+	t.Run("invalid count", func(t *testing.T) {
+		if _, err := remindCalc(&Config{Stocks: stocks}, nil, start, 0); errorCode(err) != CodeUsage {
+			t.Errorf("Expected %s, got %v", CodeUsage, err)
+		}
+	})
+}
+
+func TestValidateRemind(t *testing.T) {
+	if err := validateRemind(&Config{Remind: &RemindConfig{Cadence: "weekly"}}); err == nil {
+		t.Error("Expected error for unknown cadence")
+	}
+	if err := validateRemind(&Config{Remind: &RemindConfig{Band: -1}}); err == nil {
+		t.Error("Expected error for negative band")
+	}
+}
+
+func TestRemindEvents(t *testing.T) {
+	config := &Config{}
+	reminders := []Reminder{{Date: time.Date(2026, time.April, 30, 0, 0, 0, 0, time.UTC), Reason: "quarterly rebalance check"}}
+	var buf bytes.Buffer
+	if err := writeICS(&buf, remindEvents(config, reminders)); err != nil {
+		t.Fatalf("writeICS failed: %v", err)
+	}
+	if out := buf.String(); !strings.Contains(out, "UID:remind-20260430@fin-tilt") || !strings.Contains(out, "DTSTART;VALUE=DATE:20260430") {
+		t.Errorf("Calendar mismatch: got %s", out)
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CashFlow is money moved into (positive) or out of (negative) an account
+type CashFlow struct {
+	Date    time.Time `json:"date"`
+	Account string    `json:"account,omitempty"`
+	Amount  int       `json:"amount"`
+}
+
+// Valuation is the value of an account, or the whole portfolio, at a point in time
+type Valuation struct {
+	Date  time.Time `json:"date"`
+	Value int       `json:"value"`
+}
+
+type ReturnResult struct {
+	Account    string    `json:"account"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	StartValue int       `json:"start_value"`
+	EndValue   int       `json:"end_value"`
+	NetFlows   int       `json:"net_flows"`
+	// IRR is the annualized money-weighted return
+	IRR float64 `json:"irr"`
+	// TWR is the cumulative time-weighted return, and TWRAnnualized the same
+	// return per year
+	TWR           float64 `json:"twr"`
+	TWRAnnualized float64 `json:"twr_annualized"`
+}
+
+func returns(ctx context.Context, config *Config, args []string) {
+	var flowsCsv, historyCsv string
+	flagSet := flag.NewFlagSet("returns", flag.ExitOnError)
+	flagSet.StringVar(&flowsCsv, "flows", "", "CSV of deposits and withdrawals (Date, Amount, and optional Account columns), in addition to imported transactions")
+	flagSet.StringVar(&historyCsv, "history", "", "CSV of monthly closing prices for the benchmark instead of the price history cache")
+	var portfolioCsv string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		portfolioCsv = args[0]
+		args = args[1:]
+	}
+	flagSet.Parse(args)
+
+	var snapshots []Snapshot
+	if config.Snapshots != "" {
+		var err error
+		if snapshots, err = readSnapshots(config.Snapshots); err != nil {
+			printError(fmt.Errorf("reading snapshots: %w", err))
+			return
+		}
+	}
+	if portfolioCsv != "" {
+		holdings, err := loadPortfolio(config, portfolioCsv)
+		if err != nil {
+			printError(err)
+			return
+		}
+		if len(snapshots) == 0 || holdings.AsOf.After(snapshots[len(snapshots)-1].AsOf) {
+			snapshots = append(snapshots, *newSnapshot(holdings))
+		}
+	}
+	if len(snapshots) < 2 {
+		printError(codedErrorf(CodeUsage, "returns need at least two valuations; record exports with the snapshot command or pass the current portfolio CSV"))
+		return
+	}
+
+	var flows []CashFlow
+	if flowsCsv != "" {
+		file, err := os.Open(flowsCsv)
+		if err != nil {
+			printError(err)
+			return
+		}
+		defer file.Close()
+		if flows, err = readCashFlows(file); err != nil {
+			printError(fmt.Errorf("reading cash flows: %w", err))
+			return
+		}
+	}
+
+	if config.Transactions != "" {
+		transactions, err := readTransactionLog(config.Transactions)
+		if err != nil {
+			printError(fmt.Errorf("reading transactions: %w", err))
+			return
+		}
+		for _, t := range transactions {
+			if flow, ok := t.flow(); ok {
+				flows = append(flows, flow)
+			}
+		}
+	}
+
+	results, err := returnsCalc(snapshots, flows)
+	if err != nil {
+		printError(err)
+		return
+	}
+	fmt.Printf("%-20s %-23s %14s %14s %9s %9s %9s\n", "Account", "Period", "Net Flows", "End Value", "IRR", "TWR", "TWR/yr")
+	fmt.Println(strings.Repeat("-", 105))
+	for _, result := range results {
+		period := result.Start.Format(time.DateOnly) + " to " + result.End.Format(time.DateOnly)
+		fmt.Printf("%-20s %-23s %14s %14s %8.2f%% %8.2f%% %8.2f%%\n", result.Account, period, formatAmount(result.NetFlows, true), formatAmount(result.EndValue, true), result.IRR*100, result.TWR*100, result.TWRAnnualized*100)
+	}
+	if config.Benchmark != nil {
+		printBenchmarkReturn(ctx, config, historyCsv, results[0])
+	}
+}
+
+// printBenchmarkReturn compares the portfolio's time-weighted return to the
+// benchmark's over the same period. Prices come from -history or the price
+// source; without them the comparison is skipped with a note.
+func printBenchmarkReturn(ctx context.Context, config *Config, historyCsv string, total ReturnResult) {
+	var history PriceHistory
+	var err error
+	if historyCsv != "" {
+		var file *os.File
+		if file, err = os.Open(historyCsv); err == nil {
+			defer file.Close()
+			history, err = readHistory(file, "")
+		}
+	} else {
+		// Start a month early for the close the first return is measured from
+		history, err = priceHistory(ctx, config, config.Benchmark.symbols(), total.Start.AddDate(0, -1, 0))
+	}
+	if err == nil {
+		var benchmark *BenchmarkReturn
+		if benchmark, err = benchmarkReturnCalc(config.Benchmark, history, total.Start, total.End); err == nil {
+			relative := signed((total.TWR-benchmark.Return)*100, "%", "ahead", "behind", "even")
+			fmt.Printf("\nBenchmark %s: %.2f%% from %s to %s (portfolio TWR %.2f%%, %s relative)\n", benchmark.Name, benchmark.Return*100, benchmark.Start, benchmark.End, total.TWR*100, relative)
+			return
+		}
+	}
+	fmt.Printf("\nBenchmark %s: not compared (%v)\n", config.Benchmark.label(), err)
+}
+
+// readCashFlows parses a CSV with Date and Amount columns and an optional
+// Account column. Flows without an account count only toward the total.
+func readCashFlows(r io.Reader) ([]CashFlow, error) {
+	reader := newCSVReader(r)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	header = normalizeHeader(header)
+	dateIndex := slices.Index(header, "Date")
+	amountIndex := slices.Index(header, "Amount")
+	accountIndex := slices.Index(header, "Account")
+	if dateIndex < 0 || amountIndex < 0 {
+		return nil, codedErrorf(CodeCSVHeader, "CSV file must have 'Date' and 'Amount' columns")
+	}
+
+	var flows []CashFlow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if field(record, dateIndex) == "" {
+			continue
+		}
+		date, err := parseCSVDate(field(record, dateIndex))
+		if err != nil {
+			return nil, err
+		}
+		amount, err := amountToInt(field(record, amountIndex))
+		if err != nil {
+			return nil, codedErrorf(CodeCSVValue, "error parsing amount on %s: %w", field(record, dateIndex), err)
+		}
+		flows = append(flows, CashFlow{Date: date, Account: field(record, accountIndex), Amount: amount})
+	}
+	return flows, nil
+}
+
+// returnsCalc computes returns for the whole portfolio and for each account
+// with at least two valuations. snapshots must be oldest first.
+func returnsCalc(snapshots []Snapshot, flows []CashFlow) ([]ReturnResult, error) {
+	var total []Valuation
+	byAccount := make(map[string][]Valuation)
+	for _, snapshot := range snapshots {
+		total = append(total, Valuation{Date: snapshot.AsOf, Value: snapshot.Total})
+		for account, value := range snapshot.Accounts {
+			if account != "" {
+				byAccount[account] = append(byAccount[account], Valuation{Date: snapshot.AsOf, Value: value})
+			}
+		}
+	}
+
+	result, err := accountReturns("Total", total, flows)
+	if err != nil {
+		return nil, err
+	}
+	results := []ReturnResult{result}
+
+	accounts := make([]string, 0, len(byAccount))
+	for account := range byAccount {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+	for _, account := range accounts {
+		if len(byAccount[account]) < 2 {
+			continue
+		}
+		var accountFlows []CashFlow
+		for _, flow := range flows {
+			if flow.Account == account {
+				accountFlows = append(accountFlows, flow)
+			}
+		}
+		result, err := accountReturns(account, byAccount[account], accountFlows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// accountReturns measures returns between the first and last valuations,
+// using flows dated after the first valuation and on or before the last
+func accountReturns(account string, valuations []Valuation, flows []CashFlow) (ReturnResult, error) {
+	first, last := valuations[0], valuations[len(valuations)-1]
+	result := ReturnResult{Account: account, Start: first.Date, End: last.Date, StartValue: first.Value, EndValue: last.Value}
+	years := last.Date.Sub(first.Date).Hours() / 24 / 365
+	if years <= 0 {
+		return result, fmt.Errorf("%s: valuations must span more than one point in time", account)
+	}
+
+	var period []CashFlow
+	for _, flow := range flows {
+		if flow.Date.After(first.Date) && !flow.Date.After(last.Date) {
+			period = append(period, flow)
+			result.NetFlows += flow.Amount
+		}
+	}
+
+	// Money-weighted: the starting value and deposits go in, the ending value comes out
+	dated := []CashFlow{{Date: first.Date, Amount: -first.Value}}
+	for _, flow := range period {
+		dated = append(dated, CashFlow{Date: flow.Date, Amount: -flow.Amount})
+	}
+	dated = append(dated, CashFlow{Date: last.Date, Amount: last.Value})
+	irr, err := xirr(dated)
+	if err != nil {
+		return result, fmt.Errorf("%s: %w", account, err)
+	}
+	result.IRR = irr
+
+	// Time-weighted: chain the Modified Dietz return of each period between valuations
+	growth := 1.0
+	for i := 1; i < len(valuations); i++ {
+		start, end := valuations[i-1], valuations[i]
+		days := end.Date.Sub(start.Date).Hours() / 24
+		netFlow, weighted := 0.0, 0.0
+		for _, flow := range period {
+			if flow.Date.After(start.Date) && !flow.Date.After(end.Date) {
+				netFlow += float64(flow.Amount)
+				weighted += float64(flow.Amount) * end.Date.Sub(flow.Date).Hours() / 24 / days
+			}
+		}
+		base := float64(start.Value) + weighted
+		if base <= 0 {
+			return result, fmt.Errorf("%s: no invested value between %s and %s", account, start.Date.Format(time.DateOnly), end.Date.Format(time.DateOnly))
+		}
+		growth *= 1 + (float64(end.Value)-float64(start.Value)-netFlow)/base
+	}
+	result.TWR = growth - 1
+	result.TWRAnnualized = math.Pow(growth, 1/years) - 1
+	return result, nil
+}
+
+// xirr finds the annual rate at which the present value of dated flows is
+// zero, by bisection since the present value falls as the rate rises
+func xirr(flows []CashFlow) (float64, error) {
+	presentValue := func(rate float64) float64 {
+		pv := 0.0
+		for _, flow := range flows {
+			years := flow.Date.Sub(flows[0].Date).Hours() / 24 / 365
+			pv += float64(flow.Amount) / math.Pow(1+rate, years)
+		}
+		return pv
+	}
+	low, high := -0.9999, 100.0
+	if presentValue(low)*presentValue(high) > 0 {
+		return 0, errors.New("money-weighted return has no solution for these flows")
+	}
+	for range 200 {
+		mid := (low + high) / 2
+		if presentValue(mid) > 0 {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	return (low + high) / 2, nil
+}
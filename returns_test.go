@@ -0,0 +1,68 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReturnsCalc(t *testing.T) {
+	file, err := os.Open(filepath.Join("tests", "portfolios", "flows.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	flows, err := readCashFlows(file)
+	if err != nil {
+		t.Fatalf("readCashFlows failed: %v", err)
+	}
+	if len(flows) != 3 || flows[0].Amount != 1000000 || flows[2].Amount != -200000 {
+		t.Fatalf("Flows mismatch: %+v", flows)
+	}
+
+	day := func(month time.Month, year int) time.Time { return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC) }
+	snapshots := []Snapshot{
+		{AsOf: day(time.January, 2025), Total: 10000000, Accounts: map[string]int{"Individual": 10000000}},
+		{AsOf: day(time.July, 2025), Total: 11500000, Accounts: map[string]int{"Individual": 11500000}},
+		{AsOf: day(time.January, 2026), Total: 17300000, Accounts: map[string]int{"Individual": 10000000, "Roth IRA": 7300000}},
+	}
+	results, err := returnsCalc(snapshots, flows)
+	if err != nil {
+		t.Fatalf("returnsCalc failed: %v", err)
+	}
+	if len(results) != 2 || results[0].Account != "Total" || results[1].Account != "Individual" {
+		t.Fatalf("Expected Total and Individual results, got %+v", results)
+	}
+
+	total := results[0]
+	if total.NetFlows != 1500000 || total.EndValue != 17300000 {
+		t.Errorf("Total mismatch: %+v", total)
+	}
+	if total.TWR <= 0 || total.IRR <= 0 {
+		t.Errorf("Expected positive returns, got TWR %f IRR %f", total.TWR, total.IRR)
+	}
+
+	// Individual: $100,000 grows to $115,000 by July after a $10,000 April
+	// deposit, then falls to $100,000 after a $2,000 withdrawal
+	individual := results[1]
+	weight := float64(day(time.July, 2025).Sub(day(time.April, 2025))) / float64(day(time.July, 2025).Sub(day(time.January, 2025)))
+	first := (115000.0 - 100000 - 10000) / (100000 + 10000*weight)
+	weight = float64(day(time.January, 2026).Sub(day(time.October, 2025))) / float64(day(time.January, 2026).Sub(day(time.July, 2025)))
+	second := (100000.0 - 115000 + 2000) / (115000 - 2000*weight)
+	if expected := (1+first)*(1+second) - 1; math.Abs(individual.TWR-expected) > 1e-9 {
+		t.Errorf("Individual TWR mismatch: got %f, expected %f", individual.TWR, expected)
+	}
+}
+
+func TestXIRR(t *testing.T) {
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	flows := []CashFlow{{Date: start, Amount: -10000}, {Date: start.AddDate(0, 0, 365), Amount: 11000}}
+	if rate, err := xirr(flows); err != nil || math.Abs(rate-0.1) > 1e-9 {
+		t.Errorf("xirr mismatch: got %f (%v), expected 0.1", rate, err)
+	}
+	if _, err := xirr([]CashFlow{{Date: start, Amount: 100}, {Date: start.AddDate(1, 0, 0), Amount: 100}}); err == nil {
+		t.Error("Expected error when every flow is positive")
+	}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// minRiskMonths is the shortest overlapping history worth measuring
+const minRiskMonths = 12
+
+// PriceHistory maps symbol -> month ("2006-01") -> closing price
+type PriceHistory map[string]map[string]float64
+
+// RiskMetrics are annualized volatility, worst peak-to-trough decline, and
+// beta versus the benchmark, computed from monthly returns
+type RiskMetrics struct {
+	Volatility  float64 `json:"volatility"`
+	MaxDrawdown float64 `json:"max_drawdown"`
+	Beta        float64 `json:"beta"`
+}
+
+type RiskReport struct {
+	Benchmark        string      `json:"benchmark"`
+	Start            string      `json:"start"`
+	End              string      `json:"end"`
+	Months           int         `json:"months"`
+	Current          RiskMetrics `json:"current"`
+	Target           RiskMetrics `json:"target"`
+	BenchmarkMetrics RiskMetrics `json:"benchmark_metrics"`
+}
+
+func risk(ctx context.Context, config *Config, args []string) {
+	var benchmarkSymbol, historyCsv string
+	var years int
+	flagSet := flag.NewFlagSet("risk", flag.ExitOnError)
+	flagSet.StringVar(&benchmarkSymbol, "benchmark", "", "Symbol to measure beta against (default: the config's benchmark, or SPY)")
+	flagSet.IntVar(&years, "years", 5, "Years of monthly history to use")
+	flagSet.StringVar(&historyCsv, "history", "", "CSV of monthly closing prices (Date column plus one column per symbol) instead of downloading them")
+	if len(args) < 1 {
+		flag.Usage()
+		return
+	}
+	portfolioCsv := args[0]
+	flagSet.Parse(args[1:])
+
+	holdings, err := loadPortfolio(config, portfolioCsv)
+	if err != nil {
+		printError(err)
+		return
+	}
+	current, target := riskWeights(config, holdings)
+	benchmark := riskBenchmark(config, benchmarkSymbol)
+	start := time.Now().AddDate(-years, -1, 0)
+
+	var history PriceHistory
+	if historyCsv != "" {
+		file, err := os.Open(historyCsv)
+		if err != nil {
+			printError(err)
+			return
+		}
+		defer file.Close()
+		history, err = readHistory(file, "")
+		if err != nil {
+			printError(fmt.Errorf("reading price history: %w", err))
+			return
+		}
+	} else {
+		history, err = priceHistory(ctx, config, riskSymbols(benchmark, current, target), start)
+		if err != nil {
+			printError(err)
+			return
+		}
+	}
+
+	report, err := riskCalc(current, target, history, benchmark, start.Format("2006-01"))
+	if err != nil {
+		printError(err)
+		return
+	}
+
+	fmt.Printf("Monthly returns from %s to %s (%d months), beta versus %s\n", report.Start, report.End, report.Months, report.Benchmark)
+	fmt.Println(rule())
+	fmt.Printf("%-10s %12s %14s %8s\n", "", "Volatility", "Max Drawdown", "Beta")
+	for _, row := range []struct {
+		name    string
+		metrics RiskMetrics
+	}{{"Current", report.Current}, {"Target", report.Target}, {report.Benchmark, report.BenchmarkMetrics}} {
+		fmt.Printf("%-10s %11.2f%% %13.2f%% %8.2f\n", row.name, row.metrics.Volatility*100, row.metrics.MaxDrawdown*100, row.metrics.Beta)
+	}
+}
+
+// riskWeights returns the current and target weight of each configured symbol
+func riskWeights(config *Config, holdings *Holdings) (current, target map[string]float64) {
+	current = make(map[string]float64)
+	target = make(map[string]float64)
+	total := 0
+	for _, stock := range config.Stocks {
+		total += holdings.Amounts[stock.Symbol]
+	}
+	for _, stock := range config.Stocks {
+		if total > 0 {
+			current[stock.Symbol] = float64(holdings.Amounts[stock.Symbol]) / float64(total)
+		}
+		target[stock.Symbol] = stock.TargetPercentage / 100
+	}
+	return current, target
+}
+
+// riskBenchmark is what risk measures beta against: the -benchmark symbol,
+// or else the config's benchmark that returns also compares against, or
+// else SPY
+func riskBenchmark(config *Config, symbol string) *BenchmarkConfig {
+	if symbol == "" && config.Benchmark != nil {
+		return config.Benchmark
+	}
+	if symbol == "" {
+		symbol = "SPY"
+	}
+	return &BenchmarkConfig{Composition: map[string]float64{symbol: 100}}
+}
+
+// riskSymbols lists the benchmark's symbols and then every other symbol with
+// a nonzero weight
+func riskSymbols(benchmark *BenchmarkConfig, weightSets ...map[string]float64) []string {
+	symbols := benchmark.symbols()
+	n := len(symbols)
+	for _, weights := range weightSets {
+		for symbol, weight := range weights {
+			if weight > 0 && !slices.Contains(symbols, symbol) {
+				symbols = append(symbols, symbol)
+			}
+		}
+	}
+	slices.Sort(symbols[n:])
+	return symbols
+}
+
+// readHistory parses closing prices keyed by month, keeping the last close
+// seen in each month. A file with a Close column (as downloaded) holds prices
+// for symbol; otherwise every column after Date is a symbol.
+func readHistory(r io.Reader, symbol string) (PriceHistory, error) {
+	reader := newCSVReader(r)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("no price data")
+	} else if err != nil {
+		return nil, err
+	}
+	header = normalizeHeader(header)
+	dateIdx := slices.Index(header, "Date")
+	if dateIdx < 0 {
+		return nil, codedErrorf(CodeCSVHeader, "missing Date column (got %q)", strings.Join(header, ","))
+	}
+	columns := make(map[int]string)
+	if closeIdx := slices.Index(header, "Close"); closeIdx >= 0 {
+		columns[closeIdx] = symbol
+	} else {
+		for i, name := range header {
+			if i != dateIdx && name != "" {
+				columns[i] = strings.ToUpper(name)
+			}
+		}
+	}
+
+	history := make(PriceHistory)
+	latest := make(map[string]time.Time)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		dateStr := field(record, dateIdx)
+		if dateStr == "" {
+			continue
+		}
+		date, err := parseCSVDate(dateStr)
+		if err != nil {
+			return nil, err
+		}
+		month := date.Format("2006-01")
+		for i, sym := range columns {
+			value := strings.TrimPrefix(field(record, i), "$")
+			if value == "" {
+				continue
+			}
+			price, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", ""), 64)
+			if err != nil {
+				return nil, codedErrorf(CodeCSVValue, "invalid price %q for %s on %s", value, sym, dateStr)
+			}
+			key := sym + "\x00" + month
+			if date.Before(latest[key]) {
+				continue
+			}
+			latest[key] = date
+			if history[sym] == nil {
+				history[sym] = make(map[string]float64)
+			}
+			history[sym][month] = price
+		}
+	}
+	if len(history) == 0 {
+		return nil, errors.New("no price data")
+	}
+	return history, nil
+}
+
+// parseCSVDate parses the date formats found in price and transaction CSVs
+func parseCSVDate(value string) (time.Time, error) {
+	for _, layout := range []string{time.DateOnly, "01/02/2006", "1/2/2006"} {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, codedErrorf(CodeCSVValue, "invalid date %q", value)
+}
+
+// monthlyReturns converts closes to returns for each month whose previous
+// month also has a close
+func monthlyReturns(closes map[string]float64) map[string]float64 {
+	returns := make(map[string]float64)
+	for month, price := range closes {
+		date, _ := time.Parse("2006-01", month)
+		prev, ok := closes[date.AddDate(0, -1, 0).Format("2006-01")]
+		if ok && prev > 0 {
+			returns[month] = price/prev - 1
+		}
+	}
+	return returns
+}
+
+// riskCalc measures the current and target portfolios, rebalanced monthly,
+// over the months since start in which every weighted symbol and the
+// benchmark's symbols have returns
+func riskCalc(current, target map[string]float64, history PriceHistory, benchmark *BenchmarkConfig, start string) (*RiskReport, error) {
+	returns := make(map[string]map[string]float64)
+	for _, symbol := range riskSymbols(benchmark, current, target) {
+		closes, ok := history[symbol]
+		if !ok {
+			return nil, fmt.Errorf("no price history for %s", symbol)
+		}
+		returns[symbol] = monthlyReturns(closes)
+	}
+
+	var months []string
+	for month := range returns[benchmark.symbols()[0]] {
+		if month < start {
+			continue
+		}
+		covered := true
+		for _, r := range returns {
+			if _, ok := r[month]; !ok {
+				covered = false
+				break
+			}
+		}
+		if covered {
+			months = append(months, month)
+		}
+	}
+	if len(months) < minRiskMonths {
+		return nil, fmt.Errorf("only %d months of overlapping price history; at least %d are needed", len(months), minRiskMonths)
+	}
+	slices.Sort(months)
+
+	series := func(weights map[string]float64) []float64 {
+		values := make([]float64, len(months))
+		for i, month := range months {
+			for symbol, weight := range weights {
+				if weight > 0 {
+					values[i] += weight * returns[symbol][month]
+				}
+			}
+		}
+		return values
+	}
+	benchmarkWeights := make(map[string]float64)
+	for symbol, percentage := range benchmark.Composition {
+		benchmarkWeights[symbol] = percentage / 100
+	}
+	benchmarkReturns := series(benchmarkWeights)
+
+	return &RiskReport{
+		Benchmark:        benchmark.label(),
+		Start:            months[0],
+		End:              months[len(months)-1],
+		Months:           len(months),
+		Current:          riskMetrics(series(current), benchmarkReturns),
+		Target:           riskMetrics(series(target), benchmarkReturns),
+		BenchmarkMetrics: riskMetrics(benchmarkReturns, benchmarkReturns),
+	}, nil
+}
+
+func riskMetrics(returns, benchmark []float64) RiskMetrics {
+	n := float64(len(returns))
+	mean := func(values []float64) float64 {
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		return sum / n
+	}
+	meanR, meanB := mean(returns), mean(benchmark)
+	var variance, covariance, benchmarkVariance float64
+	for i := range returns {
+		variance += (returns[i] - meanR) * (returns[i] - meanR)
+		covariance += (returns[i] - meanR) * (benchmark[i] - meanB)
+		benchmarkVariance += (benchmark[i] - meanB) * (benchmark[i] - meanB)
+	}
+
+	value, peak, drawdown := 1.0, 1.0, 0.0
+	for _, r := range returns {
+		value *= 1 + r
+		peak = max(peak, value)
+		drawdown = max(drawdown, 1-value/peak)
+	}
+
+	metrics := RiskMetrics{
+		Volatility:  math.Sqrt(variance/(n-1)) * math.Sqrt(12),
+		MaxDrawdown: drawdown,
+	}
+	if benchmarkVariance > 0 {
+		metrics.Beta = covariance / benchmarkVariance
+	}
+	return metrics
+}
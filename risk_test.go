@@ -0,0 +1,72 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRiskCalc(t *testing.T) {
+	file, err := os.Open(filepath.Join("tests", "history", "monthly.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	history, err := readHistory(file, "")
+	if err != nil {
+		t.Fatalf("readHistory failed: %v", err)
+	}
+	if len(history) != 4 || len(history["VTI"]) != 25 {
+		t.Fatalf("History mismatch: got %d symbols, %d VTI months", len(history), len(history["VTI"]))
+	}
+
+	current := map[string]float64{"SPY": 1}
+	target := map[string]float64{"VTI": 0.6, "BND": 0.4}
+	spy := riskBenchmark(&Config{}, "")
+	report, err := riskCalc(current, target, history, spy, "")
+	if err != nil {
+		t.Fatalf("riskCalc failed: %v", err)
+	}
+	if report.Months != 24 || report.Start != "2024-01" || report.End != "2025-12" {
+		t.Errorf("Range mismatch: got %s to %s (%d months)", report.Start, report.End, report.Months)
+	}
+	if report.Current != report.BenchmarkMetrics || math.Abs(report.Current.Beta-1) > 1e-9 {
+		t.Errorf("A portfolio of only the benchmark should match it: got %+v vs %+v", report.Current, report.BenchmarkMetrics)
+	}
+	if report.Target.Volatility >= report.Current.Volatility || report.Target.Beta >= 1 {
+		t.Errorf("Adding bonds should lower volatility and beta: got %+v", report.Target)
+	}
+
+	if _, err := riskCalc(current, target, history, spy, "2025-06"); err == nil {
+		t.Error("Expected error for fewer than 12 months of history")
+	}
+	if _, err := riskCalc(current, map[string]float64{"VNQ": 1}, history, spy, ""); err == nil {
+		t.Error("Expected error for a symbol without history")
+	}
+
+	// Without -benchmark, beta is measured against the config's benchmark
+	config := &Config{Benchmark: &BenchmarkConfig{Name: "60/40", Composition: map[string]float64{"VTI": 60, "BND": 40}}}
+	report, err = riskCalc(current, target, history, riskBenchmark(config, ""), "")
+	if err != nil {
+		t.Fatalf("riskCalc failed: %v", err)
+	}
+	if report.Benchmark != "60/40" || report.Target != report.BenchmarkMetrics {
+		t.Errorf("Expected the target to match the 60/40 benchmark, got %+v", report)
+	}
+	if benchmark := riskBenchmark(config, "VTI"); benchmark.label() != "VTI" {
+		t.Errorf("Expected -benchmark to override the config's, got %s", benchmark.label())
+	}
+}
+
+func TestRiskMetrics(t *testing.T) {
+	returns := []float64{0.10, -0.20, 0.05, -0.10, 0.30}
+	metrics := riskMetrics(returns, returns)
+	// Peak of 1.10 falls to 1.10 * 0.8 * 1.05 * 0.9 = 0.8316
+	if math.Abs(metrics.MaxDrawdown-0.244) > 1e-9 {
+		t.Errorf("MaxDrawdown mismatch: got %f, expected 0.244", metrics.MaxDrawdown)
+	}
+	if math.Abs(metrics.Volatility-math.Sqrt(0.037*12)) > 1e-9 {
+		t.Errorf("Volatility mismatch: got %f", metrics.Volatility)
+	}
+}
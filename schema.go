@@ -0,0 +1,239 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configSchemaJSON is the JSON Schema for the config file. Editors that use
+// yaml-language-server read it for completion and inline errors.
+//
+//go:embed config.schema.json
+var configSchemaJSON []byte
+
+// Schema is the subset of JSON Schema that config.schema.json uses
+type Schema struct {
+	Ref         string             `json:"$ref"`
+	Description string             `json:"description"`
+	Type        schemaTypes        `json:"type"`
+	Properties  map[string]*Schema `json:"properties"`
+	Required    []string           `json:"required"`
+	// AdditionalProperties is either false, which is left to the YAML
+	// decoder's unknown key check, or a schema for every other key
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	Enum                 []any              `json:"enum"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum"`
+	MinLength            int                `json:"minLength"`
+	MinItems             int                `json:"minItems"`
+	Pattern              string             `json:"pattern"`
+	Defs                 map[string]*Schema `json:"$defs"`
+}
+
+// schemaTypes is a schema's type, which may be one type name or a list of them
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*t = schemaTypes{one}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// SchemaError is a config value that doesn't match the schema
+type SchemaError struct {
+	Line, Column int
+	// Path is the dotted location of the value, e.g. stocks[1].target_percentage
+	Path    string
+	Message string
+}
+
+func (e *SchemaError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+	}
+	return fmt.Sprintf("line %d, column %d: %s: %s", e.Line, e.Column, e.Path, e.Message)
+}
+
+func configSchema() (*Schema, error) {
+	var schema Schema
+	if err := json.Unmarshal(configSchemaJSON, &schema); err != nil {
+		return nil, fmt.Errorf("error reading config schema: %w", err)
+	}
+	return &schema, nil
+}
+
+func configCommand(args []string) int {
+	if len(args) < 1 {
+		fmt.Println("Usage: fin-tilt config schema|diff")
+		return 1
+	}
+	switch args[0] {
+	case "schema":
+		fmt.Print(string(configSchemaJSON))
+		return 0
+	case "diff":
+		return configDiff(args[1:])
+	}
+	printError(codedErrorf(CodeUsage, "unknown config command %q", args[0]))
+	return 1
+}
+
+// validateSchema checks the YAML document against the config schema and
+// returns every mismatch, joined, with the line and column of each
+func validateSchema(doc *yaml.Node) error {
+	schema, err := configSchema()
+	if err != nil {
+		return err
+	}
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+	// An empty file is left to the decoder
+	if doc.Kind == 0 {
+		return nil
+	}
+	var errs []error
+	schema.validate(schema, doc, "", &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+	return &CodedError{Code: CodeConfigInvalid, Err: errors.Join(errs...)}
+}
+
+func (s *Schema) validate(root *Schema, node *yaml.Node, path string, errs *[]error) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if s.Ref != "" {
+		resolved := root.Defs[strings.TrimPrefix(s.Ref, "#/$defs/")]
+		if resolved == nil {
+			*errs = append(*errs, fmt.Errorf("config schema: unknown reference %s", s.Ref))
+			return
+		}
+		s = resolved
+	}
+	// An empty value decodes to the zero value, as if the key were left out
+	if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null" {
+		return
+	}
+	fail := func(at *yaml.Node, format string, args ...any) {
+		*errs = append(*errs, &SchemaError{Line: at.Line, Column: at.Column, Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	kind := yamlKind(node)
+	if len(s.Type) > 0 && !slices.ContainsFunc(s.Type, func(t string) bool { return t == kind || (t == "number" && kind == "integer") }) {
+		fail(node, "expected %s, got %s", strings.Join(s.Type, " or "), kind)
+		return
+	}
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(value any) bool { return fmt.Sprint(value) == node.Value }) {
+		var values []string
+		for _, value := range s.Enum {
+			values = append(values, fmt.Sprint(value))
+		}
+		fail(node, "%q is not one of %s", node.Value, strings.Join(values, ", "))
+		return
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		var extra *Schema
+		if len(s.AdditionalProperties) > 0 && s.AdditionalProperties[0] == '{' {
+			if err := json.Unmarshal(s.AdditionalProperties, &extra); err != nil {
+				*errs = append(*errs, fmt.Errorf("config schema: %w", err))
+				return
+			}
+		}
+		seen := make(map[string]bool)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i].Value, node.Content[i+1]
+			seen[key] = true
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			if property := s.Properties[key]; property != nil {
+				property.validate(root, value, keyPath, errs)
+			} else if extra != nil {
+				extra.validate(root, value, keyPath, errs)
+			}
+		}
+		for _, key := range s.Required {
+			if !seen[key] {
+				fail(node, "missing required key %s", key)
+			}
+		}
+	case yaml.SequenceNode:
+		if len(node.Content) < s.MinItems {
+			fail(node, "expected at least %d items", s.MinItems)
+		}
+		if s.Items != nil {
+			for i, item := range node.Content {
+				s.Items.validate(root, item, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	case yaml.ScalarNode:
+		if kind == "number" || kind == "integer" {
+			var n float64
+			if err := node.Decode(&n); err != nil {
+				fail(node, "%v", err)
+				return
+			}
+			if s.Minimum != nil && n < *s.Minimum {
+				fail(node, "%v is less than the minimum of %v", node.Value, *s.Minimum)
+			}
+			if s.Maximum != nil && n > *s.Maximum {
+				fail(node, "%v is more than the maximum of %v", node.Value, *s.Maximum)
+			}
+			if s.ExclusiveMinimum != nil && n <= *s.ExclusiveMinimum {
+				fail(node, "%v must be more than %v", node.Value, *s.ExclusiveMinimum)
+			}
+		}
+		if kind == "string" {
+			if len(node.Value) < s.MinLength {
+				fail(node, "must not be empty")
+			}
+			if s.Pattern != "" {
+				pattern, err := regexp.Compile(s.Pattern)
+				if err != nil {
+					*errs = append(*errs, fmt.Errorf("config schema: %w", err))
+				} else if !pattern.MatchString(node.Value) {
+					fail(node, "%q is not in the expected format", node.Value)
+				}
+			}
+		}
+	}
+}
+
+// yamlKind names a node's type as JSON Schema does. Dates are strings in
+// JSON, so YAML timestamps are too.
+func yamlKind(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	}
+	switch node.ShortTag() {
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	case "!!bool":
+		return "boolean"
+	case "!!null":
+		return "null"
+	}
+	return "string"
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TestConfigSchemaCoversConfig keeps the schema in step with the config
+// structs, so editors offer every key the decoder accepts
+func TestConfigSchemaCoversConfig(t *testing.T) {
+	schema, err := configSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var check func(s *Schema, typ reflect.Type, path string)
+	check = func(s *Schema, typ reflect.Type, path string) {
+		if s.Ref != "" {
+			s = schema.Defs[strings.TrimPrefix(s.Ref, "#/$defs/")]
+		}
+		for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice {
+			typ = typ.Elem()
+			if s.Items != nil {
+				s = s.Items
+			}
+		}
+		if typ.Kind() != reflect.Struct || typ.PkgPath() != reflect.TypeFor[Config]().PkgPath() {
+			return
+		}
+		for i := range typ.NumField() {
+			name, _, _ := strings.Cut(typ.Field(i).Tag.Get("yaml"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			property := s.Properties[name]
+			if property == nil {
+				t.Errorf("schema has no property %s%s", path, name)
+				continue
+			}
+			check(property, typ.Field(i).Type, path+name+".")
+		}
+	}
+	check(schema, reflect.TypeFor[Config](), "")
+}
+
+func TestValidateSchema(t *testing.T) {
+	tests := []struct {
+		name     string
+		yaml     string
+		expected string
+	}{
+		{
+			name: "valid",
+			yaml: "stocks:\n  - symbol: VTI\n    target_percentage: 100\nstale_after: 2w\n",
+		},
+		{
+			name:     "percentage as text",
+			yaml:     "stocks:\n  - symbol: VTI\n    target_percentage: all\n",
+			expected: "line 3, column 24: stocks[0].target_percentage: expected number, got string",
+		},
+		{
+			name:     "missing symbol",
+			yaml:     "stocks:\n  - target_percentage: 100\n",
+			expected: "line 2, column 5: stocks[0]: missing required key symbol",
+		},
+		{
+			name:     "unknown enum value",
+			yaml:     "stocks:\n  - symbol: VTI\n    target_percentage: 100\nlot_method: cheapest\n",
+			expected: `line 4, column 13: lot_method: "cheapest" is not one of hifo, lifo, fifo, min-tax`,
+		},
+		{
+			name:     "bad age and percentage over 100",
+			yaml:     "stocks:\n  - symbol: VTI\n    target_percentage: 120\nstale_after: soon\n",
+			expected: "line 3, column 24: stocks[0].target_percentage: 120 is more than the maximum of 100\nline 4, column 14: stale_after: \"soon\" is not in the expected format",
+		},
+		{
+			name:     "missing stocks",
+			yaml:     "lot_method: fifo\n",
+			expected: "line 1, column 1: missing required key stocks",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.yaml), &doc); err != nil {
+				t.Fatal(err)
+			}
+			err := validateSchema(&doc)
+			actual := ""
+			if err != nil {
+				actual = err.Error()
+			}
+			if actual != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, actual)
+			}
+		})
+	}
+}
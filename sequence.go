@@ -0,0 +1,109 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// TradeStep is one trade in the order an account's trades should be placed
+type TradeStep struct {
+	Account string `json:"account"`
+	Symbol  string `json:"symbol"`
+	// Amount is positive for a purchase and negative for a sale
+	Amount int `json:"amount"`
+	// SettlesOn is set on a purchase in a cash account that needs the
+	// proceeds of a sale, which can't be spent before they settle
+	SettlesOn string `json:"settles_on,omitempty"`
+}
+
+// settlementDate is when a trade placed on day settles: the next business
+// day (T+1). Market holidays aren't known, so one may push it a day later.
+func settlementDate(day time.Time) time.Time {
+	for day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		day = day.AddDate(0, 0, 1)
+	}
+	day = day.AddDate(0, 0, 1)
+	for day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day
+}
+
+// tradeSequence orders the trades of each configured account that both sells
+// and buys: sales first, largest first, so their proceeds fund the purchases.
+// In a cash account, purchases beyond the cash already there must wait for
+// the sales to settle.
+func tradeSequence(config *Config, holdings *Holdings, symbols map[string]SymbolData, now time.Time) []TradeStep {
+	trades := make(map[string][]TradeStep)
+	var accounts []string
+	for _, stock := range config.Stocks {
+		data := symbols[stock.Symbol]
+		account := config.account(data.Account, "")
+		amount := data.AmountNeeded
+		if data.WholeShares {
+			amount -= data.ResidualCash
+		}
+		if account == nil || amount == 0 {
+			continue
+		}
+		if trades[account.Name] == nil {
+			accounts = append(accounts, account.Name)
+		}
+		trades[account.Name] = append(trades[account.Name], TradeStep{Account: account.Name, Symbol: stock.Symbol, Amount: amount})
+	}
+
+	settles := settlementDate(now).Format(time.DateOnly)
+	var sequence []TradeStep
+	for _, name := range accounts {
+		steps := trades[name]
+		if !slices.ContainsFunc(steps, func(s TradeStep) bool { return s.Amount < 0 }) || !slices.ContainsFunc(steps, func(s TradeStep) bool { return s.Amount > 0 }) {
+			continue
+		}
+		// Ascending amounts put sales first, from the largest, then
+		// purchases from the smallest, so that settled cash covers as many
+		// purchases as it can
+		slices.SortStableFunc(steps, func(a, b TradeStep) int { return cmp.Compare(a.Amount, b.Amount) })
+		account := config.account(name, "")
+		settled := holdings.availableCash(config, account)
+		for i, step := range steps {
+			if step.Amount <= 0 || !account.CashAccount {
+				continue
+			}
+			if step.Amount <= settled {
+				settled -= step.Amount
+			} else {
+				steps[i].SettlesOn = settles
+			}
+		}
+		sequence = append(sequence, steps...)
+	}
+	return sequence
+}
+
+func printTradeSequence(result *RebalanceResult) {
+	if len(result.Sequence) == 0 {
+		return
+	}
+	fmt.Println("\n" + rule())
+	fmt.Println("Execution order")
+	fmt.Println(rule())
+	account, n := "", 0
+	for _, step := range result.Sequence {
+		if step.Account != account {
+			account, n = step.Account, 0
+			fmt.Printf("%s:\n", account)
+		}
+		n++
+		action, amount := "Sell", -step.Amount
+		if step.Amount > 0 {
+			action, amount = "Buy", step.Amount
+		}
+		line := fmt.Sprintf("  %d. %s %s of %s", n, action, formatAmount(amount, true), step.Symbol)
+		if step.SettlesOn != "" {
+			line += fmt.Sprintf(" on or after %s, once sale proceeds settle (T+1)", step.SettlesOn)
+		}
+		fmt.Println(line)
+	}
+}
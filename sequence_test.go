@@ -0,0 +1,56 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTradeSequence(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	config.Accounts = []Account{{Name: "Individual"}, {Name: "Roth IRA", CashAccount: true}}
+	config.Stocks[1].TargetPercentage = 10
+	config.Stocks[2].TargetPercentage = 19
+	holdings := loadHoldings(t, config, "cash.csv")
+	result, err := allocationCalc(config, holdings, 0)
+	if err != nil {
+		t.Fatalf("allocationCalc failed: %v", err)
+	}
+
+	// The Roth IRA sells VXUS to buy BND, but its $500 of cash can't cover
+	// the purchase until the sale settles on Monday
+	friday := time.Date(2026, time.October, 16, 10, 0, 0, 0, time.UTC)
+	sequence := tradeSequence(config, holdings, result.Symbols, friday)
+	expected := []TradeStep{
+		{Account: "Roth IRA", Symbol: "VXUS", Amount: -800000},
+		{Account: "Roth IRA", Symbol: "BND", Amount: 800000, SettlesOn: "2026-10-19"},
+	}
+	if !reflect.DeepEqual(sequence, expected) {
+		t.Errorf("Sequence mismatch: got %+v, expected %+v", sequence, expected)
+	}
+
+	// With margin, the purchase can follow the sale at once
+	config.Accounts[1].CashAccount = false
+	sequence = tradeSequence(config, holdings, result.Symbols, friday)
+	if len(sequence) != 2 || sequence[1].SettlesOn != "" {
+		t.Errorf("Expected no settlement wait in a margin account, got %+v", sequence)
+	}
+}
+
+func TestSettlementDate(t *testing.T) {
+	tests := map[string]string{
+		"2026-10-14": "2026-10-15", // Wednesday
+		"2026-10-16": "2026-10-19", // Friday
+		"2026-10-17": "2026-10-20", // Saturday, traded Monday
+	}
+	for day, expected := range tests {
+		date, _ := time.Parse(time.DateOnly, day)
+		if got := settlementDate(date).Format(time.DateOnly); got != expected {
+			t.Errorf("settlementDate(%s) = %s, expected %s", day, got, expected)
+		}
+	}
+}
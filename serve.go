@@ -0,0 +1,151 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ServeConfig struct {
+	Addr string `yaml:"addr,omitempty"`
+	// Portfolio is the CSV read on every request, so replacing it with a newer
+	// export updates the answers without restarting the server. Without one,
+	// the latest snapshot is reported on.
+	Portfolio string `yaml:"portfolio,omitempty"`
+	// SlackSigningSecret verifies slash command requests. The SLACK_SIGNING_SECRET
+	// environment variable takes precedence.
+	SlackSigningSecret string `yaml:"slack_signing_secret,omitempty"`
+}
+
+func serve(ctx context.Context, config *Config, args []string) {
+	cfg := ServeConfig{}
+	if config.Serve != nil {
+		cfg = *config.Serve
+	}
+	flagSet := flag.NewFlagSet("serve", flag.ExitOnError)
+	flagSet.StringVar(&cfg.Addr, "addr", cmp.Or(cfg.Addr, ":8080"), "Address to listen on")
+	flagSet.StringVar(&cfg.Portfolio, "portfolio", cfg.Portfolio, "Portfolio CSV to report on instead of the latest snapshot")
+	flagSet.Parse(args)
+	if secret := os.Getenv("SLACK_SIGNING_SECRET"); secret != "" {
+		cfg.SlackSigningSecret = secret
+	}
+	if cfg.Portfolio == "" && config.Snapshots == "" {
+		printError(codedErrorf(CodeUsage, "serve needs a portfolio CSV (serve.portfolio in the config or -portfolio) or snapshots in the config"))
+		return
+	}
+	if cfg.SlackSigningSecret == "" {
+		printError(codedErrorf(CodeConfigInvalid, "set serve.slack_signing_secret or SLACK_SIGNING_SECRET to verify Slack requests"))
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /slack/command", slackCommandHandler(config, &cfg, time.Now))
+	server := &http.Server{Addr: cfg.Addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	errs := make(chan error, 1)
+	go func() { errs <- server.ListenAndServe() }()
+	log.Printf("Listening on %s", cfg.Addr)
+	select {
+	case err := <-errs:
+		printError(err)
+	case <-ctx.Done():
+		// Let in-flight slash commands finish before exiting
+		log.Printf("Shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			printError(err)
+		}
+	}
+}
+
+// slackCommandHandler answers the /fintilt slash command
+func slackCommandHandler(config *Config, cfg *ServeConfig, now func() time.Time) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, "error reading request", http.StatusBadRequest)
+			return
+		}
+		if !verifySlackSignature(cfg.SlackSigningSecret, r.Header, body, now()) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			http.Error(w, "invalid form body", http.StatusBadRequest)
+			return
+		}
+
+		var text string
+		switch strings.TrimSpace(form.Get("text")) {
+		case "status":
+			text, err = portfolioStatus(config, cfg.Portfolio)
+			if err != nil {
+				text = "Error: " + err.Error()
+			}
+		default:
+			text = "Usage: /fintilt status"
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"response_type": "ephemeral",
+			"text":          text,
+		})
+	})
+}
+
+// verifySlackSignature checks the v0 HMAC signature Slack sends with every
+// request, rejecting requests more than five minutes old to prevent replays
+func verifySlackSignature(secret string, header http.Header, body []byte, now time.Time) bool {
+	timestamp, err := strconv.ParseInt(header.Get("X-Slack-Request-Timestamp"), 10, 64)
+	if err != nil || math.Abs(now.Sub(time.Unix(timestamp, 0)).Minutes()) > 5 {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%d:%s", timestamp, body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(header.Get("X-Slack-Signature")))
+}
+
+func portfolioStatus(config *Config, portfolioCsv string) (string, error) {
+	holdings, err := currentHoldings(config, portfolioCsv)
+	if err != nil {
+		return "", err
+	}
+	result, err := allocationCalc(config, holdings, 0)
+	if err != nil {
+		return "", err
+	}
+	return driftSummary(config, result), nil
+}
+
+// driftSummary is a compact plain-text view of each symbol's drift and any
+// warnings
+func driftSummary(config *Config, result *RebalanceResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Total: %s as of %s\n", formatAmount(result.Total, true), formatAsOf(result.AsOf, result.AsOfSource))
+	fmt.Fprintf(&b, "Drift score: %.2f (%s)\n", result.Urgency.Score, result.Urgency.Level)
+	for _, stock := range config.Stocks {
+		data := result.Symbols[stock.Symbol]
+		fmt.Fprintf(&b, "%s: %.2f%% (target %.2f%%, drift %+.2f%%, trade %s)\n", stock.Symbol, data.CurrentPercentage, data.TargetPercentage, data.Drift, formatAmount(data.AmountNeeded, true))
+	}
+	for _, warning := range result.Warnings {
+		fmt.Fprintf(&b, "Warning: %s\n", warning.Message)
+	}
+	return b.String()
+}
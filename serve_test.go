@@ -0,0 +1,94 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlackCommandHandler(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	cfg := &ServeConfig{
+		Portfolio:          filepath.Join("tests", "portfolios", "unbalanced.csv"),
+		SlackSigningSecret: "shhh",
+	}
+	now := time.Unix(1760000000, 0)
+	handler := slackCommandHandler(config, cfg, func() time.Time { return now })
+
+	send := func(body string, timestamp int64, secret string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/slack/command", strings.NewReader(body))
+		mac := hmac.New(sha256.New, []byte(secret))
+		fmt.Fprintf(mac, "v0:%d:%s", timestamp, body)
+		req.Header.Set("X-Slack-Request-Timestamp", fmt.Sprint(timestamp))
+		req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send("command=%2Ffintilt&text=status", now.Unix(), "shhh")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Status mismatch: got %d, expected 200", rec.Code)
+	}
+	var resp map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !strings.Contains(resp["text"], "VTI: 80.00% (target 71.00%, drift +9.00%, trade -$9,000.00)") {
+		t.Errorf("Unexpected status text: %q", resp["text"])
+	}
+
+	if rec := send("command=%2Ffintilt&text=status", now.Unix(), "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Bad signature: got %d, expected 401", rec.Code)
+	}
+	if rec := send("command=%2Ffintilt&text=status", now.Add(-10*time.Minute).Unix(), "shhh"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Stale timestamp: got %d, expected 401", rec.Code)
+	}
+}
+
+func TestPortfolioStatusFromSnapshot(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	if _, err := portfolioStatus(config, ""); errorCode(err) != CodeUsage {
+		t.Errorf("Expected no portfolio or snapshots to be a usage error, got %v", err)
+	}
+
+	portfolio := filepath.Join("tests", "portfolios", "unbalanced.csv")
+	holdings, err := readPortfolio(config, portfolio)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Snapshots = filepath.Join(t.TempDir(), "snapshots.jsonl")
+	if _, err := appendSnapshot(config.Snapshots, newSnapshot(holdings)); err != nil {
+		t.Fatal(err)
+	}
+	fromCSV, err := portfolioStatus(config, portfolio)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromSnapshot, err := portfolioStatus(config, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the as-of line differs
+	csvLines, snapshotLines := strings.Split(fromCSV, "\n"), strings.Split(fromSnapshot, "\n")
+	if !strings.Contains(snapshotLines[0], "(snapshot)") {
+		t.Errorf("Expected the as-of time to come from the snapshot, got %q", snapshotLines[0])
+	}
+	if strings.Join(csvLines[1:], "\n") != strings.Join(snapshotLines[1:], "\n") {
+		t.Errorf("Expected the snapshot's status to match the CSV's:\n%s\n%s", fromCSV, fromSnapshot)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+func validateSharePrices(config *Config) error {
+	for symbol, price := range config.SharePrices {
+		if _, ok := config.primarySymbol(symbol); !ok {
+			return fmt.Errorf("share_prices has %s, which is not a configured stock or alternative", symbol)
+		}
+		if price <= 0 {
+			return fmt.Errorf("share price of %s must be positive", symbol)
+		}
+	}
+	return nil
+}
+
+// valueShares values the positions read as shares alone, such as those of
+// DSPP and ESOP plan statements, at the config's share_prices or else the
+// quotes plugin's prices. A manual price also fills in the share price of a
+// stock that the export gives none for.
+func valueShares(ctx context.Context, config *Config, holdings *Holdings) error {
+	prices := make(map[string]int)
+	for symbol, price := range config.SharePrices {
+		prices[symbol] = int(price)
+	}
+	var missing []string
+	for _, p := range holdings.Positions {
+		if p.SharesOnly && prices[p.Symbol] == 0 && !slices.Contains(missing, p.Symbol) {
+			missing = append(missing, p.Symbol)
+		}
+	}
+	if len(missing) > 0 {
+		name := config.pluginFor("quotes")
+		if name == "" {
+			return codedErrorf(CodeConfigInvalid, "no price for the shares of %s; set share_prices in the config or add a quotes plugin", strings.Join(missing, ", "))
+		}
+		response, err := runPlugin(ctx, config, name, PluginRequest{Type: "quotes", Symbols: missing})
+		if err != nil {
+			return err
+		}
+		var unquoted []string
+		for _, symbol := range missing {
+			if price := response.Quotes[symbol]; price > 0 {
+				prices[symbol] = price.cents()
+			} else {
+				unquoted = append(unquoted, symbol)
+			}
+		}
+		if len(unquoted) > 0 {
+			return codedErrorf(CodePlugin, "quotes plugin %s has no price for the shares of %s", name, strings.Join(unquoted, ", "))
+		}
+	}
+
+	for i := range holdings.Positions {
+		p := &holdings.Positions[i]
+		if !p.SharesOnly {
+			continue
+		}
+		p.Price = prices[p.Symbol]
+		p.Value = int(math.Round(p.Quantity * float64(p.Price)))
+		p.SharesOnly = false
+		holdings.Amounts[p.Primary] += p.Value
+		holdings.AccountTotals[p.Account] += p.Value
+		if account := config.account(p.Account, p.Account); account != nil {
+			if holdings.AmountsByAccount[p.Primary] == nil {
+				holdings.AmountsByAccount[p.Primary] = make(map[string]int)
+			}
+			holdings.AmountsByAccount[p.Primary][account.Name] += p.Value
+		}
+	}
+	for _, stock := range config.Stocks {
+		if price := prices[stock.Symbol]; price > 0 && holdings.Prices[stock.Symbol] == 0 {
+			holdings.Prices[stock.Symbol] = price
+		}
+	}
+	return nil
+}
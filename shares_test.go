@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValueShares(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	// The ESOP gives shares alone, the DSPP shares and a price
+	csv := `Account Name,Symbol,Quantity,Last Price
+ESOP,VTI,100,
+DSPP,VXUS,10,$60.00
+`
+	read := func() *Holdings {
+		holdings, err := readHoldings(config, strings.NewReader(csv))
+		if err != nil {
+			t.Fatalf("readHoldings failed: %v", err)
+		}
+		return holdings
+	}
+	holdings := read()
+	if holdings.Amounts["VTI"] != 0 || holdings.Amounts["VXUS"] != 60000 || !holdings.Positions[0].SharesOnly {
+		t.Fatalf("Expected VTI's shares left to value and VXUS valued at its price, got %v %+v", holdings.Amounts, holdings.Positions)
+	}
+	if err := valueShares(context.Background(), config, holdings); errorCode(err) != CodeConfigInvalid {
+		t.Errorf("Expected an error for shares without a price, got %v", err)
+	}
+
+	config.SharePrices = map[string]Money{"VTI": 25000}
+	holdings = read()
+	if err := valueShares(context.Background(), config, holdings); err != nil {
+		t.Fatalf("valueShares failed: %v", err)
+	}
+	if holdings.Amounts["VTI"] != 2500000 || holdings.AccountTotals["ESOP"] != 2500000 || holdings.Prices["VTI"] != 25000 || holdings.Positions[0].Value != 2500000 {
+		t.Errorf("Expected VTI valued at its share price, got %v %v %+v", holdings.Amounts, holdings.Prices, holdings.Positions[0])
+	}
+
+	// Without a manual price, the quotes plugin prices them
+	config.SharePrices = nil
+	config.Plugins = map[string]PluginConfig{"quotes": {Command: `printf '{"quotes": {"VTI": 240}}'`, Provides: []string{"quotes"}}}
+	holdings = read()
+	if err := valueShares(context.Background(), config, holdings); err != nil {
+		t.Fatalf("valueShares failed: %v", err)
+	}
+	if holdings.Amounts["VTI"] != 2400000 {
+		t.Errorf("Expected VTI valued at its quote, got %v", holdings.Amounts)
+	}
+
+	config.SharePrices = map[string]Money{"AAPL": 100}
+	if err := config.validate(); err == nil {
+		t.Error("Expected an error for a share price of an unconfigured symbol")
+	}
+}
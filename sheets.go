@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const sheetsScope = "https://www.googleapis.com/auth/spreadsheets"
+
+// sheetsAPI is the base URL of the Google Sheets API, overridden in tests
+var sheetsAPI = "https://sheets.googleapis.com/v4/spreadsheets"
+
+type SheetsConfig struct {
+	SpreadsheetID string `yaml:"spreadsheet_id"`
+	Tab           string `yaml:"tab,omitempty"`
+	// Credentials is a service account key file. When omitted, an OAuth access
+	// token is read from the GOOGLE_OAUTH_ACCESS_TOKEN environment variable.
+	Credentials string `yaml:"credentials,omitempty"`
+}
+
+type serviceAccountKey struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+func (s *SheetsConfig) tab() string {
+	if s.Tab == "" {
+		return "fin-tilt"
+	}
+	return s.Tab
+}
+
+func sheets(ctx context.Context, config *Config, args []string) {
+	if len(args) < 2 || args[0] != "push" {
+		fmt.Println("Usage: fin-tilt sheets push <portfolio.csv> [-toDeposit <amount>]")
+		return
+	}
+	if offline {
+		printError(codedErrorf(CodeOffline, "sheets push writes to Google Sheets, which -offline doesn't allow"))
+		return
+	}
+	if config.Sheets == nil || config.Sheets.SpreadsheetID == "" {
+		printError(codedErrorf(CodeConfigInvalid, "config has no sheets.spreadsheet_id"))
+		return
+	}
+	var toDeposit int
+	flagSet := flag.NewFlagSet("sheets push", flag.ExitOnError)
+	flagSet.Func("toDeposit", "Additional amount to deposit, in dollars", func(value string) error {
+		amount, err := amountToInt(value)
+		toDeposit = amount
+		return err
+	})
+	portfolioCsv := args[1]
+	flagSet.Parse(args[2:])
+
+	holdings, err := loadPortfolio(config, portfolioCsv)
+	if err != nil {
+		printError(err)
+		return
+	}
+	result, err := allocationCalc(config, holdings, toDeposit)
+	if err != nil {
+		printError(err)
+		return
+	}
+
+	auditOutputs.rebalanced(result)
+	rows := sheetRows(config, result)
+	if skipDryRun(fmt.Sprintf("Replace tab %q", config.Sheets.tab()), sheetPreview(config.Sheets, rows)) {
+		return
+	}
+	if err := confirm(fmt.Sprintf("Replace tab %q", config.Sheets.tab()), sheetPreview(config.Sheets, rows)); err != nil {
+		printError(err)
+		return
+	}
+	token, err := sheetsToken(ctx, config.Sheets)
+	if err != nil {
+		printError(fmt.Errorf("authenticating with Google: %w", err))
+		return
+	}
+	if err := pushSheet(ctx, config.Sheets, config.fetchPolicy("sheets"), token, rows); err != nil {
+		printError(fmt.Errorf("writing to Google Sheets: %w", err))
+		return
+	}
+	auditOutputs.PlanExported = fmt.Sprintf("tab %q of sheet %s", config.Sheets.tab(), config.Sheets.SpreadsheetID)
+	fmt.Printf("Wrote %d symbols to tab %q\n", len(config.Stocks), config.Sheets.tab())
+}
+
+// sheetRows lays out the allocation, drift, and trade plan as spreadsheet rows.
+// Amounts are written in dollars so Sheets can format them as currency.
+func sheetRows(config *Config, result *RebalanceResult) [][]any {
+	dollars := func(cents int) float64 { return float64(cents) / 100 }
+	rows := [][]any{
+		{"As Of", formatAsOf(result.AsOf, result.AsOfSource)},
+		{"Total", dollars(result.Total)},
+		{"Deposit", dollars(result.DepositAmount)},
+		{},
+		{"Symbol", "Description", "Current Value", "Current %", "Target %", "Drift %", "Trade"},
+	}
+	for _, stock := range config.Stocks {
+		data := result.Symbols[stock.Symbol]
+		rows = append(rows, []any{
+			stock.Symbol,
+			stock.Description,
+			dollars(data.Amount),
+			math.Round(data.CurrentPercentage*100) / 100,
+			data.TargetPercentage,
+			math.Round(data.Drift*100) / 100,
+			dollars(data.AmountNeeded),
+		})
+	}
+	return rows
+}
+
+// sheetPreview lays out rows as tab-separated text for confirmation
+func sheetPreview(cfg *SheetsConfig, rows [][]any) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Tab %q of spreadsheet %s will be cleared and replaced with:\n", cfg.tab(), cfg.SpreadsheetID)
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = fmt.Sprint(cell)
+		}
+		b.WriteString(strings.Join(cells, "\t") + "\n")
+	}
+	return b.String()
+}
+
+// pushSheet clears the configured tab and writes rows starting at A1, pacing
+// and retrying the requests per policy
+func pushSheet(ctx context.Context, cfg *SheetsConfig, policy fetchPolicy, token string, rows [][]any) error {
+	wait, stop := policy.pacer(ctx)
+	defer stop()
+	request := func(method, endpoint string, body any) error {
+		_, err := fetchWithRetry(ctx, policy, endpoint, wait, func(ctx context.Context, endpoint string) (struct{}, error) {
+			return struct{}{}, sheetsRequest(ctx, method, endpoint, token, body)
+		})
+		return err
+	}
+	base := sheetsAPI + "/" + url.PathEscape(cfg.SpreadsheetID) + "/values/"
+	tab := sheetTabRange(cfg.tab())
+	if err := request(http.MethodPost, base+url.PathEscape(tab)+":clear", map[string]any{}); err != nil {
+		return err
+	}
+	cellRange := tab + "!A1"
+	body := map[string]any{
+		"range":          cellRange,
+		"majorDimension": "ROWS",
+		"values":         rows,
+	}
+	return request(http.MethodPut, base+url.PathEscape(cellRange)+"?valueInputOption=RAW", body)
+}
+
+// sheetTabRange is a tab's name as an A1 range, quoted so that names with
+// spaces or punctuation, such as "My Portfolio", are read whole
+func sheetTabRange(tab string) string {
+	return "'" + strings.ReplaceAll(tab, "'", "''") + "'"
+}
+
+func sheetsRequest(ctx context.Context, method, endpoint, token string, body any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%w: %s", &httpStatusError{StatusCode: resp.StatusCode, Status: resp.Status}, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// sheetsToken returns an OAuth access token, exchanging a signed JWT for one
+// when a service account key is configured
+func sheetsToken(ctx context.Context, cfg *SheetsConfig) (string, error) {
+	if cfg.Credentials == "" {
+		token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+		if token == "" {
+			return "", errors.New("set sheets.credentials to a service account key or GOOGLE_OAUTH_ACCESS_TOKEN to an access token")
+		}
+		return token, nil
+	}
+
+	keyBytes, err := os.ReadFile(cfg.Credentials)
+	if err != nil {
+		return "", err
+	}
+	var key serviceAccountKey
+	if err := json.Unmarshal(keyBytes, &key); err != nil {
+		return "", fmt.Errorf("invalid service account key: %w", err)
+	}
+	if key.TokenURI == "" {
+		key.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	assertion, err := signJWT(&key, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, key.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", err
+	}
+	if tokenResp.AccessToken == "" {
+		return "", fmt.Errorf("token exchange failed: %s %s", resp.Status, tokenResp.Error)
+	}
+	return tokenResp.AccessToken, nil
+}
+
+// signJWT builds the RS256-signed assertion used in the service account flow
+func signJWT(key *serviceAccountKey, now time.Time) (string, error) {
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return "", errors.New("service account private key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("invalid service account private key: %w", err)
+	}
+	privateKey, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", errors.New("service account private key is not an RSA key")
+	}
+
+	encode := func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(b), err
+	}
+	header, err := encode(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := encode(map[string]any{
+		"iss":   key.ClientEmail,
+		"scope": sheetsScope,
+		"aud":   key.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := header + "." + claims
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPushSheet(t *testing.T) {
+	fastFetchLimits(t)
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	holdings := loadHoldings(t, config, "unbalanced.csv")
+	result, err := allocationCalc(config, holdings, 0)
+	if err != nil {
+		t.Fatalf("allocationCalc failed: %v", err)
+	}
+	rows := sheetRows(config, result)
+
+	var paths []string
+	var written struct {
+		Range  string  `json:"range"`
+		Values [][]any `json:"values"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			t.Errorf("Missing bearer token on %s", r.URL.Path)
+		}
+		paths = append(paths, r.Method+" "+r.URL.EscapedPath())
+		if r.Method == http.MethodPut {
+			if err := json.NewDecoder(r.Body).Decode(&written); err != nil {
+				t.Errorf("Failed to decode body: %v", err)
+			}
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+	defer func(api string) { sheetsAPI = api }(sheetsAPI)
+	sheetsAPI = server.URL
+
+	cfg := &SheetsConfig{SpreadsheetID: "sheet123", Tab: "Plan"}
+	if err := pushSheet(context.Background(), cfg, (&Config{}).fetchPolicy("sheets"), "test-token", rows); err != nil {
+		t.Fatalf("pushSheet failed: %v", err)
+	}
+
+	expected := []string{
+		"POST /sheet123/values/%27Plan%27:clear",
+		"PUT /sheet123/values/%27Plan%27%21A1",
+	}
+	if strings.Join(paths, ",") != strings.Join(expected, ",") {
+		t.Errorf("Requests mismatch: got %v, expected %v", paths, expected)
+	}
+	if len(written.Values) != 8 {
+		t.Fatalf("Row count mismatch: got %d, expected 8", len(written.Values))
+	}
+	vti := written.Values[5]
+	if vti[0] != "VTI" || vti[2] != 80000.0 || vti[5] != 9.0 || vti[6] != -9000.0 {
+		t.Errorf("VTI row mismatch: got %v", vti)
+	}
+
+	// A tab name with a space or a quote is quoted in the range
+	paths = nil
+	cfg.Tab = "Bob's Portfolio"
+	if err := pushSheet(context.Background(), cfg, (&Config{}).fetchPolicy("sheets"), "test-token", rows); err != nil {
+		t.Fatalf("pushSheet failed: %v", err)
+	}
+	expected = []string{
+		"POST /sheet123/values/%27Bob%27%27s%20Portfolio%27:clear",
+		"PUT /sheet123/values/%27Bob%27%27s%20Portfolio%27%21A1",
+	}
+	if strings.Join(paths, ",") != strings.Join(expected, ",") {
+		t.Errorf("Requests mismatch: got %v, expected %v", paths, expected)
+	}
+	if written.Range != "'Bob''s Portfolio'!A1" {
+		t.Errorf("Range mismatch: got %q", written.Range)
+	}
+}
+
+func TestSignJWT(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	key := &serviceAccountKey{
+		ClientEmail: "fin-tilt@example.iam.gserviceaccount.com",
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		TokenURI:    "https://oauth2.googleapis.com/token",
+	}
+
+	jwt, err := signJWT(key, time.Unix(1700000000, 0))
+	if err != nil {
+		t.Fatalf("signJWT failed: %v", err)
+	}
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("JWT should have 3 parts, got %d", len(parts))
+	}
+	claimsJSON, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var claims map[string]any
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		t.Fatalf("Failed to decode claims: %v", err)
+	}
+	if claims["iss"] != key.ClientEmail || claims["scope"] != sheetsScope || claims["exp"] != 1700003600.0 {
+		t.Errorf("Claims mismatch: got %v", claims)
+	}
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&privateKey.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("Signature does not verify: %v", err)
+	}
+}
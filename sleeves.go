@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// sleeveCadences are the calendar cadences a sleeve can be checked on
+var sleeveCadences = []string{"monthly", "quarterly", "annually"}
+
+const defaultSleeveBand = 5.0
+
+// SleeveConfig is a part of the portfolio, such as a core of index funds or
+// satellite picks, that is rebalanced on its own. Its stocks name it in their
+// sleeve setting, and its share of the portfolio is the sum of their targets.
+type SleeveConfig struct {
+	Name string `yaml:"name"`
+	// Band is the drift within the sleeve, in percentage points, that calls
+	// for rebalancing it (default 5)
+	Band float64 `yaml:"band,omitempty"`
+	// Cadence is how often remind schedules a check of the sleeve: monthly,
+	// quarterly, or annually. Unset leaves it to the portfolio's reminders.
+	Cadence string `yaml:"cadence,omitempty"`
+}
+
+// SleeveResult is a sleeve's drift and the trades that rebalance it without
+// moving money in or out of it
+type SleeveResult struct {
+	Name   string  `json:"name"`
+	Amount int     `json:"amount"`
+	Band   float64 `json:"band"`
+	// CurrentPercentage, TargetPercentage, and Drift are the sleeve's share
+	// of the whole portfolio
+	CurrentPercentage float64 `json:"current_percentage"`
+	TargetPercentage  float64 `json:"target_percentage"`
+	Drift             float64 `json:"drift"`
+	// Breached is set when some symbol has drifted past the band within the
+	// sleeve, which is when its trades should be made
+	Breached bool `json:"breached"`
+	// Symbols are the sleeve's stocks, with percentages of the sleeve
+	Symbols map[string]SymbolData `json:"symbols"`
+}
+
+func validateSleeves(config *Config) error {
+	if len(config.Sleeves) == 0 {
+		for _, stock := range config.Stocks {
+			if stock.Sleeve != "" {
+				return fmt.Errorf("%s is in sleeve %q, but the config has no sleeves", stock.Symbol, stock.Sleeve)
+			}
+		}
+		return nil
+	}
+	names := make(map[string]bool)
+	for _, sleeve := range config.Sleeves {
+		if sleeve.Name == "" {
+			return fmt.Errorf("every sleeve needs a name")
+		}
+		if names[sleeve.Name] {
+			return fmt.Errorf("sleeve %s is configured more than once", sleeve.Name)
+		}
+		names[sleeve.Name] = true
+		if sleeve.Band < 0 {
+			return fmt.Errorf("band of sleeve %s must not be negative", sleeve.Name)
+		}
+		if sleeve.Cadence != "" && !slices.Contains(sleeveCadences, sleeve.Cadence) {
+			return fmt.Errorf("unknown cadence %q for sleeve %s (expected one of %s)", sleeve.Cadence, sleeve.Name, strings.Join(sleeveCadences, ", "))
+		}
+	}
+	// Sleeves sum to the whole portfolio, so every stock must be in one
+	for _, stock := range config.Stocks {
+		if !names[stock.Sleeve] {
+			if stock.Sleeve == "" {
+				return fmt.Errorf("%s is in no sleeve; with sleeves configured, every stock needs one", stock.Symbol)
+			}
+			return fmt.Errorf("%s is in unknown sleeve %q", stock.Symbol, stock.Sleeve)
+		}
+	}
+	return nil
+}
+
+func (s SleeveConfig) band() float64 {
+	if s.Band == 0 {
+		return defaultSleeveBand
+	}
+	return s.Band
+}
+
+// sleeveCalc rebalances each sleeve to the targets of its stocks, scaled to
+// add up to 100 within the sleeve
+func sleeveCalc(config *Config, holdings *Holdings) []SleeveResult {
+	total := 0
+	for _, stock := range config.Stocks {
+		total += holdings.Amounts[stock.Symbol]
+	}
+	var results []SleeveResult
+	for _, sleeve := range config.Sleeves {
+		result := SleeveResult{Name: sleeve.Name, Band: sleeve.band(), Symbols: make(map[string]SymbolData)}
+		for _, stock := range config.Stocks {
+			if stock.Sleeve == sleeve.Name {
+				result.Amount += holdings.Amounts[stock.Symbol]
+				result.TargetPercentage += stock.TargetPercentage
+			}
+		}
+		if total > 0 {
+			result.CurrentPercentage = float64(result.Amount) / float64(total) * 100
+		}
+		result.Drift = result.CurrentPercentage - result.TargetPercentage
+
+		for _, stock := range config.Stocks {
+			if stock.Sleeve != sleeve.Name {
+				continue
+			}
+			data := SymbolData{Amount: holdings.Amounts[stock.Symbol], Price: holdings.Prices[stock.Symbol]}
+			if result.TargetPercentage > 0 {
+				data.TargetPercentage = stock.TargetPercentage / result.TargetPercentage * 100
+			}
+			if result.Amount > 0 {
+				data.CurrentPercentage = float64(data.Amount) / float64(result.Amount) * 100
+				data.Drift = data.CurrentPercentage - data.TargetPercentage
+				data.AmountNeeded = config.round(float64(result.Amount)*(-data.Drift/100), "half-up")
+			}
+			if math.Abs(data.Drift) > result.Band {
+				result.Breached = true
+			}
+			result.Symbols[stock.Symbol] = data
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func printSleeves(config *Config, holdings *Holdings) {
+	if len(config.Sleeves) == 0 {
+		return
+	}
+	fmt.Println("\n" + rule())
+	fmt.Println("Sleeves")
+	fmt.Println(rule())
+	for i, sleeve := range sleeveCalc(config, holdings) {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s - %s, %.2f%% of the portfolio (target %.2f%%, %s)\n", sleeve.Name, formatAmount(sleeve.Amount, true), sleeve.CurrentPercentage, sleeve.TargetPercentage, driftText(sleeve.Drift))
+		for _, stock := range config.Stocks {
+			data, ok := sleeve.Symbols[stock.Symbol]
+			if !ok {
+				continue
+			}
+			trade := "hold"
+			if sleeve.Breached {
+				trade = tradeText(data.AmountNeeded, formatAmount(data.AmountNeeded, false))
+			}
+			fmt.Printf("  %-8s %.2f%% of sleeve (target %.2f%%, %s)  %s\n", stock.Symbol, data.CurrentPercentage, data.TargetPercentage, driftText(data.Drift), trade)
+		}
+		if !sleeve.Breached {
+			fmt.Printf("  Within its %g-point band; no trades needed\n", sleeve.Band)
+		}
+	}
+}
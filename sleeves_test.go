@@ -0,0 +1,89 @@
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSleeveCalc(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "sleeves.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	sleeves := sleeveCalc(config, loadHoldings(t, config, "sleeves.csv"))
+	if len(sleeves) != 2 {
+		t.Fatalf("Expected 2 sleeves, got %+v", sleeves)
+	}
+
+	// The core is 2.5 points off within its default 5-point band
+	core := sleeves[0]
+	if core.Amount != 8000000 || core.TargetPercentage != 80 || core.Breached || core.Band != 5 {
+		t.Errorf("Core mismatch: %+v", core)
+	}
+	if vti := core.Symbols["VTI"]; vti.TargetPercentage != 75 || math.Abs(vti.Drift-2.5) > 1e-9 {
+		t.Errorf("VTI mismatch: %+v", vti)
+	}
+
+	// The satellite is 10 points off, past its 3-point band, and is
+	// rebalanced without moving money out of it
+	satellite := sleeves[1]
+	if !satellite.Breached || math.Abs(satellite.Drift) > 1e-9 {
+		t.Errorf("Satellite mismatch: %+v", satellite)
+	}
+	if arkk, vnq := satellite.Symbols["ARKK"], satellite.Symbols["VNQ"]; arkk.AmountNeeded != -200000 || vnq.AmountNeeded != 200000 {
+		t.Errorf("Satellite trades mismatch: ARKK %d, VNQ %d", arkk.AmountNeeded, vnq.AmountNeeded)
+	}
+}
+
+func TestValidateSleeves(t *testing.T) {
+	stocks := func(sleeves ...string) []Stock {
+		var s []Stock
+		for i, sleeve := range sleeves {
+			s = append(s, Stock{Symbol: string(rune('A' + i)), TargetPercentage: 50, Sleeve: sleeve})
+		}
+		return s
+	}
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{"no sleeves", Config{Stocks: stocks("", "")}, false},
+		{"sleeves", Config{Stocks: stocks("core", "satellite"), Sleeves: []SleeveConfig{{Name: "core"}, {Name: "satellite", Cadence: "monthly"}}}, false},
+		{"stock without a sleeve", Config{Stocks: stocks("core", ""), Sleeves: []SleeveConfig{{Name: "core"}}}, true},
+		{"unknown sleeve", Config{Stocks: stocks("core", "other"), Sleeves: []SleeveConfig{{Name: "core"}}}, true},
+		{"sleeve without config", Config{Stocks: stocks("core", "core")}, true},
+		{"duplicate sleeve", Config{Stocks: stocks("core", "core"), Sleeves: []SleeveConfig{{Name: "core"}, {Name: "core"}}}, true},
+		{"band cadence", Config{Stocks: stocks("core", "core"), Sleeves: []SleeveConfig{{Name: "core", Cadence: "band"}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateSleeves(&tt.config); (err != nil) != tt.wantErr {
+				t.Errorf("validateSleeves() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSleeveReminders(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "sleeves.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	start := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	reminders, err := remindCalc(config, nil, start, 2)
+	if err != nil {
+		t.Fatalf("remindCalc failed: %v", err)
+	}
+	// Two quarterly portfolio checks, two quarterly satellite checks, and
+	// two annual core checks, in date order
+	if len(reminders) != 6 || reminders[1].Sleeve != "satellite" || reminders[5].Sleeve != "core" || !reminders[5].Date.Equal(start.AddDate(2, 0, 0)) {
+		t.Errorf("Reminders mismatch: %+v", reminders)
+	}
+	events := remindEvents(config, reminders)
+	if events[0].UID == events[1].UID {
+		t.Errorf("Expected distinct event UIDs on the same date, got %s", events[0].UID)
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Snapshot is the state of a portfolio at one export, appended as a line of
+// JSON (or as CSV rows, for a .csv file) to the file named by the config's
+// snapshots setting
+type Snapshot struct {
+	AsOf    time.Time      `json:"as_of"`
+	Total   int            `json:"total"`
+	Amounts map[string]int `json:"amounts"`
+	// Cash holds uninvested cash by account
+	Cash map[string]int `json:"cash,omitempty"`
+	// Accounts holds the total value of each account
+	Accounts map[string]int `json:"accounts,omitempty"`
+	// Liabilities holds what was owed on each liability, which Total leaves out
+	Liabilities map[string]int `json:"liabilities,omitempty"`
+	// Source is the content hash of the export the snapshot was recorded
+	// from, so importing the same export again is a no-op
+	Source string `json:"source,omitempty"`
+}
+
+func newSnapshot(holdings *Holdings) *Snapshot {
+	snapshot := &Snapshot{
+		AsOf:     holdings.AsOf,
+		Amounts:  make(map[string]int, len(holdings.Amounts)),
+		Cash:     make(map[string]int, len(holdings.Cash)),
+		Accounts: make(map[string]int, len(holdings.AccountTotals)),
+	}
+	for symbol, amount := range holdings.Amounts {
+		snapshot.Amounts[symbol] = amount
+		snapshot.Total += amount
+	}
+	for account, amount := range holdings.Cash {
+		snapshot.Cash[account] = amount
+		snapshot.Total += amount
+	}
+	for account, amount := range holdings.AccountTotals {
+		snapshot.Accounts[account] = amount
+	}
+	if len(holdings.Liabilities) > 0 {
+		snapshot.Liabilities = maps.Clone(holdings.Liabilities)
+	}
+	return snapshot
+}
+
+// holdings is what the snapshot kept of the holdings it was recorded from:
+// amounts, cash, account totals, and liabilities, without prices or where
+// each symbol is held
+func (s *Snapshot) holdings() *Holdings {
+	return &Holdings{
+		Amounts:       maps.Clone(s.Amounts),
+		Cash:          maps.Clone(s.Cash),
+		AccountTotals: maps.Clone(s.Accounts),
+		Liabilities:   maps.Clone(s.Liabilities),
+		AsOf:          s.AsOf,
+		AsOfSource:    asOfSnapshot,
+	}
+}
+
+// currentHoldings reads the portfolio at path as rebalance does, or without
+// one, takes the holdings of the config's latest snapshot, checked for
+// staleness as an export is
+func currentHoldings(config *Config, path string) (*Holdings, error) {
+	if path != "" {
+		return loadPortfolio(config, path)
+	}
+	if config.Snapshots == "" {
+		return nil, codedErrorf(CodeUsage, "no portfolio; pass a portfolio CSV or set snapshots in the config")
+	}
+	snapshots, err := readSnapshots(config.Snapshots)
+	if err != nil {
+		return nil, fmt.Errorf("reading snapshots: %w", err)
+	}
+	if len(snapshots) == 0 {
+		return nil, codedErrorf(CodeUsage, "no snapshots in %s; record one with snapshot or pass a portfolio CSV", config.Snapshots)
+	}
+	holdings := snapshots[len(snapshots)-1].holdings()
+	warning, err := checkStaleness(config, holdings.AsOf, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if warning != nil {
+		holdings.Warnings = append(holdings.Warnings, *warning)
+	}
+	printWarnings(os.Stderr, holdings.Warnings)
+	return holdings, nil
+}
+
+func snapshot(config *Config, args []string) {
+	recordSnapshot(config, "snapshot", args)
+}
+
+// recordSnapshot records a snapshot of a portfolio, for the snapshot and
+// history import commands. An export that's already recorded, by its content
+// or its as-of time, is skipped unless -force replaces its snapshot.
+func recordSnapshot(config *Config, name string, args []string) {
+	var force bool
+	flagSet := flag.NewFlagSet(name, flag.ExitOnError)
+	flagSet.BoolVar(&force, "force", false, "Import an export that's already recorded again, replacing its snapshot")
+	if len(args) < 1 {
+		flag.Usage()
+		return
+	}
+	portfolioCsv := args[0]
+	flagSet.Parse(args[1:])
+	if config.Snapshots == "" {
+		printError(codedErrorf(CodeConfigInvalid, "config has no snapshots file"))
+		return
+	}
+
+	holdings, err := loadPortfolio(config, portfolioCsv)
+	if err != nil {
+		printError(err)
+		return
+	}
+	s := newSnapshot(holdings)
+	if s.Source, err = exportHash(portfolioCsv); err != nil {
+		printError(err)
+		return
+	}
+	guard, err := guardStore(config.Snapshots)
+	if err != nil {
+		printError(fmt.Errorf("recording snapshot: %w", err))
+		return
+	}
+	existing, err := readSnapshots(config.Snapshots)
+	if err != nil {
+		printError(fmt.Errorf("recording snapshot: %w", err))
+		return
+	}
+	asOf := formatAsOf(holdings.AsOf, holdings.AsOfSource)
+	duplicates := duplicateSnapshots(existing, s)
+	if len(duplicates) > 0 && !force {
+		fmt.Printf("A snapshot as of %s is already recorded from this export; pass -force to import it again\n", duplicates[0].AsOf.Format(time.DateTime))
+		return
+	}
+	action := fmt.Sprintf("Record snapshot as of %s in %s", asOf, config.Snapshots)
+	var preview strings.Builder
+	if len(duplicates) > 0 {
+		action = fmt.Sprintf("Replace %d snapshots in %s", len(duplicates), config.Snapshots)
+		fmt.Fprintf(&preview, "%d snapshots will be replaced by the one as of %s (%s):\n", len(duplicates), asOf, formatAmount(s.Total, true))
+		for _, d := range duplicates {
+			fmt.Fprintf(&preview, "%s  %s\n", d.AsOf.Format(time.DateTime), formatAmount(d.Total, true))
+		}
+	}
+	if skipDryRun(action, preview.String()) {
+		return
+	}
+	if len(duplicates) > 0 {
+		if err := confirm(action, preview.String()); err != nil {
+			printError(err)
+			return
+		}
+		err = guard.write(func() error { return replaceSnapshots(config.Snapshots, existing, s) })
+	} else {
+		err = guard.write(func() error {
+			_, err := appendSnapshot(config.Snapshots, s)
+			return err
+		})
+	}
+	if err != nil {
+		printError(fmt.Errorf("recording snapshot: %w", err))
+		return
+	}
+	fmt.Printf("Recorded snapshot as of %s in %s\n", asOf, config.Snapshots)
+}
+
+// exportHash is the content hash of the files a portfolio path names, which
+// identifies an export however its file is named or dated. A plugin has no
+// content to hash, so a path with one has no hash.
+func exportHash(path string) (string, error) {
+	hash := sha256.New()
+	for _, path := range portfolioPaths(path) {
+		if strings.HasPrefix(path, pluginPrefix) {
+			return "", nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(hash, file)
+		file.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)[:8]), nil
+}
+
+// configHash is the content hash of the config as parsed, which changes with
+// any setting but not with comments or formatting
+func configHash(config *Config) (string, error) {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:8]), nil
+}
+
+// readSnapshots loads every snapshot in path, oldest first. A missing file
+// has no snapshots.
+func readSnapshots(path string) ([]Snapshot, error) {
+	read := readJSONLines[Snapshot]
+	if isCSVLedger(path) {
+		read = readSnapshotsCSV
+	}
+	snapshots, err := read(path)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool { return snapshots[i].AsOf.Before(snapshots[j].AsOf) })
+	return snapshots, nil
+}
+
+// appendSnapshot adds snapshot to path unless one from the same export or
+// with the same as-of time is already there, so recording the same export
+// twice is harmless
+func appendSnapshot(path string, snapshot *Snapshot) (bool, error) {
+	existing, err := readSnapshots(path)
+	if err != nil {
+		return false, err
+	}
+	if len(duplicateSnapshots(existing, snapshot)) > 0 {
+		return false, nil
+	}
+	if isCSVLedger(path) {
+		return true, appendSnapshotsCSV(path, []Snapshot{*snapshot})
+	}
+	return true, appendJSONLines(path, []*Snapshot{snapshot})
+}
+
+// sameExport reports whether other was recorded from the same export as s,
+// by its content hash, or as of the same time
+func (s *Snapshot) sameExport(other Snapshot) bool {
+	return other.AsOf.Equal(s.AsOf) || (s.Source != "" && other.Source == s.Source)
+}
+
+// duplicateSnapshots returns the snapshots recorded from the same export as s
+func duplicateSnapshots(snapshots []Snapshot, s *Snapshot) []Snapshot {
+	var duplicates []Snapshot
+	for _, existing := range snapshots {
+		if s.sameExport(existing) {
+			duplicates = append(duplicates, existing)
+		}
+	}
+	return duplicates
+}
+
+// replaceSnapshots rewrites path with the existing snapshots, less the
+// duplicates of s, and s
+func replaceSnapshots(path string, existing []Snapshot, s *Snapshot) error {
+	kept := slices.DeleteFunc(slices.Clone(existing), s.sameExport)
+	kept = append(kept, *s)
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].AsOf.Before(kept[j].AsOf) })
+	return writeSnapshots(path, kept)
+}
+
+// readJSONLines decodes each non-empty line of path as a T. A missing file
+// has no lines.
+func readJSONLines[T any](path string) ([]T, error) {
+	var values []T
+	err := scanJSONLines(path, func(value T) error {
+		values = append(values, value)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// scanJSONLines decodes each non-empty line of path as a T and passes it to
+// fn, one at a time, so a long file needn't fit in memory. A missing file
+// has no lines.
+func scanJSONLines[T any](path string, fn func(T) error) error {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var value T
+		if err := json.Unmarshal(scanner.Bytes(), &value); err != nil {
+			return fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		if err := fn(value); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// appendJSONLines writes each value to the end of path as a line of JSON,
+// creating the file if needed
+func appendJSONLines[T any](path string, values []T) error {
+	var buf bytes.Buffer
+	for _, value := range values {
+		line, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(line, '\n'))
+	}
+	return appendFile(path, &buf)
+}
+
+// appendFile copies r to the end of path, creating the file if needed
+func appendFile(path string, r io.Reader) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// sqliteMagic starts every SQLite database file
+const sqliteMagic = "SQLite format 3\x00"
+
+// sqliteDB reads the tables of a SQLite database file, such as a GnuCash
+// book saved in sqlite format, without a database driver. It only scans
+// whole tables; indexes and a write-ahead log beside the file are ignored.
+type sqliteDB struct {
+	data []byte
+	// pageSize is the size of each page; usable leaves out the bytes that
+	// extensions reserve at the end of each
+	pageSize, usable int
+	// roots maps each table's name to its b-tree's root page and columns
+	roots map[string]sqliteTable
+}
+
+type sqliteTable struct {
+	root    int
+	columns []string
+}
+
+// sqliteRow maps a row's column names to their values: nil, int64,
+// float64, string, or []byte
+type sqliteRow map[string]any
+
+func openSQLite(data []byte) (*sqliteDB, error) {
+	if len(data) < 100 || !bytes.HasPrefix(data, []byte(sqliteMagic)) {
+		return nil, errors.New("not a SQLite database")
+	}
+	pageSize := int(binary.BigEndian.Uint16(data[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return nil, fmt.Errorf("invalid page size %d", pageSize)
+	}
+	if encoding := binary.BigEndian.Uint32(data[56:60]); encoding > 1 {
+		return nil, errors.New("only UTF-8 databases can be read")
+	}
+	db := &sqliteDB{data: data, pageSize: pageSize, usable: pageSize - int(data[20])}
+
+	// The schema table, on page 1, lists each table's root page and the SQL
+	// that created it
+	schema, err := db.scan(1, []string{"type", "name", "tbl_name", "rootpage", "sql"})
+	if err != nil {
+		return nil, fmt.Errorf("reading the schema: %w", err)
+	}
+	db.roots = make(map[string]sqliteTable)
+	for _, row := range schema {
+		name, _ := row["name"].(string)
+		root, _ := row["rootpage"].(int64)
+		sql, _ := row["sql"].(string)
+		if row["type"] == "table" {
+			db.roots[name] = sqliteTable{root: int(root), columns: sqliteColumns(sql)}
+		}
+	}
+	return db, nil
+}
+
+// table reads every row of the named table
+func (db *sqliteDB) table(name string) ([]sqliteRow, error) {
+	table, ok := db.roots[name]
+	if !ok {
+		return nil, fmt.Errorf("no %s table", name)
+	}
+	rows, err := db.scan(table.root, table.columns)
+	if err != nil {
+		return nil, fmt.Errorf("reading the %s table: %w", name, err)
+	}
+	return rows, nil
+}
+
+// page returns page number n, counting from 1
+func (db *sqliteDB) page(n int) ([]byte, error) {
+	start := (n - 1) * db.pageSize
+	if n < 1 || start+db.pageSize > len(db.data) {
+		return nil, fmt.Errorf("page %d is out of range", n)
+	}
+	return db.data[start : start+db.pageSize], nil
+}
+
+// scan walks the table b-tree rooted at page root, decoding each row's
+// record into columns
+func (db *sqliteDB) scan(root int, columns []string) ([]sqliteRow, error) {
+	var rows []sqliteRow
+	visited := make(map[int]bool)
+	var walk func(n int) error
+	walk = func(n int) error {
+		if visited[n] {
+			return fmt.Errorf("page %d is linked twice", n)
+		}
+		visited[n] = true
+		page, err := db.page(n)
+		if err != nil {
+			return err
+		}
+		// Page 1 starts with the database header
+		header := page
+		if n == 1 {
+			header = page[100:]
+		}
+		kind, cells := header[0], int(binary.BigEndian.Uint16(header[3:5]))
+		pointers := header[8:]
+		if kind == 0x05 {
+			pointers = header[12:]
+		} else if kind != 0x0d {
+			return fmt.Errorf("page %d isn't a table page", n)
+		}
+		if len(pointers) < 2*cells {
+			return fmt.Errorf("page %d has too many cells", n)
+		}
+		for i := range cells {
+			offset := int(binary.BigEndian.Uint16(pointers[2*i:]))
+			if offset+4 > len(page) {
+				return fmt.Errorf("page %d has a cell out of range", n)
+			}
+			if kind == 0x05 {
+				// An interior cell points to the subtree of smaller rowids
+				if err := walk(int(binary.BigEndian.Uint32(page[offset:]))); err != nil {
+					return err
+				}
+				continue
+			}
+			payload, err := db.payload(page, offset)
+			if err != nil {
+				return fmt.Errorf("page %d: %w", n, err)
+			}
+			values, err := sqliteRecord(payload)
+			if err != nil {
+				return fmt.Errorf("page %d: %w", n, err)
+			}
+			row := make(sqliteRow, len(columns))
+			// Columns added after a row was written are missing from it
+			for j, column := range columns {
+				if j < len(values) {
+					row[column] = values[j]
+				} else {
+					row[column] = nil
+				}
+			}
+			rows = append(rows, row)
+		}
+		if kind == 0x05 {
+			return walk(int(binary.BigEndian.Uint32(header[8:12])))
+		}
+		return nil
+	}
+	return rows, walk(root)
+}
+
+// payload reads the record of the leaf cell at offset, following its
+// overflow pages if it doesn't fit on the page
+func (db *sqliteDB) payload(page []byte, offset int) ([]byte, error) {
+	size, n := sqliteVarint(page[offset:])
+	offset += n
+	_, n = sqliteVarint(page[offset:]) // the rowid
+	offset += n
+	total := int(size)
+	maxLocal := db.usable - 35
+	local := total
+	if total > maxLocal {
+		minLocal := (db.usable-12)*32/255 - 23
+		local = minLocal + (total-minLocal)%(db.usable-4)
+		if local > maxLocal {
+			local = minLocal
+		}
+	}
+	if offset+local > len(page) {
+		return nil, errors.New("a cell runs past the end of its page")
+	}
+	payload := append([]byte(nil), page[offset:offset+local]...)
+	if local == total {
+		return payload, nil
+	}
+	if offset+local+4 > len(page) {
+		return nil, errors.New("a cell runs past the end of its page")
+	}
+	next := int(binary.BigEndian.Uint32(page[offset+local:]))
+	for len(payload) < total {
+		overflow, err := db.page(next)
+		if err != nil {
+			return nil, err
+		}
+		next = int(binary.BigEndian.Uint32(overflow))
+		payload = append(payload, overflow[4:min(db.usable, 4+total-len(payload))]...)
+		if next == 0 && len(payload) < total {
+			return nil, errors.New("a record's overflow pages end early")
+		}
+	}
+	return payload, nil
+}
+
+// sqliteVarint decodes a SQLite variable-length integer, returning it and
+// its length in bytes
+func sqliteVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := range min(len(b), 9) {
+		if i == 8 {
+			return v<<8 | uint64(b[i]), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return v, len(b)
+}
+
+// sqliteRecord decodes a record: a header of serial types, one for each
+// value, then the values
+func sqliteRecord(payload []byte) ([]any, error) {
+	headerSize, n := sqliteVarint(payload)
+	if headerSize < uint64(n) || headerSize > uint64(len(payload)) {
+		return nil, errors.New("a record's header runs past its end")
+	}
+	header, body := payload[n:headerSize], payload[headerSize:]
+	var values []any
+	for len(header) > 0 {
+		serial, n := sqliteVarint(header)
+		header = header[n:]
+		size := 0
+		switch {
+		case serial >= 12:
+			size = int(serial-12) / 2
+		case serial >= 1 && serial <= 4:
+			size = int(serial)
+		case serial == 5:
+			size = 6
+		case serial == 6 || serial == 7:
+			size = 8
+		}
+		if size > len(body) {
+			return nil, errors.New("a record's value runs past its end")
+		}
+		field := body[:size]
+		body = body[size:]
+		switch {
+		case serial == 0:
+			values = append(values, nil)
+		case serial <= 6:
+			// Big-endian two's complement of the field's size
+			var v int64
+			for i, b := range field {
+				if i == 0 {
+					v = int64(int8(b))
+				} else {
+					v = v<<8 | int64(b)
+				}
+			}
+			values = append(values, v)
+		case serial == 7:
+			values = append(values, math.Float64frombits(binary.BigEndian.Uint64(field)))
+		case serial == 8 || serial == 9:
+			values = append(values, int64(serial-8))
+		case serial >= 12 && serial%2 == 0:
+			values = append(values, append([]byte(nil), field...))
+		case serial >= 13:
+			values = append(values, string(field))
+		default:
+			return nil, fmt.Errorf("unknown serial type %d", serial)
+		}
+	}
+	return values, nil
+}
+
+// sqliteColumns lists the column names in a CREATE TABLE statement, in order,
+// leaving out the table's constraints
+func sqliteColumns(sql string) []string {
+	start, end := strings.Index(sql, "("), strings.LastIndex(sql, ")")
+	if start < 0 || end < start {
+		return nil
+	}
+	// Split the definitions at the commas outside parentheses, such as the
+	// one in numeric(10, 2)
+	var definitions []string
+	depth, from := 0, start+1
+	for i := start + 1; i < end; i++ {
+		switch sql[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				definitions = append(definitions, sql[from:i])
+				from = i + 1
+			}
+		}
+	}
+	definitions = append(definitions, sql[from:end])
+
+	var columns []string
+	for _, definition := range definitions {
+		fields := strings.Fields(definition)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN":
+			continue
+		}
+		columns = append(columns, strings.Trim(fields[0], "\"`[]"))
+	}
+	return columns
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSQLiteTable(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("tests", "portfolios", "book-sqlite.gnucash"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := openSQLite(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The prices table spans interior pages
+	prices, err := db.table("prices")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prices) != 403 {
+		t.Errorf("Expected 403 prices, got %d", len(prices))
+	}
+	if price := prices[0]; price["value_num"] != int64(20000) || price["value_denom"] != int64(100) || price["source"] != "user:price" {
+		t.Errorf("Expected the first price of 20000/100, got %v", price)
+	}
+	// One description overflows its page
+	transactions, err := db.table("transactions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := transactions[0]["description"].(string); got != strings.Repeat("Opening balance ", 200) {
+		t.Errorf("Expected the overflowing description, got %d bytes", len(got))
+	}
+	if _, err := db.table("lots"); err == nil {
+		t.Error("Expected a missing table to be reported")
+	}
+	// Prices are stored past the first half of the file
+	truncated, err := openSQLite(data[:len(data)/2])
+	if err == nil {
+		_, err = truncated.table("prices")
+	}
+	if err == nil {
+		t.Error("Expected a truncated database to be reported")
+	}
+}
+
+func TestSQLiteRecord(t *testing.T) {
+	// A header of 6 bytes, then NULL, a 1-byte -2, a 2-byte 300, 1, and "hi"
+	record := []byte{6, 0, 1, 2, 9, 17, 0xfe, 0x01, 0x2c, 'h', 'i'}
+	values, err := sqliteRecord(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []any{nil, int64(-2), int64(300), int64(1), "hi"}
+	if !slices.Equal(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+	if _, err := sqliteRecord(record[:8]); err == nil {
+		t.Error("Expected a truncated record to be reported")
+	}
+	if v, n := sqliteVarint([]byte{0x81, 0x00}); v != 128 || n != 2 {
+		t.Errorf("Expected 128 in 2 bytes, got %d in %d", v, n)
+	}
+}
+
+func TestSQLiteColumns(t *testing.T) {
+	sql := `CREATE TABLE prices(guid text(32) PRIMARY KEY NOT NULL, "value_num" bigint, amount numeric(10, 2), PRIMARY KEY (guid), CONSTRAINT positive CHECK (amount > 0))`
+	expected := []string{"guid", "value_num", "amount"}
+	if got := sqliteColumns(sql); !slices.Equal(got, expected) {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxPreambleLines is how many lines may precede the header of a portfolio CSV
+const maxPreambleLines = 10
+
+// defaultStaleAfter is the export age that triggers a warning when the config
+// doesn't set stale_after
+const defaultStaleAfter = 3 * 24 * time.Hour
+
+// maxAge is set by the -maxAge flag. Zero means stale exports only warn.
+var maxAge time.Duration
+
+// Age is a duration written with day or week units, e.g. "7d", "2w", or "36h"
+type Age time.Duration
+
+func (a *Age) UnmarshalYAML(node *yaml.Node) error {
+	age, err := parseAge(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	*a = Age(age)
+	return nil
+}
+
+func parseAge(value string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if number, found := strings.CutSuffix(value, suffix); found {
+			n, err := strconv.ParseFloat(number, 64)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid age %q", value)
+			}
+			return time.Duration(n * float64(unit)), nil
+		}
+	}
+	age, err := time.ParseDuration(value)
+	if err != nil || age < 0 {
+		return 0, fmt.Errorf("invalid age %q (expected e.g. 7d, 2w, or 36h)", value)
+	}
+	return age, nil
+}
+
+// Sources of Holdings.AsOf
+const (
+	asOfExport       = "export"
+	asOfFileModified = "file modified"
+	asOfSnapshot     = "snapshot"
+)
+
+// formatAsOf describes when holdings were valued and where that time came from
+func formatAsOf(asOf time.Time, source string) string {
+	if asOf.IsZero() {
+		return "unknown"
+	}
+	layout := time.DateTime
+	if source == asOfExport {
+		layout = time.DateOnly
+	}
+	return fmt.Sprintf("%s (%s)", asOf.Format(layout), source)
+}
+
+var (
+	asOfLinePattern = regexp.MustCompile(`(?i)(date downloaded|as of|generated at)`)
+	asOfDatePattern = regexp.MustCompile(`\b(\d{4}-\d{2}-\d{2}|\d{1,2}/\d{1,2}/\d{4}|[A-Z][a-z]{2}-\d{1,2}-\d{4}|[A-Z][a-z]{2} \d{1,2} \d{4})\b`)
+	asOfLayouts     = []string{time.DateOnly, "1/2/2006", "Jan-2-2006", "Jan 2 2006"}
+)
+
+// parseAsOf finds an export date in a "Date downloaded", "as of", or
+// "Generated at" (E*TRADE) line
+func parseAsOf(record []string) (time.Time, bool) {
+	line := strings.Join(record, " ")
+	if !asOfLinePattern.MatchString(line) {
+		return time.Time{}, false
+	}
+	match := asOfDatePattern.FindString(line)
+	for _, layout := range asOfLayouts {
+		if date, err := time.ParseInLocation(layout, match, time.Local); err == nil {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// staleAfter is the age at which exports and cached prices are stale
+func (c *Config) staleAfter() time.Duration {
+	if c.StaleAfter != nil {
+		return time.Duration(*c.StaleAfter)
+	}
+	return defaultStaleAfter
+}
+
+// checkStaleness fails if the export is older than -maxAge and returns a
+// warning if it is older than the config's stale_after. Exports without a
+// date are not checked.
+func checkStaleness(config *Config, asOf, now time.Time) (*Warning, error) {
+	if asOf.IsZero() {
+		return nil, nil
+	}
+	age := now.Sub(asOf)
+	days := int(age.Hours() / 24)
+	if maxAge > 0 && age > maxAge {
+		return nil, fmt.Errorf("portfolio export is from %s (%d days old), older than -maxAge allows", asOf.Format(time.DateOnly), days)
+	}
+	if age > config.staleAfter() {
+		return &Warning{Code: WarningStaleExport, Message: fmt.Sprintf("portfolio export is from %s (%d days old); download a fresh one before trading", asOf.Format(time.DateOnly), days)}, nil
+	}
+	return nil, nil
+}
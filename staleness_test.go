@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAge(t *testing.T) {
+	tests := map[string]time.Duration{
+		"7d":   7 * 24 * time.Hour,
+		"2w":   14 * 24 * time.Hour,
+		"36h":  36 * time.Hour,
+		"1.5d": 36 * time.Hour,
+	}
+	for value, expected := range tests {
+		if actual, err := parseAge(value); err != nil || actual != expected {
+			t.Errorf("parseAge(%q): got %v (%v), expected %v", value, actual, err, expected)
+		}
+	}
+	for _, value := range []string{"", "7", "-1d", "soon"} {
+		if _, err := parseAge(value); err == nil {
+			t.Errorf("parseAge(%q): expected error", value)
+		}
+	}
+}
+
+func TestExportDate(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+
+	holdings := loadHoldings(t, config, "fidelity_export.csv")
+	if expected := time.Date(2026, time.October, 1, 0, 0, 0, 0, time.Local); !holdings.AsOf.Equal(expected) {
+		t.Errorf("AsOf mismatch: got %s, expected %s", holdings.AsOf, expected)
+	}
+	if holdings.Amounts["VTI"] != 7100000 {
+		t.Errorf("VTI amount mismatch: got %d, expected 7100000", holdings.Amounts["VTI"])
+	}
+
+	preamble := "Positions for account Brokerage as of 10:32 AM ET, 10/15/2026\n\nSymbol,Current Value\nVTI,$100.00\n"
+	holdings, err = readHoldings(config, strings.NewReader(preamble))
+	if err != nil {
+		t.Fatalf("readHoldings failed: %v", err)
+	}
+	if expected := time.Date(2026, time.October, 15, 0, 0, 0, 0, time.Local); !holdings.AsOf.Equal(expected) {
+		t.Errorf("AsOf mismatch: got %s, expected %s", holdings.AsOf, expected)
+	}
+
+	now := time.Date(2026, time.October, 17, 0, 0, 0, 0, time.Local)
+	defer func(age time.Duration) { maxAge = age }(maxAge)
+	maxAge = 7 * 24 * time.Hour
+	if warning, err := checkStaleness(config, holdings.AsOf, now); err != nil || warning != nil {
+		t.Errorf("Two-day-old export should pass -maxAge 7d without a warning: %v %v", warning, err)
+	}
+	if _, err := checkStaleness(config, now.AddDate(0, 0, -8), now); err == nil {
+		t.Error("Expected error for an export older than -maxAge")
+	}
+	maxAge = 0
+	if warning, err := checkStaleness(config, now.AddDate(0, 0, -5), now); err != nil || warning == nil || warning.Code != WarningStaleExport {
+		t.Errorf("Expected a warning for a five-day-old export, got %v (%v)", warning, err)
+	}
+	if warning, err := checkStaleness(config, time.Time{}, now); err != nil || warning != nil {
+		t.Errorf("Exports without a date should not be checked: %v %v", warning, err)
+	}
+}
+
+func TestAsOf(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+
+	holdings, err := loadPortfolio(config, filepath.Join("tests", "portfolios", "fidelity_export.csv"))
+	if err != nil {
+		t.Fatalf("loadPortfolio failed: %v", err)
+	}
+	if holdings.AsOfSource != asOfExport {
+		t.Errorf("AsOfSource mismatch: got %q, expected %q", holdings.AsOfSource, asOfExport)
+	}
+	result, err := allocationCalc(config, holdings, 0)
+	if err != nil {
+		t.Fatalf("allocationCalc failed: %v", err)
+	}
+	if actual := formatAsOf(result.AsOf, result.AsOfSource); actual != "2026-10-01 (export)" {
+		t.Errorf("formatAsOf mismatch: got %q", actual)
+	}
+
+	path := filepath.Join(t.TempDir(), "portfolio.csv")
+	if err := os.WriteFile(path, []byte("Symbol,Current Value\nVTI,$100.00\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	modified := time.Date(2026, time.October, 16, 18, 45, 0, 0, time.Local)
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	holdings, err = loadPortfolio(config, path)
+	if err != nil {
+		t.Fatalf("loadPortfolio failed: %v", err)
+	}
+	if actual := formatAsOf(holdings.AsOf, holdings.AsOfSource); actual != "2026-10-16 18:45:00 (file modified)" {
+		t.Errorf("formatAsOf mismatch: got %q", actual)
+	}
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StaticPosition is a holding that no export covers, such as I bonds at
+// TreasuryDirect, a CD, or a private fund, whose value is kept by hand
+type StaticPosition struct {
+	// Symbol is a configured stock or alternative, or a cash symbol; a
+	// liability's is any name for it
+	Symbol  string `yaml:"symbol"`
+	Value   Money  `yaml:"value"`
+	Account string `yaml:"account,omitempty"`
+	// Updated is when the value was last brought up to date
+	Updated time.Time `yaml:"updated,omitempty"`
+	// Liability marks a debt, such as a mortgage or loan, whose Value is
+	// owed. It counts against net worth but stays out of the portfolio.
+	Liability bool `yaml:"liability,omitempty"`
+}
+
+// primarySymbol returns the configured stock that symbol is, or is an
+// alternative to
+func (c *Config) primarySymbol(symbol string) (string, bool) {
+	for _, stock := range c.Stocks {
+		if stock.Symbol == symbol || slices.Contains(stock.Alternatives, symbol) {
+			return stock.Symbol, true
+		}
+	}
+	return "", false
+}
+
+func validateStaticPosition(config *Config, position StaticPosition) error {
+	if position.Liability {
+		if position.Symbol == "" {
+			return fmt.Errorf("liabilities need a symbol to name them")
+		}
+	} else if _, found := config.primarySymbol(position.Symbol); !found && !config.isCash(position.Symbol) {
+		return codedErrorf(CodeUnknownSymbol, "static position %s must be listed in stocks or be a cash symbol", position.Symbol)
+	}
+	if position.Value < 0 {
+		return fmt.Errorf("static position %s must not have a negative value", position.Symbol)
+	}
+	return nil
+}
+
+func validateStaticPositions(config *Config) error {
+	for _, position := range config.StaticPositions {
+		if err := validateStaticPosition(config, position); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// staticPositions returns the static positions in the config followed by
+// those in its static_positions_file, if it exists
+func staticPositions(config *Config) ([]StaticPosition, error) {
+	positions := slices.Clone(config.StaticPositions)
+	if config.StaticPositionsFile == "" {
+		return positions, nil
+	}
+	fromFile, err := readStaticPositionsFile(config)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", config.StaticPositionsFile, err)
+	}
+	for _, position := range fromFile {
+		if err := validateStaticPosition(config, position); err != nil {
+			return nil, fmt.Errorf("%s: %w", config.StaticPositionsFile, err)
+		}
+	}
+	return append(positions, fromFile...), nil
+}
+
+// readStaticPositionsYAML reads a list in the same form as static_positions
+func readStaticPositionsYAML(r io.Reader) ([]StaticPosition, error) {
+	var positions []StaticPosition
+	if err := yaml.NewDecoder(r).Decode(&positions); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return positions, nil
+}
+
+// readStaticPositionsCSV reads Symbol and Value columns, with optional
+// Account, Updated (YYYY-MM-DD), and Liability (true or false) columns
+func readStaticPositionsCSV(r io.Reader) ([]StaticPosition, error) {
+	reader := newCSVReader(r)
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading header: %w", err)
+	}
+	header = normalizeHoldingsHeader(header)
+	symbolIndex := holdingsColumn(header, "Symbol")
+	valueIndex := holdingsColumn(header, "Current Value")
+	if symbolIndex == -1 || valueIndex == -1 {
+		return nil, codedErrorf(CodeCSVHeader, "static positions CSV must have 'Symbol' and 'Value' columns")
+	}
+	accountIndex := holdingsColumn(header, "Account Name")
+	updatedIndex := slices.Index(header, "Updated")
+	liabilityIndex := slices.Index(header, "Liability")
+
+	var positions []StaticPosition
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		position := StaticPosition{Symbol: strings.TrimSpace(field(record, symbolIndex)), Account: field(record, accountIndex)}
+		if position.Symbol == "" {
+			continue
+		}
+		value, err := amountToInt(field(record, valueIndex))
+		if err != nil {
+			return nil, codedErrorf(CodeCSVValue, "error parsing value of %s: %w", position.Symbol, err)
+		}
+		position.Value = Money(value)
+		if updated := field(record, updatedIndex); updated != "" {
+			if position.Updated, err = time.Parse(time.DateOnly, updated); err != nil {
+				return nil, codedErrorf(CodeCSVValue, "error parsing updated date of %s: %w", position.Symbol, err)
+			}
+		}
+		if liability := field(record, liabilityIndex); liability != "" {
+			if position.Liability, err = strconv.ParseBool(liability); err != nil {
+				return nil, codedErrorf(CodeCSVValue, "error parsing liability of %s: %w", position.Symbol, err)
+			}
+		}
+		positions = append(positions, position)
+	}
+	return positions, nil
+}
+
+// addStaticPositions merges the static positions into holdings read from an
+// export, so that they count toward the total before drift is worked out.
+// Liabilities are kept apart in holdings.Liabilities.
+func addStaticPositions(config *Config, holdings *Holdings) error {
+	positions, err := staticPositions(config)
+	if err != nil {
+		return err
+	}
+	for _, position := range positions {
+		value := int(position.Value)
+		if position.Liability {
+			if holdings.Liabilities == nil {
+				holdings.Liabilities = make(map[string]int)
+			}
+			holdings.Liabilities[positionName(position)] += value
+			continue
+		}
+		holdings.AccountTotals[position.Account] += value
+		holdings.Static = append(holdings.Static, position)
+		primary, found := config.primarySymbol(position.Symbol)
+		if !found {
+			holdings.Cash[position.Account] += value
+			continue
+		}
+		holdings.Amounts[primary] += value
+		holdings.Positions = append(holdings.Positions, Position{Account: position.Account, Symbol: position.Symbol, Primary: primary, Value: value})
+		if account := config.account("", position.Account); account != nil {
+			if holdings.AmountsByAccount[primary] == nil {
+				holdings.AmountsByAccount[primary] = make(map[string]int)
+			}
+			holdings.AmountsByAccount[primary][account.Name] += value
+		}
+	}
+	return nil
+}
+
+func printStaticPositions(holdings *Holdings) {
+	for _, position := range holdings.Static {
+		details := []string{formatAmount(int(position.Value), true)}
+		if position.Account != "" {
+			details = append(details, position.Account)
+		}
+		if !position.Updated.IsZero() {
+			details = append(details, "updated "+position.Updated.Format(time.DateOnly))
+		}
+		fmt.Printf("%s (%s) is a static position\n", position.Symbol, strings.Join(details, ", "))
+	}
+}
+
+// staticPositionsCSVHeader is the header holdings set writes to a CSV file
+var staticPositionsCSVHeader = []string{"Symbol", "Value", "Account", "Updated", "Liability"}
+
+// readStaticPositionsFile returns the positions in the config's
+// static_positions_file alone. A missing file has none.
+func readStaticPositionsFile(config *Config) ([]StaticPosition, error) {
+	file, err := os.Open(config.StaticPositionsFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if isCSVLedger(config.StaticPositionsFile) {
+		return readStaticPositionsCSV(file)
+	}
+	return readStaticPositionsYAML(file)
+}
+
+// encodeStaticPositions writes positions in the form the file's name calls
+// for, with values in dollars and dates without a time
+func encodeStaticPositions(path string, positions []StaticPosition) ([]byte, error) {
+	var buf bytes.Buffer
+	if isCSVLedger(path) {
+		writer := csv.NewWriter(&buf)
+		writer.Write(staticPositionsCSVHeader)
+		for _, position := range positions {
+			updated := ""
+			if !position.Updated.IsZero() {
+				updated = position.Updated.Format(time.DateOnly)
+			}
+			liability := ""
+			if position.Liability {
+				liability = "true"
+			}
+			writer.Write([]string{position.Symbol, formatDecimal(int(position.Value)), position.Account, updated, liability})
+		}
+		writer.Flush()
+		return buf.Bytes(), writer.Error()
+	}
+
+	list := &yaml.Node{Kind: yaml.SequenceNode}
+	for _, position := range positions {
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		setMappingValue(node, "symbol", stringNode(position.Symbol))
+		setMappingValue(node, "value", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: formatDecimal(int(position.Value))})
+		if position.Account != "" {
+			setMappingValue(node, "account", stringNode(position.Account))
+		}
+		if !position.Updated.IsZero() {
+			setMappingValue(node, "updated", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!timestamp", Value: position.Updated.Format(time.DateOnly)})
+		}
+		if position.Liability {
+			setMappingValue(node, "liability", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "true"})
+		}
+		list.Content = append(list.Content, node)
+	}
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(list); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), encoder.Close()
+}
+
+// holdings records hand-valued positions in the config's static_positions_file
+func holdings(config *Config, args []string) {
+	if len(args) < 1 || !slices.Contains([]string{"set", "remove", "list"}, args[0]) {
+		fmt.Println("Usage: fin-tilt holdings set <symbol> <amount> | remove <symbol> | list [-account <name>] [-date <YYYY-MM-DD>] [-liability]")
+		return
+	}
+	if config.StaticPositionsFile == "" {
+		printError(codedErrorf(CodeConfigInvalid, "config has no static_positions_file"))
+		return
+	}
+	action := args[0]
+	if action == "list" {
+		holdingsList(config)
+		return
+	}
+	var positional []string
+	for _, arg := range args[1:] {
+		if strings.HasPrefix(arg, "-") {
+			break
+		}
+		positional = append(positional, arg)
+	}
+	var account, dateStr string
+	var liability bool
+	flagSet := flag.NewFlagSet("holdings "+action, flag.ExitOnError)
+	flagSet.StringVar(&account, "account", "", "Account the position is held in")
+	if action == "set" {
+		flagSet.StringVar(&dateStr, "date", time.Now().Format(time.DateOnly), "Date the value was checked (YYYY-MM-DD)")
+		flagSet.BoolVar(&liability, "liability", false, "Record a debt, such as a mortgage, owed rather than held")
+	}
+	flagSet.Parse(args[1+len(positional):])
+	if want := map[string]int{"set": 2, "remove": 1}[action]; len(positional) != want {
+		printError(codedErrorf(CodeUsage, "holdings %s takes %d arguments, got %d", action, want, len(positional)))
+		return
+	}
+
+	position := StaticPosition{Symbol: positional[0], Account: account, Liability: liability}
+	if action == "set" {
+		value, err := amountToInt(positional[1])
+		if err != nil {
+			printError(codedErrorf(CodeUsage, "parsing amount: %w", err))
+			return
+		}
+		position.Value = Money(value)
+		if position.Updated, err = time.Parse(time.DateOnly, dateStr); err != nil {
+			printError(codedErrorf(CodeUsage, "parsing date: %w", err))
+			return
+		}
+		// A position being removed only has to be recorded
+		if err := validateStaticPosition(config, position); err != nil {
+			printError(err)
+			return
+		}
+	}
+	same := func(p StaticPosition) bool { return p.Symbol == position.Symbol && p.Account == position.Account }
+	if slices.ContainsFunc(config.StaticPositions, same) {
+		printError(codedErrorf(CodeUsage, "%s is declared in the config's static_positions; edit it there", positionName(position)))
+		return
+	}
+
+	guard, err := guardStore(config.StaticPositionsFile)
+	if err != nil {
+		printError(err)
+		return
+	}
+	positions, err := readStaticPositionsFile(config)
+	if err != nil {
+		printError(fmt.Errorf("reading %s: %w", config.StaticPositionsFile, err))
+		return
+	}
+	updated, change, err := setStaticPosition(positions, position, action == "remove")
+	if err != nil {
+		printError(err)
+		return
+	}
+	data, err := encodeStaticPositions(config.StaticPositionsFile, updated)
+	if err != nil {
+		printError(err)
+		return
+	}
+	if skipDryRun("Update "+config.StaticPositionsFile, change) {
+		return
+	}
+	if err := confirm("Update "+config.StaticPositionsFile, change+"\n"); err != nil {
+		printError(err)
+		return
+	}
+	if err := guard.write(func() error { return replaceFile(config.StaticPositionsFile, data) }); err != nil {
+		printError(fmt.Errorf("saving %s: %w", config.StaticPositionsFile, err))
+		return
+	}
+	fmt.Printf("Updated %s: %s\n", config.StaticPositionsFile, change)
+}
+
+// setStaticPosition replaces the value of the position with the same symbol
+// and account, adds it if there is none, or removes it, and describes the
+// change
+func setStaticPosition(positions []StaticPosition, position StaticPosition, remove bool) ([]StaticPosition, string, error) {
+	i := slices.IndexFunc(positions, func(p StaticPosition) bool { return p.Symbol == position.Symbol && p.Account == position.Account })
+	name := positionName(position)
+	switch {
+	case remove && i < 0:
+		return nil, "", codedErrorf(CodeUsage, "%s is not a recorded static position", name)
+	case remove:
+		return slices.Delete(slices.Clone(positions), i, i+1), fmt.Sprintf("%s %s -> removed", name, formatAmount(int(positions[i].Value), true)), nil
+	case i < 0:
+		return append(slices.Clone(positions), position), fmt.Sprintf("%s added at %s", name, formatAmount(int(position.Value), true)), nil
+	}
+	updated := slices.Clone(positions)
+	updated[i] = position
+	return updated, fmt.Sprintf("%s %s -> %s", name, formatAmount(int(positions[i].Value), true), formatAmount(int(position.Value), true)), nil
+}
+
+func positionName(position StaticPosition) string {
+	if position.Account == "" {
+		return position.Symbol
+	}
+	return fmt.Sprintf("%s (%s)", position.Symbol, position.Account)
+}
+
+func holdingsList(config *Config) {
+	positions, err := staticPositions(config)
+	if err != nil {
+		printError(err)
+		return
+	}
+	if len(positions) == 0 {
+		fmt.Println("No static positions")
+		return
+	}
+	for _, position := range positions {
+		updated := ""
+		if !position.Updated.IsZero() {
+			updated = "updated " + position.Updated.Format(time.DateOnly)
+		}
+		if position.Liability {
+			updated = strings.TrimSpace("liability " + updated)
+		}
+		fmt.Printf("%-8s %14s  %-20s %s\n", position.Symbol, formatAmount(int(position.Value), true), position.Account, updated)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAddStaticPositions(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "static.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	holdings := loadHoldings(t, config, "balanced.csv")
+	if err := addStaticPositions(config, holdings); err != nil {
+		t.Fatalf("addStaticPositions failed: %v", err)
+	}
+
+	// The I bonds in the config and the CD, an alternative, in the CSV file
+	if got := holdings.Amounts["IBOND"]; got != 1500000 {
+		t.Errorf("IBOND amount: got %d, expected 1500000", got)
+	}
+	if got := holdings.AmountsByAccount["IBOND"]["TreasuryDirect"]; got != 1000000 {
+		t.Errorf("Configured account mismatch: got %d, expected 1000000", got)
+	}
+	if got := holdings.Cash["Credit Union"]; got != 50000 {
+		t.Errorf("Cash mismatch: got %d, expected 50000", got)
+	}
+	if got := holdings.AccountTotals["Credit Union"]; got != 550000 {
+		t.Errorf("Account total mismatch: got %d, expected 550000", got)
+	}
+	if len(holdings.Static) != 3 || holdings.Static[1].Updated.Format("2006-01-02") != "2026-09-15" {
+		t.Errorf("Static positions mismatch: %+v", holdings.Static)
+	}
+
+	result, err := allocationCalc(config, holdings, 0)
+	if err != nil {
+		t.Fatalf("allocationCalc failed: %v", err)
+	}
+	if result.Total != 11500000 || result.Symbols["IBOND"].AmountNeeded != 225000 {
+		t.Errorf("Expected static positions in the total, got %d with IBOND needing %d", result.Total, result.Symbols["IBOND"].AmountNeeded)
+	}
+}
+
+func TestStaticPositionsYAMLFile(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	config.StaticPositionsFile = filepath.Join(t.TempDir(), "static.yaml")
+
+	// A missing file has no positions yet
+	if positions, err := staticPositions(config); err != nil || len(positions) != 0 {
+		t.Fatalf("Expected no positions, got %v (%v)", positions, err)
+	}
+
+	if err := os.WriteFile(config.StaticPositionsFile, []byte("- symbol: BND\n  value: $2,500.50\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	positions, err := staticPositions(config)
+	if err != nil || len(positions) != 1 || positions[0].Value != 250050 {
+		t.Errorf("Expected BND at 250050, got %v (%v)", positions, err)
+	}
+
+	if err := os.WriteFile(config.StaticPositionsFile, []byte("- symbol: GOLD\n  value: 100\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := staticPositions(config); errorCode(err) != CodeUnknownSymbol {
+		t.Errorf("Expected an unknown symbol error, got %v", err)
+	}
+}
+
+func TestSetStaticPosition(t *testing.T) {
+	positions := []StaticPosition{{Symbol: "IBOND", Value: 1000000, Account: "TreasuryDirect"}}
+
+	updated, change, err := setStaticPosition(positions, StaticPosition{Symbol: "IBOND", Value: 1050000, Account: "TreasuryDirect"}, false)
+	if err != nil || len(updated) != 1 || updated[0].Value != 1050000 || change != "IBOND (TreasuryDirect) $10,000.00 -> $10,500.00" {
+		t.Errorf("Update mismatch: %v, %q (%v)", updated, change, err)
+	}
+	if positions[0].Value != 1000000 {
+		t.Errorf("Expected the original positions to be left alone")
+	}
+
+	// The same symbol in another account is another position
+	updated, change, err = setStaticPosition(positions, StaticPosition{Symbol: "IBOND", Value: 500000, Account: "hsa"}, false)
+	if err != nil || len(updated) != 2 || change != "IBOND (hsa) added at $5,000.00" {
+		t.Errorf("Add mismatch: %v, %q (%v)", updated, change, err)
+	}
+
+	if updated, _, err = setStaticPosition(positions, StaticPosition{Symbol: "IBOND", Account: "TreasuryDirect"}, true); err != nil || len(updated) != 0 {
+		t.Errorf("Remove mismatch: %v (%v)", updated, err)
+	}
+	if _, _, err = setStaticPosition(positions, StaticPosition{Symbol: "IBOND"}, true); errorCode(err) != CodeUsage {
+		t.Errorf("Expected a usage error removing an unrecorded position, got %v", err)
+	}
+}
+
+func TestEncodeStaticPositions(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "static.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	positions := []StaticPosition{
+		{Symbol: "CD", Value: 1234567, Account: "hsa", Updated: time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)},
+		{Symbol: "CASH", Value: 5000},
+		{Symbol: "MORTGAGE", Value: 25000000, Liability: true},
+	}
+	for _, name := range []string{"static.yaml", "static.csv"} {
+		t.Run(name, func(t *testing.T) {
+			config.StaticPositionsFile = filepath.Join(t.TempDir(), name)
+			data, err := encodeStaticPositions(config.StaticPositionsFile, positions)
+			if err != nil {
+				t.Fatalf("encodeStaticPositions failed: %v", err)
+			}
+			if err := os.WriteFile(config.StaticPositionsFile, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			read, err := readStaticPositionsFile(config)
+			if err != nil {
+				t.Fatalf("readStaticPositionsFile failed: %v", err)
+			}
+			if !reflect.DeepEqual(read, positions) {
+				t.Errorf("Round trip mismatch:\n%s\ngot %+v", data, read)
+			}
+		})
+	}
+}
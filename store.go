@@ -0,0 +1,187 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// storeLockWait is how long a run waits for another to release a store
+var storeLockWait = 10 * time.Second
+
+const (
+	// storeLockStale is the age at which a lock is taken to be left behind
+	// by a run that crashed, or by one on a machine that went offline
+	storeLockStale = 10 * time.Minute
+	storeLockPoll  = 100 * time.Millisecond
+)
+
+// StoreLock is the contents of a store's lock file: the run that holds it
+type StoreLock struct {
+	Host string    `json:"host"`
+	PID  int       `json:"pid"`
+	Time time.Time `json:"time"`
+}
+
+// StoreGuard protects a local store that runs on several machines may write,
+// such as a snapshots file in an iCloud or Dropbox folder. A lock file syncs
+// to the other machines, unlike an flock, but only after a delay, so a write
+// also checks that the store is still what the run read.
+type StoreGuard struct {
+	path string
+	// sum is the checksum of the store when it was read; "" if it was missing
+	sum string
+}
+
+// guardStore is called before a store is read. Writing is refused while
+// sync conflicts are unresolved, as the copies hold changes the store lacks.
+func guardStore(path string) (*StoreGuard, error) {
+	copies, err := conflictedCopies(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(copies) > 0 {
+		return nil, codedErrorf(CodeStoreConflict, "%s has conflicting copies from a sync: %s; merge them into it and delete them", path, strings.Join(copies, ", "))
+	}
+	sum, err := storeChecksum(path)
+	if err != nil {
+		return nil, err
+	}
+	return &StoreGuard{path: path, sum: sum}, nil
+}
+
+// write runs fn, which writes the store, holding its lock, if the store is
+// unchanged since guardStore
+func (g *StoreGuard) write(fn func() error) error {
+	unlock, err := lockStore(g.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	sum, err := storeChecksum(g.path)
+	if err != nil {
+		return err
+	}
+	if sum != g.sum {
+		return codedErrorf(CodeStoreConflict, "%s changed while this run was using it, by another run or a sync; run the command again", g.path)
+	}
+	return fn()
+}
+
+func storeChecksum(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// conflictedCopies lists the copies of path that sync services leave when
+// two machines change it at once: Dropbox's "name (host's conflicted copy
+// date).ext", iCloud's "name 2.ext", Google Drive's "name (1).ext", and
+// Syncthing's "name.sync-conflict-date.ext"
+func conflictedCopies(path string) ([]string, error) {
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	ext := filepath.Ext(name)
+	stem := regexp.QuoteMeta(strings.TrimSuffix(name, ext))
+	pattern := regexp.MustCompile(`^` + stem + `( \(.*\)| \d+|\.sync-conflict-[^.]*)` + regexp.QuoteMeta(ext) + `$`)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var copies []string
+	for _, entry := range entries {
+		if !entry.IsDir() && pattern.MatchString(entry.Name()) {
+			copies = append(copies, entry.Name())
+		}
+	}
+	return copies, nil
+}
+
+// lockStore takes the advisory lock on path, a file beside it named
+// path.lock, waiting up to storeLockWait for another run to release it. A
+// stale lock, or one held by a process of this host that has exited, is
+// taken over. The returned function releases the lock if it's still ours.
+func lockStore(path string) (func(), error) {
+	lockPath := path + ".lock"
+	host, _ := os.Hostname()
+	self := StoreLock{Host: host, PID: os.Getpid(), Time: time.Now().UTC()}
+	data, err := json.Marshal(self)
+	if err != nil {
+		return nil, err
+	}
+	// A store in the data directory may be the first thing written there
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(storeLockWait)
+	for {
+		file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			_, err = file.Write(data)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(lockPath)
+				return nil, err
+			}
+			return func() {
+				if holder, err := readStoreLock(lockPath); err == nil && holder.Host == self.Host && holder.PID == self.PID && holder.Time.Equal(self.Time) {
+					os.Remove(lockPath)
+				}
+			}, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+		holder, err := readStoreLock(lockPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err == nil && holder.stale(host) {
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				return nil, codedErrorf(CodeLocked, "%s is locked by %s, which can't be read: %w", path, lockPath, err)
+			}
+			return nil, codedErrorf(CodeLocked, "%s is locked by process %d on %s since %s; if no run is still going, delete %s", path, holder.PID, holder.Host, holder.Time.Local().Format(time.DateTime), lockPath)
+		}
+		time.Sleep(storeLockPoll)
+	}
+}
+
+func readStoreLock(path string) (StoreLock, error) {
+	var lock StoreLock
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return lock, err
+	}
+	return lock, json.Unmarshal(data, &lock)
+}
+
+// stale reports whether the lock was left behind: it's older than
+// storeLockStale, or its process on this host has exited
+func (l StoreLock) stale(host string) bool {
+	if time.Since(l.Time) > storeLockStale {
+		return true
+	}
+	return l.Host == host && !processExists(l.PID)
+}
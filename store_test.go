@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLockStore(t *testing.T) {
+	saved := storeLockWait
+	t.Cleanup(func() { storeLockWait = saved })
+	storeLockWait = 0
+	path := filepath.Join(t.TempDir(), "snapshots.jsonl")
+
+	unlock, err := lockStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockStore(path); errorCode(err) != CodeLocked {
+		t.Errorf("Expected a held lock to be reported, got %v", err)
+	}
+	unlock()
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("Expected the lock file to be removed, got %v", err)
+	}
+
+	// A lock from another machine is waited on until it's stale
+	writeLock := func(lock StoreLock) {
+		data, _ := json.Marshal(lock)
+		if err := os.WriteFile(path+".lock", data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeLock(StoreLock{Host: "other-host", PID: os.Getpid(), Time: time.Now()})
+	if _, err := lockStore(path); errorCode(err) != CodeLocked {
+		t.Errorf("Expected another host's lock to be waited on, got %v", err)
+	}
+	writeLock(StoreLock{Host: "other-host", PID: os.Getpid(), Time: time.Now().Add(-storeLockStale - time.Minute)})
+	unlock, err = lockStore(path)
+	if err != nil {
+		t.Fatalf("Expected a stale lock to be taken over, got %v", err)
+	}
+	unlock()
+}
+
+func TestConflictedCopies(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"snapshots.jsonl",
+		"snapshots (MacBook's conflicted copy 2026-10-01).jsonl",
+		"snapshots 2.jsonl",
+		"snapshots.sync-conflict-20261001-101010-ABCDEF.jsonl",
+		"snapshots.jsonl.lock",
+		"snapshots-old.jsonl",
+		"transactions 2.jsonl",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	copies, err := conflictedCopies(filepath.Join(dir, "snapshots.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"snapshots (MacBook's conflicted copy 2026-10-01).jsonl",
+		"snapshots 2.jsonl",
+		"snapshots.sync-conflict-20261001-101010-ABCDEF.jsonl",
+	}
+	if !slices.Equal(copies, want) {
+		t.Errorf("Expected the conflicted copies %q, got %q", want, copies)
+	}
+	if _, err := guardStore(filepath.Join(dir, "snapshots.jsonl")); errorCode(err) != CodeStoreConflict {
+		t.Errorf("Expected conflicted copies to stop a write, got %v", err)
+	}
+}
+
+func TestStoreGuard(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transactions.jsonl")
+	guard, err := guardStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Another machine's write syncs in while this run has the store read
+	if err := os.WriteFile(path, []byte("{}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	written := false
+	err = guard.write(func() error {
+		written = true
+		return nil
+	})
+	if errorCode(err) != CodeStoreConflict || written {
+		t.Errorf("Expected a changed store not to be written, got %v", err)
+	}
+
+	if guard, err = guardStore(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := guard.write(func() error { return appendJSONLines(path, []int{1}) }); err != nil {
+		t.Errorf("Expected an unchanged store to be written, got %v", err)
+	}
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("Expected the lock to be released, got %v", err)
+	}
+}
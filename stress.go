@@ -0,0 +1,256 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// StressScenario is a set of market shocks applied to the portfolio at once
+type StressScenario struct {
+	Name string `yaml:"name" json:"name"`
+	// Equities is the percent change in the price of every stock without a
+	// duration
+	Equities float64 `yaml:"equities,omitempty" json:"equities,omitempty"`
+	// Rates is the change in interest rates, in percentage points, which
+	// moves each bond fund's price by its duration times the opposite
+	Rates float64 `yaml:"rates,omitempty" json:"rates,omitempty"`
+	// USD is the percent change in the dollar against other currencies,
+	// which moves the unhedged foreign currency the other way
+	USD float64 `yaml:"usd,omitempty" json:"usd,omitempty"`
+	// Symbols set the price change, in percent, of particular stocks instead
+	// of the equity or rate shock
+	Symbols map[string]float64 `yaml:"symbols,omitempty" json:"symbols,omitempty"`
+}
+
+// defaultStressScenarios are run when the config has none and no shock is
+// given on the command line
+var defaultStressScenarios = []StressScenario{
+	{Name: "Equities -30%", Equities: -30},
+	{Name: "Rates +2%", Rates: 2},
+	{Name: "USD -10%", USD: -10},
+}
+
+// StressSymbol is one stock before and after a scenario's shocks
+type StressSymbol struct {
+	Symbol string `json:"symbol"`
+	// Change is the percent change in the stock's value
+	Change     float64 `json:"change"`
+	Before     int     `json:"before"`
+	After      int     `json:"after"`
+	Percentage float64 `json:"percentage"`
+	Drift      float64 `json:"drift"`
+	// Breached means the drift is outside the band after the shock
+	Breached bool `json:"breached"`
+}
+
+type StressResult struct {
+	Scenario StressScenario `json:"scenario"`
+	Before   int            `json:"before"`
+	After    int            `json:"after"`
+	Symbols  []StressSymbol `json:"symbols"`
+	// Breached lists the symbols the shocks push outside the band
+	Breached []string `json:"breached"`
+	Urgency  Urgency  `json:"urgency"`
+}
+
+func validateStress(config *Config) error {
+	for _, stock := range config.Stocks {
+		if stock.Duration < 0 {
+			return fmt.Errorf("duration of %s must not be negative", stock.Symbol)
+		}
+	}
+	seen := make(map[string]bool)
+	for _, scenario := range config.StressScenarios {
+		if scenario.Name == "" {
+			return fmt.Errorf("stress scenarios need a name")
+		}
+		if seen[scenario.Name] {
+			return fmt.Errorf("stress scenario %q appears multiple times", scenario.Name)
+		}
+		seen[scenario.Name] = true
+		if err := scenario.validate(config); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *StressScenario) validate(config *Config) error {
+	if s.Equities <= -100 || s.USD <= -100 {
+		return fmt.Errorf("stress scenario %q must not shock equities or the dollar by -100%% or more", s.Name)
+	}
+	for symbol, change := range s.Symbols {
+		if !slices.ContainsFunc(config.Stocks, func(stock Stock) bool { return stock.Symbol == symbol }) {
+			return codedErrorf(CodeUnknownSymbol, "stress scenario %q shocks %s, which is not a configured stock", s.Name, symbol)
+		}
+		if change <= -100 {
+			return fmt.Errorf("stress scenario %q must not shock %s by -100%% or more", s.Name, symbol)
+		}
+	}
+	return nil
+}
+
+func stress(config *Config, args []string) {
+	var format string
+	var scenario StressScenario
+	flagSet := flag.NewFlagSet("stress", flag.ExitOnError)
+	flagSet.Float64Var(&scenario.Equities, "equities", 0, "Percent change in equity prices, e.g. -30")
+	flagSet.Float64Var(&scenario.Rates, "rates", 0, "Change in interest rates in percentage points, e.g. 2")
+	flagSet.Float64Var(&scenario.USD, "usd", 0, "Percent change in the dollar, e.g. -10")
+	flagSet.StringVar(&format, "format", "text", "Output format: text or json")
+	if len(args) < 1 {
+		flag.Usage()
+		return
+	}
+	portfolioCsv := args[0]
+	flagSet.Parse(args[1:])
+	if format != "text" && format != "json" {
+		printError(codedErrorf(CodeUsage, "unknown format %q", format))
+		return
+	}
+	scenarios := config.StressScenarios
+	if len(scenarios) == 0 {
+		scenarios = defaultStressScenarios
+	}
+	// Shocks on the command line make a scenario of their own
+	var names []string
+	flagSet.Visit(func(f *flag.Flag) {
+		if f.Name != "format" {
+			names = append(names, fmt.Sprintf("%s %s", f.Name, f.Value))
+		}
+	})
+	if len(names) > 0 {
+		scenario.Name = strings.Join(names, ", ")
+		if err := scenario.validate(config); err != nil {
+			printError(codedErrorf(CodeUsage, "%w", err))
+			return
+		}
+		scenarios = []StressScenario{scenario}
+	}
+
+	holdings, err := loadPortfolio(config, portfolioCsv)
+	if err != nil {
+		printError(err)
+		return
+	}
+	var results []StressResult
+	for _, scenario := range scenarios {
+		result, err := stressCalc(config, holdings, scenario)
+		if err != nil {
+			printError(err)
+			return
+		}
+		results = append(results, *result)
+	}
+
+	if format == "json" {
+		if err := writeJSON(os.Stdout, results); err != nil {
+			printError(err)
+		}
+		return
+	}
+	for _, result := range results {
+		printStress(config, &result)
+	}
+}
+
+// stressChange is the percent change in a stock's value under the scenario:
+// its own shock, or else the equity shock or, for a stock with a duration,
+// the rate shock, compounded with the change in its unhedged foreign currency
+func stressChange(stock Stock, scenario StressScenario) float64 {
+	change, ok := scenario.Symbols[stock.Symbol]
+	if !ok {
+		change = scenario.Equities
+		if stock.Duration > 0 {
+			change = -stock.Duration * scenario.Rates
+		}
+	}
+	factor := 1 + max(change, -100)/100
+	if foreign := stock.foreignCurrency(); foreign > 0 && !stock.Hedged && scenario.USD != 0 {
+		// A dollar worth USD% more buys back 1/(1+USD%) of the currency's value
+		currency := 100/(100+scenario.USD) - 1
+		factor *= 1 + currency*foreign/100
+	}
+	return (factor - 1) * 100
+}
+
+// stressCalc applies the scenario's shocks to the holdings and works out the
+// drift that results. Cash is left as it is. A symbol is breached when its
+// drift is outside the band policy's band, or remind's band without one.
+func stressCalc(config *Config, holdings *Holdings, scenario StressScenario) (*StressResult, error) {
+	shocked := holdings.clone()
+	result := &StressResult{Scenario: scenario, Breached: []string{}}
+	changes := make(map[string]float64)
+	for _, stock := range config.Stocks {
+		changes[stock.Symbol] = stressChange(stock, scenario)
+		before := holdings.Amounts[stock.Symbol]
+		shocked.Amounts[stock.Symbol] = int(math.Round(float64(before) * (1 + changes[stock.Symbol]/100)))
+		result.Before += before
+		result.After += shocked.Amounts[stock.Symbol]
+	}
+	for _, cash := range holdings.Cash {
+		result.Before += cash
+		result.After += cash
+	}
+	allocation, err := allocationCalc(config, shocked, 0)
+	if err != nil {
+		return nil, err
+	}
+	result.Urgency = allocation.Urgency
+	for _, stock := range config.Stocks {
+		data := allocation.Symbols[stock.Symbol]
+		symbol := StressSymbol{
+			Symbol:     stock.Symbol,
+			Change:     changes[stock.Symbol],
+			Before:     holdings.Amounts[stock.Symbol],
+			After:      shocked.Amounts[stock.Symbol],
+			Percentage: data.CurrentPercentage,
+			Drift:      data.Drift,
+		}
+		if config.BandPolicy == "5/25" {
+			symbol.Breached = outsideSwedroeBands(data.TargetPercentage, data.CurrentPercentage)
+		} else {
+			symbol.Breached = math.Abs(data.Drift) > config.Remind.band()
+		}
+		if symbol.Breached {
+			result.Breached = append(result.Breached, stock.Symbol)
+		}
+		result.Symbols = append(result.Symbols, symbol)
+	}
+	return result, nil
+}
+
+func printStress(config *Config, result *StressResult) {
+	change := result.After - result.Before
+	percent := 0.0
+	if result.Before != 0 {
+		percent = float64(change) / float64(result.Before) * 100
+	}
+	fmt.Println(rule())
+	fmt.Printf("%s: %s -> %s (%s, %s)\n", result.Scenario.Name, formatAmount(result.Before, true), formatAmount(result.After, true), changeText(change), signed(percent, "%", "up", "down", "unchanged"))
+	fmt.Println(rule())
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, symbol := range result.Symbols {
+		line := fmt.Sprintf("%s\t%s\t%s -> %s\t%.2f%%\t%s", symbol.Symbol, signed(symbol.Change, "%", "up", "down", "unchanged"), formatAmount(symbol.Before, true), formatAmount(symbol.After, true), symbol.Percentage, driftText(symbol.Drift))
+		if symbol.Breached {
+			line += "\t" + red("outside band")
+		}
+		fmt.Fprintln(w, line)
+	}
+	w.Flush()
+	band := config.bandLabel()
+	if config.BandPolicy != "5/25" {
+		band = fmt.Sprintf("%g-point band", config.Remind.band())
+	}
+	if len(result.Breached) == 0 {
+		fmt.Println(green(fmt.Sprintf("Every symbol stays within the %s", band)))
+	} else {
+		fmt.Println(red(fmt.Sprintf("Outside the %s: %s", band, strings.Join(result.Breached, ", "))))
+	}
+	fmt.Println(urgencyText(result.Urgency))
+}
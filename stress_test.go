@@ -0,0 +1,56 @@
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestStressCalc(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	all := 100.0
+	config.Stocks[1].ForeignCurrency = &all
+	config.Stocks[2].Duration = 6
+	holdings := loadHoldings(t, config, "cash.csv")
+
+	// VTI and VXUS fall 30%, and BND's duration of 6 loses it 12% on 2 points
+	result, err := stressCalc(config, holdings, StressScenario{Name: "crash", Equities: -30, Rates: 2})
+	if err != nil {
+		t.Fatalf("stressCalc failed: %v", err)
+	}
+	changes := map[string]float64{}
+	for _, symbol := range result.Symbols {
+		changes[symbol.Symbol] = symbol.Change
+	}
+	if math.Abs(changes["VTI"]+30) > 1e-9 || math.Abs(changes["BND"]+12) > 1e-9 {
+		t.Errorf("Change mismatch: %v", changes)
+	}
+	if result.Before-result.After != 2130000+540000+132000 {
+		t.Errorf("Expected the portfolio to lose $28,020, got %d -> %d", result.Before, result.After)
+	}
+
+	// A falling dollar lifts the foreign fund by a ninth; an override stands
+	// in for the equity shock
+	result, err = stressCalc(config, holdings, StressScenario{Name: "dollar", Equities: -50, USD: -10, Symbols: map[string]float64{"VTI": 0}})
+	if err != nil {
+		t.Fatalf("stressCalc failed: %v", err)
+	}
+	vti, vxus := result.Symbols[0], result.Symbols[1]
+	if vti.After != vti.Before || math.Abs(vxus.Change-(0.5*100/90-1)*100) > 1e-9 {
+		t.Errorf("Change mismatch: %+v %+v", vti, vxus)
+	}
+	// VXUS falls from 18% to about 11% and VTI rises to 77%, both past the
+	// 5-point band
+	if !slices.Equal(result.Breached, []string{"VTI", "VXUS"}) {
+		t.Errorf("Expected VTI and VXUS to breach the band, got %v", result.Breached)
+	}
+
+	config.StressScenarios = []StressScenario{{Name: "bad", Symbols: map[string]float64{"AAPL": -50}}}
+	if err := config.validate(); err == nil {
+		t.Error("Expected an error for a scenario shocking an unconfigured symbol")
+	}
+}
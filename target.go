@@ -0,0 +1,315 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StockTarget is a symbol's target percentage
+type StockTarget struct {
+	Symbol     string  `json:"symbol"`
+	Percentage float64 `json:"target_percentage"`
+}
+
+// target edits the config's stocks and returns the process exit code. It
+// runs before the config is validated so that it can repair one whose
+// targets don't add up.
+func target(configPath string, args []string) int {
+	if len(args) < 1 || !slices.Contains([]string{"set", "add", "remove"}, args[0]) {
+		fmt.Println("Usage: fin-tilt target set <symbol> <percent>... | add <symbol> <percent> | remove <symbol> [-balance <symbol>] [-scale]")
+		return 1
+	}
+	action := args[0]
+	var positional []string
+	for _, arg := range args[1:] {
+		if strings.HasPrefix(arg, "-") {
+			break
+		}
+		positional = append(positional, arg)
+	}
+
+	var balance, description string
+	var scale bool
+	flagSet := flag.NewFlagSet("target "+action, flag.ExitOnError)
+	flagSet.StringVar(&balance, "balance", "", "Offset the change against this symbol's target")
+	flagSet.BoolVar(&scale, "scale", false, "Scale the other targets proportionally to make up the change")
+	if action == "add" {
+		flagSet.StringVar(&description, "description", "", "Description of the new stock")
+	}
+	flagSet.Parse(args[1+len(positional):])
+
+	changes, err := parseTargetChanges(action, positional)
+	if err != nil {
+		printError(err)
+		return 1
+	}
+
+	config, err := readConfigDocument(configPath)
+	if err != nil {
+		printError(err)
+		return 1
+	}
+	stocks, err := stocksNode(config)
+	if err != nil {
+		printError(err)
+		return 1
+	}
+
+	original := stockTargets(stocks)
+	targets := slices.Clone(original)
+	if action == "add" {
+		if slices.ContainsFunc(targets, func(t StockTarget) bool { return t.Symbol == changes[0].Symbol }) {
+			printError(codedErrorf(CodeUsage, "%s is already in the config's stocks", changes[0].Symbol))
+			return 1
+		}
+		targets = append(targets, StockTarget{Symbol: changes[0].Symbol})
+	}
+	updated, err := retarget(targets, changes, balance, scale)
+	if err != nil {
+		printError(err)
+		return 1
+	}
+	if action == "remove" {
+		updated = slices.DeleteFunc(updated, func(t StockTarget) bool { return t.Symbol == changes[0].Symbol })
+	}
+	setStockTargets(stocks, updated, description)
+
+	// Whatever else is wrong with the config must be fixed by hand first
+	rendered, err := config.render()
+	if err != nil {
+		printError(err)
+		return 1
+	}
+
+	summary := targetSummary(original, updated)
+	if skipDryRun("Write "+configPath, "Targets in "+configPath+":\n"+summary) {
+		return 0
+	}
+	if err := confirm("Write "+configPath, "Targets in "+configPath+":\n"+summary); err != nil {
+		printError(err)
+		return 1
+	}
+	if err := replaceFile(configPath, rendered); err != nil {
+		printError(fmt.Errorf("writing config: %w", err))
+		return 1
+	}
+	fmt.Printf("Updated %s:\n%s", configPath, summary)
+	return 0
+}
+
+// parseTargetChanges reads the symbol and percent arguments of a target
+// command. Removing a stock changes its target to 0.
+func parseTargetChanges(action string, args []string) ([]StockTarget, error) {
+	if action == "remove" {
+		if len(args) != 1 {
+			return nil, codedErrorf(CodeUsage, "target remove takes one symbol")
+		}
+		return []StockTarget{{Symbol: args[0]}}, nil
+	}
+	if len(args) == 0 || len(args)%2 != 0 || (action == "add" && len(args) != 2) {
+		return nil, codedErrorf(CodeUsage, "target %s takes a symbol and a percent", action)
+	}
+	var changes []StockTarget
+	for i := 0; i < len(args); i += 2 {
+		percentage, err := strconv.ParseFloat(strings.TrimSuffix(args[i+1], "%"), 64)
+		if err != nil {
+			return nil, codedErrorf(CodeUsage, "parsing percent for %s: %w", args[i], err)
+		}
+		changes = append(changes, StockTarget{Symbol: args[i], Percentage: percentage})
+	}
+	return changes, nil
+}
+
+// retarget applies changes to targets. The difference they make to the
+// total is taken from the balance symbol, spread over the unchanged targets
+// in proportion to their size with scale, or else must already be zero.
+func retarget(targets, changes []StockTarget, balance string, scale bool) ([]StockTarget, error) {
+	if balance != "" && scale {
+		return nil, codedErrorf(CodeUsage, "pass -balance or -scale, not both")
+	}
+	updated := slices.Clone(targets)
+	index := func(symbol string) int {
+		return slices.IndexFunc(updated, func(t StockTarget) bool { return t.Symbol == symbol })
+	}
+	changed := make(map[string]bool)
+	difference := 0.0
+	for _, change := range changes {
+		if change.Percentage < 0 || change.Percentage > 100 {
+			return nil, codedErrorf(CodeUsage, "target for %s must be between 0 and 100, got %g", change.Symbol, change.Percentage)
+		}
+		i := index(change.Symbol)
+		if i < 0 {
+			return nil, codedErrorf(CodeUnknownSymbol, "%s is not in the config's stocks", change.Symbol)
+		}
+		if changed[change.Symbol] {
+			return nil, codedErrorf(CodeUsage, "%s is given more than once", change.Symbol)
+		}
+		changed[change.Symbol] = true
+		difference += change.Percentage - updated[i].Percentage
+		updated[i].Percentage = change.Percentage
+	}
+
+	switch {
+	case balance != "":
+		i := index(balance)
+		if i < 0 {
+			return nil, codedErrorf(CodeUnknownSymbol, "%s is not in the config's stocks", balance)
+		}
+		if changed[balance] {
+			return nil, codedErrorf(CodeUsage, "can't balance %s against itself", balance)
+		}
+		updated[i].Percentage = roundPercentage(updated[i].Percentage-difference, 6)
+		if updated[i].Percentage < 0 {
+			return nil, codedErrorf(CodeConfigSum, "%s would need a target of %g%% to make up the change", balance, updated[i].Percentage)
+		}
+	case scale:
+		fixed, rest := 0.0, 0.0
+		for _, t := range updated {
+			if changed[t.Symbol] {
+				fixed += t.Percentage
+			} else {
+				rest += t.Percentage
+			}
+		}
+		if fixed > 100 {
+			return nil, codedErrorf(CodeConfigSum, "changed targets add up to %g%%, more than 100", fixed)
+		}
+		if rest == 0 {
+			return nil, codedErrorf(CodeConfigSum, "no other targets to scale")
+		}
+		// Scaled targets are rounded to hundredths, and the largest takes
+		// the remainder so the total stays exactly 100
+		largest, sum := -1, fixed
+		for i, t := range updated {
+			if changed[t.Symbol] {
+				continue
+			}
+			updated[i].Percentage = roundPercentage(t.Percentage*(100-fixed)/rest, 2)
+			sum += updated[i].Percentage
+			if largest < 0 || updated[i].Percentage > updated[largest].Percentage {
+				largest = i
+			}
+		}
+		updated[largest].Percentage = roundPercentage(updated[largest].Percentage+100-sum, 6)
+	}
+
+	total := 0.0
+	for _, t := range updated {
+		total += t.Percentage
+	}
+	if math.Abs(total-100) > 1e-9 {
+		return nil, codedErrorf(CodeConfigSum, "targets would add up to %g%%; pass -balance <symbol> or -scale to make up the difference", roundPercentage(total, 6))
+	}
+	return updated, nil
+}
+
+func roundPercentage(percentage float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(percentage*scale) / scale
+}
+
+// targetSummary lists each target that changed, was added, or was removed
+func targetSummary(before, after []StockTarget) string {
+	var b strings.Builder
+	for _, t := range before {
+		i := slices.IndexFunc(after, func(a StockTarget) bool { return a.Symbol == t.Symbol })
+		switch {
+		case i < 0:
+			fmt.Fprintf(&b, "  %-8s %g%% -> removed\n", t.Symbol, t.Percentage)
+		case after[i].Percentage != t.Percentage:
+			fmt.Fprintf(&b, "  %-8s %g%% -> %g%%\n", t.Symbol, t.Percentage, after[i].Percentage)
+		}
+	}
+	for _, t := range after {
+		if !slices.ContainsFunc(before, func(b StockTarget) bool { return b.Symbol == t.Symbol }) {
+			fmt.Fprintf(&b, "  %-8s added at %g%%\n", t.Symbol, t.Percentage)
+		}
+	}
+	return b.String()
+}
+
+// stocksNode finds the stocks sequence in the config, adding an empty one to
+// a config without it
+func stocksNode(config *configDocument) (*yaml.Node, error) {
+	root, err := config.root()
+	if err != nil {
+		return nil, err
+	}
+	if stocks := mappingValue(root, "stocks"); stocks != nil {
+		if stocks.Kind != yaml.SequenceNode {
+			return nil, codedErrorf(CodeConfigInvalid, "line %d: stocks is not a list", stocks.Line)
+		}
+		return stocks, nil
+	}
+	stocks := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	setMappingValue(root, "stocks", stocks)
+	return stocks, nil
+}
+
+// stockTargets reads the symbol and target of each item of the stocks
+// sequence. Values that don't parse are read as zero and left for the
+// edited config's validation to report.
+func stockTargets(stocks *yaml.Node) []StockTarget {
+	var targets []StockTarget
+	for _, item := range stocks.Content {
+		var t StockTarget
+		if symbol := mappingValue(item, "symbol"); symbol != nil {
+			t.Symbol = symbol.Value
+		}
+		if percentage := mappingValue(item, "target_percentage"); percentage != nil {
+			t.Percentage, _ = strconv.ParseFloat(percentage.Value, 64)
+		}
+		targets = append(targets, t)
+	}
+	return targets
+}
+
+// setStockTargets rewrites the stocks sequence to match targets, editing
+// only the target_percentage of stocks that stay, so that their other keys
+// and comments are kept. New stocks are added at the end.
+func setStockTargets(stocks *yaml.Node, targets []StockTarget, description string) {
+	var items []*yaml.Node
+	seen := make(map[string]bool)
+	for _, item := range stocks.Content {
+		symbol := mappingValue(item, "symbol")
+		if symbol == nil {
+			items = append(items, item)
+			continue
+		}
+		i := slices.IndexFunc(targets, func(t StockTarget) bool { return t.Symbol == symbol.Value })
+		if i < 0 {
+			continue
+		}
+		seen[symbol.Value] = true
+		setMappingValue(item, "target_percentage", percentageNode(targets[i].Percentage))
+		items = append(items, item)
+	}
+	for _, t := range targets {
+		if seen[t.Symbol] {
+			continue
+		}
+		item := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		setMappingValue(item, "symbol", stringNode(t.Symbol))
+		setMappingValue(item, "target_percentage", percentageNode(t.Percentage))
+		if description != "" {
+			setMappingValue(item, "description", stringNode(description))
+		}
+		items = append(items, item)
+	}
+	stocks.Content = items
+}
+
+// percentageNode is a target percentage as a plain number
+func percentageNode(percentage float64) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: strconv.FormatFloat(percentage, 'f', -1, 64)}
+	if percentage == math.Trunc(percentage) {
+		node.Tag = "!!int"
+	}
+	return node
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRetarget(t *testing.T) {
+	targets := []StockTarget{{"VTI", 71}, {"VXUS", 18}, {"BND", 11}}
+	tests := []struct {
+		name    string
+		changes []StockTarget
+		balance string
+		scale   bool
+		want    []StockTarget
+		code    string
+	}{
+		{"balanced", []StockTarget{{"VTI", 60}}, "BND", false, []StockTarget{{"VTI", 60}, {"VXUS", 18}, {"BND", 22}}, ""},
+		{"several at once", []StockTarget{{"VTI", 70}, {"BND", 12}}, "", false, []StockTarget{{"VTI", 70}, {"VXUS", 18}, {"BND", 12}}, ""},
+		// 29 points split 18:11 over 30 leaves a rounding remainder for VXUS
+		{"scaled", []StockTarget{{"VTI", 70}}, "", true, []StockTarget{{"VTI", 70}, {"VXUS", 18.62}, {"BND", 11.38}}, ""},
+		{"unbalanced", []StockTarget{{"VTI", 60}}, "", false, nil, CodeConfigSum},
+		{"balance below zero", []StockTarget{{"VTI", 85}}, "BND", false, nil, CodeConfigSum},
+		{"unknown symbol", []StockTarget{{"VEA", 5}}, "BND", false, nil, CodeUnknownSymbol},
+		{"balance against itself", []StockTarget{{"BND", 5}}, "BND", false, nil, CodeUsage},
+		{"both", []StockTarget{{"VTI", 60}}, "BND", true, nil, CodeUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := retarget(targets, tt.changes, tt.balance, tt.scale)
+			if tt.code != "" {
+				if errorCode(err) != tt.code {
+					t.Fatalf("Expected %s, got %v", tt.code, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("retarget failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Targets mismatch: got %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if targets[0].Percentage != 71 {
+		t.Errorf("retarget changed its input: %v", targets)
+	}
+}
+
+func TestTargetKeepsComments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := `# Household allocation
+stocks:
+  - symbol: VTI
+    target_percentage: 71 # core holding
+    description: Vanguard Total Stock Market ETF
+  - symbol: VXUS
+    target_percentage: 18
+    description: Vanguard Total International Stock ETF
+  # Bonds
+  - symbol: BND
+    target_percentage: 11
+    description: Vanguard Total Bond Market ETF
+`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	defer func(yes bool) { assumeYes = yes }(assumeYes)
+	assumeYes = true
+
+	if status := target(path, []string{"add", "VNQ", "5", "-balance", "VTI", "-description", "Vanguard Real Estate ETF"}); status != 0 {
+		t.Fatalf("target add failed with status %d", status)
+	}
+	if status := target(path, []string{"remove", "VXUS", "-balance", "BND"}); status != 0 {
+		t.Fatalf("target remove failed with status %d", status)
+	}
+	// A change that doesn't add up leaves the file alone
+	if status := target(path, []string{"set", "VTI", "50"}); status == 0 {
+		t.Fatal("Expected an unbalanced change to fail")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# Household allocation
+stocks:
+  - symbol: VTI
+    target_percentage: 66 # core holding
+    description: Vanguard Total Stock Market ETF
+  # Bonds
+  - symbol: BND
+    target_percentage: 29
+    description: Vanguard Total Bond Market ETF
+  - symbol: VNQ
+    target_percentage: 5
+    description: Vanguard Real Estate ETF
+`
+	if got := string(data); got != want {
+		t.Errorf("Config mismatch:\n%s\nwant:\n%s", got, want)
+	}
+	if _, err := parseConfig(path); err != nil {
+		t.Errorf("Edited config doesn't parse: %v", err)
+	}
+	if matches, _ := filepath.Glob(path + ".*"); len(matches) != 0 {
+		t.Errorf("Expected no temp files left, got %v", matches)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"time"
+)
+
+// TargetDateFund is a fund of funds whose value is split across configured
+// stocks by its published composition rather than tracked as one holding
+type TargetDateFund struct {
+	Symbol      string          `yaml:"symbol"`
+	Description string          `yaml:"description,omitempty"`
+	GlidePath   []GlidePathStep `yaml:"glide_path"`
+}
+
+// GlidePathStep is the fund's composition, as percentages of configured
+// symbols, from Date until the next step
+type GlidePathStep struct {
+	Date        time.Time          `yaml:"date"`
+	Composition map[string]float64 `yaml:"composition"`
+}
+
+func validateTargetDateFunds(config *Config) error {
+	seen := make(map[string]bool)
+	for _, fund := range config.TargetDateFunds {
+		if seen[fund.Symbol] {
+			return fmt.Errorf("target-date fund %s appears multiple times", fund.Symbol)
+		}
+		seen[fund.Symbol] = true
+		if slices.ContainsFunc(config.Stocks, func(stock Stock) bool {
+			return stock.Symbol == fund.Symbol || slices.Contains(stock.Alternatives, fund.Symbol)
+		}) {
+			return fmt.Errorf("target-date fund %s is also listed in stocks", fund.Symbol)
+		}
+		if len(fund.GlidePath) == 0 {
+			return fmt.Errorf("target-date fund %s needs at least one glide_path entry", fund.Symbol)
+		}
+		for _, step := range fund.GlidePath {
+			total := 0.0
+			for symbol, percentage := range step.Composition {
+				if !slices.ContainsFunc(config.Stocks, func(stock Stock) bool { return stock.Symbol == symbol }) {
+					return codedErrorf(CodeUnknownSymbol, "target-date fund %s holds %s, which is not listed in stocks", fund.Symbol, symbol)
+				}
+				if percentage < 0 {
+					return fmt.Errorf("target-date fund %s has a negative percentage for %s", fund.Symbol, symbol)
+				}
+				total += percentage
+			}
+			if math.Abs(total-100) > 1e-9 {
+				return codedErrorf(CodeConfigSum, "target-date fund %s composition on %s does not add up to 100", fund.Symbol, step.Date.Format(time.DateOnly))
+			}
+		}
+	}
+	return nil
+}
+
+// targetDateFund finds the configured fund with the given symbol
+func (c *Config) targetDateFund(symbol string) *TargetDateFund {
+	for i := range c.TargetDateFunds {
+		if c.TargetDateFunds[i].Symbol == symbol {
+			return &c.TargetDateFunds[i]
+		}
+	}
+	return nil
+}
+
+// composition returns the glide path step in effect on date, or the earliest
+// step when date precedes them all
+func (f *TargetDateFund) composition(date time.Time) map[string]float64 {
+	steps := slices.Clone(f.GlidePath)
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].Date.Before(steps[j].Date) })
+	current := steps[0]
+	for _, step := range steps[1:] {
+		if step.Date.After(date) {
+			break
+		}
+		current = step
+	}
+	return current.Composition
+}
+
+// decompose splits amount across the fund's composition on date. Rounding
+// leftovers go to the largest component so the parts sum to amount.
+func (f *TargetDateFund) decompose(amount int, date time.Time) map[string]int {
+	composition := f.composition(date)
+	symbols := make([]string, 0, len(composition))
+	for symbol := range composition {
+		symbols = append(symbols, symbol)
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		if composition[symbols[i]] != composition[symbols[j]] {
+			return composition[symbols[i]] > composition[symbols[j]]
+		}
+		return symbols[i] < symbols[j]
+	})
+
+	parts := make(map[string]int)
+	remaining := amount
+	for _, symbol := range symbols[1:] {
+		parts[symbol] = int(math.Round(float64(amount) * composition[symbol] / 100))
+		remaining -= parts[symbol]
+	}
+	parts[symbols[0]] = remaining
+	return parts
+}
+
+// addTargetDateFunds folds the value of target-date fund rows, collected by
+// readHoldings, into the configured symbols they hold
+func addTargetDateFunds(config *Config, holdings *Holdings, fundAmounts map[string]map[string]int) {
+	date := holdings.AsOf
+	if date.IsZero() {
+		date = time.Now()
+	}
+	for symbol, byAccount := range fundAmounts {
+		fund := config.targetDateFund(symbol)
+		for account, amount := range byAccount {
+			holdings.FundAmounts[symbol] += amount
+			for component, part := range fund.decompose(amount, date) {
+				holdings.Amounts[component] += part
+				if account == "" {
+					continue
+				}
+				if holdings.AmountsByAccount[component] == nil {
+					holdings.AmountsByAccount[component] = make(map[string]int)
+				}
+				holdings.AmountsByAccount[component][account] += part
+			}
+		}
+	}
+}
+
+// printTargetDateFunds lists the target-date funds whose value was split
+// into their underlying symbols
+func printTargetDateFunds(holdings *Holdings) {
+	symbols := make([]string, 0, len(holdings.FundAmounts))
+	for symbol := range holdings.FundAmounts {
+		symbols = append(symbols, symbol)
+	}
+	slices.Sort(symbols)
+	for _, symbol := range symbols {
+		fmt.Printf("%s (%s) counted by its underlying holdings\n", symbol, formatAmount(holdings.FundAmounts[symbol], true))
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTargetDateFund(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "target_date.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+
+	holdings := loadHoldings(t, config, "target_date.csv")
+	expected := map[string]int{"VTI": 4700001, "VXUS": 1800000, "BND": 1000000}
+	for symbol, amount := range expected {
+		if holdings.Amounts[symbol] != amount {
+			t.Errorf("%s amount mismatch: got %d, expected %d", symbol, holdings.Amounts[symbol], amount)
+		}
+	}
+	if _, found := holdings.Amounts["VFIFX"]; found || holdings.FundAmounts["VFIFX"] != 5000001 {
+		t.Errorf("VFIFX should only be tracked as a fund: got %v / %v", holdings.Amounts, holdings.FundAmounts)
+	}
+
+	fund := config.targetDateFund("VFIFX")
+	parts := fund.decompose(10000000, time.Date(2040, time.January, 1, 0, 0, 0, 0, time.UTC))
+	if parts["VTI"] != 4800000 || parts["VXUS"] != 3200000 || parts["BND"] != 2000000 {
+		t.Errorf("Later glide path step mismatch: got %v", parts)
+	}
+	parts = fund.decompose(10000000, time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	if parts["BND"] != 1000000 {
+		t.Errorf("Dates before the glide path should use its first step: got %v", parts)
+	}
+
+	config.TargetDateFunds[0].GlidePath[0].Composition["VNQ"] = 5
+	if err := config.validate(); err == nil {
+		t.Error("Expected error for a composition holding an unconfigured symbol")
+	}
+}
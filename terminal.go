@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// noPager prints reports straight to the terminal instead of through
+// $PAGER. Set by the -noPager flag.
+var noPager bool
+
+// terminalWidth is the width that descriptions and notes wrap at, or 0 when
+// stdout isn't a terminal and lines are left whole
+var terminalWidth int
+
+// plain leaves out color and spells out signs, e.g. "overweight by 2.10%"
+// instead of "+2.10%", for screen readers and logs. Set by the -plain flag.
+var plain bool
+
+// unpagedCommands run until interrupted, so a pager would hold their output
+var unpagedCommands = []string{"serve"}
+
+// progressMinSize is the file size above which reading it shows progress
+const progressMinSize = 1 << 20
+
+// progressReader shows on w how much of a long file has been read, as a
+// percentage of its size redrawn on one line
+type progressReader struct {
+	r     io.Reader
+	w     io.Writer
+	label string
+	size  int64
+	read  int64
+	// shown is the percentage last drawn, or -1 before the first
+	shown int
+	line  string
+}
+
+// withProgress wraps a file that is large enough to take a while in a
+// reader showing progress on stderr, when stderr is a terminal. Call done
+// once reading stops, to clear the line.
+func withProgress(file *os.File, label string) (r io.Reader, done func()) {
+	info, err := file.Stat()
+	if err != nil || info.Size() < progressMinSize || !isTerminal(os.Stderr) {
+		return file, func() {}
+	}
+	p := &progressReader{r: file, w: os.Stderr, label: label, size: info.Size(), shown: -1}
+	return p, p.done
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+	if percent := int(min(p.read, p.size) * 100 / p.size); percent != p.shown {
+		p.shown = percent
+		p.line = fmt.Sprintf("%s: %d%%", p.label, percent)
+		fmt.Fprint(p.w, "\r"+p.line)
+	}
+	return n, err
+}
+
+func (p *progressReader) done() {
+	if p.line != "" {
+		fmt.Fprint(p.w, "\r"+strings.Repeat(" ", utf8.RuneCountInString(p.line))+"\r")
+		p.line = ""
+	}
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0 && isTTY(f)
+}
+
+// detectTerminalWidth returns $COLUMNS, or else the width f reports, or else 80
+func detectTerminalWidth(f *os.File) int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	if width := ttyWidth(f); width > 0 {
+		return width
+	}
+	return 80
+}
+
+// wrapText breaks text at spaces into lines no wider than width. A word
+// wider than a line, such as a long URL, is cut short with an ellipsis.
+func wrapText(text string, width int) string {
+	if width <= 0 || utf8.RuneCountInString(text) <= width {
+		return text
+	}
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		if utf8.RuneCountInString(word) > width {
+			if plain {
+				word = string([]rune(word)[:max(width-3, 1)]) + "..."
+			} else {
+				word = string([]rune(word)[:width-1]) + "…"
+			}
+		}
+		switch {
+		case line == "":
+			line = word
+		case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	return strings.Join(append(lines, line), "\n")
+}
+
+// startPager pipes everything printed to stdout through $PAGER, or
+// "less -FRX" when it isn't set, which exits at once when the output fits on
+// one screen and keeps colors. finish restores stdout and waits for the pager
+// to exit. Without a pager to run, output goes straight to stdout.
+func startPager() (finish func() error, err error) {
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		pager = "less -FRX"
+	}
+	args := strings.Fields(pager)
+	if len(args) == 0 || args[0] == "cat" {
+		return nil, nil
+	}
+	path, err := exec.LookPath(args[0])
+	if err != nil {
+		return nil, nil
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(path, args[1:]...)
+	cmd.Stdin = r
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		r.Close()
+		w.Close()
+		return nil, err
+	}
+	r.Close()
+	stdout := os.Stdout
+	os.Stdout = w
+	finish = func() error {
+		os.Stdout = stdout
+		w.Close()
+		return cmd.Wait()
+	}
+	return finish, nil
+}
+
+// rule is the line drawn around section headings, left out under -plain
+func rule() string {
+	if plain {
+		return ""
+	}
+	return strings.Repeat("-", 60)
+}
+
+// signed formats value, in unit, with its sign, or under -plain in words:
+// above or below by the magnitude, or even when it rounds to zero
+func signed(value float64, unit, above, below, even string) string {
+	if !plain {
+		return fmt.Sprintf("%+.2f%s", value, unit)
+	}
+	switch rounded := math.Round(value*100) / 100; {
+	case rounded > 0:
+		return fmt.Sprintf("%s by %.2f%s", above, rounded, unit)
+	case rounded < 0:
+		return fmt.Sprintf("%s by %.2f%s", below, -rounded, unit)
+	}
+	return even
+}
+
+// driftText formats a drift from target, e.g. "+2.10%" or "overweight by 2.10%"
+func driftText(drift float64) string {
+	return signed(drift, "%", "overweight", "underweight", "on target")
+}
+
+// tradeText marks text describing a trade of cents as a purchase, in green
+// with a "+", or a sale, in red. Under -plain the text starts with "buy" or
+// "sell" instead, and its minus signs are dropped.
+func tradeText(cents int, text string) string {
+	if plain {
+		switch {
+		case cents > 0:
+			return "buy " + text
+		case cents < 0:
+			return "sell " + strings.ReplaceAll(text, "-", "")
+		}
+		return "no trade"
+	}
+	if cents > 0 {
+		return green("+" + text)
+	}
+	return red(text)
+}
@@ -0,0 +1,16 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package main
+
+import "os"
+
+// isTTY can't tell a terminal from other character devices on this platform
+func isTTY(f *os.File) bool {
+	return true
+}
+
+// ttyWidth can't ask the terminal on this platform, so $COLUMNS or the
+// default width is used
+func ttyWidth(f *os.File) int {
+	return 0
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWrapText(t *testing.T) {
+	tests := []struct {
+		text     string
+		width    int
+		expected string
+	}{
+		{"Vanguard Total Stock Market ETF", 0, "Vanguard Total Stock Market ETF"},
+		{"Vanguard Total Stock Market ETF", 40, "Vanguard Total Stock Market ETF"},
+		{"Vanguard Total Stock Market ETF", 16, "Vanguard Total\nStock Market ETF"},
+		{"See https://investor.vanguard.com/vti", 16, "See\nhttps://investo…"},
+		{"Notes:  two  spaces", 12, "Notes: two\nspaces"},
+	}
+	for _, tt := range tests {
+		if actual := wrapText(tt.text, tt.width); actual != tt.expected {
+			t.Errorf("wrapText(%q, %d) = %q, expected %q", tt.text, tt.width, actual, tt.expected)
+		}
+	}
+}
+
+func TestTerminalDetection(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "report.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if isTerminal(file) {
+		t.Error("Expected a regular file not to be a terminal")
+	}
+
+	t.Setenv("COLUMNS", "132")
+	if width := detectTerminalWidth(file); width != 132 {
+		t.Errorf("Expected $COLUMNS to set the width, got %d", width)
+	}
+	t.Setenv("COLUMNS", "")
+	if width := detectTerminalWidth(file); width != 80 {
+		t.Errorf("Expected the default width, got %d", width)
+	}
+}
+
+func TestStartPager(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "paged.txt")
+	t.Setenv("PAGER", "tee "+out)
+	stdout := os.Stdout
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	os.Stdout = devNull
+	defer func() { os.Stdout = stdout }()
+
+	finish, err := startPager()
+	if err != nil || finish == nil {
+		t.Fatalf("startPager failed: %v", err)
+	}
+	os.Stdout.WriteString("Total: $1,000.00\n")
+	if err := finish(); err != nil {
+		t.Fatalf("Pager failed: %v", err)
+	}
+	if os.Stdout != devNull {
+		t.Error("Expected stdout to be restored")
+	}
+	if paged, _ := os.ReadFile(out); string(paged) != "Total: $1,000.00\n" {
+		t.Errorf("Paged output mismatch: got %q", paged)
+	}
+
+	t.Setenv("PAGER", "no-such-pager-fin-tilt")
+	if finish, err := startPager(); finish != nil || err != nil {
+		t.Errorf("Expected a missing pager to be skipped, got %v", err)
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	if actual := driftText(2.1); actual != "+2.10%" {
+		t.Errorf("Drift mismatch: got %q", actual)
+	}
+	if actual := tradeText(100000, "$1,000.00"); actual != green("+$1,000.00") {
+		t.Errorf("Trade mismatch: got %q", actual)
+	}
+
+	defer func(p bool) { plain = p }(plain)
+	plain = true
+	tests := []struct {
+		actual, expected string
+	}{
+		{driftText(2.1), "overweight by 2.10%"},
+		{driftText(-0.456), "underweight by 0.46%"},
+		{driftText(0.001), "on target"},
+		{tradeText(100000, "$1,000.00"), "buy $1,000.00"},
+		{tradeText(-300000, "-3 shares (-$3,000.00)"), "sell 3 shares ($3,000.00)"},
+		{tradeText(0, "$0.00"), "no trade"},
+		{red("Warning"), "Warning"},
+		{rule(), ""},
+		{wrapText("See https://investor.vanguard.com/vti", 16), "See\nhttps://inves..."},
+	}
+	for _, tt := range tests {
+		if tt.actual != tt.expected {
+			t.Errorf("Got %q, expected %q", tt.actual, tt.expected)
+		}
+	}
+}
+
+func TestProgressReader(t *testing.T) {
+	var shown bytes.Buffer
+	p := &progressReader{r: strings.NewReader(strings.Repeat("x", 400)), w: &shown, label: "Reading lots.csv", size: 400, shown: -1}
+	buf := make([]byte, 100)
+	for {
+		if _, err := p.Read(buf); err != nil {
+			break
+		}
+	}
+	if got := shown.String(); got != "\rReading lots.csv: 25%\rReading lots.csv: 50%\rReading lots.csv: 75%\rReading lots.csv: 100%" {
+		t.Errorf("Unexpected progress %q", got)
+	}
+	shown.Reset()
+	p.done()
+	if got := shown.String(); got != "\r"+strings.Repeat(" ", len("Reading lots.csv: 100%"))+"\r" {
+		t.Errorf("Expected done to clear the line, got %q", got)
+	}
+}
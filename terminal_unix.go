@@ -0,0 +1,30 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+type winsize struct{ Rows, Cols, X, Y uint16 }
+
+func getWinsize(f *os.File) (winsize, bool) {
+	var size winsize
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&size)))
+	return size, errno == 0
+}
+
+// isTTY tells a terminal apart from other character devices such as
+// /dev/null, which have no window size
+func isTTY(f *os.File) bool {
+	_, ok := getWinsize(f)
+	return ok
+}
+
+// ttyWidth asks the terminal behind f for its width in columns, or returns 0
+func ttyWidth(f *os.File) int {
+	size, _ := getWinsize(f)
+	return int(size.Cols)
+}
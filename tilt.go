@@ -0,0 +1,191 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// tiltFactors are the factors a tilt can be measured and targeted on
+var tiltFactors = []string{"size", "value", "quality"}
+
+// categoryLoadings estimates factor loadings from a fund's style-box
+// category, relative to the total market (large-blend): size runs from 0
+// (large) to 1 (small), value from -1 (growth) to 1 (value). Categories say
+// nothing about quality, so it must come from a stock's explicit factors.
+var categoryLoadings = map[string]map[string]float64{
+	"large-value":  {"size": 0, "value": 1},
+	"large-blend":  {"size": 0, "value": 0},
+	"large-growth": {"size": 0, "value": -1},
+	"mid-value":    {"size": 0.5, "value": 1},
+	"mid-blend":    {"size": 0.5, "value": 0},
+	"mid-growth":   {"size": 0.5, "value": -1},
+	"small-value":  {"size": 1, "value": 1},
+	"small-blend":  {"size": 1, "value": 0},
+	"small-growth": {"size": 1, "value": -1},
+}
+
+const defaultTiltTolerance = 0.1
+
+type TiltConfig struct {
+	// Target is the desired loading for each factor of interest
+	Target map[string]float64 `yaml:"target"`
+	// Tolerance is how far a loading may be from its target and still be on
+	// target (default 0.1)
+	Tolerance float64 `yaml:"tolerance,omitempty"`
+}
+
+type FactorTilt struct {
+	Factor  string   `json:"factor"`
+	Current float64  `json:"current"`
+	Target  float64  `json:"target"`
+	Desired *float64 `json:"desired,omitempty"`
+	OnTrack bool     `json:"on_track"`
+}
+
+type TiltReport struct {
+	Factors []FactorTilt `json:"factors"`
+	// Excluded lists symbols without a category or factors, such as bond
+	// funds, which don't count toward the tilt
+	Excluded []string `json:"excluded,omitempty"`
+}
+
+func (t *TiltConfig) tolerance() float64 {
+	if t == nil || t.Tolerance == 0 {
+		return defaultTiltTolerance
+	}
+	return t.Tolerance
+}
+
+func validateTilt(config *Config) error {
+	for _, stock := range config.Stocks {
+		if stock.Category != "" {
+			if _, ok := categoryLoadings[stock.Category]; !ok {
+				return fmt.Errorf("unknown category %q for %s (expected e.g. small-value or large-blend)", stock.Category, stock.Symbol)
+			}
+		}
+		for factor := range stock.Factors {
+			if !slices.Contains(tiltFactors, factor) {
+				return fmt.Errorf("unknown factor %q for %s (expected one of %s)", factor, stock.Symbol, strings.Join(tiltFactors, ", "))
+			}
+		}
+	}
+	if config.Tilt == nil {
+		return nil
+	}
+	for factor := range config.Tilt.Target {
+		if !slices.Contains(tiltFactors, factor) {
+			return fmt.Errorf("unknown tilt factor %q (expected one of %s)", factor, strings.Join(tiltFactors, ", "))
+		}
+	}
+	if config.Tilt.Tolerance < 0 {
+		return fmt.Errorf("tilt tolerance must not be negative")
+	}
+	return nil
+}
+
+// loadings returns a stock's factor loadings, with explicit factors
+// overriding those estimated from its category. ok is false when the stock
+// has neither.
+func (s *Stock) loadings() (loadings map[string]float64, ok bool) {
+	if s.Category == "" && len(s.Factors) == 0 {
+		return nil, false
+	}
+	loadings = make(map[string]float64)
+	for factor, loading := range categoryLoadings[s.Category] {
+		loadings[factor] = loading
+	}
+	for factor, loading := range s.Factors {
+		loadings[factor] = loading
+	}
+	return loadings, true
+}
+
+func tilt(config *Config, args []string) {
+	flagSet := flag.NewFlagSet("tilt", flag.ExitOnError)
+	if len(args) < 1 {
+		flag.Usage()
+		return
+	}
+	portfolioCsv := args[0]
+	flagSet.Parse(args[1:])
+
+	holdings, err := loadPortfolio(config, portfolioCsv)
+	if err != nil {
+		printError(err)
+		return
+	}
+	report, err := tiltCalc(config, holdings)
+	if err != nil {
+		printError(err)
+		return
+	}
+
+	fmt.Printf("%-10s %10s %10s %10s\n", "Factor", "Current", "Target", "Desired")
+	fmt.Println(rule())
+	for _, factor := range report.Factors {
+		desired, status := "-", ""
+		if factor.Desired != nil {
+			desired = fmt.Sprintf("%.2f", *factor.Desired)
+			if factor.OnTrack {
+				status = green("on target")
+			} else {
+				off := factor.Current - *factor.Desired
+				status = red(fmt.Sprintf("off by %+.2f", off))
+				if plain {
+					status = signed(off, "", "above desired", "below desired", "on target")
+				}
+			}
+		}
+		fmt.Printf("%-10s %10.2f %10.2f %10s  %s\n", factor.Factor, factor.Current, factor.Target, desired, status)
+	}
+	if len(report.Excluded) > 0 {
+		fmt.Printf("\nExcluded (no category or factors): %s\n", strings.Join(report.Excluded, ", "))
+	}
+}
+
+// tiltCalc averages factor loadings over the equity holdings, weighted by
+// current value and by target percentage
+func tiltCalc(config *Config, holdings *Holdings) (*TiltReport, error) {
+	report := &TiltReport{}
+	current := make(map[string]float64)
+	target := make(map[string]float64)
+	var currentWeight, targetWeight float64
+	for _, stock := range config.Stocks {
+		loadings, ok := stock.loadings()
+		if !ok {
+			report.Excluded = append(report.Excluded, stock.Symbol)
+			continue
+		}
+		amount := float64(holdings.Amounts[stock.Symbol])
+		currentWeight += amount
+		targetWeight += stock.TargetPercentage
+		for factor, loading := range loadings {
+			current[factor] += amount * loading
+			target[factor] += stock.TargetPercentage * loading
+		}
+	}
+	if currentWeight == 0 && targetWeight == 0 {
+		return nil, fmt.Errorf("no stocks have a category or factors to measure a tilt from")
+	}
+
+	for _, factor := range tiltFactors {
+		tilt := FactorTilt{Factor: factor}
+		if currentWeight > 0 {
+			tilt.Current = current[factor] / currentWeight
+		}
+		if targetWeight > 0 {
+			tilt.Target = target[factor] / targetWeight
+		}
+		if config.Tilt != nil {
+			if desired, ok := config.Tilt.Target[factor]; ok {
+				tilt.Desired = &desired
+				tilt.OnTrack = math.Abs(tilt.Current-desired) <= config.Tilt.tolerance()+1e-9
+			}
+		}
+		report.Factors = append(report.Factors, tilt)
+	}
+	return report, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestTiltCalc(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "tilt.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	holdings := loadHoldings(t, config, "tilt.csv")
+
+	report, err := tiltCalc(config, holdings)
+	if err != nil {
+		t.Fatalf("tiltCalc failed: %v", err)
+	}
+	if !slices.Equal(report.Excluded, []string{"BND"}) {
+		t.Errorf("Excluded mismatch: got %v, expected [BND]", report.Excluded)
+	}
+	expected := map[string][2]float64{
+		"size":    {10.0 / 90, 20.0 / 90},
+		"value":   {10.0 / 90, 20.0 / 90},
+		"quality": {3.0 / 90, 6.0 / 90},
+	}
+	for _, factor := range report.Factors {
+		if math.Abs(factor.Current-expected[factor.Factor][0]) > 1e-9 || math.Abs(factor.Target-expected[factor.Factor][1]) > 1e-9 {
+			t.Errorf("%s mismatch: got %f/%f, expected %v", factor.Factor, factor.Current, factor.Target, expected[factor.Factor])
+		}
+		if factor.Factor == "quality" && factor.Desired != nil {
+			t.Error("quality has no desired tilt")
+		}
+		if factor.Factor == "size" && (factor.Desired == nil || factor.OnTrack) {
+			t.Errorf("size should be off target: %+v", factor)
+		}
+	}
+
+	holdings.Amounts["AVUV"], holdings.Amounts["VTI"] = 2500000, 4500000
+	report, err = tiltCalc(config, holdings)
+	if err != nil {
+		t.Fatalf("tiltCalc failed: %v", err)
+	}
+	if !report.Factors[0].OnTrack {
+		t.Errorf("size should be on target: %+v", report.Factors[0])
+	}
+
+	config.Stocks[0].Category = "tiny-value"
+	if err := config.validate(); err == nil {
+		t.Error("Expected error for unknown category")
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+)
+
+// TopUp is the smallest deposit whose buy-only split brings every symbol back
+// to its target
+type TopUp struct {
+	Total   int `json:"total"`
+	Deposit int `json:"deposit"`
+	// Limiting is the most overweight symbol, which the rest are bought up to
+	Limiting string     `json:"limiting"`
+	Buys     []TopUpBuy `json:"buys"`
+}
+
+// TopUpBuy is one symbol's share of a top-up
+type TopUpBuy struct {
+	Symbol            string  `json:"symbol"`
+	Amount            int     `json:"amount"`
+	CurrentPercentage float64 `json:"current_percentage"`
+	TargetPercentage  float64 `json:"target_percentage"`
+	Buy               int     `json:"buy"`
+}
+
+func topup(config *Config, args []string) {
+	var format string
+	flagSet := flag.NewFlagSet("topup", flag.ExitOnError)
+	flagSet.StringVar(&format, "format", "text", "Output format: text or json")
+	if len(args) < 1 {
+		flag.Usage()
+		return
+	}
+	portfolioCsv := args[0]
+	flagSet.Parse(args[1:])
+	if format != "text" && format != "json" {
+		printError(codedErrorf(CodeUsage, "unknown format %q", format))
+		return
+	}
+
+	holdings, err := loadPortfolio(config, portfolioCsv)
+	if err != nil {
+		printError(err)
+		return
+	}
+	result, err := topupCalc(config, holdings.Amounts)
+	if err != nil {
+		printError(err)
+		return
+	}
+
+	if format == "json" {
+		if err := writeJSON(os.Stdout, result); err != nil {
+			printError(err)
+		}
+		return
+	}
+	if result.Deposit == 0 {
+		fmt.Println(green("Every symbol is already at its target; no deposit is needed"))
+		return
+	}
+	fmt.Printf("Deposit %s to reach every target without selling (%s to %s)\n", formatAmount(result.Deposit, true), formatAmount(result.Total, true), formatAmount(result.Total+result.Deposit, true))
+	fmt.Printf("%s is the most overweight; everything else is bought up to match it\n", result.Limiting)
+	fmt.Println(rule())
+	for _, buy := range result.Buys {
+		fmt.Printf("%-6s %6.2f%% -> %6.2f%%: %s\n", buy.Symbol, buy.CurrentPercentage, buy.TargetPercentage, tradeText(buy.Buy, formatAmount(buy.Buy, false)))
+	}
+}
+
+// topupCalc finds the smallest total at which no symbol is above its target,
+// which is the most overweight symbol's value over its target weight, and
+// splits the difference with buyOnlyCalc
+func topupCalc(config *Config, holdings map[string]int) (*TopUp, error) {
+	result := &TopUp{Buys: []TopUpBuy{}}
+	for _, stock := range config.Stocks {
+		result.Total += holdings[stock.Symbol]
+	}
+	if result.Total <= 0 {
+		return nil, fmt.Errorf("portfolio has no value in any configured symbol")
+	}
+
+	needed := result.Total
+	for _, stock := range config.Stocks {
+		amount := holdings[stock.Symbol]
+		if amount <= 0 {
+			continue
+		}
+		if stock.TargetPercentage <= 0 {
+			return nil, codedErrorf(CodeConfigInvalid, "%s has a 0%% target, so only selling it restores the targets", stock.Symbol)
+		}
+		if total := int(math.Ceil(float64(amount) * 100 / stock.TargetPercentage)); total > needed {
+			needed, result.Limiting = total, stock.Symbol
+		}
+	}
+	result.Deposit = needed - result.Total
+	if result.Deposit == 0 {
+		return result, nil
+	}
+
+	buys := buyOnlyCalc(config, holdings, result.Deposit)
+	for _, stock := range config.Stocks {
+		result.Buys = append(result.Buys, TopUpBuy{
+			Symbol:            stock.Symbol,
+			Amount:            holdings[stock.Symbol],
+			CurrentPercentage: float64(holdings[stock.Symbol]) / float64(result.Total) * 100,
+			TargetPercentage:  stock.TargetPercentage,
+			Buy:               buys[stock.Symbol],
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestTopupCalc(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	holdings := loadHoldings(t, config, "cash.csv")
+
+	result, err := topupCalc(config, holdings.Amounts)
+	if err != nil {
+		t.Fatalf("topupCalc failed: %v", err)
+	}
+	if result.Deposit != 0 {
+		t.Errorf("Expected no deposit for a portfolio on target, got %d", result.Deposit)
+	}
+
+	// VTI's $71,000 is 66% of $107,575.76, so the others are bought up to
+	// their share of that
+	config.Stocks[0].TargetPercentage = 66
+	config.Stocks[2].TargetPercentage = 16
+	result, err = topupCalc(config, holdings.Amounts)
+	if err != nil {
+		t.Fatalf("topupCalc failed: %v", err)
+	}
+	if result.Deposit != 757576 || result.Limiting != "VTI" {
+		t.Errorf("Expected a deposit of 757576 limited by VTI, got %d limited by %q", result.Deposit, result.Limiting)
+	}
+	expected := map[string]int{"VTI": 0, "VXUS": 136363, "BND": 621212}
+	for _, buy := range result.Buys {
+		if buy.Buy < 0 {
+			t.Errorf("Expected no sale of %s, got %d", buy.Symbol, buy.Buy)
+		}
+		if buy.Buy != expected[buy.Symbol] {
+			t.Errorf("Expected to buy %d of %s, got %d", expected[buy.Symbol], buy.Symbol, buy.Buy)
+		}
+	}
+
+	config.Stocks[0].TargetPercentage = 0
+	config.Stocks[1].TargetPercentage = 84
+	if _, err := topupCalc(config, holdings.Amounts); err == nil {
+		t.Error("Expected an error for a held symbol with a 0% target")
+	}
+}
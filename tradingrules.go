@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// RedemptionFee is a fund's fee on selling shares held for less than Days
+type RedemptionFee struct {
+	Percentage float64 `yaml:"percentage"`
+	Days       int     `yaml:"days"`
+}
+
+// TradeWarning is a recommended trade that a fund's redemption fee or
+// frequent-trading policy applies to, given recent transactions
+type TradeWarning struct {
+	Symbol string `json:"symbol"`
+	// Rule is redemption-fee or frequent-trading
+	Rule string `json:"rule"`
+	// Trade is the recommended trade the rule applies to: buy or sell
+	Trade string `json:"trade"`
+	Days  int    `json:"days"`
+	// Last is the most recent transaction the rule counts from, and Until the
+	// first day it no longer applies
+	Last  time.Time `json:"last"`
+	Until time.Time `json:"until"`
+	// Fee is the redemption fee the sale is estimated to cost
+	Fee int `json:"fee,omitempty"`
+}
+
+func validateTradingRules(config *Config) error {
+	for _, stock := range config.Stocks {
+		if fee := stock.RedemptionFee; fee != nil && (fee.Percentage <= 0 || fee.Percentage > 100 || fee.Days <= 0) {
+			return fmt.Errorf("redemption_fee of %s needs a percentage between 0 and 100 and a positive number of days", stock.Symbol)
+		}
+		if stock.FrequentTradingDays < 0 {
+			return fmt.Errorf("frequent_trading_days of %s must not be negative", stock.Symbol)
+		}
+	}
+	return nil
+}
+
+// hasTradingRules reports whether any stock has a redemption fee or
+// frequent-trading policy, which need the transaction log to check
+func (c *Config) hasTradingRules() bool {
+	for _, stock := range c.Stocks {
+		if stock.RedemptionFee != nil || stock.FrequentTradingDays > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// tradeWarningCalc checks each recommended trade against its stock's rules
+// and the transactions in its own symbol. A sale pays the redemption fee on
+// the shares bought within the fee's days, taking the oldest shares first as
+// funds do, and breaks a frequent-trading policy within its days of a
+// purchase; a purchase within them of a sale is blocked.
+func tradeWarningCalc(config *Config, symbolData map[string]SymbolData, transactions []Transaction, now time.Time) []TradeWarning {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	// latest finds the last transaction of a type within days, and the
+	// amount of them all
+	latest := func(symbol, kind string, days int) (last time.Time, amount int) {
+		for _, t := range transactions {
+			if t.Symbol != symbol || t.Type != kind || !today.Before(t.Date.AddDate(0, 0, days)) {
+				continue
+			}
+			amount += max(t.Amount, -t.Amount)
+			if t.Date.After(last) {
+				last = t.Date
+			}
+		}
+		return last, amount
+	}
+
+	var warnings []TradeWarning
+	for _, stock := range config.Stocks {
+		data := symbolData[stock.Symbol]
+		switch {
+		case data.AmountNeeded < 0:
+			if fee := stock.RedemptionFee; fee != nil {
+				if last, recent := latest(stock.Symbol, "buy", fee.Days); recent > 0 {
+					charged := -data.AmountNeeded - max(data.Amount-recent, 0)
+					if charged > 0 {
+						warnings = append(warnings, TradeWarning{Symbol: stock.Symbol, Rule: "redemption-fee", Trade: "sell", Days: fee.Days, Last: last, Until: last.AddDate(0, 0, fee.Days), Fee: int(math.Round(float64(min(charged, recent)) * fee.Percentage / 100))})
+					}
+				}
+			}
+			if days := stock.FrequentTradingDays; days > 0 {
+				if last, _ := latest(stock.Symbol, "buy", days); !last.IsZero() {
+					warnings = append(warnings, TradeWarning{Symbol: stock.Symbol, Rule: "frequent-trading", Trade: "sell", Days: days, Last: last, Until: last.AddDate(0, 0, days)})
+				}
+			}
+		case data.AmountNeeded > 0 && stock.FrequentTradingDays > 0:
+			days := stock.FrequentTradingDays
+			if last, _ := latest(stock.Symbol, "sell", days); !last.IsZero() {
+				warnings = append(warnings, TradeWarning{Symbol: stock.Symbol, Rule: "frequent-trading", Trade: "buy", Days: days, Last: last, Until: last.AddDate(0, 0, days)})
+			}
+		}
+	}
+	return warnings
+}
+
+// addTradeWarnings sets result's trade warnings from the config's
+// transaction log, when it has one and some stock has trading rules
+func addTradeWarnings(config *Config, result *RebalanceResult) error {
+	if config.Transactions == "" || !config.hasTradingRules() {
+		return nil
+	}
+	transactions, err := readTransactionLog(config.Transactions)
+	if err != nil {
+		return fmt.Errorf("reading transactions: %w", err)
+	}
+	result.TradeWarnings = tradeWarningCalc(config, result.Symbols, transactions, time.Now())
+	return nil
+}
+
+func printTradeWarnings(warnings []TradeWarning) {
+	for _, w := range warnings {
+		var text string
+		switch {
+		case w.Rule == "redemption-fee":
+			text = fmt.Sprintf("Warning: selling %s now costs about %s in redemption fees on shares bought within %d days (last on %s); they can be sold free from %s", w.Symbol, formatAmount(w.Fee, true), w.Days, w.Last.Format(time.DateOnly), w.Until.Format(time.DateOnly))
+		case w.Trade == "sell":
+			text = fmt.Sprintf("Warning: %s was bought on %s; selling it before %s breaks its %d-day frequent-trading policy", w.Symbol, w.Last.Format(time.DateOnly), w.Until.Format(time.DateOnly), w.Days)
+		default:
+			text = fmt.Sprintf("Warning: %s was sold on %s; its %d-day frequent-trading policy blocks buying it back before %s", w.Symbol, w.Last.Format(time.DateOnly), w.Days, w.Until.Format(time.DateOnly))
+		}
+		fmt.Println(red(text))
+	}
+}
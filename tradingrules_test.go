@@ -0,0 +1,57 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTradeWarningCalc(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	holdings := loadHoldings(t, config, "cash.csv")
+	config.Stocks[0].TargetPercentage = 66
+	config.Stocks[2].TargetPercentage = 16
+	config.Stocks[0].RedemptionFee = &RedemptionFee{Percentage: 2, Days: 60}
+	config.Stocks[0].FrequentTradingDays = 30
+	config.Stocks[2].FrequentTradingDays = 30
+	result, err := allocationCalc(config, holdings, 0)
+	if err != nil {
+		t.Fatalf("allocationCalc failed: %v", err)
+	}
+
+	now := time.Date(2026, time.October, 17, 15, 0, 0, 0, time.UTC)
+	day := func(daysAgo int) time.Time { return time.Date(2026, time.October, 17-daysAgo, 0, 0, 0, 0, time.UTC) }
+	transactions := []Transaction{
+		{Date: day(90), Type: "buy", Symbol: "VTI", Amount: -100000},
+		{Date: day(20), Type: "buy", Symbol: "VTI", Amount: -6900000},
+		{Date: day(40), Type: "sell", Symbol: "BND", Amount: 100000},
+		{Date: day(5), Type: "sell", Symbol: "VXUS", Amount: 100000},
+	}
+
+	// Selling $5,000 of VTI uses up the $2,000 held over 60 days, so $3,000
+	// of the sale pays the 2% fee; VXUS has no trade and BND's sale was long
+	// enough ago
+	expected := []TradeWarning{
+		{Symbol: "VTI", Rule: "redemption-fee", Trade: "sell", Days: 60, Last: day(20), Until: day(20).AddDate(0, 0, 60), Fee: 6000},
+		{Symbol: "VTI", Rule: "frequent-trading", Trade: "sell", Days: 30, Last: day(20), Until: day(20).AddDate(0, 0, 30)},
+	}
+	if got := tradeWarningCalc(config, result.Symbols, transactions, now); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Warning mismatch:\ngot      %+v\nexpected %+v", got, expected)
+	}
+
+	// Buying BND back ten days after selling it is blocked
+	transactions = append(transactions, Transaction{Date: day(10), Type: "sell", Symbol: "BND", Amount: 100000})
+	warnings := tradeWarningCalc(config, result.Symbols, transactions, now)
+	if len(warnings) != 3 || warnings[2].Symbol != "BND" || warnings[2].Trade != "buy" || !warnings[2].Until.Equal(day(10).AddDate(0, 0, 30)) {
+		t.Errorf("Expected the BND purchase blocked, got %+v", warnings)
+	}
+
+	config.Stocks[0].RedemptionFee.Days = 0
+	if err := config.validate(); err == nil {
+		t.Error("Expected an error for a redemption fee without days")
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// transactionTypes are the kinds of activity a broker export is sorted into
+var transactionTypes = []string{"buy", "sell", "dividend", "interest", "fee", "contribution", "withdrawal", "other"}
+
+// Transaction is one row of broker activity. Amount is the cash effect on the
+// account as the broker reports it: negative for buys, positive for sales,
+// dividends, and contributions.
+type Transaction struct {
+	// ID identifies the row across imports so the same file can be imported twice
+	ID          string    `json:"id"`
+	Date        time.Time `json:"date"`
+	Account     string    `json:"account,omitempty"`
+	Type        string    `json:"type"`
+	Symbol      string    `json:"symbol,omitempty"`
+	Quantity    float64   `json:"quantity,omitempty"`
+	Price       int       `json:"price,omitempty"`
+	Amount      int       `json:"amount"`
+	Description string    `json:"description,omitempty"`
+}
+
+// flow returns the money the transaction moved into or out of its account,
+// and whether it is a contribution or withdrawal at all
+func (t *Transaction) flow() (CashFlow, bool) {
+	if t.Type != "contribution" && t.Type != "withdrawal" {
+		return CashFlow{}, false
+	}
+	return CashFlow{Date: t.Date, Account: t.Account, Amount: t.Amount}, true
+}
+
+func importTransactions(config *Config, args []string) {
+	flagSet := flag.NewFlagSet("import", flag.ExitOnError)
+	if len(args) < 1 {
+		flag.Usage()
+		return
+	}
+	activityCsv := args[0]
+	flagSet.Parse(args[1:])
+	if config.Transactions == "" {
+		printError(codedErrorf(CodeConfigInvalid, "config has no transactions file"))
+		return
+	}
+
+	file, err := os.Open(activityCsv)
+	if err != nil {
+		printError(err)
+		return
+	}
+	defer file.Close()
+	// The new transactions wait in a temporary file until the import is
+	// confirmed, so a long export needn't fit in memory
+	pending, err := os.CreateTemp("", "fin-tilt-import-*")
+	if err != nil {
+		printError(err)
+		return
+	}
+	defer os.Remove(pending.Name())
+	defer pending.Close()
+	guard, err := guardStore(config.Transactions)
+	if err != nil {
+		printError(err)
+		return
+	}
+	activity, done := withProgress(file, "Reading "+activityCsv)
+	staged, err := stageTransactions(config.Transactions, activity, pending)
+	done()
+	if err != nil {
+		printError(err)
+		return
+	}
+	if staged.Added > 0 {
+		var preview strings.Builder
+		fmt.Fprintf(&preview, "%d transactions will be added to %s:\n", staged.Added, config.Transactions)
+		for _, t := range staged.Preview {
+			fmt.Fprintf(&preview, "%s  %-12s %-8s %12s  %s\n", t.Date.Format(time.DateOnly), t.Type, t.Symbol, formatAmount(t.Amount, true), t.Account)
+		}
+		if more := staged.Added - len(staged.Preview); more > 0 {
+			fmt.Fprintf(&preview, "... and %d more\n", more)
+		}
+		if skipDryRun("Import "+activityCsv, preview.String()) {
+			return
+		}
+		if err := confirm("Import "+activityCsv, preview.String()); err != nil {
+			printError(err)
+			return
+		}
+		if _, err := pending.Seek(0, io.SeekStart); err != nil {
+			printError(err)
+			return
+		}
+		if err := guard.write(func() error { return appendFile(config.Transactions, pending) }); err != nil {
+			printError(fmt.Errorf("saving transactions: %w", err))
+			return
+		}
+	}
+
+	var summary []string
+	for _, kind := range transactionTypes {
+		if staged.Counts[kind] > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", staged.Counts[kind], kind))
+		}
+	}
+	fmt.Printf("Imported %d new transactions (%d already imported)", staged.Added, staged.Read-staged.Added)
+	if len(summary) > 0 {
+		fmt.Printf(": %s", strings.Join(summary, ", "))
+	}
+	fmt.Println()
+}
+
+// importPreviewRows is how many new transactions import lists before asking
+// to save them
+const importPreviewRows = 20
+
+// StagedImport sums up the transactions of an activity export that aren't
+// yet in the log
+type StagedImport struct {
+	// Read counts every transaction in the export, and Added the new ones
+	Read  int
+	Added int
+	// Counts holds the number of new transactions of each type
+	Counts map[string]int
+	// Preview is the first importPreviewRows new transactions
+	Preview []Transaction
+}
+
+// stageTransactions streams the activity export r, writing the transactions
+// not already in the log at path to w as JSON lines. Only the IDs of the log
+// are held in memory, not its transactions.
+func stageTransactions(path string, r io.Reader, w io.Writer) (*StagedImport, error) {
+	ids := make(map[string]bool)
+	err := scanJSONLines(path, func(t Transaction) error {
+		ids[t.ID] = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading transactions: %w", err)
+	}
+	staged := &StagedImport{Counts: make(map[string]int)}
+	bw := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bw)
+	err = scanTransactions(r, func(t Transaction) error {
+		staged.Read++
+		if ids[t.ID] {
+			return nil
+		}
+		ids[t.ID] = true
+		staged.Added++
+		staged.Counts[t.Type]++
+		if len(staged.Preview) < importPreviewRows {
+			staged.Preview = append(staged.Preview, t)
+		}
+		return encoder.Encode(t)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading activity: %w", err)
+	}
+	return staged, bw.Flush()
+}
+
+// scanTransactions parses a broker activity export, such as Fidelity's
+// account history or Schwab's transactions CSV, row by row in the export's
+// order, passing each transaction to fn
+func scanTransactions(r io.Reader, fn func(Transaction) error) error {
+	reader := newCSVReader(r)
+	reader.ReuseRecord = true
+	var header []string
+	dateIndex, actionIndex, amountIndex := -1, -1, -1
+	for line := 0; dateIndex == -1 || actionIndex == -1 || amountIndex == -1; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) || line == maxPreambleLines {
+			return codedErrorf(CodeCSVHeader, "CSV file must have date, 'Action', and 'Amount' columns")
+		}
+		if err != nil {
+			return fmt.Errorf("error reading header: %w", err)
+		}
+		header = normalizeActivityHeader(record)
+		dateIndex = indexOfAny(header, "Run Date", "Trade Date", "Date")
+		actionIndex = indexOfAny(header, "Action", "Transaction Type", "Type")
+		amountIndex = slices.Index(header, "Amount")
+	}
+	accountIndex := indexOfAny(header, "Account", "Account Name", "Account Number")
+	symbolIndex := slices.Index(header, "Symbol")
+	descriptionIndex := slices.Index(header, "Description")
+	quantityIndex := slices.Index(header, "Quantity")
+	priceIndex := slices.Index(header, "Price")
+
+	seen := make(map[string]int)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+		// Skip disclaimers and other footer lines that don't start with a date
+		date, err := parseCSVDate(strings.TrimSpace(field(record, dateIndex)))
+		if err != nil {
+			continue
+		}
+		// Corporate actions like splits have no amount
+		amount := 0
+		if value := strings.TrimSpace(field(record, amountIndex)); value != "" {
+			if amount, err = amountToInt(value); err != nil {
+				return codedErrorf(CodeCSVValue, "error parsing amount on %s: %w", field(record, dateIndex), err)
+			}
+		}
+		t := Transaction{
+			Date:        date,
+			Account:     strings.TrimSpace(field(record, accountIndex)),
+			Type:        classifyAction(field(record, actionIndex), amount),
+			Symbol:      strings.TrimSpace(field(record, symbolIndex)),
+			Amount:      amount,
+			Description: strings.TrimSpace(field(record, descriptionIndex)),
+		}
+		if quantity := strings.ReplaceAll(strings.TrimSpace(field(record, quantityIndex)), ",", ""); quantity != "" {
+			if t.Quantity, err = strconv.ParseFloat(quantity, 64); err != nil {
+				return codedErrorf(CodeCSVValue, "error parsing quantity on %s: %w", field(record, dateIndex), err)
+			}
+		}
+		if price := strings.TrimSpace(field(record, priceIndex)); price != "" {
+			if t.Price, err = amountToInt(price); err != nil {
+				return codedErrorf(CodeCSVValue, "error parsing price on %s: %w", field(record, dateIndex), err)
+			}
+		}
+		// Identical rows in one file are distinct transactions (two equal
+		// buys on the same day), so number them
+		key := transactionKey(&t, field(record, actionIndex))
+		seen[key]++
+		sum := sha256.Sum256([]byte(key + "#" + strconv.Itoa(seen[key])))
+		t.ID = hex.EncodeToString(sum[:8])
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeActivityHeader trims headers and drops unit suffixes such as
+// "Amount ($)"
+func normalizeActivityHeader(record []string) []string {
+	header := normalizeHeader(record)
+	for i, name := range header {
+		header[i] = strings.TrimSpace(strings.TrimSuffix(name, "($)"))
+	}
+	return header
+}
+
+func indexOfAny(header []string, names ...string) int {
+	for _, name := range names {
+		if i := slices.Index(header, name); i >= 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+func transactionKey(t *Transaction, action string) string {
+	return strings.Join([]string{
+		t.Date.Format(time.DateOnly), t.Account, strings.TrimSpace(action), t.Symbol,
+		strconv.FormatFloat(t.Quantity, 'f', -1, 64), strconv.Itoa(t.Amount), t.Description,
+	}, "|")
+}
+
+// classifyAction sorts a broker's action text into a transaction type. Money
+// movements are told apart by the sign of the amount.
+func classifyAction(action string, amount int) string {
+	action = strings.ToUpper(strings.TrimSpace(action))
+	has := func(words ...string) bool {
+		return slices.ContainsFunc(words, func(word string) bool { return strings.Contains(action, word) })
+	}
+	switch {
+	case has("REINVEST", "BOUGHT") || strings.HasPrefix(action, "BUY"):
+		return "buy"
+	case has("SOLD") || strings.HasPrefix(action, "SELL"):
+		return "sell"
+	case has("DIVIDEND", "CAP GAIN"):
+		return "dividend"
+	case has("INTEREST"):
+		return "interest"
+	case has("FEE", "COMMISSION"):
+		return "fee"
+	case has("CONTRIBUTION", "DEPOSIT", "TRANSFER", "WITHDRAWAL", "DISTRIBUTION", "JOURNAL", "MONEYLINK", "WIRE"):
+		if amount < 0 {
+			return "withdrawal"
+		}
+		return "contribution"
+	}
+	return "other"
+}
+
+// readTransactionLog loads every imported transaction, oldest first
+func readTransactionLog(path string) ([]Transaction, error) {
+	transactions, err := readJSONLines[Transaction](path)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(transactions, func(i, j int) bool { return transactions[i].Date.Before(transactions[j].Date) })
+	return transactions, nil
+}
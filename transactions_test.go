@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStageTransactions(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("tests", "portfolios", "activity.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "transactions.jsonl")
+	var pending bytes.Buffer
+	staged, err := stageTransactions(path, bytes.NewReader(data), &pending)
+	if err != nil || staged.Read != 6 || staged.Added != 6 || staged.Counts["buy"] != 2 {
+		t.Fatalf("First import: got %+v (%v), expected 6 added", staged, err)
+	}
+	if err := appendFile(path, &pending); err != nil {
+		t.Fatalf("appendFile failed: %v", err)
+	}
+	pending.Reset()
+	if staged, err := stageTransactions(path, bytes.NewReader(data), &pending); err != nil || staged.Added != 0 || pending.Len() != 0 {
+		t.Errorf("Second import: got %+v (%v), expected nothing added", staged, err)
+	}
+
+	log, err := readTransactionLog(path)
+	if err != nil {
+		t.Fatalf("readTransactionLog failed: %v", err)
+	}
+	expected := []struct {
+		kind   string
+		amount int
+	}{
+		{"withdrawal", -30000}, {"sell", 59998}, {"contribution", 250000}, {"buy", -100000}, {"buy", -100000}, {"dividend", 5210},
+	}
+	if len(log) != len(expected) {
+		t.Fatalf("Expected %d transactions, got %d: %+v", len(expected), len(log), log)
+	}
+	for i, e := range expected {
+		if log[i].Type != e.kind || log[i].Amount != e.amount {
+			t.Errorf("Transaction %d mismatch: got %s %d, expected %s %d", i, log[i].Type, log[i].Amount, e.kind, e.amount)
+		}
+	}
+	if buy := log[3]; buy.Symbol != "VTI" || buy.Quantity != 4 || buy.Price != 25000 || buy.Account != "Individual" {
+		t.Errorf("Buy mismatch: %+v", buy)
+	}
+	if log[3].ID == log[4].ID {
+		t.Error("Identical rows in one file should get distinct IDs")
+	}
+	if flow, ok := log[2].flow(); !ok || flow.Amount != 250000 || flow.Account != "Individual" {
+		t.Errorf("Contribution flow mismatch: %+v", flow)
+	}
+	if _, ok := log[3].flow(); ok {
+		t.Error("Buys are not cash flows")
+	}
+}
+
+func TestStageLongExport(t *testing.T) {
+	var csv strings.Builder
+	csv.WriteString("Run Date,Action,Symbol,Amount\n")
+	for day := 1; day <= 28; day++ {
+		fmt.Fprintf(&csv, "09/%02d/2026,YOU BOUGHT,VTI,-100.00\n", day)
+	}
+	var pending bytes.Buffer
+	staged, err := stageTransactions(filepath.Join(t.TempDir(), "transactions.jsonl"), strings.NewReader(csv.String()), &pending)
+	if err != nil {
+		t.Fatalf("stageTransactions failed: %v", err)
+	}
+	// Only the start of a long import is kept for the preview
+	if staged.Added != 28 || len(staged.Preview) != importPreviewRows || strings.Count(pending.String(), "\n") != 28 {
+		t.Errorf("Expected 28 staged with %d previewed, got %d staged, %d previewed, and %q", importPreviewRows, staged.Added, len(staged.Preview), pending.String())
+	}
+}
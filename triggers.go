@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"time"
+)
+
+// triggerPolicies are the rebalancing policies trigger-analysis compares
+var triggerPolicies = []string{"never", "monthly", "quarterly", "annually", "5/25"}
+
+// TriggerStats is how one rebalancing policy fared over the price history
+type TriggerStats struct {
+	Policy     string `json:"policy"`
+	Rebalances int    `json:"rebalances"`
+	// Turnover is the share of the portfolio bought (and sold) per year, in
+	// percent
+	Turnover float64 `json:"turnover"`
+	// AverageDrift is the largest drift of any symbol, in percentage points,
+	// averaged over the month ends, and MaxDrift the largest at any of them
+	AverageDrift float64 `json:"average_drift"`
+	MaxDrift     float64 `json:"max_drift"`
+	// Return is the annualized return, before trading costs and taxes
+	Return float64 `json:"return"`
+}
+
+// TriggerSimulation is how one policy fared across the simulated histories,
+// on average, with the spread of its return
+type TriggerSimulation struct {
+	Policy       string  `json:"policy"`
+	Rebalances   float64 `json:"rebalances"`
+	Turnover     float64 `json:"turnover"`
+	AverageDrift float64 `json:"average_drift"`
+	MaxDrift     float64 `json:"max_drift"`
+	// Return is the median annualized return, and ReturnLow and ReturnHigh
+	// the 5th and 95th percentiles
+	Return     float64 `json:"return"`
+	ReturnLow  float64 `json:"return_low"`
+	ReturnHigh float64 `json:"return_high"`
+}
+
+// TriggerInputs records everything an analysis was worked out from, so that
+// -replay can run it again exactly
+type TriggerInputs struct {
+	// ConfigHash is the content hash of the config, and HistoryHash that of
+	// the -history CSV, if one was read
+	ConfigHash  string             `json:"config_hash"`
+	HistoryHash string             `json:"history_hash,omitempty"`
+	Targets     map[string]float64 `json:"targets"`
+	Start       string             `json:"start"`
+	// Paths is how many histories were simulated from Seed
+	Paths int    `json:"paths,omitempty"`
+	Seed  uint64 `json:"seed,omitempty"`
+	// Prices are the month-end closes the returns came from
+	Prices PriceHistory `json:"prices"`
+}
+
+type TriggerAnalysis struct {
+	Start    string         `json:"start"`
+	End      string         `json:"end"`
+	Months   int            `json:"months"`
+	Policies []TriggerStats `json:"policies"`
+	// Simulated are the policies across resampled histories, under -paths
+	Simulated []TriggerSimulation `json:"simulated,omitempty"`
+	Inputs    *TriggerInputs      `json:"inputs"`
+}
+
+func triggerAnalysis(ctx context.Context, config *Config, args []string) {
+	var historyCsv, replay, format string
+	var years, paths int
+	var seed uint64
+	flagSet := flag.NewFlagSet("trigger-analysis", flag.ExitOnError)
+	flagSet.IntVar(&years, "years", 10, "Years of monthly history to use")
+	flagSet.StringVar(&historyCsv, "history", "", "CSV of monthly closing prices (Date column plus one column per symbol) instead of downloading them")
+	flagSet.IntVar(&paths, "paths", 0, "Also simulate this many histories resampled from the real one")
+	flagSet.Uint64Var(&seed, "seed", 0, "Seed for resampling the simulated histories (default random, and recorded in the output)")
+	flagSet.StringVar(&replay, "replay", "", "JSON output of an earlier run to repeat exactly, from the prices, targets, and seed it recorded")
+	flagSet.StringVar(&format, "format", "text", "Output format: text or json")
+	flagSet.Parse(args)
+	if format != "text" && format != "json" {
+		printError(codedErrorf(CodeUsage, "unknown format %q", format))
+		return
+	}
+	if paths < 0 {
+		printError(codedErrorf(CodeUsage, "-paths must not be negative"))
+		return
+	}
+	configHash, err := configHash(config)
+	if err != nil {
+		printError(err)
+		return
+	}
+
+	var inputs *TriggerInputs
+	if replay != "" {
+		if inputs, err = readTriggerInputs(replay); err != nil {
+			printError(err)
+			return
+		}
+		if inputs.ConfigHash != configHash {
+			fmt.Fprintf(os.Stderr, "The config has changed since %s was run; replaying the targets it recorded\n", replay)
+		}
+	} else {
+		inputs = &TriggerInputs{ConfigHash: configHash, Targets: make(map[string]float64), Start: time.Now().AddDate(-years, -1, 0).Format("2006-01"), Paths: paths}
+		for _, stock := range config.Stocks {
+			if stock.TargetPercentage > 0 {
+				inputs.Targets[stock.Symbol] = stock.TargetPercentage
+			}
+		}
+		if paths > 0 {
+			inputs.Seed = seed
+			if seed == 0 {
+				inputs.Seed = rand.Uint64()
+			}
+		}
+		if inputs.Prices, inputs.HistoryHash, err = triggerHistory(ctx, config, historyCsv, inputs); err != nil {
+			printError(err)
+			return
+		}
+	}
+
+	analysis, err := triggerCalc(inputs.Targets, inputs.Prices, inputs.Start)
+	if err != nil {
+		printError(err)
+		return
+	}
+	if inputs.Paths > 0 {
+		returns, months, _ := triggerReturns(inputs.Targets, inputs.Prices, inputs.Start)
+		analysis.Simulated = simulateTriggers(inputs.Targets, returns, months, inputs.Paths, inputs.Seed)
+	}
+	analysis.Inputs = inputs
+
+	if format == "json" {
+		if err := writeJSON(os.Stdout, analysis); err != nil {
+			printError(err)
+		}
+		return
+	}
+	fmt.Printf("Monthly returns from %s to %s (%d months), rebalancing back to target\n", analysis.Start, analysis.End, analysis.Months)
+	fmt.Println(rule())
+	fmt.Printf("%-10s %10s %12s %10s %10s %10s\n", "Policy", "Rebalances", "Turnover/yr", "Avg drift", "Max drift", "Return/yr")
+	for _, stats := range analysis.Policies {
+		fmt.Printf("%-10s %10d %11.2f%% %10.2f %10.2f %9.2f%%\n", stats.Policy, stats.Rebalances, stats.Turnover, stats.AverageDrift, stats.MaxDrift, stats.Return*100)
+	}
+	fmt.Println("\nDrift is the largest of any symbol at each month end, in percentage points. Returns leave out trading costs and taxes.")
+	if len(analysis.Simulated) > 0 {
+		fmt.Printf("\nAcross %d histories resampled from those months (seed %d), on average\n", inputs.Paths, inputs.Seed)
+		fmt.Println(rule())
+		fmt.Printf("%-10s %10s %12s %10s %10s %10s %17s\n", "Policy", "Rebalances", "Turnover/yr", "Avg drift", "Max drift", "Return/yr", "5th-95th pct")
+		for _, stats := range analysis.Simulated {
+			fmt.Printf("%-10s %10.1f %11.2f%% %10.2f %10.2f %9.2f%% %7.2f%% to %5.2f%%\n", stats.Policy, stats.Rebalances, stats.Turnover, stats.AverageDrift, stats.MaxDrift, stats.Return*100, stats.ReturnLow*100, stats.ReturnHigh*100)
+		}
+	}
+	fmt.Printf("\nConfig %s; pass -format json and -replay the output to repeat this run exactly\n", inputs.ConfigHash)
+}
+
+// triggerHistory reads the monthly closes of the targets from historyCsv, or
+// else downloads them, with the CSV's content hash
+func triggerHistory(ctx context.Context, config *Config, historyCsv string, inputs *TriggerInputs) (PriceHistory, string, error) {
+	if historyCsv == "" {
+		start, _ := time.Parse("2006-01", inputs.Start)
+		history, err := priceHistory(ctx, config, slices.Sorted(maps.Keys(inputs.Targets)), start)
+		return history, "", err
+	}
+	hash, err := exportHash(historyCsv)
+	if err != nil {
+		return nil, "", err
+	}
+	file, err := os.Open(historyCsv)
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+	history, err := readHistory(file, "")
+	if err != nil {
+		return nil, "", fmt.Errorf("reading price history: %w", err)
+	}
+	// Only the targets' closes are needed to replay the run
+	for symbol := range history {
+		if _, ok := inputs.Targets[symbol]; !ok {
+			delete(history, symbol)
+		}
+	}
+	return history, hash, nil
+}
+
+// readTriggerInputs reads the inputs recorded in the JSON output of an
+// earlier trigger-analysis
+func readTriggerInputs(path string) (*TriggerInputs, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var analysis TriggerAnalysis
+	if err := json.Unmarshal(data, &analysis); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if analysis.Inputs == nil || len(analysis.Inputs.Prices) == 0 {
+		return nil, codedErrorf(CodeUsage, "%s records no inputs to replay; it must be trigger-analysis -format json output", path)
+	}
+	return analysis.Inputs, nil
+}
+
+// triggerCalc runs every policy over the months since start in which every
+// symbol with a target has a return, starting each at the target weights
+func triggerCalc(target map[string]float64, history PriceHistory, start string) (*TriggerAnalysis, error) {
+	returns, months, err := triggerReturns(target, history, start)
+	if err != nil {
+		return nil, err
+	}
+	analysis := &TriggerAnalysis{Start: months[0], End: months[len(months)-1], Months: len(months)}
+	for _, policy := range triggerPolicies {
+		analysis.Policies = append(analysis.Policies, simulateTrigger(policy, target, returns, months))
+	}
+	return analysis, nil
+}
+
+// triggerReturns is the monthly return of each symbol with a target, and the
+// months since start, in order, in which every one of them has a return
+func triggerReturns(target map[string]float64, history PriceHistory, start string) (map[string]map[string]float64, []string, error) {
+	if len(target) == 0 {
+		return nil, nil, codedErrorf(CodeConfigInvalid, "no stocks with a target to simulate")
+	}
+	returns := make(map[string]map[string]float64)
+	for symbol := range target {
+		closes, ok := history[symbol]
+		if !ok {
+			return nil, nil, fmt.Errorf("no price history for %s", symbol)
+		}
+		returns[symbol] = monthlyReturns(closes)
+	}
+	// Any symbol's months will do, since each month must be in all of them
+	var months []string
+	for month := range returns[slices.Sorted(maps.Keys(target))[0]] {
+		if month < start {
+			continue
+		}
+		covered := true
+		for _, r := range returns {
+			if _, ok := r[month]; !ok {
+				covered = false
+				break
+			}
+		}
+		if covered {
+			months = append(months, month)
+		}
+	}
+	if len(months) < minRiskMonths {
+		return nil, nil, fmt.Errorf("only %d months of overlapping price history; at least %d are needed", len(months), minRiskMonths)
+	}
+	slices.Sort(months)
+	return returns, months, nil
+}
+
+// simulateTrigger grows a portfolio at the target weights month by month,
+// rebalancing it back to target at the month ends the policy calls for
+func simulateTrigger(policy string, target map[string]float64, returns map[string]map[string]float64, months []string) TriggerStats {
+	stats := TriggerStats{Policy: policy}
+	values := make(map[string]float64, len(target))
+	for symbol, percentage := range target {
+		values[symbol] = percentage / 100
+	}
+	// Summing in a fixed order keeps the results the same to the last bit
+	symbols := slices.Sorted(maps.Keys(target))
+	growth, turnover, driftSum := 1.0, 0.0, 0.0
+	for _, month := range months {
+		total := 0.0
+		for _, symbol := range symbols {
+			values[symbol] *= 1 + returns[symbol][month]
+			total += values[symbol]
+		}
+		growth *= total
+
+		maxDrift, breached, traded := 0.0, false, 0.0
+		for _, symbol := range symbols {
+			current := values[symbol] / total * 100
+			maxDrift = max(maxDrift, math.Abs(current-target[symbol]))
+			breached = breached || outsideSwedroeBands(target[symbol], current)
+			traded += math.Abs(current - target[symbol])
+		}
+		driftSum += maxDrift
+		stats.MaxDrift = max(stats.MaxDrift, maxDrift)
+
+		date, _ := time.Parse("2006-01", month)
+		var rebalance bool
+		switch policy {
+		case "monthly":
+			rebalance = true
+		case "quarterly":
+			rebalance = date.Month()%3 == 0
+		case "annually":
+			rebalance = date.Month() == time.December
+		case "5/25":
+			rebalance = breached
+		}
+		if rebalance && traded > 0 {
+			stats.Rebalances++
+			// Every dollar bought is matched by one sold
+			turnover += traded / 2
+			for symbol := range values {
+				values[symbol] = target[symbol] / 100
+			}
+		} else {
+			for symbol := range values {
+				values[symbol] /= total
+			}
+		}
+	}
+	years := float64(len(months)) / 12
+	stats.Turnover = turnover / years
+	stats.AverageDrift = driftSum / float64(len(months))
+	stats.Return = math.Pow(growth, 1/years) - 1
+	return stats
+}
+
+// simulateTriggers runs every policy over paths histories as long as the real
+// one, each month's returns drawn at random, with replacement, from a real
+// month, so that the symbols keep moving together. The same seed draws the
+// same histories.
+func simulateTriggers(target map[string]float64, returns map[string]map[string]float64, months []string, paths int, seed uint64) []TriggerSimulation {
+	random := rand.New(rand.NewPCG(seed, seed))
+	stats := make(map[string][]TriggerStats)
+	for range paths {
+		// The path keeps the real months' dates, for the calendar policies
+		path := make(map[string]map[string]float64, len(returns))
+		for symbol := range returns {
+			path[symbol] = make(map[string]float64, len(months))
+		}
+		for _, month := range months {
+			drawn := months[random.IntN(len(months))]
+			for symbol := range returns {
+				path[symbol][month] = returns[symbol][drawn]
+			}
+		}
+		for _, policy := range triggerPolicies {
+			stats[policy] = append(stats[policy], simulateTrigger(policy, target, path, months))
+		}
+	}
+
+	var simulations []TriggerSimulation
+	for _, policy := range triggerPolicies {
+		simulation := TriggerSimulation{Policy: policy}
+		var returns []float64
+		for _, s := range stats[policy] {
+			simulation.Rebalances += float64(s.Rebalances)
+			simulation.Turnover += s.Turnover
+			simulation.AverageDrift += s.AverageDrift
+			simulation.MaxDrift += s.MaxDrift
+			returns = append(returns, s.Return)
+		}
+		simulation.Rebalances /= float64(paths)
+		simulation.Turnover /= float64(paths)
+		simulation.AverageDrift /= float64(paths)
+		simulation.MaxDrift /= float64(paths)
+		slices.Sort(returns)
+		simulation.Return = percentile(returns, 50)
+		simulation.ReturnLow = percentile(returns, 5)
+		simulation.ReturnHigh = percentile(returns, 95)
+		simulations = append(simulations, simulation)
+	}
+	return simulations
+}
+
+// percentile is the pth percentile of sorted values, by the nearest rank
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[min(max(rank-1, 0), len(sorted)-1)]
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestTriggerCalc(t *testing.T) {
+	file, err := os.Open(filepath.Join("tests", "history", "monthly.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	history, err := readHistory(file, "")
+	if err != nil {
+		t.Fatalf("readHistory failed: %v", err)
+	}
+
+	analysis, err := triggerCalc(map[string]float64{"VTI": 60, "VXUS": 30, "BND": 10}, history, "2024-01")
+	if err != nil {
+		t.Fatalf("triggerCalc failed: %v", err)
+	}
+	if analysis.Months != 24 || len(analysis.Policies) != len(triggerPolicies) {
+		t.Fatalf("Analysis mismatch: %+v", analysis)
+	}
+	stats := make(map[string]TriggerStats)
+	for _, s := range analysis.Policies {
+		stats[s.Policy] = s
+	}
+	if s := stats["never"]; s.Rebalances != 0 || s.Turnover != 0 {
+		t.Errorf("Expected no trading without rebalancing, got %+v", s)
+	}
+	if s := stats["monthly"]; s.Rebalances != 24 || s.Turnover <= stats["annually"].Turnover {
+		t.Errorf("Expected monthly rebalancing to trade most, got %+v", s)
+	}
+	if s := stats["annually"]; s.Rebalances != 2 {
+		t.Errorf("Expected a rebalance each December, got %+v", s)
+	}
+	// Rebalancing more often keeps drift smaller
+	if stats["monthly"].AverageDrift >= stats["never"].AverageDrift || stats["quarterly"].MaxDrift > stats["never"].MaxDrift {
+		t.Errorf("Drift mismatch: %+v", analysis.Policies)
+	}
+
+	if _, err := triggerCalc(map[string]float64{"VTI": 60, "GLD": 40}, history, "2024-01"); err == nil {
+		t.Error("Expected an error for a symbol without history")
+	}
+}
+
+func TestSimulateTriggers(t *testing.T) {
+	file, err := os.Open(filepath.Join("tests", "history", "monthly.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	history, err := readHistory(file, "")
+	if err != nil {
+		t.Fatalf("readHistory failed: %v", err)
+	}
+	target := map[string]float64{"VTI": 60, "VXUS": 30, "BND": 10}
+	returns, months, err := triggerReturns(target, history, "2024-01")
+	if err != nil {
+		t.Fatalf("triggerReturns failed: %v", err)
+	}
+
+	// The same seed draws the same histories
+	simulations := simulateTriggers(target, returns, months, 50, 7)
+	if !reflect.DeepEqual(simulations, simulateTriggers(target, returns, months, 50, 7)) {
+		t.Error("Expected the same seed to give the same simulations")
+	}
+	if reflect.DeepEqual(simulations, simulateTriggers(target, returns, months, 50, 8)) {
+		t.Error("Expected another seed to give other simulations")
+	}
+	for _, s := range simulations {
+		if s.ReturnLow > s.Return || s.Return > s.ReturnHigh {
+			t.Errorf("Expected the median return within its percentiles, got %+v", s)
+		}
+	}
+	if monthly := simulations[1]; monthly.Policy != "monthly" || monthly.Rebalances != 24 {
+		t.Errorf("Expected monthly rebalancing every month of every path, got %+v", monthly)
+	}
+
+	// The recorded inputs replay the run
+	inputs := &TriggerInputs{Targets: target, Start: "2024-01", Paths: 50, Seed: 7, Prices: history}
+	data, err := json.Marshal(TriggerAnalysis{Inputs: inputs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "run.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := readTriggerInputs(path)
+	if err != nil {
+		t.Fatalf("readTriggerInputs failed: %v", err)
+	}
+	returns, months, err = triggerReturns(replayed.Targets, replayed.Prices, replayed.Start)
+	if err != nil {
+		t.Fatalf("triggerReturns failed: %v", err)
+	}
+	if !reflect.DeepEqual(simulations, simulateTriggers(replayed.Targets, returns, months, replayed.Paths, replayed.Seed)) {
+		t.Error("Expected the replayed inputs to give the same simulations")
+	}
+	if err := os.WriteFile(path, []byte(`{"start": "2024-01"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readTriggerInputs(path); err == nil {
+		t.Error("Expected an error replaying output without inputs")
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"time"
+)
+
+type UnwindStep struct {
+	Date      time.Time      `json:"date"`
+	Sell      int            `json:"sell"`
+	Shares    int            `json:"shares,omitempty"`
+	Gains     int            `json:"gains"`
+	Purchases map[string]int `json:"purchases"`
+}
+
+type UnwindPlan struct {
+	Symbol       string       `json:"symbol"`
+	Excess       int          `json:"excess"`
+	GainFraction float64      `json:"gain_fraction"`
+	Steps        []UnwindStep `json:"steps"`
+	Remaining    int          `json:"remaining"`
+}
+
+func unwind(config *Config, args []string) {
+	var symbol, startStr string
+	var years int
+	gainsBudget, basis := -1, -1
+	flagSet := flag.NewFlagSet("unwind", flag.ExitOnError)
+	flagSet.StringVar(&symbol, "symbol", "", "Overweight symbol to unwind")
+	flagSet.Func("gainsBudget", "Maximum capital gains to realize per calendar year, in dollars", func(value string) error {
+		amount, err := amountToInt(value)
+		gainsBudget = amount
+		return err
+	})
+	flagSet.Func("basis", "Total cost basis of the position, in dollars (defaults to the CSV's Cost Basis Total column)", func(value string) error {
+		amount, err := amountToInt(value)
+		basis = amount
+		return err
+	})
+	flagSet.IntVar(&years, "years", 3, "Number of years to spread the sales over")
+	flagSet.StringVar(&startStr, "start", time.Now().Format(time.DateOnly), "Date of the first sale (YYYY-MM-DD)")
+	if len(args) < 1 {
+		flag.Usage()
+		return
+	}
+	portfolioCsv := args[0]
+	flagSet.Parse(args[1:])
+
+	if symbol == "" || gainsBudget < 0 {
+		printError(codedErrorf(CodeUsage, "-symbol and -gainsBudget are required"))
+		return
+	}
+	start, err := time.Parse(time.DateOnly, startStr)
+	if err != nil {
+		printError(codedErrorf(CodeUsage, "parsing start date: %w", err))
+		return
+	}
+
+	holdings, err := loadPortfolio(config, portfolioCsv)
+	if err != nil {
+		printError(err)
+		return
+	}
+
+	plan, err := unwindCalc(config, holdings, symbol, basis, gainsBudget, years*4, start)
+	if err != nil {
+		printError(err)
+		return
+	}
+
+	fmt.Printf("%s is %s above target; %.1f%% of each sale is a capital gain\n", plan.Symbol, formatAmount(plan.Excess, true), plan.GainFraction*100)
+	for _, step := range plan.Steps {
+		sell := formatAmount(step.Sell, true)
+		if step.Shares > 0 {
+			sell = fmt.Sprintf("%d shares (%s)", step.Shares, sell)
+		}
+		fmt.Println("\n" + rule())
+		fmt.Printf("%s - Sell %s, realizing %s in gains\n", step.Date.Format(time.DateOnly), sell, formatAmount(step.Gains, true))
+		fmt.Println(rule())
+		for _, stock := range config.Stocks {
+			if amount := step.Purchases[stock.Symbol]; amount > 0 {
+				fmt.Printf("%s: %s\n", stock.Symbol, tradeText(amount, formatAmount(amount, true)))
+			}
+		}
+	}
+	fmt.Println("\n" + rule())
+	if plan.Remaining > 0 {
+		fmt.Printf("Still %s above target after %d years; raise the gains budget or extend the horizon\n", formatAmount(plan.Remaining, true), years)
+	} else {
+		fmt.Printf("%s reaches its target within %d years\n", plan.Symbol, years)
+	}
+}
+
+// unwindCalc spreads the sale of a symbol's excess over its target across
+// quarters, selling an even share of what remains each quarter while keeping
+// the gains realized in each calendar year within gainsBudget. Proceeds are
+// reinvested buy-only. A negative basisCents uses the CSV's cost basis.
+func unwindCalc(config *Config, holdings *Holdings, symbol string, basisCents, gainsBudget, quarters int, start time.Time) (*UnwindPlan, error) {
+	if quarters < 1 {
+		return nil, fmt.Errorf("horizon must be at least one quarter, got %d", quarters)
+	}
+	amount, held := holdings.Amounts[symbol]
+	if !held || amount <= 0 {
+		return nil, codedErrorf(CodeUnknownSymbol, "%s is not held in the portfolio", symbol)
+	}
+	// The gain is measured on the rows the cost basis covers: the CSV's is
+	// only for rows of the symbol itself, not its alternatives
+	basisAmount := amount
+	if basisCents < 0 {
+		basis, found := holdings.CostBasis[symbol]
+		if !found {
+			return nil, fmt.Errorf("no cost basis for %s: add a Cost Basis Total column or pass -basis", symbol)
+		}
+		basisCents, basisAmount = basis, 0
+		for _, position := range holdings.Positions {
+			if position.Symbol == symbol && position.Primary == symbol {
+				basisAmount += position.Value
+			}
+		}
+	}
+
+	result, err := allocationCalc(config, holdings, 0)
+	if err != nil {
+		return nil, err
+	}
+	excess := -result.Symbols[symbol].AmountNeeded
+	if excess <= 0 {
+		return nil, errors.New(symbol + " is not above its target")
+	}
+
+	plan := &UnwindPlan{
+		Symbol:       symbol,
+		Excess:       excess,
+		GainFraction: math.Max(0, float64(basisAmount-basisCents)/float64(max(basisAmount, 1))),
+	}
+	price := holdings.Prices[symbol]
+	current := holdings.clone()
+	gainsByYear := make(map[int]int)
+	remaining := excess
+	for q := 0; q < quarters && remaining > 0; q++ {
+		date := addMonths(start, 3*q)
+		quartersLeft := quarters - q
+		sell := (remaining + quartersLeft - 1) / quartersLeft
+		if plan.GainFraction > 0 {
+			budgetLeft := gainsBudget - gainsByYear[date.Year()]
+			sell = min(sell, int(math.Floor(float64(budgetLeft)/plan.GainFraction)))
+		}
+		step := UnwindStep{Date: date}
+		if price > 0 {
+			step.Shares = sell / price
+			sell = step.Shares * price
+		}
+		if sell <= 0 {
+			continue
+		}
+		step.Sell = sell
+		step.Gains = int(math.Round(float64(sell) * plan.GainFraction))
+		gainsByYear[date.Year()] += step.Gains
+
+		current.Amounts[symbol] -= sell
+		step.Purchases = buyOnlyCalc(config, current.Amounts, sell)
+		for s, purchase := range step.Purchases {
+			current.Amounts[s] += purchase
+		}
+		remaining -= sell
+		plan.Steps = append(plan.Steps, step)
+	}
+	plan.Remaining = remaining
+	return plan, nil
+}
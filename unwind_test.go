@@ -0,0 +1,113 @@
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnwindCalc(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "unvested.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	holdings := loadHoldings(t, config, "concentrated.csv")
+	start := time.Date(2026, time.November, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("gains budget limits each calendar year", func(t *testing.T) {
+		plan, err := unwindCalc(config, holdings, "ACME", -1, 500000, 8, start)
+		if err != nil {
+			t.Fatalf("unwindCalc failed: %v", err)
+		}
+		if plan.Excess != 3000000 {
+			t.Errorf("Excess mismatch: got %d, expected 3000000", plan.Excess)
+		}
+		gainsByYear := make(map[int]int)
+		sold := 0
+		for _, step := range plan.Steps {
+			gainsByYear[step.Date.Year()] += step.Gains
+			sold += step.Sell
+			if step.Sell != step.Shares*15000 {
+				t.Errorf("%s: sale of %d is not a whole number of shares", step.Date.Format(time.DateOnly), step.Sell)
+			}
+		}
+		for year, gains := range gainsByYear {
+			if gains > 500000 {
+				t.Errorf("%d: realized %d in gains, over the 500000 budget", year, gains)
+			}
+		}
+		if sold+plan.Remaining != plan.Excess {
+			t.Errorf("Sold %d plus remaining %d does not equal excess %d", sold, plan.Remaining, plan.Excess)
+		}
+		if plan.Remaining == 0 {
+			t.Error("Expected the budget to leave part of the position unsold")
+		}
+	})
+
+	t.Run("large budget reaches target", func(t *testing.T) {
+		plan, err := unwindCalc(config, holdings, "ACME", 3000000, 10000000, 4, start)
+		if err != nil {
+			t.Fatalf("unwindCalc failed: %v", err)
+		}
+		if plan.GainFraction != 0 {
+			t.Errorf("Gain fraction mismatch: got %f, expected 0 when basis equals value", plan.GainFraction)
+		}
+		if len(plan.Steps) != 4 || plan.Remaining != 0 {
+			t.Errorf("Expected 4 sales reaching target, got %d with %d remaining", len(plan.Steps), plan.Remaining)
+		}
+	})
+
+	t.Run("month-end start stays in each quarter's month", func(t *testing.T) {
+		monthEnd := time.Date(2026, time.November, 30, 0, 0, 0, 0, time.UTC)
+		plan, err := unwindCalc(config, holdings, "ACME", 3000000, 10000000, 4, monthEnd)
+		if err != nil {
+			t.Fatalf("unwindCalc failed: %v", err)
+		}
+		expected := []string{"2026-11-30", "2027-02-28", "2027-05-30", "2027-08-30"}
+		var dates []string
+		for _, step := range plan.Steps {
+			dates = append(dates, step.Date.Format(time.DateOnly))
+		}
+		if strings.Join(dates, ",") != strings.Join(expected, ",") {
+			t.Errorf("Dates mismatch: got %v, expected %v", dates, expected)
+		}
+	})
+
+	t.Run("symbol at target", func(t *testing.T) {
+		if _, err := unwindCalc(config, holdings, "BND", -1, 100000, 4, start); err == nil {
+			t.Error("Expected error for a symbol that is not overweight")
+		}
+	})
+
+	t.Run("alternative held", func(t *testing.T) {
+		config, err := parseConfigData([]byte(`stocks:
+  - symbol: VTI
+    target_percentage: 60
+    alternatives: [ITOT]
+  - symbol: BND
+    target_percentage: 40
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		holdings, err := readHoldings(config, strings.NewReader(`Symbol,Quantity,Last Price,Current Value,Cost Basis Total
+VTI,200,$250.00,$50000.00,$40000.00
+ITOT,200,$100.00,$20000.00,$5000.00
+BND,400,$75.00,$30000.00,$30000.00
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		plan, err := unwindCalc(config, holdings, "VTI", -1, 10000000, 4, start)
+		if err != nil {
+			t.Fatalf("unwindCalc failed: %v", err)
+		}
+		// The basis covers VTI's row alone, so ITOT's $20,000 isn't counted
+		// as gain: $10,000 of gain on $50,000
+		if math.Abs(plan.GainFraction-0.2) > 1e-9 {
+			t.Errorf("Gain fraction mismatch: got %f, expected 0.2", plan.GainFraction)
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	urgencyOK        = "ok"
+	urgencyWatch     = "watch"
+	urgencyRebalance = "rebalance now"
+
+	defaultUrgencyWatch     = 1.0
+	defaultUrgencyRebalance = 3.0
+)
+
+// UrgencyConfig sets the drift scores at which the portfolio needs watching
+// or rebalancing
+type UrgencyConfig struct {
+	// Watch is the drift score, in percentage points, from which drift is
+	// worth watching (default 1)
+	Watch float64 `yaml:"watch,omitempty"`
+	// Rebalance is the drift score from which it is time to rebalance (default 3)
+	Rebalance float64 `yaml:"rebalance,omitempty"`
+}
+
+// Urgency summarizes the drift of the whole portfolio
+type Urgency struct {
+	// Score is the target-weighted average of each symbol's absolute drift,
+	// in percentage points
+	Score float64 `json:"score"`
+	// Level is ok, watch, or rebalance now
+	Level string `json:"level"`
+}
+
+// commandUrgency is the urgency the command found, which post hooks can use
+// to raise an alert
+var commandUrgency Urgency
+
+func validateUrgency(config *Config) error {
+	u := config.Urgency
+	if u == nil {
+		return nil
+	}
+	if u.Watch < 0 || u.Rebalance < 0 {
+		return fmt.Errorf("urgency thresholds must not be negative")
+	}
+	if u.watch() >= u.rebalance() {
+		return fmt.Errorf("urgency watch threshold %g must be below the rebalance threshold %g", u.watch(), u.rebalance())
+	}
+	return nil
+}
+
+func (u *UrgencyConfig) watch() float64 {
+	if u == nil || u.Watch == 0 {
+		return defaultUrgencyWatch
+	}
+	return u.Watch
+}
+
+func (u *UrgencyConfig) rebalance() float64 {
+	if u == nil || u.Rebalance == 0 {
+		return defaultUrgencyRebalance
+	}
+	return u.Rebalance
+}
+
+// urgencyCalc scores the drift of every configured symbol, weighted by its
+// target, so that a large holding off by a point counts for more than a
+// small one off by the same, and maps the score to a level. Drift is counted
+// in notional exposure, so a point of a 2x fund counts double.
+func urgencyCalc(config *Config, symbols map[string]SymbolData) Urgency {
+	score := 0.0
+	for _, stock := range config.Stocks {
+		score += stock.TargetPercentage / 100 * math.Abs(symbols[stock.Symbol].Drift*stock.leverage())
+	}
+	level := urgencyOK
+	switch {
+	case score >= config.Urgency.rebalance():
+		level = urgencyRebalance
+	case score >= config.Urgency.watch():
+		level = urgencyWatch
+	}
+	return Urgency{Score: score, Level: level}
+}
+
+// urgencyText describes the urgency for a report, in red when it is time to
+// rebalance
+func urgencyText(u Urgency) string {
+	text := fmt.Sprintf("Drift score: %.2f (%s)", u.Score, u.Level)
+	switch u.Level {
+	case urgencyOK:
+		return green(text)
+	case urgencyRebalance:
+		return red(text)
+	}
+	return text
+}
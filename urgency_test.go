@@ -0,0 +1,69 @@
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestUrgencyCalc(t *testing.T) {
+	stocks := []Stock{{Symbol: "VTI", TargetPercentage: 70}, {Symbol: "BND", TargetPercentage: 30}}
+	tests := []struct {
+		name    string
+		urgency *UrgencyConfig
+		drifts  [2]float64
+		score   float64
+		level   string
+	}{
+		{"on target", nil, [2]float64{0, 0}, 0, urgencyOK},
+		// 0.7 * 1 + 0.3 * 1
+		{"watch", nil, [2]float64{1, -1}, 1, urgencyWatch},
+		// 0.7 * 4 + 0.3 * 4
+		{"rebalance", nil, [2]float64{-4, 4}, 4, urgencyRebalance},
+		{"configured thresholds", &UrgencyConfig{Watch: 5, Rebalance: 10}, [2]float64{-4, 4}, 4, urgencyOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Stocks: stocks, Urgency: tt.urgency}
+			symbols := map[string]SymbolData{"VTI": {Drift: tt.drifts[0]}, "BND": {Drift: tt.drifts[1]}}
+			got := urgencyCalc(config, symbols)
+			if math.Abs(got.Score-tt.score) > 1e-9 || got.Level != tt.level {
+				t.Errorf("Urgency mismatch: got %+v, expected score %g and level %s", got, tt.score, tt.level)
+			}
+		})
+	}
+}
+
+func TestValidateUrgency(t *testing.T) {
+	for _, u := range []*UrgencyConfig{nil, {Watch: 0.5}, {Watch: 2, Rebalance: 6}} {
+		if err := validateUrgency(&Config{Urgency: u}); err != nil {
+			t.Errorf("Expected %+v to be valid, got %v", u, err)
+		}
+	}
+	// The default rebalance threshold of 3 is below this watch threshold
+	for _, u := range []*UrgencyConfig{{Watch: 4}, {Watch: 2, Rebalance: 2}, {Rebalance: -1}} {
+		if err := validateUrgency(&Config{Urgency: u}); err == nil {
+			t.Errorf("Expected %+v to be invalid", u)
+		}
+	}
+}
+
+func TestRebalanceUrgency(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	// 0.71 * 9 + 0.18 * 6 + 0.11 * 3 for the unbalanced portfolio
+	for csvFile, expected := range map[string]Urgency{
+		"balanced.csv":   {Score: 0, Level: urgencyOK},
+		"unbalanced.csv": {Score: 7.8, Level: urgencyRebalance},
+	} {
+		result, err := allocationCalc(config, loadHoldings(t, config, csvFile), 0)
+		if err != nil {
+			t.Fatalf("allocationCalc failed: %v", err)
+		}
+		if math.Abs(result.Urgency.Score-expected.Score) > 1e-9 || result.Urgency.Level != expected.Level {
+			t.Errorf("%s: urgency mismatch: got %+v, expected %+v", csvFile, result.Urgency, expected)
+		}
+	}
+}
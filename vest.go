@@ -0,0 +1,209 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+type UnvestedGrant struct {
+	Symbol string `yaml:"symbol"`
+	// Price values the shares when the portfolio CSV has no Last Price for the symbol
+	Price   Money          `yaml:"price,omitempty"`
+	Vesting []VestingEvent `yaml:"vesting"`
+}
+
+type VestingEvent struct {
+	Date   time.Time `yaml:"date"`
+	Shares float64   `yaml:"shares"`
+}
+
+type VestPlan struct {
+	Symbol       string         `json:"symbol"`
+	Date         time.Time      `json:"date"`
+	Price        int            `json:"price"`
+	SharesVested float64        `json:"shares_vested"`
+	SharesToSell float64        `json:"shares_to_sell"`
+	Proceeds     int            `json:"proceeds"`
+	Purchases    map[string]int `json:"purchases"`
+}
+
+func validateUnvested(config *Config) error {
+	for _, grant := range config.Unvested {
+		if !slices.ContainsFunc(config.Stocks, func(stock Stock) bool { return stock.Symbol == grant.Symbol }) {
+			return codedErrorf(CodeUnknownSymbol, "unvested symbol %s must be listed in stocks (use target_percentage: 0 to diversify out of it)", grant.Symbol)
+		}
+		for _, event := range grant.Vesting {
+			if event.Shares <= 0 {
+				return fmt.Errorf("unvested %s vesting on %s must have a positive number of shares", grant.Symbol, event.Date.Format(time.DateOnly))
+			}
+		}
+	}
+	return nil
+}
+
+// price returns the share price of a grant, preferring the portfolio's last price
+func (g *UnvestedGrant) price(holdings *Holdings) (int, error) {
+	if price := holdings.Prices[g.Symbol]; price > 0 {
+		return price, nil
+	}
+	if g.Price > 0 {
+		return int(g.Price), nil
+	}
+	return 0, fmt.Errorf("no price for unvested %s: add a Last Price column to the CSV or a price to the config", g.Symbol)
+}
+
+// unvestedHoldings returns a copy of holdings with the value of every share
+// still unvested after asOf added to its symbol
+func unvestedHoldings(config *Config, holdings *Holdings, asOf time.Time) (*Holdings, int, error) {
+	combined := holdings.clone()
+	unvestedTotal := 0
+	for _, grant := range config.Unvested {
+		price, err := grant.price(holdings)
+		if err != nil {
+			return nil, 0, err
+		}
+		shares := 0.0
+		for _, event := range grant.Vesting {
+			if event.Date.After(asOf) {
+				shares += event.Shares
+			}
+		}
+		value := int(math.Round(shares * float64(price)))
+		combined.Amounts[grant.Symbol] += value
+		unvestedTotal += value
+	}
+	return combined, unvestedTotal, nil
+}
+
+func printUnvestedAllocation(config *Config, holdings *Holdings, depositCents int) error {
+	combined, unvestedTotal, err := unvestedHoldings(config, holdings, time.Now())
+	if err != nil {
+		return err
+	}
+	if unvestedTotal == 0 {
+		return nil
+	}
+	result, err := allocationCalc(config, combined, depositCents)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("\n" + rule())
+	fmt.Printf("Including %s of unvested equity\n", formatAmount(unvestedTotal, true))
+	fmt.Println(rule())
+	for _, stock := range config.Stocks {
+		data := result.Symbols[stock.Symbol]
+		fmt.Printf("%s - %.2f%% (%s)\n", stock.Symbol, data.CurrentPercentage, driftText(data.Drift))
+	}
+	fmt.Printf("Total: %s\n", formatAmount(result.Total, true))
+	return nil
+}
+
+func vest(config *Config, args []string) {
+	var dateStr, symbol string
+	var shares float64
+	flagSet := flag.NewFlagSet("vest", flag.ExitOnError)
+	flagSet.StringVar(&dateStr, "date", time.Now().Format(time.DateOnly), "Plan for the most recent vest on or before this date (YYYY-MM-DD)")
+	flagSet.StringVar(&symbol, "symbol", "", "Only plan for this unvested symbol")
+	flagSet.Float64Var(&shares, "shares", 0, "Override the number of newly vested shares")
+	if len(args) < 1 {
+		flag.Usage()
+		return
+	}
+	portfolioCsv := args[0]
+	flagSet.Parse(args[1:])
+
+	asOf, err := time.Parse(time.DateOnly, dateStr)
+	if err != nil {
+		printError(codedErrorf(CodeUsage, "parsing date: %w", err))
+		return
+	}
+
+	holdings, err := loadPortfolio(config, portfolioCsv)
+	if err != nil {
+		printError(err)
+		return
+	}
+
+	plans, err := vestCalc(config, holdings, asOf, symbol, shares)
+	if err != nil {
+		printError(err)
+		return
+	}
+
+	for _, plan := range plans {
+		fmt.Println("\n" + rule())
+		fmt.Printf("%s vested %s: %g shares (%s)\n", plan.Symbol, plan.Date.Format(time.DateOnly), plan.SharesVested, formatAmount(int(math.Round(plan.SharesVested*float64(plan.Price))), true))
+		fmt.Println(rule())
+		fmt.Printf("Sell: %s\n", red(fmt.Sprintf("%g shares (%s)", plan.SharesToSell, formatAmount(plan.Proceeds, true))))
+		fmt.Printf("Keep: %g shares\n", plan.SharesVested-plan.SharesToSell)
+		if plan.Proceeds == 0 {
+			continue
+		}
+		fmt.Println("Reinvest proceeds:")
+		for _, stock := range config.Stocks {
+			if amount := plan.Purchases[stock.Symbol]; amount > 0 {
+				fmt.Printf("  %s: %s\n", stock.Symbol, tradeText(amount, formatAmount(amount, true)))
+			}
+		}
+	}
+}
+
+// vestCalc plans what to do with the shares from the most recent vest of each
+// grant on or before asOf. The portfolio is assumed to already include the
+// vested shares. Whole shares are sold until the symbol is back at its target
+// (all of them for a 0% target) and the proceeds are reinvested buy-only.
+func vestCalc(config *Config, holdings *Holdings, asOf time.Time, symbol string, sharesOverride float64) ([]VestPlan, error) {
+	current := holdings.clone()
+
+	var plans []VestPlan
+	for _, grant := range config.Unvested {
+		if symbol != "" && grant.Symbol != symbol {
+			continue
+		}
+		var latest *VestingEvent
+		for i, event := range grant.Vesting {
+			if !event.Date.After(asOf) && (latest == nil || event.Date.After(latest.Date)) {
+				latest = &grant.Vesting[i]
+			}
+		}
+		if latest == nil {
+			continue
+		}
+		price, err := grant.price(holdings)
+		if err != nil {
+			return nil, err
+		}
+		plan := VestPlan{
+			Symbol:       grant.Symbol,
+			Date:         latest.Date,
+			Price:        price,
+			SharesVested: latest.Shares,
+		}
+		if sharesOverride > 0 {
+			plan.SharesVested = sharesOverride
+		}
+
+		result, err := allocationCalc(config, current, 0)
+		if err != nil {
+			return nil, err
+		}
+		if overweight := -result.Symbols[grant.Symbol].AmountNeeded; overweight > 0 {
+			plan.SharesToSell = math.Min(plan.SharesVested, math.Floor(float64(overweight)/float64(price)))
+		}
+		plan.Proceeds = int(math.Round(plan.SharesToSell * float64(price)))
+		current.Amounts[grant.Symbol] -= plan.Proceeds
+		plan.Purchases = buyOnlyCalc(config, current.Amounts, plan.Proceeds)
+		for s, amount := range plan.Purchases {
+			current.Amounts[s] += amount
+		}
+		plans = append(plans, plan)
+	}
+	if len(plans) == 0 {
+		return nil, fmt.Errorf("no vesting events on or before %s", asOf.Format(time.DateOnly))
+	}
+	return plans, nil
+}
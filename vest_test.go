@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func loadHoldings(t *testing.T, config *Config, csvFile string) *Holdings {
+	t.Helper()
+	file, err := os.Open(filepath.Join("tests", "portfolios", csvFile))
+	if err != nil {
+		t.Fatalf("Failed to open CSV file: %v", err)
+	}
+	defer file.Close()
+	holdings, err := readHoldings(config, file)
+	if err != nil {
+		t.Fatalf("readHoldings failed: %v", err)
+	}
+	return holdings
+}
+
+func TestVestCalc(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "unvested.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	holdings := loadHoldings(t, config, "rsu.csv")
+	asOf := time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)
+
+	combined, unvested, err := unvestedHoldings(config, holdings, asOf)
+	if err != nil {
+		t.Fatalf("unvestedHoldings failed: %v", err)
+	}
+	if unvested != 600000 || combined.Amounts["ACME"] != 1800000 {
+		t.Errorf("Unvested value mismatch: got %d (ACME %d), expected 600000 (ACME 1800000)", unvested, combined.Amounts["ACME"])
+	}
+	if holdings.Amounts["ACME"] != 1200000 {
+		t.Errorf("unvestedHoldings modified the original holdings")
+	}
+
+	plans, err := vestCalc(config, holdings, asOf, "", 0)
+	if err != nil {
+		t.Fatalf("vestCalc failed: %v", err)
+	}
+	if len(plans) != 1 {
+		t.Fatalf("Plan count mismatch: got %d, expected 1", len(plans))
+	}
+	plan := plans[0]
+	if !plan.Date.Equal(time.Date(2026, time.September, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Vest date mismatch: got %s", plan.Date)
+	}
+	if plan.SharesToSell != 40 || plan.Proceeds != 600000 {
+		t.Errorf("Sale mismatch: got %g shares for %d, expected 40 shares for 600000", plan.SharesToSell, plan.Proceeds)
+	}
+	if plan.Purchases["VTI"] != 426000 || plan.Purchases["ACME"] != 0 {
+		t.Errorf("Purchases mismatch: got %v", plan.Purchases)
+	}
+
+	if _, err := vestCalc(config, holdings, time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC), "", 0); err == nil {
+		t.Error("Expected error when nothing has vested yet")
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+)
+
+// Warning codes are stable identifiers for findings that don't stop a
+// command, like the error codes
+const (
+	// WarningSkippedRow means a CSV row of a configured symbol had no value
+	// and was left out
+	WarningSkippedRow = "W_SKIPPED_ROW"
+	// WarningUnmatchedSymbol means a CSV holds a symbol the config doesn't
+	// list, whose value was left out
+	WarningUnmatchedSymbol = "W_UNMATCHED_SYMBOL"
+	// WarningStaleExport means the export is older than stale_after
+	WarningStaleExport = "W_STALE_EXPORT"
+	// WarningValueMismatch means a CSV row's value disagrees with its
+	// quantity times price
+	WarningValueMismatch = "W_VALUE_MISMATCH"
+)
+
+// defaultValueTolerance is how far, in percent, a row's value may be from its
+// quantity times price without a value_tolerance setting
+const defaultValueTolerance = 1.0
+
+// valueToleranceFloor is the difference in cents always allowed between a
+// row's value and its quantity times price, for prices rounded to the cent
+const valueToleranceFloor = 100
+
+// Warning is a finding about the inputs that doesn't stop a command. Reading
+// a portfolio returns them with the holdings instead of printing them, so the
+// command line, JSON output, and server can each show them their own way.
+type Warning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Symbol  string `json:"symbol,omitempty"`
+	// Line is the CSV line of the row the warning is about
+	Line int `json:"line,omitempty"`
+}
+
+// tickerPattern matches the symbols of unmatched rows worth a warning, which
+// leaves out the account names, repeated headers, and notes some exports mix
+// in with their positions
+var tickerPattern = regexp.MustCompile(`^[A-Z][A-Z0-9./-]{0,11}$`)
+
+// unmatchedSymbol reports whether an unmatched row's symbol looks like a
+// holding rather than a total row, such as E*TRADE's TOTAL
+func unmatchedSymbol(symbol string) bool {
+	return tickerPattern.MatchString(symbol) && symbol != "TOTAL"
+}
+
+func (c *Config) valueTolerance() float64 {
+	if c.ValueTolerance != nil {
+		return *c.ValueTolerance
+	}
+	return defaultValueTolerance
+}
+
+// reconcileValue checks a position's value against its quantity times price,
+// when the row on line has both, returning a warning if they're further apart
+// than the config's value_tolerance. They disagree when an export is corrupt
+// or was partly edited by hand.
+func reconcileValue(config *Config, position Position, line int) *Warning {
+	if position.Quantity == 0 || position.Price == 0 {
+		return nil
+	}
+	expected := int(math.Round(position.Quantity * float64(position.Price)))
+	difference := abs(position.Value - expected)
+	if difference <= valueToleranceFloor || float64(difference) <= math.Abs(float64(expected))*config.valueTolerance()/100 {
+		return nil
+	}
+	return &Warning{Code: WarningValueMismatch, Symbol: position.Symbol, Line: line,
+		Message: fmt.Sprintf("the %s row on line %d is valued at %s, but %g shares at %s are worth %s; check the export", position.Symbol, line, formatAmount(position.Value, true), position.Quantity, formatAmount(position.Price, true), formatAmount(expected, true))}
+}
+
+func printWarnings(w io.Writer, warnings []Warning) {
+	for _, warning := range warnings {
+		fmt.Fprintln(w, red("Warning: "+warning.Message))
+	}
+}
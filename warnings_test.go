@@ -0,0 +1,88 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHoldingsWarnings(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	csv := `Symbol,Description,Current Value
+VTI,Total Stock,$71000.00
+AAPL,Apple,$600.00
+BND
+VXUS,Total Intl,$18000.00
+AAPL,Apple,$400.00
+Pending Activity,,$100.00
+TOTAL,,$90100.00
+`
+	holdings, err := readHoldings(config, strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("readHoldings failed: %v", err)
+	}
+	// The cut-off BND row and AAPL are reported, AAPL's rows summed; cash and
+	// the total row aren't
+	expected := []Warning{
+		{Code: WarningSkippedRow, Symbol: "BND", Line: 4, Message: "the BND row on line 4 has no value and was skipped"},
+		{Code: WarningUnmatchedSymbol, Symbol: "AAPL", Message: "AAPL isn't in the config, so its $1,000.00 was left out"},
+	}
+	if !reflect.DeepEqual(holdings.Warnings, expected) {
+		t.Errorf("Warning mismatch:\ngot      %+v\nexpected %+v", holdings.Warnings, expected)
+	}
+
+	// The warnings come back with the rebalance
+	result, err := allocationCalc(config, holdings, 0)
+	if err != nil {
+		t.Fatalf("allocationCalc failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.Warnings, expected) {
+		t.Errorf("Expected the holdings' warnings in the result, got %+v", result.Warnings)
+	}
+	if summary := driftSummary(config, result); !strings.Contains(summary, "Warning: AAPL isn't in the config") {
+		t.Errorf("Expected the warnings in the server's summary, got %q", summary)
+	}
+
+	holdings.merge(&Holdings{Warnings: []Warning{{Code: WarningStaleExport, Message: "stale"}}})
+	if len(holdings.Warnings) != 3 {
+		t.Errorf("Expected merged holdings to keep both sources' warnings, got %+v", holdings.Warnings)
+	}
+}
+
+func TestReconcileValue(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "simple.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	// BND's value lost a digit in editing; VTI's is off by rounding, and
+	// VXUS has no quantity to check
+	csv := `Symbol,Quantity,Last Price,Current Value
+VTI,284,$250.00,$71000.50
+BND,150,$73.33,$1100.00
+VXUS,,$60.00,$18000.00
+`
+	holdings, err := readHoldings(config, strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("readHoldings failed: %v", err)
+	}
+	expected := []Warning{{Code: WarningValueMismatch, Symbol: "BND", Line: 3,
+		Message: "the BND row on line 3 is valued at $1,100.00, but 150 shares at $73.33 are worth $10,999.50; check the export"}}
+	if !reflect.DeepEqual(holdings.Warnings, expected) {
+		t.Errorf("Warning mismatch:\ngot      %+v\nexpected %+v", holdings.Warnings, expected)
+	}
+
+	// A 2% difference passes a looser tolerance
+	position := Position{Symbol: "VTI", Quantity: 100, Price: 10000, Value: 1020000}
+	if reconcileValue(config, position, 2) == nil {
+		t.Error("Expected a 2% difference flagged by default")
+	}
+	tolerance := 5.0
+	config.ValueTolerance = &tolerance
+	if warning := reconcileValue(config, position, 2); warning != nil {
+		t.Errorf("Expected a 2%% difference within a 5%% tolerance, got %+v", warning)
+	}
+}
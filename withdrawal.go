@@ -0,0 +1,258 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+// rmdAccountTypes are the account types with required minimum distributions
+var rmdAccountTypes = []string{"traditional-ira", "401k", "403b", "457b"}
+
+// uniformLifetime is the IRS Uniform Lifetime Table distribution period for
+// each age from 72 on; ages past the table use its last entry
+var uniformLifetime = []float64{
+	27.4, 26.5, 25.5, 24.6, 23.7, 22.9, 22.0, 21.1, 20.2, 19.4, // 72-81
+	18.5, 17.7, 16.8, 16.0, 15.2, 14.4, 13.7, 12.9, 12.2, 11.5, // 82-91
+	10.8, 10.1, 9.5, 8.9, 8.4, 7.8, 7.3, 6.8, 6.4, 6.0, // 92-101
+	5.6, 5.2, 4.9, 4.6, 4.3, 4.1, 3.9, 3.7, 3.5, 3.4, // 102-111
+	3.3, 3.1, 3.0, 2.9, 2.8, 2.7, 2.5, 2.3, 2.0, // 112-120
+}
+
+type WithdrawalConfig struct {
+	// Birthdate of the account owner, which sets when RMDs start and their size
+	Birthdate time.Time `yaml:"birthdate,omitempty"`
+	// Rate is the safe withdrawal rate as a percentage of the portfolio
+	Rate float64 `yaml:"rate,omitempty"`
+}
+
+type RequiredDistribution struct {
+	Account string  `json:"account"`
+	Balance int     `json:"balance"`
+	Divisor float64 `json:"divisor"`
+	Amount  int     `json:"amount"`
+}
+
+type WithdrawalSale struct {
+	Symbol  string `json:"symbol"`
+	Account string `json:"account,omitempty"`
+	Amount  int    `json:"amount"`
+	// DriftAfter is the symbol's drift once the whole withdrawal is taken
+	DriftAfter float64 `json:"drift_after"`
+}
+
+type WithdrawalPlan struct {
+	Year int `json:"year"`
+	// Age is the age reached during Year, when a birthdate is configured
+	Age int `json:"age,omitempty"`
+	// RMDStartYear is the first year distributions are required
+	RMDStartYear int                    `json:"rmd_start_year,omitempty"`
+	RMDs         []RequiredDistribution `json:"rmds,omitempty"`
+	RMDTotal     int                    `json:"rmd_total"`
+	Rate         float64                `json:"rate,omitempty"`
+	RateAmount   int                    `json:"rate_amount,omitempty"`
+	// Amount is the larger of the RMD total and the safe withdrawal
+	Amount int              `json:"amount"`
+	Sales  []WithdrawalSale `json:"sales"`
+}
+
+func validateWithdrawal(config *Config) error {
+	if config.Withdrawal != nil && (config.Withdrawal.Rate < 0 || config.Withdrawal.Rate > 100) {
+		return fmt.Errorf("withdrawal rate must be between 0 and 100")
+	}
+	return nil
+}
+
+// rmdStartAge is the age RMDs start at under SECURE 2.0 for a birth year
+func rmdStartAge(birthYear int) int {
+	switch {
+	case birthYear < 1951:
+		return 72
+	case birthYear < 1960:
+		return 73
+	}
+	return 75
+}
+
+// rmdDivisor returns the Uniform Lifetime Table distribution period for age
+func rmdDivisor(age int) float64 {
+	return uniformLifetime[min(max(age-72, 0), len(uniformLifetime)-1)]
+}
+
+// accountBalance is the value of every row in a configured account
+func accountBalance(holdings *Holdings, name string) int {
+	if balance, ok := holdings.AccountTotals[name]; ok {
+		return balance
+	}
+	balance := holdings.Cash[name]
+	for _, amounts := range holdings.AmountsByAccount {
+		balance += amounts[name]
+	}
+	return balance
+}
+
+func withdrawalPlan(config *Config, args []string) {
+	var format string
+	var year int
+	rate := -1.0
+	flagSet := flag.NewFlagSet("withdrawal-plan", flag.ExitOnError)
+	flagSet.Float64Var(&rate, "rate", -1, "Safe withdrawal rate as a percentage of the portfolio (defaults to withdrawal.rate)")
+	flagSet.IntVar(&year, "year", time.Now().Year(), "Year to plan withdrawals for")
+	flagSet.StringVar(&format, "format", "text", "Output format: text or json")
+	if len(args) < 1 {
+		flag.Usage()
+		return
+	}
+	portfolioCsv := args[0]
+	flagSet.Parse(args[1:])
+	if format != "text" && format != "json" {
+		printError(codedErrorf(CodeUsage, "unknown format %q", format))
+		return
+	}
+	if rate < 0 && config.Withdrawal != nil {
+		rate = config.Withdrawal.Rate
+	}
+
+	holdings, err := loadPortfolio(config, portfolioCsv)
+	if err != nil {
+		printError(err)
+		return
+	}
+	plan, err := withdrawalCalc(config, holdings, max(rate, 0), year)
+	if err != nil {
+		printError(err)
+		return
+	}
+
+	if format == "json" {
+		if err := writeJSON(os.Stdout, plan); err != nil {
+			printError(err)
+		}
+		return
+	}
+
+	if plan.Age > 0 {
+		if len(plan.RMDs) > 0 {
+			fmt.Printf("Age %d in %d; required minimum distributions:\n", plan.Age, plan.Year)
+			for _, rmd := range plan.RMDs {
+				fmt.Printf("%s: %s (%s / %.1f)\n", rmd.Account, formatAmount(rmd.Amount, true), formatAmount(rmd.Balance, true), rmd.Divisor)
+			}
+			fmt.Printf("Total RMD: %s\n", formatAmount(plan.RMDTotal, true))
+		} else if plan.Year < plan.RMDStartYear {
+			fmt.Printf("Age %d in %d; required minimum distributions start in %d\n", plan.Age, plan.Year, plan.RMDStartYear)
+		} else {
+			fmt.Printf("Age %d in %d; no accounts with required minimum distributions\n", plan.Age, plan.Year)
+		}
+	}
+	if plan.Rate > 0 {
+		fmt.Printf("Safe withdrawal at %.2f%%: %s\n", plan.Rate, formatAmount(plan.RateAmount, true))
+	}
+	fmt.Println("\n" + rule())
+	fmt.Printf("Withdraw %s\n", formatAmount(plan.Amount, true))
+	fmt.Println(rule())
+	for _, sale := range plan.Sales {
+		line := fmt.Sprintf("%s: %s", sale.Symbol, tradeText(-sale.Amount, formatAmount(-sale.Amount, true)))
+		if sale.Account != "" {
+			line += " in " + sale.Account
+		}
+		fmt.Printf("%s (drift after %s)\n", line, driftText(sale.DriftAfter))
+	}
+}
+
+// withdrawalCalc works out the year's withdrawal, the larger of the required
+// minimum distributions and rate percent of the portfolio, and the sales that
+// raise it with the least drift. RMDs are estimated from current balances
+// rather than the previous year-end's.
+func withdrawalCalc(config *Config, holdings *Holdings, rate float64, year int) (*WithdrawalPlan, error) {
+	withdrawal := config.Withdrawal
+	if rate <= 0 && (withdrawal == nil || withdrawal.Birthdate.IsZero()) {
+		return nil, codedErrorf(CodeUsage, "set withdrawal.birthdate for required minimum distributions, or a withdrawal rate")
+	}
+
+	plan := &WithdrawalPlan{Year: year, Rate: rate}
+	rmdAccounts := make(map[string]bool)
+	if withdrawal != nil && !withdrawal.Birthdate.IsZero() {
+		plan.Age = year - withdrawal.Birthdate.Year()
+		plan.RMDStartYear = withdrawal.Birthdate.Year() + rmdStartAge(withdrawal.Birthdate.Year())
+		for _, account := range config.Accounts {
+			if !slices.Contains(rmdAccountTypes, account.Type) {
+				continue
+			}
+			rmdAccounts[account.Name] = true
+			balance := accountBalance(holdings, account.Name)
+			if year < plan.RMDStartYear || balance <= 0 {
+				continue
+			}
+			// Round up so the distribution is never a cent short
+			rmd := RequiredDistribution{Account: account.Name, Balance: balance, Divisor: rmdDivisor(plan.Age)}
+			rmd.Amount = config.round(float64(balance)/rmd.Divisor, "ceil")
+			plan.RMDs = append(plan.RMDs, rmd)
+			plan.RMDTotal += rmd.Amount
+		}
+	}
+
+	total := 0
+	for _, stock := range config.Stocks {
+		total += holdings.Amounts[stock.Symbol]
+	}
+	if rate > 0 {
+		plan.RateAmount = config.round(float64(total)*rate/100, "floor")
+	}
+	plan.Amount = max(plan.RMDTotal, plan.RateAmount)
+	if plan.Amount == 0 {
+		return plan, nil
+	}
+	if plan.Amount > total {
+		return nil, fmt.Errorf("withdrawal of %s exceeds portfolio value of %s", formatAmount(plan.Amount, true), formatAmount(total, true))
+	}
+
+	sales := sellOnlyCalc(config, holdings.Amounts, plan.Amount)
+	// Rounding each sale down can leave the withdrawal a few cents short; the
+	// largest sale makes up the difference
+	largest, sold := "", 0
+	for _, stock := range config.Stocks {
+		if largest == "" || sales[stock.Symbol] > sales[largest] {
+			largest = stock.Symbol
+		}
+		sold += sales[stock.Symbol]
+	}
+	sales[largest] += plan.Amount - sold
+
+	after := holdings.clone()
+	for symbol, amount := range sales {
+		after.Amounts[symbol] -= amount
+	}
+	result, err := allocationCalc(config, after, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, stock := range config.Stocks {
+		if sales[stock.Symbol] <= 0 {
+			continue
+		}
+		plan.Sales = append(plan.Sales, WithdrawalSale{
+			Symbol:     stock.Symbol,
+			Account:    withdrawalAccount(holdings.AmountsByAccount[stock.Symbol], rmdAccounts),
+			Amount:     sales[stock.Symbol],
+			DriftAfter: result.Symbols[stock.Symbol].Drift,
+		})
+	}
+	return plan, nil
+}
+
+// withdrawalAccount picks where to sell a symbol: the RMD account holding the
+// most of it, so sales count toward distributions, or else its trade account
+func withdrawalAccount(amounts map[string]int, rmdAccounts map[string]bool) string {
+	inRMDAccounts := make(map[string]int)
+	for account, amount := range amounts {
+		if rmdAccounts[account] {
+			inRMDAccounts[account] = amount
+		}
+	}
+	if len(inRMDAccounts) > 0 {
+		return tradeAccount(inRMDAccounts)
+	}
+	return tradeAccount(amounts)
+}
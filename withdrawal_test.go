@@ -0,0 +1,84 @@
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestWithdrawalCalc(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "withdrawal.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	holdings := loadHoldings(t, config, "withdrawal.csv")
+
+	plan, err := withdrawalCalc(config, holdings, config.Withdrawal.Rate, 2026)
+	if err != nil {
+		t.Fatalf("withdrawalCalc failed: %v", err)
+	}
+	if plan.Age != 75 || plan.RMDStartYear != 2024 {
+		t.Errorf("Age mismatch: got %d starting %d, expected 75 starting 2024", plan.Age, plan.RMDStartYear)
+	}
+	// $80,000 in the traditional IRA over a 24.6 year distribution period
+	if len(plan.RMDs) != 1 || plan.RMDs[0].Account != "Traditional IRA" || plan.RMDTotal != 325204 {
+		t.Errorf("RMD mismatch: got %+v totaling %d, expected Traditional IRA totaling 325204", plan.RMDs, plan.RMDTotal)
+	}
+	// The 4% withdrawal is larger than the RMD, so it sets the amount
+	if plan.RateAmount != 400000 || plan.Amount != 400000 {
+		t.Errorf("Amount mismatch: got %d (rate %d), expected 400000", plan.Amount, plan.RateAmount)
+	}
+
+	expected := []WithdrawalSale{
+		{Symbol: "VTI", Account: "Traditional IRA", Amount: 75000, DriftAfter: 1.71875},
+		{Symbol: "BND", Account: "Traditional IRA", Amount: 325000, DriftAfter: 7.447917},
+	}
+	if len(plan.Sales) != len(expected) {
+		t.Fatalf("Sale count mismatch: got %+v", plan.Sales)
+	}
+	for i, e := range expected {
+		actual := plan.Sales[i]
+		if actual.Symbol != e.Symbol || actual.Account != e.Account || actual.Amount != e.Amount || math.Abs(actual.DriftAfter-e.DriftAfter) > 0.0001 {
+			t.Errorf("Sale %d mismatch: got %+v, expected %+v", i, actual, e)
+		}
+	}
+}
+
+func TestWithdrawalCalcBeforeRMDs(t *testing.T) {
+	config, err := parseConfig(filepath.Join("tests", "configs", "withdrawal.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	holdings := loadHoldings(t, config, "withdrawal.csv")
+
+	plan, err := withdrawalCalc(config, holdings, 0, 2023)
+	if err != nil {
+		t.Fatalf("withdrawalCalc failed: %v", err)
+	}
+	if len(plan.RMDs) != 0 || plan.Amount != 0 || len(plan.Sales) != 0 {
+		t.Errorf("Expected nothing to withdraw before RMDs start, got %+v", plan)
+	}
+
+	config.Withdrawal = nil
+	if _, err := withdrawalCalc(config, holdings, 0, 2026); errorCode(err) != CodeUsage {
+		t.Errorf("Expected %s without a birthdate or rate, got %v", CodeUsage, err)
+	}
+}
+
+func TestRMDDivisor(t *testing.T) {
+	tests := []struct {
+		age      int
+		expected float64
+	}{
+		{72, 27.4},
+		{75, 24.6},
+		{90, 12.2},
+		{120, 2.0},
+		{125, 2.0},
+	}
+	for _, tt := range tests {
+		if actual := rmdDivisor(tt.age); actual != tt.expected {
+			t.Errorf("rmdDivisor(%d) = %v, expected %v", tt.age, actual, tt.expected)
+		}
+	}
+}